@@ -0,0 +1,266 @@
+package sprout
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	agentActivityFile = "activity.json"
+	// activityHours is the window covered by the repo activity heatmap.
+	activityHours = 24
+	// agentActivityRetention bounds how many hourly buckets are kept on disk.
+	agentActivityRetention = 48 * time.Hour
+	// activityCommitWeight is how many agent output lines a commit counts
+	// for when both are folded into one heatmap intensity.
+	activityCommitWeight = 50
+)
+
+var agentActivityMu sync.Mutex
+
+// agentActivity stores agent output volume in lines per worktree, bucketed
+// by Unix hour.
+type agentActivity struct {
+	Worktrees map[string]map[int64]int `json:"worktrees"`
+}
+
+// RepoActivity is the hourly activity of a repository over the last
+// activityHours hours; index 0 is the oldest hour.
+type RepoActivity struct {
+	Commits    [activityHours]int
+	AgentLines [activityHours]int
+}
+
+func agentActivityPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "sprout", agentActivityFile), nil
+}
+
+func readAgentActivity() (agentActivity, error) {
+	activity := agentActivity{Worktrees: map[string]map[int64]int{}}
+	path, err := agentActivityPath()
+	if err != nil {
+		return activity, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return activity, nil
+		}
+		return activity, err
+	}
+	if err := json.Unmarshal(data, &activity); err != nil {
+		return agentActivity{Worktrees: map[string]map[int64]int{}}, err
+	}
+	if activity.Worktrees == nil {
+		activity.Worktrees = map[string]map[int64]int{}
+	}
+	return activity, nil
+}
+
+func writeAgentActivity(activity agentActivity) error {
+	path, err := agentActivityPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(activity)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// recordAgentActivity adds per-worktree output line counts to the bucket of
+// the current hour and drops buckets older than agentActivityRetention.
+func recordAgentActivity(deltas map[string]int, now time.Time) error {
+	if len(deltas) == 0 {
+		return nil
+	}
+	agentActivityMu.Lock()
+	defer agentActivityMu.Unlock()
+
+	activity, err := readAgentActivity()
+	if err != nil {
+		errorLogf("agent_activity read failed: %v", err)
+	}
+	hour := now.Unix() / 3600
+	oldest := now.Add(-agentActivityRetention).Unix() / 3600
+	for path, lines := range deltas {
+		buckets := activity.Worktrees[path]
+		if buckets == nil {
+			buckets = map[int64]int{}
+			activity.Worktrees[path] = buckets
+		}
+		buckets[hour] += lines
+	}
+	for path, buckets := range activity.Worktrees {
+		for h := range buckets {
+			if h < oldest {
+				delete(buckets, h)
+			}
+		}
+		if len(buckets) == 0 {
+			delete(activity.Worktrees, path)
+		}
+	}
+	return writeAgentActivity(activity)
+}
+
+// agentPaneSample is the scrollback size of an agent pane at one point in time.
+type agentPaneSample struct {
+	Path    string
+	History int
+}
+
+// sampleAgentPanes reads the scrollback size of every agent pane in every
+// tmux session, keyed by pane id.
+func (m *Manager) sampleAgentPanes() (map[string]agentPaneSample, error) {
+	out, err := m.runCmdOutput("", "tmux", "list-panes", "-a", "-F", "#{pane_id}\t#{window_name}\t#{session_path}\t#{history_size}\t#{cursor_y}")
+	if err != nil {
+		// No server running means no panes.
+		return map[string]agentPaneSample{}, nil
+	}
+	samples := map[string]agentPaneSample{}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 5 || !strings.HasPrefix(fields[1], "agent-") {
+			continue
+		}
+		history, _ := strconv.Atoi(fields[3])
+		cursor, _ := strconv.Atoi(fields[4])
+		samples[fields[0]] = agentPaneSample{Path: fields[2], History: history + cursor}
+	}
+	return samples, nil
+}
+
+// agentOutputDeltas turns two pane samples into output lines per worktree.
+// Panes seen for the first time only establish a baseline. The count stops
+// growing once a pane's scrollback is full, so it is a lower bound.
+func agentOutputDeltas(prev, cur map[string]agentPaneSample) map[string]int {
+	deltas := map[string]int{}
+	for id, sample := range cur {
+		before, ok := prev[id]
+		if !ok || sample.Path == "" {
+			continue
+		}
+		if d := sample.History - before.History; d > 0 {
+			deltas[sample.Path] += d
+		}
+	}
+	return deltas
+}
+
+// RepoActivity returns the commits and agent output of a repository's
+// worktrees per hour over the last activityHours hours.
+func (m *Manager) RepoActivity(repoRoot string, now time.Time) (RepoActivity, error) {
+	var act RepoActivity
+	endHour := now.Unix() / 3600
+	bucket := func(hour int64) int {
+		idx := activityHours - 1 - int(endHour-hour)
+		if idx < 0 || idx >= activityHours {
+			return -1
+		}
+		return idx
+	}
+
+	since := fmt.Sprintf("--since=%d", now.Add(-activityHours*time.Hour).Unix())
+	out, err := m.runCmdOutput(repoRoot, "git", "log", "--all", "--no-merges", since, "--format=%ct")
+	if err != nil {
+		return act, err
+	}
+	for _, line := range strings.Split(out, "\n") {
+		ts, err := strconv.ParseInt(strings.TrimSpace(line), 10, 64)
+		if err != nil {
+			continue
+		}
+		if idx := bucket(ts / 3600); idx >= 0 {
+			act.Commits[idx]++
+		}
+	}
+
+	items, err := m.parseWorktreeList(repoRoot)
+	if err != nil {
+		return act, err
+	}
+	agentActivityMu.Lock()
+	activity, err := readAgentActivity()
+	agentActivityMu.Unlock()
+	if err != nil {
+		errorLogf("agent_activity read failed: %v", err)
+	}
+	for _, wt := range items {
+		for hour, lines := range activity.Worktrees[wt.Path] {
+			if idx := bucket(hour); idx >= 0 {
+				act.AgentLines[idx] += lines
+			}
+		}
+	}
+	return act, nil
+}
+
+func (a RepoActivity) hourScore(i int) int {
+	return a.Commits[i]*activityCommitWeight + a.AgentLines[i]
+}
+
+// MaxScore is the busiest hour's combined commit and agent activity.
+func (a RepoActivity) MaxScore() int {
+	max := 0
+	for i := range a.Commits {
+		if s := a.hourScore(i); s > max {
+			max = s
+		}
+	}
+	return max
+}
+
+// Totals returns the commits and agent output lines across all hours.
+func (a RepoActivity) Totals() (commits, lines int) {
+	for i := range a.Commits {
+		commits += a.Commits[i]
+		lines += a.AgentLines[i]
+	}
+	return commits, lines
+}
+
+var activityShades = []rune{'·', '░', '▒', '▓', '█'}
+
+// activityHeatmap renders one character per hour, shaded relative to max so
+// that several repositories can be compared on one scale.
+func activityHeatmap(a RepoActivity, max int) string {
+	var b strings.Builder
+	for i := range a.Commits {
+		level := 0
+		if score := a.hourScore(i); score > 0 && max > 0 {
+			level = 1 + score*(len(activityShades)-2)/max
+			if level >= len(activityShades) {
+				level = len(activityShades) - 1
+			}
+		}
+		b.WriteRune(activityShades[level])
+	}
+	return b.String()
+}
+
+// compactCount formats n as 950, 1.2k, or 3.4M.
+func compactCount(n int) string {
+	switch {
+	case n >= 1_000_000:
+		return fmt.Sprintf("%.1fM", float64(n)/1_000_000)
+	case n >= 1_000:
+		return fmt.Sprintf("%.1fk", float64(n)/1_000)
+	}
+	return strconv.Itoa(n)
+}
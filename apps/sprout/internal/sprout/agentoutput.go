@@ -0,0 +1,155 @@
+package sprout
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const agentOutputFollowInterval = time.Second
+
+// AgentOutputOptions selects what sprout agent output prints.
+type AgentOutputOptions struct {
+	Target string
+	// Lines is how many of the last lines to print, scrollback included.
+	Lines int
+	// StripANSI drops colors and other escape sequences.
+	StripANSI bool
+}
+
+// tmuxCapturePaneLines captures a pane's output as lines, from start rows back
+// in the scrollback (0 for the visible screen only), without the cursor the
+// TUI draws. Wrapped lines are joined and the blank rows below the last
+// output are dropped.
+func (m *Manager) tmuxCapturePaneLines(paneTarget string, start int, escapes bool) ([]string, error) {
+	args := []string{"capture-pane", "-p", "-J", "-t", paneTarget}
+	if escapes {
+		args = append(args, "-e")
+	}
+	if start > 0 {
+		args = append(args, "-S", fmt.Sprintf("-%d", start))
+	}
+	out, err := m.runCmdOutput("", "tmux", args...)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(strings.TrimRight(out, "\n"), "\n")
+	for len(lines) > 0 && strings.TrimSpace(stripANSI(lines[len(lines)-1])) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines, nil
+}
+
+// tmuxPaneExists reports whether a pane target names a live pane.
+func (m *Manager) tmuxPaneExists(paneTarget string) bool {
+	return m.runCmdQuiet("", "tmux", "display-message", "-p", "-t", paneTarget, "#{pane_id}") == nil
+}
+
+// runningAgentPane resolves target to its worktree path and the pane of its
+// agent, failing when no agent window is open.
+func (m *Manager) runningAgentPane(target string) (string, string, error) {
+	if !m.commandExists("tmux") {
+		return "", "", errors.New("tmux is required for agent workflows")
+	}
+	repoRoot, wt, err := m.resolveWorktreeForTmux(target)
+	if err != nil {
+		return "", "", err
+	}
+	pane := m.agentPaneTarget(repoRoot, wt)
+	if !m.tmuxPaneExists(pane) {
+		return "", "", fmt.Errorf("no agent running in %s", wt.Path)
+	}
+	return wt.Path, pane, nil
+}
+
+// AgentOutputTail returns the path of the target worktree and the last lines
+// its agent printed.
+func (m *Manager) AgentOutputTail(opts AgentOutputOptions) (string, []string, error) {
+	path, pane, err := m.runningAgentPane(opts.Target)
+	if err != nil {
+		return "", nil, err
+	}
+	lines, err := m.tmuxCapturePaneLines(pane, opts.Lines, !opts.StripANSI)
+	if err != nil {
+		return "", nil, err
+	}
+	if opts.Lines > 0 && len(lines) > opts.Lines {
+		lines = lines[len(lines)-opts.Lines:]
+	}
+	return path, lines, nil
+}
+
+// FollowAgentOutput prints the last lines of the agent's output like
+// AgentOutputTail, then polls its screen and passes on the lines it prints
+// until done is closed or the agent window goes away.
+func (m *Manager) FollowAgentOutput(opts AgentOutputOptions, done <-chan struct{}, fn func(line string) error) error {
+	_, pane, err := m.runningAgentPane(opts.Target)
+	if err != nil {
+		return err
+	}
+	_, lines, err := m.AgentOutputTail(opts)
+	if err != nil {
+		return err
+	}
+	for _, line := range lines {
+		if err := fn(line); err != nil {
+			return err
+		}
+	}
+	screen, err := m.tmuxCapturePaneLines(pane, 0, !opts.StripANSI)
+	if err != nil {
+		return err
+	}
+	ticker := time.NewTicker(agentOutputFollowInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return nil
+		case <-ticker.C:
+		}
+		next, err := m.tmuxCapturePaneLines(pane, 0, !opts.StripANSI)
+		if err != nil {
+			if !m.tmuxPaneExists(pane) {
+				return errors.New("agent window closed")
+			}
+			return err
+		}
+		for _, line := range newOutputLines(screen, next) {
+			if err := fn(line); err != nil {
+				return err
+			}
+		}
+		screen = next
+	}
+}
+
+// newOutputLines returns the lines of the screen next that were not on the
+// screen prev. When the output scrolled, the lines below the overlap are
+// new; when it changed in place, such as a status line redrawing, the lines
+// from the first change down are.
+func newOutputLines(prev, next []string) []string {
+	for overlap := min(len(prev), len(next)); overlap > 0; overlap-- {
+		if equalLines(prev[len(prev)-overlap:], next[:overlap]) {
+			return next[overlap:]
+		}
+	}
+	same := 0
+	for same < len(prev) && same < len(next) && prev[same] == next[same] {
+		same++
+	}
+	return next[same:]
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,67 @@
+package sprout
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// AgentOverride is the agent configuration a linked worktree's own
+// .sprout.toml sets for agents started in that worktree alone, such as a
+// different model or sandbox flags.
+type AgentOverride struct {
+	// Source is the file the override was read from.
+	Source   string
+	Command  string            // agent_command
+	Args     string            // agent_args
+	Commands map[string]string // agent_command_<type>
+}
+
+func (o AgentOverride) empty() bool {
+	return o.Command == "" && o.Args == "" && len(o.Commands) == 0
+}
+
+// worktreeAgentOverride reads the agent settings of the .sprout.toml at the
+// root of a linked worktree. The main worktree's .sprout.toml is the repo
+// config, which is loaded for every worktree already, so it is no override.
+func (m *Manager) worktreeAgentOverride(worktreePath string) (AgentOverride, error) {
+	if strings.TrimSpace(worktreePath) == "" {
+		return AgentOverride{}, nil
+	}
+	// A linked worktree has a .git file pointing at the main repository;
+	// the main worktree has the .git directory.
+	if info, err := m.statPath(filepath.Join(worktreePath, ".git")); err != nil || info.IsDir() {
+		return AgentOverride{}, nil
+	}
+	path := filepath.Join(worktreePath, ".sprout.toml")
+	data, err := m.readFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return AgentOverride{}, nil
+		}
+		return AgentOverride{}, err
+	}
+	var scratch Config
+	if err := parseTOMLFlatReader(path, bytes.NewReader(data), &scratch, ""); err != nil {
+		return AgentOverride{}, err
+	}
+	override := AgentOverride{
+		Command:  strings.TrimSpace(scratch.AgentCommand),
+		Args:     strings.TrimSpace(scratch.AgentArgs),
+		Commands: scratch.AgentCommands,
+	}
+	if !override.empty() {
+		override.Source = path
+	}
+	return override, nil
+}
+
+// withAgentArgs appends agent_args to an agent command.
+func withAgentArgs(command, args string) string {
+	if args = strings.TrimSpace(args); args == "" {
+		return command
+	}
+	return strings.TrimSpace(command + " " + args)
+}
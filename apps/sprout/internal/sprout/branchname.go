@@ -0,0 +1,136 @@
+package sprout
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Branch naming policy: sprout new <type> <name> checks type against
+// branch_types and builds the branch from branch_template. Every new branch,
+// however it was named, must match branch_pattern when one is set.
+const defaultBranchTemplate = "{type}/{slug}"
+
+func defaultBranchTypes() []string {
+	return []string{"feat", "fix", "chore", "docs", "refactor", "test"}
+}
+
+var branchPlaceholderRe = regexp.MustCompile(`\{[a-z]+\}`)
+
+func parseBranchTemplate(value string) (string, error) {
+	v := strings.TrimSpace(value)
+	if v == "" {
+		return defaultBranchTemplate, nil
+	}
+	for _, p := range branchPlaceholderRe.FindAllString(v, -1) {
+		switch p {
+		case "{type}", "{slug}", "{user}", "{date}":
+		default:
+			return "", fmt.Errorf("invalid branch_template %q: unknown placeholder %s (want {type}, {slug}, {user}, or {date})", value, p)
+		}
+	}
+	if !strings.Contains(v, "{slug}") {
+		return "", fmt.Errorf("invalid branch_template %q: must contain {slug}", value)
+	}
+	return v, nil
+}
+
+func parseBranchPattern(value string) (string, error) {
+	v := strings.TrimSpace(value)
+	if _, err := regexp.Compile(v); err != nil {
+		return "", fmt.Errorf("invalid branch_pattern %q: %w", value, err)
+	}
+	return v, nil
+}
+
+func normalizeBranchTypes(values []string) []string {
+	out := make([]string, 0, len(values))
+	for _, raw := range values {
+		if t := strings.TrimSpace(raw); t != "" {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// validBranchType reports whether branchType is one of branch_types. An
+// empty branch_types list accepts any type that slugifies to itself.
+func (m *Manager) validBranchType(branchType string) bool {
+	if len(m.Cfg.BranchTypes) == 0 {
+		return branchType != "" && slugify(branchType, m.Cfg.SlugMode) == branchType
+	}
+	for _, t := range m.Cfg.BranchTypes {
+		if t == branchType {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Manager) invalidBranchTypeError(branchType string) string {
+	if len(m.Cfg.BranchTypes) == 0 {
+		return fmt.Sprintf("invalid type '%s' (expected a lowercase word like feat)", branchType)
+	}
+	return fmt.Sprintf("invalid type '%s' (expected: %s)", branchType, strings.Join(m.Cfg.BranchTypes, "|"))
+}
+
+// expandBranchTemplate fills branch_template for a type and a slugified
+// name. {user} and {date} are only looked up when the template uses them.
+func (m *Manager) expandBranchTemplate(repoRoot, branchType, slug string) string {
+	template := m.Cfg.BranchTemplate
+	if template == "" {
+		template = defaultBranchTemplate
+	}
+	return branchPlaceholderRe.ReplaceAllStringFunc(template, func(p string) string {
+		switch p {
+		case "{type}":
+			return branchType
+		case "{slug}":
+			return slug
+		case "{user}":
+			return m.branchUser(repoRoot)
+		case "{date}":
+			return time.Now().Format("2006-01-02")
+		}
+		return p
+	})
+}
+
+// branchUser is the {user} of branch_template: the part of git's
+// user.email before the @, or the login name when that is unset.
+func (m *Manager) branchUser(repoRoot string) string {
+	name := ""
+	if email, err := m.runCmdOutput(repoRoot, "git", "config", "user.email"); err == nil {
+		name, _, _ = strings.Cut(strings.TrimSpace(email), "@")
+	}
+	if name == "" {
+		if u, err := user.Current(); err == nil {
+			name = u.Username
+		} else {
+			name = os.Getenv("USER")
+		}
+	}
+	if s := slugify(name, m.Cfg.SlugMode); s != "" {
+		return s
+	}
+	return "user"
+}
+
+// checkBranchPattern returns an error when branch_pattern is set and the new
+// branch does not match it.
+func (m *Manager) checkBranchPattern(branch string) error {
+	if m.Cfg.BranchPattern == "" {
+		return nil
+	}
+	re, err := regexp.Compile(m.Cfg.BranchPattern)
+	if err != nil {
+		return fmt.Errorf("invalid branch_pattern: %w", err)
+	}
+	if !re.MatchString(branch) {
+		return fmt.Errorf("branch %q does not match branch_pattern %s", branch, m.Cfg.BranchPattern)
+	}
+	return nil
+}
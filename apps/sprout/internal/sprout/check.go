@@ -0,0 +1,133 @@
+package sprout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// branchCheckKey is the git config variable, under branch.<name>, that
+// holds the result of the branch's last sprout check as
+// "<pass|fail> <unix time> <duration ms> <commit>".
+const branchCheckKey = "sproutcheck"
+
+// Check outcomes.
+const (
+	checkPass = "pass"
+	checkFail = "fail"
+)
+
+// WorktreeCheck is the last check_command result of a worktree's branch.
+type WorktreeCheck struct {
+	Status     string    `json:"status,omitempty"`
+	At         time.Time `json:"at,omitempty"`
+	DurationMS int64     `json:"duration_ms,omitempty"`
+	// Commit is the HEAD the check ran against. Stale is set when the
+	// branch has moved on since.
+	Commit string `json:"commit,omitempty"`
+	Stale  bool   `json:"stale,omitempty"`
+}
+
+func parseWorktreeCheck(value string) (WorktreeCheck, bool) {
+	fields := strings.Fields(value)
+	if len(fields) != 4 || (fields[0] != checkPass && fields[0] != checkFail) {
+		return WorktreeCheck{}, false
+	}
+	at, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return WorktreeCheck{}, false
+	}
+	duration, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return WorktreeCheck{}, false
+	}
+	return WorktreeCheck{Status: fields[0], At: time.Unix(at, 0), DurationMS: duration, Commit: fields[3]}, true
+}
+
+// branchChecks reads the recorded check of every branch, marking the ones
+// whose branch has new commits as stale.
+func (m *Manager) branchChecks(repoRoot string) map[string]WorktreeCheck {
+	res := map[string]WorktreeCheck{}
+	values := m.branchConfigValues(repoRoot, branchCheckKey)
+	if len(values) == 0 {
+		return res
+	}
+	heads := map[string]string{}
+	if out, err := m.runCmdOutput(repoRoot, "git", "for-each-ref", "--format=%(objectname) %(refname:short)", "refs/heads"); err != nil {
+		debugLogf("branch_checks heads failed repo=%q: %v", repoRoot, err)
+	} else {
+		for _, line := range strings.Split(out, "\n") {
+			if commit, branch, ok := strings.Cut(strings.TrimSpace(line), " "); ok {
+				heads[branch] = commit
+			}
+		}
+	}
+	for branch, value := range values {
+		check, ok := parseWorktreeCheck(value)
+		if !ok {
+			continue
+		}
+		check.Stale = heads[branch] != "" && heads[branch] != check.Commit
+		res[branch] = check
+	}
+	return res
+}
+
+// formatCheck renders the check column: pass or fail, with a * when the
+// branch has new commits since.
+func formatCheck(check WorktreeCheck) string {
+	if check.Stale {
+		return check.Status + "*"
+	}
+	return check.Status
+}
+
+// CheckResult is the outcome of sprout check in one worktree.
+type CheckResult struct {
+	ExecResult
+	Status string `json:"status"`
+}
+
+// Check runs check_command in one or all worktrees, like Exec, and records
+// whether it passed in each branch's config for the CHECK column. Detached
+// worktrees are checked but nothing is recorded for them.
+func (m *Manager) Check(ctx context.Context, opts ExecOptions) ([]CheckResult, error) {
+	command := strings.TrimSpace(m.Cfg.CheckCommand)
+	if command == "" {
+		return nil, errors.New("no check_command configured")
+	}
+	repoRoot, err := m.RequireRepo()
+	if err != nil {
+		return nil, err
+	}
+	opts.Command = []string{command}
+	results, err := m.Exec(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	checks := make([]CheckResult, len(results))
+	for i, res := range results {
+		checks[i] = CheckResult{ExecResult: res, Status: checkFail}
+		if res.OK() {
+			checks[i].Status = checkPass
+		}
+		// A stopped or unstartable check says nothing about the branch.
+		if res.Error != "" || res.Branch == "" || !m.BranchExists(repoRoot, res.Branch) {
+			continue
+		}
+		head, err := m.runCmdOutput(res.Path, "git", "rev-parse", "HEAD")
+		if err != nil {
+			errorLogf("check head failed path=%q: %v", res.Path, err)
+			continue
+		}
+		value := fmt.Sprintf("%s %d %d %s", checks[i].Status, time.Now().Unix(), res.DurationMS, strings.TrimSpace(head))
+		if err := m.runCmdQuiet(repoRoot, "git", "config", "branch."+res.Branch+"."+branchCheckKey, value); err != nil {
+			errorLogf("check record failed branch=%q: %v", res.Branch, err)
+		}
+	}
+	infoLogf("check done results=%d", len(checks))
+	return checks, nil
+}
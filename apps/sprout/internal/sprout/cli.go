@@ -1,15 +1,24 @@
 package sprout
 
 import (
+	"bufio"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/lipgloss/table"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
 var (
@@ -17,6 +26,10 @@ var (
 		Use:   "sprout",
 		Short: "sprout - git worktree manager with interactive TUI",
 		Long:  GetBannerANSI() + "\nsprout - git worktree manager with interactive TUI",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			waitForLock, _ = cmd.Flags().GetBool("wait")
+			return initOutput(cmd)
+		},
 		Run: func(cmd *cobra.Command, args []string) {
 			mgr := getManager()
 			os.Exit(RunUI(mgr))
@@ -28,6 +41,14 @@ var (
 		Short: "Launch the interactive TUI",
 		Run: func(cmd *cobra.Command, args []string) {
 			mgr := getManager()
+			if cmd.Flags().Changed("on-quit") {
+				value, _ := cmd.Flags().GetString("on-quit")
+				action, err := parseQuitAction(value)
+				if err != nil {
+					cliFail(err)
+				}
+				mgr.Cfg.OnQuit = action
+			}
 			os.Exit(RunUI(mgr))
 		},
 	}
@@ -38,6 +59,13 @@ var (
 		Run:   runNew,
 	}
 
+	planCmd = &cobra.Command{
+		Use:   "plan <file>",
+		Short: "Create a worktree per task of a markdown or YAML task list",
+		Args:  cobra.ExactArgs(1),
+		Run:   runPlan,
+	}
+
 	listCmd = &cobra.Command{
 		Use:   "list",
 		Short: "List worktrees",
@@ -62,6 +90,13 @@ var (
 		Run:   runLaunch,
 	}
 
+	popupCmd = &cobra.Command{
+		Use:   "popup",
+		Short: "Pick a worktree and switch tmux to its session, for tmux display-popup",
+		Args:  cobra.NoArgs,
+		Run:   runPopup,
+	}
+
 	detachCmd = &cobra.Command{
 		Use:   "detach <target>",
 		Short: "Detach from a tmux session",
@@ -69,9 +104,9 @@ var (
 	}
 
 	agentCmd = &cobra.Command{
-		Use:   "agent <action> <target>",
-		Short: "Manage agents (start, stop, attach)",
-		Args:  cobra.ExactArgs(2),
+		Use:   "agent <action> <target> [text]",
+		Short: "Manage agents (start, stop, restart, attach, send, output, history)",
+		Args:  cobra.RangeArgs(1, 3),
 		Run:   runAgent,
 	}
 
@@ -81,21 +116,200 @@ var (
 		Run:   runRemove,
 	}
 
+	undoCmd = &cobra.Command{
+		Use:   "undo",
+		Short: "Restore the last removed worktree, with its uncommitted files",
+		Args:  cobra.NoArgs,
+		Run:   runUndo,
+	}
+
+	fetchCmd = &cobra.Command{
+		Use:   "fetch",
+		Short: "Fetch the repository once for all its worktrees, pruning deleted remote branches",
+		Args:  cobra.NoArgs,
+		Run:   runFetch,
+	}
+
+	lockCmd = &cobra.Command{
+		Use:   "lock <target>",
+		Short: "Lock a worktree to prevent removal",
+		Run:   runLock,
+	}
+
+	unlockCmd = &cobra.Command{
+		Use:   "unlock <target>",
+		Short: "Unlock a worktree",
+		Run:   runUnlock,
+	}
+
+	mvCmd = &cobra.Command{
+		Use:   "mv <target> <new-branch>",
+		Short: "Rename a worktree and its branch",
+		Run:   runMove,
+	}
+
+	priorityCmd = &cobra.Command{
+		Use:   "priority <target> [high|normal|low]",
+		Short: "Show or set a worktree's priority",
+		Args:  cobra.MaximumNArgs(2),
+		Run:   runPriority,
+	}
+
+	labelCmd = &cobra.Command{
+		Use:   "label <target> [add|rm <label>...|clear]",
+		Short: "Show, add, or remove a worktree's labels",
+		Run:   runLabel,
+	}
+
+	rebaseCmd = &cobra.Command{
+		Use:   "rebase <target>",
+		Short: "Open an interactive rebase in a tmux window",
+		Run:   runRebase,
+	}
+
+	mergeCmd = &cobra.Command{
+		Use:   "merge <target>",
+		Short: "Merge a finished worktree's branch into the base branch",
+		Run:   runMergeCmd,
+	}
+
+	pickCmd = &cobra.Command{
+		Use:   "pick <src> <dst>",
+		Short: "Copy changed files or commits from one worktree into another",
+		Args:  cobra.ExactArgs(2),
+		Run:   runPick,
+	}
+
+	shareCmd = &cobra.Command{
+		Use:   "share <target>",
+		Short: "Serve a read-only web view of a worktree diff",
+		Run:   runShare,
+	}
+
+	exportCmd = &cobra.Command{
+		Use:   "export <target>",
+		Short: "Export a worktree diff as a patch, HTML page, or Markdown review",
+		Run:   runExport,
+	}
+
+	execCmd = &cobra.Command{
+		Use:   "exec [target] -- <command> [args...]",
+		Short: "Run a command in one or every worktree",
+		Run:   runExec,
+	}
+
+	checkCmd = &cobra.Command{
+		Use:   "check [target]",
+		Short: "Run check_command in one or every worktree and record the result",
+		Args:  cobra.MaximumNArgs(1),
+		Run:   runCheck,
+	}
+
+	sessionsCmd = &cobra.Command{
+		Use:   "sessions [list|kill-orphans]",
+		Short: "List sprout tmux sessions and clean up orphaned ones",
+		Args:  cobra.MaximumNArgs(1),
+		Run:   runSessions,
+	}
+
+	shutdownCmd = &cobra.Command{
+		Use:   "shutdown",
+		Short: "Stop all running agents, optionally detaching their sessions",
+		Args:  cobra.NoArgs,
+		Run:   runShutdown,
+	}
+
+	eventsCmd = &cobra.Command{
+		Use:   "events",
+		Short: "Print sprout events as JSON lines, optionally following new ones",
+		Args:  cobra.NoArgs,
+		Run:   runEvents,
+	}
+
+	serveCmd = &cobra.Command{
+		Use:   "serve",
+		Short: "Serve a JSON API for managing worktrees and agents over HTTP",
+		Args:  cobra.NoArgs,
+		Run:   runServe,
+	}
+
+	mcpCmd = &cobra.Command{
+		Use:   "mcp",
+		Short: "Serve sprout's worktree and agent operations as MCP tools over stdio",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			mgr := getManager()
+			// stdout carries the protocol; everything else goes to the debug log.
+			if err := mgr.ServeMCP(os.Stdin, os.Stdout); err != nil {
+				fmt.Fprintln(os.Stderr, ErrorMsg(err.Error()))
+				os.Exit(1)
+			}
+		},
+	}
+
+	statusCmd = &cobra.Command{
+		Use:   "status",
+		Short: "Summarize agents and their token and cost usage per worktree",
+		Args:  cobra.NoArgs,
+		Run:   runStatus,
+	}
+
+	watchCmd = &cobra.Command{
+		Use:   "watch",
+		Short: "Keep a status file of worktrees and agents up to date for status bars",
+		Args:  cobra.NoArgs,
+		Run:   runWatch,
+	}
+
+	statuslineCmd = &cobra.Command{
+		Use:   "statusline",
+		Short: "Print a one-line worktree and agent summary for status bars",
+		Args:  cobra.NoArgs,
+		Run:   runStatusline,
+	}
+
+	statsCmd = &cobra.Command{
+		Use:   "stats",
+		Short: "Summarize worktree and agent activity from the event log",
+		Args:  cobra.NoArgs,
+		Run:   runStats,
+	}
+
+	perfCmd = &cobra.Command{
+		Use:   "perf",
+		Short: "Report how long git and tmux commands and sprout operations take",
+		Args:  cobra.NoArgs,
+		Run:   runPerf,
+	}
+
+	resumeCmd = &cobra.Command{
+		Use:   "resume",
+		Short: "Start again the agents that stopped when the tmux server went away",
+		Args:  cobra.NoArgs,
+		Run:   runResume,
+	}
+
+	configCmd = &cobra.Command{
+		Use:   "config <show|init|schema|lint> [target]",
+		Short: "Show, write, describe, or lint the sprout config",
+		Args:  cobra.RangeArgs(1, 2),
+		Run:   runConfig,
+	}
+
 	doctorCmd = &cobra.Command{
 		Use:   "doctor",
-		Short: "Check system health",
+		Short: "Check system health and repair worktree problems",
 		Run:   runDoctor,
 	}
 
 	shellHookCmd = &cobra.Command{
-		Use:   "shell-hook <shell>",
+		Use:   "shell-hook <zsh|bash|fish|powershell|nu>",
 		Short: "Generate shell hook",
 		Args:  cobra.ExactArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
 			hook, err := ShellHook(args[0])
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "error: %v\n", err)
-				os.Exit(1)
+				cliFail(err)
 			}
 			fmt.Print(hook)
 		},
@@ -103,50 +317,171 @@ var (
 
 	versionCmd = &cobra.Command{
 		Use:   "version",
-		Short: "Show version",
+		Short: "Show version and build info",
+		Args:  cobra.NoArgs,
 		Run: func(cmd *cobra.Command, args []string) {
-			fmt.Println(Version)
+			info := buildInfo()
+			if asJSON, _ := cmd.Flags().GetBool("json"); asJSON && !jsonOutput() {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(info); err != nil {
+					cliFail(err)
+				}
+				return
+			}
+			cliDone(info, func() {
+				fmt.Println(info)
+			})
 		},
 	}
 )
 
+// waitForLock is the --wait flag, applied to every manager getManager makes.
+var waitForLock bool
+
 func emitCDMarkerIfEnabled(cfg Config, path string) {
-	if cfg.EmitCDMarker {
+	if cfg.EmitCDMarker && !jsonOutput() {
 		fmt.Printf("__SPROUT_CD__=%s\n", path)
 	}
 }
 
 func init() {
+	rootCmd.Version = buildInfo().String()
+	rootCmd.SetVersionTemplate("sprout {{.Version}}\n")
+	rootCmd.PersistentFlags().String("output", "", "Output format: text or json (default: $SPROUT_OUTPUT or text)")
+	rootCmd.PersistentFlags().Bool("wait", false, "Wait for another sprout operation on the repository to finish instead of failing")
+
+	uiCmd.Flags().String("on-quit", "", "What to do with running agents on quit: none, ask, stop-agents, or detach (default: on_quit)")
+
 	newCmd.Flags().String("from", "", "Base branch to create from")
 	newCmd.Flags().String("from-branch", "", "Existing branch to create worktree from")
+	newCmd.Flags().Int("from-pr", 0, "GitHub pull request to fetch and create a worktree from")
 	newCmd.Flags().Bool("no-launch", false, "Do not launch tmux session")
+	newCmd.Flags().String("priority", "", "Priority of the new worktree: high, normal, or low")
+	newCmd.Flags().Bool("yes", false, "Create even when the WIP limit is reached")
 
 	listCmd.Flags().Bool("json", false, "Output in JSON format")
+	versionCmd.Flags().Bool("json", false, "Output the version and build info as JSON")
+	listCmd.Flags().String("sort", "", "Order: path, active (most recent first), or idle (least recent first)")
+	listCmd.Flags().StringSlice("label", nil, "Only worktrees with all of these labels (comma-separated or repeated)")
+	listCmd.Flags().String("filter", "", "Only worktrees matching a filter preset from the [filters] config table, or a filter like the TUI's")
 
 	goCmd.Flags().Bool("attach", false, "Attach to tmux session")
 	goCmd.Flags().Bool("no-launch", false, "Do not launch tmux session")
 
 	launchCmd.Flags().Bool("no-attach", false, "Do not attach to tmux session")
+	launchCmd.Flags().Bool("dry-run", false, "Print the tmux commands that would create the session instead of running them")
+
+	agentCmd.Flags().String("type", "", "Agent type to start instead of the default (start, attach and restart)")
+	agentCmd.Flags().String("prompt", "", "Prompt to send once the agent is ready for input (start and attach)")
+	agentCmd.Flags().Bool("replay", false, "Send the last prompt again once the restarted agent is ready (restart)")
+	agentCmd.Flags().Bool("attach", false, "Attach to the restarted agent (restart)")
+	agentCmd.Flags().Duration("prompt-timeout", agentPromptTimeout, "How long to wait for the agent to be ready for --prompt")
+	agentCmd.Flags().Bool("enter", true, "Press Enter after the text (send; off with --raw-keys unless given)")
+	agentCmd.Flags().Bool("raw-keys", false, "Send the text as tmux key names, such as C-c or Escape (send)")
+	agentCmd.Flags().Int("lines", 50, "Number of output lines to print, scrollback included (output)")
+	agentCmd.Flags().BoolP("follow", "f", false, "Keep printing the output as the agent prints it (output)")
+	agentCmd.Flags().Bool("strip-ansi", false, "Drop colors and other escape sequences (output)")
 
 	rmCmd.Flags().Bool("force", false, "Force removal")
 	rmCmd.Flags().Bool("delete-branch", false, "Delete the branch associated with the worktree")
+	rmCmd.Flags().Bool("yes", false, "Skip the confirmation prompt when force-removing a locked worktree or naming it by a partial target")
+	rmCmd.Flags().Bool("switch", false, "Allow removing the worktree you are in by moving to the main worktree (automatic with the shell hook)")
+
+	lockCmd.Flags().String("reason", "", "Reason recorded with the lock")
+
+	rebaseCmd.Flags().String("onto", "", "Base branch to rebase onto (default: base_branch)")
+	rebaseCmd.Flags().Bool("no-attach", false, "Do not attach to the rebase window")
+
+	planCmd.Flags().String("type", "", "Branch type of tasks that do not set one (default: the first of branch_types, or feat)")
+	planCmd.Flags().String("from", "", "Base branch to create from")
+	planCmd.Flags().Bool("agent", false, "Start an agent in each new worktree and send it the task (default: auto_start_agent)")
+	planCmd.Flags().String("agent-type", "", "Agent type to start (default: default_agent_type)")
+	planCmd.Flags().Bool("dry-run", false, "List the tasks and their branches without creating anything")
+	planCmd.Flags().Bool("yes", false, "Create past the WIP limit without asking")
+
+	mergeCmd.Flags().String("into", "", "Branch to merge into (default: base_branch)")
+	mergeCmd.Flags().Bool("squash", false, "Squash the branch into a single commit")
+	mergeCmd.Flags().StringP("message", "m", "", "Commit message (default: \"Merge branch '<branch>'\", or the commit subject when squashing one commit)")
+	mergeCmd.Flags().Bool("remove", false, "Remove the worktree after merging")
+	mergeCmd.Flags().Bool("delete-branch", false, "Remove the worktree and delete its branch after merging")
+	mergeCmd.Flags().Bool("skip-checks", false, "Merge even when the pull request checks are failing or pending")
+	mergeCmd.Flags().Bool("yes", false, "Skip the confirmation prompt")
+
+	pickCmd.Flags().StringSlice("files", nil, "Changed files of <src> whose changes to apply to <dst> (comma-separated or repeated)")
+	pickCmd.Flags().String("since", "", "Revision the files are compared with (default: where <src> forked from base_branch)")
+	pickCmd.Flags().StringSlice("commit", nil, "Commits to cherry-pick onto <dst> (comma-separated or repeated)")
+
+	shareCmd.Flags().String("addr", "", "Listen address (default: 127.0.0.1 on a random port)")
+	shareCmd.Flags().Bool("lan", false, "Listen on all interfaces so the page can be opened over LAN")
+	shareCmd.Flags().Bool("agent", false, "Include the agent transcript")
+	shareCmd.Flags().Duration("for", 0, "Stop serving after this long (default: until interrupted)")
+
+	exportCmd.Flags().String("format", "patch", "Export format: patch, html, or markdown")
+	exportCmd.Flags().StringP("out", "o", "", "Output file, or - for stdout (default: <branch>.<ext>)")
+
+	execCmd.Flags().Bool("all", false, "Run in every worktree of the repository")
+	execCmd.Flags().Int("parallel", 1, "Number of worktrees to run in at once")
 
-	rootCmd.AddCommand(uiCmd, newCmd, listCmd, goCmd, pathCmd, launchCmd, detachCmd, agentCmd, rmCmd, doctorCmd, shellHookCmd, versionCmd)
+	checkCmd.Flags().Bool("all", false, "Check every worktree of the repository")
+	checkCmd.Flags().Int("parallel", 1, "Number of worktrees to check at once")
+
+	shutdownCmd.Flags().Bool("detach", false, "Also kill the tmux session of every worktree")
+	shutdownCmd.Flags().Bool("yes", false, "Skip the confirmation prompt")
+
+	eventsCmd.Flags().BoolP("follow", "f", false, "Keep printing events as they happen")
+	eventsCmd.Flags().String("since", "", "Only events since this long ago (e.g. 1h or 7d) or this RFC 3339 time")
+	eventsCmd.Flags().StringSlice("type", nil, "Only events of these types (comma-separated)")
+	eventsCmd.Flags().Bool("all", false, "Include events from every repository, not just the current one")
+
+	serveCmd.Flags().String("listen", serveDefaultAddr, "Listen address")
+	serveCmd.Flags().String("token", "", "Bearer token clients must send (default: $SPROUT_SERVE_TOKEN or ~/.config/sprout/serve-token)")
+
+	watchCmd.Flags().Duration("interval", defaultWatchInterval, "How often to refresh the status")
+	watchCmd.Flags().String("file", "", "Status file to write (default: ~/.config/sprout/status.json)")
+	watchCmd.Flags().String("prometheus", "", "Also write Prometheus text metrics to this file")
+	watchCmd.Flags().Bool("once", false, "Write the status once and exit")
+	statuslineCmd.Flags().String("format", statuslinePlain, "Output format: plain, tmux, or starship")
+	statuslineCmd.Flags().String("file", "", "Status file to read (default: ~/.config/sprout/status.json)")
+	statuslineCmd.Flags().String("dir", "", "Directory whose repository to summarize (default: the working directory)")
+	statsCmd.Flags().String("since", "", "Only count activity since this long ago (e.g. 30d) or this RFC 3339 time")
+	statsCmd.Flags().Bool("all", false, "Summarize every repository, not just the current one")
+	perfCmd.Flags().Int("limit", 15, "Show at most this many commands and slow invocations (0 for all)")
+	perfCmd.Flags().Bool("repo", false, "Only list slow invocations run in the current repository's worktrees")
+	perfCmd.Flags().Bool("reset", false, "Forget the timings recorded so far")
+
+	configCmd.Flags().Bool("json", false, "Print the config as JSON (show)")
+	configCmd.Flags().Bool("toml", false, "Print the config as TOML, the default (show)")
+	configCmd.Flags().Bool("origin", false, "Show the file or environment variable each value came from (show)")
+	configCmd.Flags().Bool("repo", false, "Write .sprout.toml at the repository root instead of the global config (init)")
+	configCmd.Flags().Bool("force", false, "Overwrite an existing config file (init)")
+
+	doctorCmd.Flags().Bool("fix", false, "Repair stale worktrees, broken gitdir pointers, and orphaned tmux sessions, and move worktrees to their worktree_path_template path")
+
+	for _, c := range []*cobra.Command{goCmd, pathCmd, launchCmd, detachCmd, agentCmd, rmCmd, mvCmd, lockCmd, unlockCmd, priorityCmd, labelCmd, rebaseCmd, mergeCmd, shareCmd, exportCmd, execCmd, checkCmd} {
+		c.Flags().Bool("pick", false, "Choose the worktree in an interactive picker, filtered by the target if given")
+	}
+
+	rootCmd.AddCommand(uiCmd, newCmd, planCmd, listCmd, goCmd, pathCmd, launchCmd, popupCmd, detachCmd, agentCmd, rmCmd, undoCmd, fetchCmd, mvCmd, lockCmd, unlockCmd, priorityCmd, labelCmd, rebaseCmd, mergeCmd, pickCmd, shareCmd, exportCmd, execCmd, checkCmd, sessionsCmd, shutdownCmd, resumeCmd, eventsCmd, statusCmd, watchCmd, statuslineCmd, statsCmd, perfCmd, mcpCmd, serveCmd, configCmd, doctorCmd, shellHookCmd, versionCmd)
 }
 
 func getManager() *Manager {
 	cfg, err := LoadConfig()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "error loading config: %v\n", err)
-		os.Exit(1)
+		cliFail(fmt.Errorf("error loading config: %w", err))
 	}
-	return NewManager(cfg)
+	ApplyColorSettings(cfg)
+	mgr := NewManager(cfg)
+	mgr.WaitForLock = waitForLock
+	return mgr
 }
 
 func Run(args []string) int {
 	// We pass nothing to Execute() as it uses os.Args by default.
 	// But if we want to pass specific args, we can.
-	if err := rootCmd.Execute(); err != nil {
+	err := rootCmd.Execute()
+	flushPerf()
+	if err != nil {
 		return 1
 	}
 	return 0
@@ -156,71 +491,271 @@ func runNew(cmd *cobra.Command, args []string) {
 	mgr := getManager()
 	from, _ := cmd.Flags().GetString("from")
 	fromBranch, _ := cmd.Flags().GetString("from-branch")
+	fromPR, _ := cmd.Flags().GetInt("from-pr")
 	noLaunch, _ := cmd.Flags().GetBool("no-launch")
+	yes, _ := cmd.Flags().GetBool("yes")
+	priority, _ := cmd.Flags().GetString("priority")
+	if _, err := parsePriority(priority); err != nil {
+		cliFail(err)
+	}
 
-	if fromBranch != "" {
+	if fromPR != 0 && (fromBranch != "" || len(args) > 0) {
+		cliUsage("sprout new --from-pr <number> (without <type> <name> or --from-branch)")
+	}
+
+	if fromBranch != "" || fromPR != 0 {
 		// Existing branch mode
+		checkWIPLimit(mgr, yes)
 		launch := mgr.Cfg.AutoLaunch && !noLaunch
-		_, path, err := mgr.NewWorktree(NewOptions{
+		ctx, stop := interruptContext()
+		defer stop()
+		if fromPR != 0 {
+			branch, err := mgr.FetchPullRequest(ctx, fromPR)
+			if err != nil {
+				cliFail(err)
+			}
+			fromBranch = branch
+		}
+		_, path, err := mgr.NewWorktree(ctx, NewOptions{
 			FromBranch: fromBranch,
 			Launch:     launch,
 		})
 		if err != nil {
-			fmt.Fprintln(os.Stderr, ErrorMsg(err.Error()))
-			os.Exit(1)
+			failNew(err)
 		}
-		if mgr.Cfg.AutoStartAgent {
-			if _, _, err := mgr.StartAgent(AgentOptions{Target: path, Attach: false}); err != nil {
-				fmt.Fprintln(os.Stderr, WarnMsg(fmt.Sprintf("created worktree but could not auto-start agent: %v", err)))
+		setNewWorktreePriority(mgr, path, priority)
+		if mgr.autoStartAgent() {
+			if _, _, err := mgr.StartAgent(ctx, AgentOptions{Target: path, Attach: false}); err != nil {
+				cliWarn(fmt.Sprintf("created worktree but could not auto-start agent: %v", err))
 			}
 		}
-		fmt.Println(SuccessMsg(fmt.Sprintf("Created worktree from %s: %s", StyleBranch.Render(fromBranch), StylePath.Render(path))))
+		cliDone(map[string]any{"path": path, "branch": fromBranch}, func() {
+			fmt.Println(SuccessMsg(fmt.Sprintf("Created worktree from %s: %s", StyleBranch.Render(fromBranch), StylePath.Render(path))))
+		})
 		emitCDMarkerIfEnabled(mgr.Cfg, path)
 		return
 	}
 
 	if len(args) < 2 {
-		fmt.Fprintln(os.Stderr, ErrorMsg("usage: sprout new <type> <name> [--from <base>] [--no-launch]"))
-		fmt.Fprintln(os.Stderr, StyleDim.Render("       or: sprout new --from-branch <existing-branch>"))
-		os.Exit(1)
+		if !jsonOutput() {
+			fmt.Fprintln(os.Stderr, ErrorMsg("usage: sprout new <type> <name> [--from <base>] [--no-launch]"))
+			fmt.Fprintln(os.Stderr, StyleDim.Render("       or: sprout new --from-branch <existing-branch>"))
+			fmt.Fprintln(os.Stderr, StyleDim.Render("       or: sprout new --from-pr <number>"))
+			os.Exit(1)
+		}
+		cliUsage("sprout new <type> <name> [--from <base>] [--no-launch], sprout new --from-branch <existing-branch>, or sprout new --from-pr <number>")
 	}
 
+	checkWIPLimit(mgr, yes)
 	launch := mgr.Cfg.AutoLaunch && !noLaunch
 	branchType := args[0]
 	name := strings.Join(args[1:], " ")
-	_, path, err := mgr.NewWorktree(NewOptions{
+	ctx, stop := interruptContext()
+	defer stop()
+	branch, path, err := mgr.NewWorktree(ctx, NewOptions{
 		Type:       branchType,
 		Name:       name,
 		BaseBranch: from,
 		Launch:     launch,
 	})
 	if err != nil {
-		fmt.Fprintln(os.Stderr, ErrorMsg(err.Error()))
-		os.Exit(1)
+		failNew(err)
 	}
-	if mgr.Cfg.AutoStartAgent {
-		if _, _, err := mgr.StartAgent(AgentOptions{Target: path, Attach: false}); err != nil {
-			fmt.Fprintln(os.Stderr, WarnMsg(fmt.Sprintf("created worktree but could not auto-start agent: %v", err)))
+	setNewWorktreePriority(mgr, path, priority)
+	if mgr.autoStartAgent() {
+		if _, _, err := mgr.StartAgent(ctx, AgentOptions{Target: path, Attach: false}); err != nil {
+			cliWarn(fmt.Sprintf("created worktree but could not auto-start agent: %v", err))
 		}
 	}
-	fmt.Println(SuccessMsg(fmt.Sprintf("Created worktree: %s", StylePath.Render(path))))
+	cliDone(map[string]any{"path": path, "branch": branch}, func() {
+		fmt.Println(SuccessMsg(fmt.Sprintf("Created worktree: %s", StylePath.Render(path))))
+	})
 	emitCDMarkerIfEnabled(mgr.Cfg, path)
 }
 
+// newFieldLabels names the request fields the way sprout new spells them.
+var newFieldLabels = map[string]string{
+	createFieldType:   "<type>",
+	createFieldName:   "<name>",
+	createFieldBranch: "branch",
+	createFieldBase:   "--from",
+	createFieldPath:   "path",
+}
+
+// failNew reports a failed sprout new, with one line per invalid field.
+func failNew(err error) {
+	var verr *ValidationError
+	if jsonOutput() || !errors.As(err, &verr) {
+		cliFail(err)
+	}
+	for _, f := range verr.Fields {
+		fmt.Fprintln(os.Stderr, ErrorMsg(fmt.Sprintf("%s %s", StyleDim.Render(newFieldLabels[f.Field]+":"), f.Message)))
+	}
+	os.Exit(1)
+}
+
+// checkWIPLimit stops worktree creation at the configured WIP limit unless
+// the user confirms or passes --yes.
+func checkWIPLimit(mgr *Manager, yes bool) {
+	status, err := mgr.WIPStatus()
+	if err != nil || !status.Exceeded() || yes {
+		return
+	}
+	msg := wipLimitMessage(status, 3)
+	if jsonOutput() {
+		cliFail(fmt.Errorf("%s (pass --yes to create anyway)", msg))
+	}
+	fmt.Fprintln(os.Stderr, WarnMsg(msg))
+	if !confirmPrompt("Create another worktree anyway?") {
+		cliFail(errors.New("aborted: WIP limit reached"))
+	}
+}
+
+func setNewWorktreePriority(mgr *Manager, path, priority string) {
+	if p, _ := parsePriority(priority); p == priorityNormal {
+		return
+	}
+	if _, err := mgr.SetPriority(path, priority); err != nil {
+		cliWarn(fmt.Sprintf("created worktree but could not set priority: %v", err))
+	}
+}
+
+func runPlan(cmd *cobra.Command, args []string) {
+	mgr := getManager()
+	tasks, err := ReadTaskList(args[0])
+	if err != nil {
+		cliFail(err)
+	}
+	opts := PlanOptions{Tasks: tasks, StartAgent: mgr.autoStartAgent()}
+	opts.Type, _ = cmd.Flags().GetString("type")
+	opts.BaseBranch, _ = cmd.Flags().GetString("from")
+	opts.AgentType, _ = cmd.Flags().GetString("agent-type")
+	if cmd.Flags().Changed("agent") {
+		opts.StartAgent, _ = cmd.Flags().GetBool("agent")
+	}
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	yes, _ := cmd.Flags().GetBool("yes")
+	if opts.Type == "" {
+		opts.Type = "feat"
+		if len(mgr.Cfg.BranchTypes) > 0 {
+			opts.Type = mgr.Cfg.BranchTypes[0]
+		}
+	}
+
+	if dryRun {
+		type plannedTask struct {
+			PlanTask
+			Branch string `json:"branch,omitempty"`
+			Error  string `json:"error,omitempty"`
+		}
+		planned := make([]plannedTask, len(tasks))
+		for i, task := range tasks {
+			planned[i].PlanTask = task
+			branchType := task.Type
+			if branchType == "" {
+				branchType = opts.Type
+			}
+			if branch, err := mgr.MakeBranchName(branchType, task.Title); err != nil {
+				planned[i].Error = err.Error()
+			} else {
+				planned[i].Branch = branch
+			}
+		}
+		cliDone(map[string]any{"tasks": planned}, func() {
+			for _, p := range planned {
+				if p.Error != "" {
+					fmt.Println(ErrorMsg(fmt.Sprintf("%s: %s", p.Title, p.Error)))
+					continue
+				}
+				fmt.Printf("%s  %s\n", StyleBranch.Render(p.Branch), p.Title)
+			}
+		})
+		return
+	}
+
+	if status, err := mgr.WIPStatus(); err == nil && status.Limit > 0 && status.Count+len(tasks) > status.Limit && !yes {
+		msg := fmt.Sprintf("%d tasks would take the repository past its WIP limit (%d/%d worktrees)", len(tasks), status.Count, status.Limit)
+		if jsonOutput() {
+			cliFail(fmt.Errorf("%s (pass --yes to create anyway)", msg))
+		}
+		fmt.Fprintln(os.Stderr, WarnMsg(msg))
+		if !confirmPrompt("Create them anyway?") {
+			cliFail(errors.New("aborted: WIP limit reached"))
+		}
+	}
+	if opts.StartAgent && !jsonOutput() {
+		fmt.Fprintln(os.Stderr, InfoMsg("Waiting for the agents to be ready for their tasks..."))
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+	results, err := mgr.Plan(ctx, opts)
+	if err != nil {
+		cliFail(err)
+	}
+	cliDone(map[string]any{"tasks": results}, func() {
+		for _, res := range results {
+			switch {
+			case res.Error != "":
+				fmt.Println(ErrorMsg(fmt.Sprintf("%s: %s", res.Title, res.Error)))
+			case res.Existing:
+				fmt.Println(InfoMsg(fmt.Sprintf("Already exists, left alone: %s", StylePath.Render(res.Path))))
+			case res.Prompted:
+				fmt.Println(SuccessMsg(fmt.Sprintf("Created %s and sent the task to its agent", StylePath.Render(res.Path))))
+			default:
+				fmt.Println(SuccessMsg(fmt.Sprintf("Created %s", StylePath.Render(res.Path))))
+			}
+		}
+	})
+}
+
 func runList(cmd *cobra.Command, args []string) {
 	mgr := getManager()
 	jsonOut, _ := cmd.Flags().GetBool("json")
 
-	items, err := mgr.ListWorktrees()
+	items, err := mgr.ListWorktrees(context.Background())
 	if err != nil {
 		if errors.Is(err, ErrNotGitRepo) {
-			fmt.Fprintln(os.Stderr, "error: run this command inside a git worktree")
-			os.Exit(1)
+			err = errors.New("run this command inside a git worktree")
+		}
+		if jsonOutput() {
+			cliFail(err)
 		}
 		fmt.Fprintf(os.Stderr, "error: %v\n", err)
 		os.Exit(1)
 	}
+	sortMode := mgr.Cfg.Sort
+	if value, _ := cmd.Flags().GetString("sort"); value != "" {
+		if sortMode, err = parseWorktreeSort(value); err != nil {
+			cliFail(err)
+		}
+	}
+	sortWorktrees(items, sortMode)
+	if values, _ := cmd.Flags().GetStringSlice("label"); len(values) > 0 {
+		labels, err := parseLabels(values)
+		if err != nil {
+			cliFail(err)
+		}
+		kept := items[:0]
+		for _, it := range items {
+			if hasLabels(it, labels) {
+				kept = append(kept, it)
+			}
+		}
+		items = kept
+	}
+	if value, _ := cmd.Flags().GetString("filter"); strings.TrimSpace(value) != "" {
+		repoRoot, err := mgr.RequireRepo()
+		if err != nil {
+			cliFail(err)
+		}
+		items = mgr.filterWorktrees(repoRoot, items, parseWorktreeFilter(resolveFilter(mgr.Cfg.FilterPresets, value)))
+	}
 
+	if jsonOutput() {
+		cliDone(items, nil)
+		return
+	}
 	if jsonOut {
 		enc := json.NewEncoder(os.Stdout)
 		enc.SetIndent("", "  ")
@@ -231,197 +766,1788 @@ func runList(cmd *cobra.Command, args []string) {
 		return
 	}
 
+	columns := listColumns(mgr.Cfg)
+	headers := make([]string, len(columns))
+	widths := make([]int, len(columns))
+	for col, name := range columns {
+		headers[col] = worktreeColumnHeaders[name]
+		widths[col] = len(headers[col])
+	}
+	rows := make([][]string, len(items))
+	pathCol := -1
+	for i, it := range items {
+		rows[i] = make([]string, len(columns))
+		for col, name := range columns {
+			if name == columnPath {
+				pathCol = col
+				continue
+			}
+			rows[i][col] = listCell(name, it)
+			if w := lipgloss.Width(rows[i][col]); w > widths[col] {
+				widths[col] = w
+			}
+		}
+	}
+	if pathCol >= 0 {
+		// On a terminal the path takes the width the other columns leave;
+		// piped output keeps it whole.
+		pathWidth := 0
+		if total, _, err := term.GetSize(int(os.Stdout.Fd())); err == nil {
+			others := append(append([]int(nil), widths[:pathCol]...), widths[pathCol+1:]...)
+			pathWidth = pathColumnWidth(total-2, others, 1)
+		}
+		repoRoot, _ := mgr.RequireRepo()
+		for i, it := range items {
+			path := mgr.displayPath(repoRoot, it.Path)
+			if pathWidth > 0 {
+				path = truncatePath(path, pathWidth)
+			}
+			rows[i][pathCol] = StylePath.Render(path)
+		}
+	}
+
 	t := table.New().
 		Border(lipgloss.NormalBorder()).
 		BorderStyle(lipgloss.NewStyle().Foreground(ColorGreen)).
-		Headers("CUR", "BRANCH", "STATUS", "TMUX", "AGENT", "PATH")
+		Headers(headers...).
+		Rows(rows...)
+
+	fmt.Println(t)
+}
 
-	for _, it := range items {
-		cur := ""
+// listCell renders column name of it for sprout list. The path column is
+// laid out by runList.
+func listCell(name string, it Worktree) string {
+	switch name {
+	case columnCur:
 		if it.Current {
-			cur = "*"
+			return StyleCurrentWorktree.Render("*")
 		}
+	case columnBranch:
 		branch := it.Branch
 		if branch == "" {
 			branch = "detached"
 		}
-		status := "clean"
-		if it.Dirty {
-			status = "dirty"
-		}
-
-		// Styles
-		curStr := cur
-		if it.Current {
-			curStr = StyleCurrentWorktree.Render(cur)
-		}
-
 		branchStr := StyleBranch.Render(branch)
 		if it.Current {
 			branchStr = StyleCurrentWorktree.Render(branch)
 		}
-
-		statusStr := StyleClean.Render(status)
-		if it.Dirty {
-			statusStr = StyleDirty.Render(status)
+		if it.PR != 0 {
+			branchStr += StyleDim.Render(fmt.Sprintf(" #%d", it.PR))
 		}
-
-		tmuxStr := StyleDim.Render(it.TmuxState)
+		return branchStr
+	case columnPriority:
+		priority := worktreePriority(it)
+		switch priority {
+		case priorityHigh:
+			return StyleDirty.Render(priority)
+		case priorityLow:
+			return StyleDim.Render(priority)
+		}
+		return priority
+	case columnLabels:
+		return StyleDim.Render(formatLabels(it))
+	case columnStatus:
+		status := worktreeStatusLabel(it)
+		switch status {
+		case "dirty", rebaseStateAborted:
+			return StyleDirty.Render(status)
+		case rebaseStateRunning:
+			return StyleWarning.Render(status)
+		}
+		return StyleClean.Render(status)
+	case columnTmux:
 		if it.TmuxState == "yes" {
-			tmuxStr = StyleClean.Render(it.TmuxState)
+			return StyleClean.Render(it.TmuxState)
 		}
-
-		agentStr := StyleDim.Render(it.AgentState)
-		if it.AgentState == "yes" {
-			agentStr = StyleClean.Render(it.AgentState)
+		return StyleDim.Render(it.TmuxState)
+	case columnAgent:
+		switch it.AgentState {
+		case "yes":
+			return StyleClean.Render(it.AgentState)
+		case "crashed":
+			return StyleDirty.Render(it.AgentState)
 		}
-
-		pathStr := StylePath.Render(it.Path)
-
-		t.Row(curStr, branchStr, statusStr, tmuxStr, agentStr, pathStr)
+		return StyleDim.Render(it.AgentState)
+	case columnLock:
+		if it.Locked {
+			return StyleWarning.Render("locked")
+		}
+	case columnAhead:
+		ahead := formatAheadBehind(it)
+		if it.Behind > 0 {
+			return StyleWarning.Render(ahead)
+		}
+		return StyleDim.Render(ahead)
+	case columnCheck:
+		check := formatCheck(it.Check)
+		switch {
+		case it.Check.Stale:
+			return StyleDim.Render(check)
+		case it.Check.Status == checkFail:
+			return StyleDirty.Render(check)
+		}
+		return StyleClean.Render(check)
+	case columnCost:
+		return StyleDim.Render(formatAgentUsage(it.Usage))
+	case columnActive:
+		active := formatLastActive(it.LastActive, time.Now())
+		if !it.LastActive.IsZero() && time.Since(it.LastActive) > staleBranchAge {
+			return StyleWarning.Render(active)
+		}
+		return StyleDim.Render(active)
 	}
-
-	fmt.Println(t)
+	return ""
 }
 
 func runGo(cmd *cobra.Command, args []string) {
+	mgr := getManager()
+	args, _ = targetArg(cmd, mgr, args, 0, 1)
 	if len(args) != 1 {
-		fmt.Fprintln(os.Stderr, ErrorMsg("usage: sprout go <target> [--attach] [--no-launch]"))
-		os.Exit(1)
+		cliUsage("sprout go <target> [--attach] [--no-launch]")
 	}
-	mgr := getManager()
 	attach, _ := cmd.Flags().GetBool("attach")
 	noLaunch, _ := cmd.Flags().GetBool("no-launch")
 
 	path, err := mgr.Go(GoOptions{Target: args[0], Launch: !noLaunch, Attach: attach})
 	if err != nil {
-		fmt.Fprintln(os.Stderr, ErrorMsg(err.Error()))
-		os.Exit(1)
+		cliFail(err)
 	}
-	fmt.Println(SuccessMsg(StylePath.Render(path)))
+	cliDone(map[string]any{"path": path}, func() {
+		fmt.Println(SuccessMsg(StylePath.Render(path)))
+	})
 	emitCDMarkerIfEnabled(mgr.Cfg, path)
 }
 
 func runPath(cmd *cobra.Command, args []string) {
+	mgr := getManager()
+	args, _ = targetArg(cmd, mgr, args, 0, 1)
 	if len(args) != 1 {
-		fmt.Fprintln(os.Stderr, ErrorMsg("usage: sprout path <target>"))
-		os.Exit(1)
+		cliUsage("sprout path <target>")
 	}
-	mgr := getManager()
 	path, err := mgr.Path(args[0])
 	if err != nil {
-		fmt.Fprintln(os.Stderr, ErrorMsg(err.Error()))
-		os.Exit(1)
+		cliFail(err)
 	}
-	fmt.Println(StylePath.Render(path))
+	cliDone(map[string]any{"path": path}, func() {
+		fmt.Println(StylePath.Render(path))
+	})
 }
 
 func runLaunch(cmd *cobra.Command, args []string) {
+	mgr := getManager()
+	args, _ = targetArg(cmd, mgr, args, 0, 1)
 	if len(args) != 1 {
-		fmt.Fprintln(os.Stderr, ErrorMsg("usage: sprout launch <target> [--no-attach]"))
-		os.Exit(1)
+		cliUsage("sprout launch <target> [--no-attach] [--dry-run]")
+	}
+	if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+		preview, err := mgr.PreviewLaunch(args[0])
+		if err != nil {
+			cliFail(err)
+		}
+		cliDone(preview, func() {
+			if preview.Exists {
+				fmt.Fprintln(os.Stderr, InfoMsg(fmt.Sprintf("Session %s is running; launching would only focus it. Creating it would run:", preview.Session)))
+			}
+			for _, command := range preview.Commands {
+				fmt.Println(command)
+			}
+		})
+		return
 	}
-	mgr := getManager()
 	noAttach, _ := cmd.Flags().GetBool("no-attach")
 	path, err := mgr.Launch(LaunchOptions{Target: args[0], NoAttach: noAttach})
 	if err != nil {
-		fmt.Fprintln(os.Stderr, ErrorMsg(err.Error()))
-		os.Exit(1)
+		cliFail(err)
+	}
+	cliDone(map[string]any{"path": path}, func() {
+		fmt.Println(SuccessMsg(fmt.Sprintf("Launched %s", StylePath.Render(path))))
+	})
+}
+
+// runPopup is meant for a tmux popup bound to a key, like
+// display-popup -E "sprout popup": it picks a worktree and switches the
+// client to its session, starting the session when it is not running.
+// Closing the picker does nothing, so the popup just goes away.
+func runPopup(cmd *cobra.Command, args []string) {
+	mgr := getManager()
+	if !mgr.insideTmux() {
+		cliFail(errors.New("sprout popup runs inside tmux, e.g. tmux display-popup -E \"sprout popup\""))
+	}
+	items, err := mgr.ListWorktrees(context.Background())
+	if err != nil {
+		cliFail(err)
+	}
+	wt, err := pickWorktree(items, "")
+	if errors.Is(err, errPickCanceled) {
+		return
+	}
+	if err != nil {
+		cliFail(err)
+	}
+	if _, err := mgr.Launch(LaunchOptions{Target: wt.Path, SwitchClient: true}); err != nil {
+		cliFail(err)
 	}
-	fmt.Println(SuccessMsg(fmt.Sprintf("Launched %s", StylePath.Render(path))))
 }
 
 func runDetach(cmd *cobra.Command, args []string) {
+	mgr := getManager()
+	args, _ = targetArg(cmd, mgr, args, 0, 1)
 	if len(args) != 1 {
-		fmt.Fprintln(os.Stderr, ErrorMsg("usage: sprout detach <target>"))
-		os.Exit(1)
+		cliUsage("sprout detach <target>")
 	}
-	mgr := getManager()
 	path, detached, err := mgr.Detach(args[0])
 	if err != nil {
-		fmt.Fprintln(os.Stderr, ErrorMsg(err.Error()))
-		os.Exit(1)
-	}
-	if detached {
-		fmt.Println(SuccessMsg(fmt.Sprintf("Detached %s", StylePath.Render(path))))
-	} else {
-		fmt.Println(InfoMsg(fmt.Sprintf("Session not running: %s", StylePath.Render(path))))
+		cliFail(err)
 	}
+	cliDone(map[string]any{"path": path, "detached": detached}, func() {
+		if detached {
+			fmt.Println(SuccessMsg(fmt.Sprintf("Detached %s", StylePath.Render(path))))
+		} else {
+			fmt.Println(InfoMsg(fmt.Sprintf("Session not running: %s", StylePath.Render(path))))
+		}
+	})
 }
 
 func runAgent(cmd *cobra.Command, args []string) {
 	mgr := getManager()
+	args, _ = targetArg(cmd, mgr, args, 1, max(len(args), 2))
+	if len(args) < 2 || len(args) > 3 {
+		cliUsage("sprout agent <action> <target> [text]")
+	}
 	action := args[0]
+	cliOutput.Command = "agent " + action
 	target := args[1]
+	agentType, _ := cmd.Flags().GetString("type")
+	prompt, _ := cmd.Flags().GetString("prompt")
+	promptTimeout, _ := cmd.Flags().GetDuration("prompt-timeout")
+	if len(args) > 2 && action != "send" {
+		cliFail(fmt.Errorf("agent %s takes no text argument", action))
+	}
 	switch action {
 	case "start":
-		path, already, err := mgr.StartAgent(AgentOptions{Target: target, Attach: false})
-		if err != nil {
-			fmt.Fprintln(os.Stderr, ErrorMsg(err.Error()))
-			os.Exit(1)
+		if prompt != "" && !jsonOutput() {
+			fmt.Fprintln(os.Stderr, InfoMsg("Waiting for the agent to be ready for the prompt..."))
 		}
-		if already {
-			fmt.Println(InfoMsg(fmt.Sprintf("Agent already running: %s", StylePath.Render(path))))
-		} else {
-			fmt.Println(SuccessMsg(fmt.Sprintf("Agent started: %s", StylePath.Render(path))))
+		path, already, err := startAgentCLI(mgr, AgentOptions{Target: target, AgentType: agentType, Prompt: prompt, PromptTimeout: promptTimeout})
+		if err != nil {
+			cliFail(err)
 		}
+		cliDone(map[string]any{"path": path, "already_running": already, "prompted": prompt != ""}, func() {
+			if already {
+				fmt.Println(InfoMsg(fmt.Sprintf("Agent already running: %s", StylePath.Render(path))))
+			} else {
+				fmt.Println(SuccessMsg(fmt.Sprintf("Agent started: %s", StylePath.Render(path))))
+			}
+			if prompt != "" {
+				fmt.Println(SuccessMsg("Prompt sent"))
+			}
+		})
 	case "attach":
-		path, err := mgr.AttachAgent(target)
+		path, _, err := startAgentCLI(mgr, AgentOptions{Target: target, Attach: true, AgentType: agentType, Prompt: prompt, PromptTimeout: promptTimeout})
 		if err != nil {
-			fmt.Fprintln(os.Stderr, ErrorMsg(err.Error()))
-			os.Exit(1)
+			cliFail(err)
 		}
-		fmt.Println(SuccessMsg(fmt.Sprintf("Agent attached: %s", StylePath.Render(path))))
+		cliDone(map[string]any{"path": path}, func() {
+			fmt.Println(SuccessMsg(fmt.Sprintf("Agent attached: %s", StylePath.Render(path))))
+		})
 	case "stop":
-		path, stopped, err := mgr.StopAgent(target)
+		path, stopped, err := mgr.StopAgent(context.Background(), target)
 		if err != nil {
-			fmt.Fprintln(os.Stderr, ErrorMsg(err.Error()))
-			os.Exit(1)
+			cliFail(err)
 		}
-		if stopped {
-			fmt.Println(SuccessMsg(fmt.Sprintf("Agent stopped: %s", StylePath.Render(path))))
-		} else {
-			fmt.Println(InfoMsg(fmt.Sprintf("Agent not running: %s", StylePath.Render(path))))
+		cliDone(map[string]any{"path": path, "stopped": stopped}, func() {
+			if stopped {
+				fmt.Println(SuccessMsg(fmt.Sprintf("Agent stopped: %s", StylePath.Render(path))))
+			} else {
+				fmt.Println(InfoMsg(fmt.Sprintf("Agent not running: %s", StylePath.Render(path))))
+			}
+		})
+	case "restart":
+		replay, _ := cmd.Flags().GetBool("replay")
+		attach, _ := cmd.Flags().GetBool("attach")
+		if replay && !jsonOutput() {
+			fmt.Fprintln(os.Stderr, InfoMsg("Waiting for the agent to be ready for the last prompt..."))
 		}
-	default:
-		fmt.Fprintln(os.Stderr, ErrorMsg(fmt.Sprintf("unknown action for agent: %s", action)))
-		os.Exit(1)
+		path, replayed, err := mgr.RestartAgent(context.Background(), RestartAgentOptions{
+			Target:           target,
+			Attach:           attach,
+			AgentType:        agentType,
+			ReplayLastPrompt: replay,
+			PromptTimeout:    promptTimeout,
+		})
+		if err != nil {
+			cliFail(err)
+		}
+		if replay && replayed == "" {
+			cliWarn("No prompt sent to this agent yet; nothing to replay")
+		}
+		cliDone(map[string]any{"path": path, "replayed_prompt": replayed}, func() {
+			fmt.Println(SuccessMsg(fmt.Sprintf("Agent restarted: %s", StylePath.Render(path))))
+			if replayed != "" {
+				fmt.Println(SuccessMsg("Last prompt sent again"))
+			}
+		})
+	case "send":
+		enter, _ := cmd.Flags().GetBool("enter")
+		raw, _ := cmd.Flags().GetBool("raw-keys")
+		if raw && !cmd.Flags().Changed("enter") {
+			// Raw keys are sent as given; Enter is one of them if wanted.
+			enter = false
+		}
+		text, err := agentSendText(args[2:])
+		if err != nil {
+			cliFail(err)
+		}
+		path, err := agentSendCLI(mgr, target, text, raw, enter)
+		if err != nil {
+			cliFail(err)
+		}
+		cliDone(map[string]any{"path": path, "submitted": enter}, func() {
+			fmt.Println(SuccessMsg(fmt.Sprintf("Sent to agent: %s", StylePath.Render(path))))
+		})
+	case "output":
+		lines, _ := cmd.Flags().GetInt("lines")
+		follow, _ := cmd.Flags().GetBool("follow")
+		strip, _ := cmd.Flags().GetBool("strip-ansi")
+		if lines < 0 {
+			cliFail(fmt.Errorf("invalid --lines %d (want 0 or more)", lines))
+		}
+		opts := AgentOutputOptions{Target: target, Lines: lines, StripANSI: strip || jsonOutput()}
+		if !follow {
+			path, output, err := mgr.AgentOutputTail(opts)
+			if err != nil {
+				cliFail(err)
+			}
+			cliDone(map[string]any{"path": path, "lines": output}, func() {
+				for _, line := range output {
+					fmt.Println(line)
+				}
+			})
+			return
+		}
+		// Like events --follow, following streams lines whatever the output
+		// format: one JSON string per line with --output json.
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+		done := make(chan struct{})
+		go func() {
+			<-stop
+			close(done)
+		}()
+		err := mgr.FollowAgentOutput(opts, done, func(line string) error {
+			if jsonOutput() {
+				data, err := json.Marshal(line)
+				if err != nil {
+					return err
+				}
+				line = string(data)
+			}
+			_, err := fmt.Println(line)
+			return err
+		})
+		if err != nil {
+			cliFail(err)
+		}
+	case "history":
+		path, entries, err := mgr.PromptHistory(target)
+		if err != nil {
+			cliFail(err)
+		}
+		if entries == nil {
+			entries = []PromptHistoryEntry{}
+		}
+		cliDone(map[string]any{"path": path, "prompts": entries}, func() {
+			if len(entries) == 0 {
+				fmt.Println(InfoMsg(fmt.Sprintf("No prompts sent yet: %s", StylePath.Render(path))))
+				return
+			}
+			for i, entry := range entries {
+				fmt.Printf("%s %s %s\n",
+					StyleDim.Render(fmt.Sprintf("%3d", i+1)),
+					StyleDim.Render(entry.SentAt.Local().Format("2006-01-02 15:04")),
+					entry.Prompt,
+				)
+			}
+		})
+	default:
+		cliFail(fmt.Errorf("unknown action for agent: %s", action))
+	}
+}
+
+// agentSendText is the text of agent send: its argument, or standard input
+// when there is none or it is "-". One trailing newline is dropped, so that
+// echo and heredocs send what they show.
+func agentSendText(args []string) (string, error) {
+	if len(args) > 0 && args[0] != "-" {
+		return args[0], nil
+	}
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", errors.New("agent send needs text as an argument or on standard input")
 	}
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return "", err
+	}
+	text := strings.TrimSuffix(string(data), "\n")
+	return strings.TrimSuffix(text, "\r"), nil
+}
+
+// agentSendCLI types text into the agent of target. Text submitted with Enter
+// is a prompt: it is checkpointed and kept in the prompt history like one
+// sent from the TUI. Raw keys and text typed without Enter are not.
+func agentSendCLI(mgr *Manager, target, text string, raw, enter bool) (string, error) {
+	if _, _, err := mgr.runningAgentPane(target); err != nil {
+		return "", err
+	}
+	if enter && !raw {
+		if strings.TrimSpace(text) == "" {
+			return "", errors.New("nothing to send")
+		}
+		return mgr.SendAgentCommand(target, text)
+	}
+	var keys []string
+	if raw {
+		keys = strings.Fields(text)
+	} else if text != "" {
+		keys = []string{"-l", text}
+	}
+	if enter {
+		keys = append(keys, "C-m")
+	} else if len(keys) == 0 {
+		return "", errors.New("nothing to send")
+	}
+	return mgr.SendAgentKeys(target, keys...)
+}
+
+// startAgentCLI starts an agent. When its command is not installed it offers
+// to start an installed agent type instead.
+func startAgentCLI(mgr *Manager, opts AgentOptions) (string, bool, error) {
+	path, already, err := mgr.StartAgent(context.Background(), opts)
+	var notFound *AgentNotFoundError
+	if err == nil || !errors.As(err, &notFound) {
+		return path, already, err
+	}
+	if len(notFound.Alternatives) == 0 {
+		return "", false, fmt.Errorf("%w (install it or set agent_command / default_agent_type)", err)
+	}
+	installed := strings.Join(notFound.Alternatives, ", ")
+	if jsonOutput() {
+		return "", false, fmt.Errorf("%w (installed agent types: %s; pass --type)", err, installed)
+	}
+	fmt.Fprintln(os.Stderr, ErrorMsg(err.Error()))
+	fmt.Fprintln(os.Stderr, InfoMsg("Installed agent types: "+installed))
+	alt := notFound.Alternatives[0]
+	if !confirmPrompt(fmt.Sprintf("Start %s instead?", alt)) {
+		return "", false, errors.New("aborted")
+	}
+	opts.AgentType = alt
+	return mgr.StartAgent(context.Background(), opts)
 }
 
 func runRemove(cmd *cobra.Command, args []string) {
+	mgr := getManager()
+	given := args
+	args, partial := targetArg(cmd, mgr, args, 0, 1)
 	if len(args) != 1 {
-		fmt.Fprintln(os.Stderr, ErrorMsg("usage: sprout rm <target> [--delete-branch] [--force]"))
-		os.Exit(1)
+		cliUsage("sprout rm <target> [--delete-branch] [--force] [--yes] [--switch]")
 	}
-	mgr := getManager()
 	force, _ := cmd.Flags().GetBool("force")
 	deleteBranch, _ := cmd.Flags().GetBool("delete-branch")
+	yes, _ := cmd.Flags().GetBool("yes")
+	switchToMain, _ := cmd.Flags().GetBool("switch")
+
+	// A partial target is only a guess at the worktree to remove.
+	if partial && !yes {
+		if jsonOutput() {
+			cliFail(fmt.Errorf("%q only partially matches %s (pass --yes to remove it)", given[0], args[0]))
+		}
+		if !confirmPrompt("Remove it?") {
+			cliFail(errors.New("aborted"))
+		}
+	}
+
+	if force && !yes {
+		if wt, err := mgr.FindWorktree(args[0]); err == nil && wt.Locked {
+			if jsonOutput() {
+				cliFail(fmt.Errorf("%s (pass --yes to confirm removal)", lockedWorktreeMessage(wt)))
+			}
+			fmt.Fprintln(os.Stderr, WarnMsg(lockedWorktreeMessage(wt)))
+			if !confirmPrompt("Remove it anyway?") {
+				cliFail(errors.New("aborted"))
+			}
+		}
+	}
 
-	path, warnings, err := mgr.Remove(RemoveOptions{Target: args[0], Force: force, DeleteBranch: deleteBranch})
+	ctx, stop := interruptContext()
+	defer stop()
+	mainPath := ""
+	path, warnings, err := mgr.Remove(ctx, RemoveOptions{
+		Target:       args[0],
+		Force:        force,
+		DeleteBranch: deleteBranch,
+		// The shell hook follows the cd marker out of the removed directory.
+		SwitchToMain: switchToMain || mgr.Cfg.EmitCDMarker,
+		OnSwitch:     func(p string) { mainPath = p },
+	})
 	if err != nil {
-		fmt.Fprintln(os.Stderr, ErrorMsg(err.Error()))
-		os.Exit(1)
+		// Removal can fail after the directory is gone; the shell should not
+		// be left in it.
+		if mainPath != "" && !mgr.dirExists(args[0]) {
+			emitCDMarkerIfEnabled(mgr.Cfg, mainPath)
+		}
+		cliFail(err)
 	}
 	for _, w := range warnings {
-		fmt.Fprintln(os.Stderr, WarnMsg(w))
+		cliWarn(w)
+	}
+	if mainPath != "" && !mgr.Cfg.EmitCDMarker {
+		cliWarn(fmt.Sprintf("your shell is still in the removed directory; cd %s", mainPath))
+	}
+	result := map[string]any{"path": path}
+	if mainPath != "" {
+		result["switched_to"] = mainPath
+	}
+	cliDone(result, func() {
+		fmt.Println(SuccessMsg(fmt.Sprintf("Removed %s", StylePath.Render(path))))
+		if rec, err := mgr.LastRemoved(); err == nil && rec != nil && rec.Path == path {
+			fmt.Println(InfoMsg(fmt.Sprintf("sprout undo restores it for the next %d minute(s)", mgr.Cfg.UndoMinutes)))
+		}
+		if mainPath != "" {
+			fmt.Println(InfoMsg(fmt.Sprintf("Switched to the main worktree: %s", StylePath.Render(mainPath))))
+		}
+	})
+	if mainPath != "" {
+		emitCDMarkerIfEnabled(mgr.Cfg, mainPath)
 	}
-	fmt.Println(SuccessMsg(fmt.Sprintf("Removed %s", StylePath.Render(path))))
 }
 
-func runDoctor(cmd *cobra.Command, args []string) {
+// interruptContext returns a context canceled by Ctrl-C or SIGTERM, so a
+// command can stop its git work and clean up instead of dying halfway. A
+// second signal kills the process as usual.
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx, stop
+}
+
+func confirmPrompt(question string) bool {
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+// targetArg resolves args[i], the worktree a command taking want arguments
+// targets, to the worktree's path, so that a unique prefix or fuzzy match of
+// its branch names it too. When the target is left out on an interactive
+// terminal, or with --pick, the worktree is chosen in a picker instead, with
+// a given target as its filter. It reports whether the target only partially
+// matched, after saying which worktree that was.
+func targetArg(cmd *cobra.Command, mgr *Manager, args []string, i, want int) ([]string, bool) {
+	pick, _ := cmd.Flags().GetBool("pick")
+	interactive := !jsonOutput() && term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stdout.Fd()))
+	switch {
+	case len(args) == want-1 && i <= len(args) && (pick || interactive):
+		wt := pickTarget(mgr, "", interactive)
+		return append(append(append([]string{}, args[:i]...), wt.Path), args[i:]...), false
+	case len(args) == want && pick:
+		wt := pickTarget(mgr, args[i], interactive)
+		out := append([]string{}, args...)
+		out[i] = wt.Path
+		return out, false
+	case len(args) != want:
+		return args, false
+	}
+	wt, exact, err := mgr.ResolveTarget(args[i])
+	if err != nil {
+		cliFail(err)
+	}
+	if !exact && !jsonOutput() {
+		fmt.Fprintln(os.Stderr, InfoMsg(fmt.Sprintf("Using %s", StyleBranch.Render(worktreeBranchOrName(wt)))))
+	}
+	out := append([]string{}, args...)
+	out[i] = wt.Path
+	return out, !exact
+}
+
+// pickTarget lets the user choose the worktree a command targets.
+func pickTarget(mgr *Manager, query string, interactive bool) *Worktree {
+	if !interactive {
+		cliFail(errors.New("--pick needs an interactive terminal"))
+	}
+	items, err := mgr.ListWorktrees(context.Background())
+	if err != nil {
+		cliFail(err)
+	}
+	wt, err := pickWorktree(items, query)
+	if err != nil {
+		cliFail(err)
+	}
+	return wt
+}
+
+func runUndo(cmd *cobra.Command, args []string) {
+	mgr := getManager()
+	ctx, stop := interruptContext()
+	defer stop()
+	rec, err := mgr.Undo(ctx)
+	if err != nil {
+		cliFail(err)
+	}
+	cliDone(rec, func() {
+		fmt.Println(SuccessMsg(fmt.Sprintf("Restored %s", StylePath.Render(rec.Path))))
+		if rec.BranchDeleted {
+			fmt.Println(InfoMsg(fmt.Sprintf("Recreated branch %s", StyleBranch.Render(rec.Branch))))
+		}
+		if rec.Files != "" {
+			fmt.Println(InfoMsg("Uncommitted files are back as unstaged changes."))
+		}
+	})
+}
+
+func runFetch(cmd *cobra.Command, args []string) {
 	mgr := getManager()
-	report := mgr.Doctor()
-	for _, line := range report.Lines {
-		if strings.HasPrefix(line, "ok") {
-			fmt.Println(SuccessMsg(strings.TrimPrefix(line, "ok   ")))
-		} else if strings.HasPrefix(line, "miss") {
-			fmt.Println(ErrorMsg(strings.TrimPrefix(line, "miss ")))
-		} else if strings.HasPrefix(line, "warn") {
-			fmt.Println(WarnMsg(strings.TrimPrefix(line, "warn ")))
+	ctx, stop := interruptContext()
+	defer stop()
+	res, err := mgr.Fetch(ctx)
+	if err != nil {
+		cliFail(err)
+	}
+	cliDone(res, func() {
+		fmt.Println(SuccessMsg(fmt.Sprintf("Fetched %s in %s", StylePath.Render(res.Repo), formatPerfMS(res.DurationMS))))
+	})
+}
+
+func runLock(cmd *cobra.Command, args []string) {
+	mgr := getManager()
+	args, _ = targetArg(cmd, mgr, args, 0, 1)
+	if len(args) != 1 {
+		cliUsage("sprout lock <target> [--reason <text>]")
+	}
+	reason, _ := cmd.Flags().GetString("reason")
+	path, err := mgr.Lock(args[0], reason)
+	if err != nil {
+		cliFail(err)
+	}
+	cliDone(map[string]any{"path": path, "reason": reason}, func() {
+		fmt.Println(SuccessMsg(fmt.Sprintf("Locked %s", StylePath.Render(path))))
+	})
+}
+
+func runUnlock(cmd *cobra.Command, args []string) {
+	mgr := getManager()
+	args, _ = targetArg(cmd, mgr, args, 0, 1)
+	if len(args) != 1 {
+		cliUsage("sprout unlock <target>")
+	}
+	path, unlocked, err := mgr.Unlock(args[0])
+	if err != nil {
+		cliFail(err)
+	}
+	cliDone(map[string]any{"path": path, "unlocked": unlocked}, func() {
+		if !unlocked {
+			fmt.Println(InfoMsg(fmt.Sprintf("Not locked: %s", StylePath.Render(path))))
+			return
+		}
+		fmt.Println(SuccessMsg(fmt.Sprintf("Unlocked %s", StylePath.Render(path))))
+	})
+}
+
+func runMove(cmd *cobra.Command, args []string) {
+	mgr := getManager()
+	args, _ = targetArg(cmd, mgr, args, 0, 2)
+	if len(args) != 2 {
+		cliUsage("sprout mv <target> <new-branch>")
+	}
+	path, warnings, err := mgr.Move(MoveOptions{Target: args[0], NewBranch: args[1]})
+	if err != nil {
+		cliFail(err)
+	}
+	for _, w := range warnings {
+		cliWarn(w)
+	}
+	cliDone(map[string]any{"path": path, "branch": args[1]}, func() {
+		fmt.Println(SuccessMsg(fmt.Sprintf("Moved to %s: %s", StyleBranch.Render(args[1]), StylePath.Render(path))))
+	})
+	emitCDMarkerIfEnabled(mgr.Cfg, path)
+}
+
+func runPriority(cmd *cobra.Command, args []string) {
+	mgr := getManager()
+	args, _ = targetArg(cmd, mgr, args, 0, max(len(args), 1))
+	if len(args) < 1 || len(args) > 2 {
+		cliUsage("sprout priority <target> [high|normal|low]")
+	}
+	if len(args) == 1 {
+		wt, err := mgr.FindWorktree(args[0])
+		if err != nil {
+			cliFail(err)
+		}
+		priority := worktreePriority(*wt)
+		cliDone(map[string]any{"path": wt.Path, "priority": priority}, func() {
+			fmt.Println(priority)
+		})
+		return
+	}
+	priority, err := parsePriority(args[1])
+	if err != nil {
+		cliFail(err)
+	}
+	path, err := mgr.SetPriority(args[0], priority)
+	if err != nil {
+		cliFail(err)
+	}
+	cliDone(map[string]any{"path": path, "priority": priority}, func() {
+		fmt.Println(SuccessMsg(fmt.Sprintf("Priority set to %s: %s", priority, StylePath.Render(path))))
+	})
+}
+
+func runLabel(cmd *cobra.Command, args []string) {
+	mgr := getManager()
+	args, _ = targetArg(cmd, mgr, args, 0, max(len(args), 1))
+	usage := "sprout label <target> [add|rm <label>...|clear]"
+	if len(args) < 1 {
+		cliUsage(usage)
+	}
+	var (
+		path   string
+		labels []string
+		err    error
+	)
+	switch {
+	case len(args) == 1:
+		wt, err := mgr.FindWorktree(args[0])
+		if err != nil {
+			cliFail(err)
+		}
+		labels := wt.Labels
+		if labels == nil {
+			labels = []string{}
+		}
+		cliDone(map[string]any{"path": wt.Path, "labels": labels}, func() {
+			for _, label := range labels {
+				fmt.Println(label)
+			}
+		})
+		return
+	case args[1] == "add" && len(args) > 2:
+		path, labels, err = mgr.AddLabels(args[0], args[2:])
+	case (args[1] == "rm" || args[1] == "remove") && len(args) > 2:
+		path, labels, err = mgr.RemoveLabels(args[0], args[2:])
+	case args[1] == "clear" && len(args) == 2:
+		path, labels, err = mgr.RemoveLabels(args[0], nil)
+	default:
+		cliUsage(usage)
+	}
+	if err != nil {
+		cliFail(err)
+	}
+	if labels == nil {
+		labels = []string{}
+	}
+	cliDone(map[string]any{"path": path, "labels": labels}, func() {
+		if len(labels) == 0 {
+			fmt.Println(SuccessMsg(fmt.Sprintf("Labels cleared: %s", StylePath.Render(path))))
+			return
+		}
+		fmt.Println(SuccessMsg(fmt.Sprintf("Labels set to %s: %s", strings.Join(labels, ", "), StylePath.Render(path))))
+	})
+}
+
+func runRebase(cmd *cobra.Command, args []string) {
+	mgr := getManager()
+	args, _ = targetArg(cmd, mgr, args, 0, 1)
+	if len(args) != 1 {
+		cliUsage("sprout rebase <target> [--onto <branch>] [--no-attach]")
+	}
+	onto, _ := cmd.Flags().GetString("onto")
+	noAttach, _ := cmd.Flags().GetBool("no-attach")
+	path, base, err := mgr.Rebase(RebaseOptions{Target: args[0], BaseBranch: onto, Attach: !noAttach})
+	if err != nil {
+		cliFail(err)
+	}
+	cliDone(map[string]any{"path": path, "base": base}, func() {
+		fmt.Println(SuccessMsg(fmt.Sprintf("Rebase onto %s opened: %s", StyleBranch.Render(base), StylePath.Render(path))))
+	})
+}
+
+// runMergeCmd checks that the branch can be merged, shows what will happen,
+// and merges once confirmed.
+func runMergeCmd(cmd *cobra.Command, args []string) {
+	mgr := getManager()
+	args, _ = targetArg(cmd, mgr, args, 0, 1)
+	if len(args) != 1 {
+		cliUsage("sprout merge <target> [--into <branch>] [--squash] [-m <message>] [--remove] [--delete-branch] [--skip-checks] [--yes]")
+	}
+	opts := MergeOptions{Target: args[0]}
+	opts.BaseBranch, _ = cmd.Flags().GetString("into")
+	opts.Squash, _ = cmd.Flags().GetBool("squash")
+	opts.Message, _ = cmd.Flags().GetString("message")
+	opts.Remove, _ = cmd.Flags().GetBool("remove")
+	opts.DeleteBranch, _ = cmd.Flags().GetBool("delete-branch")
+	opts.SkipChecks, _ = cmd.Flags().GetBool("skip-checks")
+	yes, _ := cmd.Flags().GetBool("yes")
+
+	ctx, stop := interruptContext()
+	defer stop()
+	plan, err := mgr.PlanMerge(ctx, opts)
+	if err != nil {
+		cliFail(err)
+	}
+	if !yes {
+		if jsonOutput() {
+			cliFail(errors.New("pass --yes to merge without the confirmation prompt"))
+		}
+		for _, line := range mergePlanLines(plan) {
+			fmt.Fprintln(os.Stderr, InfoMsg(line))
+		}
+		if !confirmPrompt("Merge?") {
+			cliFail(errors.New("aborted"))
+		}
+	}
+
+	res, err := mgr.Merge(ctx, opts)
+	if err != nil {
+		cliFail(err)
+	}
+	for _, w := range res.Warnings {
+		cliWarn(w)
+	}
+	cliDone(map[string]any{"path": res.Path, "branch": res.Branch, "base": res.Base, "commit": res.Commit, "squash": res.Squash, "removed": res.Removed}, func() {
+		fmt.Println(SuccessMsg(fmt.Sprintf("Merged %s into %s (%s)", StyleBranch.Render(res.Branch), StyleBranch.Render(res.Base), shortHash(res.Commit))))
+		if res.Removed {
+			fmt.Println(SuccessMsg(fmt.Sprintf("Removed %s", StylePath.Render(res.Path))))
+		}
+	})
+}
+
+// mergePlanLines describes a merge plan for the confirmation prompt.
+func mergePlanLines(plan MergePlan) []string {
+	how := "Merge"
+	if plan.Squash {
+		how = "Squash-merge"
+	}
+	where := "in a temporary worktree"
+	if plan.Into != "" {
+		where = "in " + plan.Into
+	}
+	commits := "1 commit"
+	if plan.Commits != 1 {
+		commits = fmt.Sprintf("%d commits", plan.Commits)
+	}
+	lines := []string{
+		fmt.Sprintf("%s %s (%s) into %s %s", how, plan.Branch, commits, plan.Base, where),
+		"Message: " + plan.Message,
+		"Pull request checks: " + plan.Checks,
+	}
+	switch {
+	case plan.Delete:
+		lines = append(lines, "Then remove "+plan.Path+" and delete "+plan.Branch)
+	case plan.Remove:
+		lines = append(lines, "Then remove "+plan.Path)
+	}
+	return lines
+}
+
+func runPick(cmd *cobra.Command, args []string) {
+	mgr := getManager()
+	files, _ := cmd.Flags().GetStringSlice("files")
+	since, _ := cmd.Flags().GetString("since")
+	commits, _ := cmd.Flags().GetStringSlice("commit")
+	res, err := mgr.Pick(context.Background(), PickOptions{Source: args[0], Dest: args[1], Files: files, Since: since, Commits: commits})
+	if err != nil {
+		cliFail(err)
+	}
+	cliDone(map[string]any{"source": res.Source, "dest": res.Dest, "files": res.Files, "commits": res.Commits}, func() {
+		if len(res.Commits) > 0 {
+			fmt.Println(SuccessMsg(fmt.Sprintf("Cherry-picked %d commit(s) into %s", len(res.Commits), StylePath.Render(res.Dest))))
+			return
+		}
+		fmt.Println(SuccessMsg(fmt.Sprintf("Applied changes to %s into %s", strings.Join(res.Files, ", "), StylePath.Render(res.Dest))))
+	})
+}
+
+func runShare(cmd *cobra.Command, args []string) {
+	mgr := getManager()
+	args, _ = targetArg(cmd, mgr, args, 0, 1)
+	if len(args) != 1 {
+		cliUsage("sprout share <target> [--lan] [--addr <host:port>] [--agent] [--for <duration>]")
+	}
+	addr, _ := cmd.Flags().GetString("addr")
+	lan, _ := cmd.Flags().GetBool("lan")
+	agent, _ := cmd.Flags().GetBool("agent")
+	duration, _ := cmd.Flags().GetDuration("for")
+	if addr == "" && lan {
+		addr = "0.0.0.0:0"
+	}
+	srv, err := mgr.Share(ShareOptions{Target: args[0], Addr: addr, Agent: agent})
+	if err != nil {
+		cliFail(err)
+	}
+	cliDone(map[string]any{"path": srv.Path, "urls": srv.URLs}, func() {
+		fmt.Println(SuccessMsg(fmt.Sprintf("Sharing diff for %s (read-only)", StylePath.Render(srv.Path))))
+		for _, u := range srv.URLs {
+			fmt.Println("  " + u)
+		}
+		fmt.Println(InfoMsg("Press Ctrl-C to stop."))
+	})
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		if duration > 0 {
+			select {
+			case <-stop:
+			case <-time.After(duration):
+			}
 		} else {
+			<-stop
+		}
+		_ = srv.Close()
+	}()
+	if err := srv.Serve(); err != nil {
+		fmt.Fprintln(os.Stderr, ErrorMsg(err.Error()))
+		os.Exit(1)
+	}
+}
+
+func runExport(cmd *cobra.Command, args []string) {
+	mgr := getManager()
+	args, _ = targetArg(cmd, mgr, args, 0, 1)
+	if len(args) != 1 {
+		cliUsage("sprout export <target> [--format patch|html|markdown] [-o <file>]")
+	}
+	format, _ := cmd.Flags().GetString("format")
+	out, _ := cmd.Flags().GetString("out")
+	res, err := mgr.Export(ExportOptions{Target: args[0], Format: format})
+	if err != nil {
+		cliFail(err)
+	}
+	if out == "-" {
+		if jsonOutput() {
+			cliDone(map[string]any{"path": res.Path, "branch": res.Branch, "format": res.Format, "content": string(res.Content)}, nil)
+			return
+		}
+		_, _ = os.Stdout.Write(res.Content)
+		return
+	}
+	if out == "" {
+		out = safeName(res.Branch) + exportExtension(res.Format)
+	}
+	if err := os.WriteFile(out, res.Content, 0o644); err != nil {
+		cliFail(err)
+	}
+	cliDone(map[string]any{"path": res.Path, "branch": res.Branch, "format": res.Format, "file": absPath(out), "bytes": len(res.Content)}, func() {
+		fmt.Println(SuccessMsg(fmt.Sprintf("Exported %s as %s: %s", StyleBranch.Render(res.Branch), res.Format, StylePath.Render(out))))
+	})
+}
+
+func runSessions(cmd *cobra.Command, args []string) {
+	mgr := getManager()
+	action := "list"
+	if len(args) == 1 {
+		action = args[0]
+	}
+	cliOutput.Command = "sessions " + action
+	switch action {
+	case "list":
+		sessions, err := mgr.Sessions()
+		if err != nil {
+			cliFail(err)
+		}
+		if sessions == nil {
+			sessions = []TmuxSession{}
+		}
+		cliDone(map[string]any{"sessions": sessions}, func() {
+			if len(sessions) == 0 {
+				fmt.Println(InfoMsg("No sprout tmux sessions running."))
+				return
+			}
+			t := table.New().
+				Border(lipgloss.NormalBorder()).
+				BorderStyle(lipgloss.NewStyle().Foreground(ColorGreen)).
+				Headers("SESSION", "WINDOWS", "ATTACHED", "WORKTREE")
+			orphans := 0
+			for _, s := range sessions {
+				attached := StyleDim.Render("no")
+				if s.Attached {
+					attached = StyleClean.Render("yes")
+				}
+				worktree := StylePath.Render(s.Worktree)
+				if s.Orphan {
+					worktree = StyleDirty.Render("orphaned")
+					orphans++
+				} else if s.Worktree == "" {
+					worktree = StyleDim.Render(s.Path)
+				}
+				t.Row(s.Name, fmt.Sprint(s.Windows), attached, worktree)
+			}
+			fmt.Println(t)
+			if orphans > 0 {
+				fmt.Println(InfoMsg(fmt.Sprintf("%d orphaned session(s); run sprout sessions kill-orphans to remove them.", orphans)))
+			}
+		})
+	case "kill-orphans":
+		killed, err := mgr.KillOrphanSessions()
+		if err != nil {
+			for _, name := range killed {
+				cliWarn("killed " + name)
+			}
+			cliFail(err)
+		}
+		if killed == nil {
+			killed = []string{}
+		}
+		cliDone(map[string]any{"killed": killed}, func() {
+			if len(killed) == 0 {
+				fmt.Println(InfoMsg("No orphaned sessions."))
+				return
+			}
+			for _, name := range killed {
+				fmt.Println(SuccessMsg("Killed " + name))
+			}
+		})
+	default:
+		cliFail(fmt.Errorf("unknown action for sessions: %s", action))
+	}
+}
+
+func runShutdown(cmd *cobra.Command, args []string) {
+	mgr := getManager()
+	detach, _ := cmd.Flags().GetBool("detach")
+	yes, _ := cmd.Flags().GetBool("yes")
+
+	plan, err := mgr.ShutdownPlan()
+	if err != nil {
+		cliFail(err)
+	}
+	if plan.Empty(detach) {
+		cliDone(ShutdownResult{StoppedAgents: []string{}, DetachedSessions: []string{}}, func() {
+			fmt.Println(InfoMsg("Nothing running."))
+		})
+		return
+	}
+	if !yes {
+		summary := "Stop " + plan.Summary(detach)
+		if jsonOutput() {
+			cliFail(fmt.Errorf("%s? (pass --yes to confirm)", summary))
+		}
+		if !confirmPrompt(summary + "?") {
+			cliFail(errors.New("aborted"))
+		}
+	}
+
+	res, err := mgr.Shutdown(plan, detach)
+	if err != nil {
+		for _, path := range res.StoppedAgents {
+			cliWarn("stopped agent in " + path)
+		}
+		for _, path := range res.DetachedSessions {
+			cliWarn("detached " + path)
+		}
+		cliFail(err)
+	}
+	cliDone(res, func() {
+		for _, path := range res.DetachedSessions {
+			fmt.Println(SuccessMsg("Detached " + StylePath.Render(path)))
+		}
+		fmt.Println(SuccessMsg(fmt.Sprintf("Stopped %d agent(s)", len(res.StoppedAgents))))
+	})
+}
+
+func runEvents(cmd *cobra.Command, args []string) {
+	follow, _ := cmd.Flags().GetBool("follow")
+	since, _ := cmd.Flags().GetString("since")
+	typeValues, _ := cmd.Flags().GetStringSlice("type")
+	all, _ := cmd.Flags().GetBool("all")
+
+	types, err := parseEventTypes(typeValues)
+	if err != nil {
+		cliFail(err)
+	}
+	filter := EventFilter{Types: types}
+	if since != "" {
+		if filter.Since, err = parseSince(since, time.Now()); err != nil {
+			cliFail(err)
+		}
+	}
+	if !all {
+		// Outside a repository there is nothing to narrow down to.
+		mgr := getManager()
+		if repoRoot, err := mgr.RequireRepo(); err == nil {
+			filter.RepoRoot = mgr.mainRepoRoot(repoRoot)
+		}
+	}
+
+	if !follow {
+		events, err := ReadEvents(filter)
+		if err != nil {
+			cliFail(err)
+		}
+		if events == nil {
+			events = []Event{}
+		}
+		cliDone(map[string]any{"events": events}, func() {
+			for _, ev := range events {
+				printEventLine(ev)
+			}
+		})
+		return
+	}
+
+	// Following streams one JSON object per line whatever the output format;
+	// there is no end to wrap a result envelope around.
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		<-stop
+		close(done)
+	}()
+	err = FollowEvents(filter, !filter.Since.IsZero(), done, func(ev Event) error {
+		printEventLine(ev)
+		return nil
+	})
+	if err != nil {
+		cliFail(err)
+	}
+}
+
+func printEventLine(ev Event) {
+	line, err := json.Marshal(ev)
+	if err != nil {
+		return
+	}
+	fmt.Println(string(line))
+}
+
+// parseSince reads a --since value: a duration back from now, which may
+// count days (7d) or weeks (2w), or a time.
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if n, err := strconv.Atoi(strings.TrimSuffix(value, "d")); err == nil && strings.HasSuffix(value, "d") && n >= 0 {
+		return now.AddDate(0, 0, -n), nil
+	}
+	if n, err := strconv.Atoi(strings.TrimSuffix(value, "w")); err == nil && strings.HasSuffix(value, "w") && n >= 0 {
+		return now.AddDate(0, 0, -7*n), nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %q (want a duration like 2h or 7d, or an RFC 3339 time)", value)
+}
+
+func runServe(cmd *cobra.Command, args []string) {
+	mgr := getManager()
+	addr, _ := cmd.Flags().GetString("listen")
+	token, _ := cmd.Flags().GetString("token")
+	if token == "" {
+		token = os.Getenv("SPROUT_SERVE_TOKEN")
+	}
+	srv, err := mgr.NewAPIServer(ServeOptions{Addr: addr, Token: token})
+	if err != nil {
+		cliFail(err)
+	}
+	cliDone(map[string]any{"url": srv.URL, "token_path": srv.TokenPath}, func() {
+		fmt.Println(SuccessMsg("Serving the sprout API at " + srv.URL))
+		if srv.TokenPath != "" {
+			fmt.Println(InfoMsg("Send \"Authorization: Bearer <token>\" with the token in " + StylePath.Render(srv.TokenPath)))
+		}
+		fmt.Println(InfoMsg("Press Ctrl-C to stop."))
+	})
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-stop
+		_ = srv.Close()
+	}()
+	if err := srv.Serve(); err != nil {
+		fmt.Fprintln(os.Stderr, ErrorMsg(err.Error()))
+		os.Exit(1)
+	}
+}
+
+func runStats(cmd *cobra.Command, args []string) {
+	mgr := getManager()
+	since, _ := cmd.Flags().GetString("since")
+	all, _ := cmd.Flags().GetBool("all")
+
+	opts := StatsOptions{All: all}
+	if since != "" {
+		var err error
+		if opts.Since, err = parseSince(since, time.Now()); err != nil {
+			cliFail(err)
+		}
+	}
+	stats, err := mgr.Stats(opts)
+	if err != nil {
+		cliFail(err)
+	}
+	cliDone(stats, func() {
+		scope := "all repositories"
+		if stats.Repo != "" {
+			scope = stats.Repo
+		}
+		period := "all time"
+		if stats.Since != nil {
+			period = "since " + stats.Since.Local().Format("2006-01-02")
+		}
+		fmt.Printf("%s %s\n\n", StyleBranch.Render(scope), StyleDim.Render("("+period+")"))
+
+		unknown := stats.Removed - stats.Merged - stats.Abandoned
+		removed := fmt.Sprintf("%d (%d merged, %d abandoned", stats.Removed, stats.Merged, stats.Abandoned)
+		if unknown > 0 {
+			removed += fmt.Sprintf(", %d unknown", unknown)
+		}
+		removed += ")"
+		lifetime := "-"
+		if stats.LifetimesMeasured > 0 {
+			lifetime = fmt.Sprintf("%s (over %d worktree(s))", formatHours(stats.AverageLifetimeHours), stats.LifetimesMeasured)
+		}
+		fmt.Printf("  Created           %d\n", stats.Created)
+		fmt.Printf("  Removed           %s\n", removed)
+		fmt.Printf("  Average lifetime  %s\n", lifetime)
+		fmt.Printf("  Agent time        %s over %d run(s)\n", formatHours(stats.AgentHours), stats.AgentRuns)
+
+		if len(stats.Weeks) == 0 {
+			fmt.Println()
+			fmt.Println(InfoMsg("No worktrees created or removed yet."))
+			return
+		}
+		t := table.New().
+			Border(lipgloss.NormalBorder()).
+			BorderStyle(lipgloss.NewStyle().Foreground(ColorGreen)).
+			Headers("WEEK", "CREATED", "REMOVED")
+		for _, w := range stats.Weeks {
+			t.Row(w.Start.Format("2006-01-02"), strconv.Itoa(w.Created), strconv.Itoa(w.Removed))
+		}
+		fmt.Println()
+		fmt.Println(t)
+	})
+}
+
+func runPerf(cmd *cobra.Command, args []string) {
+	limit, _ := cmd.Flags().GetInt("limit")
+	repoOnly, _ := cmd.Flags().GetBool("repo")
+	reset, _ := cmd.Flags().GetBool("reset")
+	if limit < 0 {
+		cliUsage("sprout perf [--limit <n>] [--repo] [--reset]")
+	}
+
+	if reset {
+		if err := resetPerf(); err != nil {
+			cliFail(err)
+		}
+		cliDone(map[string]bool{"reset": true}, func() {
+			fmt.Println(SuccessMsg("Forgot the recorded timings"))
+		})
+		return
+	}
+
+	opts := PerfReportOptions{Limit: limit}
+	if repoOnly {
+		mgr := getManager()
+		items, err := mgr.ListWorktrees(context.Background())
+		if err != nil {
+			cliFail(err)
+		}
+		for _, wt := range items {
+			opts.Dirs = append(opts.Dirs, wt.Path)
+		}
+	}
+	report, err := loadPerfReport(opts)
+	if err != nil {
+		cliFail(err)
+	}
+	cliDone(report, func() {
+		if len(report.Commands) == 0 && len(report.Operations) == 0 {
+			fmt.Println(InfoMsg("No timings recorded yet."))
+			return
+		}
+		statsTable := func(title string, stats []PerfStat) {
+			if len(stats) == 0 {
+				return
+			}
+			t := table.New().
+				Border(lipgloss.NormalBorder()).
+				BorderStyle(lipgloss.NewStyle().Foreground(ColorGreen)).
+				Headers(title, "RUNS", "TOTAL", "AVG", "P90", "MAX")
+			for _, st := range stats {
+				t.Row(st.Name, strconv.Itoa(st.Count), formatPerfMS(st.TotalMS), formatPerfMS(st.AvgMS), formatPerfMS(st.P90MS), formatPerfMS(st.MaxMS))
+			}
+			fmt.Println(t)
+		}
+		statsTable("COMMAND", report.Commands)
+		statsTable("OPERATION", report.Operations)
+
+		if len(report.Slowest) == 0 {
+			fmt.Println(StyleDim.Render(fmt.Sprintf("No command took %s or more in the last week.", perfSlowThreshold)))
+			return
+		}
+		t := table.New().
+			Border(lipgloss.NormalBorder()).
+			BorderStyle(lipgloss.NewStyle().Foreground(ColorGreen)).
+			Headers("SLOWEST", "WHEN", "DIR", "COMMAND")
+		for _, s := range report.Slowest {
+			command := s.Command
+			if s.Failed {
+				command += " " + StyleDim.Render("(failed)")
+			}
+			t.Row(formatPerfMS(s.DurationMS), s.Time.Local().Format("01-02 15:04"), s.Dir, command)
+		}
+		fmt.Println(t)
+	})
+}
+
+// formatPerfMS shows a duration in milliseconds as ms below a second and as
+// seconds above.
+func formatPerfMS(ms int64) string {
+	if ms < 1000 {
+		return fmt.Sprintf("%dms", ms)
+	}
+	return fmt.Sprintf("%.1fs", float64(ms)/1000)
+}
+
+func runStatus(cmd *cobra.Command, args []string) {
+	mgr := getManager()
+	report, err := mgr.Status(context.Background())
+	if err != nil {
+		cliFail(err)
+	}
+	cliDone(report, func() {
+		t := table.New().
+			Border(lipgloss.NormalBorder()).
+			BorderStyle(lipgloss.NewStyle().Foreground(ColorGreen)).
+			Headers("BRANCH", "AGENT", "TOKENS", "COST", "CONTEXT LEFT")
+		for _, w := range report.Worktrees {
+			t.Row(w.Branch, w.Agent, formatUsageTokens(w.Usage), formatUsageCost(w.Usage), formatContextLeft(w))
+		}
+		t.Row(StyleBranch.Render("total"), "", formatUsageTokens(report.Total), formatUsageCost(report.Total), "")
+		fmt.Println(StyleBranch.Render(report.Repo))
+		fmt.Println(t)
+		fmt.Println(StyleDim.Render("Usage is what the agents print (token counts, session cost); agents that print none count nothing."))
+	})
+}
+
+func formatUsageTokens(u AgentUsage) string {
+	if u.Tokens == 0 {
+		return "-"
+	}
+	return compactCount(int(u.Tokens))
+}
+
+func formatUsageCost(u AgentUsage) string {
+	if u.CostUSD == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("$%.2f", u.CostUSD)
+}
+
+func formatContextLeft(w WorktreeStatus) string {
+	if w.Usage.ContextLeft == 0 || w.Agent != "yes" {
+		return "-"
+	}
+	return fmt.Sprintf("%d%%", w.Usage.ContextLeft)
+}
+
+func runWatch(cmd *cobra.Command, args []string) {
+	mgr := getManager()
+	interval, _ := cmd.Flags().GetDuration("interval")
+	file, _ := cmd.Flags().GetString("file")
+	metrics, _ := cmd.Flags().GetString("prometheus")
+	once, _ := cmd.Flags().GetBool("once")
+	if interval < time.Second {
+		cliFail(fmt.Errorf("invalid --interval %s (want 1s or more)", interval))
+	}
+	if file == "" {
+		path, err := DefaultWatchStatusFile()
+		if err != nil {
+			cliFail(err)
+		}
+		file = path
+	}
+	opts := WatchOptions{Interval: interval, StatusFile: file, MetricsFile: metrics, Once: once}
+	if once {
+		if err := mgr.Watch(context.Background(), opts); err != nil {
+			cliFail(err)
+		}
+		cliDone(map[string]any{"file": file, "prometheus": metrics}, func() {
+			fmt.Println(SuccessMsg(fmt.Sprintf("Status written: %s", StylePath.Render(file))))
+		})
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if !jsonOutput() {
+		fmt.Fprintln(os.Stderr, InfoMsg(fmt.Sprintf("Writing status to %s every %s (ctrl+c stops)", file, interval)))
+	}
+	if err := mgr.Watch(ctx, opts); err != nil {
+		cliFail(err)
+	}
+	cliDone(map[string]any{"file": file, "prometheus": metrics}, func() {})
+}
+
+// runStatusline reads only the status file sprout watch writes: no config,
+// git, or tmux, so status bars can run it on every redraw.
+func runStatusline(cmd *cobra.Command, args []string) {
+	formatValue, _ := cmd.Flags().GetString("format")
+	file, _ := cmd.Flags().GetString("file")
+	dir, _ := cmd.Flags().GetString("dir")
+	format, err := parseStatuslineFormat(formatValue)
+	if err != nil {
+		cliFail(err)
+	}
+	if file == "" {
+		if file, err = DefaultWatchStatusFile(); err != nil {
+			cliFail(err)
+		}
+	}
+	line, err := Statusline(file, statuslineDir(dir), format, time.Now())
+	if err != nil {
+		cliFail(err)
+	}
+	cliDone(map[string]any{"line": line}, func() {
+		if line != "" {
 			fmt.Println(line)
 		}
+	})
+}
+
+func runExec(cmd *cobra.Command, args []string) {
+	const usage = "sprout exec <target|--all> [--parallel <n>] -- <command> [args...]"
+	mgr := getManager()
+	all, _ := cmd.Flags().GetBool("all")
+	parallel, _ := cmd.Flags().GetInt("parallel")
+	dash := cmd.ArgsLenAtDash()
+	if dash < 0 || dash == len(args) {
+		cliUsage(usage)
+	}
+	targets, command := args[:dash], args[dash:]
+	if all {
+		if len(targets) > 0 {
+			cliUsage(usage)
+		}
+	} else if targets, _ = targetArg(cmd, mgr, targets, 0, 1); len(targets) != 1 {
+		cliUsage(usage)
+	}
+	if parallel < 1 {
+		cliFail(fmt.Errorf("--parallel must be at least 1, got %d", parallel))
+	}
+
+	opts := ExecOptions{All: all, Command: command, Parallel: parallel}
+	if !all {
+		opts.Target = targets[0]
+	}
+	if !jsonOutput() {
+		opts.Output = os.Stdout
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+	results, err := mgr.Exec(ctx, opts)
+	if err != nil {
+		cliFail(err)
+	}
+	failed := 0
+	for _, res := range results {
+		if !res.OK() {
+			failed++
+		}
+	}
+	result := map[string]any{"command": strings.Join(command, " "), "results": results}
+	if failed > 0 && jsonOutput() {
+		writeCommandResult(result, cliOutput.Warnings, fmt.Errorf("failed in %d of %d worktree(s)", failed, len(results)))
+		os.Exit(1)
+	}
+	cliDone(result, func() {
+		if !all {
+			if res := results[0]; res.Error != "" {
+				fmt.Fprintln(os.Stderr, ErrorMsg(res.Error))
+			}
+			return
+		}
+		if len(results) == 0 {
+			fmt.Println(InfoMsg("No worktrees."))
+			return
+		}
+		fmt.Println()
+		for _, res := range results {
+			fmt.Println(execSummary(res))
+		}
+	})
+	// One worktree exits like the command did; several exit 1 if any failed.
+	if !all && results[0].ExitCode > 0 {
+		os.Exit(results[0].ExitCode)
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// execSummary is the line sprout exec and check print for one worktree.
+func execSummary(res ExecResult) string {
+	took := (time.Duration(res.DurationMS) * time.Millisecond).Round(100 * time.Millisecond)
+	switch {
+	case res.Error != "":
+		return ErrorMsg(fmt.Sprintf("%s: %s", StyleBranch.Render(res.Branch), res.Error))
+	case res.ExitCode != 0:
+		return ErrorMsg(fmt.Sprintf("%s: exit %d (%s)", StyleBranch.Render(res.Branch), res.ExitCode, took))
+	}
+	return SuccessMsg(fmt.Sprintf("%s (%s)", StyleBranch.Render(res.Branch), took))
+}
+
+func runCheck(cmd *cobra.Command, args []string) {
+	const usage = "sprout check <target|--all> [--parallel <n>]"
+	mgr := getManager()
+	all, _ := cmd.Flags().GetBool("all")
+	parallel, _ := cmd.Flags().GetInt("parallel")
+	if all {
+		if len(args) > 0 {
+			cliUsage(usage)
+		}
+	} else if args, _ = targetArg(cmd, mgr, args, 0, 1); len(args) != 1 {
+		cliUsage(usage)
+	}
+	if parallel < 1 {
+		cliFail(fmt.Errorf("--parallel must be at least 1, got %d", parallel))
+	}
+
+	opts := ExecOptions{All: all, Parallel: parallel}
+	if !all {
+		opts.Target = args[0]
+	}
+	if !jsonOutput() {
+		opts.Output = os.Stdout
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+	results, err := mgr.Check(ctx, opts)
+	if err != nil {
+		cliFail(err)
+	}
+	failed := 0
+	for _, res := range results {
+		if res.Status != checkPass {
+			failed++
+		}
+	}
+	result := map[string]any{"command": mgr.Cfg.CheckCommand, "results": results}
+	if failed > 0 && jsonOutput() {
+		writeCommandResult(result, cliOutput.Warnings, fmt.Errorf("check failed in %d of %d worktree(s)", failed, len(results)))
+		os.Exit(1)
+	}
+	cliDone(result, func() {
+		if len(results) == 0 {
+			fmt.Println(InfoMsg("No worktrees."))
+			return
+		}
+		fmt.Println()
+		for _, res := range results {
+			fmt.Println(execSummary(res.ExecResult))
+		}
+	})
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func runResume(cmd *cobra.Command, args []string) {
+	mgr := getManager()
+	results, err := mgr.ResumeAgents()
+	if err != nil {
+		cliFail(err)
+	}
+	cliDone(map[string]any{"agents": results}, func() {
+		if len(results) == 0 {
+			fmt.Println(InfoMsg("No agents to resume."))
+			return
+		}
+		for _, res := range results {
+			if res.Error != "" {
+				fmt.Println(ErrorMsg(fmt.Sprintf("%s: %s", res.Branch, res.Error)))
+				continue
+			}
+			fmt.Println(SuccessMsg("Resumed agent in " + StylePath.Render(res.Path)))
+		}
+	})
+}
+
+func runConfig(cmd *cobra.Command, args []string) {
+	action := args[0]
+	cliOutput.Command = "config " + action
+	if len(args) == 2 && action != "lint" {
+		cliUsage("sprout config <show|init|schema> or sprout config lint [target]")
+	}
+	switch action {
+	case "show":
+		asJSON, _ := cmd.Flags().GetBool("json")
+		asTOML, _ := cmd.Flags().GetBool("toml")
+		withOrigin, _ := cmd.Flags().GetBool("origin")
+		if asJSON && asTOML {
+			cliUsage("sprout config show [--json|--toml] [--origin]")
+		}
+		cfg, origins, err := LoadConfigOrigins()
+		if err != nil {
+			cliFail(fmt.Errorf("error loading config: %w", err))
+		}
+		if jsonOutput() {
+			cliDone(map[string]any{"config": configJSON(cfg, origins, withOrigin)}, nil)
+			return
+		}
+		if asJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(configJSON(cfg, origins, withOrigin)); err != nil {
+				cliFail(err)
+			}
+			return
+		}
+		text, err := renderConfigTOML(cfg, origins, withOrigin)
+		if err != nil {
+			cliFail(err)
+		}
+		fmt.Print(text)
+	case "init":
+		repo, _ := cmd.Flags().GetBool("repo")
+		force, _ := cmd.Flags().GetBool("force")
+		path := globalConfigPath()
+		if repo {
+			root, err := findGitRoot(".")
+			if err != nil {
+				cliFail(errors.New("run sprout config init --repo inside a git repository"))
+			}
+			path = filepath.Join(root, ".sprout.toml")
+		}
+		if path == "" {
+			cliFail(errors.New("cannot locate the global config file"))
+		}
+		if _, err := os.Stat(path); err == nil && !force {
+			cliFail(fmt.Errorf("%s already exists (pass --force to overwrite it)", path))
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			cliFail(err)
+		}
+		if err := os.WriteFile(path, []byte(defaultConfigFile()), 0o644); err != nil {
+			cliFail(err)
+		}
+		cliDone(map[string]any{"path": path}, func() {
+			fmt.Println(SuccessMsg("Wrote " + StylePath.Render(path)))
+		})
+	case "schema":
+		schema := configSchema()
+		if jsonOutput() {
+			cliDone(map[string]any{"schema": schema}, nil)
+			return
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(schema); err != nil {
+			cliFail(err)
+		}
+	case "lint":
+		mgr := getManager()
+		path, err := mgr.RequireRepo()
+		if len(args) == 2 {
+			var wt *Worktree
+			if wt, _, err = mgr.ResolveTarget(args[1]); err == nil {
+				path = wt.Path
+			}
+		}
+		if err != nil {
+			cliFail(err)
+		}
+		issues := mgr.LintWindows(path)
+		result := map[string]any{"worktree": path, "windows": len(mgr.Cfg.Windows), "issues": issues}
+		if len(issues) > 0 && jsonOutput() {
+			writeCommandResult(result, cliOutput.Warnings, fmt.Errorf("%d layout problem(s)", len(issues)))
+			os.Exit(1)
+		}
+		cliDone(result, func() {
+			if len(mgr.Cfg.Windows) == 0 {
+				fmt.Println(InfoMsg("No [[windows]] configured."))
+				return
+			}
+			for _, issue := range issues {
+				fmt.Println(WarnMsg(issue.String()))
+			}
+			if len(issues) == 0 {
+				fmt.Println(SuccessMsg(fmt.Sprintf("%d window(s) OK against %s", len(mgr.Cfg.Windows), StylePath.Render(path))))
+			}
+		})
+		if len(issues) > 0 {
+			os.Exit(1)
+		}
+	default:
+		cliFail(fmt.Errorf("unknown action for config: %s", action))
+	}
+}
+
+func runDoctor(cmd *cobra.Command, args []string) {
+	fix, _ := cmd.Flags().GetBool("fix")
+	cfg, err := LoadConfig()
+	if err != nil {
+		// Keep going with defaults; the config check names the broken file.
+		cfg = DefaultConfig()
+		applyEnvOverrides(&cfg)
+	}
+	ApplyColorSettings(cfg)
+	mgr := NewManager(cfg)
+	report := mgr.Doctor(DoctorOptions{Fix: fix})
+	if jsonOutput() {
+		type doctorCheck struct {
+			Check   string `json:"check"`
+			Status  string `json:"status"`
+			Message string `json:"message"`
+			Fix     string `json:"fix,omitempty"`
+			FixErr  string `json:"fix_error,omitempty"`
+		}
+		checks := []doctorCheck{}
+		for _, item := range report.Items {
+			checks = append(checks, doctorCheck{Check: item.Check, Status: item.Status, Message: item.Message, Fix: item.Fix, FixErr: item.FixErr})
+		}
+		missing := append([]string{}, report.MissingReqs...)
+		result := map[string]any{"checks": checks, "missing": missing}
+		if report.ExitCode != 0 {
+			writeCommandResult(result, cliOutput.Warnings, doctorFailure(report))
+		} else {
+			cliDone(result, nil)
+		}
+		os.Exit(report.ExitCode)
+	}
+	fixable := false
+	for i, item := range report.Items {
+		line := strings.TrimSpace(strings.TrimPrefix(report.Lines[i], item.Status))
+		switch {
+		case item.Fix == doctorFixed, item.Status == doctorOK:
+			fmt.Println(SuccessMsg(line))
+		case item.Status == doctorMiss || item.Status == doctorFail || item.Fix == doctorFixFailed:
+			fmt.Println(ErrorMsg(line))
+		default:
+			fmt.Println(WarnMsg(line))
+		}
+		if item.Fix == doctorFixAvailable {
+			fixable = true
+		}
+	}
+	if fixable {
+		fmt.Println(InfoMsg("Run sprout doctor --fix to repair the fixable problems."))
 	}
 	os.Exit(report.ExitCode)
 }
+
+func doctorFailure(report DoctorReport) error {
+	if len(report.MissingReqs) > 0 {
+		return fmt.Errorf("missing required tools: %s", strings.Join(report.MissingReqs, ", "))
+	}
+	return errors.New("doctor found problems")
+}
@@ -1,83 +1,231 @@
 package sprout
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/charmbracelet/lipgloss"
 	"github.com/gdamore/tcell/v2"
+	"github.com/muesli/termenv"
+)
+
+// Color modes accepted by the color setting.
+const (
+	colorAuto   = "auto"
+	colorAlways = "always"
+	colorNever  = "never"
+)
+
+// Themes accepted by the theme setting.
+const (
+	themeDark  = "dark"
+	themeLight = "light"
+)
+
+// Palette is the set of colors every CLI style and TUI color is built from.
+type Palette struct {
+	Lime    lipgloss.Color
+	Green   lipgloss.Color
+	Emerald lipgloss.Color
+	Cyan    lipgloss.Color
+	Blue    lipgloss.Color
+	Purple  lipgloss.Color
+	Red     lipgloss.Color
+	Yellow  lipgloss.Color
+	Gray    lipgloss.Color
+}
+
+var (
+	// Muted/Nord-inspired colors for dark terminals.
+	darkPalette = Palette{
+		Lime:    "#b4be82",
+		Green:   "#a3be8c",
+		Emerald: "#8fbcbb",
+		Cyan:    "#88c0d0",
+		Blue:    "#81a1c1",
+		Purple:  "#b48ead",
+		Red:     "#bf616a",
+		Yellow:  "#ebcb8b",
+		Gray:    "#4c566a",
+	}
+
+	// The same hues, darkened to stay readable on a white background.
+	lightPalette = Palette{
+		Lime:    "#5f6b1f",
+		Green:   "#3f7a2a",
+		Emerald: "#2b6f6d",
+		Cyan:    "#1c6b82",
+		Blue:    "#2d5a8e",
+		Purple:  "#7b4b8c",
+		Red:     "#b02a37",
+		Yellow:  "#8a6100",
+		Gray:    "#7b8494",
+	}
 )
 
 var (
-	// Colors (Muted/Nord-inspired)
-	ColorLime    = lipgloss.Color("#b4be82")
-	ColorGreen   = lipgloss.Color("#a3be8c")
-	ColorEmerald = lipgloss.Color("#8fbcbb")
-	ColorCyan    = lipgloss.Color("#88c0d0")
-	ColorBlue    = lipgloss.Color("#81a1c1")
-	ColorPurple  = lipgloss.Color("#b48ead")
-	ColorRed     = lipgloss.Color("#bf616a")
-	ColorGray    = lipgloss.Color("#4c566a")
+	// Colors of the active palette
+	ColorLime    lipgloss.Color
+	ColorGreen   lipgloss.Color
+	ColorEmerald lipgloss.Color
+	ColorCyan    lipgloss.Color
+	ColorBlue    lipgloss.Color
+	ColorPurple  lipgloss.Color
+	ColorRed     lipgloss.Color
+	ColorYellow  lipgloss.Color
+	ColorGray    lipgloss.Color
 
 	// Unified Theme Colors (Exported for TUI)
-	ThemeColorPrimary   = ColorGreen
-	ThemeColorSecondary = ColorCyan
-	ThemeColorAccent    = ColorLime
-	ThemeColorMuted     = ColorGray
+	ThemeColorPrimary   lipgloss.Color
+	ThemeColorSecondary lipgloss.Color
+	ThemeColorAccent    lipgloss.Color
+	ThemeColorMuted     lipgloss.Color
 
 	// Styles
+	StyleSuccess lipgloss.Style
+	StyleError   lipgloss.Style
+	StyleWarning lipgloss.Style
+	StyleInfo    lipgloss.Style
+	StyleBold    lipgloss.Style
+	StyleFaint   lipgloss.Style
+
+	// Table/List Styles
+	StyleHeader          lipgloss.Style
+	StyleTableHead       lipgloss.Style
+	StyleCurrentWorktree lipgloss.Style
+	StyleBranch          lipgloss.Style
+	StyleDirty           lipgloss.Style
+	StyleClean           lipgloss.Style
+	StyleDim             lipgloss.Style
+	StylePath            lipgloss.Style
+
+	// Box styles for larger announcements
+	StyleBox lipgloss.Style
+)
+
+var (
+	// colorEnabled is false when color = "never" or NO_COLOR is set; the
+	// TUI then draws everything in the terminal's default colors.
+	colorEnabled = true
+	activeTheme  = themeDark
+)
+
+func init() {
+	usePalette(darkPalette)
+}
+
+// usePalette points the color variables at p and rebuilds every style.
+func usePalette(p Palette) {
+	ColorLime = p.Lime
+	ColorGreen = p.Green
+	ColorEmerald = p.Emerald
+	ColorCyan = p.Cyan
+	ColorBlue = p.Blue
+	ColorPurple = p.Purple
+	ColorRed = p.Red
+	ColorYellow = p.Yellow
+	ColorGray = p.Gray
+
+	ThemeColorPrimary = ColorGreen
+	ThemeColorSecondary = ColorCyan
+	ThemeColorAccent = ColorLime
+	ThemeColorMuted = ColorGray
+
 	StyleSuccess = lipgloss.NewStyle().
-			Foreground(ColorGreen).
-			Bold(true)
+		Foreground(ColorGreen).
+		Bold(true)
 
 	StyleError = lipgloss.NewStyle().
-			Foreground(ColorRed).
-			Bold(true)
+		Foreground(ColorRed).
+		Bold(true)
 
 	StyleWarning = lipgloss.NewStyle().
-			Foreground(ColorPurple).
-			Bold(true)
+		Foreground(ColorPurple).
+		Bold(true)
 
 	StyleInfo = lipgloss.NewStyle().
-			Foreground(ColorCyan)
+		Foreground(ColorCyan)
 
 	StyleBold = lipgloss.NewStyle().Bold(true)
 
 	StyleFaint = lipgloss.NewStyle().Foreground(ColorGray)
 
-	// Table/List Styles
 	StyleHeader = lipgloss.NewStyle().
-			Foreground(ThemeColorPrimary).
-			Bold(true).
-			MarginBottom(1)
+		Foreground(ThemeColorPrimary).
+		Bold(true).
+		MarginBottom(1)
 
 	StyleTableHead = lipgloss.NewStyle().
-			Foreground(ThemeColorPrimary).
-			Bold(true).
-			Underline(true)
+		Foreground(ThemeColorPrimary).
+		Bold(true).
+		Underline(true)
 
 	StyleCurrentWorktree = lipgloss.NewStyle().
-				Foreground(ThemeColorAccent).
-				Bold(true)
+		Foreground(ThemeColorAccent).
+		Bold(true)
 
 	StyleBranch = lipgloss.NewStyle().
-			Foreground(ThemeColorSecondary)
+		Foreground(ThemeColorSecondary)
 
 	StyleDirty = lipgloss.NewStyle().
-			Foreground(ColorRed)
+		Foreground(ColorRed)
 
 	StyleClean = lipgloss.NewStyle().
-			Foreground(ColorGreen)
+		Foreground(ColorGreen)
 
 	StyleDim = lipgloss.NewStyle().
-			Foreground(ThemeColorMuted)
+		Foreground(ThemeColorMuted)
 
 	StylePath = lipgloss.NewStyle().
-			Foreground(ColorBlue)
+		Foreground(ColorBlue)
 
-	// Box styles for larger announcements
 	StyleBox = lipgloss.NewStyle().
-			Border(lipgloss.RoundedBorder()).
-			BorderForeground(ThemeColorPrimary).
-			Padding(0, 1)
-)
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(ThemeColorPrimary).
+		Padding(0, 1)
+}
+
+func parseColorMode(value string) (string, error) {
+	switch v := strings.ToLower(strings.TrimSpace(value)); v {
+	case "", colorAuto:
+		return colorAuto, nil
+	case colorAlways, colorNever:
+		return v, nil
+	}
+	return "", fmt.Errorf("invalid color %q (want auto, always, or never)", value)
+}
+
+func parseTheme(value string) (string, error) {
+	switch v := strings.ToLower(strings.TrimSpace(value)); v {
+	case "", themeDark:
+		return themeDark, nil
+	case themeLight:
+		return v, nil
+	}
+	return "", fmt.Errorf("invalid theme %q (want dark or light)", value)
+}
+
+// ApplyColorSettings selects the palette for cfg.Theme and the color mode
+// for cfg.Color. Every style and TUI color resolves through the result, so
+// it must run before anything is rendered. In auto mode lipgloss drops
+// colors by itself when stdout is not a terminal.
+func ApplyColorSettings(cfg Config) {
+	activeTheme = themeDark
+	palette := darkPalette
+	if cfg.Theme == themeLight {
+		activeTheme = themeLight
+		palette = lightPalette
+	}
+	usePalette(palette)
+
+	colorEnabled = cfg.Color != colorNever
+	switch cfg.Color {
+	case colorNever:
+		lipgloss.SetColorProfile(termenv.Ascii)
+	case colorAlways:
+		lipgloss.SetColorProfile(termenv.TrueColor)
+	}
+}
 
 func SuccessMsg(msg string) string {
 	return StyleSuccess.Render("✓ ") + msg
@@ -95,8 +243,21 @@ func InfoMsg(msg string) string {
 	return StyleInfo.Render("• ") + msg
 }
 
-// ColorToTcell converts a lipgloss Color to a tcell Color
+// ColorToTcell converts a lipgloss Color to a tcell Color, or to the
+// terminal default when colors are disabled.
 func ColorToTcell(c lipgloss.Color) tcell.Color {
+	if !colorEnabled {
+		return tcell.ColorDefault
+	}
 	// Simple conversion for basic hex colors
 	return tcell.GetColor(string(c))
 }
+
+// colorTag returns a tview color tag for c, such as "[#88c0d0]", or a
+// reset tag when colors are disabled.
+func colorTag(c lipgloss.Color) string {
+	if !colorEnabled {
+		return "[-]"
+	}
+	return "[" + string(c) + "]"
+}
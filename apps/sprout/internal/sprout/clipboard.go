@@ -0,0 +1,91 @@
+package sprout
+
+import (
+	"encoding/base64"
+	"errors"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// How copyToClipboard delivered the text.
+const (
+	clipboardNative = "clipboard"
+	clipboardOSC52  = "OSC 52"
+)
+
+// copyToClipboard puts text on the system clipboard with the platform's
+// clipboard tool. Over SSH, or when no tool is installed, it asks the
+// terminal to set its clipboard with an OSC 52 escape sequence instead, which
+// reaches the local machine through SSH and tmux. It returns which of the two
+// it used.
+func copyToClipboard(text string) (string, error) {
+	if !inSSHSession() {
+		if argv := clipboardCommand(); len(argv) > 0 {
+			cmd := exec.Command(argv[0], argv[1:]...)
+			cmd.Stdin = strings.NewReader(text)
+			err := cmd.Run()
+			if err == nil {
+				return clipboardNative, nil
+			}
+			debugLogf("clipboard %s failed, falling back to OSC 52: %v", argv[0], err)
+		}
+	}
+	if err := writeOSC52(text); err != nil {
+		return "", err
+	}
+	return clipboardOSC52, nil
+}
+
+func inSSHSession() bool {
+	return os.Getenv("SSH_TTY") != "" || os.Getenv("SSH_CONNECTION") != ""
+}
+
+// clipboardCommand is the command that copies its stdin to the clipboard, or
+// nil when none is available.
+func clipboardCommand() []string {
+	candidates := [][]string{}
+	switch runtime.GOOS {
+	case "darwin":
+		candidates = append(candidates, []string{"pbcopy"})
+	case "windows":
+		candidates = append(candidates, []string{"clip"})
+	default:
+		if os.Getenv("WAYLAND_DISPLAY") != "" {
+			candidates = append(candidates, []string{"wl-copy"})
+		}
+		if os.Getenv("DISPLAY") != "" {
+			candidates = append(candidates, []string{"xclip", "-selection", "clipboard"}, []string{"xsel", "--clipboard", "--input"})
+		}
+		// WSL reaches the Windows clipboard through clip.exe.
+		candidates = append(candidates, []string{"clip.exe"})
+	}
+	for _, argv := range candidates {
+		if _, err := exec.LookPath(argv[0]); err == nil {
+			return argv
+		}
+	}
+	return nil
+}
+
+// osc52Sequence is the escape sequence that sets the terminal clipboard to
+// text. Inside tmux it is wrapped in a passthrough sequence so it reaches
+// the outer terminal.
+func osc52Sequence(text string, tmux bool) string {
+	seq := "\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte(text)) + "\a"
+	if tmux {
+		return "\x1bPtmux;" + strings.ReplaceAll(seq, "\x1b", "\x1b\x1b") + "\x1b\\"
+	}
+	return seq
+}
+
+func writeOSC52(text string) error {
+	tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+	if err != nil {
+		return errors.New("no clipboard tool found and no terminal to send OSC 52 to")
+	}
+	defer tty.Close()
+	_, err = tty.WriteString(osc52Sequence(text, os.Getenv("TMUX") != ""))
+	return err
+}
@@ -0,0 +1,242 @@
+package sprout
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Worktree table columns, chosen and ordered with the columns setting for
+// both sprout list and the TUI.
+const (
+	columnCur       = "cur"
+	columnBranch    = "branch"
+	columnPriority  = "priority"
+	columnLabels    = "labels"
+	columnStatus    = "status"
+	columnTmux      = "tmux"
+	columnAgent     = "agent"
+	columnTodo      = "todo"
+	columnLock      = "lock"
+	columnResources = "resources"
+	columnAhead     = "ahead"
+	columnMerge     = "merge"
+	columnCheck     = "check"
+	columnCost      = "cost"
+	columnActive    = "active"
+	columnPath      = "path"
+)
+
+var worktreeColumnHeaders = map[string]string{
+	columnCur:       "CUR",
+	columnBranch:    "BRANCH",
+	columnPriority:  "PRI",
+	columnLabels:    "LABELS",
+	columnStatus:    "STATUS",
+	columnTmux:      "TMUX",
+	columnAgent:     "AGENT",
+	columnTodo:      "TODO",
+	columnLock:      "LOCK",
+	columnResources: "CPU/MEM",
+	columnAhead:     "AHEAD",
+	columnMerge:     "MERGE",
+	columnCheck:     "CHECK",
+	columnCost:      "COST",
+	columnActive:    "ACTIVE",
+	columnPath:      "PATH",
+}
+
+// Column width limits. Branch names are cut at branchColumnWidth and label
+// lists at labelsColumnWidth; the path gets whatever the other columns leave,
+// but never less than minPathColumnWidth.
+const (
+	branchColumnWidth  = 35
+	labelsColumnWidth  = 24
+	maxPathColumnWidth = 120
+	minPathColumnWidth = 24
+)
+
+func defaultListColumns() []string {
+	return []string{columnCur, columnBranch, columnPriority, columnLabels, columnStatus, columnTmux, columnAgent, columnLock, columnPath}
+}
+
+func defaultTableColumns() []string {
+	return []string{columnCur, columnBranch, columnLabels, columnStatus, columnTmux, columnAgent, columnTodo, columnMerge, columnCheck, columnLock, columnResources, columnPath}
+}
+
+func parseColumns(values []string) ([]string, error) {
+	out := make([]string, 0, len(values))
+	seen := map[string]bool{}
+	for _, raw := range values {
+		name := strings.ToLower(strings.TrimSpace(raw))
+		if name == "" {
+			continue
+		}
+		if _, ok := worktreeColumnHeaders[name]; !ok {
+			return nil, fmt.Errorf("invalid column %q (expected: %s)", raw, strings.Join(worktreeColumnNames(), "|"))
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate column %q", raw)
+		}
+		seen[name] = true
+		out = append(out, name)
+	}
+	return out, nil
+}
+
+func worktreeColumnNames() []string {
+	return []string{columnCur, columnBranch, columnPriority, columnLabels, columnStatus, columnTmux, columnAgent, columnTodo, columnLock, columnResources, columnAhead, columnMerge, columnCheck, columnCost, columnActive, columnPath}
+}
+
+// listColumns is the columns of sprout list. TODO counts, merge conflicts,
+// and resource usage are only sampled by the TUI, so those columns are left
+// out.
+func listColumns(cfg Config) []string {
+	if len(cfg.Columns) == 0 {
+		return defaultListColumns()
+	}
+	out := make([]string, 0, len(cfg.Columns))
+	for _, c := range cfg.Columns {
+		if c != columnTodo && c != columnMerge && c != columnResources {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// tableColumns is the columns of the TUI worktree table. The resources
+// column follows the R toggle: it is dropped while hidden and, when shown
+// but not configured, placed before the path.
+func tableColumns(cfg Config, showResources bool) []string {
+	columns := cfg.Columns
+	if len(columns) == 0 {
+		columns = defaultTableColumns()
+	}
+	out := make([]string, 0, len(columns)+1)
+	hasResources := false
+	for _, c := range columns {
+		if c == columnResources {
+			hasResources = true
+			if !showResources {
+				continue
+			}
+		}
+		out = append(out, c)
+	}
+	if showResources && !hasResources {
+		at := len(out)
+		for i, c := range out {
+			if c == columnPath {
+				at = i
+			}
+		}
+		out = append(out[:at], append([]string{columnResources}, out[at:]...)...)
+	}
+	return out
+}
+
+func columnIndex(columns []string, name string) int {
+	for i, c := range columns {
+		if c == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// pathColumnWidth is how much of total is left for the path column once the
+// other columns, widths[i] wide, and gap cells between columns are laid out.
+// total <= 0 means the width is unknown.
+func pathColumnWidth(total int, widths []int, gap int) int {
+	if total <= 0 {
+		return maxPathColumnWidth
+	}
+	rest := total
+	for _, w := range widths {
+		rest -= w + gap
+	}
+	if rest < minPathColumnWidth {
+		return minPathColumnWidth
+	}
+	if rest > maxPathColumnWidth {
+		return maxPathColumnWidth
+	}
+	return rest
+}
+
+// Path display modes for worktree paths in tables. JSON output always has
+// absolute paths.
+const (
+	pathDisplayAbsolute = "absolute"
+	// pathDisplayHome abbreviates the home directory to ~.
+	pathDisplayHome = "home"
+	// pathDisplayRelative shows paths under the worktree root relative to
+	// it, and others like pathDisplayHome.
+	pathDisplayRelative = "relative"
+)
+
+func parsePathDisplay(value string) (string, error) {
+	switch v := strings.ToLower(strings.TrimSpace(value)); v {
+	case "", pathDisplayAbsolute:
+		return pathDisplayAbsolute, nil
+	case pathDisplayHome, pathDisplayRelative:
+		return v, nil
+	}
+	return "", fmt.Errorf("invalid path_display %q (want absolute, home, or relative)", value)
+}
+
+// displayPath renders an absolute worktree path as path_display asks.
+func (m *Manager) displayPath(repoRoot, path string) string {
+	switch m.Cfg.PathDisplay {
+	case pathDisplayRelative:
+		root := m.WorktreeRootDir(repoRoot)
+		if rel, err := filepath.Rel(root, path); err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return rel
+		}
+		return abbreviateHome(path)
+	case pathDisplayHome:
+		return abbreviateHome(path)
+	}
+	return path
+}
+
+func abbreviateHome(path string) string {
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" || home == string(filepath.Separator) {
+		return path
+	}
+	home = filepath.Clean(home)
+	if path == home {
+		return "~"
+	}
+	if strings.HasPrefix(path, home+string(filepath.Separator)) {
+		return "~" + path[len(home):]
+	}
+	return path
+}
+
+// formatLabels renders the labels column.
+func formatLabels(item Worktree) string {
+	return truncate(strings.Join(item.Labels, ","), labelsColumnWidth)
+}
+
+// formatAheadBehind renders the ahead column: commits on the branch missing
+// from base_branch, then commits on base_branch missing from the branch.
+func formatAheadBehind(item Worktree) string {
+	if item.Branch == "" || item.Ahead < 0 {
+		return ""
+	}
+	if item.Ahead == 0 && item.Behind == 0 {
+		return "0"
+	}
+	parts := []string{}
+	if item.Ahead > 0 {
+		parts = append(parts, "↑"+strconv.Itoa(item.Ahead))
+	}
+	if item.Behind > 0 {
+		parts = append(parts, "↓"+strconv.Itoa(item.Behind))
+	}
+	return strings.Join(parts, " ")
+}
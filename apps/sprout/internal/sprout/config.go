@@ -2,7 +2,9 @@ package sprout
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -26,32 +28,85 @@ type PaneLayout struct {
 
 // WindowConfig defines a named tmux window with panes for the structured config.
 type WindowConfig struct {
-	Name   string       `toml:"name"`
-	Layout string       `toml:"layout"` // tmux layout: even-horizontal, even-vertical, tiled, main-horizontal, main-vertical
-	Panes  []PaneConfig `toml:"panes"`
+	Name   string            `toml:"name"`
+	Layout string            `toml:"layout"` // tmux layout: even-horizontal, even-vertical, tiled, main-horizontal, main-vertical
+	Dir    string            `toml:"dir"`    // default working dir for panes that set none; same forms as PaneConfig.Dir
+	Env    map[string]string `toml:"env"`    // environment of every pane; see paneEnv for the placeholders
+	Panes  []PaneConfig      `toml:"panes"`
 }
 
 // PaneConfig defines a single tmux pane within a window.
 type PaneConfig struct {
-	Dir string `toml:"dir"` // working dir: abs path, ~/..., {worktree}/..., relative-to-worktree, or empty for worktree root
-	Run string `toml:"run"` // command to execute
+	Dir   string            `toml:"dir"`   // working dir: abs path, ~/..., {worktree}/..., relative-to-worktree, or empty for the window dir
+	Run   string            `toml:"run"`   // command to execute
+	Size  string            `toml:"size"`  // width or height along the window's split: "30%" or a number of cells; empty splits evenly
+	Focus bool              `toml:"focus"` // select this pane once the window is built
+	Env   map[string]string `toml:"env"`   // environment on top of the window's
 }
 
 type Config struct {
 	BaseBranch           string
 	WorktreeRootTemplate string
+	WorktreePathTemplate string // each worktree's directory under the root: {branch}, {type}, {slug}, {date}
+	FlattenBranchDirs    bool   // one directory level per worktree: feat/x/y lives in feat-x-y
+	WorktreeSubdir       string // dir inside each worktree that sessions and agents start in
 	AutoLaunch           bool
 	AutoStartAgent       bool
 	CopyUntrackedExclude []string
 	UpdateCheck          bool
+	UpdateChannel        string // stable or prerelease releases for the update check
 	SessionTools         []string
 	LaunchNvim           bool
 	LaunchLazygit        bool
 	AgentCommand         string
+	AgentArgs            string // extra arguments appended to the agent command
 	DefaultAgentType     string
 	AgentCommands        map[string]string
+	AgentResumePrompt    string
 	SessionPrefix        string
+	SessionMode          string // per-worktree or per-repo tmux sessions
+	SlugMode             string
+	BranchTypes          []string // types accepted by sprout new <type> <name>; empty accepts any
+	BranchTemplate       string   // how sprout new <type> <name> builds the branch
+	BranchPattern        string   // regex every new branch must match; empty allows any
+	GitBackend           string
+	DirtyUntracked       string // how often untracked files are looked for in the STATUS column
 	EmitCDMarker         bool
+	LogLevel             string
+	WIPLimit             int
+	OnQuit               string
+	LaunchBackend        string // tmux, wt (Windows Terminal), or none
+	Color                string
+	Theme                string
+	ShowResources        bool
+	RepoSidebar          bool     // show the repository sidebar in the TUI
+	Columns              []string // worktree table columns, in order; empty uses the built-in layout
+	PathDisplay          string   // absolute, home (~), or relative to the worktree root
+	Sort                 string   // worktree order: path, active (recent first), or idle (stale first)
+	DetailsPercent       int
+	DetailPollMS         int      // how often the details pane polls while its agent is busy
+	DetailIdlePollMS     int      // the slowest it backs off to while nothing changes
+	DetailCaptureLines   int      // most agent pane lines the details pane captures
+	DiffFilesCacheMS     int      // how long the GIT DIFF tab's file list is reused
+	DiffPatchCacheMS     int      // how long a file's patch is reused
+	NotifyDesktop        []string // agent events shown as desktop notifications
+	NotifyBell           []string // agent events that ring the terminal bell
+	NotifyWebhook        string
+	NotifyWebhookEvents  []string // agent events posted to NotifyWebhook
+	AgentIdleMinutes     int      // minutes an agent may wait for input before the idle warning; 0 disables it
+	AutoFetchMinutes     int      // minutes between background fetches of the repository in the TUI; 0 disables it
+	AgentExitKeys        []string // tmux keys asking an agent to exit before its window is killed; empty kills it right away
+	AgentExitWait        int      // seconds to wait for an agent to exit after AgentExitKeys
+	UndoMinutes          int      // minutes sprout undo can bring a removed worktree back; 0 disables it
+	ExportDir            string   // where the TUI export action writes; ~, absolute, or relative to the repo root
+	SSHHost              string   // host the repository lives on; git and tmux run there over ssh
+	SSHRepo              string   // path of the repository on SSHHost
+	ContainerCommand     string   // prefix running session commands in the worktree's container
+	ContainerUp          string   // starts the worktree's container before its session
+	ContainerDown        string   // stops the worktree's container after its session
+	CheckCommand         string   // test or lint command sprout check runs in a worktree
+	Hooks                map[string]string
+	FilterPresets        map[string]string
 	SessionLayouts       map[string]SessionLayout
 	Windows              []WindowConfig // ordered window/pane definitions from [[windows]]
 }
@@ -60,10 +115,12 @@ func DefaultConfig() Config {
 	return Config{
 		BaseBranch:           "main",
 		WorktreeRootTemplate: "../{repo}.worktrees",
+		WorktreePathTemplate: defaultWorktreePathTemplate,
 		AutoLaunch:           true,
 		AutoStartAgent:       true,
 		CopyUntrackedExclude: []string{},
 		UpdateCheck:          true,
+		UpdateChannel:        updateChannelStable,
 		SessionTools:         defaultSessionTools(),
 		LaunchNvim:           true,
 		LaunchLazygit:        true,
@@ -75,11 +132,52 @@ func DefaultConfig() Config {
 			"claude": "claude",
 			"gemini": "gemini",
 		},
-		SessionPrefix: "sprout",
+		SessionPrefix:       "sprout",
+		SessionMode:         sessionModePerWorktree,
+		SlugMode:            slugModeASCII,
+		PathDisplay:         pathDisplayAbsolute,
+		Sort:                sortPath,
+		BranchTypes:         defaultBranchTypes(),
+		BranchTemplate:      defaultBranchTemplate,
+		GitBackend:          gitBackendExec,
+		DirtyUntracked:      dirtyUntrackedPeriodic,
+		LogLevel:            "info",
+		OnQuit:              quitActionNone,
+		LaunchBackend:       launchBackendTmux,
+		Color:               colorAuto,
+		Theme:               themeDark,
+		DetailsPercent:      defaultDetailsPercent,
+		DetailPollMS:        defaultDetailPollMS,
+		DetailIdlePollMS:    defaultDetailIdlePollMS,
+		DetailCaptureLines:  defaultDetailCaptureLines,
+		DiffFilesCacheMS:    defaultDiffFilesCacheMS,
+		DiffPatchCacheMS:    defaultDiffPatchCacheMS,
+		NotifyDesktop:       []string{},
+		NotifyBell:          []string{},
+		NotifyWebhookEvents: []string{notifyEventReady, notifyEventExited},
+		AgentIdleMinutes:    defaultAgentIdleMinutes,
+		AgentExitKeys:       defaultAgentExitKeys(),
+		AgentExitWait:       defaultAgentExitWait,
+		UndoMinutes:         defaultUndoMinutes,
+		ExportDir:           defaultExportDir,
+		FilterPresets:       defaultFilterPresets(),
 	}
 }
 
 func LoadConfig() (Config, error) {
+	return loadConfig(nil)
+}
+
+// LoadConfigOrigins loads the config like LoadConfig and reports where each
+// setting that is not at its default came from: the config file's path or
+// the environment variable's name, by config key.
+func LoadConfigOrigins() (Config, map[string]string, error) {
+	origins := map[string]string{}
+	cfg, err := loadConfig(origins)
+	return cfg, origins, err
+}
+
+func loadConfig(origins map[string]string) (Config, error) {
 	cfg := DefaultConfig()
 
 	// Resolve repo name once for structured config scoping.
@@ -89,32 +187,46 @@ func LoadConfig() (Config, error) {
 	}
 
 	// 1. Global config
-	globalPath := os.Getenv("SPROUT_CONFIG")
-	if globalPath == "" {
-		home, err := os.UserHomeDir()
-		if err == nil {
-			globalPath = filepath.Join(home, ".config", "sprout", "config.toml")
-		}
-	}
-	if globalPath != "" {
+	if globalPath := globalConfigPath(); globalPath != "" {
 		if _, err := os.Stat(globalPath); err == nil {
 			if err := parseTOMLFlat(globalPath, &cfg); err != nil {
 				return cfg, err
 			}
+			// A [repos.<name>] section overrides the global keys for
+			// that repository, below .sprout.toml and the environment.
+			if repoName != "" {
+				if err := parseTOMLFlatTable(globalPath, &cfg, repoConfigTable(repoName)); err != nil {
+					return cfg, err
+				}
+			}
 			if err := parseTOMLStructured(globalPath, &cfg, repoName, false); err != nil {
 				return cfg, err
 			}
+			if origins != nil {
+				if err := markConfigFileOrigins(origins, globalPath, repoName, false); err != nil {
+					return cfg, err
+				}
+			}
 		}
 	}
 
-	// 2. Repo-level config (.sprout.toml at git root), overrides global
+	// 2. Repo-level config (.sprout.toml at git root), overrides global.
+	// Outside a repository, a workspace .sprout.toml in the current
+	// directory can point sprout at a repository on an SSH host.
+	configDir := "."
 	if repoRoot, err := findGitRoot("."); err == nil {
-		repoConfigPath := filepath.Join(repoRoot, ".sprout.toml")
-		if _, err := os.Stat(repoConfigPath); err == nil {
-			if err := parseTOMLFlat(repoConfigPath, &cfg); err != nil {
-				return cfg, err
-			}
-			if err := parseTOMLStructured(repoConfigPath, &cfg, "", true); err != nil {
+		configDir = repoRoot
+	}
+	repoConfigPath := filepath.Join(configDir, ".sprout.toml")
+	if _, err := os.Stat(repoConfigPath); err == nil {
+		if err := parseTOMLFlat(repoConfigPath, &cfg); err != nil {
+			return cfg, err
+		}
+		if err := parseTOMLStructured(repoConfigPath, &cfg, "", true); err != nil {
+			return cfg, err
+		}
+		if origins != nil {
+			if err := markConfigFileOrigins(origins, repoConfigPath, "", true); err != nil {
 				return cfg, err
 			}
 		}
@@ -122,13 +234,151 @@ func LoadConfig() (Config, error) {
 
 	// 3. Env var overrides (highest priority)
 	applyEnvOverrides(&cfg)
+	if origins != nil {
+		markConfigEnvOrigins(origins, cfg)
+	}
 	if os.Getenv("SPROUT_EMIT_CD_MARKER") == "1" {
 		cfg.EmitCDMarker = true
 	}
 	return cfg, nil
 }
 
+// globalConfigPath returns SPROUT_CONFIG or ~/.config/sprout/config.toml.
+func globalConfigPath() string {
+	if path := os.Getenv("SPROUT_CONFIG"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "sprout", "config.toml")
+}
+
 // findGitRoot walks up from dir until it finds a directory containing .git.
+// Bounds of the details_percent setting.
+const (
+	defaultDetailsPercent = 60
+	minDetailsPercent     = 10
+	maxDetailsPercent     = 90
+)
+
+func parseDetailsPercent(value string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || n < minDetailsPercent || n > maxDetailsPercent {
+		return 0, fmt.Errorf("invalid details_percent %q (want %d-%d)", value, minDetailsPercent, maxDetailsPercent)
+	}
+	return n, nil
+}
+
+// Defaults of the details pane's polling and caching settings.
+const (
+	defaultDetailPollMS       = 150
+	defaultDetailIdlePollMS   = 2000
+	defaultDetailCaptureLines = 60
+	defaultDiffFilesCacheMS   = 900
+	defaultDiffPatchCacheMS   = 2000
+)
+
+// detailSettingBounds are the {min, max} of the details pane settings.
+var detailSettingBounds = map[string][2]int{
+	"detail_poll_ms":       {20, 5000},
+	"detail_idle_poll_ms":  {20, 60000},
+	"detail_capture_lines": {20, 2000},
+	"diff_files_cache_ms":  {0, 60000},
+	"diff_patch_cache_ms":  {0, 60000},
+}
+
+// parseDetailSetting parses one of the details pane settings within its
+// bounds.
+func parseDetailSetting(key, value string) (int, error) {
+	bounds := detailSettingBounds[key]
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || n < bounds[0] || n > bounds[1] {
+		return 0, fmt.Errorf("invalid %s %q (want %d-%d)", key, value, bounds[0], bounds[1])
+	}
+	return n, nil
+}
+
+// detailSettingFields maps the details pane settings to their fields.
+func detailSettingFields(cfg *Config) map[string]*int {
+	return map[string]*int{
+		"detail_poll_ms":       &cfg.DetailPollMS,
+		"detail_idle_poll_ms":  &cfg.DetailIdlePollMS,
+		"detail_capture_lines": &cfg.DetailCaptureLines,
+		"diff_files_cache_ms":  &cfg.DiffFilesCacheMS,
+		"diff_patch_cache_ms":  &cfg.DiffPatchCacheMS,
+	}
+}
+
+// defaultAgentIdleMinutes is how long an agent may wait for input before the
+// TUI warns about it.
+const defaultAgentIdleMinutes = 15
+
+// defaultAgentExitWait is how many seconds an agent gets to exit after the
+// exit keys before its window is killed.
+const defaultAgentExitWait = 5
+
+// defaultAgentExitKeys quits codex, Claude Code, gemini, and aider, which all
+// exit on a second Ctrl-C.
+func defaultAgentExitKeys() []string {
+	return []string{"C-c", "C-c"}
+}
+
+// saveGlobalConfigValue sets a top-level key in the global config file,
+// keeping the rest of the file as it is. An existing assignment before the
+// first table is replaced in place; otherwise the key is inserted there.
+func saveGlobalConfigValue(key, value string) error {
+	path := globalConfigPath()
+	if path == "" {
+		return errors.New("cannot locate the global config file")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	updated := setTopLevelConfigValue(string(data), key, value)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(updated), 0o644)
+}
+
+func setTopLevelConfigValue(content, key, value string) string {
+	assignment := key + " = " + value
+	var lines []string
+	if content != "" {
+		lines = strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	}
+	tableStart := len(lines)
+	replaced := false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") {
+			tableStart = i
+			break
+		}
+		if k, _, ok := strings.Cut(stripComment(trimmed), "="); ok && strings.TrimSpace(k) == key {
+			lines[i] = assignment
+			replaced = true
+		}
+	}
+	if !replaced {
+		// Append to the top-level keys, ahead of any blank lines that
+		// separate them from the first table.
+		at := tableStart
+		for at > 0 && strings.TrimSpace(lines[at-1]) == "" {
+			at--
+		}
+		insert := []string{assignment}
+		if at == tableStart && tableStart < len(lines) {
+			insert = append(insert, "")
+		}
+		lines = append(lines[:at], append(insert, lines[at:]...)...)
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
 func findGitRoot(dir string) (string, error) {
 	abs, err := filepath.Abs(dir)
 	if err != nil {
@@ -147,14 +397,54 @@ func findGitRoot(dir string) (string, error) {
 }
 
 func parseTOMLFlat(path string, cfg *Config) error {
+	return parseTOMLFlatTable(path, cfg, "")
+}
+
+// repoConfigTable is the table of the global config that overrides settings
+// for one repository, by its directory name.
+func repoConfigTable(repoName string) string {
+	return "repos." + repoName
+}
+
+// nestedConfigTable is the table name under table: [name] at the top level,
+// or [repos.<repo>.name] for a repository's section of the global config.
+func nestedConfigTable(table, name string) string {
+	if table == "" {
+		return name
+	}
+	return table + "." + name
+}
+
+// tomlTableName returns the name of the table a header line opens, with
+// quotes and spaces around its keys dropped. Array tables keep their
+// brackets so they never match a plain table.
+func tomlTableName(header string) string {
+	header = stripComment(header)
+	if strings.HasPrefix(header, "[[") {
+		return header
+	}
+	name := strings.TrimSuffix(strings.TrimPrefix(header, "["), "]")
+	return strings.NewReplacer("\"", "", " ", "", "\t", "").Replace(name)
+}
+
+// parseTOMLFlatTable reads the flat keys of one table of a config file: ""
+// for the top-level keys, or repoConfigTable for a repository's section of
+// the global config.
+func parseTOMLFlatTable(path string, cfg *Config, table string) error {
 	f, err := os.Open(path)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
+	return parseTOMLFlatReader(path, f, cfg, table)
+}
 
-	s := bufio.NewScanner(f)
+// parseTOMLFlatReader is parseTOMLFlatTable for a config file already read
+// or opened; path names it in errors.
+func parseTOMLFlatReader(path string, r io.Reader, cfg *Config, table string) error {
+	s := bufio.NewScanner(r)
 	lineNum := 0
+	current := ""
 	for s.Scan() {
 		lineNum++
 		line := strings.TrimSpace(s.Text())
@@ -162,6 +452,22 @@ func parseTOMLFlat(path string, cfg *Config) error {
 			continue
 		}
 		if strings.HasPrefix(line, "[") {
+			current = tomlTableName(line)
+			continue
+		}
+		if current == hooksTable(table) {
+			if err := parseHookLine(cfg, stripComment(line)); err != nil {
+				return fmt.Errorf("%s:%d %w", path, lineNum, err)
+			}
+			continue
+		}
+		if current == filtersTable(table) {
+			if err := parseFilterPresetLine(cfg, stripComment(line)); err != nil {
+				return fmt.Errorf("%s:%d %w", path, lineNum, err)
+			}
+			continue
+		}
+		if current != table {
 			continue
 		}
 		line = stripComment(line)
@@ -185,12 +491,70 @@ func parseTOMLFlat(path string, cfg *Config) error {
 				return fmt.Errorf("%s:%d invalid worktree_root_template: %w", path, lineNum, err)
 			}
 			cfg.WorktreeRootTemplate = v
+		case "worktree_subdir":
+			v, err := parseString(value)
+			if err != nil {
+				return fmt.Errorf("%s:%d invalid worktree_subdir: %w", path, lineNum, err)
+			}
+			sub, err := parseWorktreeSubdir(v)
+			if err != nil {
+				return fmt.Errorf("%s:%d %w", path, lineNum, err)
+			}
+			cfg.WorktreeSubdir = sub
+		case "flatten_branch_dirs":
+			v, err := parseBool(value)
+			if err != nil {
+				return fmt.Errorf("%s:%d invalid flatten_branch_dirs: %w", path, lineNum, err)
+			}
+			cfg.FlattenBranchDirs = v
 		case "auto_launch":
 			v, err := parseBool(value)
 			if err != nil {
 				return fmt.Errorf("%s:%d invalid auto_launch: %w", path, lineNum, err)
 			}
 			cfg.AutoLaunch = v
+		case "show_resources":
+			v, err := parseBool(value)
+			if err != nil {
+				return fmt.Errorf("%s:%d invalid show_resources: %w", path, lineNum, err)
+			}
+			cfg.ShowResources = v
+		case "repo_sidebar":
+			v, err := parseBool(value)
+			if err != nil {
+				return fmt.Errorf("%s:%d invalid repo_sidebar: %w", path, lineNum, err)
+			}
+			cfg.RepoSidebar = v
+		case "columns":
+			v, err := parseStringArray(value)
+			if err != nil {
+				return fmt.Errorf("%s:%d invalid columns: %w", path, lineNum, err)
+			}
+			columns, err := parseColumns(v)
+			if err != nil {
+				return fmt.Errorf("%s:%d %w", path, lineNum, err)
+			}
+			cfg.Columns = columns
+		case "sort":
+			v, err := parseString(value)
+			if err != nil {
+				return fmt.Errorf("%s:%d invalid sort: %w", path, lineNum, err)
+			}
+			mode, err := parseWorktreeSort(v)
+			if err != nil {
+				return fmt.Errorf("%s:%d %w", path, lineNum, err)
+			}
+			cfg.Sort = mode
+		case "path_display":
+			v, err := parseString(value)
+			if err != nil {
+				return fmt.Errorf("%s:%d invalid path_display: %w", path, lineNum, err)
+			}
+			mode, err := parsePathDisplay(v)
+			if err != nil {
+				return fmt.Errorf("%s:%d %w", path, lineNum, err)
+			}
+			cfg.PathDisplay = mode
 		case "auto_start_agent":
 			v, err := parseBool(value)
 			if err != nil {
@@ -209,6 +573,16 @@ func parseTOMLFlat(path string, cfg *Config) error {
 				return fmt.Errorf("%s:%d invalid update_check: %w", path, lineNum, err)
 			}
 			cfg.UpdateCheck = v
+		case "update_channel":
+			v, err := parseString(value)
+			if err != nil {
+				return fmt.Errorf("%s:%d invalid update_channel: %w", path, lineNum, err)
+			}
+			channel, err := parseUpdateChannel(v)
+			if err != nil {
+				return fmt.Errorf("%s:%d %w", path, lineNum, err)
+			}
+			cfg.UpdateChannel = channel
 		case "session_tools":
 			v, err := parseStringArray(value)
 			if err != nil {
@@ -235,6 +609,18 @@ func parseTOMLFlat(path string, cfg *Config) error {
 				return fmt.Errorf("%s:%d invalid agent_command: %w", path, lineNum, err)
 			}
 			cfg.AgentCommand = v
+		case "agent_args":
+			v, err := parseString(value)
+			if err != nil {
+				return fmt.Errorf("%s:%d invalid agent_args: %w", path, lineNum, err)
+			}
+			cfg.AgentArgs = v
+		case "agent_resume_prompt":
+			v, err := parseString(value)
+			if err != nil {
+				return fmt.Errorf("%s:%d invalid agent_resume_prompt: %w", path, lineNum, err)
+			}
+			cfg.AgentResumePrompt = v
 		case "default_agent_type":
 			v, err := parseString(value)
 			if err != nil {
@@ -247,6 +633,238 @@ func parseTOMLFlat(path string, cfg *Config) error {
 				return fmt.Errorf("%s:%d invalid session_prefix: %w", path, lineNum, err)
 			}
 			cfg.SessionPrefix = v
+		case "session_mode":
+			v, err := parseString(value)
+			if err != nil {
+				return fmt.Errorf("%s:%d invalid session_mode: %w", path, lineNum, err)
+			}
+			mode, err := parseSessionMode(v)
+			if err != nil {
+				return fmt.Errorf("%s:%d %w", path, lineNum, err)
+			}
+			cfg.SessionMode = mode
+		case "slug_mode":
+			v, err := parseString(value)
+			if err != nil {
+				return fmt.Errorf("%s:%d invalid slug_mode: %w", path, lineNum, err)
+			}
+			mode, err := parseSlugMode(v)
+			if err != nil {
+				return fmt.Errorf("%s:%d %w", path, lineNum, err)
+			}
+			cfg.SlugMode = mode
+		case "branch_types":
+			v, err := parseStringArray(value)
+			if err != nil {
+				return fmt.Errorf("%s:%d invalid branch_types: %w", path, lineNum, err)
+			}
+			cfg.BranchTypes = normalizeBranchTypes(v)
+		case "worktree_path_template":
+			v, err := parseString(value)
+			if err != nil {
+				return fmt.Errorf("%s:%d invalid worktree_path_template: %w", path, lineNum, err)
+			}
+			template, err := parseWorktreePathTemplate(v)
+			if err != nil {
+				return fmt.Errorf("%s:%d %w", path, lineNum, err)
+			}
+			cfg.WorktreePathTemplate = template
+		case "branch_template":
+			v, err := parseString(value)
+			if err != nil {
+				return fmt.Errorf("%s:%d invalid branch_template: %w", path, lineNum, err)
+			}
+			template, err := parseBranchTemplate(v)
+			if err != nil {
+				return fmt.Errorf("%s:%d %w", path, lineNum, err)
+			}
+			cfg.BranchTemplate = template
+		case "branch_pattern":
+			v, err := parseString(value)
+			if err != nil {
+				return fmt.Errorf("%s:%d invalid branch_pattern: %w", path, lineNum, err)
+			}
+			pattern, err := parseBranchPattern(v)
+			if err != nil {
+				return fmt.Errorf("%s:%d %w", path, lineNum, err)
+			}
+			cfg.BranchPattern = pattern
+		case "git_backend":
+			v, err := parseString(value)
+			if err != nil {
+				return fmt.Errorf("%s:%d invalid git_backend: %w", path, lineNum, err)
+			}
+			backend, err := parseGitBackend(v)
+			if err != nil {
+				return fmt.Errorf("%s:%d %w", path, lineNum, err)
+			}
+			cfg.GitBackend = backend
+		case "dirty_untracked":
+			v, err := parseString(value)
+			if err != nil {
+				return fmt.Errorf("%s:%d invalid dirty_untracked: %w", path, lineNum, err)
+			}
+			mode, err := parseDirtyUntracked(v)
+			if err != nil {
+				return fmt.Errorf("%s:%d %w", path, lineNum, err)
+			}
+			cfg.DirtyUntracked = mode
+		case "wip_limit":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return fmt.Errorf("%s:%d invalid wip_limit: %q (want a non-negative integer)", path, lineNum, value)
+			}
+			cfg.WIPLimit = n
+		case "agent_idle_minutes":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return fmt.Errorf("%s:%d invalid agent_idle_minutes: %q (want a non-negative integer)", path, lineNum, value)
+			}
+			cfg.AgentIdleMinutes = n
+		case "auto_fetch_minutes":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return fmt.Errorf("%s:%d invalid auto_fetch_minutes: %q (want a non-negative integer)", path, lineNum, value)
+			}
+			cfg.AutoFetchMinutes = n
+		case "agent_exit_keys":
+			v, err := parseStringArray(value)
+			if err != nil {
+				return fmt.Errorf("%s:%d invalid agent_exit_keys: %w", path, lineNum, err)
+			}
+			cfg.AgentExitKeys = v
+		case "agent_exit_wait":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return fmt.Errorf("%s:%d invalid agent_exit_wait: %q (want a non-negative number of seconds)", path, lineNum, value)
+			}
+			cfg.AgentExitWait = n
+		case "undo_minutes":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return fmt.Errorf("%s:%d invalid undo_minutes: %q (want a non-negative number of minutes)", path, lineNum, value)
+			}
+			cfg.UndoMinutes = n
+		case "details_percent":
+			n, err := parseDetailsPercent(value)
+			if err != nil {
+				return fmt.Errorf("%s:%d %w", path, lineNum, err)
+			}
+			cfg.DetailsPercent = n
+		case "detail_poll_ms", "detail_idle_poll_ms", "detail_capture_lines", "diff_files_cache_ms", "diff_patch_cache_ms":
+			n, err := parseDetailSetting(key, value)
+			if err != nil {
+				return fmt.Errorf("%s:%d %w", path, lineNum, err)
+			}
+			*detailSettingFields(cfg)[key] = n
+		case "notify_desktop", "notify_bell", "notify_webhook_events":
+			v, err := parseStringArray(value)
+			if err == nil {
+				v, err = parseNotifyEvents(v)
+			}
+			if err != nil {
+				return fmt.Errorf("%s:%d invalid %s: %w", path, lineNum, key, err)
+			}
+			switch key {
+			case "notify_desktop":
+				cfg.NotifyDesktop = v
+			case "notify_bell":
+				cfg.NotifyBell = v
+			default:
+				cfg.NotifyWebhookEvents = v
+			}
+		case "notify_webhook":
+			v, err := parseString(value)
+			if err != nil {
+				return fmt.Errorf("%s:%d invalid notify_webhook: %w", path, lineNum, err)
+			}
+			cfg.NotifyWebhook = strings.TrimSpace(v)
+		case "ssh_host":
+			v, err := parseString(value)
+			if err != nil {
+				return fmt.Errorf("%s:%d invalid ssh_host: %w", path, lineNum, err)
+			}
+			cfg.SSHHost = strings.TrimSpace(v)
+		case "ssh_repo":
+			v, err := parseString(value)
+			if err != nil {
+				return fmt.Errorf("%s:%d invalid ssh_repo: %w", path, lineNum, err)
+			}
+			cfg.SSHRepo = strings.TrimSpace(v)
+		case "container_command", "container_up", "container_down":
+			v, err := parseString(value)
+			if err != nil {
+				return fmt.Errorf("%s:%d invalid %s: %w", path, lineNum, key, err)
+			}
+			switch key {
+			case "container_command":
+				cfg.ContainerCommand = strings.TrimSpace(v)
+			case "container_up":
+				cfg.ContainerUp = strings.TrimSpace(v)
+			default:
+				cfg.ContainerDown = strings.TrimSpace(v)
+			}
+		case "check_command":
+			v, err := parseString(value)
+			if err != nil {
+				return fmt.Errorf("%s:%d invalid check_command: %w", path, lineNum, err)
+			}
+			cfg.CheckCommand = strings.TrimSpace(v)
+		case "export_dir":
+			v, err := parseString(value)
+			if err != nil {
+				return fmt.Errorf("%s:%d invalid export_dir: %w", path, lineNum, err)
+			}
+			cfg.ExportDir = strings.TrimSpace(v)
+		case "on_quit":
+			v, err := parseString(value)
+			if err != nil {
+				return fmt.Errorf("%s:%d invalid on_quit: %w", path, lineNum, err)
+			}
+			action, err := parseQuitAction(v)
+			if err != nil {
+				return fmt.Errorf("%s:%d %w", path, lineNum, err)
+			}
+			cfg.OnQuit = action
+		case "launch_backend":
+			v, err := parseString(value)
+			if err != nil {
+				return fmt.Errorf("%s:%d invalid launch_backend: %w", path, lineNum, err)
+			}
+			backend, err := parseLaunchBackend(v)
+			if err != nil {
+				return fmt.Errorf("%s:%d %w", path, lineNum, err)
+			}
+			cfg.LaunchBackend = backend
+		case "color":
+			v, err := parseString(value)
+			if err != nil {
+				return fmt.Errorf("%s:%d invalid color: %w", path, lineNum, err)
+			}
+			mode, err := parseColorMode(v)
+			if err != nil {
+				return fmt.Errorf("%s:%d %w", path, lineNum, err)
+			}
+			cfg.Color = mode
+		case "theme":
+			v, err := parseString(value)
+			if err != nil {
+				return fmt.Errorf("%s:%d invalid theme: %w", path, lineNum, err)
+			}
+			theme, err := parseTheme(v)
+			if err != nil {
+				return fmt.Errorf("%s:%d %w", path, lineNum, err)
+			}
+			cfg.Theme = theme
+		case "log_level":
+			v, err := parseString(value)
+			if err != nil {
+				return fmt.Errorf("%s:%d invalid log_level: %w", path, lineNum, err)
+			}
+			if _, ok := parseLogLevel(v); !ok {
+				return fmt.Errorf("%s:%d invalid log_level: %q (want error, info, debug, or trace)", path, lineNum, v)
+			}
+			cfg.LogLevel = v
 		default:
 			if strings.HasPrefix(key, "window_") {
 				// Format: window_<winname> = ["cmd1", "cmd2"]
@@ -545,11 +1163,48 @@ func applyEnvOverrides(cfg *Config) {
 	if v := os.Getenv("SPROUT_WORKTREE_ROOT_TEMPLATE"); v != "" {
 		cfg.WorktreeRootTemplate = v
 	}
+	if v := os.Getenv("SPROUT_WORKTREE_PATH_TEMPLATE"); v != "" {
+		if template, err := parseWorktreePathTemplate(v); err == nil {
+			cfg.WorktreePathTemplate = template
+		}
+	}
+	if v := os.Getenv("SPROUT_FLATTEN_BRANCH_DIRS"); v != "" {
+		if b, err := parseBool(v); err == nil {
+			cfg.FlattenBranchDirs = b
+		}
+	}
 	if v := os.Getenv("SPROUT_AUTO_LAUNCH"); v != "" {
 		if b, err := parseBool(v); err == nil {
 			cfg.AutoLaunch = b
 		}
 	}
+	if v := os.Getenv("SPROUT_SHOW_RESOURCES"); v != "" {
+		if b, err := parseBool(v); err == nil {
+			cfg.ShowResources = b
+		}
+	}
+	if v := os.Getenv("SPROUT_REPO_SIDEBAR"); v != "" {
+		if b, err := parseBool(v); err == nil {
+			cfg.RepoSidebar = b
+		}
+	}
+	if v := os.Getenv("SPROUT_COLUMNS"); v != "" {
+		if items, err := parseStringListEnv(v); err == nil {
+			if columns, err := parseColumns(items); err == nil {
+				cfg.Columns = columns
+			}
+		}
+	}
+	if v := os.Getenv("SPROUT_SORT"); v != "" {
+		if mode, err := parseWorktreeSort(v); err == nil {
+			cfg.Sort = mode
+		}
+	}
+	if v := os.Getenv("SPROUT_PATH_DISPLAY"); v != "" {
+		if mode, err := parsePathDisplay(v); err == nil {
+			cfg.PathDisplay = mode
+		}
+	}
 	if v := os.Getenv("SPROUT_AUTO_START_AGENT"); v != "" {
 		if b, err := parseBool(v); err == nil {
 			cfg.AutoStartAgent = b
@@ -560,6 +1215,11 @@ func applyEnvOverrides(cfg *Config) {
 			cfg.UpdateCheck = b
 		}
 	}
+	if v := os.Getenv("SPROUT_UPDATE_CHANNEL"); v != "" {
+		if channel, err := parseUpdateChannel(v); err == nil {
+			cfg.UpdateChannel = channel
+		}
+	}
 	if v := os.Getenv("SPROUT_COPY_UNTRACKED_EXCLUDE"); v != "" {
 		if items, err := parseStringListEnv(v); err == nil {
 			cfg.CopyUntrackedExclude = items
@@ -585,6 +1245,12 @@ func applyEnvOverrides(cfg *Config) {
 	if v := os.Getenv("SPROUT_AGENT_COMMAND"); v != "" {
 		cfg.AgentCommand = v
 	}
+	if v := os.Getenv("SPROUT_AGENT_ARGS"); v != "" {
+		cfg.AgentArgs = v
+	}
+	if v := os.Getenv("SPROUT_AGENT_RESUME_PROMPT"); v != "" {
+		cfg.AgentResumePrompt = v
+	}
 	if v := os.Getenv("SPROUT_DEFAULT_AGENT_TYPE"); v != "" {
 		cfg.DefaultAgentType = strings.ToLower(strings.TrimSpace(v))
 	}
@@ -608,9 +1274,172 @@ func applyEnvOverrides(cfg *Config) {
 		}
 		cfg.AgentCommands[agentType] = val
 	}
+	for _, entry := range os.Environ() {
+		key, val, _ := strings.Cut(entry, "=")
+		hook, ok := strings.CutPrefix(key, hookEnvPrefix)
+		if !ok {
+			continue
+		}
+		name, err := parseHookName(hook)
+		if err != nil {
+			continue
+		}
+		if cfg.Hooks == nil {
+			cfg.Hooks = map[string]string{}
+		}
+		cfg.Hooks[name] = val
+	}
+	if v := os.Getenv("SPROUT_WORKTREE_SUBDIR"); v != "" {
+		if sub, err := parseWorktreeSubdir(v); err == nil {
+			cfg.WorktreeSubdir = sub
+		}
+	}
 	if v := os.Getenv("SPROUT_SESSION_PREFIX"); v != "" {
 		cfg.SessionPrefix = v
 	}
+	if v := os.Getenv("SPROUT_SESSION_MODE"); v != "" {
+		if mode, err := parseSessionMode(v); err == nil {
+			cfg.SessionMode = mode
+		}
+	}
+	if v := os.Getenv("SPROUT_SLUG_MODE"); v != "" {
+		if mode, err := parseSlugMode(v); err == nil {
+			cfg.SlugMode = mode
+		}
+	}
+	if v := os.Getenv("SPROUT_BRANCH_TYPES"); v != "" {
+		if items, err := parseStringListEnv(v); err == nil {
+			cfg.BranchTypes = normalizeBranchTypes(items)
+		}
+	}
+	if v := os.Getenv("SPROUT_BRANCH_TEMPLATE"); v != "" {
+		if template, err := parseBranchTemplate(v); err == nil {
+			cfg.BranchTemplate = template
+		}
+	}
+	if v := os.Getenv("SPROUT_BRANCH_PATTERN"); v != "" {
+		if pattern, err := parseBranchPattern(v); err == nil {
+			cfg.BranchPattern = pattern
+		}
+	}
+	if v := os.Getenv("SPROUT_GIT_BACKEND"); v != "" {
+		if backend, err := parseGitBackend(v); err == nil {
+			cfg.GitBackend = backend
+		}
+	}
+	if v := os.Getenv("SPROUT_DIRTY_UNTRACKED"); v != "" {
+		if mode, err := parseDirtyUntracked(v); err == nil {
+			cfg.DirtyUntracked = mode
+		}
+	}
+	if v := os.Getenv("SPROUT_WIP_LIMIT"); v != "" {
+		if n, err := strconv.Atoi(strings.TrimSpace(v)); err == nil && n >= 0 {
+			cfg.WIPLimit = n
+		}
+	}
+	if v := os.Getenv("SPROUT_AGENT_IDLE_MINUTES"); v != "" {
+		if n, err := strconv.Atoi(strings.TrimSpace(v)); err == nil && n >= 0 {
+			cfg.AgentIdleMinutes = n
+		}
+	}
+	if v := os.Getenv("SPROUT_AUTO_FETCH_MINUTES"); v != "" {
+		if n, err := strconv.Atoi(strings.TrimSpace(v)); err == nil && n >= 0 {
+			cfg.AutoFetchMinutes = n
+		}
+	}
+	if v, ok := os.LookupEnv("SPROUT_AGENT_EXIT_KEYS"); ok {
+		if keys, err := parseStringListEnv(v); err == nil {
+			cfg.AgentExitKeys = keys
+		}
+	}
+	if v := os.Getenv("SPROUT_AGENT_EXIT_WAIT"); v != "" {
+		if n, err := strconv.Atoi(strings.TrimSpace(v)); err == nil && n >= 0 {
+			cfg.AgentExitWait = n
+		}
+	}
+	if v := os.Getenv("SPROUT_UNDO_MINUTES"); v != "" {
+		if n, err := strconv.Atoi(strings.TrimSpace(v)); err == nil && n >= 0 {
+			cfg.UndoMinutes = n
+		}
+	}
+	if v := os.Getenv("SPROUT_DETAILS_PERCENT"); v != "" {
+		if n, err := parseDetailsPercent(v); err == nil {
+			cfg.DetailsPercent = n
+		}
+	}
+	for key, dst := range detailSettingFields(cfg) {
+		if v := os.Getenv("SPROUT_" + strings.ToUpper(key)); v != "" {
+			if n, err := parseDetailSetting(key, v); err == nil {
+				*dst = n
+			}
+		}
+	}
+	for env, dst := range map[string]*[]string{
+		"SPROUT_NOTIFY_DESKTOP":        &cfg.NotifyDesktop,
+		"SPROUT_NOTIFY_BELL":           &cfg.NotifyBell,
+		"SPROUT_NOTIFY_WEBHOOK_EVENTS": &cfg.NotifyWebhookEvents,
+	} {
+		if v, ok := os.LookupEnv(env); ok {
+			if list, err := parseStringListEnv(v); err == nil {
+				if events, err := parseNotifyEvents(list); err == nil {
+					*dst = events
+				}
+			}
+		}
+	}
+	if v := os.Getenv("SPROUT_NOTIFY_WEBHOOK"); v != "" {
+		cfg.NotifyWebhook = strings.TrimSpace(v)
+	}
+	if v := os.Getenv("SPROUT_SSH_HOST"); v != "" {
+		cfg.SSHHost = strings.TrimSpace(v)
+	}
+	if v := os.Getenv("SPROUT_SSH_REPO"); v != "" {
+		cfg.SSHRepo = strings.TrimSpace(v)
+	}
+	if v := os.Getenv("SPROUT_CONTAINER_COMMAND"); v != "" {
+		cfg.ContainerCommand = strings.TrimSpace(v)
+	}
+	if v := os.Getenv("SPROUT_CONTAINER_UP"); v != "" {
+		cfg.ContainerUp = strings.TrimSpace(v)
+	}
+	if v := os.Getenv("SPROUT_CONTAINER_DOWN"); v != "" {
+		cfg.ContainerDown = strings.TrimSpace(v)
+	}
+	if v := os.Getenv("SPROUT_CHECK_COMMAND"); v != "" {
+		cfg.CheckCommand = strings.TrimSpace(v)
+	}
+	if v := os.Getenv("SPROUT_EXPORT_DIR"); v != "" {
+		cfg.ExportDir = strings.TrimSpace(v)
+	}
+	if v := os.Getenv("SPROUT_ON_QUIT"); v != "" {
+		if action, err := parseQuitAction(v); err == nil {
+			cfg.OnQuit = action
+		}
+	}
+	if v := os.Getenv("SPROUT_LAUNCH_BACKEND"); v != "" {
+		if backend, err := parseLaunchBackend(v); err == nil {
+			cfg.LaunchBackend = backend
+		}
+	}
+	// https://no-color.org: any non-empty value disables color.
+	if os.Getenv("NO_COLOR") != "" {
+		cfg.Color = colorNever
+	}
+	if v := os.Getenv("SPROUT_COLOR"); v != "" {
+		if mode, err := parseColorMode(v); err == nil {
+			cfg.Color = mode
+		}
+	}
+	if v := os.Getenv("SPROUT_THEME"); v != "" {
+		if theme, err := parseTheme(v); err == nil {
+			cfg.Theme = theme
+		}
+	}
+	if v := os.Getenv("SPROUT_DEBUG"); v != "" {
+		if _, ok := parseLogLevel(v); ok {
+			cfg.LogLevel = v
+		}
+	}
 }
 
 // parseTOMLStructured uses BurntSushi/toml to decode the structured [[windows]]
@@ -633,14 +1462,31 @@ func parseTOMLStructured(path string, cfg *Config, repoName string, isRepoConfig
 		return err
 	}
 
-	if isRepoConfig {
-		if len(raw.Windows) > 0 {
-			cfg.Windows = raw.Windows
+	windows := raw.Windows
+	if !isRepoConfig {
+		windows = nil
+		if repoCfg, ok := raw.Repos[repoName]; ok && repoName != "" {
+			windows = repoCfg.Windows
 		}
-	} else if repoName != "" {
-		if repoCfg, ok := raw.Repos[repoName]; ok && len(repoCfg.Windows) > 0 {
-			cfg.Windows = repoCfg.Windows
+	}
+	if len(windows) == 0 {
+		return nil
+	}
+	for i := range windows {
+		if err := parsePaneEnv(windows[i].Env); err != nil {
+			return fmt.Errorf("%s: window %d: %w", path, i+1, err)
+		}
+		for j := range windows[i].Panes {
+			if err := parsePaneEnv(windows[i].Panes[j].Env); err != nil {
+				return fmt.Errorf("%s: window %d pane %d: %w", path, i+1, j+1, err)
+			}
+			size, err := parsePaneSize(windows[i].Panes[j].Size)
+			if err != nil {
+				return fmt.Errorf("%s: window %d pane %d: %w", path, i+1, j+1, err)
+			}
+			windows[i].Panes[j].Size = size
 		}
 	}
+	cfg.Windows = windows
 	return nil
 }
@@ -4,7 +4,9 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestParseTOMLFlat(t *testing.T) {
@@ -86,3 +88,707 @@ func TestApplyEnvOverridesUpdateCheck(t *testing.T) {
 		t.Fatalf("unexpected update_check from env: got=%v want=false", cfg.UpdateCheck)
 	}
 }
+
+func TestParseTOMLFlatLogLevel(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+	if err := os.WriteFile(path, []byte(`log_level = "trace"`), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg := DefaultConfig()
+	if err := parseTOMLFlat(path, &cfg); err != nil {
+		t.Fatalf("parse config: %v", err)
+	}
+	if cfg.LogLevel != "trace" {
+		t.Fatalf("expected trace log level, got %q", cfg.LogLevel)
+	}
+
+	if err := os.WriteFile(path, []byte(`log_level = "loud"`), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := parseTOMLFlat(path, &cfg); err == nil {
+		t.Fatalf("expected error for unknown log level")
+	}
+}
+
+func TestApplyEnvOverridesDebugLevel(t *testing.T) {
+	t.Setenv("SPROUT_DEBUG", "1")
+	cfg := DefaultConfig()
+	applyEnvOverrides(&cfg)
+	if level, _ := parseLogLevel(cfg.LogLevel); level != logLevelDebug {
+		t.Fatalf("expected SPROUT_DEBUG=1 to select debug, got %q", cfg.LogLevel)
+	}
+}
+
+func TestParseTOMLFlatWIPLimit(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+	if err := os.WriteFile(path, []byte("wip_limit = 3\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg := DefaultConfig()
+	if err := parseTOMLFlat(path, &cfg); err != nil {
+		t.Fatalf("parse config: %v", err)
+	}
+	if cfg.WIPLimit != 3 {
+		t.Fatalf("expected wip_limit 3, got %d", cfg.WIPLimit)
+	}
+
+	if err := os.WriteFile(path, []byte("wip_limit = -1\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := parseTOMLFlat(path, &cfg); err == nil {
+		t.Fatalf("expected error for negative wip_limit")
+	}
+}
+
+func TestParseTOMLFlatAgentIdleMinutes(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+	cfg := DefaultConfig()
+	if cfg.AgentIdleMinutes != defaultAgentIdleMinutes {
+		t.Fatalf("expected default agent_idle_minutes %d, got %d", defaultAgentIdleMinutes, cfg.AgentIdleMinutes)
+	}
+	if err := os.WriteFile(path, []byte("agent_idle_minutes = 0\nnotify_bell = [\"idle\"]\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := parseTOMLFlat(path, &cfg); err != nil {
+		t.Fatalf("parse config: %v", err)
+	}
+	if cfg.AgentIdleMinutes != 0 || len(cfg.NotifyBell) != 1 || cfg.NotifyBell[0] != notifyEventIdle {
+		t.Fatalf("unexpected config: agent_idle_minutes=%d notify_bell=%v", cfg.AgentIdleMinutes, cfg.NotifyBell)
+	}
+
+	if err := os.WriteFile(path, []byte("agent_idle_minutes = soon\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := parseTOMLFlat(path, &cfg); err == nil {
+		t.Fatalf("expected error for a non-numeric agent_idle_minutes")
+	}
+}
+
+func TestParseTOMLFlatAgentExit(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+	cfg := DefaultConfig()
+	if len(cfg.AgentExitKeys) != 2 || cfg.AgentExitWait != defaultAgentExitWait {
+		t.Fatalf("unexpected defaults: agent_exit_keys=%v agent_exit_wait=%d", cfg.AgentExitKeys, cfg.AgentExitWait)
+	}
+	if err := os.WriteFile(path, []byte("agent_exit_keys = [\"/quit\", \"Enter\"]\nagent_exit_wait = 10\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := parseTOMLFlat(path, &cfg); err != nil {
+		t.Fatalf("parse config: %v", err)
+	}
+	if len(cfg.AgentExitKeys) != 2 || cfg.AgentExitKeys[0] != "/quit" || cfg.AgentExitKeys[1] != "Enter" || cfg.AgentExitWait != 10 {
+		t.Fatalf("unexpected config: agent_exit_keys=%v agent_exit_wait=%d", cfg.AgentExitKeys, cfg.AgentExitWait)
+	}
+
+	if err := os.WriteFile(path, []byte("agent_exit_wait = -1\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := parseTOMLFlat(path, &cfg); err == nil {
+		t.Fatalf("expected error for a negative agent_exit_wait")
+	}
+}
+
+func TestParseTOMLFlatOnQuit(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+	if err := os.WriteFile(path, []byte("on_quit = \"Stop-Agents\"\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg := DefaultConfig()
+	if cfg.OnQuit != quitActionNone {
+		t.Fatalf("expected default on_quit none, got %q", cfg.OnQuit)
+	}
+	if err := parseTOMLFlat(path, &cfg); err != nil {
+		t.Fatalf("parse config: %v", err)
+	}
+	if cfg.OnQuit != quitActionStopAgents {
+		t.Fatalf("expected on_quit stop-agents, got %q", cfg.OnQuit)
+	}
+
+	if err := os.WriteFile(path, []byte("on_quit = \"kill\"\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := parseTOMLFlat(path, &cfg); err == nil {
+		t.Fatalf("expected error for invalid on_quit")
+	}
+}
+
+func TestParseTOMLFlatLaunchBackend(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+	cfg := DefaultConfig()
+	if cfg.LaunchBackend != launchBackendTmux {
+		t.Fatalf("expected default launch_backend tmux, got %q", cfg.LaunchBackend)
+	}
+	if err := os.WriteFile(path, []byte("launch_backend = \"WT\"\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := parseTOMLFlat(path, &cfg); err != nil {
+		t.Fatalf("parse config: %v", err)
+	}
+	if cfg.LaunchBackend != launchBackendWT {
+		t.Fatalf("expected launch_backend wt, got %q", cfg.LaunchBackend)
+	}
+	m := &Manager{Cfg: Config{LaunchBackend: launchBackendNone, AutoStartAgent: true}}
+	if m.autoStartAgent() {
+		t.Fatalf("expected no agent auto-start without a launch backend")
+	}
+
+	if err := os.WriteFile(path, []byte("launch_backend = \"screen\"\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := parseTOMLFlat(path, &cfg); err == nil {
+		t.Fatalf("expected error for invalid launch_backend")
+	}
+}
+
+func TestParseTOMLFlatNotify(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+	content := `notify_desktop = ["ready"]
+notify_bell = ["Ready", "exited"]
+notify_webhook = "http://localhost:9000/hook"
+notify_webhook_events = ["exited"]`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg := DefaultConfig()
+	if newNotifier(cfg).Enabled() {
+		t.Fatalf("expected notifications to be off by default")
+	}
+	if err := parseTOMLFlat(path, &cfg); err != nil {
+		t.Fatalf("parse config: %v", err)
+	}
+	if !reflect.DeepEqual(cfg.NotifyDesktop, []string{"ready"}) || !reflect.DeepEqual(cfg.NotifyBell, []string{"ready", "exited"}) ||
+		cfg.NotifyWebhook != "http://localhost:9000/hook" || !reflect.DeepEqual(cfg.NotifyWebhookEvents, []string{"exited"}) {
+		t.Fatalf("unexpected notify config: %+v", cfg)
+	}
+
+	if err := os.WriteFile(path, []byte("notify_bell = [\"done\"]\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := parseTOMLFlat(path, &cfg); err == nil {
+		t.Fatalf("expected error for invalid notify event")
+	}
+}
+
+func TestParseTOMLFlatColorAndTheme(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+	if err := os.WriteFile(path, []byte("color = \"never\"\ntheme = \"Light\"\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg := DefaultConfig()
+	if err := parseTOMLFlat(path, &cfg); err != nil {
+		t.Fatalf("parse config: %v", err)
+	}
+	if cfg.Color != colorNever || cfg.Theme != themeLight {
+		t.Fatalf("expected color never and light theme, got color=%q theme=%q", cfg.Color, cfg.Theme)
+	}
+
+	if err := os.WriteFile(path, []byte("theme = \"solarized\"\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := parseTOMLFlat(path, &cfg); err == nil {
+		t.Fatalf("expected error for unknown theme")
+	}
+}
+
+func TestParseTOMLFlatBranchPolicy(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+	content := `branch_types = ["story", "bug"]
+branch_template = "{type}/{date}/{slug}"
+branch_pattern = "^(story|bug)/"`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg := DefaultConfig()
+	if err := parseTOMLFlat(path, &cfg); err != nil {
+		t.Fatalf("parse config: %v", err)
+	}
+	if !reflect.DeepEqual(cfg.BranchTypes, []string{"story", "bug"}) || cfg.BranchTemplate != "{type}/{date}/{slug}" || cfg.BranchPattern != "^(story|bug)/" {
+		t.Fatalf("unexpected branch policy: %+v", cfg)
+	}
+
+	for _, bad := range []string{`branch_template = "{type}/fixed"`, `branch_template = "{team}/{slug}"`, `branch_pattern = "feat/("`} {
+		if err := os.WriteFile(path, []byte(bad+"\n"), 0o644); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		if err := parseTOMLFlat(path, &cfg); err == nil {
+			t.Fatalf("expected error for %s", bad)
+		}
+	}
+}
+
+func TestParseTOMLFlatColumns(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+	if err := os.WriteFile(path, []byte(`columns = ["Branch", "status", "todo", "ahead", "path"]`+"\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg := DefaultConfig()
+	if err := parseTOMLFlat(path, &cfg); err != nil {
+		t.Fatalf("parse config: %v", err)
+	}
+	if want := []string{"branch", "status", "todo", "ahead", "path"}; !reflect.DeepEqual(cfg.Columns, want) {
+		t.Fatalf("columns = %v, want %v", cfg.Columns, want)
+	}
+	if got, want := listColumns(cfg), []string{"branch", "status", "ahead", "path"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("listColumns = %v, want %v", got, want)
+	}
+	if got, want := tableColumns(cfg, true), []string{"branch", "status", "todo", "ahead", "resources", "path"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("tableColumns = %v, want %v", got, want)
+	}
+	if got := tableColumns(DefaultConfig(), false); columnIndex(got, columnResources) >= 0 {
+		t.Fatalf("expected the hidden resources column to be dropped, got %v", got)
+	}
+
+	for _, bad := range []string{`columns = ["branch", "size"]`, `columns = ["path", "path"]`} {
+		if err := os.WriteFile(path, []byte(bad+"\n"), 0o644); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		if err := parseTOMLFlat(path, &cfg); err == nil {
+			t.Fatalf("expected error for %s", bad)
+		}
+	}
+}
+
+func TestApplyEnvOverridesNoColor(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	cfg := DefaultConfig()
+	applyEnvOverrides(&cfg)
+	if cfg.Color != colorNever {
+		t.Fatalf("expected NO_COLOR to select color never, got %q", cfg.Color)
+	}
+
+	t.Setenv("SPROUT_COLOR", "always")
+	cfg = DefaultConfig()
+	applyEnvOverrides(&cfg)
+	if cfg.Color != colorAlways {
+		t.Fatalf("expected SPROUT_COLOR to override NO_COLOR, got %q", cfg.Color)
+	}
+}
+
+func TestSaveGlobalConfigValue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sprout", "config.toml")
+	t.Setenv("SPROUT_CONFIG", path)
+
+	if err := saveGlobalConfigValue("details_percent", "70"); err != nil {
+		t.Fatalf("save to missing file: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "details_percent = 70\n" {
+		t.Fatalf("unexpected new config: %q", data)
+	}
+
+	content := "base_branch = \"main\"\ndetails_percent = 40 # mine\n\n[[windows]]\nname = \"dev\"\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := saveGlobalConfigValue("details_percent", "55"); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if err := saveGlobalConfigValue("wip_limit", "2"); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	want := "base_branch = \"main\"\ndetails_percent = 55\nwip_limit = 2\n\n[[windows]]\nname = \"dev\"\n"
+	if data, _ := os.ReadFile(path); string(data) != want {
+		t.Fatalf("unexpected updated config:\n%s\nwant:\n%s", data, want)
+	}
+
+	cfg := DefaultConfig()
+	if err := parseTOMLFlat(path, &cfg); err != nil {
+		t.Fatalf("parse config: %v", err)
+	}
+	if cfg.DetailsPercent != 55 || cfg.WIPLimit != 2 {
+		t.Fatalf("unexpected parsed config: details_percent=%d wip_limit=%d", cfg.DetailsPercent, cfg.WIPLimit)
+	}
+
+	if err := os.WriteFile(path, []byte("details_percent = 95\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := parseTOMLFlat(path, &cfg); err == nil {
+		t.Fatalf("expected error for out-of-range details_percent")
+	}
+}
+
+func TestConfigShowOrigins(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	content := "base_branch = \"dev\" # team default\nagent_command_pi = \"pi --yolo\"\n\n[[repos.app.windows]]\nname = \"dev\"\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	t.Setenv("SPROUT_WIP_LIMIT", "3")
+
+	cfg := DefaultConfig()
+	origins := map[string]string{}
+	if err := parseTOMLFlat(path, &cfg); err != nil {
+		t.Fatalf("parse config: %v", err)
+	}
+	if err := parseTOMLStructured(path, &cfg, "app", false); err != nil {
+		t.Fatalf("parse structured config: %v", err)
+	}
+	if err := markConfigFileOrigins(origins, path, "app", false); err != nil {
+		t.Fatalf("mark origins: %v", err)
+	}
+	applyEnvOverrides(&cfg)
+	markConfigEnvOrigins(origins, cfg)
+
+	got := map[string]configEntry{}
+	for _, entry := range effectiveConfigEntries(cfg, origins) {
+		got[entry.Key] = entry
+	}
+	if e := got["base_branch"]; e.Value != "dev" || e.Origin != path {
+		t.Fatalf("unexpected base_branch entry: %+v", e)
+	}
+	if e := got["wip_limit"]; e.Value != 3 || e.Origin != "SPROUT_WIP_LIMIT" {
+		t.Fatalf("unexpected wip_limit entry: %+v", e)
+	}
+	if e := got["agent_command_pi"]; e.Value != "pi --yolo" || e.Origin != path {
+		t.Fatalf("unexpected agent_command_pi entry: %+v", e)
+	}
+	if e := got["session_prefix"]; e.Origin != configOriginDefault {
+		t.Fatalf("unexpected session_prefix entry: %+v", e)
+	}
+	if origins["windows"] != path {
+		t.Fatalf("expected windows from %s, got %q", path, origins["windows"])
+	}
+
+	// The shown TOML reads back as the same config.
+	text, err := renderConfigTOML(cfg, origins, true)
+	if err != nil {
+		t.Fatalf("render config: %v", err)
+	}
+	shown := filepath.Join(t.TempDir(), "shown.toml")
+	if err := os.WriteFile(shown, []byte(text), 0o644); err != nil {
+		t.Fatalf("write shown config: %v", err)
+	}
+	reread := DefaultConfig()
+	if err := parseTOMLFlat(shown, &reread); err != nil {
+		t.Fatalf("parse shown config: %v\n%s", err, text)
+	}
+	if err := parseTOMLStructured(shown, &reread, "", true); err != nil {
+		t.Fatalf("parse shown windows: %v\n%s", err, text)
+	}
+	if again, _ := renderConfigTOML(reread, origins, true); again != text {
+		t.Fatalf("shown config does not read back:\n%s\nwant:\n%s", again, text)
+	}
+}
+
+func TestDefaultConfigFileParses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte(defaultConfigFile()), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg := DefaultConfig()
+	if err := parseTOMLFlat(path, &cfg); err != nil {
+		t.Fatalf("parse config: %v", err)
+	}
+	if err := parseTOMLStructured(path, &cfg, "", true); err != nil {
+		t.Fatalf("parse structured config: %v", err)
+	}
+	if !reflect.DeepEqual(cfg, DefaultConfig()) {
+		t.Fatalf("default config file changes the defaults: %+v", cfg)
+	}
+}
+
+func TestParseTOMLFlatRepoSection(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	content := `base_branch = "main"
+wip_limit = 4
+
+[repos.app]
+base_branch = "develop" # app branches off develop
+default_agent_type = "claude"
+
+[[repos.app.windows]]
+name = "dev"
+
+[repos."other-app"]
+base_branch = "trunk"
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cfg := DefaultConfig()
+	if err := parseTOMLFlat(path, &cfg); err != nil {
+		t.Fatalf("parse config: %v", err)
+	}
+	if cfg.BaseBranch != "main" || cfg.WIPLimit != 4 || cfg.DefaultAgentType != "codex" {
+		t.Fatalf("repo sections leaked into the top level: %+v", cfg)
+	}
+	if err := parseTOMLFlatTable(path, &cfg, repoConfigTable("app")); err != nil {
+		t.Fatalf("parse repo section: %v", err)
+	}
+	if cfg.BaseBranch != "develop" || cfg.DefaultAgentType != "claude" || cfg.WIPLimit != 4 {
+		t.Fatalf("unexpected config for app: %+v", cfg)
+	}
+
+	other := DefaultConfig()
+	if err := parseTOMLFlatTable(path, &other, repoConfigTable("other-app")); err != nil {
+		t.Fatalf("parse quoted repo section: %v", err)
+	}
+	if other.BaseBranch != "trunk" {
+		t.Fatalf("expected base_branch from [repos.\"other-app\"], got %q", other.BaseBranch)
+	}
+
+	origins := map[string]string{}
+	if err := markConfigFileOrigins(origins, path, "app", false); err != nil {
+		t.Fatalf("mark origins: %v", err)
+	}
+	if want := path + " [repos.app]"; origins["base_branch"] != want || origins["wip_limit"] != path {
+		t.Fatalf("unexpected origins: %v", origins)
+	}
+	if _, ok := origins["name"]; ok {
+		t.Fatalf("window keys recorded as settings: %v", origins)
+	}
+}
+
+func TestParseTOMLFlatHooks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	content := `base_branch = "main"
+
+[hooks]
+on_create = "make deps" # install dependencies
+on_agent_ready = "say ready"
+
+[repos.app.hooks]
+on_create = "npm ci"
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cfg := DefaultConfig()
+	if err := parseTOMLFlat(path, &cfg); err != nil {
+		t.Fatalf("parse config: %v", err)
+	}
+	if cfg.Hooks[hookOnCreate] != "make deps" || cfg.Hooks[hookOnAgentReady] != "say ready" || cfg.BaseBranch != "main" {
+		t.Fatalf("unexpected hooks: %v", cfg.Hooks)
+	}
+	if err := parseTOMLFlatTable(path, &cfg, repoConfigTable("app")); err != nil {
+		t.Fatalf("parse repo section: %v", err)
+	}
+	if cfg.Hooks[hookOnCreate] != "npm ci" || cfg.Hooks[hookOnAgentReady] != "say ready" {
+		t.Fatalf("expected [repos.app.hooks] to override on_create only, got %v", cfg.Hooks)
+	}
+
+	origins := map[string]string{}
+	if err := markConfigFileOrigins(origins, path, "app", false); err != nil {
+		t.Fatalf("mark origins: %v", err)
+	}
+	if want := path + " [repos.app.hooks]"; origins["hooks.on_create"] != want || origins["hooks.on_agent_ready"] != path {
+		t.Fatalf("unexpected origins: %v", origins)
+	}
+
+	t.Setenv("SPROUT_HOOK_ON_ATTACH", "tmux display-message attached")
+	t.Setenv("SPROUT_HOOK_NOT_A_HOOK", "ignored")
+	applyEnvOverrides(&cfg)
+	if cfg.Hooks[hookOnAttach] != "tmux display-message attached" || len(cfg.Hooks) != 3 {
+		t.Fatalf("unexpected hooks after env overrides: %v", cfg.Hooks)
+	}
+
+	rendered, err := renderConfigTOML(cfg, nil, false)
+	if err != nil {
+		t.Fatalf("render config: %v", err)
+	}
+	renderedPath := filepath.Join(t.TempDir(), "rendered.toml")
+	if err := os.WriteFile(renderedPath, []byte(rendered), 0o644); err != nil {
+		t.Fatalf("write rendered config: %v", err)
+	}
+	roundTrip := DefaultConfig()
+	if err := parseTOMLFlat(renderedPath, &roundTrip); err != nil {
+		t.Fatalf("parse rendered config: %v", err)
+	}
+	if len(roundTrip.Hooks) != 3 || roundTrip.Hooks[hookOnCreate] != "npm ci" {
+		t.Fatalf("hooks did not round-trip through config show: %v", roundTrip.Hooks)
+	}
+
+	bad := filepath.Join(t.TempDir(), "bad.toml")
+	if err := os.WriteFile(bad, []byte("[hooks]\non_merge = \"true\"\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := parseTOMLFlat(bad, &cfg); err == nil || !strings.Contains(err.Error(), "unknown hook") {
+		t.Fatalf("expected unknown hook error, got %v", err)
+	}
+}
+
+func TestParseTOMLFlatFilterPresets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	content := `[filters]
+mine = "label:mine"
+dirty = ""
+
+[repos.app.filters]
+review = "agent:ready status:dirty"
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cfg := DefaultConfig()
+	if err := parseTOMLFlat(path, &cfg); err != nil {
+		t.Fatalf("parse config: %v", err)
+	}
+	if err := parseTOMLFlatTable(path, &cfg, repoConfigTable("app")); err != nil {
+		t.Fatalf("parse repo section: %v", err)
+	}
+	want := []string{"agent-ready", "mine", "review"}
+	if got := filterPresetNames(cfg.FilterPresets); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected presets %v, got %v", want, got)
+	}
+
+	origins := map[string]string{}
+	if err := markConfigFileOrigins(origins, path, "app", false); err != nil {
+		t.Fatalf("mark origins: %v", err)
+	}
+	if want := path + " [repos.app.filters]"; origins["filters.review"] != want || origins["filters.mine"] != path {
+		t.Fatalf("unexpected origins: %v", origins)
+	}
+
+	bad := filepath.Join(t.TempDir(), "bad.toml")
+	if err := os.WriteFile(bad, []byte("[filters]\n\"my view\" = \"x\"\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := parseTOMLFlat(bad, &cfg); err == nil {
+		t.Fatalf("expected an invalid preset name to be rejected")
+	}
+}
+
+func TestParseTOMLStructuredPaneEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".sprout.toml")
+	content := "[[windows]]\nname = \"dev\"\nenv = { NODE_ENV = \"development\" }\n\n[[windows.panes]]\nrun = \"pnpm dev\"\nenv = { PORT = \"{port}\" }\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg := DefaultConfig()
+	if err := parseTOMLStructured(path, &cfg, "", true); err != nil {
+		t.Fatalf("parse config: %v", err)
+	}
+	win := cfg.Windows[0]
+	if win.Env["NODE_ENV"] != "development" || win.Panes[0].Env["PORT"] != "{port}" {
+		t.Fatalf("unexpected env: %+v", win)
+	}
+
+	content = "[[windows]]\nname = \"dev\"\n\n[[windows.panes]]\nenv = { \"MY-PORT\" = \"1\" }\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := parseTOMLStructured(path, &cfg, "", true); err == nil {
+		t.Fatal("expected env name MY-PORT to be rejected")
+	}
+}
+
+func TestParseTOMLStructuredPaneSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".sprout.toml")
+	content := "[[windows]]\nname = \"dev\"\n\n[[windows.panes]]\nrun = \"nvim .\"\nsize = \"70%\"\nfocus = true\n\n[[windows.panes]]\nsize = \" 12 \"\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg := DefaultConfig()
+	if err := parseTOMLStructured(path, &cfg, "", true); err != nil {
+		t.Fatalf("parse config: %v", err)
+	}
+	panes := cfg.Windows[0].Panes
+	if panes[0].Size != "70%" || !panes[0].Focus || panes[1].Size != "12" || panes[1].Focus {
+		t.Fatalf("unexpected panes: %+v", panes)
+	}
+
+	for _, bad := range []string{"0%", "100%", "-3", "half"} {
+		content := "[[windows]]\nname = \"dev\"\n\n[[windows.panes]]\nsize = \"" + bad + "\"\n"
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		if err := parseTOMLStructured(path, &cfg, "", true); err == nil {
+			t.Fatalf("expected size %q to be rejected", bad)
+		}
+	}
+}
+
+func TestIsNewerVersionPrerelease(t *testing.T) {
+	cases := []struct {
+		latest, current string
+		want            bool
+	}{
+		{"v1.2.0", "v1.1.9", true},
+		{"v1.2.0", "v1.2.0", false},
+		{"v1.2.0", "v1.2.0-rc.1", true},
+		{"v1.2.0-rc.1", "v1.2.0", false},
+		{"v1.2.0-rc.2", "v1.2.0-rc.1", true},
+		{"v1.2.0-rc.10", "v1.2.0-rc.9", true},
+		{"v1.2.0-rc.1", "v1.2.0-beta.3", true},
+		{"v1.2.0-rc.1.1", "v1.2.0-rc.1", true},
+		{"v1.3.0-alpha", "v1.2.0", true},
+	}
+	for _, tc := range cases {
+		if got := isNewerVersion(tc.latest, tc.current); got != tc.want {
+			t.Errorf("isNewerVersion(%q, %q) = %v, want %v", tc.latest, tc.current, got, tc.want)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte(`update_channel = "Prerelease"`), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg := DefaultConfig()
+	if cfg.UpdateChannel != updateChannelStable {
+		t.Fatalf("expected default update_channel stable, got %q", cfg.UpdateChannel)
+	}
+	if err := parseTOMLFlat(path, &cfg); err != nil {
+		t.Fatalf("parse config: %v", err)
+	}
+	if cfg.UpdateChannel != updateChannelPrerelease {
+		t.Fatalf("expected update_channel prerelease, got %q", cfg.UpdateChannel)
+	}
+	if err := os.WriteFile(path, []byte(`update_channel = "nightly"`), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := parseTOMLFlat(path, &cfg); err == nil {
+		t.Fatalf("expected error for invalid update_channel")
+	}
+}
+
+func TestDetailPollSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte("detail_poll_ms = 100\ndetail_idle_poll_ms = 5000\ndiff_patch_cache_ms = 0\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg := DefaultConfig()
+	if err := parseTOMLFlat(path, &cfg); err != nil {
+		t.Fatalf("parse config: %v", err)
+	}
+	if cfg.DetailPollMS != 100 || cfg.DetailIdlePollMS != 5000 || cfg.DiffPatchCacheMS != 0 || cfg.DetailCaptureLines != defaultDetailCaptureLines {
+		t.Fatalf("unexpected parsed config: %+v", cfg)
+	}
+	t.Setenv("SPROUT_DETAIL_CAPTURE_LINES", "200")
+	applyEnvOverrides(&cfg)
+	if cfg.DetailCaptureLines != 200 {
+		t.Fatalf("expected SPROUT_DETAIL_CAPTURE_LINES to apply, got %d", cfg.DetailCaptureLines)
+	}
+	if err := os.WriteFile(path, []byte("detail_poll_ms = 5\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := parseTOMLFlat(path, &cfg); err == nil {
+		t.Fatalf("expected error for out-of-range detail_poll_ms")
+	}
+
+	fast, slow := 150*time.Millisecond, 2*time.Second
+	interval := fast
+	for i := 0; i < 10; i++ {
+		interval = nextDetailPollInterval(interval, false, fast, slow)
+	}
+	if interval != slow {
+		t.Fatalf("expected idle polling to back off to %v, got %v", slow, interval)
+	}
+	if got := nextDetailPollInterval(interval, true, fast, slow); got != fast {
+		t.Fatalf("expected activity to poll at %v again, got %v", fast, got)
+	}
+	if got := nextDetailPollInterval(fast, false, fast, slow); got != 2*fast {
+		t.Fatalf("expected one idle poll to double the interval, got %v", got)
+	}
+}
@@ -0,0 +1,468 @@
+package sprout
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// configSetting describes a top-level config key for sprout config: its
+// environment variable, a one-line description, the values it accepts when
+// they are a fixed set, and how to read it from a loaded Config.
+type configSetting struct {
+	Key         string
+	Env         string
+	Description string
+	Enum        []string
+	Value       func(cfg Config) any
+}
+
+// configSettings are the top-level config keys, in the order sprout config
+// shows them. The agent_command_<type> keys and [[windows]] come after them.
+var configSettings = []configSetting{
+	{Key: "base_branch", Env: "SPROUT_BASE_BRANCH", Description: "Default base branch for new worktrees", Value: func(c Config) any { return c.BaseBranch }},
+	{Key: "worktree_root_template", Env: "SPROUT_WORKTREE_ROOT_TEMPLATE", Description: "Worktree root directory; {repo} is the repository name", Value: func(c Config) any { return c.WorktreeRootTemplate }},
+	{Key: "worktree_path_template", Env: "SPROUT_WORKTREE_PATH_TEMPLATE", Description: "Directory of each worktree under the root: {branch}, {type}, {slug}, {date}", Value: func(c Config) any { return c.WorktreePathTemplate }},
+	{Key: "flatten_branch_dirs", Env: "SPROUT_FLATTEN_BRANCH_DIRS", Description: "Map branch slashes to dashes in worktree directory names", Value: func(c Config) any { return c.FlattenBranchDirs }},
+	{Key: "worktree_subdir", Env: "SPROUT_WORKTREE_SUBDIR", Description: "Directory inside each worktree where sessions and the agent start", Value: func(c Config) any { return c.WorktreeSubdir }},
+	{Key: "auto_launch", Env: "SPROUT_AUTO_LAUNCH", Description: "Launch a tmux session when creating a worktree", Value: func(c Config) any { return c.AutoLaunch }},
+	{Key: "auto_start_agent", Env: "SPROUT_AUTO_START_AGENT", Description: "Start the agent when creating a worktree", Value: func(c Config) any { return c.AutoStartAgent }},
+	{Key: "copy_untracked_exclude", Env: "SPROUT_COPY_UNTRACKED_EXCLUDE", Description: "Patterns not copied with untracked and ignored files into new worktrees", Value: func(c Config) any { return c.CopyUntrackedExclude }},
+	{Key: "update_check", Env: "SPROUT_UPDATE_CHECK", Description: "Check GitHub for updates once a day", Value: func(c Config) any { return c.UpdateCheck }},
+	{Key: "update_channel", Env: "SPROUT_UPDATE_CHANNEL", Description: "Releases the update check offers: stable only, or prereleases too", Enum: []string{updateChannelStable, updateChannelPrerelease}, Value: func(c Config) any { return c.UpdateChannel }},
+	{Key: "session_tools", Env: "SPROUT_SESSION_TOOLS", Description: "Windows of a new tmux session: agent, lazygit, nvim", Value: func(c Config) any { return c.SessionTools }},
+	{Key: "launch_nvim", Env: "SPROUT_LAUNCH_NVIM", Description: "Open Neovim in new tmux sessions (deprecated: use session_tools)", Value: func(c Config) any { return c.LaunchNvim }},
+	{Key: "launch_lazygit", Env: "SPROUT_LAUNCH_LAZYGIT", Description: "Open Lazygit in new tmux sessions (deprecated: use session_tools)", Value: func(c Config) any { return c.LaunchLazygit }},
+	{Key: "agent_command", Env: "SPROUT_AGENT_COMMAND", Description: "Default agent command (deprecated: use default_agent_type)", Value: func(c Config) any { return c.AgentCommand }},
+	{Key: "agent_args", Env: "SPROUT_AGENT_ARGS", Description: "Extra arguments appended to the agent command", Value: func(c Config) any { return c.AgentArgs }},
+	{Key: "default_agent_type", Env: "SPROUT_DEFAULT_AGENT_TYPE", Description: "Agent type started by default: codex, aider, claude, gemini, or an agent_command_<type>", Value: func(c Config) any { return c.DefaultAgentType }},
+	{Key: "agent_resume_prompt", Env: "SPROUT_AGENT_RESUME_PROMPT", Description: "Prompt sent to agents resumed after a tmux restart: {branch}, {last_prompt}", Value: func(c Config) any { return c.AgentResumePrompt }},
+	{Key: "session_prefix", Env: "SPROUT_SESSION_PREFIX", Description: "Prefix of tmux session names", Value: func(c Config) any { return c.SessionPrefix }},
+	{Key: "session_mode", Env: "SPROUT_SESSION_MODE", Description: "A tmux session per worktree, or one per repository with a window per worktree tool", Enum: []string{sessionModePerWorktree, sessionModePerRepo}, Value: func(c Config) any { return c.SessionMode }},
+	{Key: "slug_mode", Env: "SPROUT_SLUG_MODE", Description: "How non-ASCII letters in names become branch slugs", Enum: []string{slugModeASCII, slugModeUnicode}, Value: func(c Config) any { return c.SlugMode }},
+	{Key: "branch_types", Env: "SPROUT_BRANCH_TYPES", Description: "Types accepted by sprout new; empty accepts any", Value: func(c Config) any { return c.BranchTypes }},
+	{Key: "branch_template", Env: "SPROUT_BRANCH_TEMPLATE", Description: "How sprout new builds branch names: {type}, {slug}, {user}, {date}", Value: func(c Config) any { return c.BranchTemplate }},
+	{Key: "branch_pattern", Env: "SPROUT_BRANCH_PATTERN", Description: "Regular expression every new branch must match", Value: func(c Config) any { return c.BranchPattern }},
+	{Key: "git_backend", Env: "SPROUT_GIT_BACKEND", Description: "How worktree status and branches are read", Enum: []string{gitBackendExec, gitBackendGoGit}, Value: func(c Config) any { return c.GitBackend }},
+	{Key: "dirty_untracked", Env: "SPROUT_DIRTY_UNTRACKED", Description: "Whether untracked files make a worktree dirty in listings: every refresh, once a minute, or never", Enum: []string{dirtyUntrackedAll, dirtyUntrackedPeriodic, dirtyUntrackedNo}, Value: func(c Config) any { return c.DirtyUntracked }},
+	{Key: "log_level", Env: "SPROUT_DEBUG", Description: "Debug log verbosity", Enum: []string{"error", "info", "debug", "trace"}, Value: func(c Config) any { return c.LogLevel }},
+	{Key: "wip_limit", Env: "SPROUT_WIP_LIMIT", Description: "Linked worktrees allowed before creation asks to finish one; 0 is unlimited", Value: func(c Config) any { return c.WIPLimit }},
+	{Key: "on_quit", Env: "SPROUT_ON_QUIT", Description: "What quitting the TUI does with running agents", Enum: []string{quitActionNone, quitActionAsk, quitActionStopAgents, quitActionDetach}, Value: func(c Config) any { return c.OnQuit }},
+	{Key: "launch_backend", Env: "SPROUT_LAUNCH_BACKEND", Description: "Where worktrees and agents are launched", Enum: []string{launchBackendTmux, launchBackendWT, launchBackendNone}, Value: func(c Config) any { return c.LaunchBackend }},
+	{Key: "notify_desktop", Env: "SPROUT_NOTIFY_DESKTOP", Description: "Agent events shown as desktop notifications: ready, exited, idle", Value: func(c Config) any { return c.NotifyDesktop }},
+	{Key: "notify_bell", Env: "SPROUT_NOTIFY_BELL", Description: "Agent events that ring the terminal bell: ready, exited, idle", Value: func(c Config) any { return c.NotifyBell }},
+	{Key: "notify_webhook", Env: "SPROUT_NOTIFY_WEBHOOK", Description: "URL that receives a JSON POST for agent events", Value: func(c Config) any { return c.NotifyWebhook }},
+	{Key: "notify_webhook_events", Env: "SPROUT_NOTIFY_WEBHOOK_EVENTS", Description: "Agent events posted to notify_webhook", Value: func(c Config) any { return c.NotifyWebhookEvents }},
+	{Key: "agent_idle_minutes", Env: "SPROUT_AGENT_IDLE_MINUTES", Description: "Minutes an agent may wait for input before the idle warning; 0 disables it", Value: func(c Config) any { return c.AgentIdleMinutes }},
+	{Key: "auto_fetch_minutes", Env: "SPROUT_AUTO_FETCH_MINUTES", Description: "Minutes between background fetches of the repository while the TUI runs; 0 disables it", Value: func(c Config) any { return c.AutoFetchMinutes }},
+	{Key: "agent_exit_keys", Env: "SPROUT_AGENT_EXIT_KEYS", Description: "tmux keys asking an agent to exit before it is stopped", Value: func(c Config) any { return c.AgentExitKeys }},
+	{Key: "agent_exit_wait", Env: "SPROUT_AGENT_EXIT_WAIT", Description: "Seconds to wait for an agent to exit after agent_exit_keys", Value: func(c Config) any { return c.AgentExitWait }},
+	{Key: "undo_minutes", Env: "SPROUT_UNDO_MINUTES", Description: "Minutes sprout undo can bring a removed worktree back; 0 disables it", Value: func(c Config) any { return c.UndoMinutes }},
+	{Key: "export_dir", Env: "SPROUT_EXPORT_DIR", Description: "Where the TUI writes exported transcripts and patches", Value: func(c Config) any { return c.ExportDir }},
+	{Key: "ssh_host", Env: "SPROUT_SSH_HOST", Description: "Host whose repository sprout manages over ssh", Value: func(c Config) any { return c.SSHHost }},
+	{Key: "ssh_repo", Env: "SPROUT_SSH_REPO", Description: "Path of the repository on ssh_host", Value: func(c Config) any { return c.SSHRepo }},
+	{Key: "container_command", Env: "SPROUT_CONTAINER_COMMAND", Description: "Prefix running session commands in the worktree's container", Value: func(c Config) any { return c.ContainerCommand }},
+	{Key: "container_up", Env: "SPROUT_CONTAINER_UP", Description: "Starts the worktree's container before its session", Value: func(c Config) any { return c.ContainerUp }},
+	{Key: "container_down", Env: "SPROUT_CONTAINER_DOWN", Description: "Stops the worktree's container after its session", Value: func(c Config) any { return c.ContainerDown }},
+	{Key: "check_command", Env: "SPROUT_CHECK_COMMAND", Description: "Test or lint command sprout check runs in a worktree", Value: func(c Config) any { return c.CheckCommand }},
+	{Key: "color", Env: "SPROUT_COLOR", Description: "When to use color; NO_COLOR disables it", Enum: []string{colorAuto, colorAlways, colorNever}, Value: func(c Config) any { return c.Color }},
+	{Key: "theme", Env: "SPROUT_THEME", Description: "Color palette", Enum: []string{themeDark, themeLight}, Value: func(c Config) any { return c.Theme }},
+	{Key: "show_resources", Env: "SPROUT_SHOW_RESOURCES", Description: "Show CPU and memory of each worktree's tmux session in the TUI", Value: func(c Config) any { return c.ShowResources }},
+	{Key: "repo_sidebar", Env: "SPROUT_REPO_SIDEBAR", Description: "Show the repositories beside the current one in a TUI sidebar", Value: func(c Config) any { return c.RepoSidebar }},
+	{Key: "columns", Env: "SPROUT_COLUMNS", Description: "Worktree table columns, in order; empty uses the built-in layout", Value: func(c Config) any { return c.Columns }},
+	{Key: "path_display", Env: "SPROUT_PATH_DISPLAY", Description: "How table paths are shown", Enum: []string{pathDisplayAbsolute, pathDisplayHome, pathDisplayRelative}, Value: func(c Config) any { return c.PathDisplay }},
+	{Key: "sort", Env: "SPROUT_SORT", Description: "Worktree order for sprout list and the TUI", Enum: []string{sortPath, sortActive, sortIdle}, Value: func(c Config) any { return c.Sort }},
+	{Key: "details_percent", Env: "SPROUT_DETAILS_PERCENT", Description: "Share of the TUI height given to the Details pane (10-90)", Value: func(c Config) any { return c.DetailsPercent }},
+	{Key: "detail_poll_ms", Env: "SPROUT_DETAIL_POLL_MS", Description: "Milliseconds between Details pane updates while the selected agent is busy (20-5000)", Value: func(c Config) any { return c.DetailPollMS }},
+	{Key: "detail_idle_poll_ms", Env: "SPROUT_DETAIL_IDLE_POLL_MS", Description: "Slowest the Details pane updates once nothing changes (20-60000)", Value: func(c Config) any { return c.DetailIdlePollMS }},
+	{Key: "detail_capture_lines", Env: "SPROUT_DETAIL_CAPTURE_LINES", Description: "Most lines of agent output the Details pane captures (20-2000)", Value: func(c Config) any { return c.DetailCaptureLines }},
+	{Key: "diff_files_cache_ms", Env: "SPROUT_DIFF_FILES_CACHE_MS", Description: "Milliseconds the GIT DIFF tab reuses its list of changed files (0-60000)", Value: func(c Config) any { return c.DiffFilesCacheMS }},
+	{Key: "diff_patch_cache_ms", Env: "SPROUT_DIFF_PATCH_CACHE_MS", Description: "Milliseconds the GIT DIFF tab reuses a file's patch (0-60000)", Value: func(c Config) any { return c.DiffPatchCacheMS }},
+}
+
+const (
+	agentCommandKeyPrefix = "agent_command_"
+	agentCommandEnvPrefix = "SPROUT_AGENT_COMMAND_"
+	configOriginDefault   = "default"
+)
+
+// configEnvSet reports whether an environment variable overrides a setting.
+// Lists can be overridden with an empty value, which clears them.
+func configEnvSet(env string, value any) bool {
+	v, ok := os.LookupEnv(env)
+	if _, isList := value.([]string); isList {
+		return ok
+	}
+	return v != ""
+}
+
+// markConfigFileOrigins records path as the origin of the keys a config file
+// sets, including agent_command_<type> keys, its [hooks], [filters] and
+// [[windows]].
+// The global file also sets them per repository under [repos.<repo>], which
+// is noted after the path.
+func markConfigFileOrigins(origins map[string]string, path, repoName string, isRepoConfig bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	repoTable := ""
+	if !isRepoConfig && repoName != "" {
+		repoTable = repoConfigTable(repoName)
+	}
+	s := bufio.NewScanner(f)
+	current := ""
+	for s.Scan() {
+		line := stripComment(strings.TrimSpace(s.Text()))
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			current = tomlTableName(line)
+			continue
+		}
+		key, _, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		switch {
+		case current == "":
+			origins[key] = path
+		case repoTable != "" && current == repoTable:
+			origins[key] = path + " [" + repoTable + "]"
+		case current == hooksTable(""):
+			origins[hookConfigKey(key)] = path
+		case repoTable != "" && current == hooksTable(repoTable):
+			origins[hookConfigKey(key)] = path + " [" + hooksTable(repoTable) + "]"
+		case current == filtersTable(""):
+			origins[filtersTable("")+"."+key] = path
+		case repoTable != "" && current == filtersTable(repoTable):
+			origins[filtersTable("")+"."+key] = path + " [" + filtersTable(repoTable) + "]"
+		}
+	}
+	if err := s.Err(); err != nil {
+		return err
+	}
+	var cfg Config
+	if err := parseTOMLStructured(path, &cfg, repoName, isRepoConfig); err != nil {
+		return err
+	}
+	if len(cfg.Windows) > 0 {
+		origins["windows"] = path
+	}
+	return nil
+}
+
+// markConfigEnvOrigins records the environment variables that override
+// settings of cfg.
+func markConfigEnvOrigins(origins map[string]string, cfg Config) {
+	for _, setting := range configSettings {
+		if configEnvSet(setting.Env, setting.Value(cfg)) {
+			origins[setting.Key] = setting.Env
+		}
+	}
+	if os.Getenv("NO_COLOR") != "" && os.Getenv("SPROUT_COLOR") == "" {
+		origins["color"] = "NO_COLOR"
+	}
+	for _, entry := range os.Environ() {
+		name, _, _ := strings.Cut(entry, "=")
+		if agentType, ok := strings.CutPrefix(name, agentCommandEnvPrefix); ok && agentType != "" {
+			origins[agentCommandKeyPrefix+strings.ToLower(agentType)] = name
+		}
+		if hook, ok := strings.CutPrefix(name, hookEnvPrefix); ok {
+			if _, err := parseHookName(hook); err == nil {
+				origins[hookConfigKey(hook)] = name
+			}
+		}
+	}
+}
+
+// configEntry is one effective setting shown by sprout config show.
+type configEntry struct {
+	Key    string
+	Value  any
+	Origin string
+}
+
+// configOrigin returns where the setting key came from, or
+// configOriginDefault.
+func configOrigin(origins map[string]string, key string) string {
+	if o := origins[key]; o != "" {
+		return o
+	}
+	return configOriginDefault
+}
+
+// effectiveConfigEntries lists the settings of cfg with where each came
+// from: a config file, an environment variable, or the default.
+func effectiveConfigEntries(cfg Config, origins map[string]string) []configEntry {
+	origin := func(key string) string { return configOrigin(origins, key) }
+	entries := make([]configEntry, 0, len(configSettings)+len(cfg.AgentCommands))
+	for _, setting := range configSettings {
+		entries = append(entries, configEntry{Key: setting.Key, Value: setting.Value(cfg), Origin: origin(setting.Key)})
+	}
+	types := make([]string, 0, len(cfg.AgentCommands))
+	for agentType := range cfg.AgentCommands {
+		types = append(types, agentType)
+	}
+	sort.Strings(types)
+	for _, agentType := range types {
+		key := agentCommandKeyPrefix + agentType
+		entries = append(entries, configEntry{Key: key, Value: cfg.AgentCommands[agentType], Origin: origin(key)})
+	}
+	return entries
+}
+
+// tomlConfigValue renders a setting's value the way parseTOMLFlat reads it.
+func tomlConfigValue(value any) string {
+	switch v := value.(type) {
+	case string:
+		return strconv.Quote(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case int:
+		return strconv.Itoa(v)
+	case []string:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = strconv.Quote(item)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	}
+	return fmt.Sprint(value)
+}
+
+// renderConfigTOML writes the effective config as a config file, with each
+// setting's origin as a trailing comment when withOrigin is set.
+func renderConfigTOML(cfg Config, origins map[string]string, withOrigin bool) (string, error) {
+	var b strings.Builder
+	for _, entry := range effectiveConfigEntries(cfg, origins) {
+		line := entry.Key + " = " + tomlConfigValue(entry.Value)
+		if withOrigin {
+			line += "  # " + entry.Origin
+		}
+		b.WriteString(line + "\n")
+	}
+	writeConfigTable(&b, "hooks", sortedHooks(cfg.Hooks), cfg.Hooks, origins, withOrigin)
+	writeConfigTable(&b, "filters", sortedKeys(cfg.FilterPresets), cfg.FilterPresets, origins, withOrigin)
+	if len(cfg.Windows) > 0 {
+		if withOrigin {
+			fmt.Fprintf(&b, "\n# windows: %s\n", origins["windows"])
+		} else {
+			b.WriteString("\n")
+		}
+		var buf bytes.Buffer
+		if err := toml.NewEncoder(&buf).Encode(struct {
+			Windows []WindowConfig `toml:"windows"`
+		}{cfg.Windows}); err != nil {
+			return "", err
+		}
+		b.Write(buf.Bytes())
+	}
+	return b.String(), nil
+}
+
+// writeConfigTable writes a [table] of string settings, in the order of
+// names. The origin of each is recorded under "<table>.<name>".
+func writeConfigTable(b *strings.Builder, table string, names []string, values, origins map[string]string, withOrigin bool) {
+	if len(names) == 0 {
+		return
+	}
+	b.WriteString("\n[" + table + "]\n")
+	for _, name := range names {
+		line := name + " = " + tomlConfigValue(values[name])
+		if withOrigin {
+			line += "  # " + configOrigin(origins, table+"."+name)
+		}
+		b.WriteString(line + "\n")
+	}
+}
+
+// configTableJSON is a table of string settings as a JSON object, like
+// configJSON.
+func configTableJSON(table string, values, origins map[string]string, withOrigin bool) map[string]any {
+	out := map[string]any{}
+	for name, value := range values {
+		if withOrigin {
+			out[name] = map[string]any{"value": value, "origin": configOrigin(origins, table+"."+name)}
+		} else {
+			out[name] = value
+		}
+	}
+	return out
+}
+
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// configJSON is the effective config as a JSON object. With withOrigin each
+// value becomes {"value": ..., "origin": ...}.
+func configJSON(cfg Config, origins map[string]string, withOrigin bool) map[string]any {
+	out := map[string]any{}
+	for _, entry := range effectiveConfigEntries(cfg, origins) {
+		if withOrigin {
+			out[entry.Key] = map[string]any{"value": entry.Value, "origin": entry.Origin}
+		} else {
+			out[entry.Key] = entry.Value
+		}
+	}
+	if len(cfg.Hooks) > 0 {
+		out["hooks"] = configTableJSON("hooks", cfg.Hooks, origins, withOrigin)
+	}
+	if len(cfg.FilterPresets) > 0 {
+		out["filters"] = configTableJSON("filters", cfg.FilterPresets, origins, withOrigin)
+	}
+	if len(cfg.Windows) > 0 {
+		if withOrigin {
+			out["windows"] = map[string]any{"value": cfg.Windows, "origin": origins["windows"]}
+		} else {
+			out["windows"] = cfg.Windows
+		}
+	}
+	return out
+}
+
+// defaultConfigFile is the file sprout config init writes: every setting
+// commented out at its default, under its description, so the file documents
+// what can be set and defaults that change later still apply.
+func defaultConfigFile() string {
+	cfg := DefaultConfig()
+	var b strings.Builder
+	b.WriteString("# sprout configuration. Uncomment a setting to change it; run\n")
+	b.WriteString("# sprout config show --origin to see the effective values.\n")
+	for _, setting := range configSettings {
+		b.WriteString("\n# " + setting.Description)
+		if len(setting.Enum) > 0 {
+			b.WriteString(" (" + strings.Join(setting.Enum, ", ") + ")")
+		}
+		b.WriteString(". Env: " + setting.Env + "\n")
+		b.WriteString("# " + setting.Key + " = " + tomlConfigValue(setting.Value(cfg)) + "\n")
+	}
+	b.WriteString("\n# Command of an agent type, for default_agent_type or sprout agent start --type.\n")
+	b.WriteString("# agent_command_claude = \"claude\"\n")
+	b.WriteString("\n# Shell commands run in the background on worktree events, with the worktree in\n")
+	b.WriteString("# SPROUT_WORKTREE, SPROUT_BRANCH and SPROUT_REPO. Env: SPROUT_HOOK_<NAME>\n")
+	b.WriteString("# [hooks]\n# on_create = \"make deps\"\n# on_remove = \"\"\n# on_agent_ready = \"\"\n# on_attach = \"\"\n")
+	b.WriteString("\n# Named worktree filters for sprout list --filter and the TUI's f key; set one to\n")
+	b.WriteString("# \"\" to drop it.\n")
+	b.WriteString("# [filters]\n")
+	for _, name := range sortedKeys(cfg.FilterPresets) {
+		b.WriteString("# " + name + " = " + tomlConfigValue(cfg.FilterPresets[name]) + "\n")
+	}
+	b.WriteString("# mine = \"label:mine\"\n")
+	b.WriteString("\n# Windows of new tmux sessions, replacing session_tools.\n")
+	b.WriteString("# [[windows]]\n# name = \"dev\"\n# layout = \"main-vertical\"\n#\n# [[windows.panes]]\n# run = \"nvim .\"\n")
+	return b.String()
+}
+
+// configSchema is a JSON Schema of the config file, for editors that
+// validate TOML against one.
+func configSchema() map[string]any {
+	cfg := DefaultConfig()
+	properties := map[string]any{}
+	for _, setting := range configSettings {
+		value := setting.Value(cfg)
+		prop := map[string]any{"description": setting.Description, "default": value}
+		switch value.(type) {
+		case string:
+			prop["type"] = "string"
+		case bool:
+			prop["type"] = "boolean"
+		case int:
+			prop["type"] = "integer"
+			prop["minimum"] = 0
+			if setting.Key == "details_percent" {
+				prop["minimum"], prop["maximum"] = minDetailsPercent, maxDetailsPercent
+			}
+			if bounds, ok := detailSettingBounds[setting.Key]; ok {
+				prop["minimum"], prop["maximum"] = bounds[0], bounds[1]
+			}
+		case []string:
+			prop["type"] = "array"
+			prop["items"] = map[string]any{"type": "string"}
+		}
+		if len(setting.Enum) > 0 {
+			prop["enum"] = setting.Enum
+		}
+		properties[setting.Key] = prop
+	}
+	env := map[string]any{
+		"type":                 "object",
+		"propertyNames":        map[string]any{"pattern": envNameRe.String()},
+		"additionalProperties": map[string]any{"type": "string"},
+		"description":          "Environment variables; values can use {branch}, {slug}, {repo}, {worktree} and {port}",
+	}
+	window := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"name":   map[string]any{"type": "string"},
+			"layout": map[string]any{"type": "string", "description": "tmux layout, such as main-vertical or tiled"},
+			"dir":    map[string]any{"type": "string"},
+			"env":    env,
+			"panes": map[string]any{"type": "array", "items": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"dir":   map[string]any{"type": "string"},
+					"run":   map[string]any{"type": "string"},
+					"size":  map[string]any{"type": "string", "pattern": `^([1-9][0-9]?%|[1-9][0-9]*)$`, "description": "Width or height along the window's split: a percentage such as 30% or a number of cells"},
+					"focus": map[string]any{"type": "boolean", "description": "Select this pane once the window is built"},
+					"env":   env,
+				},
+			}},
+		},
+	}
+	windows := map[string]any{"type": "array", "description": "Windows of new tmux sessions", "items": window}
+	properties["windows"] = windows
+	hookProperties := map[string]any{}
+	for _, name := range hookNames {
+		hookProperties[name] = map[string]any{"type": "string"}
+	}
+	properties["filters"] = map[string]any{
+		"type":                 "object",
+		"description":          "Named worktree filters for sprout list --filter and the TUI",
+		"propertyNames":        map[string]any{"pattern": filterPresetNameRe.String()},
+		"additionalProperties": map[string]any{"type": "string"},
+		"default":              cfg.FilterPresets,
+	}
+	properties["hooks"] = map[string]any{
+		"type":                 "object",
+		"description":          "Shell commands run in the background on worktree events",
+		"properties":           hookProperties,
+		"additionalProperties": false,
+	}
+	repoProperties := make(map[string]any, len(properties))
+	for key, prop := range properties {
+		repoProperties[key] = prop
+	}
+	properties["repos"] = map[string]any{
+		"type":        "object",
+		"description": "Settings of one repository, by directory name, in the global config",
+		"additionalProperties": map[string]any{
+			"type":       "object",
+			"properties": repoProperties,
+		},
+	}
+	return map[string]any{
+		"$schema":    "https://json-schema.org/draft/2020-12/schema",
+		"title":      "sprout configuration",
+		"type":       "object",
+		"properties": properties,
+		"patternProperties": map[string]any{
+			"^" + agentCommandKeyPrefix + "[a-z0-9_-]+$": map[string]any{"type": "string", "description": "Command of an agent type"},
+		},
+	}
+}
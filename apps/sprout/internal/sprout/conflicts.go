@@ -0,0 +1,39 @@
+package sprout
+
+import "strings"
+
+// BranchConflicts dry-runs merging a worktree's branch into the base branch
+// with git merge-tree, which leaves the index and working tree alone, and
+// returns the files that would conflict. Only commits count; uncommitted
+// changes are not part of the merge. The result is nil, rather than empty,
+// for the base branch itself and detached worktrees, which have nothing to
+// merge. It needs git 2.38 or newer.
+func (m *Manager) BranchConflicts(repoRoot string, wt *Worktree) ([]string, error) {
+	base, err := m.ResolveBaseBranch(repoRoot, "")
+	if err != nil {
+		return nil, err
+	}
+	if wt.Branch == "" || wt.Branch == base {
+		return nil, nil
+	}
+	return m.mergeConflicts(wt.Path, base, "HEAD")
+}
+
+// parseMergeTreeConflicts reads the conflicted files that git merge-tree
+// --name-only lists after the tree it wrote.
+func parseMergeTreeConflicts(out string) []string {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	files := []string{}
+	seen := map[string]bool{}
+	for _, line := range lines[1:] {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		if !seen[line] {
+			seen[line] = true
+			files = append(files, line)
+		}
+	}
+	return files
+}
@@ -0,0 +1,81 @@
+package sprout
+
+import (
+	"context"
+	"strings"
+)
+
+// containerShell starts an interactive shell in a container that may not
+// have bash.
+const containerShell = `command -v bash >/dev/null && exec bash || exec sh`
+
+// containerEnabled reports whether worktree sessions run in containers.
+func (m *Manager) containerEnabled() bool {
+	return strings.TrimSpace(m.Cfg.ContainerCommand) != ""
+}
+
+// expandContainerTemplate fills in {worktree}, the shell-quoted worktree
+// path, and {name}, a name for the worktree's container made from name,
+// see containerName.
+func expandContainerTemplate(tmpl, name, worktreePath string) string {
+	out := strings.ReplaceAll(tmpl, "{worktree}", shellQuote(worktreePath))
+	return strings.ReplaceAll(out, "{name}", safeName(name))
+}
+
+// containerize wraps a tmux window or pane command to run inside the
+// worktree's container through container_command, or returns it unchanged
+// when sessions do not use containers. An empty command, or the user's
+// shell, becomes a shell in the container.
+func (m *Manager) containerize(session, worktreePath, command string) string {
+	if !m.containerEnabled() {
+		return command
+	}
+	command = strings.TrimSpace(command)
+	if command == "" || command == defaultShellCommand() {
+		command = containerShell
+	}
+	return expandContainerTemplate(m.Cfg.ContainerCommand, m.containerName(session, worktreePath), worktreePath) + " sh -c " + shellQuote(command)
+}
+
+// containerUpCommand is the command that starts a worktree's container:
+// container_up, or devcontainer up for a devcontainer exec
+// container_command.
+func (m *Manager) containerUpCommand() string {
+	if up := strings.TrimSpace(m.Cfg.ContainerUp); up != "" {
+		return up
+	}
+	if strings.HasPrefix(strings.TrimSpace(m.Cfg.ContainerCommand), "devcontainer exec") {
+		return "devcontainer up --workspace-folder {worktree}"
+	}
+	return ""
+}
+
+// containerUp starts the container of a worktree before its session is
+// created, so the session's commands have something to exec into.
+func (m *Manager) containerUp(session, worktreePath string) error {
+	if !m.containerEnabled() {
+		return nil
+	}
+	up := m.containerUpCommand()
+	if up == "" {
+		return nil
+	}
+	if m.preview != nil {
+		m.preview.record("sh", "-c", expandContainerTemplate(up, m.containerName(session, worktreePath), worktreePath))
+		return nil
+	}
+	infoLogf("container up session=%q path=%q", session, worktreePath)
+	_, err := m.runCmdBytesContext(context.Background(), worktreePath, gitWorktreeCommandTimeout(), "sh", "-c", expandContainerTemplate(up, m.containerName(session, worktreePath), worktreePath))
+	return err
+}
+
+// containerDown stops the container of a worktree after its session is
+// killed, when container_down is set.
+func (m *Manager) containerDown(session, worktreePath string) error {
+	down := strings.TrimSpace(m.Cfg.ContainerDown)
+	if !m.containerEnabled() || down == "" {
+		return nil
+	}
+	infoLogf("container down session=%q path=%q", session, worktreePath)
+	return m.runCmdQuiet(worktreePath, "sh", "-c", expandContainerTemplate(down, m.containerName(session, worktreePath), worktreePath))
+}
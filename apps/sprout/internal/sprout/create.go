@@ -0,0 +1,178 @@
+package sprout
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Fields of a worktree creation request that validation can reject.
+const (
+	createFieldType   = "type"
+	createFieldName   = "name"
+	createFieldBranch = "branch"
+	createFieldBase   = "base"
+	createFieldPath   = "path"
+)
+
+// Validation error codes.
+const (
+	createErrRequired       = "required"
+	createErrInvalidType    = "invalid_type"
+	createErrEmptySlug      = "empty_slug"
+	createErrInvalidBranch  = "invalid_branch"
+	createErrBranchPattern  = "branch_pattern"
+	createErrBranchExists   = "branch_exists"
+	createErrBranchNotFound = "branch_not_found"
+	createErrBaseNotFound   = "base_not_found"
+	createErrPathExists     = "path_exists"
+)
+
+// FieldError is a validation failure of one field of a creation request.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every field a creation request failed on.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		parts = append(parts, f.Message)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Field returns the error of field, if it has one.
+func (e *ValidationError) Field(field string) (FieldError, bool) {
+	for _, f := range e.Fields {
+		if f.Field == field {
+			return f, true
+		}
+	}
+	return FieldError{}, false
+}
+
+func (e *ValidationError) add(field, code, format string, args ...any) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Code: code, Message: fmt.Sprintf(format, args...)})
+}
+
+// CreateRequest is a validated NewOptions with the branch, path, and base it
+// resolves to.
+type CreateRequest struct {
+	Options  NewOptions
+	RepoRoot string
+	Branch   string
+	Path     string
+	// Base is the branch a new branch starts from; empty for existing branches.
+	Base     string
+	Existing bool
+	// Remote is set when Branch exists only on that remote: creating the
+	// request makes a local branch tracking it.
+	Remote string
+	// ExistingPath is the worktree already checked out on Branch, if any.
+	// Creating the request then just returns it.
+	ExistingPath string
+}
+
+// ValidateNew resolves opts into a CreateRequest without touching the
+// repository. Problems with the request itself come back together as a
+// *ValidationError; other failures, like not being in a repository, are
+// returned as is.
+func (m *Manager) ValidateNew(opts NewOptions) (*CreateRequest, error) {
+	repoRoot, err := m.RequireRepo()
+	if err != nil {
+		return nil, err
+	}
+	req := &CreateRequest{Options: opts, RepoRoot: repoRoot, Existing: opts.FromBranch != ""}
+	verr := &ValidationError{}
+
+	branch := strings.TrimSpace(opts.Branch)
+	branchField := createFieldBranch
+	if req.Existing {
+		branch = strings.TrimSpace(opts.FromBranch)
+	}
+	if branch == "" && !req.Existing {
+		if opts.Type == "" && opts.Name == "" {
+			verr.add(createFieldBranch, createErrRequired, "branch name is required")
+		} else {
+			branchField = createFieldName
+			if !m.validBranchType(opts.Type) {
+				verr.add(createFieldType, createErrInvalidType, "%s", m.invalidBranchTypeError(opts.Type))
+			}
+			if slug, err := m.Slugify(opts.Name); err != nil {
+				hint := ""
+				if m.Cfg.SlugMode != slugModeUnicode && hasNonASCIILetter(opts.Name) {
+					hint = ` (set slug_mode = "unicode" to keep non-ASCII letters)`
+				}
+				verr.add(createFieldName, createErrEmptySlug, "feature name %q resolves to an empty slug%s", opts.Name, hint)
+			} else {
+				branch = m.expandBranchTemplate(repoRoot, opts.Type, slug)
+			}
+		}
+	} else if branch == "" {
+		verr.add(createFieldBranch, createErrRequired, "branch name is required")
+	}
+	if len(verr.Fields) > 0 {
+		return nil, verr
+	}
+	req.Branch = branch
+	req.Path = m.worktreePath(repoRoot, branch)
+
+	if existingPath, exists, err := m.findExistingWorktreePath(repoRoot, branch, req.Path); err == nil && exists {
+		req.ExistingPath = existingPath
+		return req, nil
+	}
+
+	if err := m.runCmdQuiet(repoRoot, "git", "check-ref-format", "--branch", branch); err != nil {
+		verr.add(branchField, createErrInvalidBranch, "%q is not a valid branch name", branch)
+	} else if req.Existing {
+		if !m.BranchExists(repoRoot, branch) {
+			if req.Remote = m.branchRemote(repoRoot, branch); req.Remote == "" {
+				verr.add(createFieldBranch, createErrBranchNotFound, "branch not found: %s", branch)
+			}
+		}
+	} else if err := m.checkBranchPattern(branch); err != nil {
+		verr.add(branchField, createErrBranchPattern, "%v", err)
+	} else if m.BranchExists(repoRoot, branch) {
+		verr.add(branchField, createErrBranchExists, "branch already exists: %s (create from the existing branch instead)", branch)
+	}
+	if !req.Existing {
+		base, err := m.ResolveBaseBranch(repoRoot, opts.BaseBranch)
+		if err != nil {
+			verr.add(createFieldBase, createErrBaseNotFound, "%v", err)
+		}
+		req.Base = base
+	}
+	if exists, err := m.pathExists(req.Path); err != nil {
+		return nil, err
+	} else if exists {
+		verr.add(createFieldPath, createErrPathExists, "target path already exists: %s", req.Path)
+	}
+
+	if len(verr.Fields) > 0 {
+		return nil, verr
+	}
+	return req, nil
+}
+
+// branchRemote returns the remote that has a branch named branch, preferring
+// origin when several do, or "" when none has it.
+func (m *Manager) branchRemote(repoRoot, branch string) string {
+	found := ""
+	for _, ref := range m.branchRefs(repoRoot, true) {
+		if remote, name, ok := strings.Cut(ref.Name, "/"); ok && name == branch {
+			if remote == "origin" {
+				return remote
+			}
+			if found == "" {
+				found = remote
+			}
+		}
+	}
+	return found
+}
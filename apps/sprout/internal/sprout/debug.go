@@ -1,15 +1,69 @@
 package sprout
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-var debugLogMu sync.Mutex
+type logLevel int32
+
+// Log levels, from least to most verbose.
+const (
+	logLevelError logLevel = iota
+	logLevelInfo
+	logLevelDebug
+	logLevelTrace
+)
+
+var logLevelNames = []string{"error", "info", "debug", "trace"}
+
+const debugLogTailBytes = 512 * 1024
+
+func (l logLevel) String() string {
+	if l < 0 || int(l) >= len(logLevelNames) {
+		return "unknown"
+	}
+	return logLevelNames[l]
+}
+
+func parseLogLevel(s string) (logLevel, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "error":
+		return logLevelError, true
+	case "info":
+		return logLevelInfo, true
+	case "debug", "1", "true":
+		return logLevelDebug, true
+	case "trace":
+		return logLevelTrace, true
+	}
+	return logLevelInfo, false
+}
+
+var (
+	debugLogMu     sync.Mutex
+	activeLogLevel atomic.Int32
+)
+
+func init() {
+	activeLogLevel.Store(int32(logLevelInfo))
+}
+
+// setLogLevel sets the most verbose level written to the debug log. Unknown
+// names leave the current level unchanged.
+func setLogLevel(name string) {
+	if level, ok := parseLogLevel(name); ok {
+		activeLogLevel.Store(int32(level))
+	}
+}
 
 func debugLogFilePath() string {
 	if v := strings.TrimSpace(os.Getenv("SPROUT_DEBUG_LOG")); v != "" {
@@ -18,7 +72,15 @@ func debugLogFilePath() string {
 	return filepath.Join(os.TempDir(), "sprout-debug.log")
 }
 
-func debugLogf(format string, args ...any) {
+func errorLogf(format string, args ...any) { writeLogf(logLevelError, format, args...) }
+func infoLogf(format string, args ...any)  { writeLogf(logLevelInfo, format, args...) }
+func debugLogf(format string, args ...any) { writeLogf(logLevelDebug, format, args...) }
+func traceLogf(format string, args ...any) { writeLogf(logLevelTrace, format, args...) }
+
+func writeLogf(level logLevel, format string, args ...any) {
+	if level > logLevel(activeLogLevel.Load()) {
+		return
+	}
 	path := debugLogFilePath()
 	if strings.TrimSpace(path) == "" {
 		return
@@ -27,7 +89,7 @@ func debugLogf(format string, args ...any) {
 		return
 	}
 
-	line := fmt.Sprintf("%s %s\n", time.Now().Format(time.RFC3339Nano), fmt.Sprintf(format, args...))
+	line := fmt.Sprintf("%s %-5s %s\n", time.Now().Format(time.RFC3339Nano), strings.ToUpper(level.String()), fmt.Sprintf(format, args...))
 
 	debugLogMu.Lock()
 	defer debugLogMu.Unlock()
@@ -39,3 +101,57 @@ func debugLogf(format string, args ...any) {
 	_, _ = f.WriteString(line)
 	_ = f.Close()
 }
+
+// logLineLevel extracts the level written by writeLogf. Lines from older
+// versions without a level are treated as debug output.
+func logLineLevel(line string) logLevel {
+	fields := strings.Fields(line)
+	if len(fields) >= 2 {
+		for level, name := range logLevelNames {
+			if fields[1] == strings.ToUpper(name) {
+				return logLevel(level)
+			}
+		}
+	}
+	return logLevelDebug
+}
+
+// tailDebugLog returns up to limit of the most recent log lines at or below
+// maxLevel. Only the last debugLogTailBytes of the file are read.
+func tailDebugLog(limit int, maxLevel logLevel) ([]string, error) {
+	f, err := os.Open(debugLogFilePath())
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	offset := st.Size() - debugLogTailBytes
+	if offset < 0 {
+		offset = 0
+	}
+	data := make([]byte, st.Size()-offset)
+	if _, err := f.ReadAt(data, offset); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if offset > 0 {
+		// Drop the partial first line.
+		if idx := bytes.IndexByte(data, '\n'); idx >= 0 {
+			data = data[idx+1:]
+		}
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	res := make([]string, 0, limit)
+	for i := len(lines) - 1; i >= 0 && len(res) < limit; i-- {
+		if lines[i] == "" || logLineLevel(lines[i]) > maxLevel {
+			continue
+		}
+		res = append(res, lines[i])
+	}
+	for i, j := 0, len(res)-1; i < j; i, j = i+1, j-1 {
+		res[i], res[j] = res[j], res[i]
+	}
+	return res, nil
+}
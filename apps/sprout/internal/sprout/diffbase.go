@@ -0,0 +1,206 @@
+package sprout
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Diff base presets for the TUI diff tab.
+const (
+	diffBaseWorking    = "working"
+	diffBaseHead       = "head"
+	diffBaseMergeBase  = "merge-base"
+	diffBaseCheckpoint = "checkpoint"
+	diffBaseRef        = "ref"
+)
+
+// checkpointRef holds the last checkpoint of a worktree. Git keeps
+// refs/worktree/* private to each worktree, so every worktree has its own.
+const checkpointRef = "refs/worktree/sprout-checkpoint"
+
+// DiffBase selects what the diff tab compares a worktree's files with.
+type DiffBase struct {
+	Kind string
+	// Ref is the revision entered by the user when Kind is diffBaseRef.
+	Ref string
+}
+
+// Label names the base for titles and status messages.
+func (b DiffBase) Label() string {
+	switch b.Kind {
+	case diffBaseHead:
+		return "HEAD"
+	case diffBaseMergeBase:
+		return "merge-base"
+	case diffBaseCheckpoint:
+		return "checkpoint"
+	case diffBaseRef:
+		return b.Ref
+	}
+	return "working tree"
+}
+
+// recordCheckpoint snapshots a worktree, uncommitted changes included, so the
+// diff tab can show what changed since. git stash create writes the snapshot
+// commit without touching the index, the working tree, or the stash list.
+// Untracked files are not part of the snapshot.
+func (m *Manager) recordCheckpoint(path string) error {
+	snapshot, err := m.runCmdOutput(path, "git", "stash", "create", "sprout checkpoint")
+	if err != nil {
+		return err
+	}
+	if snapshot = strings.TrimSpace(snapshot); snapshot == "" {
+		// Nothing uncommitted: the checkpoint is the current commit.
+		snapshot = "HEAD"
+	}
+	return m.runCmdQuiet(path, "git", "update-ref", "-m", "sprout checkpoint", checkpointRef, snapshot)
+}
+
+// diffBaseRevision resolves base to the revision the worktree's files are
+// compared with. The working tree preset resolves to "", which keeps the
+// staged and unstaged changes apart.
+func (m *Manager) diffBaseRevision(repoRoot string, wt *Worktree, base DiffBase) (string, error) {
+	switch base.Kind {
+	case diffBaseHead:
+		return "HEAD", nil
+	case diffBaseMergeBase:
+		return m.branchDiffBase(repoRoot, wt), nil
+	case diffBaseCheckpoint:
+		rev, err := m.runCmdOutput(wt.Path, "git", "rev-parse", "--verify", "--quiet", checkpointRef)
+		if err != nil || strings.TrimSpace(rev) == "" {
+			return "", errors.New("no checkpoint yet: one is recorded each time a prompt is sent to the agent")
+		}
+		return strings.TrimSpace(rev), nil
+	case diffBaseRef:
+		ref := strings.TrimSpace(base.Ref)
+		if ref == "" {
+			return "", errors.New("ref is required")
+		}
+		rev, err := m.runCmdOutput(wt.Path, "git", "rev-parse", "--verify", "--quiet", "--end-of-options", ref+"^{commit}")
+		if err != nil || strings.TrimSpace(rev) == "" {
+			return "", fmt.Errorf("unknown revision: %s", ref)
+		}
+		return strings.TrimSpace(rev), nil
+	}
+	return "", nil
+}
+
+// WorktreeDiffFilesAgainst lists the files that differ between rev and the
+// working tree, untracked files included. An empty rev lists the staged and
+// unstaged changes like WorktreeDiffFiles.
+func (m *Manager) WorktreeDiffFilesAgainst(path, rev string) ([]DiffFile, error) {
+	if rev == "" {
+		return m.WorktreeDiffFiles(path)
+	}
+	out, err := m.runCmdOutput(path, "git", "--no-pager", "diff", "--name-status", "--no-color", "--no-ext-diff", rev, "--")
+	if err != nil {
+		return nil, err
+	}
+	untracked, err := m.runCmdOutput(path, "git", "ls-files", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, err
+	}
+	return parseNameStatus(out, untracked), nil
+}
+
+// parseNameStatus turns `git diff --name-status` output and a list of
+// untracked paths into diff files. Statuses are padded to the two columns of
+// porcelain status so both render alike.
+func parseNameStatus(out, untracked string) []DiffFile {
+	files := []DiffFile{}
+	seen := map[string]struct{}{}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Split(strings.TrimRight(line, "\r"), "\t")
+		if len(fields) < 2 || fields[0] == "" {
+			continue
+		}
+		// Renames and copies list the old and new path; keep the new one.
+		file := fields[len(fields)-1]
+		if _, ok := seen[file]; ok {
+			continue
+		}
+		seen[file] = struct{}{}
+		files = append(files, DiffFile{Path: file, Status: fields[0][:1] + " "})
+	}
+	for _, file := range strings.Split(untracked, "\n") {
+		file = strings.TrimSpace(file)
+		if file == "" {
+			continue
+		}
+		if _, ok := seen[file]; ok {
+			continue
+		}
+		seen[file] = struct{}{}
+		files = append(files, DiffFile{Path: file, Status: "??"})
+	}
+	return files
+}
+
+// WorktreeDiffForFileAgainst renders the patch of one file between rev and
+// the working tree. An empty rev renders it like WorktreeDiffForFile.
+func (m *Manager) WorktreeDiffForFileAgainst(path, rev string, file DiffFile, width int) (string, error) {
+	if rev == "" {
+		return m.WorktreeDiffForFile(path, file, width)
+	}
+	patch, err := m.WorktreeFilePatch(path, rev, file)
+	if err != nil {
+		return "", err
+	}
+	if m.commandExists("delta") {
+		if rendered, renderErr := m.renderDiffWithDelta(patch, width); renderErr == nil {
+			patch = rendered
+		} else {
+			errorLogf("diff delta file=%q path=%q rev=%q failed: %v", file.Path, path, rev, renderErr)
+		}
+	} else {
+		patch = highlightDiff(patch)
+	}
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("\x1b[36m# %s\x1b[0m", file.Path))
+	if status := strings.TrimSpace(file.Status); status != "" {
+		b.WriteString(fmt.Sprintf(" \x1b[36m(%s)\x1b[0m", status))
+	}
+	b.WriteString("\n\n")
+	if strings.TrimSpace(patch) == "" {
+		b.WriteString("(no textual diff available for this file)")
+	} else {
+		b.WriteString(patch)
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// WorktreeFilePatch is the plain unified diff of one file between rev and the
+// working tree, ready for git apply. An empty rev compares against HEAD, so
+// staged and unstaged changes come as one patch.
+func (m *Manager) WorktreeFilePatch(path, rev string, file DiffFile) (string, error) {
+	if file.Status == "??" {
+		return m.runCmdOutputAllowExitCodes(path, []int{1}, "git", "--no-pager", "diff", "--no-index", "--no-color", "--no-ext-diff", "--", "/dev/null", file.Path)
+	}
+	if rev == "" {
+		rev = "HEAD"
+	}
+	return m.runCmdOutput(path, "git", "--no-pager", "diff", "--no-color", "--no-ext-diff", rev, "--", file.Path)
+}
+
+// WorktreePatch is the plain unified diff of every changed file between rev
+// and the working tree, untracked files included.
+func (m *Manager) WorktreePatch(path, rev string) (string, error) {
+	files, err := m.WorktreeDiffFilesAgainst(path, rev)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, file := range files {
+		patch, err := m.WorktreeFilePatch(path, rev, file)
+		if err != nil {
+			return "", err
+		}
+		if patch = strings.TrimRight(patch, "\n"); patch != "" {
+			b.WriteString(patch)
+			b.WriteString("\n")
+		}
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,159 @@
+package sprout
+
+import (
+	"strings"
+	"time"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// Styles of the built-in diff highlighter, which colors patches when delta
+// is not installed. They follow git diff --color.
+const (
+	diffStyleMeta    = "\x1b[1m"
+	diffStyleHunk    = "\x1b[36m"
+	diffStyleAdded   = "\x1b[32m"
+	diffStyleRemoved = "\x1b[31m"
+	diffStyleNote    = "\x1b[2m"
+	diffStyleReset   = "\x1b[0m"
+	// diffStyleEmph marks the part of a changed line that differs from the
+	// line it replaced. tview does not read the escape that turns it off
+	// alone, so a reset and the line's color follow it.
+	diffStyleEmph = "\x1b[7m"
+)
+
+// maxIntralineBytes bounds the lines compared for intraline emphasis.
+const maxIntralineBytes = 512
+
+// highlightDiff colors a plain unified diff: file headers bold, hunk headers
+// cyan, added lines green, and removed lines red. When a run of removed
+// lines is replaced by as many added lines, each pair that mostly matches
+// has the text that changed between them emphasized.
+func highlightDiff(diff string) string {
+	if strings.TrimSpace(diff) == "" {
+		return diff
+	}
+	lines := strings.Split(diff, "\n")
+	out := make([]string, 0, len(lines))
+	// parents is the number of prefix columns of the current hunk's lines:
+	// 1, or one per parent in the combined diff of a merge.
+	parents := 0
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "diff "):
+			parents = 0
+			out = append(out, diffStyleMeta+line+diffStyleReset)
+		case strings.HasPrefix(line, "@@"):
+			parents = max(1, len(line)-len(strings.TrimLeft(line, "@"))-1)
+			out = append(out, highlightHunkHeader(line))
+		case parents == 0:
+			if line == "" {
+				out = append(out, line)
+			} else {
+				out = append(out, diffStyleMeta+line+diffStyleReset)
+			}
+		case strings.HasPrefix(line, `\`):
+			out = append(out, diffStyleNote+line+diffStyleReset)
+		case parents == 1 && strings.HasPrefix(line, "-"):
+			removed := i
+			for i < len(lines) && strings.HasPrefix(lines[i], "-") {
+				i++
+			}
+			added := i
+			for i < len(lines) && strings.HasPrefix(lines[i], "+") {
+				i++
+			}
+			out = append(out, highlightChangedLines(lines[removed:added], lines[added:i])...)
+			i--
+		default:
+			prefix := line[:min(parents, len(line))]
+			switch {
+			case strings.Contains(prefix, "-"):
+				out = append(out, diffStyleRemoved+line+diffStyleReset)
+			case strings.Contains(prefix, "+"):
+				out = append(out, diffStyleAdded+line+diffStyleReset)
+			default:
+				out = append(out, line)
+			}
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+// highlightHunkHeader colors the @@ -a,b +c,d @@ range of a hunk header,
+// leaving the function it is in plain.
+func highlightHunkHeader(line string) string {
+	marker := line[:len(line)-len(strings.TrimLeft(line, "@"))]
+	end := strings.Index(line[len(marker):], " "+marker)
+	if end < 0 {
+		return diffStyleHunk + line + diffStyleReset
+	}
+	end += 2*len(marker) + 1
+	return diffStyleHunk + line[:end] + diffStyleReset + line[end:]
+}
+
+// highlightChangedLines colors a run of removed lines and the added lines
+// that follow it, pairing them for intraline emphasis when there are as many
+// of each.
+func highlightChangedLines(removed, added []string) []string {
+	out := make([]string, 0, len(removed)+len(added))
+	if len(removed) != len(added) {
+		for _, line := range removed {
+			out = append(out, diffStyleRemoved+line+diffStyleReset)
+		}
+		for _, line := range added {
+			out = append(out, diffStyleAdded+line+diffStyleReset)
+		}
+		return out
+	}
+	oldLines := make([]string, len(removed))
+	newLines := make([]string, len(added))
+	for i := range removed {
+		oldLines[i], newLines[i] = emphasizeLinePair(removed[i], added[i])
+	}
+	return append(append(out, oldLines...), newLines...)
+}
+
+// emphasizeLinePair colors a removed line and the added line that replaced
+// it, emphasizing what changed when the two mostly match.
+func emphasizeLinePair(removed, added string) (string, string) {
+	plainRemoved := diffStyleRemoved + removed + diffStyleReset
+	plainAdded := diffStyleAdded + added + diffStyleReset
+	if len(removed) > maxIntralineBytes || len(added) > maxIntralineBytes {
+		return plainRemoved, plainAdded
+	}
+	dmp := diffmatchpatch.New()
+	dmp.DiffTimeout = 50 * time.Millisecond
+	diffs := dmp.DiffCleanupSemantic(dmp.DiffMain(removed[1:], added[1:], false))
+
+	same := 0
+	for _, d := range diffs {
+		if d.Type == diffmatchpatch.DiffEqual {
+			same += len(d.Text)
+		}
+	}
+	// Lines that share little are rewrites; emphasizing nearly all of them
+	// says nothing.
+	if same*2 < max(len(removed), len(added))-1 {
+		return plainRemoved, plainAdded
+	}
+
+	var oldLine, newLine strings.Builder
+	oldLine.WriteString(diffStyleRemoved + "-")
+	newLine.WriteString(diffStyleAdded + "+")
+	for _, d := range diffs {
+		switch d.Type {
+		case diffmatchpatch.DiffEqual:
+			oldLine.WriteString(d.Text)
+			newLine.WriteString(d.Text)
+		case diffmatchpatch.DiffDelete:
+			oldLine.WriteString(diffStyleEmph + d.Text + diffStyleReset + diffStyleRemoved)
+		case diffmatchpatch.DiffInsert:
+			newLine.WriteString(diffStyleEmph + d.Text + diffStyleReset + diffStyleAdded)
+		}
+	}
+	oldLine.WriteString(diffStyleReset)
+	newLine.WriteString(diffStyleReset)
+	return oldLine.String(), newLine.String()
+}
@@ -0,0 +1,71 @@
+package sprout
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// How untracked files count toward the dirty STATUS of listed worktrees.
+// all looks for them on every refresh; periodic checks tracked files on
+// every refresh and looks for untracked ones at most once per
+// dirtyUntrackedInterval; no leaves them out. Removing, merging, and
+// copying into a worktree always look for them.
+const (
+	dirtyUntrackedAll      = "all"
+	dirtyUntrackedPeriodic = "periodic"
+	dirtyUntrackedNo       = "no"
+
+	dirtyUntrackedInterval = time.Minute
+)
+
+func parseDirtyUntracked(value string) (string, error) {
+	switch v := strings.ToLower(strings.TrimSpace(value)); v {
+	case "", dirtyUntrackedPeriodic:
+		return dirtyUntrackedPeriodic, nil
+	case dirtyUntrackedAll, dirtyUntrackedNo:
+		return v, nil
+	}
+	return "", fmt.Errorf("invalid dirty_untracked %q (want all, periodic, or no)", value)
+}
+
+// listedWorktreeDirty is WorktreeDirty for the STATUS column, trading
+// exactness about untracked files for speed as dirty_untracked says. The
+// cheap check of tracked files comes first; git status without untracked
+// files does not walk the directories git ignores or does not know.
+func (m *Manager) listedWorktreeDirty(ctx context.Context, repoRoot, path string) bool {
+	if m.Cfg.GitBackend == gitBackendGoGit || m.Cfg.DirtyUntracked == dirtyUntrackedAll {
+		return m.WorktreeDirty(ctx, path)
+	}
+	out, err := m.runCmdOutputContext(ctx, path, "git", "--no-pager", "status", "--porcelain=v2", "--no-renames", "--untracked-files=no")
+	if err != nil {
+		return false
+	}
+	if strings.TrimSpace(out) != "" {
+		return true
+	}
+	if m.Cfg.DirtyUntracked == dirtyUntrackedNo {
+		return false
+	}
+	untracked, _ := cachedQuery(m, repoRoot, path, "untracked", dirtyUntrackedInterval, false, func() (bool, error) {
+		return m.hasUntrackedFiles(ctx, path), nil
+	})
+	return untracked
+}
+
+// hasUntrackedFiles reports whether the worktree at path has files git
+// neither tracks nor ignores. An untracked directory is reported without
+// listing what is in it.
+func (m *Manager) hasUntrackedFiles(ctx context.Context, path string) bool {
+	out, err := m.runCmdOutputContext(ctx, path, "git", "--no-pager", "status", "--porcelain=v2", "--no-renames", "--untracked-files=normal")
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(line, "? ") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package sprout
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DiscardFileChanges throws away the uncommitted changes to one file of the
+// worktree at path, staged or not: an untracked file is deleted, a file new
+// in the index is removed from it and from disk, a renamed file is moved
+// back, and any other file is checked out from HEAD. There is no undo.
+func (m *Manager) DiscardFileChanges(path string, file DiffFile) error {
+	defer timeOperation("discard_file")()
+	stageState, workState := parsePorcelainStatus(file.Status)
+	switch {
+	case stageState == 'U' || workState == 'U' || (stageState == 'A' && workState == 'A') || (stageState == 'D' && workState == 'D'):
+		return fmt.Errorf("%s has merge conflicts; resolve them first", file.Path)
+	case stageState == '?' && workState == '?':
+		full := filepath.Join(path, file.Path)
+		if rel, err := filepath.Rel(path, full); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("not in the worktree: %s", file.Path)
+		}
+		if err := m.removePath(full); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	case stageState == 'A' || stageState == 'R' || stageState == 'C':
+		if err := m.runCmdQuiet(path, "git", "rm", "--force", "--quiet", "--", file.Path); err != nil {
+			return err
+		}
+		// git rm leaves a rename's source staged as deleted; a copy's
+		// source is untouched.
+		if stageState == 'R' && file.OrigPath != "" {
+			if err := m.runCmdQuiet(path, "git", "checkout", "HEAD", "--", file.OrigPath); err != nil {
+				return err
+			}
+		}
+	case stageState == ' ':
+		if err := m.runCmdQuiet(path, "git", "checkout", "--", file.Path); err != nil {
+			return err
+		}
+	default:
+		if err := m.runCmdQuiet(path, "git", "checkout", "HEAD", "--", file.Path); err != nil {
+			return err
+		}
+	}
+	m.invalidateWorktreeQueries()
+	infoLogf("discard_file done path=%q file=%q status=%q", path, file.Path, file.Status)
+	return nil
+}
@@ -0,0 +1,348 @@
+package sprout
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event types recorded in the event log.
+const (
+	eventWorktreeCreated = "worktree_created"
+	eventWorktreeRemoved = "worktree_removed"
+	eventAgentStarted    = "agent_started"
+	eventAgentStopped    = "agent_stopped"
+	eventAgentReady      = "agent_ready"
+	eventAgentExited     = "agent_exited"
+	eventAgentIdle       = "agent_idle"
+	eventSessionLaunched = "session_launched"
+)
+
+const (
+	eventsFile = "events.jsonl"
+	// eventsMaxBytes is how large the event log grows before it is moved to
+	// events.jsonl.1, replacing the one before.
+	eventsMaxBytes = 4 << 20
+	// eventsFollowInterval is how often events --follow checks the log for
+	// new lines.
+	eventsFollowInterval = 250 * time.Millisecond
+)
+
+var eventTypes = []string{
+	eventWorktreeCreated,
+	eventWorktreeRemoved,
+	eventAgentStarted,
+	eventAgentStopped,
+	eventAgentReady,
+	eventAgentExited,
+	eventAgentIdle,
+	eventSessionLaunched,
+}
+
+var eventsMu sync.Mutex
+
+// Event is something sprout did to a worktree, as written to the event log.
+// RepoRoot is the main worktree of the repository, whichever worktree the
+// event came from. Outcome is set on worktree_removed: merged or abandoned,
+// depending on whether the branch had been merged into base_branch, or empty
+// when that could not be told.
+type Event struct {
+	Type      string    `json:"type"`
+	Time      time.Time `json:"time"`
+	Repo      string    `json:"repo"`
+	RepoRoot  string    `json:"repo_root"`
+	Branch    string    `json:"branch,omitempty"`
+	Path      string    `json:"path,omitempty"`
+	AgentType string    `json:"agent_type,omitempty"`
+	Session   string    `json:"session,omitempty"`
+	Outcome   string    `json:"outcome,omitempty"`
+}
+
+// eventBus hands events to the subscribers in this process.
+type eventBus struct {
+	mu   sync.Mutex
+	subs map[chan Event]struct{}
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{subs: map[chan Event]struct{}{}}
+}
+
+// publish sends ev to every subscriber without blocking; a subscriber that
+// is not keeping up misses the event rather than stalling sprout.
+func (b *eventBus) publish(ev Event) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs {
+		select {
+		case ch <- ev:
+		default:
+			debugLogf("event_bus subscriber full, dropped type=%s path=%q", ev.Type, ev.Path)
+		}
+	}
+}
+
+func (b *eventBus) subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, 64)
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// SubscribeEvents returns the events this Manager emits from now on, and a
+// function that ends the subscription. Events from other sprout processes
+// only reach the event log; follow it with FollowEvents.
+func (m *Manager) SubscribeEvents() (<-chan Event, func()) {
+	return m.events.subscribe()
+}
+
+// emit fills in ev for wt, records it in the event log, publishes it, and
+// runs the hook it triggers. Failing to record is logged and otherwise
+// ignored, like the other state files.
+func (m *Manager) emit(repoRoot string, wt *Worktree, ev Event) {
+	ev.Time = time.Now().UTC()
+	ev.Repo = m.RepoName(repoRoot)
+	ev.RepoRoot = m.mainRepoRoot(repoRoot)
+	if wt != nil {
+		ev.Branch = worktreeBranchOrName(wt)
+		ev.Path = wt.Path
+		ev.Session = m.tmuxWorktreeSessionName(repoRoot, wt)
+	}
+	// Whatever the event is about changed worktrees or branches.
+	m.InvalidateQueries(repoRoot)
+	if err := appendEvent(ev); err != nil {
+		errorLogf("event_log append failed type=%s path=%q: %v", ev.Type, ev.Path, err)
+	}
+	debugLogf("event type=%s branch=%q path=%q", ev.Type, ev.Branch, ev.Path)
+	m.events.publish(ev)
+	m.runEventHook(ev)
+}
+
+// mainRepoRoot returns the main worktree of the repository repoRoot belongs
+// to, so events from linked worktrees are filed under the same repository.
+func (m *Manager) mainRepoRoot(repoRoot string) string {
+	out, err := m.runCmdOutput(repoRoot, "git", "rev-parse", "--path-format=absolute", "--git-common-dir")
+	if err != nil {
+		return repoRoot
+	}
+	commonDir := filepath.FromSlash(strings.TrimSpace(out))
+	if filepath.Base(commonDir) != ".git" {
+		// Bare repositories have no main worktree.
+		return repoRoot
+	}
+	return filepath.Dir(commonDir)
+}
+
+func eventsPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "sprout", eventsFile), nil
+}
+
+// appendEvent adds ev to the event log as one JSON line. Lines are written
+// with a single O_APPEND write, so concurrent sprout processes do not
+// interleave them. A log that would grow past eventsMaxBytes is rotated
+// first, so one older log is kept.
+func appendEvent(ev Event) error {
+	path, err := eventsPath()
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	eventsMu.Lock()
+	defer eventsMu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	if info, err := os.Stat(path); err == nil && info.Size()+int64(len(line)) > eventsMaxBytes {
+		if err := os.Rename(path, path+".1"); err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(line); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// EventFilter selects events from the event log. Zero values match
+// everything.
+type EventFilter struct {
+	RepoRoot string
+	Types    []string
+	Since    time.Time
+}
+
+func (f EventFilter) match(ev Event) bool {
+	if f.RepoRoot != "" && ev.RepoRoot != f.RepoRoot {
+		return false
+	}
+	if len(f.Types) > 0 && !containsString(f.Types, ev.Type) {
+		return false
+	}
+	return f.Since.IsZero() || !ev.Time.Before(f.Since)
+}
+
+func parseEventTypes(values []string) ([]string, error) {
+	types := make([]string, 0, len(values))
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			part = strings.ToLower(strings.TrimSpace(part))
+			if part == "" {
+				continue
+			}
+			if !containsString(eventTypes, part) {
+				return nil, fmt.Errorf("invalid event type %q (want one of %s)", part, strings.Join(eventTypes, ", "))
+			}
+			types = append(types, part)
+		}
+	}
+	return types, nil
+}
+
+// readEventsFrom decodes the complete lines of r that match filter. It
+// returns how many bytes it consumed, so a partly written last line is read
+// again on the next call. Lines that do not decode are skipped.
+func readEventsFrom(r io.Reader, filter EventFilter) ([]Event, int64, error) {
+	var events []Event
+	var consumed int64
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return events, consumed, nil
+			}
+			return events, consumed, err
+		}
+		consumed += int64(len(line))
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		var ev Event
+		if err := json.Unmarshal(line, &ev); err != nil {
+			debugLogf("event_log skipped bad line: %v", err)
+			continue
+		}
+		if filter.match(ev) {
+			events = append(events, ev)
+		}
+	}
+}
+
+// ReadEvents returns the events in the event log, and in the log rotated
+// out before it, that match filter, oldest first.
+func ReadEvents(filter EventFilter) ([]Event, error) {
+	path, err := eventsPath()
+	if err != nil {
+		return nil, err
+	}
+	var events []Event
+	for _, name := range []string{path + ".1", path} {
+		f, err := os.Open(name)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		found, _, err := readEventsFrom(f, filter)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, found...)
+	}
+	return events, nil
+}
+
+// FollowEvents calls fn for each event matching filter that is appended to
+// the event log, until done is closed or fn returns an error. With history
+// it first goes through the events already logged. It picks up events from
+// every sprout process.
+func FollowEvents(filter EventFilter, history bool, done <-chan struct{}, fn func(Event) error) error {
+	path, err := eventsPath()
+	if err != nil {
+		return err
+	}
+	var offset int64
+	if info, err := os.Stat(path); err == nil && !history {
+		offset = info.Size()
+	}
+	ticker := time.NewTicker(eventsFollowInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return nil
+		case <-ticker.C:
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				offset = 0
+				continue
+			}
+			return err
+		}
+		if info.Size() < offset {
+			// The log was truncated or replaced; start over from the top.
+			offset = 0
+		}
+		if info.Size() == offset {
+			continue
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			f.Close()
+			return err
+		}
+		events, consumed, err := readEventsFrom(f, filter)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		offset += consumed
+		for _, ev := range events {
+			if err := fn(ev); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package sprout
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ExecOptions selects the worktrees sprout exec runs a command in.
+type ExecOptions struct {
+	// Target names one worktree; All runs in every worktree of the repository.
+	Target string
+	All    bool
+	// Command is the program and its arguments. A single argument runs as a
+	// shell script, so pipes and && work when it is quoted.
+	Command []string
+	// Parallel is how many worktrees run at once; below 2 they run one after
+	// another.
+	Parallel int
+	// Output gets the command's output as it runs; with All, line by line
+	// and prefixed with each worktree's branch. When nil, the output is kept
+	// in ExecResult.Output instead.
+	Output io.Writer
+}
+
+// ExecResult is how the command went in one worktree.
+type ExecResult struct {
+	Path       string `json:"path"`
+	Branch     string `json:"branch"`
+	ExitCode   int    `json:"exit_code"`
+	DurationMS int64  `json:"duration_ms"`
+	Output     string `json:"output,omitempty"`
+	// Error is set when the command could not run or was stopped; a command
+	// that ran and failed only has a nonzero ExitCode.
+	Error string `json:"error,omitempty"`
+}
+
+// OK reports whether the command ran and exited 0.
+func (r ExecResult) OK() bool {
+	return r.ExitCode == 0 && r.Error == ""
+}
+
+// Exec runs a command in one or all worktrees of the current repository and
+// reports how it went in each, in the order the worktrees are listed.
+// Worktrees whose directory is gone are skipped. Once ctx is done, running
+// commands are killed and the ones left are not started.
+func (m *Manager) Exec(ctx context.Context, opts ExecOptions) ([]ExecResult, error) {
+	if len(opts.Command) == 0 {
+		return nil, errors.New("no command to run")
+	}
+	var targets []Worktree
+	if opts.All {
+		items, err := m.ListWorktrees(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, wt := range items {
+			if m.dirExists(wt.Path) {
+				targets = append(targets, wt)
+			}
+		}
+	} else {
+		wt, _, err := m.ResolveTarget(opts.Target)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, *wt)
+	}
+
+	script := opts.Command[0]
+	if len(opts.Command) > 1 {
+		parts := make([]string, len(opts.Command))
+		for i, arg := range opts.Command {
+			parts[i] = shellQuote(arg)
+		}
+		script = strings.Join(parts, " ")
+	}
+	parallel := opts.Parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	results := make([]ExecResult, len(targets))
+	var outMu sync.Mutex
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i := range targets {
+		wt := targets[i]
+		results[i] = ExecResult{Path: wt.Path, Branch: worktreeBranchOrName(&wt)}
+		if ctx.Err() != nil {
+			results[i].ExitCode = -1
+			results[i].Error = "not run: " + ctx.Err().Error()
+			continue
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(res *ExecResult) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			var out io.Writer
+			var captured bytes.Buffer
+			switch {
+			case opts.Output == nil:
+				out = &captured
+			case !opts.All:
+				out = opts.Output
+			default:
+				out = &prefixWriter{mu: &outMu, out: opts.Output, prefix: res.Branch + " | "}
+			}
+			m.execIn(ctx, res, script, out)
+			if w, ok := out.(*prefixWriter); ok {
+				w.Flush()
+			}
+			res.Output = captured.String()
+		}(&results[i])
+	}
+	wg.Wait()
+	return results, nil
+}
+
+// execIn runs script with sh in res.Path, on the SSH host in remote mode,
+// and records its exit code and duration in res.
+func (m *Manager) execIn(ctx context.Context, res *ExecResult, script string, out io.Writer) {
+	start := time.Now()
+	debugLogf("exec dir=%q script=%q", res.Path, script)
+	runDir, runName, runArgs := m.remoteCommand(res.Path, "sh", []string{"-c", script}, false)
+	cmd := exec.CommandContext(ctx, runName, runArgs...)
+	if runDir != "" {
+		cmd.Dir = runDir
+	}
+	cmd.Stdout = out
+	cmd.Stderr = out
+	cmd.WaitDelay = 2 * time.Second
+	err := cmd.Run()
+	res.DurationMS = time.Since(start).Milliseconds()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case ctx.Err() != nil:
+		res.ExitCode = -1
+		res.Error = "stopped: " + ctx.Err().Error()
+	case errors.As(err, &exitErr):
+		res.ExitCode = exitErr.ExitCode()
+	default:
+		res.ExitCode = -1
+		res.Error = err.Error()
+	}
+}
+
+// prefixWriter writes whole lines to out, each after prefix, so that the
+// output of commands running side by side stays readable.
+type prefixWriter struct {
+	mu     *sync.Mutex
+	out    io.Writer
+	prefix string
+	buf    []byte
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		if err := w.writeLine(w.buf[:i+1]); err != nil {
+			return len(p), err
+		}
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush writes what is left of an unterminated last line.
+func (w *prefixWriter) Flush() {
+	if len(w.buf) > 0 {
+		_ = w.writeLine(append(w.buf, '\n'))
+		w.buf = nil
+	}
+}
+
+func (w *prefixWriter) writeLine(line []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err := fmt.Fprintf(w.out, "%s%s", w.prefix, line)
+	return err
+}
@@ -0,0 +1,241 @@
+package sprout
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// defaultExportDir is where the TUI export action writes unless export_dir
+// says otherwise.
+const defaultExportDir = "~/.local/share/sprout/exports"
+
+// agentTranscriptLines is how much agent scrollback an export keeps; tmux
+// keeps 2000 lines unless history-limit is raised.
+const agentTranscriptLines = 50000
+
+// Export formats accepted by Export.
+const (
+	exportPatch    = "patch"
+	exportHTML     = "html"
+	exportMarkdown = "markdown"
+)
+
+type ExportOptions struct {
+	Target string
+	Format string
+}
+
+type ExportResult struct {
+	Path    string
+	Branch  string
+	Format  string
+	Content []byte
+}
+
+// exportExtension returns the file extension used for an export format.
+func exportExtension(format string) string {
+	switch format {
+	case exportHTML:
+		return ".html"
+	case exportMarkdown:
+		return ".md"
+	}
+	return ".patch"
+}
+
+// Export renders the worktree's branch diff as a plain patch, a standalone
+// HTML page, or a Markdown review document.
+func (m *Manager) Export(opts ExportOptions) (ExportResult, error) {
+	format := strings.ToLower(strings.TrimSpace(opts.Format))
+	switch format {
+	case "":
+		format = exportPatch
+	case "md":
+		format = exportMarkdown
+	case exportPatch, exportHTML, exportMarkdown:
+	default:
+		return ExportResult{}, fmt.Errorf("invalid export format %q (want patch, html, or markdown)", opts.Format)
+	}
+
+	repoRoot, wt, err := m.resolveWorktreeForTmux(opts.Target)
+	if err != nil {
+		return ExportResult{}, err
+	}
+	res := ExportResult{Path: wt.Path, Branch: worktreeBranchOrName(wt), Format: format}
+	if format == exportHTML {
+		res.Content, err = m.renderSharePage(repoRoot, wt, ShareOptions{})
+		if err != nil {
+			return ExportResult{}, err
+		}
+	} else {
+		diff, err := m.BranchDiff(repoRoot, wt)
+		if err != nil {
+			return ExportResult{}, err
+		}
+		if format == exportMarkdown {
+			base, _ := m.ResolveBaseBranch(repoRoot, "")
+			diff = renderReviewMarkdown(res.Branch, base, diff, time.Now())
+		}
+		res.Content = []byte(diff)
+	}
+	infoLogf("export done path=%q format=%s bytes=%d", wt.Path, format, len(res.Content))
+	return res, nil
+}
+
+type diffChunk struct {
+	File      string
+	Additions int
+	Deletions int
+	Text      string
+}
+
+// splitDiffFiles splits a unified diff into one chunk per file.
+func splitDiffFiles(diff string) []diffChunk {
+	var chunks []diffChunk
+	var cur *diffChunk
+	var text strings.Builder
+	flush := func() {
+		if cur != nil {
+			cur.Text = strings.TrimRight(text.String(), "\n")
+			chunks = append(chunks, *cur)
+		}
+		text.Reset()
+	}
+	inHeader := false
+	for _, line := range strings.Split(strings.TrimRight(diff, "\n"), "\n") {
+		if strings.HasPrefix(line, "diff --git ") {
+			flush()
+			cur = &diffChunk{File: diffGitPath(line)}
+			inHeader = true
+		}
+		if cur == nil {
+			continue
+		}
+		text.WriteString(line)
+		text.WriteByte('\n')
+		switch {
+		case strings.HasPrefix(line, "@@"):
+			inHeader = false
+		case inHeader:
+		case strings.HasPrefix(line, "+"):
+			cur.Additions++
+		case strings.HasPrefix(line, "-"):
+			cur.Deletions++
+		}
+	}
+	flush()
+	return chunks
+}
+
+// diffGitPath extracts the new path from a "diff --git a/x b/x" header.
+func diffGitPath(header string) string {
+	rest := strings.TrimPrefix(header, "diff --git ")
+	if idx := strings.LastIndex(rest, " b/"); idx >= 0 {
+		return rest[idx+3:]
+	}
+	return rest
+}
+
+func renderReviewMarkdown(branch, base, diff string, now time.Time) string {
+	chunks := splitDiffFiles(diff)
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Review: %s\n\n", branch)
+	if base != "" {
+		fmt.Fprintf(&b, "- Base: `%s`\n", base)
+	}
+	fmt.Fprintf(&b, "- Generated: %s\n", now.Format(time.RFC1123))
+	totalAdd, totalDel := 0, 0
+	for _, c := range chunks {
+		totalAdd += c.Additions
+		totalDel += c.Deletions
+	}
+	fmt.Fprintf(&b, "- Files changed: %d (+%d / -%d)\n\n", len(chunks), totalAdd, totalDel)
+	if len(chunks) == 0 {
+		b.WriteString("No changes since the base branch.\n")
+		return b.String()
+	}
+
+	b.WriteString("## Files\n\n")
+	b.WriteString("| File | + | - |\n|---|---:|---:|\n")
+	for _, c := range chunks {
+		fmt.Fprintf(&b, "| `%s` | %d | %d |\n", strings.ReplaceAll(c.File, "|", `\|`), c.Additions, c.Deletions)
+	}
+
+	b.WriteString("\n## Changes\n")
+	for _, c := range chunks {
+		fence := "```"
+		for strings.Contains(c.Text, fence) {
+			fence += "`"
+		}
+		fmt.Fprintf(&b, "\n### %s\n\n%sdiff\n%s\n%s\n", c.File, fence, c.Text, fence)
+	}
+	return b.String()
+}
+
+// ExportDir is the directory export_dir names for repoRoot: "~" expands to
+// the home directory and relative paths are taken from the repo root.
+func (m *Manager) ExportDir(repoRoot string) string {
+	dir := strings.TrimSpace(m.Cfg.ExportDir)
+	if dir == "" {
+		dir = defaultExportDir
+	}
+	return resolvePaneDir(dir, repoRoot)
+}
+
+// exportFileName names an export of a worktree's branch, like
+// "app-feat-login-agent-20261016-150405.log".
+func exportFileName(repo, branch, what, ext string, now time.Time) string {
+	parts := []string{}
+	for _, part := range []string{repo, branch, what} {
+		if token := sessionToken(part); token != "" {
+			parts = append(parts, token)
+		}
+	}
+	parts = append(parts, now.Format("20060102-150405"))
+	return strings.Join(parts, "-") + ext
+}
+
+// WriteExport writes content to a new timestamped file in the export dir and
+// returns its path. what names the kind of export, such as "agent" or
+// "diff"; a file exported within the same second gets a numbered name
+// rather than replacing the earlier one.
+func (m *Manager) WriteExport(repoRoot string, wt *Worktree, what, ext string, content []byte) (string, error) {
+	dir := m.ExportDir(repoRoot)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	name := exportFileName(m.RepoName(repoRoot), worktreeBranchOrName(wt), what, ext, time.Now())
+	path := filepath.Join(dir, name)
+	for i := 2; ; i++ {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if os.IsExist(err) {
+			path = filepath.Join(dir, strings.TrimSuffix(name, ext)+fmt.Sprintf("-%d", i)+ext)
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		_, err = f.Write(content)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return "", err
+		}
+		infoLogf("export written path=%q bytes=%d", path, len(content))
+		return path, nil
+	}
+}
+
+// AgentTranscript is the agent output of wt as plain text, with as much of
+// its scrollback as tmux kept.
+func (m *Manager) AgentTranscript(repoRoot string, wt *Worktree) (string, error) {
+	out, err := m.agentOutputForWorktree(repoRoot, wt, agentTranscriptLines)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(stripANSI(out), "\n") + "\n", nil
+}
@@ -0,0 +1,141 @@
+package sprout
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const fetchStateFile = "fetch.json"
+
+var fetchStateMu sync.Mutex
+
+// fetchState holds when each repository, keyed by its main worktree, was
+// last fetched, so sprout fetch and every TUI share one schedule.
+type fetchState struct {
+	Repos map[string]time.Time `json:"repos"`
+}
+
+// FetchResult is what sprout fetch reports.
+type FetchResult struct {
+	Repo       string    `json:"repo"`
+	FetchedAt  time.Time `json:"fetched_at"`
+	DurationMS int64     `json:"duration_ms"`
+	// Skipped is set when auto-fetch found the repository fetched recently
+	// enough, by this or another sprout.
+	Skipped bool `json:"skipped,omitempty"`
+}
+
+func fetchStatePath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "sprout", fetchStateFile), nil
+}
+
+func readFetchState() (fetchState, error) {
+	state := fetchState{Repos: map[string]time.Time{}}
+	path, err := fetchStatePath()
+	if err != nil {
+		return state, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return state, nil
+		}
+		return state, err
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fetchState{Repos: map[string]time.Time{}}, err
+	}
+	if state.Repos == nil {
+		state.Repos = map[string]time.Time{}
+	}
+	return state, nil
+}
+
+// lastFetch returns when the repository with main worktree repoRoot was
+// last fetched by sprout, or the zero time.
+func lastFetch(repoRoot string) time.Time {
+	fetchStateMu.Lock()
+	defer fetchStateMu.Unlock()
+	state, err := readFetchState()
+	if err != nil {
+		errorLogf("fetch state read failed: %v", err)
+		return time.Time{}
+	}
+	return state.Repos[repoRoot]
+}
+
+func recordFetch(repoRoot string, at time.Time) error {
+	fetchStateMu.Lock()
+	defer fetchStateMu.Unlock()
+	state, err := readFetchState()
+	if err != nil {
+		debugLogf("fetch state read failed, starting over: %v", err)
+		state = fetchState{Repos: map[string]time.Time{}}
+	}
+	state.Repos[repoRoot] = at.UTC()
+	path, err := fetchStatePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// Fetch runs git fetch --prune in the repository. Its worktrees share the
+// repository's refs, so one fetch updates all of them. Cached queries about
+// the repository are dropped, so ahead/behind counts are read again.
+func (m *Manager) Fetch(ctx context.Context) (FetchResult, error) {
+	defer timeOperation("fetch")()
+	repoRoot, err := m.RequireRepo()
+	if err != nil {
+		return FetchResult{}, err
+	}
+	start := time.Now()
+	if err := m.runCmdQuietContext(ctx, repoRoot, "git", "fetch", "--prune"); err != nil {
+		return FetchResult{}, err
+	}
+	m.InvalidateQueries(repoRoot)
+	res := FetchResult{Repo: m.mainRepoRoot(repoRoot), FetchedAt: time.Now().UTC(), DurationMS: time.Since(start).Milliseconds()}
+	if err := recordFetch(res.Repo, res.FetchedAt); err != nil {
+		errorLogf("fetch state write failed: %v", err)
+	}
+	infoLogf("fetch repo=%q duration_ms=%d", res.Repo, res.DurationMS)
+	return res, nil
+}
+
+// fetchDue reports whether a repository last fetched at last is due for an
+// auto-fetch every interval.
+func fetchDue(last time.Time, interval time.Duration, now time.Time) bool {
+	return interval > 0 && now.Sub(last) >= interval
+}
+
+// AutoFetch fetches the repository when it was not fetched within the last
+// auto_fetch_minutes, by this or another sprout, and reports it skipped
+// otherwise.
+func (m *Manager) AutoFetch(ctx context.Context) (FetchResult, error) {
+	repoRoot, err := m.RequireRepo()
+	if err != nil {
+		return FetchResult{}, err
+	}
+	interval := time.Duration(m.Cfg.AutoFetchMinutes) * time.Minute
+	mainRoot := m.mainRepoRoot(repoRoot)
+	if last := lastFetch(mainRoot); !fetchDue(last, interval, time.Now()) {
+		return FetchResult{Repo: mainRoot, FetchedAt: last, Skipped: true}, nil
+	}
+	return m.Fetch(ctx)
+}
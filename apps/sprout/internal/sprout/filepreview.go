@@ -0,0 +1,105 @@
+package sprout
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// How much of a text file the diff tab's preview shows.
+const (
+	previewHeadBytes = 64 << 10
+	previewHeadLines = 200
+)
+
+// WorktreeFilePreview renders what a changed file of the worktree at path
+// holds now, for files whose patch says little, such as new or binary ones:
+// its type, sniffed from its content, its size and modification time, the
+// dimensions of images, and the first lines of text files.
+func (m *Manager) WorktreeFilePreview(path string, file DiffFile) (string, error) {
+	defer timeOperation("file_preview")()
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("\x1b[36m# %s\x1b[0m", file.Path))
+	if status := strings.TrimSpace(file.Status); status != "" {
+		b.WriteString(fmt.Sprintf(" \x1b[36m(%s)\x1b[0m", status))
+	}
+	b.WriteString("\n\n")
+
+	full := filepath.Join(path, file.Path)
+	info, err := m.statPath(full)
+	if errors.Is(err, os.ErrNotExist) {
+		b.WriteString("(the file was deleted; its patch shows what it held)")
+		return b.String(), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		b.WriteString(previewMeta("type", "directory"))
+		b.WriteString(previewMeta("modified", info.ModTime().Format("2006-01-02 15:04:05")))
+		return strings.TrimSpace(b.String()), nil
+	}
+
+	head, err := m.readFileHead(full, previewHeadBytes)
+	if err != nil {
+		return "", err
+	}
+
+	b.WriteString(previewMeta("type", http.DetectContentType(head)))
+	size := fmt.Sprintf("%d bytes", info.Size())
+	if info.Size() >= 1<<10 {
+		size = compactBytes(info.Size()) + " (" + size + ")"
+	}
+	b.WriteString(previewMeta("size", size))
+	b.WriteString(previewMeta("modified", info.ModTime().Format("2006-01-02 15:04:05")))
+	b.WriteString(previewMeta("mode", info.Mode().String()))
+	if cfg, format, err := image.DecodeConfig(bytes.NewReader(head)); err == nil {
+		b.WriteString(previewMeta("image", fmt.Sprintf("%dx%d %s", cfg.Width, cfg.Height, format)))
+	}
+	if len(head) == 0 || !previewIsText(head) {
+		return strings.TrimSpace(b.String()), nil
+	}
+
+	b.WriteString("\n")
+	lines := strings.Split(strings.TrimSuffix(stripANSI(string(head)), "\n"), "\n")
+	truncated := int64(len(head)) < info.Size()
+	if truncated && len(lines) > 1 {
+		// previewHeadBytes may have cut the last line short.
+		lines = lines[:len(lines)-1]
+	}
+	shown := min(len(lines), previewHeadLines)
+	width := len(fmt.Sprint(shown))
+	for i, line := range lines[:shown] {
+		b.WriteString(fmt.Sprintf("\x1b[2m%*d\x1b[0m %s\n", width, i+1, strings.TrimRight(line, "\r")))
+	}
+	if shown < len(lines) || truncated {
+		b.WriteString(fmt.Sprintf("\x1b[2m... (first %d lines shown)\x1b[0m\n", shown))
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+func previewMeta(name, value string) string {
+	return fmt.Sprintf("\x1b[34m%-9s\x1b[0m %s\n", name, value)
+}
+
+// previewIsText reports whether head, the start of a file, reads as text:
+// valid UTF-8 without NUL bytes. A multi-byte rune cut at the end of head
+// does not count against it.
+func previewIsText(head []byte) bool {
+	if bytes.IndexByte(head, 0) >= 0 {
+		return false
+	}
+	for i := 0; i < utf8.UTFMax && len(head) > 0 && !utf8.Valid(head); i++ {
+		head = head[:len(head)-1]
+	}
+	return utf8.Valid(head)
+}
@@ -0,0 +1,103 @@
+package sprout
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const savedFiltersFile = "filters.json"
+
+var savedFiltersMu sync.Mutex
+
+// savedFilters holds the last worktree filter query of each repository, keyed
+// by repository root, so the TUI can restore it in the next session.
+type savedFilters struct {
+	Repos map[string]string `json:"repos"`
+}
+
+func savedFiltersPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "sprout", savedFiltersFile), nil
+}
+
+func readSavedFilters() (savedFilters, error) {
+	filters := savedFilters{Repos: map[string]string{}}
+	path, err := savedFiltersPath()
+	if err != nil {
+		return filters, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return filters, nil
+		}
+		return filters, err
+	}
+	if err := json.Unmarshal(data, &filters); err != nil {
+		return savedFilters{Repos: map[string]string{}}, err
+	}
+	if filters.Repos == nil {
+		filters.Repos = map[string]string{}
+	}
+	return filters, nil
+}
+
+func writeSavedFilters(filters savedFilters) error {
+	path, err := savedFiltersPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(filters, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// savedFilter returns the filter query last used in repoRoot, or "".
+func savedFilter(repoRoot string) string {
+	savedFiltersMu.Lock()
+	defer savedFiltersMu.Unlock()
+
+	filters, err := readSavedFilters()
+	if err != nil {
+		errorLogf("saved_filters read failed: %v", err)
+		return ""
+	}
+	return filters.Repos[repoRoot]
+}
+
+// saveFilter remembers query as the filter of repoRoot. An empty query
+// forgets it.
+func saveFilter(repoRoot, query string) error {
+	if strings.TrimSpace(repoRoot) == "" {
+		return nil
+	}
+	savedFiltersMu.Lock()
+	defer savedFiltersMu.Unlock()
+
+	filters, err := readSavedFilters()
+	if err != nil {
+		errorLogf("saved_filters read failed: %v", err)
+	}
+	query = strings.TrimSpace(query)
+	if filters.Repos[repoRoot] == query {
+		return nil
+	}
+	if query == "" {
+		delete(filters.Repos, repoRoot)
+	} else {
+		filters.Repos[repoRoot] = query
+	}
+	return writeSavedFilters(filters)
+}
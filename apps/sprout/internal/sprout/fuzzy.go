@@ -0,0 +1,163 @@
+package sprout
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rivo/tview"
+)
+
+// Fuzzy match scoring, loosely after fzf: every matched rune scores, runs of
+// adjacent matches and matches at word starts score more, and gaps cost.
+const (
+	fuzzyScoreMatch       = 16
+	fuzzyBonusConsecutive = 8
+	fuzzyBonusBoundary    = 10
+	fuzzyPenaltyGap       = 1
+)
+
+// fuzzyMatch reports whether the runes of pattern appear in text in order,
+// ignoring case. It returns a score, higher for tighter matches, and the byte
+// offsets in text of the matched runes. An empty pattern matches everything
+// with score 0.
+func fuzzyMatch(pattern, text string) (int, []int, bool) {
+	pat := []rune(strings.ToLower(strings.TrimSpace(pattern)))
+	if len(pat) == 0 {
+		return 0, nil, true
+	}
+	runes := []rune(text)
+	lower := []rune(strings.ToLower(text))
+	if len(lower) != len(runes) {
+		lower = runes
+	}
+
+	// Find the first full match, then walk back from its end to the latest
+	// start, which gives the shortest window ending there.
+	pi, end := 0, -1
+	for i, r := range lower {
+		if unicode.ToLower(r) == pat[pi] {
+			pi++
+			if pi == len(pat) {
+				end = i
+				break
+			}
+		}
+	}
+	if end < 0 {
+		return 0, nil, false
+	}
+	start := end
+	for pi = len(pat) - 1; start >= 0; start-- {
+		if unicode.ToLower(lower[start]) == pat[pi] {
+			if pi == 0 {
+				break
+			}
+			pi--
+		}
+	}
+
+	offsets := make([]int, len(runes)+1)
+	for i, off := 0, 0; i < len(runes); i++ {
+		offsets[i] = off
+		off += utf8.RuneLen(runes[i])
+	}
+
+	score := 0
+	positions := make([]int, 0, len(pat))
+	prev := -1
+	pi = 0
+	for i := start; i <= end && pi < len(pat); i++ {
+		if unicode.ToLower(lower[i]) != pat[pi] {
+			continue
+		}
+		score += fuzzyScoreMatch
+		if isFuzzyBoundary(runes, i) {
+			score += fuzzyBonusBoundary
+		}
+		if prev >= 0 {
+			if i == prev+1 {
+				score += fuzzyBonusConsecutive
+			} else {
+				score -= (i - prev - 1) * fuzzyPenaltyGap
+			}
+		}
+		positions = append(positions, offsets[i])
+		prev = i
+		pi++
+	}
+	return score, positions, true
+}
+
+// isFuzzyBoundary reports whether runes[i] starts a word: the first rune, one
+// after a separator, or an upper-case letter after a lower-case one.
+func isFuzzyBoundary(runes []rune, i int) bool {
+	if i == 0 {
+		return true
+	}
+	prev := runes[i-1]
+	switch prev {
+	case '/', '-', '_', '.', ' ':
+		return true
+	}
+	return unicode.IsLower(prev) && unicode.IsUpper(runes[i])
+}
+
+// highlightMatches truncates text to max bytes like truncate, escapes it for
+// a tview cell, and underlines the runes at the given byte offsets. Runs cut
+// off by the truncation are not highlighted, so the ellipsis stays plain.
+func highlightMatches(text string, positions []int, max int) string {
+	limit := len(text)
+	if limit > max {
+		limit = max
+		if max > 3 {
+			limit = max - 3
+		}
+	}
+	text = truncate(text, max)
+	if len(positions) == 0 {
+		return tview.Escape(text)
+	}
+	marked := make(map[int]bool, len(positions))
+	for _, p := range positions {
+		if p < limit {
+			marked[p] = true
+		}
+	}
+	var b strings.Builder
+	var run strings.Builder
+	inMatch := false
+	flush := func() {
+		if run.Len() == 0 {
+			return
+		}
+		if inMatch {
+			b.WriteString("[::bu]" + tview.Escape(run.String()) + "[::-]")
+		} else {
+			b.WriteString(tview.Escape(run.String()))
+		}
+		run.Reset()
+	}
+	for i, r := range text {
+		if marked[i] != inMatch {
+			flush()
+			inMatch = marked[i]
+		}
+		run.WriteRune(r)
+	}
+	flush()
+	return b.String()
+}
+
+// shiftOffsets returns positions moved n bytes right, for text that gained a
+// prefix after it was matched.
+func shiftOffsets(positions []int, n int) []int {
+	if n == 0 || len(positions) == 0 {
+		return positions
+	}
+	out := make([]int, len(positions))
+	for i, p := range positions {
+		out[i] = p + n
+	}
+	return out
+}
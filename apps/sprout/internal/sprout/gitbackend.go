@@ -0,0 +1,211 @@
+package sprout
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+)
+
+// Git backends for the status and branch queries run on every refresh.
+// exec runs the git binary; gogit reads the repository in-process with
+// go-git, saving a subprocess per worktree on large repositories. Anything
+// go-git cannot read falls back to exec.
+const (
+	gitBackendExec  = "exec"
+	gitBackendGoGit = "gogit"
+)
+
+func parseGitBackend(value string) (string, error) {
+	switch v := strings.ToLower(strings.TrimSpace(value)); v {
+	case "", gitBackendExec:
+		return gitBackendExec, nil
+	case gitBackendGoGit, "go-git":
+		return gitBackendGoGit, nil
+	}
+	return "", fmt.Errorf("invalid git_backend %q (want exec or gogit)", value)
+}
+
+// worktreeStatus lists the changed and untracked files of the worktree at
+// path, like git status --porcelain --untracked-files=all.
+func (m *Manager) worktreeStatus(ctx context.Context, path string) ([]DiffFile, error) {
+	if m.Cfg.GitBackend == gitBackendGoGit {
+		files, err := goGitStatus(path)
+		if err == nil {
+			return files, nil
+		}
+		debugLogf("git_backend gogit status failed path=%q, using git: %v", path, err)
+	}
+	out, err := m.runCmdOutputContext(ctx, path, "git", "--no-pager", "status", "--porcelain", "--untracked-files=all")
+	if err != nil {
+		return nil, err
+	}
+	return parsePorcelainFiles(out), nil
+}
+
+func parsePorcelainFiles(out string) []DiffFile {
+	lines := strings.Split(out, "\n")
+	files := make([]DiffFile, 0, len(lines))
+	seen := map[string]struct{}{}
+	for _, line := range lines {
+		line = strings.TrimRight(line, "\r")
+		if len(line) < 3 {
+			continue
+		}
+		status := line[:2]
+		file := strings.TrimSpace(line[3:])
+		if file == "" {
+			continue
+		}
+		orig := ""
+		if idx := strings.LastIndex(file, " -> "); idx >= 0 {
+			orig = strings.TrimSpace(file[:idx])
+			file = strings.TrimSpace(file[idx+4:])
+		}
+		if file == "" {
+			continue
+		}
+		if _, ok := seen[file]; ok {
+			continue
+		}
+		seen[file] = struct{}{}
+		files = append(files, DiffFile{
+			Path:     file,
+			Status:   status,
+			OrigPath: orig,
+		})
+	}
+	return files
+}
+
+// goGitStatus is worktreeStatus read with go-git. Files come in git status
+// order: tracked changes by path, then untracked files.
+func goGitStatus(path string) ([]DiffFile, error) {
+	repo, err := git.PlainOpenWithOptions(path, &git.PlainOpenOptions{EnableDotGitCommonDir: true})
+	if err != nil {
+		return nil, err
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	// go-git reads .gitignore files and info/exclude on its own, but not
+	// core.excludesFile.
+	if global, err := gitignore.LoadGlobalPatterns(osfs.New("/")); err == nil {
+		wt.Excludes = append(wt.Excludes, global...)
+	}
+	status, err := wt.Status()
+	if err != nil {
+		return nil, err
+	}
+	files := make([]DiffFile, 0, len(status))
+	for file, st := range status {
+		if st.Staging == git.Unmodified && st.Worktree == git.Unmodified {
+			continue
+		}
+		files = append(files, DiffFile{Path: file, Status: string([]byte{byte(st.Staging), byte(st.Worktree)})})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		iu, ju := files[i].Status == "??", files[j].Status == "??"
+		if iu != ju {
+			return ju
+		}
+		return files[i].Path < files[j].Path
+	})
+	return files, nil
+}
+
+// branchRef is a local or remote-tracking branch and its tip commit.
+type branchRef struct {
+	// Name is the short name: feat/x, or origin/feat/x when remote-tracking.
+	Name string
+	// Date is the committer date of the tip commit.
+	Date    time.Time
+	Author  string
+	Subject string
+}
+
+// branchRefs lists the local branches, or with remote the remote-tracking
+// branches, sorted by name. Symbolic refs like origin/HEAD are left out.
+func (m *Manager) branchRefs(repoRoot string, remote bool) []branchRef {
+	if m.Cfg.GitBackend == gitBackendGoGit {
+		refs, err := goGitBranchRefs(repoRoot, remote)
+		if err == nil {
+			return refs
+		}
+		debugLogf("git_backend gogit branches failed repo=%q, using git: %v", repoRoot, err)
+	}
+	prefix := "refs/heads/"
+	if remote {
+		prefix = "refs/remotes/"
+	}
+	out, _ := m.runCmdOutput(repoRoot, "git", "for-each-ref", "--format=%(refname)%09%(symref)%09%(committerdate:unix)%09%(authorname)%09%(contents:subject)", prefix)
+	var refs []branchRef
+	for _, line := range strings.Split(out, "\n") {
+		parts := strings.SplitN(strings.TrimRight(line, "\r"), "\t", 5)
+		if len(parts) != 5 || parts[1] != "" {
+			continue
+		}
+		ref := branchRef{Name: strings.TrimPrefix(parts[0], prefix), Author: parts[3], Subject: parts[4]}
+		if sec, err := strconv.ParseInt(parts[2], 10, 64); err == nil {
+			ref.Date = time.Unix(sec, 0)
+		}
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+func goGitBranchRefs(repoRoot string, remote bool) ([]branchRef, error) {
+	repo, err := git.PlainOpenWithOptions(repoRoot, &git.PlainOpenOptions{EnableDotGitCommonDir: true})
+	if err != nil {
+		return nil, err
+	}
+	iter, err := repo.References()
+	if err != nil {
+		return nil, err
+	}
+	var refs []branchRef
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		name := ref.Name()
+		if ref.Type() != plumbing.HashReference || !((remote && name.IsRemote()) || (!remote && name.IsBranch())) {
+			return nil
+		}
+		commit, err := repo.CommitObject(ref.Hash())
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		refs = append(refs, branchRef{
+			Name:    name.Short(),
+			Date:    time.Unix(commit.Committer.When.Unix(), 0),
+			Author:  commit.Author.Name,
+			Subject: commitSubject(commit.Message),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(refs, func(i, j int) bool { return refs[i].Name < refs[j].Name })
+	return refs, nil
+}
+
+// commitSubject is the subject of a commit message the way git prints it:
+// the first paragraph with its lines joined by spaces.
+func commitSubject(message string) string {
+	var lines []string
+	for _, line := range strings.Split(strings.TrimLeft(message, "\n"), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, " ")
+}
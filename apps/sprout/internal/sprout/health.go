@@ -0,0 +1,91 @@
+package sprout
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// HealthProblem is a misconfiguration found by StartupHealth, with a hint on
+// how to fix it.
+type HealthProblem struct {
+	Message string
+	Hint    string
+}
+
+// StartupHealth runs the quick checks shown when the TUI starts. Unlike
+// Doctor it only reports problems that would otherwise surface later as
+// failures inside a modal.
+func (m *Manager) StartupHealth(repoRoot string) []HealthProblem {
+	var problems []HealthProblem
+
+	if !m.commandExists("tmux") {
+		problems = append(problems, HealthProblem{
+			Message: "tmux is not installed",
+			Hint:    "install tmux to launch sessions and agents",
+		})
+	}
+
+	root := m.WorktreeRootDir(repoRoot)
+	if err := checkDirWritable(root); err != nil {
+		problems = append(problems, HealthProblem{
+			Message: fmt.Sprintf("worktree root %s is not writable: %v", root, err),
+			Hint:    "fix its permissions or change worktree_root_template",
+		})
+	}
+
+	agentExec := commandExecutableName(m.agentCommand())
+	if agentExec != "" && !m.commandExists(agentExec) {
+		problems = append(problems, HealthProblem{
+			Message: fmt.Sprintf("agent command %q not found", agentExec),
+			Hint:    "install it or set agent_command / default_agent_type",
+		})
+	}
+	for _, name := range m.sessionToolExecutables() {
+		if name == agentExec || m.commandExists(name) {
+			continue
+		}
+		problems = append(problems, HealthProblem{
+			Message: fmt.Sprintf("session tool %q not found", name),
+			Hint:    "install it or remove it from session_tools",
+		})
+	}
+
+	if !m.baseBranchExists(repoRoot) {
+		problems = append(problems, HealthProblem{
+			Message: fmt.Sprintf("base branch %q not found", m.Cfg.BaseBranch),
+			Hint:    "set base_branch; new worktrees fall back to the current branch",
+		})
+	}
+	return problems
+}
+
+// checkDirWritable reports whether files can be created in dir, or in its
+// nearest existing parent when dir does not exist yet.
+func checkDirWritable(dir string) error {
+	for {
+		st, err := os.Stat(dir)
+		if err == nil {
+			if !st.IsDir() {
+				return fmt.Errorf("%s is not a directory", dir)
+			}
+			break
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return err
+		}
+		dir = parent
+	}
+	f, err := os.CreateTemp(dir, ".sprout-write-check-*")
+	if err != nil {
+		return err
+	}
+	name := f.Name()
+	_ = f.Close()
+	return os.Remove(name)
+}
@@ -0,0 +1,159 @@
+package sprout
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	promptHistoryFile  = "prompt-history.json"
+	promptHistoryLimit = 50
+)
+
+var promptHistoryMu sync.Mutex
+
+// errPromptHistoryCorrupt reports a prompt history file that is not valid
+// JSON.
+var errPromptHistoryCorrupt = errors.New("prompt history is corrupt")
+
+// PromptHistoryEntry is a single prompt sent to a worktree's agent.
+type PromptHistoryEntry struct {
+	Prompt string    `json:"prompt"`
+	SentAt time.Time `json:"sent_at"`
+}
+
+type promptHistory struct {
+	Worktrees map[string][]PromptHistoryEntry `json:"worktrees"`
+}
+
+func promptHistoryPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "sprout", promptHistoryFile), nil
+}
+
+func readPromptHistory() (promptHistory, error) {
+	history := promptHistory{Worktrees: map[string][]PromptHistoryEntry{}}
+	path, err := promptHistoryPath()
+	if err != nil {
+		return history, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return history, nil
+		}
+		return history, err
+	}
+	if err := json.Unmarshal(data, &history); err != nil {
+		return promptHistory{Worktrees: map[string][]PromptHistoryEntry{}}, fmt.Errorf("%w: %s: %v", errPromptHistoryCorrupt, path, err)
+	}
+	if history.Worktrees == nil {
+		history.Worktrees = map[string][]PromptHistoryEntry{}
+	}
+	return history, nil
+}
+
+func writePromptHistory(history promptHistory) error {
+	path, err := promptHistoryPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(history, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// recordPrompt appends prompt to the history of worktreePath. Repeating the
+// most recent prompt moves it to the end instead of storing a duplicate. A
+// history file that does not parse is moved aside to a .corrupt file and a
+// new one started, so a bad edit loses nothing; one that cannot be read is
+// left alone.
+func recordPrompt(worktreePath, prompt string) error {
+	prompt = strings.TrimSpace(prompt)
+	if prompt == "" || strings.TrimSpace(worktreePath) == "" {
+		return nil
+	}
+
+	promptHistoryMu.Lock()
+	defer promptHistoryMu.Unlock()
+
+	history, err := readPromptHistory()
+	if errors.Is(err, errPromptHistoryCorrupt) {
+		path, _ := promptHistoryPath()
+		errorLogf("prompt_history read failed, moving it to %s.corrupt: %v", path, err)
+		if err := os.Rename(path, path+".corrupt"); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+	entries := history.Worktrees[worktreePath]
+	if n := len(entries); n > 0 && entries[n-1].Prompt == prompt {
+		entries = entries[:n-1]
+	}
+	entries = append(entries, PromptHistoryEntry{Prompt: prompt, SentAt: time.Now()})
+	if len(entries) > promptHistoryLimit {
+		entries = entries[len(entries)-promptHistoryLimit:]
+	}
+	history.Worktrees[worktreePath] = entries
+	return writePromptHistory(history)
+}
+
+func promptHistoryFor(worktreePath string) []PromptHistoryEntry {
+	promptHistoryMu.Lock()
+	defer promptHistoryMu.Unlock()
+
+	history, err := readPromptHistory()
+	if err != nil {
+		errorLogf("prompt_history read failed: %v", err)
+		return nil
+	}
+	return append([]PromptHistoryEntry(nil), history.Worktrees[worktreePath]...)
+}
+
+// renamePromptHistory carries the prompt history of a moved worktree over to
+// its new path.
+func renamePromptHistory(oldPath, newPath string) error {
+	promptHistoryMu.Lock()
+	defer promptHistoryMu.Unlock()
+
+	history, err := readPromptHistory()
+	if err != nil {
+		return err
+	}
+	entries, ok := history.Worktrees[oldPath]
+	if !ok {
+		return nil
+	}
+	delete(history.Worktrees, oldPath)
+	history.Worktrees[newPath] = entries
+	return writePromptHistory(history)
+}
+
+// PromptHistory returns the prompts previously sent to the agent of target,
+// oldest first.
+func (m *Manager) PromptHistory(target string) (string, []PromptHistoryEntry, error) {
+	repoRoot, err := m.RequireRepo()
+	if err != nil {
+		return "", nil, err
+	}
+	wt, err := m.findWorktreeLite(repoRoot, target)
+	if err != nil {
+		return "", nil, err
+	}
+	return wt.Path, promptHistoryFor(wt.Path), nil
+}
@@ -0,0 +1,200 @@
+package sprout
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Hooks of the [hooks] table: shell commands run when something happens to
+// a worktree.
+const (
+	hookOnCreate     = "on_create"
+	hookOnRemove     = "on_remove"
+	hookOnAgentReady = "on_agent_ready"
+	hookOnAttach     = "on_attach"
+)
+
+var hookNames = []string{hookOnCreate, hookOnRemove, hookOnAgentReady, hookOnAttach}
+
+// hookEnvPrefix names the environment variables that set a hook, such as
+// SPROUT_HOOK_ON_CREATE.
+const hookEnvPrefix = "SPROUT_HOOK_"
+
+// eventHooks maps the events that run a hook to it. on_attach has no event
+// of its own; it runs when sprout attaches to a worktree's session.
+var eventHooks = map[string]string{
+	eventWorktreeCreated: hookOnCreate,
+	eventWorktreeRemoved: hookOnRemove,
+	eventAgentReady:      hookOnAgentReady,
+}
+
+func parseHookName(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, known := range hookNames {
+		if name == known {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("unknown hook: %q (want %s)", name, strings.Join(hookNames, ", "))
+}
+
+// parseHookLine reads a line of a hooks table, such as
+// on_create = "make deps", into cfg.
+func parseHookLine(cfg *Config, line string) error {
+	key, value, ok := strings.Cut(line, "=")
+	if !ok {
+		return nil
+	}
+	name, err := parseHookName(key)
+	if err != nil {
+		return err
+	}
+	command, err := parseString(strings.TrimSpace(value))
+	if err != nil {
+		return fmt.Errorf("invalid hooks.%s: %w", name, err)
+	}
+	if cfg.Hooks == nil {
+		cfg.Hooks = map[string]string{}
+	}
+	cfg.Hooks[name] = command
+	return nil
+}
+
+// hookConfigKey is how sprout config show names a hook: hooks.on_create.
+func hookConfigKey(name string) string {
+	return "hooks." + strings.ToLower(strings.TrimSpace(name))
+}
+
+// hooksTable is the table holding the hooks of a table of the config file:
+// [hooks] at the top level, or [repos.<repo>.hooks] for a repository.
+func hooksTable(table string) string {
+	return nestedConfigTable(table, "hooks")
+}
+
+// sortedHooks lists the hooks set in hooks, in hookNames order.
+func sortedHooks(hooks map[string]string) []string {
+	names := make([]string, 0, len(hooks))
+	for name := range hooks {
+		names = append(names, name)
+	}
+	sort.SliceStable(names, func(i, j int) bool { return hookIndex(names[i]) < hookIndex(names[j]) })
+	return names
+}
+
+func hookIndex(name string) int {
+	for i, known := range hookNames {
+		if name == known {
+			return i
+		}
+	}
+	return len(hookNames)
+}
+
+// hookLogPath is where hooks write their output: next to the debug log,
+// which records when each hook started and how it exited.
+func hookLogPath() string {
+	return filepath.Join(filepath.Dir(debugLogFilePath()), "sprout-hooks.log")
+}
+
+// hookEnv is the environment of a hook run for ev: sprout's own, with the
+// event's worktree, branch, and repository.
+func hookEnv(name string, ev Event) []string {
+	return append(os.Environ(),
+		"SPROUT_HOOK="+name,
+		"SPROUT_EVENT="+ev.Type,
+		"SPROUT_REPO="+ev.Repo,
+		"SPROUT_REPO_ROOT="+ev.RepoRoot,
+		"SPROUT_BRANCH="+ev.Branch,
+		"SPROUT_WORKTREE="+ev.Path,
+		"SPROUT_SESSION="+ev.Session,
+	)
+}
+
+// runEventHook runs the hook ev triggers, if one is configured.
+func (m *Manager) runEventHook(ev Event) {
+	if name, ok := eventHooks[ev.Type]; ok {
+		m.runHook(name, ev)
+	}
+}
+
+// runAttachHook runs on_attach for wt, before sprout attaches to its
+// session; attaching outside tmux only returns once the user detaches.
+func (m *Manager) runAttachHook(repoRoot string, wt *Worktree) {
+	if strings.TrimSpace(m.Cfg.Hooks[hookOnAttach]) == "" || m.preview != nil {
+		return
+	}
+	m.runHook(hookOnAttach, Event{
+		Time:     time.Now().UTC(),
+		Repo:     m.RepoName(repoRoot),
+		RepoRoot: m.mainRepoRoot(repoRoot),
+		Branch:   worktreeBranchOrName(wt),
+		Path:     wt.Path,
+		Session:  m.tmuxWorktreeSessionName(repoRoot, wt),
+	})
+}
+
+// hookShell returns the command line that runs a hook's command on goos:
+// sh -c, or cmd /C on Windows, which has no sh.
+func hookShell(goos, command string) []string {
+	if goos == "windows" {
+		return []string{"cmd", "/C", command}
+	}
+	return []string{"sh", "-c", command}
+}
+
+// runHook starts the command of hook name with hookShell, in the event's
+// worktree, or in the repository when the worktree is gone. It does not wait
+// for the command: its output goes to hookLogPath, and the hook outlives a
+// sprout command that exits first.
+func (m *Manager) runHook(name string, ev Event) {
+	command := strings.TrimSpace(m.Cfg.Hooks[name])
+	if command == "" {
+		return
+	}
+	dir := ""
+	for _, candidate := range []string{ev.Path, ev.RepoRoot} {
+		if info, err := os.Stat(candidate); candidate != "" && err == nil && info.IsDir() {
+			dir = candidate
+			break
+		}
+	}
+	logPath := hookLogPath()
+	if err := os.MkdirAll(filepath.Dir(logPath), 0o755); err != nil {
+		errorLogf("hook %s log open failed path=%q: %v", name, logPath, err)
+		return
+	}
+	out, err := os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		errorLogf("hook %s log open failed path=%q: %v", name, logPath, err)
+		return
+	}
+	fmt.Fprintf(out, "%s %s branch=%q path=%q: %s\n", time.Now().Format(time.RFC3339), name, ev.Branch, ev.Path, command)
+
+	argv := hookShell(runtime.GOOS, command)
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Dir = dir
+	cmd.Env = hookEnv(name, ev)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		out.Close()
+		errorLogf("hook %s start failed branch=%q: %v", name, ev.Branch, err)
+		return
+	}
+	infoLogf("hook %s started pid=%d branch=%q path=%q", name, cmd.Process.Pid, ev.Branch, ev.Path)
+	go func() {
+		defer out.Close()
+		if err := cmd.Wait(); err != nil {
+			errorLogf("hook %s failed branch=%q duration_ms=%d: %v (output in %s)", name, ev.Branch, time.Since(start).Milliseconds(), err, logPath)
+			return
+		}
+		infoLogf("hook %s done branch=%q duration_ms=%d", name, ev.Branch, time.Since(start).Milliseconds())
+	}()
+}
@@ -0,0 +1,135 @@
+package sprout
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// branchLabelsKey is the git config variable, under branch.<name>, that
+// holds a branch's labels, comma-separated. Like the priority, they follow
+// the branch through `git branch -m`.
+const branchLabelsKey = "sproutlabels"
+
+var labelRe = regexp.MustCompile(`^[a-z0-9][a-z0-9._/-]*$`)
+
+func parseLabel(value string) (string, error) {
+	label := strings.ToLower(strings.TrimSpace(value))
+	if !labelRe.MatchString(label) {
+		return "", fmt.Errorf("invalid label %q (want letters, digits, and . _ / -)", value)
+	}
+	return label, nil
+}
+
+func parseLabels(values []string) ([]string, error) {
+	out := make([]string, 0, len(values))
+	for _, value := range values {
+		label, err := parseLabel(value)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, label)
+	}
+	return out, nil
+}
+
+// splitLabels reads a stored label list, dropping what does not parse.
+func splitLabels(value string) []string {
+	labels := []string{}
+	for _, part := range strings.Split(value, ",") {
+		if label, err := parseLabel(part); err == nil {
+			labels = append(labels, label)
+		}
+	}
+	return normalizeLabels(labels)
+}
+
+// normalizeLabels sorts labels and drops duplicates.
+func normalizeLabels(labels []string) []string {
+	sort.Strings(labels)
+	out := labels[:0]
+	for i, label := range labels {
+		if i == 0 || label != labels[i-1] {
+			out = append(out, label)
+		}
+	}
+	return out
+}
+
+// branchLabels reads the labels of every labeled branch of a repository.
+func (m *Manager) branchLabels(repoRoot string) map[string][]string {
+	res := map[string][]string{}
+	for branch, value := range m.branchConfigValues(repoRoot, branchLabelsKey) {
+		if labels := splitLabels(value); len(labels) > 0 {
+			res[branch] = labels
+		}
+	}
+	return res
+}
+
+// hasLabels reports whether wt carries every one of labels.
+func hasLabels(wt Worktree, labels []string) bool {
+	for _, label := range labels {
+		if !containsString(wt.Labels, label) {
+			return false
+		}
+	}
+	return true
+}
+
+// AddLabels adds labels to the target worktree's branch and returns its
+// path and labels.
+func (m *Manager) AddLabels(target string, labels []string) (string, []string, error) {
+	return m.updateLabels(target, labels, func(current, labels []string) []string {
+		return append(current, labels...)
+	})
+}
+
+// RemoveLabels removes labels from the target worktree's branch; with none
+// given, it removes them all.
+func (m *Manager) RemoveLabels(target string, labels []string) (string, []string, error) {
+	return m.updateLabels(target, labels, func(current, labels []string) []string {
+		if len(labels) == 0 {
+			return nil
+		}
+		kept := []string{}
+		for _, label := range current {
+			if !containsString(labels, label) {
+				kept = append(kept, label)
+			}
+		}
+		return kept
+	})
+}
+
+func (m *Manager) updateLabels(target string, labels []string, update func(current, labels []string) []string) (string, []string, error) {
+	labels, err := parseLabels(labels)
+	if err != nil {
+		return "", nil, err
+	}
+	repoRoot, err := m.RequireRepo()
+	if err != nil {
+		return "", nil, err
+	}
+	wt, err := m.findWorktreeLite(repoRoot, target)
+	if err != nil {
+		return "", nil, err
+	}
+	if wt.Branch == "" {
+		return "", nil, fmt.Errorf("cannot label detached worktree: %s", wt.Path)
+	}
+	current := m.branchLabels(repoRoot)[wt.Branch]
+	next := normalizeLabels(update(append([]string{}, current...), labels))
+	key := "branch." + wt.Branch + "." + branchLabelsKey
+	if len(next) == 0 {
+		// Exit status 5 means the key was not set.
+		if _, err := m.runCmdOutputAllowExitCodes(repoRoot, []int{5}, "git", "config", "--unset", key); err != nil {
+			return "", nil, err
+		}
+	} else if err := m.runCmdQuiet(repoRoot, "git", "config", key, strings.Join(next, ",")); err != nil {
+		return "", nil, err
+	}
+	infoLogf("set_labels done path=%q branch=%q labels=%q", wt.Path, wt.Branch, strings.Join(next, ","))
+	return wt.Path, next, nil
+}
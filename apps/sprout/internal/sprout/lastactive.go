@@ -0,0 +1,173 @@
+package sprout
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Worktree orders accepted by the sort setting and sprout list --sort.
+const (
+	sortPath   = "path"
+	sortActive = "active"
+	sortIdle   = "idle"
+)
+
+func parseWorktreeSort(value string) (string, error) {
+	switch v := strings.ToLower(strings.TrimSpace(value)); v {
+	case "", sortPath:
+		return sortPath, nil
+	case sortActive, sortIdle:
+		return v, nil
+	}
+	return "", fmt.Errorf("invalid sort %q (want path, active, or idle)", value)
+}
+
+// nextWorktreeSort is the order after mode, for cycling through them.
+func nextWorktreeSort(mode string) string {
+	switch mode {
+	case sortPath, "":
+		return sortActive
+	case sortActive:
+		return sortIdle
+	}
+	return sortPath
+}
+
+// worktreeLess orders a before b for mode: the current worktree first and
+// then by path, most recently active first, or least recently active first.
+// Worktrees with no known activity go last when sorting by activity.
+func worktreeLess(a, b *Worktree, mode string) bool {
+	switch mode {
+	case sortActive, sortIdle:
+		if a.LastActive.IsZero() != b.LastActive.IsZero() {
+			return b.LastActive.IsZero()
+		}
+		if !a.LastActive.Equal(b.LastActive) {
+			if mode == sortActive {
+				return a.LastActive.After(b.LastActive)
+			}
+			return a.LastActive.Before(b.LastActive)
+		}
+		return a.Path < b.Path
+	}
+	if a.Current != b.Current {
+		return a.Current
+	}
+	return a.Path < b.Path
+}
+
+// sortWorktrees orders items for mode.
+func sortWorktrees(items []Worktree, mode string) {
+	sort.SliceStable(items, func(i, j int) bool {
+		return worktreeLess(&items[i], &items[j], mode)
+	})
+}
+
+// branchTip is the commit a local branch points at and its commit time.
+type branchTip struct {
+	Commit string
+	Time   time.Time
+}
+
+// branchTips reads the tip of every local branch in one git call.
+func (m *Manager) branchTips(repoRoot string) map[string]branchTip {
+	res := map[string]branchTip{}
+	out, err := m.runCmdOutput(repoRoot, "git", "for-each-ref", "--format=%(objectname) %(committerdate:unix) %(refname:short)", "refs/heads")
+	if err != nil {
+		debugLogf("branch_tips failed repo=%q: %v", repoRoot, err)
+		return res
+	}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.SplitN(strings.TrimSpace(line), " ", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		tip := branchTip{Commit: fields[0]}
+		if sec, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+			tip.Time = time.Unix(sec, 0)
+		}
+		res[fields[2]] = tip
+	}
+	return res
+}
+
+// worktreeGitDir is the git dir of a worktree: .git itself in the main
+// worktree, or the directory the .git file of a linked one points at. In SSH
+// remote mode git reports it, which costs one command rather than two.
+func (m *Manager) worktreeGitDir(worktreePath string) string {
+	if m.sshRemoteActive() {
+		out, err := m.runCmdOutput(worktreePath, "git", "rev-parse", "--absolute-git-dir")
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(out)
+	}
+	dotGit := filepath.Join(worktreePath, ".git")
+	info, err := os.Stat(dotGit)
+	if err != nil {
+		return ""
+	}
+	if info.IsDir() {
+		return dotGit
+	}
+	data, err := os.ReadFile(dotGit)
+	if err != nil {
+		return ""
+	}
+	dir, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir:")
+	if !ok {
+		return ""
+	}
+	dir = strings.TrimSpace(dir)
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(worktreePath, dir)
+	}
+	return dir
+}
+
+// worktreeLastActive is when a worktree was last touched: the latest of its
+// HEAD commit, its index, which git writes when staging, checking out, or
+// when a status finds files changed, and its agent's pane activity. Any of
+// them may be zero.
+func (m *Manager) worktreeLastActive(headCommit time.Time, worktreePath string, agentActivity int64) time.Time {
+	latest := headCommit
+	if gitDir := m.worktreeGitDir(worktreePath); gitDir != "" {
+		if info, err := m.statPath(filepath.Join(gitDir, "index")); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	if agentActivity > 0 {
+		if t := time.Unix(agentActivity, 0); t.After(latest) {
+			latest = t
+		}
+	}
+	return latest
+}
+
+// headCommitTime is the commit time of a worktree's HEAD, for detached
+// worktrees that branchTips does not cover.
+func (m *Manager) headCommitTime(worktreePath string) time.Time {
+	out, err := m.runCmdOutput(worktreePath, "git", "log", "-1", "--format=%ct")
+	if err != nil {
+		return time.Time{}
+	}
+	sec, err := strconv.ParseInt(strings.TrimSpace(out), 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
+}
+
+// formatLastActive renders how long ago a worktree was last touched, or ""
+// when that is not known.
+func formatLastActive(t, now time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return formatAge(t, now)
+}
@@ -0,0 +1,68 @@
+package sprout
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// Launch backends accepted by the launch_backend setting.
+const (
+	launchBackendTmux = "tmux"
+	launchBackendWT   = "wt"
+	launchBackendNone = "none"
+)
+
+// errNoLaunchBackend is returned by launches when there is no terminal to
+// open the worktree in.
+var errNoLaunchBackend = errors.New("no terminal to launch in: install tmux, or set launch_backend = \"wt\" to use Windows Terminal")
+
+func parseLaunchBackend(value string) (string, error) {
+	switch v := strings.ToLower(strings.TrimSpace(value)); v {
+	case "", launchBackendTmux:
+		return launchBackendTmux, nil
+	case launchBackendWT, launchBackendNone:
+		return v, nil
+	}
+	return "", fmt.Errorf("invalid launch_backend %q (want tmux, wt, or none)", value)
+}
+
+// launchBackend is the backend worktrees are launched with. Without tmux
+// installed the tmux backend becomes none: worktrees are still created,
+// listed, and removed, just without sessions or agents.
+func (m *Manager) launchBackend() string {
+	switch m.Cfg.LaunchBackend {
+	case launchBackendWT, launchBackendNone:
+		return m.Cfg.LaunchBackend
+	}
+	if !m.commandExists("tmux") {
+		return launchBackendNone
+	}
+	return launchBackendTmux
+}
+
+// autoStartAgent reports whether new worktrees get an agent, which needs a
+// backend to run it in.
+func (m *Manager) autoStartAgent() bool {
+	return m.Cfg.AutoStartAgent && m.launchBackend() != launchBackendNone
+}
+
+// wtOpenTab opens a Windows Terminal tab titled title in dir, running command
+// in a shell that stays open after it, or just the shell when command is
+// empty.
+func (m *Manager) wtOpenTab(title, dir, command string) error {
+	if !m.commandExists("wt") {
+		return errors.New("Windows Terminal (wt) is required for launch_backend = \"wt\"")
+	}
+	args := []string{"-w", "0", "new-tab", "--title", title, "-d", dir}
+	if command != "" {
+		shell := []string{"powershell", "-NoExit", "-Command"}
+		if runtime.GOOS != "windows" {
+			shell = []string{defaultShellCommand(), "-c"}
+		}
+		// wt splits its command line into subcommands at semicolons.
+		args = append(append(args, shell...), strings.ReplaceAll(command, ";", `\;`))
+	}
+	return m.runCmdQuiet("", "wt", args...)
+}
@@ -0,0 +1,112 @@
+package sprout
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// tmuxPreview stands in for tmux while sprout launch --dry-run builds a
+// session: it records the commands instead of running them and keeps track
+// of the sessions and windows they would have created.
+type tmuxPreview struct {
+	commands []string
+	sessions map[string]bool
+	windows  map[string]bool
+}
+
+func newTmuxPreview() *tmuxPreview {
+	return &tmuxPreview{sessions: map[string]bool{}, windows: map[string]bool{}}
+}
+
+// plainShellArg matches arguments that need no quoting in a shell.
+var plainShellArg = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./{}-]+$`)
+
+func (p *tmuxPreview) record(name string, args ...string) {
+	words := []string{name}
+	for _, arg := range args {
+		switch {
+		case arg == ";":
+			words = append(words, `\;`)
+		case plainShellArg.MatchString(arg):
+			words = append(words, arg)
+		default:
+			words = append(words, shellQuote(arg))
+		}
+	}
+	p.commands = append(p.commands, strings.Join(words, " "))
+}
+
+func (p *tmuxPreview) run(args []string) {
+	p.record("tmux", args...)
+	flag := func(name string) string {
+		for i := 0; i+1 < len(args) && args[i] != ";"; i++ {
+			if args[i] == name {
+				return args[i+1]
+			}
+		}
+		return ""
+	}
+	switch args[0] {
+	case "new-session":
+		p.sessions[flag("-s")] = true
+		p.windows[flag("-s")+":"+flag("-n")] = true
+	case "new-window":
+		p.windows[flag("-t")+":"+flag("-n")] = true
+	}
+}
+
+// tmux runs a tmux command that changes sessions, or records it when
+// previewing a launch.
+func (m *Manager) tmux(args ...string) error {
+	if m.preview != nil {
+		m.preview.run(args)
+		return nil
+	}
+	return m.runCmdQuiet("", "tmux", args...)
+}
+
+// LaunchPreview is what sprout launch --dry-run prints: the commands that
+// create the tmux session of a worktree from scratch with the current
+// config. Exists reports that the session is already running, in which case
+// a real launch only focuses it.
+type LaunchPreview struct {
+	Path     string   `json:"path"`
+	Session  string   `json:"session"`
+	Window   string   `json:"window"`
+	Exists   bool     `json:"exists"`
+	Commands []string `json:"commands"`
+}
+
+// PreviewLaunch works out the commands sprout launch would run for target
+// without running any of them.
+func (m *Manager) PreviewLaunch(target string) (LaunchPreview, error) {
+	repoRoot, err := m.RequireRepo()
+	if err != nil {
+		return LaunchPreview{}, err
+	}
+	wt, err := m.FindWorktree(target)
+	if err != nil {
+		return LaunchPreview{}, err
+	}
+	if backend := m.launchBackend(); backend != launchBackendTmux {
+		return LaunchPreview{}, fmt.Errorf("--dry-run previews tmux sessions, but launch_backend is %s", backend)
+	}
+	if m.preview != nil {
+		return LaunchPreview{}, errors.New("a launch preview is already running")
+	}
+	branch := worktreeBranchOrName(wt)
+	session := m.tmuxWorktreeSessionNameFrom(repoRoot, branch, wt.Path)
+	preview := LaunchPreview{Path: wt.Path, Session: session, Exists: m.tmuxHasSession(session)}
+
+	m.preview = newTmuxPreview()
+	defer func() { m.preview = nil }()
+	_, window, err := m.tmuxEnsureWorktreeWindow(repoRoot, branch, wt.Path)
+	if err != nil {
+		return LaunchPreview{}, err
+	}
+	preview.Window = window
+	preview.Commands = m.preview.commands
+	return preview, nil
+}
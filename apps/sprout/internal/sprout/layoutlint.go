@@ -0,0 +1,154 @@
+package sprout
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// tmuxLayouts are the layout names tmux select-layout accepts.
+var tmuxLayouts = []string{
+	"even-horizontal", "even-vertical", "main-horizontal", "main-horizontal-mirrored",
+	"main-vertical", "main-vertical-mirrored", "tiled",
+}
+
+// tmuxLayoutString matches a layout saved from tmux list-windows, such as
+// "bb62,159x48,0,0{79x48,0,0,79x48,80,0}".
+var tmuxLayoutString = regexp.MustCompile(`^[0-9a-f]{4},\d+x\d+,`)
+
+// shellBuiltins are run commands that are not looked up on PATH.
+var shellBuiltins = map[string]bool{
+	"cd": true, "export": true, "source": true, ".": true, "exec": true, "set": true,
+	"unset": true, "alias": true, "eval": true, "true": true, "false": true, "test": true, "[": true,
+}
+
+// LayoutIssue is a problem in the [[windows]] config found by LintWindows.
+// Pane is 1-based; 0 means the problem is with the window itself.
+type LayoutIssue struct {
+	Window  string `json:"window"`
+	Pane    int    `json:"pane,omitempty"`
+	Message string `json:"message"`
+}
+
+func (i LayoutIssue) String() string {
+	if i.Pane > 0 {
+		return fmt.Sprintf("window %q pane %d: %s", i.Window, i.Pane, i.Message)
+	}
+	return fmt.Sprintf("window %q: %s", i.Window, i.Message)
+}
+
+// LintWindows checks the configured [[windows]] for what would make a launch
+// go wrong or surprise: unknown layouts, windows without panes, duplicate
+// window names, several focused panes, dirs that do not exist in
+// worktreePath, and pane commands that are not installed. Commands are not
+// checked when container_command runs them in a container.
+func (m *Manager) LintWindows(worktreePath string) []LayoutIssue {
+	issues := []LayoutIssue{}
+	seen := map[string]int{}
+	for i, win := range m.Cfg.Windows {
+		name := trimTmuxWindowName(win.Name)
+		if name == "" {
+			name = fmt.Sprintf("window-%d", i+1)
+		}
+		add := func(pane int, format string, args ...any) {
+			issues = append(issues, LayoutIssue{Window: name, Pane: pane, Message: fmt.Sprintf(format, args...)})
+		}
+		if first, dup := seen[name]; dup {
+			add(0, "same name as window %d; tmux would reuse that window", first)
+		} else {
+			seen[name] = i + 1
+		}
+		if layout := strings.TrimSpace(win.Layout); layout != "" && !validTmuxLayout(layout) {
+			add(0, "unknown layout %q (want %s)", layout, strings.Join(tmuxLayouts, ", "))
+		}
+		if len(win.Panes) == 0 {
+			add(0, "no [[windows.panes]]; the window opens a shell")
+		}
+		focused, last := 0, 0
+		for j, pane := range win.Panes {
+			if pane.Focus {
+				focused, last = focused+1, j+1
+			}
+		}
+		if focused > 1 {
+			add(0, "%d panes set focus; only pane %d is focused", focused, last)
+		}
+		winDir := m.worktreeStartDir(worktreePath)
+		if win.Dir != "" {
+			winDir = resolvePaneDir(win.Dir, worktreePath)
+			if !m.dirExists(winDir) {
+				add(0, "dir %q does not exist (%s)", win.Dir, winDir)
+			}
+		}
+		for j, pane := range win.Panes {
+			paneDir := winDir
+			if pane.Dir != "" {
+				paneDir = resolvePaneDir(pane.Dir, worktreePath)
+				if !m.dirExists(paneDir) {
+					add(j+1, "dir %q does not exist (%s)", pane.Dir, paneDir)
+				}
+			}
+			run := strings.TrimSpace(pane.Run)
+			if run == "" {
+				if pane.Dir == "" {
+					add(j+1, "empty pane; set run or dir, or leave it out")
+				}
+				continue
+			}
+			if m.Cfg.ContainerCommand != "" {
+				continue
+			}
+			if exe := paneExecutable(run); exe != "" && !m.paneExecutableExists(exe, paneDir) {
+				add(j+1, "%s is not installed", exe)
+			}
+		}
+	}
+	return issues
+}
+
+func validTmuxLayout(layout string) bool {
+	for _, name := range tmuxLayouts {
+		if layout == name {
+			return true
+		}
+	}
+	return tmuxLayoutString.MatchString(layout)
+}
+
+// paneExecutable returns the program a pane's run command starts, skipping
+// leading VAR=value assignments. It is empty for shell builtins.
+func paneExecutable(run string) string {
+	for _, field := range strings.Fields(run) {
+		if strings.Contains(field, "=") && !strings.Contains(field, "/") {
+			continue
+		}
+		field = strings.Trim(field, `"'`)
+		if shellBuiltins[field] {
+			return ""
+		}
+		return field
+	}
+	return ""
+}
+
+// paneExecutableExists looks exe up on PATH, or in dir when it is a path.
+func (m *Manager) paneExecutableExists(exe, dir string) bool {
+	if !strings.Contains(exe, "/") {
+		return m.commandExists(exe)
+	}
+	if strings.HasPrefix(exe, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			exe = filepath.Join(home, exe[2:])
+		}
+	}
+	if !filepath.IsAbs(exe) {
+		exe = filepath.Join(dir, exe)
+	}
+	if m.sshRemoteActive() {
+		return m.runCmdQuiet("", "sh", "-c", `test -e "$1"`, "sh", exe) == nil
+	}
+	_, err := os.Stat(exe)
+	return err == nil
+}
@@ -0,0 +1,136 @@
+package sprout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	repoLockFile         = "sprout.lock"
+	repoLockPollInterval = 100 * time.Millisecond
+)
+
+// errRepoBusy is wrapped by the error of an operation that found another
+// sprout process holding the repository lock.
+var errRepoBusy = errors.New("another sprout operation is in progress in this repository")
+
+// heldRepoLock is a repository lock this process holds, with how many
+// operations are using it.
+type heldRepoLock struct {
+	file  *os.File
+	count int
+}
+
+var (
+	repoLocksMu sync.Mutex
+	repoLocks   = map[string]*heldRepoLock{}
+)
+
+// repoLockPath is the lock file of a repository. It lives in the git common
+// dir, so every worktree of the repository shares it.
+func (m *Manager) repoLockPath(repoRoot string) (string, error) {
+	out, err := m.runCmdOutput(repoRoot, "git", "rev-parse", "--path-format=absolute", "--git-common-dir")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.FromSlash(strings.TrimSpace(out)), repoLockFile), nil
+}
+
+// lockRepo takes the repository's operation lock for op, so two sprout
+// processes, such as the TUI and a CLI command, do not create or remove
+// worktrees and tmux sessions at the same time. When another process holds
+// it, lockRepo fails with errRepoBusy, or with WaitForLock waits until it is
+// free or ctx is done. Operations of the same process share the lock, so
+// they can nest. The returned unlock may be called more than once.
+func (m *Manager) lockRepo(ctx context.Context, repoRoot, op string) (func(), error) {
+	if m.sshRemoteActive() {
+		// The lock file would be on the SSH host, out of reach of flock.
+		return func() {}, nil
+	}
+	path, err := m.repoLockPath(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	repoLocksMu.Lock()
+	defer repoLocksMu.Unlock()
+	held := repoLocks[path]
+	if held == nil {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		waited := false
+		for {
+			ok, err := tryLockFile(f)
+			if err != nil {
+				f.Close()
+				return nil, err
+			}
+			if ok {
+				break
+			}
+			if !m.WaitForLock {
+				holder := repoLockHolder(path)
+				f.Close()
+				return nil, fmt.Errorf("%w (%s; use --wait to wait for it)", errRepoBusy, holder)
+			}
+			if !waited {
+				infoLogf("repo_lock waiting path=%q holder=%q", path, repoLockHolder(path))
+				waited = true
+			}
+			// Waiting holds repoLocksMu, so the process's other operations
+			// queue up behind this one rather than slip past it.
+			select {
+			case <-ctx.Done():
+				f.Close()
+				return nil, ctx.Err()
+			case <-time.After(repoLockPollInterval):
+			}
+		}
+		if err := f.Truncate(0); err == nil {
+			_, _ = fmt.Fprintf(f, "pid %d: %s\n", os.Getpid(), op)
+		}
+		held = &heldRepoLock{file: f}
+		repoLocks[path] = held
+		debugLogf("repo_lock acquired path=%q op=%q", path, op)
+	}
+	held.count++
+	// Another process may have changed the repository while this one
+	// waited, and this operation is about to.
+	m.InvalidateQueries(repoRoot)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			m.InvalidateQueries(repoRoot)
+			repoLocksMu.Lock()
+			defer repoLocksMu.Unlock()
+			held.count--
+			if held.count > 0 {
+				return
+			}
+			delete(repoLocks, path)
+			_ = held.file.Truncate(0)
+			_ = unlockFile(held.file)
+			held.file.Close()
+			debugLogf("repo_lock released path=%q", path)
+		})
+	}, nil
+}
+
+// repoLockHolder describes the process holding a lock file, as written by
+// lockRepo, like "pid 123: new feat/login".
+func repoLockHolder(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil || strings.TrimSpace(string(data)) == "" {
+		return "held by another process"
+	}
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,31 @@
+//go:build windows
+
+package sprout
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// lockOffsetHigh places the locked byte 4 GiB into the file. Windows locks
+// keep other processes from reading the locked range, and the holder line
+// at the start has to stay readable.
+const lockOffsetHigh = 1
+
+// tryLockFile takes an exclusive lock on f without blocking. ok is false when
+// another process holds it.
+func tryLockFile(f *os.File) (bool, error) {
+	ol := &windows.Overlapped{OffsetHigh: lockOffsetHigh}
+	err := windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, ol)
+	if errors.Is(err, windows.ERROR_LOCK_VIOLATION) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func unlockFile(f *os.File) error {
+	ol := &windows.Overlapped{OffsetHigh: lockOffsetHigh}
+	return windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, ol)
+}
@@ -0,0 +1,92 @@
+package sprout
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LogCommit is one commit on a worktree's branch.
+type LogCommit struct {
+	Hash      string
+	ShortHash string
+	Author    string
+	Date      time.Time
+	Subject   string
+}
+
+// maxLogCommits caps the commits listed for a worktree, which matters for
+// the base branch's own worktree where the whole history would qualify.
+const maxLogCommits = 200
+
+// WorktreeLog lists the commits on a worktree's branch that are not on the
+// base branch, newest first. The base branch's own worktree, which has no
+// separate base, lists its most recent commits instead.
+func (m *Manager) WorktreeLog(repoRoot string, wt *Worktree) ([]LogCommit, error) {
+	args := []string{"--no-pager", "log", "--no-color", "-n", strconv.Itoa(maxLogCommits), "--format=%H%x1f%h%x1f%an%x1f%ct%x1f%s"}
+	if since := m.branchDiffBase(repoRoot, wt); since != "HEAD" {
+		args = append(args, since+"..HEAD")
+	}
+	out, err := m.runCmdOutput(wt.Path, "git", args...)
+	if err != nil {
+		return nil, err
+	}
+	return parseLogCommits(out), nil
+}
+
+func parseLogCommits(out string) []LogCommit {
+	commits := []LogCommit{}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.SplitN(line, "\x1f", 5)
+		if len(fields) != 5 {
+			continue
+		}
+		c := LogCommit{
+			Hash:      fields[0],
+			ShortHash: fields[1],
+			Author:    fields[2],
+			Subject:   fields[4],
+		}
+		if ts, err := strconv.ParseInt(fields[3], 10, 64); err == nil {
+			c.Date = time.Unix(ts, 0)
+		}
+		commits = append(commits, c)
+	}
+	return commits
+}
+
+// CommitPatch renders a commit's message, stat, and patch, through delta
+// when it is installed and the built-in highlighter otherwise.
+func (m *Manager) CommitPatch(path, hash string, width int) (string, error) {
+	header, err := m.runCmdOutput(path, "git", "--no-pager", "show", "--no-color", "--stat", "--format=fuller", hash)
+	if err != nil {
+		return "", err
+	}
+	patch, err := m.runCmdOutput(path, "git", "--no-pager", "show", "--no-color", "--no-ext-diff", "--format=", hash)
+	if err != nil {
+		return "", err
+	}
+	if m.commandExists("delta") {
+		if rendered, renderErr := m.renderDiffWithDelta(patch, width); renderErr == nil {
+			patch = rendered
+		} else {
+			errorLogf("diff delta commit=%q path=%q failed: %v", hash, path, renderErr)
+		}
+	} else {
+		patch = highlightDiff(patch)
+	}
+
+	var b strings.Builder
+	header = strings.TrimSpace(header)
+	if first, rest, ok := strings.Cut(header, "\n"); ok {
+		header = "\x1b[33m" + first + "\x1b[0m\n" + rest
+	}
+	b.WriteString(header)
+	b.WriteString("\n\n")
+	if strings.TrimSpace(patch) == "" {
+		b.WriteString("(no textual diff in this commit)")
+	} else {
+		b.WriteString(patch)
+	}
+	return strings.TrimSpace(b.String()), nil
+}
@@ -23,25 +23,59 @@ import (
 
 var (
 	ErrNotGitRepo = errors.New("run this command inside a git worktree")
-	typeRe        = regexp.MustCompile(`^(feat|fix|chore|docs|refactor|test)$`)
-	slugBadRe     = regexp.MustCompile(`[^a-z0-9/-]+`)
-	slashRe       = regexp.MustCompile(`/+`)
-	dashRe        = regexp.MustCompile(`-+`)
-	safeNameRe    = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+	// errWorktreeNotFound is wrapped by the errors of lookups that match no
+	// worktree.
+	errWorktreeNotFound = errors.New("worktree not found")
+	// errRemoveCurrentWorktree is wrapped by the error of removing the
+	// worktree sprout runs in without RemoveOptions.SwitchToMain.
+	errRemoveCurrentWorktree = errors.New("cannot remove the worktree you are in")
+	slugBadRe                = regexp.MustCompile(`[^a-z0-9/-]+`)
+	slashRe                  = regexp.MustCompile(`/+`)
+	dashRe                   = regexp.MustCompile(`-+`)
+	safeNameRe               = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
 )
 
 type Worktree struct {
-	Path       string
-	Branch     string
-	Current    bool
-	Dirty      bool
-	TmuxState  string
-	AgentState string
+	Path        string
+	Branch      string
+	Current     bool
+	Dirty       bool
+	TmuxState   string
+	AgentState  string
+	RebaseState string
+	Locked      bool
+	LockReason  string
+	Priority    string
+	// Unfinished is set when a rebase, merge, cherry-pick, or revert has
+	// stopped in the worktree and waits to be continued or aborted.
+	Unfinished bool
+	// Labels are the labels of the branch, sorted.
+	Labels []string
+	// PR is the GitHub pull request the branch was created from, or 0.
+	PR int
+	// Task is what sprout plan created the worktree for, if it did.
+	Task string
+	// AgentCommand is the agent command the worktree's own .sprout.toml
+	// sets, with its agent_args, or empty when it uses the configured one.
+	AgentCommand string
+	// Usage is the token and cost usage its agents reported.
+	Usage AgentUsage
+	// Ahead and Behind count the commits the branch has that base_branch
+	// lacks, and the reverse. Both are -1 when there is nothing to compare.
+	Ahead  int
+	Behind int
+	// LastActive is when the worktree was last touched, or zero when
+	// nothing tells.
+	LastActive time.Time
+	// Check is the branch's last sprout check, if it has one.
+	Check WorktreeCheck
 }
 
 type DiffFile struct {
 	Path   string
 	Status string
+	// OrigPath is where a renamed or copied file came from.
+	OrigPath string
 }
 
 type NewOptions struct {
@@ -77,13 +111,39 @@ type DeleteProgress struct {
 type BranchInfo struct {
 	Name   string
 	Remote bool // true if only available as a remote-tracking branch
+	// RemoteRef is the remote-tracking branch of the same name, such as
+	// origin/feat/x, or empty when no remote has it.
+	RemoteRef string
+	// LastCommit, Author, and Subject describe the tip commit: the local
+	// branch's, or the remote's for remote-only branches.
+	LastCommit time.Time
+	Author     string
+	Subject    string
+}
+
+// Location says where the branch exists: local, remote, or local+remote.
+func (b BranchInfo) Location() string {
+	switch {
+	case b.Remote:
+		return "remote"
+	case b.RemoteRef != "":
+		return "local+remote"
+	}
+	return "local"
 }
 
 // ListBranches returns all local and remote branches not already checked out
-// in an existing worktree.
+// in an existing worktree. A branch on several remotes is listed once, from
+// origin when origin has it.
 func (m *Manager) ListBranches(repoRoot string) ([]BranchInfo, error) {
+	return cachedRepoQuery(m, repoRoot, "branches", branchListCacheTTL, func() ([]BranchInfo, error) {
+		return m.listBranches(repoRoot)
+	})
+}
+
+func (m *Manager) listBranches(repoRoot string) ([]BranchInfo, error) {
 	inUse := map[string]bool{}
-	if worktrees, err := m.ListWorktrees(); err == nil {
+	if worktrees, err := m.parseWorktreeList(repoRoot); err == nil {
 		for _, wt := range worktrees {
 			if wt.Branch != "" {
 				inUse[wt.Branch] = true
@@ -91,32 +151,34 @@ func (m *Manager) ListBranches(repoRoot string) ([]BranchInfo, error) {
 		}
 	}
 
-	localOut, _ := runCmdOutput(repoRoot, "git", "branch", "--format=%(refname:short)")
-	localSet := map[string]bool{}
+	index := map[string]int{}
 	var result []BranchInfo
-	for _, name := range strings.Split(strings.TrimSpace(localOut), "\n") {
-		name = strings.TrimSpace(name)
-		if name == "" || inUse[name] {
+	for _, ref := range m.branchRefs(repoRoot, false) {
+		if inUse[ref.Name] {
 			continue
 		}
-		localSet[name] = true
-		result = append(result, BranchInfo{Name: name})
+		index[ref.Name] = len(result)
+		result = append(result, BranchInfo{Name: ref.Name, LastCommit: ref.Date, Author: ref.Author, Subject: ref.Subject})
 	}
 
-	remoteOut, _ := runCmdOutput(repoRoot, "git", "branch", "-r", "--format=%(refname:short)")
-	for _, ref := range strings.Split(strings.TrimSpace(remoteOut), "\n") {
-		ref = strings.TrimSpace(ref)
-		if ref == "" {
+	for _, ref := range m.branchRefs(repoRoot, true) {
+		remote, name, ok := strings.Cut(ref.Name, "/")
+		if !ok || inUse[name] {
 			continue
 		}
-		name := ref
-		if idx := strings.Index(ref, "/"); idx >= 0 {
-			name = ref[idx+1:]
+		i, seen := index[name]
+		if !seen {
+			index[name] = len(result)
+			result = append(result, BranchInfo{Name: name, Remote: true, RemoteRef: ref.Name, LastCommit: ref.Date, Author: ref.Author, Subject: ref.Subject})
+			continue
 		}
-		if strings.Contains(name, "HEAD") || localSet[name] || inUse[name] {
+		if result[i].RemoteRef != "" && remote != "origin" {
 			continue
 		}
-		result = append(result, BranchInfo{Name: name, Remote: true})
+		result[i].RemoteRef = ref.Name
+		if result[i].Remote {
+			result[i].LastCommit, result[i].Author, result[i].Subject = ref.Date, ref.Author, ref.Subject
+		}
 	}
 
 	sort.Slice(result, func(i, j int) bool {
@@ -129,16 +191,42 @@ type GoOptions struct {
 	Target string
 	Launch bool
 	Attach bool
+	// Window is the tmux window to land on, one of WorktreeWindows. Empty
+	// means the session's current window, or the first one of a new session.
+	Window string
 }
 
 type LaunchOptions struct {
 	Target   string
 	NoAttach bool
+	// SwitchClient switches the tmux client to the worktree's window even
+	// when sprout runs inside tmux, where Launch otherwise leaves the client
+	// where it is.
+	SwitchClient bool
 }
 
 type AgentOptions struct {
 	Target string
 	Attach bool
+	// AgentType starts the command configured for that agent type instead
+	// of the default agent command.
+	AgentType string
+	// Prompt is sent to the agent once it is ready for input, waiting up to
+	// PromptTimeout (default agentPromptTimeout).
+	Prompt        string
+	PromptTimeout time.Duration
+}
+
+type RestartAgentOptions struct {
+	Target string
+	Attach bool
+	// AgentType is the agent type to start; empty keeps the type the agent
+	// was last started with.
+	AgentType string
+	// ReplayLastPrompt sends the last prompt of the worktree's prompt
+	// history to the new agent once it is ready for input.
+	ReplayLastPrompt bool
+	PromptTimeout    time.Duration
 }
 
 type RemoveOptions struct {
@@ -146,38 +234,76 @@ type RemoveOptions struct {
 	Force            bool
 	DeleteBranch     bool
 	OnDeleteProgress func(DeleteProgress)
+	// Merged records the branch as merged in the event log even when git
+	// cannot tell, as after a squash merge.
+	Merged bool
+	// SwitchToMain allows removing the worktree sprout runs in by moving to
+	// the main worktree first; OnSwitch, when set, is told its path. Without
+	// it that removal fails, rather than leave the shell in a deleted
+	// directory.
+	SwitchToMain bool
+	OnSwitch     func(mainPath string)
+}
+
+type MoveOptions struct {
+	Target    string
+	NewBranch string
 }
 
 type Manager struct {
 	Cfg Config
+	// WaitForLock makes operations wait for another sprout process to
+	// release the repository lock instead of failing.
+	WaitForLock bool
+
+	events *eventBus
+	// preview records tmux commands instead of running them, for
+	// sprout launch --dry-run.
+	preview *tmuxPreview
+	queries *queryCache
+	// remote is the SSH host whose repository sprout manages, if any.
+	remote sshRemote
 }
 
 func NewManager(cfg Config) *Manager {
-	return &Manager{Cfg: cfg}
+	setLogLevel(cfg.LogLevel)
+	return &Manager{
+		Cfg:     cfg,
+		events:  newEventBus(),
+		queries: newQueryCache(),
+		remote:  sshRemote{host: cfg.SSHHost, repo: cfg.SSHRepo},
+	}
 }
 
 func (m *Manager) RequireRepo() (string, error) {
-	out, err := runCmdOutput("", "git", "rev-parse", "--show-toplevel")
+	if m.sshRemoteActive() && m.remote.repo == "" {
+		return "", errors.New("ssh_host is set but ssh_repo is not: set it to the repository path on " + m.remote.host)
+	}
+	out, err := m.runCmdOutput("", "git", "rev-parse", "--show-toplevel")
 	if err != nil {
 		return "", ErrNotGitRepo
 	}
-	return strings.TrimSpace(out), nil
+	// Git for Windows prints paths with forward slashes.
+	return filepath.FromSlash(strings.TrimSpace(out)), nil
 }
 
 func (m *Manager) RepoName(repoRoot string) string {
-	// Try to get the common git dir to find the "real" repo name
-	out, err := runCmdOutput(repoRoot, "git", "rev-parse", "--path-format=absolute", "--git-common-dir")
-	if err == nil {
-		commonDir := strings.TrimSpace(out)
-		// If it's a worktree, commonDir will be /path/to/mainrepo/.git
-		// We want 'mainrepo'
-		return filepath.Base(filepath.Dir(commonDir))
-	}
-	return filepath.Base(repoRoot)
+	name, _ := cachedRepoQuery(m, repoRoot, "repo_name", repoNameCacheTTL, func() (string, error) {
+		// Try to get the common git dir to find the "real" repo name
+		out, err := m.runCmdOutput(repoRoot, "git", "rev-parse", "--path-format=absolute", "--git-common-dir")
+		if err == nil {
+			commonDir := strings.TrimSpace(out)
+			// If it's a worktree, commonDir will be /path/to/mainrepo/.git
+			// We want 'mainrepo'
+			return filepath.Base(filepath.Dir(commonDir)), nil
+		}
+		return filepath.Base(repoRoot), nil
+	})
+	return name
 }
 
 func (m *Manager) CurrentBranch(repoRoot string) string {
-	out, err := runCmdOutput(repoRoot, "git", "symbolic-ref", "--quiet", "--short", "HEAD")
+	out, err := m.runCmdOutput(repoRoot, "git", "symbolic-ref", "--quiet", "--short", "HEAD")
 	if err != nil {
 		return ""
 	}
@@ -185,10 +311,21 @@ func (m *Manager) CurrentBranch(repoRoot string) string {
 }
 
 func (m *Manager) BranchExists(repoRoot, branch string) bool {
-	_, err := runCmdOutput(repoRoot, "git", "show-ref", "--verify", "--quiet", "refs/heads/"+branch)
+	_, err := m.runCmdOutput(repoRoot, "git", "show-ref", "--verify", "--quiet", "refs/heads/"+branch)
 	return err == nil
 }
 
+// baseBranchExists is BranchExists for base_branch, cached for the status
+// of every worktree. Creating and removing worktrees goes through
+// BranchExists, which always asks git.
+func (m *Manager) baseBranchExists(repoRoot string) bool {
+	base := m.Cfg.BaseBranch
+	exists, _ := cachedRepoQuery(m, repoRoot, "branch_exists\x00"+base, branchExistsCacheTTL, func() (bool, error) {
+		return m.BranchExists(repoRoot, base), nil
+	})
+	return exists
+}
+
 func (m *Manager) ResolveBaseBranch(repoRoot, requested string) (string, error) {
 	if requested != "" {
 		if !m.BranchExists(repoRoot, requested) {
@@ -209,28 +346,30 @@ func (m *Manager) ResolveBaseBranch(repoRoot, requested string) (string, error)
 }
 
 func (m *Manager) Slugify(input string) (string, error) {
-	slug := strings.ToLower(input)
-	slug = strings.ReplaceAll(slug, "_", "-")
-	slug = strings.ReplaceAll(slug, " ", "-")
-	slug = slugBadRe.ReplaceAllString(slug, "-")
-	slug = slashRe.ReplaceAllString(slug, "/")
-	slug = dashRe.ReplaceAllString(slug, "-")
-	slug = strings.Trim(slug, "-/")
+	slug := slugify(input, m.Cfg.SlugMode)
 	if slug == "" {
+		if m.Cfg.SlugMode != slugModeUnicode && hasNonASCIILetter(input) {
+			return "", errors.New(`feature name resolves to empty slug (set slug_mode = "unicode" to keep non-ASCII letters)`)
+		}
 		return "", errors.New("feature name resolves to empty slug")
 	}
 	return slug, nil
 }
 
 func (m *Manager) MakeBranchName(branchType, name string) (string, error) {
-	if !typeRe.MatchString(branchType) {
-		return "", fmt.Errorf("invalid type '%s' (expected: feat|fix|chore|docs|refactor|test)", branchType)
+	if !m.validBranchType(branchType) {
+		return "", errors.New(m.invalidBranchTypeError(branchType))
 	}
 	slug, err := m.Slugify(name)
 	if err != nil {
 		return "", err
 	}
-	return branchType + "/" + slug, nil
+	repoRoot, _ := m.RequireRepo()
+	branch := m.expandBranchTemplate(repoRoot, branchType, slug)
+	if err := m.checkBranchPattern(branch); err != nil {
+		return "", err
+	}
+	return branch, nil
 }
 
 func safeName(value string) string {
@@ -261,7 +400,7 @@ func (m *Manager) WorktreeRootDir(repoRoot string) string {
 }
 
 func (m *Manager) parseWorktreeList(repoRoot string) ([]Worktree, error) {
-	out, err := runCmdOutput(repoRoot, "git", "worktree", "list", "--porcelain")
+	out, err := m.runCmdOutput(repoRoot, "git", "worktree", "list", "--porcelain")
 	if err != nil {
 		return nil, err
 	}
@@ -269,13 +408,17 @@ func (m *Manager) parseWorktreeList(repoRoot string) ([]Worktree, error) {
 	var res []Worktree
 	var curPath string
 	var curBranch string
+	var curLocked bool
+	var curLockReason string
 
 	flush := func() {
 		if curPath != "" {
-			res = append(res, Worktree{Path: curPath, Branch: curBranch})
+			res = append(res, Worktree{Path: curPath, Branch: curBranch, Locked: curLocked, LockReason: curLockReason})
 		}
 		curPath = ""
 		curBranch = ""
+		curLocked = false
+		curLockReason = ""
 	}
 
 	for _, line := range strings.Split(out, "\n") {
@@ -286,11 +429,14 @@ func (m *Manager) parseWorktreeList(repoRoot string) ([]Worktree, error) {
 		}
 		switch {
 		case strings.HasPrefix(line, "worktree "):
-			curPath = strings.TrimPrefix(line, "worktree ")
+			curPath = filepath.FromSlash(strings.TrimPrefix(line, "worktree "))
 		case strings.HasPrefix(line, "branch refs/heads/"):
 			curBranch = strings.TrimPrefix(line, "branch refs/heads/")
 		case strings.HasPrefix(line, "branch "):
 			curBranch = strings.TrimPrefix(line, "branch ")
+		case line == "locked" || strings.HasPrefix(line, "locked "):
+			curLocked = true
+			curLockReason = strings.TrimSpace(strings.TrimPrefix(line, "locked"))
 		}
 	}
 	flush()
@@ -298,7 +444,7 @@ func (m *Manager) parseWorktreeList(repoRoot string) ([]Worktree, error) {
 }
 
 func (m *Manager) tmuxSessionName(repoRoot string) string {
-	repo := safeName(m.RepoName(repoRoot))
+	repo := sessionToken(m.RepoName(repoRoot))
 	prefix := safeName(m.Cfg.SessionPrefix)
 	if prefix == "" {
 		return repo
@@ -314,13 +460,18 @@ func (m *Manager) tmuxWorktreeSessionName(repoRoot string, wt *Worktree) string
 	return m.tmuxWorktreeSessionNameFrom(repoRoot, branch, wt.Path)
 }
 
+// tmuxWorktreeSessionNameFrom names the session of a worktree: its own, or
+// in per-repo mode the one its repository's worktrees share.
 func (m *Manager) tmuxWorktreeSessionNameFrom(repoRoot, branch, worktreePath string) string {
 	base := m.tmuxSessionName(repoRoot)
+	if m.perRepoSessions() {
+		return base
+	}
 	token := strings.TrimSpace(branch)
 	if token == "" {
 		token = filepath.Base(worktreePath)
 	}
-	suffix := safeName(token)
+	suffix := sessionToken(token)
 	if suffix == "" {
 		return base
 	}
@@ -332,7 +483,7 @@ func (m *Manager) tmuxWorktreeSessionNameFrom(repoRoot, branch, worktreePath str
 }
 
 func (m *Manager) tmuxWindowName(branch string) string {
-	name := safeName(branch)
+	name := sessionToken(branch)
 	if len(name) > 60 {
 		return name[:60]
 	}
@@ -340,7 +491,7 @@ func (m *Manager) tmuxWindowName(branch string) string {
 }
 
 func (m *Manager) tmuxAgentWindowName(branch string) string {
-	name := "agent-" + safeName(branch)
+	name := "agent-" + sessionToken(branch)
 	if len(name) > 60 {
 		return name[:60]
 	}
@@ -348,7 +499,7 @@ func (m *Manager) tmuxAgentWindowName(branch string) string {
 }
 
 func (m *Manager) tmuxLazygitWindowName(branch string) string {
-	name := "git-" + safeName(branch)
+	name := "git-" + sessionToken(branch)
 	if len(name) > 60 {
 		return name[:60]
 	}
@@ -360,7 +511,7 @@ func (m *Manager) agentCommand() string {
 	if cmd != "" {
 		return cmd
 	}
-	if commandExists("codex") {
+	if m.commandExists("codex") {
 		return "codex"
 	}
 	shell := os.Getenv("SHELL")
@@ -370,6 +521,88 @@ func (m *Manager) agentCommand() string {
 	return shell
 }
 
+// AgentNotFoundError reports that the agent command to start is not
+// installed.
+type AgentNotFoundError struct {
+	Command string
+	// Alternatives are the configured agent types whose command is installed.
+	Alternatives []string
+}
+
+func (e *AgentNotFoundError) Error() string {
+	return "agent command not found: " + e.Command
+}
+
+// resolveAgentCommand returns the command to start in worktreePath for
+// agentType, or for the default agent when it is empty, failing with
+// *AgentNotFoundError when its executable is not installed. The worktree's
+// own .sprout.toml overrides the configured agent command and agent_args.
+func (m *Manager) resolveAgentCommand(worktreePath, agentType string) (string, error) {
+	override, err := m.worktreeAgentOverride(worktreePath)
+	if err != nil {
+		return "", err
+	}
+	command, err := m.overriddenAgentCommand(override, agentType)
+	if err != nil {
+		return "", err
+	}
+	execName := commandExecutableName(command)
+	if execName != "" && !m.commandExists(strings.Fields(command)[0]) {
+		return "", &AgentNotFoundError{Command: execName, Alternatives: m.installedAgentTypes(execName)}
+	}
+	return command, nil
+}
+
+// overriddenAgentCommand is the agent command for agentType with the
+// settings of override applied over the config.
+func (m *Manager) overriddenAgentCommand(override AgentOverride, agentType string) (string, error) {
+	command := m.agentCommand()
+	if override.Command != "" {
+		command = override.Command
+	}
+	if agentType = strings.ToLower(strings.TrimSpace(agentType)); agentType != "" {
+		cmd, ok := override.Commands[agentType]
+		if !ok || strings.TrimSpace(cmd) == "" {
+			cmd, ok = m.Cfg.AgentCommands[agentType]
+		}
+		if !ok || strings.TrimSpace(cmd) == "" {
+			return "", fmt.Errorf("unknown agent type %q (configured: %s)", agentType, strings.Join(m.agentTypes(), ", "))
+		}
+		command = strings.TrimSpace(cmd)
+	}
+	args := m.Cfg.AgentArgs
+	if override.Args != "" {
+		args = override.Args
+	}
+	return withAgentArgs(command, args), nil
+}
+
+// agentTypes lists the configured agent types in order.
+func (m *Manager) agentTypes() []string {
+	types := make([]string, 0, len(m.Cfg.AgentCommands))
+	for agentType := range m.Cfg.AgentCommands {
+		types = append(types, agentType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// installedAgentTypes lists the configured agent types whose command is
+// installed, skipping those that run the executable skip.
+func (m *Manager) installedAgentTypes(skip string) []string {
+	var types []string
+	for _, agentType := range m.agentTypes() {
+		command := strings.TrimSpace(m.Cfg.AgentCommands[agentType])
+		if command == "" || commandExecutableName(command) == skip {
+			continue
+		}
+		if m.commandExists(strings.Fields(command)[0]) {
+			types = append(types, agentType)
+		}
+	}
+	return types
+}
+
 func worktreeBranchOrName(wt *Worktree) string {
 	branch := wt.Branch
 	if branch == "" {
@@ -378,18 +611,27 @@ func worktreeBranchOrName(wt *Worktree) string {
 	return branch
 }
 
-func commandExists(name string) bool {
+func (m *Manager) commandExists(name string) bool {
+	if m.sshRemoteActive() && remoteCommands[name] {
+		return m.remoteCommandExists(name)
+	}
 	_, err := exec.LookPath(name)
 	return err == nil
 }
 
 func (m *Manager) tmuxHasSession(session string) bool {
-	_, err := runCmdOutput("", "tmux", "has-session", "-t", session)
+	if m.preview != nil {
+		return m.preview.sessions[session]
+	}
+	_, err := m.runCmdOutput("", "tmux", "has-session", "-t", session)
 	return err == nil
 }
 
 func (m *Manager) tmuxWindowExists(session, window string) bool {
-	_, err := runCmdOutput("", "tmux", "has-session", "-t", session+":"+window)
+	if m.preview != nil {
+		return m.preview.windows[session+":"+window]
+	}
+	_, err := m.runCmdOutput("", "tmux", "has-session", "-t", session+":"+window)
 	return err == nil
 }
 
@@ -421,8 +663,15 @@ func commandShouldRemainOnExit(command string) bool {
 	return true
 }
 
-func (m *Manager) tmuxSetRemainOnExit(session, window string) error {
-	return runCmdQuiet("", "tmux", "set-window-option", "-t", session+":"+window, "remain-on-exit", "on")
+// withRemainOnExit appends to a tmux command creating window the command
+// setting remain-on-exit on it when command should stay visible after it
+// exits. Setting it in the same tmux call means a command that exits right
+// away still leaves its window, and its output, behind.
+func withRemainOnExit(args []string, session, window, command string) []string {
+	if !commandShouldRemainOnExit(command) {
+		return args
+	}
+	return append(args, ";", "set-window-option", "-t", session+":"+window, "remain-on-exit", "on")
 }
 
 type tmuxWindowSpec struct {
@@ -504,7 +753,7 @@ func (m *Manager) tmuxConfiguredWindows(branch string, hasCommand func(string) b
 			windowBase = m.tmuxWindowName(branch)
 		default:
 			command = strings.TrimSpace(tool)
-			windowBase = m.tmuxCustomWindowName(command)
+			windowBase = m.tmuxScopedWindowName(branch, m.tmuxCustomWindowName(command))
 		}
 
 		command = strings.TrimSpace(command)
@@ -519,7 +768,7 @@ func (m *Manager) tmuxConfiguredWindows(branch string, hasCommand func(string) b
 	return windows
 }
 
-func (m *Manager) tmuxEnsureSession(session, repoRoot, initialWindow, initialCommand string) error {
+func (m *Manager) tmuxEnsureSession(session, repoRoot, initialWindow, initialCommand string, env ...string) error {
 	if m.tmuxHasSession(session) {
 		return nil
 	}
@@ -531,16 +780,12 @@ func (m *Manager) tmuxEnsureSession(session, repoRoot, initialWindow, initialCom
 	if command == "" {
 		command = defaultShellCommand()
 	}
-	if err := runCmdQuiet("", "tmux", "new-session", "-d", "-s", session, "-n", window, "-c", repoRoot, command); err != nil {
-		return err
-	}
-	if commandShouldRemainOnExit(command) {
-		return m.tmuxSetRemainOnExit(session, window)
-	}
-	return nil
+	args := append([]string{"new-session", "-d", "-s", session, "-n", window, "-c", repoRoot}, tmuxEnvArgs(env)...)
+	args = append(args, command)
+	return m.tmux(withRemainOnExit(args, session, window, command)...)
 }
 
-func (m *Manager) tmuxEnsureWindow(session, window, worktreePath, command string) error {
+func (m *Manager) tmuxEnsureWindow(session, window, worktreePath, command string, env ...string) error {
 	if m.tmuxWindowExists(session, window) {
 		return nil
 	}
@@ -548,42 +793,39 @@ func (m *Manager) tmuxEnsureWindow(session, window, worktreePath, command string
 	if cmd == "" {
 		cmd = defaultShellCommand()
 	}
-	if err := runCmdQuiet("", "tmux", "new-window", "-d", "-t", session, "-n", window, "-c", worktreePath, cmd); err != nil {
-		return err
-	}
-	if commandShouldRemainOnExit(cmd) {
-		return m.tmuxSetRemainOnExit(session, window)
-	}
-	return nil
+	args := append([]string{"new-window", "-d", "-t", session, "-n", window, "-c", worktreePath}, tmuxEnvArgs(env)...)
+	args = append(args, cmd)
+	return m.tmux(withRemainOnExit(args, session, window, cmd)...)
 }
 
 func (m *Manager) tmuxFocusWindow(session, window string, attachOutside bool) error {
-	if err := runCmdQuiet("", "tmux", "select-window", "-t", session+":"+window); err != nil {
+	if err := m.tmux("select-window", "-t", session+":"+window); err != nil {
 		return err
 	}
 
-	if os.Getenv("TMUX") != "" {
-		return runCmdQuiet("", "tmux", "switch-client", "-t", session)
+	if m.insideTmux() {
+		return m.tmux("switch-client", "-t", session)
 	}
 
 	if attachOutside {
-		return runCmdInherit("", "tmux", "attach-session", "-t", session)
+		return m.runCmdInherit("", "tmux", "attach-session", "-t", session)
 	}
 	return nil
 }
 
 func (m *Manager) tmuxFocusSession(session string, attachOutside bool) error {
-	if os.Getenv("TMUX") != "" {
-		return runCmdQuiet("", "tmux", "switch-client", "-t", session)
+	if m.insideTmux() {
+		return m.runCmdQuiet("", "tmux", "switch-client", "-t", session)
 	}
 	if attachOutside {
-		return runCmdInherit("", "tmux", "attach-session", "-t", session)
+		return m.runCmdInherit("", "tmux", "attach-session", "-t", session)
 	}
 	return nil
 }
 
 // resolvePaneDir resolves a pane dir spec to an absolute path.
-// Returns "" when dir is empty (caller should use the worktree path as default).
+// Returns "" when dir is empty (caller falls back to the window dir or the
+// worktree start dir).
 //   - "~" or "~/..." → expands to home directory
 //   - "{worktree}" prefix → replaced with worktreePath
 //   - Absolute path → returned as-is
@@ -618,6 +860,69 @@ func resolvePaneDir(dir, worktreePath string) string {
 	return filepath.Clean(filepath.Join(worktreePath, dir))
 }
 
+// parseWorktreeSubdir checks a worktree_subdir value: a relative path that
+// stays inside the worktree, such as "apps/web".
+func parseWorktreeSubdir(value string) (string, error) {
+	v := strings.TrimSpace(value)
+	if v == "" {
+		return "", nil
+	}
+	clean := filepath.Clean(filepath.FromSlash(v))
+	if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid worktree_subdir %q (want a path inside the worktree)", value)
+	}
+	if clean == "." {
+		return "", nil
+	}
+	return clean, nil
+}
+
+// worktreeStartDir is where tmux sessions and the agent start for the
+// worktree at worktreePath: worktree_subdir inside it, or the worktree root
+// when that is unset or the checkout has no such directory.
+func (m *Manager) worktreeStartDir(worktreePath string) string {
+	if m.Cfg.WorktreeSubdir == "" {
+		return worktreePath
+	}
+	dir := filepath.Join(worktreePath, m.Cfg.WorktreeSubdir)
+	if !m.dirExists(dir) {
+		debugLogf("worktree_subdir missing path=%q subdir=%q, using worktree root", worktreePath, m.Cfg.WorktreeSubdir)
+		return worktreePath
+	}
+	return dir
+}
+
+// parsePaneSize checks a pane size: a percentage of the window such as "30%",
+// or a number of cells.
+func parsePaneSize(value string) (string, error) {
+	v := strings.TrimSpace(value)
+	if v == "" {
+		return "", nil
+	}
+	if pct, ok := strings.CutSuffix(v, "%"); ok {
+		if n, err := strconv.Atoi(pct); err == nil && n > 0 && n < 100 {
+			return v, nil
+		}
+	} else if n, err := strconv.Atoi(v); err == nil && n > 0 {
+		return v, nil
+	}
+	return "", fmt.Errorf("invalid pane size %q (want a percentage such as \"30%%\" or a number of cells)", value)
+}
+
+// tmuxPaneBaseIndex is the index tmux gives the first pane of a window,
+// which users may set with pane-base-index.
+func (m *Manager) tmuxPaneBaseIndex() int {
+	if m.preview != nil {
+		return 0
+	}
+	out, err := m.runCmdOutput("", "tmux", "show-options", "-gwv", "pane-base-index")
+	if err != nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(out))
+	return n
+}
+
 // tmuxSplitFlag returns the tmux split-window flag for a given layout name.
 // Horizontal layouts use -h (split left/right); everything else uses -v.
 func tmuxSplitFlag(layout string) string {
@@ -630,108 +935,176 @@ func tmuxSplitFlag(layout string) string {
 }
 
 // tmuxLaunchWindowedSession creates (or attaches to) a tmux session built from
-// a structured []WindowConfig. It is idempotent: if the session already exists
-// all ensure calls are no-ops and pane splitting is skipped.
-func (m *Manager) tmuxLaunchWindowedSession(session, worktreePath string, windows []WindowConfig) (string, string, error) {
+// a structured []WindowConfig. It is idempotent: if the worktree's windows
+// already exist all ensure calls are no-ops and pane splitting is skipped.
+func (m *Manager) tmuxLaunchWindowedSession(session, repoName, branch, worktreePath string, windows []WindowConfig) (string, string, error) {
 	sessionIsNew := !m.tmuxHasSession(session)
+	windowsAreNew := !m.tmuxWorktreeRunning(session, worktreePath)
+	startDir := m.worktreeStartDir(worktreePath)
 
 	for i, win := range windows {
-		winName := trimTmuxWindowName(win.Name)
-		if winName == "" {
-			winName = fmt.Sprintf("window-%d", i+1)
+		winName := windowConfigName(win, i)
+		winName = m.tmuxScopedWindowName(branch, winName)
+		winDir := startDir
+		if d := resolvePaneDir(win.Dir, worktreePath); d != "" {
+			winDir = d
 		}
 
-		// Resolve pane 0's dir and command.
-		pane0Dir := worktreePath
-		pane0Cmd := defaultShellCommand()
+		// Resolve pane 0's dir, command, and env.
+		pane0Dir := winDir
+		pane0 := PaneConfig{}
 		if len(win.Panes) > 0 {
-			if d := resolvePaneDir(win.Panes[0].Dir, worktreePath); d != "" {
+			pane0 = win.Panes[0]
+			if d := resolvePaneDir(pane0.Dir, worktreePath); d != "" {
 				pane0Dir = d
 			}
-			if c := strings.TrimSpace(win.Panes[0].Run); c != "" {
-				pane0Cmd = c
-			}
 		}
+		pane0Cmd, pane0Env := m.withPaneEnv(session, worktreePath, pane0.Run, paneEnv(win, pane0, repoName, branch, worktreePath))
 
 		if i == 0 && sessionIsNew {
-			if err := m.tmuxEnsureSession(session, pane0Dir, winName, pane0Cmd); err != nil {
+			if err := m.tmuxEnsureSession(session, pane0Dir, winName, pane0Cmd, pane0Env...); err != nil {
 				return "", "", err
 			}
 		} else {
-			if err := m.tmuxEnsureWindow(session, winName, pane0Dir, pane0Cmd); err != nil {
+			if err := m.tmuxEnsureWindow(session, winName, pane0Dir, pane0Cmd, pane0Env...); err != nil {
 				return "", "", err
 			}
 		}
+		if err := m.tmuxTagWindow(session, winName, worktreePath); err != nil {
+			return "", "", err
+		}
 
-		if !sessionIsNew {
-			continue // don't re-split panes in an existing session
+		if !windowsAreNew {
+			continue // don't re-split panes of existing windows
 		}
 
 		splitFlag := tmuxSplitFlag(win.Layout)
+		sized := false
 		for j, pane := range win.Panes {
+			if pane.Size != "" {
+				sized = true
+			}
 			if j == 0 {
 				continue // pane 0 was created with the window/session
 			}
-			paneDir := worktreePath
+			paneDir := winDir
 			if d := resolvePaneDir(pane.Dir, worktreePath); d != "" {
 				paneDir = d
 			}
 			args := []string{"split-window", splitFlag, "-t", session + ":" + winName, "-c", paneDir}
-			if pane.Run != "" {
-				args = append(args, pane.Run)
+			if pane.Size != "" {
+				args = append(args, "-l", pane.Size)
+			}
+			run, env := m.withPaneEnv(session, worktreePath, pane.Run, paneEnv(win, pane, repoName, branch, worktreePath))
+			args = append(args, tmuxEnvArgs(env)...)
+			if run != "" {
+				args = append(args, run)
 			}
-			if err := runCmdQuiet("", "tmux", args...); err != nil {
+			if err := m.tmux(args...); err != nil {
 				return "", "", err
 			}
 		}
 
 		// Apply the tmux layout. Default to even-horizontal when multiple panes
-		// are defined but no explicit layout is set.
+		// are defined but no explicit layout or pane size is set.
 		layout := win.Layout
-		if layout == "" && len(win.Panes) > 1 {
+		if layout == "" && len(win.Panes) > 1 && !sized {
 			layout = "even-horizontal"
 		}
 		if layout != "" && len(win.Panes) > 1 {
-			_ = runCmdQuiet("", "tmux", "select-layout", "-t", session+":"+winName, layout)
+			_ = m.tmux("select-layout", "-t", session+":"+winName, layout)
+		}
+
+		// Size the panes the split could not: the first one, which came
+		// with the window, and all of them once a layout has rearranged
+		// them. Then select the focused pane.
+		if sized || hasFocusedPane(win.Panes) {
+			base := m.tmuxPaneBaseIndex()
+			resizeFlag := "-y"
+			if splitFlag == "-h" {
+				resizeFlag = "-x"
+			}
+			for j, pane := range win.Panes {
+				if pane.Size != "" && (j == 0 || layout != "") {
+					_ = m.tmux("resize-pane", "-t", fmt.Sprintf("%s:%s.%d", session, winName, base+j), resizeFlag, pane.Size)
+				}
+			}
+			for j := len(win.Panes) - 1; j >= 0; j-- {
+				if win.Panes[j].Focus {
+					_ = m.tmux("select-pane", "-t", fmt.Sprintf("%s:%s.%d", session, winName, base+j))
+					break
+				}
+			}
 		}
 	}
 
 	firstWin := ""
 	if len(windows) > 0 {
-		firstWin = trimTmuxWindowName(windows[0].Name)
-		if firstWin == "" {
-			firstWin = "window-1"
-		}
+		firstWin = m.tmuxScopedWindowName(branch, windowConfigName(windows[0], 0))
 	}
 	return session, firstWin, nil
 }
 
+// windowConfigName is the tmux name of the i-th [[windows]] entry.
+func windowConfigName(win WindowConfig, i int) string {
+	if name := trimTmuxWindowName(win.Name); name != "" {
+		return name
+	}
+	return fmt.Sprintf("window-%d", i+1)
+}
+
+// hasFocusedPane reports whether a pane of a window sets focus.
+func hasFocusedPane(panes []PaneConfig) bool {
+	for _, pane := range panes {
+		if pane.Focus {
+			return true
+		}
+	}
+	return false
+}
+
 func (m *Manager) tmuxEnsureWorktreeWindow(repoRoot, branch, worktreePath string) (string, string, error) {
 	session := m.tmuxWorktreeSessionNameFrom(repoRoot, branch, worktreePath)
+	unlock, err := m.lockRepo(context.Background(), repoRoot, "launch "+branch)
+	if err != nil {
+		return "", "", err
+	}
+	defer unlock()
+
+	if !m.tmuxWorktreeRunning(session, worktreePath) {
+		if err := m.containerUp(session, worktreePath); err != nil {
+			return "", "", fmt.Errorf("start container: %w", err)
+		}
+	}
 
 	// Priority 1: structured [[windows]] config
+	repoName := m.RepoName(repoRoot)
 	if len(m.Cfg.Windows) > 0 {
-		return m.tmuxLaunchWindowedSession(session, worktreePath, m.Cfg.Windows)
+		return m.tmuxLaunchWindowedSession(session, repoName, branch, worktreePath, m.Cfg.Windows)
 	}
 
+	startDir := m.worktreeStartDir(worktreePath)
+
 	// Priority 2: legacy flat layout_* config
-	repoName := m.RepoName(repoRoot)
 	if layout, ok := m.Cfg.SessionLayouts[repoName]; ok {
 		if len(layout.Windows) > 0 {
 			for i, win := range layout.Windows {
-				winName := trimTmuxWindowName(win.Name)
+				winName := m.tmuxScopedWindowName(branch, trimTmuxWindowName(win.Name))
 				if i == 0 && !m.tmuxHasSession(session) {
 					// Use first pane of first window for session creation
-					initialCmd := defaultShellCommand()
+					initialCmd := ""
 					if len(win.Panes) > 0 {
 						initialCmd = win.Panes[0].Command
 					}
-					if err := m.tmuxEnsureSession(session, worktreePath, winName, initialCmd); err != nil {
+					if err := m.tmuxEnsureSession(session, startDir, winName, m.containerize(session, worktreePath, initialCmd)); err != nil {
 						return "", "", err
 					}
 				}
 
-				if err := m.tmuxEnsureWindow(session, winName, worktreePath, ""); err != nil {
+				if err := m.tmuxEnsureWindow(session, winName, startDir, m.containerize(session, worktreePath, "")); err != nil {
+					return "", "", err
+				}
+				if err := m.tmuxTagWindow(session, winName, worktreePath); err != nil {
 					return "", "", err
 				}
 
@@ -742,29 +1115,50 @@ func (m *Manager) tmuxEnsureWorktreeWindow(repoRoot, branch, worktreePath string
 					}
 					if j == 0 {
 						// The window itself is the first pane
-						if pane.Command != "" {
-							_ = tmuxSendPaneCommand(session+":"+winName+".0", pane.Command)
+						if command := strings.TrimSpace(pane.Command); command != "" {
+							paneTarget := session + ":" + winName + ".0"
+							if err := m.tmux("send-keys", "-t", paneTarget, "-l", command); err == nil {
+								_ = m.tmux("send-keys", "-t", paneTarget, "C-m")
+							}
 						}
 						continue
 					}
 					// Split window for subsequent panes
-					args := []string{"split-window", "-v", "-t", session + ":" + winName, "-c", worktreePath}
-					if pane.Command != "" {
-						args = append(args, pane.Command)
+					args := []string{"split-window", "-v", "-t", session + ":" + winName, "-c", startDir}
+					if command := m.containerize(session, worktreePath, pane.Command); command != "" {
+						args = append(args, command)
 					}
-					if err := runCmdQuiet("", "tmux", args...); err != nil {
+					if err := m.tmux(args...); err != nil {
 						return "", "", err
 					}
 				}
 				// Equalize panes
-				_ = runCmdQuiet("", "tmux", "select-layout", "-t", session+":"+winName, "even-vertical")
+				_ = m.tmux("select-layout", "-t", session+":"+winName, "even-vertical")
 			}
-			return session, trimTmuxWindowName(layout.Windows[0].Name), nil
+			return session, m.tmuxScopedWindowName(branch, trimTmuxWindowName(layout.Windows[0].Name)), nil
 		}
 	}
 
 	// Default tool-based layout
-	windows := m.tmuxConfiguredWindows(branch, commandExists)
+	windows := m.tmuxConfiguredWindows(branch, m.commandExists)
+	agentWindow := m.tmuxAgentWindowName(branch)
+	if agentCommand, err := m.resolveAgentCommand(worktreePath, ""); err != nil {
+		// Leave out an agent window that would die at once; starting the
+		// agent reports the missing command instead.
+		kept := windows[:0]
+		for _, window := range windows {
+			if window.Name != agentWindow {
+				kept = append(kept, window)
+			}
+		}
+		windows = kept
+	} else {
+		for i := range windows {
+			if windows[i].Name == agentWindow {
+				windows[i].Command = agentCommand
+			}
+		}
+	}
 	if len(windows) == 0 {
 		windows = []tmuxWindowSpec{{
 			Name:    m.tmuxWindowName(branch),
@@ -773,13 +1167,20 @@ func (m *Manager) tmuxEnsureWorktreeWindow(repoRoot, branch, worktreePath string
 	}
 
 	initial := windows[0]
+	launched := !m.tmuxWorktreeRunning(session, worktreePath)
 	if !m.tmuxHasSession(session) {
-		if err := m.tmuxEnsureSession(session, worktreePath, initial.Name, initial.Command); err != nil {
+		if err := m.tmuxEnsureSession(session, startDir, initial.Name, m.containerize(session, worktreePath, initial.Command)); err != nil {
 			return "", "", err
 		}
 	}
+	if launched && m.preview == nil {
+		m.emit(repoRoot, &Worktree{Path: worktreePath, Branch: branch}, Event{Type: eventSessionLaunched})
+	}
 	for _, window := range windows {
-		if err := m.tmuxEnsureWindow(session, window.Name, worktreePath, window.Command); err != nil {
+		if err := m.tmuxEnsureWindow(session, window.Name, startDir, m.containerize(session, worktreePath, window.Command)); err != nil {
+			return "", "", err
+		}
+		if err := m.tmuxTagWindow(session, window.Name, worktreePath); err != nil {
 			return "", "", err
 		}
 	}
@@ -787,8 +1188,12 @@ func (m *Manager) tmuxEnsureWorktreeWindow(repoRoot, branch, worktreePath string
 }
 
 func (m *Manager) LaunchOrFocus(repoRoot, branch, worktreePath string, attachOutside bool) error {
-	if !commandExists("tmux") {
-		return errors.New("tmux is required for launch/go workflows")
+	switch m.launchBackend() {
+	case launchBackendWT:
+		return m.wtOpenTab(branch, m.worktreeStartDir(worktreePath), "")
+	case launchBackendNone:
+		debugLogf("launch_or_focus skipped path=%q: no launch backend", worktreePath)
+		return nil
 	}
 	session, window, err := m.tmuxEnsureWorktreeWindow(repoRoot, branch, worktreePath)
 	if err != nil {
@@ -797,7 +1202,10 @@ func (m *Manager) LaunchOrFocus(repoRoot, branch, worktreePath string, attachOut
 	return m.tmuxFocusWindow(session, window, attachOutside)
 }
 
-func (m *Manager) ListWorktrees() ([]Worktree, error) {
+// ListWorktrees lists the worktrees of the current repository with their
+// dirty, tmux, and agent state. It stops with ctx's error once ctx is done.
+func (m *Manager) ListWorktrees(ctx context.Context) ([]Worktree, error) {
+	defer timeOperation("list_worktrees")()
 	repoRoot, err := m.RequireRepo()
 	if err != nil {
 		return nil, err
@@ -809,47 +1217,91 @@ func (m *Manager) ListWorktrees() ([]Worktree, error) {
 	}
 	current := absPath(repoRoot)
 
-	hasTmux := commandExists("tmux")
+	hasTmux := m.commandExists("tmux")
+	priorities := m.branchPriorities(repoRoot)
+	labels := m.branchLabels(repoRoot)
+	prs := m.branchPRs(repoRoot)
+	tasks := m.branchTasks(repoRoot)
+	checks := m.branchChecks(repoRoot)
+	usage := agentUsageTotals()
+	tips := m.branchTips(repoRoot)
+	base := m.Cfg.BaseBranch
+	if !m.baseBranchExists(repoRoot) {
+		base = ""
+	}
 
 	for i := range items {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		items[i].Priority = priorities[items[i].Branch]
+		items[i].Labels = labels[items[i].Branch]
+		items[i].PR = prs[items[i].Branch]
+		items[i].Task = tasks[items[i].Branch]
+		items[i].Check = checks[items[i].Branch]
+		if override, err := m.worktreeAgentOverride(items[i].Path); err != nil {
+			debugLogf("list_worktrees agent override path=%q: %v", items[i].Path, err)
+		} else if !override.empty() {
+			items[i].AgentCommand, _ = m.overriddenAgentCommand(override, "")
+		}
 		items[i].Path = absPath(items[i].Path)
+		items[i].Usage = usage[items[i].Path]
 		items[i].Current = items[i].Path == current
-		items[i].Dirty = m.WorktreeDirty(items[i].Path)
+		items[i].Dirty = m.listedWorktreeDirty(ctx, repoRoot, items[i].Path)
+		items[i].Unfinished = m.worktreeOperationInProgress(items[i].Path)
+		items[i].Ahead, items[i].Behind = m.cachedAheadBehind(repoRoot, base, items[i].Branch, tips)
 		items[i].TmuxState = "n/a"
 		items[i].AgentState = "n/a"
 		if !hasTmux {
+			items[i].RebaseState = m.worktreeRebaseState("", &items[i])
 			continue
 		}
 
 		items[i].TmuxState = "no"
 		items[i].AgentState = "no"
 		session := m.tmuxWorktreeSessionName(repoRoot, &items[i])
-		if m.tmuxHasSession(session) {
+		if !m.tmuxWorktreeRunning(session, items[i].Path) {
+			items[i].RebaseState = m.worktreeRebaseState("", &items[i])
+		} else {
 			items[i].TmuxState = "yes"
+			items[i].RebaseState = m.worktreeRebaseState(session, &items[i])
 			agentWindow := m.tmuxAgentWindowName(worktreeBranchOrName(&items[i]))
 			if m.tmuxWindowExists(session, agentWindow) {
 				items[i].AgentState = "yes"
-			} else if _, ok := m.findAgentPaneInSession(session); ok {
+				// An agent window kept open by remain-on-exit after its
+				// command died.
+				if m.tmuxPaneDead(m.agentPaneTarget(repoRoot, &items[i])) {
+					items[i].AgentState = "crashed"
+				}
+			} else if _, ok := m.findAgentPaneInSession(session, items[i].Path); ok {
 				items[i].AgentState = "yes"
 			}
 		}
 	}
 
-	sort.SliceStable(items, func(i, j int) bool {
-		if items[i].Current {
-			return true
+	for i := range items {
+		head := tips[items[i].Branch].Time
+		if _, ok := tips[items[i].Branch]; !ok {
+			head = m.headCommitTime(items[i].Path)
 		}
-		if items[j].Current {
-			return false
+		var activity int64
+		if items[i].AgentState == "yes" {
+			activity, _ = m.agentPaneActivity(repoRoot, &items[i])
 		}
-		return items[i].Path < items[j].Path
-	})
+		items[i].LastActive = m.worktreeLastActive(head, items[i].Path, activity)
+	}
+
+	sortWorktrees(items, sortPath)
 
 	return items, nil
 }
 
 func (m *Manager) FindWorktree(target string) (*Worktree, error) {
-	items, err := m.ListWorktrees()
+	return m.findWorktree(context.Background(), target)
+}
+
+func (m *Manager) findWorktree(ctx context.Context, target string) (*Worktree, error) {
+	items, err := m.ListWorktrees(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -860,11 +1312,11 @@ func (m *Manager) FindWorktree(target string) (*Worktree, error) {
 	}
 
 	for i := range items {
-		if target == items[i].Branch || target == items[i].Path || targetAbs == items[i].Path || target == filepath.Base(items[i].Path) {
+		if m.worktreeMatchesTarget(&items[i], target, targetAbs) {
 			return &items[i], nil
 		}
 	}
-	return nil, fmt.Errorf("worktree not found for target: %s", target)
+	return nil, fmt.Errorf("%w for target: %s", errWorktreeNotFound, target)
 }
 
 func (m *Manager) findWorktreeLite(repoRoot, target string) (*Worktree, error) {
@@ -880,15 +1332,26 @@ func (m *Manager) findWorktreeLite(repoRoot, target string) (*Worktree, error) {
 
 	for i := range items {
 		items[i].Path = absPath(items[i].Path)
-		if target == items[i].Branch || target == items[i].Path || targetAbs == items[i].Path || target == filepath.Base(items[i].Path) {
+		if m.worktreeMatchesTarget(&items[i], target, targetAbs) {
 			return &items[i], nil
 		}
 	}
-	return nil, fmt.Errorf("worktree not found for target: %s", target)
+	return nil, fmt.Errorf("%w for target: %s", errWorktreeNotFound, target)
+}
+
+// worktreeMatchesTarget reports whether a worktree is the one a command's
+// target names: by branch, path, or directory name, or with
+// flatten_branch_dirs by its branch's flattened directory name, even when the
+// worktree was created before the switch.
+func (m *Manager) worktreeMatchesTarget(wt *Worktree, target, targetAbs string) bool {
+	if target == wt.Branch || target == wt.Path || targetAbs == wt.Path || target == filepath.Base(wt.Path) {
+		return true
+	}
+	return m.Cfg.FlattenBranchDirs && wt.Branch != "" && target == flattenBranchDir(wt.Branch)
 }
 
 func (m *Manager) BranchCheckedOutAnywhere(branch string) bool {
-	items, err := m.ListWorktrees()
+	items, err := m.ListWorktrees(context.Background())
 	if err != nil {
 		return false
 	}
@@ -900,39 +1363,40 @@ func (m *Manager) BranchCheckedOutAnywhere(branch string) bool {
 	return false
 }
 
-func (m *Manager) WorktreeDirty(path string) bool {
-	out, err := runCmdOutput(path, "git", "status", "--porcelain", "--untracked-files=all")
-	if err != nil {
-		return false
-	}
-	return strings.TrimSpace(out) != ""
+func (m *Manager) WorktreeDirty(ctx context.Context, path string) bool {
+	files, err := m.worktreeStatus(ctx, path)
+	return err == nil && len(files) > 0
 }
 
 func (m *Manager) WorktreeDiff(path string, width int) (string, error) {
-	status, err := runCmdOutput(path, "git", "--no-pager", "status", "--short")
+	defer timeOperation("worktree_diff")()
+	status, err := m.runCmdOutput(path, "git", "--no-pager", "status", "--short")
 	if err != nil {
 		return "", err
 	}
-	staged, err := runCmdOutput(path, "git", "--no-pager", "diff", "--cached", "--no-color", "--no-ext-diff")
+	staged, err := m.runCmdOutput(path, "git", "--no-pager", "diff", "--cached", "--no-color", "--no-ext-diff")
 	if err != nil {
 		return "", err
 	}
-	unstaged, err := runCmdOutput(path, "git", "--no-pager", "diff", "--no-color", "--no-ext-diff")
+	unstaged, err := m.runCmdOutput(path, "git", "--no-pager", "diff", "--no-color", "--no-ext-diff")
 	if err != nil {
 		return "", err
 	}
 
-	if commandExists("delta") {
-		if rendered, renderErr := renderDiffWithDelta(staged, width); renderErr == nil {
+	if m.commandExists("delta") {
+		if rendered, renderErr := m.renderDiffWithDelta(staged, width); renderErr == nil {
 			staged = rendered
 		} else {
-			debugLogf("diff delta staged failed path=%q: %v", path, renderErr)
+			errorLogf("diff delta staged failed path=%q: %v", path, renderErr)
 		}
-		if rendered, renderErr := renderDiffWithDelta(unstaged, width); renderErr == nil {
+		if rendered, renderErr := m.renderDiffWithDelta(unstaged, width); renderErr == nil {
 			unstaged = rendered
 		} else {
-			debugLogf("diff delta unstaged failed path=%q: %v", path, renderErr)
+			errorLogf("diff delta unstaged failed path=%q: %v", path, renderErr)
 		}
+	} else {
+		staged = highlightDiff(staged)
+		unstaged = highlightDiff(unstaged)
 	}
 
 	var b strings.Builder
@@ -954,39 +1418,22 @@ func (m *Manager) WorktreeDiff(path string, width int) (string, error) {
 }
 
 func (m *Manager) WorktreeDiffFiles(path string) ([]DiffFile, error) {
-	out, err := runCmdOutput(path, "git", "--no-pager", "status", "--porcelain", "--untracked-files=all")
-	if err != nil {
-		return nil, err
+	defer timeOperation("worktree_diff_files")()
+	return m.worktreeStatus(context.Background(), path)
+}
+
+// branchDiffBase returns the commit a worktree's branch forked from the base
+// branch, falling back to HEAD when there is no separate base to compare with.
+func (m *Manager) branchDiffBase(repoRoot string, wt *Worktree) string {
+	base, err := m.ResolveBaseBranch(repoRoot, "")
+	if err != nil || base == wt.Branch {
+		return "HEAD"
 	}
-	lines := strings.Split(out, "\n")
-	files := make([]DiffFile, 0, len(lines))
-	seen := map[string]struct{}{}
-	for _, line := range lines {
-		line = strings.TrimRight(line, "\r")
-		if len(line) < 3 {
-			continue
-		}
-		status := line[:2]
-		file := strings.TrimSpace(line[3:])
-		if file == "" {
-			continue
-		}
-		if idx := strings.LastIndex(file, " -> "); idx >= 0 {
-			file = strings.TrimSpace(file[idx+4:])
-		}
-		if file == "" {
-			continue
-		}
-		if _, ok := seen[file]; ok {
-			continue
-		}
-		seen[file] = struct{}{}
-		files = append(files, DiffFile{
-			Path:   file,
-			Status: status,
-		})
+	mergeBase, err := m.runCmdOutput(wt.Path, "git", "merge-base", base, "HEAD")
+	if err != nil || strings.TrimSpace(mergeBase) == "" {
+		return "HEAD"
 	}
-	return files, nil
+	return strings.TrimSpace(mergeBase)
 }
 
 func (m *Manager) WorktreeDiffForFile(path string, file DiffFile, width int) (string, error) {
@@ -1003,36 +1450,39 @@ func (m *Manager) WorktreeDiffForFile(path string, file DiffFile, width int) (st
 
 	isUntracked := stageState == '?' && workState == '?'
 	if isUntracked {
-		unstaged, err = runCmdOutputAllowExitCodes(path, []int{1}, "git", "--no-pager", "diff", "--no-index", "--no-color", "--no-ext-diff", "--", "/dev/null", file.Path)
+		unstaged, err = m.runCmdOutputAllowExitCodes(path, []int{1}, "git", "--no-pager", "diff", "--no-index", "--no-color", "--no-ext-diff", "--", "/dev/null", file.Path)
 		if err != nil {
 			return "", err
 		}
 	} else {
 		if needsStaged {
-			staged, err = runCmdOutput(path, "git", "--no-pager", "diff", "--cached", "--no-color", "--no-ext-diff", "--", file.Path)
+			staged, err = m.runCmdOutput(path, "git", "--no-pager", "diff", "--cached", "--no-color", "--no-ext-diff", "--", file.Path)
 			if err != nil {
 				return "", err
 			}
 		}
 		if needsUnstaged {
-			unstaged, err = runCmdOutput(path, "git", "--no-pager", "diff", "--no-color", "--no-ext-diff", "--", file.Path)
+			unstaged, err = m.runCmdOutput(path, "git", "--no-pager", "diff", "--no-color", "--no-ext-diff", "--", file.Path)
 			if err != nil {
 				return "", err
 			}
 		}
 	}
 
-	if commandExists("delta") {
-		if rendered, renderErr := renderDiffWithDelta(staged, width); renderErr == nil {
+	if m.commandExists("delta") {
+		if rendered, renderErr := m.renderDiffWithDelta(staged, width); renderErr == nil {
 			staged = rendered
 		} else {
-			debugLogf("diff delta staged file=%q path=%q failed: %v", file.Path, path, renderErr)
+			errorLogf("diff delta staged file=%q path=%q failed: %v", file.Path, path, renderErr)
 		}
-		if rendered, renderErr := renderDiffWithDelta(unstaged, width); renderErr == nil {
+		if rendered, renderErr := m.renderDiffWithDelta(unstaged, width); renderErr == nil {
 			unstaged = rendered
 		} else {
-			debugLogf("diff delta unstaged file=%q path=%q failed: %v", file.Path, path, renderErr)
+			errorLogf("diff delta unstaged file=%q path=%q failed: %v", file.Path, path, renderErr)
 		}
+	} else {
+		staged = highlightDiff(staged)
+		unstaged = highlightDiff(unstaged)
 	}
 
 	var b strings.Builder
@@ -1074,18 +1524,21 @@ func parsePorcelainStatus(status string) (rune, rune) {
 	return stageState, workState
 }
 
-func renderDiffWithDelta(diff string, width int) (string, error) {
+func (m *Manager) renderDiffWithDelta(diff string, width int) (string, error) {
 	if strings.TrimSpace(diff) == "" {
 		return "", nil
 	}
-	if !commandExists("delta") {
+	if !m.commandExists("delta") {
 		return diff, nil
 	}
 	args := []string{"--paging=never"}
+	if activeTheme == themeLight {
+		args = append(args, "--light")
+	}
 	if width > 0 {
 		args = append(args, "--width", strconv.Itoa(width))
 	}
-	out, err := runCmdBytesInput("", []byte(diff), "delta", args...)
+	out, err := m.runCmdBytesInput("", []byte(diff), "delta", args...)
 	if err != nil {
 		return "", err
 	}
@@ -1094,23 +1547,23 @@ func renderDiffWithDelta(diff string, width int) (string, error) {
 	return strings.TrimRight(rendered, "\n"), nil
 }
 
-func (m *Manager) CreateWorktreeWithBranch(repoRoot, branch, worktreePath, baseBranch string) error {
+func (m *Manager) CreateWorktreeWithBranch(ctx context.Context, repoRoot, branch, worktreePath, baseBranch string) error {
 	if m.BranchExists(repoRoot, branch) {
 		return fmt.Errorf("branch already exists: %s", branch)
 	}
-	if _, err := os.Stat(worktreePath); err == nil {
-		return fmt.Errorf("target path already exists: %s", worktreePath)
-	} else if !errors.Is(err, os.ErrNotExist) {
+	if exists, err := m.pathExists(worktreePath); err != nil {
 		return err
+	} else if exists {
+		return fmt.Errorf("target path already exists: %s", worktreePath)
 	}
-	if err := os.MkdirAll(filepath.Dir(worktreePath), 0o755); err != nil {
+	if err := m.mkdirAll(filepath.Dir(worktreePath)); err != nil {
 		return err
 	}
-	return m.runGitWorktreeAdd(repoRoot, "-b", branch, worktreePath, baseBranch)
+	return m.runGitWorktreeAdd(ctx, repoRoot, "-b", branch, worktreePath, baseBranch)
 }
 
-func (m *Manager) collectCopyCandidates(sourceRoot string) ([]string, error) {
-	out, err := runCmdBytes(sourceRoot, "git", "status", "--porcelain=v2", "-z", "--untracked-files=all", "--ignored=matching")
+func (m *Manager) collectCopyCandidates(ctx context.Context, sourceRoot string) ([]string, error) {
+	out, err := m.runCmdBytesContext(ctx, sourceRoot, 0, "git", "status", "--porcelain=v2", "-z", "--untracked-files=all", "--ignored=matching")
 	if err != nil {
 		return nil, err
 	}
@@ -1202,7 +1655,10 @@ func hasGlobMeta(value string) bool {
 	return strings.ContainsAny(value, "*?[")
 }
 
-func copyFile(src, dst string, info fs.FileInfo) error {
+func copyFile(ctx context.Context, src, dst string, info fs.FileInfo) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	in, err := os.Open(src)
 	if err != nil {
 		return err
@@ -1238,7 +1694,7 @@ func copySymlink(src, dst string) error {
 	return os.Symlink(target, dst)
 }
 
-func copyTree(src, dst string) error {
+func copyTree(ctx context.Context, src, dst string) error {
 	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
@@ -1267,11 +1723,11 @@ func copyTree(src, dst string) error {
 			}
 			return nil
 		}
-		return copyFile(path, target, info)
+		return copyFile(ctx, path, target, info)
 	})
 }
 
-func copyPath(src, dst string) error {
+func copyPath(ctx context.Context, src, dst string) error {
 	info, err := os.Lstat(src)
 	if err != nil {
 		return err
@@ -1284,9 +1740,9 @@ func copyPath(src, dst string) error {
 		if err := os.MkdirAll(dst, info.Mode().Perm()); err != nil {
 			return err
 		}
-		return copyTree(src, dst)
+		return copyTree(ctx, src, dst)
 	}
-	return copyFile(src, dst, info)
+	return copyFile(ctx, src, dst, info)
 }
 
 type copyCandidate struct {
@@ -1329,14 +1785,17 @@ func estimateCopyPath(path string) (int, int64, error) {
 	return files, bytes, err
 }
 
-func (m *Manager) CopyUntrackedAndIgnored(sourceRoot, targetRoot string, onProgress func(CopyProgress)) error {
+// CopyUntrackedAndIgnored copies the untracked and ignored files of
+// sourceRoot into targetRoot, reporting to onProgress if set. It stops between
+// files once ctx is done, leaving what was copied so far in place.
+func (m *Manager) CopyUntrackedAndIgnored(ctx context.Context, sourceRoot, targetRoot string, onProgress func(CopyProgress)) error {
 	start := time.Now()
-	candidates, err := m.collectCopyCandidates(sourceRoot)
+	candidates, err := m.collectCopyCandidates(ctx, sourceRoot)
 	if err != nil {
 		return err
 	}
 
-	debugLogf("copy_untracked start source=%q target=%q candidates=%d", sourceRoot, targetRoot, len(candidates))
+	infoLogf("copy_untracked start source=%q target=%q candidates=%d", sourceRoot, targetRoot, len(candidates))
 	if onProgress != nil {
 		onProgress(CopyProgress{Phase: "scan"})
 	}
@@ -1345,6 +1804,9 @@ func (m *Manager) CopyUntrackedAndIgnored(sourceRoot, targetRoot string, onProgr
 	totalFiles := 0
 	var totalBytes int64
 	for _, rel := range candidates {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		src := filepath.Join(sourceRoot, rel)
 		dst := filepath.Join(targetRoot, rel)
 		files, bytes, err := estimateCopyPath(src)
@@ -1394,7 +1856,7 @@ func (m *Manager) CopyUntrackedAndIgnored(sourceRoot, targetRoot string, onProgr
 			}
 			return err
 		}
-		if err := copyPath(item.Src, item.Dst); err != nil {
+		if err := copyPath(ctx, item.Src, item.Dst); err != nil {
 			return fmt.Errorf("copy %s: %w", item.Rel, err)
 		}
 		copiedFiles += item.Files
@@ -1410,25 +1872,36 @@ func (m *Manager) CopyUntrackedAndIgnored(sourceRoot, targetRoot string, onProgr
 			TotalBytes:  totalBytes,
 		})
 	}
-	debugLogf("copy_untracked done source=%q target=%q copied=%d total=%d bytes=%d/%d dur=%s", sourceRoot, targetRoot, copiedFiles, totalFiles, copiedBytes, totalBytes, time.Since(start))
+	infoLogf("copy_untracked done source=%q target=%q copied=%d total=%d bytes=%d/%d dur=%s", sourceRoot, targetRoot, copiedFiles, totalFiles, copiedBytes, totalBytes, time.Since(start))
 	return nil
 }
 
-func (m *Manager) CreateWorktreeFromExisting(repoRoot, branch, worktreePath string) error {
-	if _, err := os.Stat(worktreePath); err == nil {
-		return fmt.Errorf("target path already exists: %s", worktreePath)
-	} else if !errors.Is(err, os.ErrNotExist) {
+func (m *Manager) CreateWorktreeFromExisting(ctx context.Context, repoRoot, branch, worktreePath string) error {
+	if exists, err := m.pathExists(worktreePath); err != nil {
 		return err
+	} else if exists {
+		return fmt.Errorf("target path already exists: %s", worktreePath)
 	}
-	if err := os.MkdirAll(filepath.Dir(worktreePath), 0o755); err != nil {
+	if err := m.mkdirAll(filepath.Dir(worktreePath)); err != nil {
 		return err
 	}
-	// Try local branch first, then remote
 	if m.BranchExists(repoRoot, branch) {
-		return m.runGitWorktreeAdd(repoRoot, worktreePath, branch)
+		return m.runGitWorktreeAdd(ctx, repoRoot, worktreePath, branch)
+	}
+	remote := m.branchRemote(repoRoot, branch)
+	if remote == "" {
+		return fmt.Errorf("branch not found: %s", branch)
+	}
+	// Refresh the remote-tracking branch so the new local branch starts at
+	// the remote's tip. Offline, the last fetched tip will do.
+	remoteRef := remote + "/" + branch
+	if err := m.runCmdQuietContext(ctx, repoRoot, "git", "fetch", remote, "+refs/heads/"+branch+":refs/remotes/"+remoteRef); err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		infoLogf("new_worktree fetch failed remote=%q branch=%q, using last fetched tip: %v", remote, branch, err)
 	}
-	// If it doesn't exist locally, git might still find it in remotes if --guess-remote is on (default)
-	return m.runGitWorktreeAdd(repoRoot, worktreePath, branch)
+	return m.runGitWorktreeAdd(ctx, repoRoot, "--track", "-b", branch, worktreePath, remoteRef)
 }
 
 func (m *Manager) findExistingWorktreePath(repoRoot, branch, desiredPath string) (string, bool, error) {
@@ -1436,7 +1909,10 @@ func (m *Manager) findExistingWorktreePath(repoRoot, branch, desiredPath string)
 	if err != nil {
 		return "", false, err
 	}
-	desiredAbs := absPath(desiredPath)
+	desiredAbs := ""
+	if desiredPath != "" {
+		desiredAbs = absPath(desiredPath)
+	}
 	branch = strings.TrimSpace(branch)
 	branchMatch := ""
 	for _, wt := range items {
@@ -1454,83 +1930,120 @@ func (m *Manager) findExistingWorktreePath(repoRoot, branch, desiredPath string)
 	return "", false, nil
 }
 
-func (m *Manager) NewWorktree(opts NewOptions) (string, string, error) {
-	repoRoot, err := m.RequireRepo()
+// NewWorktree validates opts with ValidateNew and creates the worktree. When
+// the branch already has a worktree it returns that one instead.
+func (m *Manager) NewWorktree(ctx context.Context, opts NewOptions) (string, string, error) {
+	req, err := m.ValidateNew(opts)
 	if err != nil {
-		debugLogf("new_worktree require_repo failed: %v", err)
+		errorLogf("new_worktree validate failed branch=%q from=%q type=%q name=%q: %v", opts.Branch, opts.FromBranch, opts.Type, opts.Name, err)
 		return "", "", err
 	}
+	return m.CreateWorktree(ctx, req)
+}
 
-	branch := strings.TrimSpace(opts.Branch)
-	isExisting := opts.FromBranch != ""
-	if isExisting {
-		branch = opts.FromBranch
-	}
-
-	if branch == "" {
-		branch, err = m.MakeBranchName(opts.Type, opts.Name)
-		if err != nil {
-			debugLogf("new_worktree make_branch failed type=%q name=%q: %v", opts.Type, opts.Name, err)
-			return "", "", err
-		}
+// CreateWorktree creates the worktree of a request returned by ValidateNew.
+// When ctx is done before it finishes, the half-made worktree, and the branch
+// if it was new, are removed again and ctx's error is returned.
+func (m *Manager) CreateWorktree(ctx context.Context, req *CreateRequest) (string, string, error) {
+	defer timeOperation("create_worktree")()
+	opts := req.Options
+	repoRoot, branch, worktreePath := req.RepoRoot, req.Branch, req.Path
+	infoLogf("new_worktree start repo=%q branch=%q launch=%t existing=%t", repoRoot, branch, opts.Launch, req.Existing)
+	if req.ExistingPath != "" {
+		debugLogf("new_worktree existing_worktree_detected branch=%q requested_path=%q existing_path=%q", branch, worktreePath, req.ExistingPath)
+		return branch, req.ExistingPath, nil
 	}
-	debugLogf("new_worktree start repo=%q branch=%q launch=%t existing=%t", repoRoot, branch, opts.Launch, isExisting)
-
-	worktreeRoot := m.WorktreeRootDir(repoRoot)
-	worktreePath := absPath(filepath.Join(worktreeRoot, branch))
-	if existingPath, exists, findErr := m.findExistingWorktreePath(repoRoot, branch, worktreePath); findErr == nil && exists {
-		debugLogf("new_worktree existing_worktree_detected branch=%q requested_path=%q existing_path=%q", branch, worktreePath, existingPath)
-		return branch, existingPath, nil
+	unlock, err := m.lockRepo(ctx, repoRoot, "new "+branch)
+	if err != nil {
+		return "", "", err
 	}
+	defer unlock()
 
-	if isExisting {
-		if err := m.CreateWorktreeFromExisting(repoRoot, branch, worktreePath); err != nil {
+	if req.Existing {
+		if err := m.CreateWorktreeFromExisting(ctx, repoRoot, branch, worktreePath); err != nil {
+			if canceledBy(ctx, err) {
+				m.rollbackCreate(req)
+				return "", "", err
+			}
 			if existingPath, exists, findErr := m.findExistingWorktreePath(repoRoot, branch, worktreePath); findErr == nil && exists {
 				debugLogf("new_worktree existing_worktree_after_create_error branch=%q requested_path=%q existing_path=%q err=%v", branch, worktreePath, existingPath, err)
 				return branch, existingPath, nil
 			}
-			debugLogf("new_worktree create_worktree_from_existing failed branch=%q path=%q: %v", branch, worktreePath, err)
+			errorLogf("new_worktree create_worktree_from_existing failed branch=%q path=%q: %v", branch, worktreePath, err)
 			return "", "", err
 		}
 	} else {
-		base, err := m.ResolveBaseBranch(repoRoot, opts.BaseBranch)
-		if err != nil {
-			debugLogf("new_worktree resolve_base failed branch=%q requested_base=%q: %v", branch, opts.BaseBranch, err)
-			return "", "", err
-		}
-
-		if err := m.CreateWorktreeWithBranch(repoRoot, branch, worktreePath, base); err != nil {
+		if err := m.CreateWorktreeWithBranch(ctx, repoRoot, branch, worktreePath, req.Base); err != nil {
+			if canceledBy(ctx, err) {
+				m.rollbackCreate(req)
+				return "", "", err
+			}
 			if existingPath, exists, findErr := m.findExistingWorktreePath(repoRoot, branch, worktreePath); findErr == nil && exists {
 				debugLogf("new_worktree existing_worktree_after_create_error branch=%q requested_path=%q existing_path=%q err=%v", branch, worktreePath, existingPath, err)
 				return branch, existingPath, nil
 			}
-			debugLogf("new_worktree create_worktree failed branch=%q path=%q base=%q: %v", branch, worktreePath, base, err)
+			errorLogf("new_worktree create_worktree failed branch=%q path=%q base=%q: %v", branch, worktreePath, req.Base, err)
 			return "", "", err
 		}
 	}
 
-	debugLogf("new_worktree created branch=%q path=%q", branch, worktreePath)
-	if opts.SkipCopyUntracked {
-		debugLogf("new_worktree copy_untracked_skipped path=%q", worktreePath)
+	infoLogf("new_worktree created branch=%q path=%q", branch, worktreePath)
+	m.emit(repoRoot, &Worktree{Path: worktreePath, Branch: branch}, Event{Type: eventWorktreeCreated})
+	if opts.SkipCopyUntracked || m.sshRemoteActive() {
+		debugLogf("new_worktree copy_untracked_skipped path=%q remote=%t", worktreePath, m.sshRemoteActive())
 	} else {
-		if err := m.CopyUntrackedAndIgnored(repoRoot, worktreePath, opts.OnCopyProgress); err != nil {
-			debugLogf("new_worktree copy_untracked_failed path=%q: %v", worktreePath, err)
+		if err := m.CopyUntrackedAndIgnored(ctx, repoRoot, worktreePath, opts.OnCopyProgress); err != nil {
+			errorLogf("new_worktree copy_untracked_failed path=%q: %v", worktreePath, err)
+			if canceledBy(ctx, err) {
+				m.rollbackCreate(req)
+				m.emit(repoRoot, &Worktree{Path: worktreePath, Branch: branch}, Event{Type: eventWorktreeRemoved})
+			}
 			return "", "", err
 		}
 		debugLogf("new_worktree copied_untracked path=%q", worktreePath)
 	}
 
+	if err := ctx.Err(); err != nil {
+		// The worktree is complete; keep it and skip launching.
+		return branch, worktreePath, nil
+	}
+	// Launching may attach to the session until the user detaches, so let
+	// other operations go; creating the session takes the lock on its own.
+	unlock()
 	if opts.Launch {
 		if err := m.LaunchOrFocus(repoRoot, branch, worktreePath, true); err != nil {
-			debugLogf("new_worktree launch_failed path=%q: %v", worktreePath, err)
+			errorLogf("new_worktree launch_failed path=%q: %v", worktreePath, err)
 			return "", "", err
 		}
 	}
-	debugLogf("new_worktree success branch=%q path=%q", branch, worktreePath)
+	infoLogf("new_worktree success branch=%q path=%q", branch, worktreePath)
 
 	return branch, worktreePath, nil
 }
 
+// canceledBy reports whether err is ctx's error, as opposed to a failure that
+// happened to come as ctx was canceled.
+func canceledBy(ctx context.Context, err error) bool {
+	return ctx.Err() != nil && errors.Is(err, ctx.Err())
+}
+
+// rollbackCreate removes what a canceled CreateWorktree left behind. The
+// worktree path did not exist before, so anything there can go.
+func (m *Manager) rollbackCreate(req *CreateRequest) {
+	infoLogf("new_worktree canceled, rolling back branch=%q path=%q", req.Branch, req.Path)
+	ctx := context.Background()
+	_ = m.runGitWorktreeRemove(ctx, req.RepoRoot, req.Path, true)
+	if err := m.removeAll(req.Path); err != nil {
+		errorLogf("new_worktree rollback remove_path failed path=%q: %v", req.Path, err)
+	}
+	_ = m.runCmdQuiet(req.RepoRoot, "git", "worktree", "prune")
+	if (!req.Existing || req.Remote != "") && m.BranchExists(req.RepoRoot, req.Branch) {
+		if err := m.runCmdQuiet(req.RepoRoot, "git", "branch", "-D", req.Branch); err != nil {
+			errorLogf("new_worktree rollback delete_branch failed branch=%q: %v", req.Branch, err)
+		}
+	}
+}
+
 func (m *Manager) Path(target string) (string, error) {
 	wt, err := m.FindWorktree(target)
 	if err != nil {
@@ -1540,6 +2053,7 @@ func (m *Manager) Path(target string) (string, error) {
 }
 
 func (m *Manager) Go(opts GoOptions) (string, error) {
+	defer timeOperation("go")()
 	repoRoot, err := m.RequireRepo()
 	if err != nil {
 		return "", err
@@ -1554,17 +2068,34 @@ func (m *Manager) Go(opts GoOptions) (string, error) {
 		branch = filepath.Base(wt.Path)
 	}
 
-	if opts.Launch && commandExists("tmux") {
+	if opts.Launch && opts.Attach && m.launchBackend() != launchBackendNone {
+		m.runAttachHook(repoRoot, wt)
+	}
+	if opts.Launch && m.launchBackend() == launchBackendWT && opts.Attach {
+		if err := m.wtOpenTab(branch, m.worktreeStartDir(wt.Path), ""); err != nil {
+			return "", err
+		}
+	}
+	if opts.Launch && m.launchBackend() == launchBackendTmux {
 		attachOutside := false
-		if os.Getenv("TMUX") == "" {
+		if !m.insideTmux() {
 			attachOutside = opts.Attach
 		}
 		session := m.tmuxWorktreeSessionNameFrom(repoRoot, branch, wt.Path)
-		if m.tmuxHasSession(session) {
+		switch {
+		case opts.Window != "":
+			if _, _, err := m.tmuxEnsureWorktreeWindow(repoRoot, branch, wt.Path); err != nil {
+				return "", err
+			}
+			if err := m.tmuxFocusWindow(session, opts.Window, attachOutside); err != nil {
+				return "", err
+			}
+		// A shared per-repo session would open on another worktree's window.
+		case !m.perRepoSessions() && m.tmuxHasSession(session):
 			if err := m.tmuxFocusSession(session, attachOutside); err != nil {
 				return "", err
 			}
-		} else {
+		default:
 			if err := m.LaunchOrFocus(repoRoot, branch, wt.Path, attachOutside); err != nil {
 				return "", err
 			}
@@ -1575,40 +2106,60 @@ func (m *Manager) Go(opts GoOptions) (string, error) {
 }
 
 func (m *Manager) Launch(opts LaunchOptions) (string, error) {
+	defer timeOperation("launch")()
 	repoRoot, err := m.RequireRepo()
 	if err != nil {
-		debugLogf("launch require_repo failed target=%q: %v", opts.Target, err)
+		errorLogf("launch require_repo failed target=%q: %v", opts.Target, err)
 		return "", err
 	}
 	wt, err := m.FindWorktree(opts.Target)
 	if err != nil {
-		debugLogf("launch find_worktree failed target=%q: %v", opts.Target, err)
+		errorLogf("launch find_worktree failed target=%q: %v", opts.Target, err)
 		return "", err
 	}
 
 	attach := !opts.NoAttach
-	if os.Getenv("TMUX") != "" {
-		attach = false
+	if m.insideTmux() {
+		attach = opts.SwitchClient
 	}
 	branch := worktreeBranchOrName(wt)
-	debugLogf("launch start target=%q path=%q branch=%q no_attach=%t", opts.Target, wt.Path, branch, opts.NoAttach)
+	infoLogf("launch start target=%q path=%q branch=%q no_attach=%t", opts.Target, wt.Path, branch, opts.NoAttach)
+	switch m.launchBackend() {
+	case launchBackendWT:
+		if err := m.wtOpenTab(branch, m.worktreeStartDir(wt.Path), ""); err != nil {
+			errorLogf("launch wt failed path=%q: %v", wt.Path, err)
+			return "", err
+		}
+		return wt.Path, nil
+	case launchBackendNone:
+		return "", errNoLaunchBackend
+	}
 
+	agentSession := m.tmuxWorktreeSessionNameFrom(repoRoot, branch, wt.Path)
+	agentWindow := m.tmuxAgentWindowName(branch)
+	hadAgent := m.tmuxHasSession(agentSession) && m.tmuxWindowExists(agentSession, agentWindow)
 	session, window, err := m.tmuxEnsureWorktreeWindow(repoRoot, branch, wt.Path)
 	if err != nil {
-		debugLogf("launch ensure_window failed path=%q branch=%q: %v", wt.Path, branch, err)
+		errorLogf("launch ensure_window failed path=%q branch=%q: %v", wt.Path, branch, err)
 		return "", err
 	}
+	if !hadAgent && m.tmuxWindowExists(agentSession, agentWindow) {
+		recordAgentSession(wt.Path, "")
+		m.emit(repoRoot, wt, Event{Type: eventAgentStarted})
+	}
 	if attach {
+		m.runAttachHook(repoRoot, wt)
 		if err := m.tmuxFocusWindow(session, window, true); err != nil {
-			debugLogf("launch focus failed session=%q window=%q: %v", session, window, err)
+			errorLogf("launch focus failed session=%q window=%q: %v", session, window, err)
 			return "", err
 		}
 	}
-	debugLogf("launch success path=%q session=%q window=%q attach=%t", wt.Path, session, window, attach)
+	infoLogf("launch success path=%q session=%q window=%q attach=%t", wt.Path, session, window, attach)
 	return wt.Path, nil
 }
 
 func (m *Manager) Detach(target string) (string, bool, error) {
+	defer timeOperation("detach")()
 	repoRoot, err := m.RequireRepo()
 	if err != nil {
 		return "", false, err
@@ -1617,33 +2168,42 @@ func (m *Manager) Detach(target string) (string, bool, error) {
 	if err != nil {
 		return "", false, err
 	}
-	if !commandExists("tmux") {
+	if !m.commandExists("tmux") {
 		return "", false, errors.New("tmux is required for detach workflows")
 	}
 
 	session := m.tmuxWorktreeSessionName(repoRoot, wt)
-	if !m.tmuxHasSession(session) {
+	if !m.tmuxWorktreeRunning(session, wt.Path) {
 		return wt.Path, false, nil
 	}
-	if err := runCmdQuiet("", "tmux", "kill-session", "-t", session); err != nil {
+	if err := m.tmuxKillWorktree(session, wt.Path); err != nil {
 		return "", false, err
 	}
+	forgetAgentSession(wt.Path)
+	if err := m.containerDown(session, wt.Path); err != nil {
+		errorLogf("detach container_down failed session=%q: %v", session, err)
+		return wt.Path, true, fmt.Errorf("session detached, but the container was not stopped: %w", err)
+	}
 	return wt.Path, true, nil
 }
 
-func (m *Manager) StartAgent(opts AgentOptions) (string, bool, error) {
+func (m *Manager) StartAgent(ctx context.Context, opts AgentOptions) (string, bool, error) {
+	defer timeOperation("start_agent")()
 	repoRoot, err := m.RequireRepo()
 	if err != nil {
-		debugLogf("start_agent require_repo failed target=%q: %v", opts.Target, err)
+		errorLogf("start_agent require_repo failed target=%q: %v", opts.Target, err)
 		return "", false, err
 	}
-	wt, err := m.FindWorktree(opts.Target)
+	wt, err := m.findWorktree(ctx, opts.Target)
 	if err != nil {
-		debugLogf("start_agent find_worktree failed target=%q: %v", opts.Target, err)
+		errorLogf("start_agent find_worktree failed target=%q: %v", opts.Target, err)
 		return "", false, err
 	}
-	if !commandExists("tmux") {
-		debugLogf("start_agent tmux_missing target=%q", opts.Target)
+	if m.launchBackend() == launchBackendWT {
+		return m.startAgentInTab(wt, opts)
+	}
+	if !m.commandExists("tmux") {
+		errorLogf("start_agent tmux_missing target=%q", opts.Target)
 		return "", false, errors.New("tmux is required for agent workflows")
 	}
 
@@ -1651,45 +2211,107 @@ func (m *Manager) StartAgent(opts AgentOptions) (string, bool, error) {
 	session := m.tmuxWorktreeSessionNameFrom(repoRoot, branch, wt.Path)
 	agentWindow := m.tmuxAgentWindowName(branch)
 	alreadyRunning := m.tmuxHasSession(session) && m.tmuxWindowExists(session, agentWindow)
+	command := ""
+	if !alreadyRunning {
+		// A missing command would leave a dead window that looks like a
+		// broken agent, so fail before creating anything.
+		if command, err = m.resolveAgentCommand(wt.Path, opts.AgentType); err != nil {
+			errorLogf("start_agent resolve_command failed target=%q type=%q: %v", opts.Target, opts.AgentType, err)
+			return "", false, err
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return "", false, err
+	}
+	unlock, err := m.lockRepo(ctx, repoRoot, "agent start "+branch)
+	if err != nil {
+		return "", false, err
+	}
+	defer unlock()
 
 	_, _, err = m.tmuxEnsureWorktreeWindow(repoRoot, branch, wt.Path)
 	if err != nil {
-		debugLogf("start_agent ensure_worktree_window failed path=%q branch=%q: %v", wt.Path, branch, err)
+		errorLogf("start_agent ensure_worktree_window failed path=%q branch=%q: %v", wt.Path, branch, err)
 		return "", false, err
 	}
-	if err := m.tmuxEnsureWindow(session, agentWindow, wt.Path, m.agentCommand()); err != nil {
-		debugLogf("start_agent ensure_agent_window failed path=%q branch=%q window=%q: %v", wt.Path, branch, agentWindow, err)
+	if command != "" {
+		command = m.containerize(session, wt.Path, command)
+	}
+	if err := m.tmuxEnsureWindow(session, agentWindow, m.worktreeStartDir(wt.Path), command); err != nil {
+		errorLogf("start_agent ensure_agent_window failed path=%q branch=%q window=%q: %v", wt.Path, branch, agentWindow, err)
+		return "", alreadyRunning, err
+	}
+	if err := m.tmuxTagWindow(session, agentWindow, wt.Path); err != nil {
 		return "", alreadyRunning, err
 	}
-	debugLogf("start_agent start path=%q session=%q window=%q attach=%t already_running=%t", wt.Path, session, agentWindow, opts.Attach, alreadyRunning)
+	infoLogf("start_agent start path=%q session=%q window=%q attach=%t already_running=%t", wt.Path, session, agentWindow, opts.Attach, alreadyRunning)
+	if !alreadyRunning {
+		recordAgentSession(wt.Path, opts.AgentType)
+		m.emit(repoRoot, wt, Event{Type: eventAgentStarted, AgentType: opts.AgentType})
+	}
+	// Waiting for the agent and attaching can take a while.
+	unlock()
+
+	if prompt := strings.TrimSpace(opts.Prompt); prompt != "" {
+		timeout := opts.PromptTimeout
+		if timeout <= 0 {
+			timeout = agentPromptTimeout
+		}
+		if err := m.sendWhenReady(ctx, wt.Path, prompt, timeout); err != nil {
+			errorLogf("start_agent prompt failed path=%q: %v", wt.Path, err)
+			return wt.Path, alreadyRunning, fmt.Errorf("agent started, but the prompt was not sent: %w", err)
+		}
+		infoLogf("start_agent prompt sent path=%q", wt.Path)
+	}
 
 	if opts.Attach {
-		attachOutside := os.Getenv("TMUX") == ""
+		m.runAttachHook(repoRoot, wt)
+		attachOutside := !m.insideTmux()
 		if err := m.tmuxFocusWindow(session, agentWindow, attachOutside); err != nil {
-			debugLogf("start_agent focus failed session=%q window=%q: %v", session, agentWindow, err)
+			errorLogf("start_agent focus failed session=%q window=%q: %v", session, agentWindow, err)
 			return "", alreadyRunning, err
 		}
 	}
 
-	debugLogf("start_agent success path=%q session=%q window=%q already_running=%t", wt.Path, session, agentWindow, alreadyRunning)
+	infoLogf("start_agent success path=%q session=%q window=%q already_running=%t", wt.Path, session, agentWindow, alreadyRunning)
 	return wt.Path, alreadyRunning, nil
 }
 
-func (m *Manager) AttachAgent(target string) (string, error) {
-	path, _, err := m.StartAgent(AgentOptions{Target: target, Attach: true})
-	return path, err
+// startAgentInTab runs the agent of wt in a Windows Terminal tab. The tab is
+// not tracked, so the agent cannot be stopped or sent a prompt afterwards.
+func (m *Manager) startAgentInTab(wt *Worktree, opts AgentOptions) (string, bool, error) {
+	if strings.TrimSpace(opts.Prompt) != "" {
+		return "", false, errors.New("sending a prompt to the agent requires tmux")
+	}
+	command, err := m.resolveAgentCommand(wt.Path, opts.AgentType)
+	if err != nil {
+		errorLogf("start_agent resolve_command failed target=%q type=%q: %v", opts.Target, opts.AgentType, err)
+		return "", false, err
+	}
+	if err := m.wtOpenTab("agent "+worktreeBranchOrName(wt), m.worktreeStartDir(wt.Path), command); err != nil {
+		errorLogf("start_agent wt failed path=%q: %v", wt.Path, err)
+		return "", false, err
+	}
+	infoLogf("start_agent success path=%q backend=wt", wt.Path)
+	return wt.Path, false, nil
 }
 
-func (m *Manager) StopAgent(target string) (string, bool, error) {
+func (m *Manager) AttachAgent(target string) (string, error) {
+	path, _, err := m.StartAgent(context.Background(), AgentOptions{Target: target, Attach: true})
+	return path, err
+}
+
+func (m *Manager) StopAgent(ctx context.Context, target string) (string, bool, error) {
+	defer timeOperation("stop_agent")()
 	repoRoot, err := m.RequireRepo()
 	if err != nil {
 		return "", false, err
 	}
-	wt, err := m.FindWorktree(target)
+	wt, err := m.findWorktree(ctx, target)
 	if err != nil {
 		return "", false, err
 	}
-	if !commandExists("tmux") {
+	if !m.commandExists("tmux") {
 		return "", false, errors.New("tmux is required for agent workflows")
 	}
 
@@ -1698,12 +2320,76 @@ func (m *Manager) StopAgent(target string) (string, bool, error) {
 	if !m.tmuxHasSession(session) || !m.tmuxWindowExists(session, agentWindow) {
 		return wt.Path, false, nil
 	}
-	if err := runCmdQuiet("", "tmux", "kill-window", "-t", session+":"+agentWindow); err != nil {
+	m.exitAgentGracefully(ctx, repoRoot, wt)
+	if err := m.runCmdQuietContext(ctx, "", "tmux", "kill-window", "-t", session+":"+agentWindow); err != nil {
 		return "", false, err
 	}
+	forgetAgentSession(wt.Path)
+	m.emit(repoRoot, wt, Event{Type: eventAgentStopped})
 	return wt.Path, true, nil
 }
 
+// RestartAgent kills the agent window of a worktree, whether its agent is
+// running or crashed, and starts the agent again. It returns the prompt it
+// replayed, if any.
+func (m *Manager) RestartAgent(ctx context.Context, opts RestartAgentOptions) (string, string, error) {
+	repoRoot, err := m.RequireRepo()
+	if err != nil {
+		return "", "", err
+	}
+	wt, err := m.findWorktree(ctx, opts.Target)
+	if err != nil {
+		return "", "", err
+	}
+	if !m.commandExists("tmux") {
+		return "", "", errors.New("tmux is required for agent workflows")
+	}
+
+	agentType := opts.AgentType
+	if agentType == "" {
+		agentSessionsMu.Lock()
+		sessions, err := readAgentSessions()
+		agentSessionsMu.Unlock()
+		if err != nil {
+			errorLogf("restart_agent read_sessions failed: %v", err)
+		}
+		agentType = sessions.Worktrees[wt.Path].AgentType
+	}
+	// Resolve the command first so a missing agent does not leave the
+	// worktree without the agent it had.
+	if _, err := m.resolveAgentCommand(wt.Path, agentType); err != nil {
+		return "", "", err
+	}
+	prompt := ""
+	if opts.ReplayLastPrompt {
+		if history := promptHistoryFor(wt.Path); len(history) > 0 {
+			prompt = history[len(history)-1].Prompt
+		}
+	}
+
+	session := m.tmuxWorktreeSessionName(repoRoot, wt)
+	agentWindow := m.tmuxAgentWindowName(worktreeBranchOrName(wt))
+	if m.tmuxHasSession(session) && m.tmuxWindowExists(session, agentWindow) {
+		if err := m.runCmdQuietContext(ctx, "", "tmux", "kill-window", "-t", session+":"+agentWindow); err != nil {
+			return "", "", err
+		}
+		m.emit(repoRoot, wt, Event{Type: eventAgentStopped})
+	}
+	infoLogf("restart_agent path=%q type=%q replay=%t", wt.Path, agentType, prompt != "")
+
+	path, _, err := m.StartAgent(ctx, AgentOptions{
+		Target:        wt.Path,
+		Attach:        opts.Attach,
+		AgentType:     agentType,
+		Prompt:        prompt,
+		PromptTimeout: opts.PromptTimeout,
+	})
+	if err != nil {
+		return path, "", err
+	}
+	return path, prompt, nil
+}
+
 func (m *Manager) resolveWorktreeForTmux(target string) (string, *Worktree, error) {
 	repoRoot, err := m.RequireRepo()
 	if err != nil {
@@ -1725,7 +2411,7 @@ func (m *Manager) agentPaneTarget(repoRoot string, wt *Worktree) string {
 				return target
 			}
 		}
-		if target, ok := m.findAgentPaneInSession(session); ok {
+		if target, ok := m.findAgentPaneInSession(session, wt.Path); ok {
 			return target
 		}
 	}
@@ -1748,60 +2434,96 @@ func (m *Manager) lazygitPaneTarget(repoRoot string, wt *Worktree) (string, erro
 }
 
 func (m *Manager) agentOutputForWorktree(repoRoot string, wt *Worktree, lines int) (string, error) {
-	if !commandExists("tmux") {
+	if !m.commandExists("tmux") {
 		return "", errors.New("tmux is required for agent workflows")
 	}
-	return tmuxCapturePaneWithCursor(m.agentPaneTarget(repoRoot, wt), lines)
+	return m.tmuxCapturePaneWithCursor(m.agentPaneTarget(repoRoot, wt), lines)
 }
 
 func (m *Manager) lazygitOutputForWorktree(repoRoot string, wt *Worktree, lines int) (string, error) {
-	if !commandExists("tmux") {
+	if !m.commandExists("tmux") {
 		return "", errors.New("tmux is required for lazygit output")
 	}
 	targetPane, err := m.lazygitPaneTarget(repoRoot, wt)
 	if err != nil {
 		return "", err
 	}
-	return tmuxCapturePaneWithCursor(targetPane, lines)
+	return m.tmuxCapturePaneWithCursor(targetPane, lines)
 }
 
 func (m *Manager) editorOutputForWorktree(repoRoot string, wt *Worktree, lines int) (string, error) {
-	if !commandExists("tmux") {
+	if !m.commandExists("tmux") {
 		return "", errors.New("tmux is required for editor output")
 	}
-	return tmuxCapturePaneWithCursor(m.editorPaneTarget(repoRoot, wt), lines)
+	return m.tmuxCapturePaneWithCursor(m.editorPaneTarget(repoRoot, wt), lines)
+}
+
+// previewPanes lists the panes of wt's tmux session for the details pane to
+// preview: the agent pane first, then the others in window order. It also
+// returns the agent pane's ID, or "" when no agent is running.
+func (m *Manager) previewPanes(repoRoot string, wt *Worktree) ([]tmuxPaneInfo, string, error) {
+	if !m.commandExists("tmux") {
+		return nil, "", errors.New("tmux is required for pane previews")
+	}
+	session := m.tmuxWorktreeSessionName(repoRoot, wt)
+	if !m.tmuxHasSession(session) {
+		return nil, "", fmt.Errorf("no tmux session for %s", worktreeBranchOrName(wt))
+	}
+	panes, err := m.listAllSessionPanes(session)
+	if err != nil {
+		return nil, "", err
+	}
+	agentID := ""
+	if wt.AgentState == "yes" {
+		target := m.agentPaneTarget(repoRoot, wt)
+		for i, pane := range panes {
+			if pane.PaneID == target || session+":"+pane.WindowName+"."+pane.PaneIndex == target {
+				agentID = pane.PaneID
+				panes = append(append([]tmuxPaneInfo{pane}, panes[:i]...), panes[i+1:]...)
+				break
+			}
+		}
+	}
+	return panes, agentID, nil
+}
+
+func (m *Manager) paneOutput(paneID string, lines int) (string, error) {
+	if !m.commandExists("tmux") {
+		return "", errors.New("tmux is required for pane previews")
+	}
+	return m.tmuxCapturePaneWithCursor(paneID, lines)
 }
 
 func (m *Manager) sendAgentKeysForWorktree(repoRoot string, wt *Worktree, keys ...string) error {
-	if !commandExists("tmux") {
+	if !m.commandExists("tmux") {
 		return errors.New("tmux is required for agent workflows")
 	}
-	return tmuxSendPaneKeys(m.agentPaneTarget(repoRoot, wt), keys...)
+	return m.tmuxSendPaneKeys(m.agentPaneTarget(repoRoot, wt), keys...)
 }
 
 func (m *Manager) sendLazygitKeysForWorktree(repoRoot string, wt *Worktree, keys ...string) error {
-	if !commandExists("tmux") {
+	if !m.commandExists("tmux") {
 		return errors.New("tmux is required for lazygit workflows")
 	}
 	targetPane, err := m.lazygitPaneTarget(repoRoot, wt)
 	if err != nil {
 		return err
 	}
-	return tmuxSendPaneKeys(targetPane, keys...)
+	return m.tmuxSendPaneKeys(targetPane, keys...)
 }
 
 func (m *Manager) sendEditorKeysForWorktree(repoRoot string, wt *Worktree, keys ...string) error {
-	if !commandExists("tmux") {
+	if !m.commandExists("tmux") {
 		return errors.New("tmux is required for editor workflows")
 	}
-	return tmuxSendPaneKeys(m.editorPaneTarget(repoRoot, wt), keys...)
+	return m.tmuxSendPaneKeys(m.editorPaneTarget(repoRoot, wt), keys...)
 }
 
 func (m *Manager) agentPaneActivity(repoRoot string, wt *Worktree) (int64, error) {
-	if !commandExists("tmux") {
+	if !m.commandExists("tmux") {
 		return 0, errors.New("tmux is required for agent workflows")
 	}
-	return tmuxPaneActivity(m.agentPaneTarget(repoRoot, wt))
+	return m.tmuxPaneActivity(m.agentPaneTarget(repoRoot, wt))
 }
 
 func (m *Manager) AgentOutput(target string, lines int) (string, error) {
@@ -1817,9 +2539,16 @@ func (m *Manager) SendAgentCommand(target, command string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	if err := tmuxSendPaneCommand(m.agentPaneTarget(repoRoot, wt), command); err != nil {
+	// Checkpoint before the agent starts acting on the prompt.
+	if err := m.recordCheckpoint(wt.Path); err != nil {
+		errorLogf("send_agent_command checkpoint failed path=%q: %v", wt.Path, err)
+	}
+	if err := m.tmuxSendPaneCommand(m.agentPaneTarget(repoRoot, wt), command); err != nil {
 		return "", err
 	}
+	if err := recordPrompt(wt.Path, command); err != nil {
+		errorLogf("send_agent_command record_history failed path=%q: %v", wt.Path, err)
+	}
 	return wt.Path, nil
 }
 
@@ -1830,6 +2559,8 @@ type tmuxPaneInfo struct {
 	Active         bool
 	CurrentCommand string
 	StartCommand   string
+	// Owner is the worktree a window of a per-repo session belongs to.
+	Owner string
 }
 
 func (m *Manager) agentExecCandidates() map[string]struct{} {
@@ -1858,8 +2589,20 @@ func (m *Manager) agentExecCandidates() map[string]struct{} {
 	return candidates
 }
 
-func listSessionPanes(session string) ([]tmuxPaneInfo, error) {
-	out, err := runCmdOutput("", "tmux", "list-panes", "-t", session, "-F", "#{window_name}\t#{pane_index}\t#{pane_id}\t#{pane_active}\t#{pane_current_command}\t#{pane_start_command}")
+func (m *Manager) listSessionPanes(session string) ([]tmuxPaneInfo, error) {
+	return m.listTmuxPanes("-t", session)
+}
+
+// listAllSessionPanes lists the panes of every window of session, where
+// listSessionPanes only sees its current window.
+func (m *Manager) listAllSessionPanes(session string) ([]tmuxPaneInfo, error) {
+	return m.listTmuxPanes("-s", "-t", session)
+}
+
+func (m *Manager) listTmuxPanes(args ...string) ([]tmuxPaneInfo, error) {
+	args = append([]string{"list-panes"}, args...)
+	args = append(args, "-F", "#{window_name}\t#{pane_index}\t#{pane_id}\t#{pane_active}\t#{pane_current_command}\t#{"+tmuxOwnerOption+"}\t#{pane_start_command}")
+	out, err := m.runCmdOutput("", "tmux", args...)
 	if err != nil {
 		return nil, err
 	}
@@ -1870,8 +2613,8 @@ func listSessionPanes(session string) ([]tmuxPaneInfo, error) {
 	lines := strings.Split(out, "\n")
 	panes := make([]tmuxPaneInfo, 0, len(lines))
 	for _, line := range lines {
-		parts := strings.SplitN(line, "\t", 6)
-		if len(parts) < 6 {
+		parts := strings.SplitN(line, "\t", 7)
+		if len(parts) < 7 {
 			continue
 		}
 		panes = append(panes, tmuxPaneInfo{
@@ -1880,7 +2623,8 @@ func listSessionPanes(session string) ([]tmuxPaneInfo, error) {
 			PaneID:         parts[2],
 			Active:         parts[3] == "1",
 			CurrentCommand: parts[4],
-			StartCommand:   parts[5],
+			Owner:          parts[5],
+			StartCommand:   parts[6],
 		})
 	}
 	return panes, nil
@@ -1903,7 +2647,7 @@ func matchesAgentCommand(pane tmuxPaneInfo, candidates map[string]struct{}) bool
 }
 
 func (m *Manager) findAgentPaneInWindow(session, window string) (string, bool) {
-	panes, err := listSessionPanes(session)
+	panes, err := m.listSessionPanes(session)
 	if err != nil {
 		return "", false
 	}
@@ -1936,8 +2680,21 @@ func (m *Manager) findAgentPaneInWindow(session, window string) (string, bool) {
 	return "", false
 }
 
-func (m *Manager) findAgentPaneInSession(session string) (string, bool) {
-	panes, err := listSessionPanes(session)
+// findAgentPaneInSession looks for the agent of worktreePath in the
+// current window of its session, or in per-repo mode, in the windows of the
+// shared session that belong to the worktree.
+func (m *Manager) findAgentPaneInSession(session, worktreePath string) (string, bool) {
+	panes, err := m.listSessionPanes(session)
+	if m.perRepoSessions() {
+		panes, err = m.listAllSessionPanes(session)
+		own := panes[:0]
+		for _, pane := range panes {
+			if pane.Owner != "" && filepath.Clean(pane.Owner) == absPath(worktreePath) {
+				own = append(own, pane)
+			}
+		}
+		panes = own
+	}
 	if err != nil {
 		return "", false
 	}
@@ -1970,7 +2727,7 @@ func (m *Manager) findAgentPaneInSession(session string) (string, bool) {
 }
 
 func (m *Manager) tmuxPaneByCommand(session, window, paneCommand string) (string, bool, error) {
-	out, err := runCmdOutput("", "tmux", "list-panes", "-t", session+":"+window, "-F", "#{pane_index}\t#{pane_current_command}")
+	out, err := m.runCmdOutput("", "tmux", "list-panes", "-t", session+":"+window, "-F", "#{pane_index}\t#{pane_current_command}")
 	if err != nil {
 		return "", false, err
 	}
@@ -1991,7 +2748,7 @@ func (m *Manager) tmuxPaneByCommand(session, window, paneCommand string) (string
 }
 
 func (m *Manager) tmuxPaneTarget(session, window string, commands []string, fallbackPane string) (string, error) {
-	out, err := runCmdOutput("", "tmux", "list-panes", "-t", session+":"+window, "-F", "#{pane_index}\t#{pane_current_command}")
+	out, err := m.runCmdOutput("", "tmux", "list-panes", "-t", session+":"+window, "-F", "#{pane_index}\t#{pane_current_command}")
 	if err != nil {
 		return "", err
 	}
@@ -2029,36 +2786,46 @@ func (m *Manager) tmuxPaneTarget(session, window string, commands []string, fall
 	return "", errors.New("matching tmux pane not found")
 }
 
-func tmuxSendPaneCommand(paneTarget, command string) error {
+func (m *Manager) tmuxSendPaneCommand(paneTarget, command string) error {
 	command = strings.TrimSpace(command)
 	if command == "" {
 		return errors.New("command cannot be empty")
 	}
-	if err := tmuxSendPaneKeys(paneTarget, "-l", command); err != nil {
+	if err := m.tmuxSendPaneKeys(paneTarget, "-l", command); err != nil {
 		return err
 	}
-	return tmuxSendPaneKeys(paneTarget, "C-m")
+	return m.tmuxSendPaneKeys(paneTarget, "C-m")
 }
 
-func tmuxSendPaneKeys(paneTarget string, keys ...string) error {
+func (m *Manager) tmuxSendPaneKeys(paneTarget string, keys ...string) error {
 	if len(keys) == 0 {
 		return errors.New("keys cannot be empty")
 	}
 	args := append([]string{"send-keys", "-t", paneTarget}, keys...)
-	return runCmdQuiet("", "tmux", args...)
+	return m.runCmdQuiet("", "tmux", args...)
 }
 
-func tmuxResizePane(paneTarget string, width, height int) error {
+func (m *Manager) tmuxResizePane(paneTarget string, width, height int) error {
 	if strings.TrimSpace(paneTarget) == "" {
 		return errors.New("pane target cannot be empty")
 	}
 	if width <= 0 || height <= 0 {
 		return errors.New("pane size must be positive")
 	}
-	return runCmdQuiet("", "tmux", "resize-pane", "-t", paneTarget, "-x", strconv.Itoa(width), "-y", strconv.Itoa(height))
+	// A pane that fills its window can only grow with the window. Resize
+	// the window of a detached session, then drop the manual window-size
+	// that resize-window leaves behind so attaching still fits the client.
+	meta, err := m.runCmdOutput("", "tmux", "display-message", "-p", "-t", paneTarget, "#{window_panes} #{session_attached}")
+	if err == nil && strings.TrimSpace(meta) == "1 0" {
+		if err := m.runCmdQuiet("", "tmux", "resize-window", "-t", paneTarget, "-x", strconv.Itoa(width), "-y", strconv.Itoa(height)); err != nil {
+			return err
+		}
+		return m.runCmdQuiet("", "tmux", "set-window-option", "-u", "-t", paneTarget, "window-size")
+	}
+	return m.runCmdQuiet("", "tmux", "resize-pane", "-t", paneTarget, "-x", strconv.Itoa(width), "-y", strconv.Itoa(height))
 }
 
-func tmuxCapturePaneWithCursor(paneTarget string, lines int) (string, error) {
+func (m *Manager) tmuxCapturePaneWithCursor(paneTarget string, lines int) (string, error) {
 	cursorFlag := "0"
 	cursorX, cursorY := 0, 0
 	paneHeight := lines
@@ -2066,7 +2833,7 @@ func tmuxCapturePaneWithCursor(paneTarget string, lines int) (string, error) {
 		paneHeight = 120
 	}
 
-	meta, err := runCmdOutput("", "tmux", "display-message", "-p", "-t", paneTarget, "#{cursor_flag} #{cursor_x} #{cursor_y} #{pane_height}")
+	meta, err := m.runCmdOutput("", "tmux", "display-message", "-p", "-t", paneTarget, "#{cursor_flag} #{cursor_x} #{cursor_y} #{pane_height}")
 	if err == nil {
 		parts := strings.Fields(strings.TrimSpace(meta))
 		if len(parts) == 4 {
@@ -2089,7 +2856,7 @@ func tmuxCapturePaneWithCursor(paneTarget string, lines int) (string, error) {
 		lines = paneHeight
 	}
 
-	out, err := runCmdOutput("", "tmux", "capture-pane", "-p", "-N", "-e", "-t", paneTarget, "-S", fmt.Sprintf("-%d", lines))
+	out, err := m.runCmdOutput("", "tmux", "capture-pane", "-p", "-N", "-e", "-t", paneTarget, "-S", fmt.Sprintf("-%d", lines))
 	if err != nil {
 		return "", err
 	}
@@ -2120,11 +2887,18 @@ func tmuxCapturePaneWithCursor(paneTarget string, lines int) (string, error) {
 	return strings.Join(rows, "\n"), nil
 }
 
-func tmuxPaneActivity(paneTarget string) (int64, error) {
+// tmuxPaneDead reports whether the command of a pane has exited, which tmux
+// only shows for windows with remain-on-exit set.
+func (m *Manager) tmuxPaneDead(paneTarget string) bool {
+	out, err := m.runCmdOutput("", "tmux", "display-message", "-p", "-t", paneTarget, "#{pane_dead}")
+	return err == nil && strings.TrimSpace(out) == "1"
+}
+
+func (m *Manager) tmuxPaneActivity(paneTarget string) (int64, error) {
 	if strings.TrimSpace(paneTarget) == "" {
 		return 0, errors.New("pane target cannot be empty")
 	}
-	out, err := runCmdOutput("", "tmux", "display-message", "-p", "-t", paneTarget, "#{pane_activity}")
+	out, err := m.runCmdOutput("", "tmux", "display-message", "-p", "-t", paneTarget, "#{pane_activity}")
 	if err != nil {
 		return 0, err
 	}
@@ -2186,6 +2960,62 @@ func overlayCursorInANSILine(line string, cursorCol int) string {
 	return out.String()
 }
 
+// wrapANSI soft-wraps every line of text at width terminal cells. Escape
+// sequences take no room and stay in place, so the styles they set carry on
+// to the next line; a wide rune that does not fit moves to the next line
+// whole. Tabs become spaces up to the next tab stop.
+func wrapANSI(text string, width int) string {
+	if width <= 0 {
+		return text
+	}
+
+	var out strings.Builder
+	out.Grow(len(text) + len(text)/width)
+
+	visCols := 0
+	i := 0
+	for i < len(text) {
+		if text[i] == '\x1b' {
+			next, ok := consumeANSIEscape(text, i)
+			if ok {
+				out.WriteString(text[i:next])
+				i = next
+				continue
+			}
+		}
+
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if size <= 0 {
+			size = 1
+		}
+		if r == '\n' {
+			out.WriteByte('\n')
+			visCols = 0
+			i += size
+			continue
+		}
+		w := runeCellWidth(r, visCols)
+		if visCols > 0 && visCols+w > width {
+			if r == '\t' {
+				w = width - visCols
+			} else {
+				out.WriteByte('\n')
+				visCols = 0
+				w = runeCellWidth(r, visCols)
+			}
+		}
+		if r == '\t' {
+			w = min(w, width)
+			out.WriteString(strings.Repeat(" ", w))
+		} else {
+			out.WriteString(text[i : i+size])
+		}
+		visCols += w
+		i += size
+	}
+	return out.String()
+}
+
 func runeCellWidth(r rune, currentCol int) int {
 	if r == '\t' {
 		tab := 8 - (currentCol % 8)
@@ -2280,7 +3110,7 @@ func (m *Manager) SendLazygitCommand(target, command string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	if err := tmuxSendPaneCommand(targetPane, command); err != nil {
+	if err := m.tmuxSendPaneCommand(targetPane, command); err != nil {
 		return "", err
 	}
 	return wt.Path, nil
@@ -2291,7 +3121,7 @@ func (m *Manager) SendEditorCommand(target, command string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	if err := tmuxSendPaneCommand(m.editorPaneTarget(repoRoot, wt), command); err != nil {
+	if err := m.tmuxSendPaneCommand(m.editorPaneTarget(repoRoot, wt), command); err != nil {
 		return "", err
 	}
 	return wt.Path, nil
@@ -2330,55 +3160,137 @@ func (m *Manager) SendEditorKeys(target string, keys ...string) (string, error)
 	return wt.Path, nil
 }
 
-func (m *Manager) Remove(opts RemoveOptions) (string, []string, error) {
+// Remove removes a worktree and its tmux session. Once ctx is done it stops
+// before the next step; a removal stopped while deleting files leaves the
+// worktree partly deleted, and says so.
+func (m *Manager) Remove(ctx context.Context, opts RemoveOptions) (string, []string, error) {
+	defer timeOperation("remove")()
 	repoRoot, err := m.RequireRepo()
 	if err != nil {
 		return "", nil, err
 	}
-	wt, err := m.FindWorktree(opts.Target)
+	unlock, err := m.lockRepo(ctx, repoRoot, "rm "+opts.Target)
+	if err != nil {
+		return "", nil, err
+	}
+	defer unlock()
+	wt, err := m.findWorktree(ctx, opts.Target)
 	if err != nil {
 		return "", nil, err
 	}
 
-	if !opts.Force && m.WorktreeDirty(wt.Path) {
+	if wt.Locked && !opts.Force {
+		return "", nil, fmt.Errorf("%s (unlock it or use --force to override)", lockedWorktreeMessage(wt))
+	}
+	if !opts.Force && m.WorktreeDirty(ctx, wt.Path) {
 		return "", nil, fmt.Errorf("worktree has uncommitted changes: %s (use --force to override)", wt.Path)
 	}
+	mainRoot := ""
+	if wt.Current {
+		mainRoot = m.mainRepoRoot(repoRoot)
+		if mainRoot == wt.Path {
+			return "", nil, fmt.Errorf("cannot remove the main worktree: %s", wt.Path)
+		}
+		if !opts.SwitchToMain {
+			return "", nil, fmt.Errorf("%w: %s (cd to another worktree first, or use --switch to move to the main worktree)", errRemoveCurrentWorktree, wt.Path)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return "", nil, err
+	}
+	if mainRoot != "" {
+		// Every later git command runs from repoRoot, which is about to go.
+		if err := os.Chdir(mainRoot); err != nil {
+			return "", nil, fmt.Errorf("switch to main worktree %s: %w", mainRoot, err)
+		}
+		repoRoot = mainRoot
+		if opts.OnSwitch != nil {
+			opts.OnSwitch(mainRoot)
+		}
+	}
 
+	// Tell merged from abandoned while the branch is still there.
+	outcome := m.branchOutcome(repoRoot, wt.Branch)
+	if opts.Merged {
+		outcome = branchOutcomeMerged
+	}
 	warnings := []string{}
 	session := ""
-	if commandExists("tmux") {
+	forgetAgentSession(wt.Path)
+	forgetAgentUsage(wt.Path)
+	if m.commandExists("tmux") {
 		session = m.tmuxWorktreeSessionName(repoRoot, wt)
-		if m.tmuxHasSession(session) {
-			if err := runCmdQuiet("", "tmux", "kill-session", "-t", session); err != nil {
+		if m.tmuxWorktreeRunning(session, wt.Path) {
+			if wt.AgentState == "yes" && len(m.Cfg.AgentExitKeys) > 0 && m.Cfg.AgentExitWait > 0 {
+				if !m.exitAgentGracefully(ctx, repoRoot, wt) && ctx.Err() == nil {
+					warnings = append(warnings, fmt.Sprintf("agent did not exit within %ds of agent_exit_keys, killed it", m.Cfg.AgentExitWait))
+				}
+			}
+			if err := m.tmuxKillWorktree(session, wt.Path); err != nil {
 				warnings = append(warnings, fmt.Sprintf("unable to stop tmux session %s before removal: %v", session, err))
 			}
+			if err := m.containerDown(session, wt.Path); err != nil {
+				warnings = append(warnings, fmt.Sprintf("unable to stop the container of %s: %v", session, err))
+			}
 		}
 	}
 
-	if opts.OnDeleteProgress != nil {
-		if err := m.removeWorktreeWithProgress(repoRoot, wt.Path, opts.OnDeleteProgress); err != nil {
+	if err := m.backupForUndo(repoRoot, wt); err != nil {
+		errorLogf("undo backup failed path=%q: %v", wt.Path, err)
+		warnings = append(warnings, fmt.Sprintf("sprout undo will not be able to restore it: %v", err))
+	}
+
+	relock := func() {}
+	if wt.Locked {
+		// git refuses to remove or prune locked worktrees, so drop the lock
+		// just before removing it, and take it again if that fails.
+		if err := m.runCmdQuiet(repoRoot, "git", "worktree", "unlock", wt.Path); err != nil {
+			return "", warnings, err
+		}
+		relock = func() {
+			args := []string{"worktree", "lock"}
+			if wt.LockReason != "" {
+				args = append(args, "--reason", wt.LockReason)
+			}
+			if err := m.runCmdQuiet(repoRoot, "git", append(args, wt.Path)...); err != nil {
+				errorLogf("remove relock failed path=%q: %v", wt.Path, err)
+				warnings = append(warnings, fmt.Sprintf("the worktree is no longer locked: %v", err))
+			}
+		}
+	}
+	// The per-file delete walks the local filesystem; on an SSH host git
+	// removes the worktree in one command.
+	onProgress := opts.OnDeleteProgress
+	if m.sshRemoteActive() {
+		onProgress = nil
+	}
+	if onProgress != nil {
+		if err := m.removeWorktreeWithProgress(ctx, repoRoot, wt.Path, onProgress); err != nil {
+			relock()
 			return "", warnings, err
 		}
 	} else {
-		if err := m.runGitWorktreeRemove(repoRoot, wt.Path, opts.Force); err != nil {
-			if shouldRetryWorktreeRemove(err) {
-				_ = runCmdQuiet(repoRoot, "git", "worktree", "prune")
-				if session != "" && m.tmuxHasSession(session) {
-					_ = runCmdQuiet("", "tmux", "kill-session", "-t", session)
+		if err := m.runGitWorktreeRemove(ctx, repoRoot, wt.Path, opts.Force); err != nil {
+			if ctx.Err() == nil && shouldRetryWorktreeRemove(err) {
+				_ = m.runCmdQuiet(repoRoot, "git", "worktree", "prune")
+				if session != "" && m.tmuxWorktreeRunning(session, wt.Path) {
+					_ = m.tmuxKillWorktree(session, wt.Path)
 				}
-				if retryErr := m.runGitWorktreeRemove(repoRoot, wt.Path, opts.Force); retryErr == nil {
+				if retryErr := m.runGitWorktreeRemove(ctx, repoRoot, wt.Path, opts.Force); retryErr == nil {
 					warnings = append(warnings, "worktree removal required a retry after cleanup")
 				} else {
+					relock()
 					return "", warnings, retryErr
 				}
 			} else {
+				relock()
 				return "", warnings, err
 			}
 		}
 	}
 
-	if opts.OnDeleteProgress != nil {
-		if err := runCmdQuiet(repoRoot, "git", "worktree", "prune"); err != nil {
+	if onProgress != nil {
+		if err := m.runCmdQuiet(repoRoot, "git", "worktree", "prune"); err != nil {
 			warnings = append(warnings, fmt.Sprintf("worktree prune failed after removal: %v", err))
 		}
 	}
@@ -2394,15 +3306,207 @@ func (m *Manager) Remove(opts RemoveOptions) (string, []string, error) {
 				branchArgs = append(branchArgs, "-d")
 			}
 			branchArgs = append(branchArgs, wt.Branch)
-			if err := runCmdQuiet(repoRoot, "git", branchArgs...); err != nil {
+			if err := m.runCmdQuiet(repoRoot, "git", branchArgs...); err != nil {
 				return "", warnings, err
 			}
+			m.markUndoBranchDeleted(repoRoot, wt.Path)
 		}
 	}
 
+	m.emit(repoRoot, wt, Event{Type: eventWorktreeRemoved, Outcome: outcome})
 	return wt.Path, warnings, nil
 }
 
+func lockedWorktreeMessage(wt *Worktree) string {
+	if wt.LockReason != "" {
+		return fmt.Sprintf("worktree is locked: %s (%s)", wt.Path, wt.LockReason)
+	}
+	return fmt.Sprintf("worktree is locked: %s", wt.Path)
+}
+
+// Lock marks a worktree as locked so it is not removed or pruned by accident.
+func (m *Manager) Lock(target, reason string) (string, error) {
+	repoRoot, err := m.RequireRepo()
+	if err != nil {
+		return "", err
+	}
+	wt, err := m.findWorktreeLite(repoRoot, target)
+	if err != nil {
+		return "", err
+	}
+	if wt.Locked {
+		return "", errors.New(lockedWorktreeMessage(wt))
+	}
+	args := []string{"worktree", "lock"}
+	if reason = strings.TrimSpace(reason); reason != "" {
+		args = append(args, "--reason", reason)
+	}
+	args = append(args, wt.Path)
+	if err := m.runCmdQuiet(repoRoot, "git", args...); err != nil {
+		return "", err
+	}
+	return wt.Path, nil
+}
+
+// Unlock removes the lock from a worktree. Unlocking a worktree that is not
+// locked is not an error; the returned bool reports whether a lock was removed.
+func (m *Manager) Unlock(target string) (string, bool, error) {
+	repoRoot, err := m.RequireRepo()
+	if err != nil {
+		return "", false, err
+	}
+	wt, err := m.findWorktreeLite(repoRoot, target)
+	if err != nil {
+		return "", false, err
+	}
+	if !wt.Locked {
+		return wt.Path, false, nil
+	}
+	if err := m.runCmdQuiet(repoRoot, "git", "worktree", "unlock", wt.Path); err != nil {
+		return "", false, err
+	}
+	return wt.Path, true, nil
+}
+
+// Move renames the branch of a worktree, relocates its directory to the path
+// derived from the new branch name, and repairs git's worktree metadata. Any
+// tmux session for the worktree is renamed to match.
+func (m *Manager) Move(opts MoveOptions) (string, []string, error) {
+	defer timeOperation("move")()
+	repoRoot, err := m.RequireRepo()
+	if err != nil {
+		return "", nil, err
+	}
+	unlock, err := m.lockRepo(context.Background(), repoRoot, "mv "+opts.Target)
+	if err != nil {
+		return "", nil, err
+	}
+	defer unlock()
+	wt, err := m.FindWorktree(opts.Target)
+	if err != nil {
+		return "", nil, err
+	}
+
+	newBranch := strings.TrimSpace(opts.NewBranch)
+	if newBranch == "" {
+		return "", nil, errors.New("new branch name is required")
+	}
+	if wt.Branch == "" {
+		return "", nil, fmt.Errorf("cannot rename a detached worktree: %s", wt.Path)
+	}
+	if wt.Locked {
+		return "", nil, fmt.Errorf("%s (unlock it first)", lockedWorktreeMessage(wt))
+	}
+	if newBranch == wt.Branch {
+		return "", nil, fmt.Errorf("worktree already uses branch: %s", newBranch)
+	}
+	if _, err := m.runCmdOutput(repoRoot, "git", "check-ref-format", "--branch", newBranch); err != nil {
+		return "", nil, fmt.Errorf("invalid branch name: %s", newBranch)
+	}
+	if m.BranchExists(repoRoot, newBranch) {
+		return "", nil, fmt.Errorf("branch already exists: %s", newBranch)
+	}
+
+	items, err := m.parseWorktreeList(repoRoot)
+	if err != nil {
+		return "", nil, err
+	}
+	mainRoot := repoRoot
+	if len(items) > 0 {
+		mainRoot = absPath(items[0].Path)
+	}
+	if wt.Path == mainRoot {
+		return "", nil, fmt.Errorf("cannot move the main worktree: %s", wt.Path)
+	}
+
+	newPath := m.worktreePath(repoRoot, newBranch)
+	if exists, err := m.pathExists(newPath); err != nil {
+		return "", nil, err
+	} else if exists {
+		return "", nil, fmt.Errorf("target path already exists: %s", newPath)
+	}
+	infoLogf("move_worktree start path=%q branch=%q new_path=%q new_branch=%q", wt.Path, wt.Branch, newPath, newBranch)
+
+	if err := m.runCmdQuiet(mainRoot, "git", "branch", "-m", wt.Branch, newBranch); err != nil {
+		return "", nil, err
+	}
+	if err := m.mkdirAll(filepath.Dir(newPath)); err != nil {
+		_ = m.runCmdQuiet(mainRoot, "git", "branch", "-m", newBranch, wt.Branch)
+		return "", nil, err
+	}
+	if err := m.renamePath(wt.Path, newPath); err != nil {
+		_ = m.runCmdQuiet(mainRoot, "git", "branch", "-m", newBranch, wt.Branch)
+		return "", nil, fmt.Errorf("move %s: %w", wt.Path, err)
+	}
+	if err := m.runCmdQuiet(mainRoot, "git", "worktree", "repair", newPath); err != nil {
+		return "", nil, fmt.Errorf("worktree moved to %s but metadata repair failed (run git worktree repair): %w", newPath, err)
+	}
+	m.removeEmptyParents(filepath.Dir(wt.Path), m.WorktreeRootDir(repoRoot))
+
+	warnings := m.renameWorktreeTmux(repoRoot, wt.Branch, wt.Path, newBranch, newPath)
+	if err := renamePromptHistory(wt.Path, newPath); err != nil {
+		errorLogf("move_worktree prompt_history failed path=%q: %v", newPath, err)
+	}
+	if err := renameAgentSession(wt.Path, newPath); err != nil {
+		errorLogf("move_worktree agent_session failed path=%q: %v", newPath, err)
+	}
+	if err := renameAgentUsage(wt.Path, newPath); err != nil {
+		errorLogf("move_worktree agent_usage failed path=%q: %v", newPath, err)
+	}
+	infoLogf("move_worktree success path=%q branch=%q warnings=%d", newPath, newBranch, len(warnings))
+	return newPath, warnings, nil
+}
+
+func (m *Manager) renameWorktreeTmux(repoRoot, oldBranch, oldPath, newBranch, newPath string) []string {
+	if !m.commandExists("tmux") {
+		return nil
+	}
+	oldSession := m.tmuxWorktreeSessionNameFrom(repoRoot, oldBranch, oldPath)
+	if !m.tmuxWorktreeRunning(oldSession, oldPath) {
+		return nil
+	}
+
+	warnings := []string{}
+	if err := m.tmuxRetagWorktree(oldSession, oldPath, newPath); err != nil {
+		warnings = append(warnings, fmt.Sprintf("unable to move tmux windows to %s: %v", newPath, err))
+	}
+	windows := [][2]string{
+		{m.tmuxAgentWindowName(oldBranch), m.tmuxAgentWindowName(newBranch)},
+		{m.tmuxLazygitWindowName(oldBranch), m.tmuxLazygitWindowName(newBranch)},
+		{m.tmuxWindowName(oldBranch), m.tmuxWindowName(newBranch)},
+	}
+	for _, w := range windows {
+		if w[0] == w[1] || !m.tmuxWindowExists(oldSession, w[0]) {
+			continue
+		}
+		if err := m.runCmdQuiet("", "tmux", "rename-window", "-t", oldSession+":"+w[0], w[1]); err != nil {
+			warnings = append(warnings, fmt.Sprintf("unable to rename tmux window %s: %v", w[0], err))
+		}
+	}
+
+	newSession := m.tmuxWorktreeSessionNameFrom(repoRoot, newBranch, newPath)
+	if newSession != oldSession {
+		if err := m.runCmdQuiet("", "tmux", "rename-session", "-t", oldSession, newSession); err != nil {
+			warnings = append(warnings, fmt.Sprintf("unable to rename tmux session %s: %v", oldSession, err))
+		}
+	}
+	warnings = append(warnings, "running tmux panes still point at the old directory; detach and relaunch to pick up the new path")
+	return warnings
+}
+
+// removeEmptyParents removes dir and its parents while they are empty,
+// stopping at (and never removing) stop, on the SSH host in remote mode.
+func (m *Manager) removeEmptyParents(dir, stop string) {
+	dir = absPath(dir)
+	stop = absPath(stop)
+	for dir != stop && strings.HasPrefix(dir, stop+string(filepath.Separator)) {
+		if err := m.removePath(dir); err != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
 type deleteItem struct {
 	Rel   string
 	Path  string
@@ -2464,7 +3568,7 @@ func collectDeletePlan(root string) ([]deleteItem, []string, int, int64, error)
 	return items, dirs, totalFiles, totalBytes, nil
 }
 
-func (m *Manager) removeWorktreeWithProgress(repoRoot, worktreePath string, onProgress func(DeleteProgress)) error {
+func (m *Manager) removeWorktreeWithProgress(ctx context.Context, repoRoot, worktreePath string, onProgress func(DeleteProgress)) error {
 	start := time.Now()
 	if onProgress != nil {
 		onProgress(DeleteProgress{Phase: "scan"})
@@ -2481,6 +3585,10 @@ func (m *Manager) removeWorktreeWithProgress(repoRoot, worktreePath string, onPr
 	var deletedBytes int64
 	lastUpdate := time.Time{}
 	for _, item := range items {
+		if err := ctx.Err(); err != nil {
+			infoLogf("remove_worktree delete stopped path=%q deleted=%d total=%d", worktreePath, deletedFiles, totalFiles)
+			return fmt.Errorf("stopped after deleting %d of %d files, %s is partly deleted: %w", deletedFiles, totalFiles, worktreePath, err)
+		}
 		if onProgress != nil {
 			now := time.Now()
 			if deletedFiles == totalFiles || lastUpdate.IsZero() || now.Sub(lastUpdate) >= 120*time.Millisecond {
@@ -2519,124 +3627,389 @@ func (m *Manager) removeWorktreeWithProgress(repoRoot, worktreePath string, onPr
 			TotalBytes:   totalBytes,
 		})
 	}
-	debugLogf("remove_worktree delete done path=%q deleted=%d total=%d bytes=%d/%d dur=%s", worktreePath, deletedFiles, totalFiles, deletedBytes, totalBytes, time.Since(start))
+	infoLogf("remove_worktree delete done path=%q deleted=%d total=%d bytes=%d/%d dur=%s", worktreePath, deletedFiles, totalFiles, deletedBytes, totalBytes, time.Since(start))
 	return nil
 }
 
+// DoctorReport is the result of Doctor. Lines is the flattened, prefixed form
+// of Items kept for plain-text output.
 type DoctorReport struct {
 	Lines       []string
+	Items       []DoctorItem
 	ExitCode    int
 	MissingReqs []string
 }
 
-func (m *Manager) Doctor() DoctorReport {
-	report := DoctorReport{Lines: []string{}, ExitCode: 0}
+// DoctorItem is a single doctor finding. Fix is empty when nothing can be
+// repaired automatically, otherwise one of doctorFixAvailable, doctorFixed, or
+// doctorFixFailed.
+type DoctorItem struct {
+	Check   string
+	Status  string
+	Message string
+	Fix     string
+	FixErr  string
+}
 
-	for _, req := range []string{"git", "tmux"} {
-		if commandExists(req) {
-			report.Lines = append(report.Lines, fmt.Sprintf("ok   %s", req))
-		} else {
-			report.Lines = append(report.Lines, fmt.Sprintf("miss %s", req))
-			report.MissingReqs = append(report.MissingReqs, req)
-			report.ExitCode = 1
-		}
+// Doctor item statuses and fix states.
+const (
+	doctorOK   = "ok"
+	doctorMiss = "miss"
+	doctorWarn = "warn"
+	doctorFail = "fail"
+
+	doctorFixAvailable = "available"
+	doctorFixed        = "fixed"
+	doctorFixFailed    = "failed"
+)
+
+type DoctorOptions struct {
+	// Fix repairs the problems that can be fixed automatically.
+	Fix bool
+}
+
+func (r *DoctorReport) add(item DoctorItem) {
+	line := fmt.Sprintf("%-4s %s", item.Status, item.Message)
+	switch item.Fix {
+	case doctorFixAvailable:
+		line += " (fixable with --fix)"
+	case doctorFixed:
+		line += " (fixed)"
+	case doctorFixFailed:
+		line += fmt.Sprintf(" (fix failed: %s)", item.FixErr)
+		r.ExitCode = 1
+	}
+	if item.Status == doctorMiss || item.Status == doctorFail {
+		r.ExitCode = 1
+	}
+	r.Items = append(r.Items, item)
+	r.Lines = append(r.Lines, line)
+}
+
+// applyFix runs fix when fixing is enabled and records the outcome on item.
+func (item *DoctorItem) applyFix(enabled bool, fix func() error) {
+	if !enabled {
+		item.Fix = doctorFixAvailable
+		return
+	}
+	if err := fix(); err != nil {
+		item.Fix = doctorFixFailed
+		item.FixErr = err.Error()
+		return
 	}
+	item.Fix = doctorFixed
+}
 
-	optionals := []string{}
-	seenOptionals := map[string]struct{}{}
-	addOptional := func(name string) {
+// sessionToolExecutables lists the executables needed by the configured
+// session tools and the agent command, without duplicates.
+func (m *Manager) sessionToolExecutables() []string {
+	names := []string{}
+	seen := map[string]struct{}{}
+	add := func(name string) {
 		name = strings.TrimSpace(name)
 		if name == "" {
 			return
 		}
-		if _, exists := seenOptionals[name]; exists {
+		if _, exists := seen[name]; exists {
 			return
 		}
-		seenOptionals[name] = struct{}{}
-		optionals = append(optionals, name)
+		seen[name] = struct{}{}
+		names = append(names, name)
 	}
 
 	for _, tool := range normalizeSessionTools(m.Cfg.SessionTools) {
 		switch strings.ToLower(strings.TrimSpace(tool)) {
 		case "agent":
-			addOptional(commandExecutableName(m.agentCommand()))
+			add(commandExecutableName(m.agentCommand()))
 		case "nvim", "neovim":
-			addOptional("nvim")
+			add("nvim")
 		case "lazygit":
-			addOptional("lazygit")
+			add("lazygit")
 		default:
-			addOptional(commandExecutableName(tool))
+			add(commandExecutableName(tool))
 		}
 	}
-	addOptional(commandExecutableName(m.agentCommand()))
+	add(commandExecutableName(m.agentCommand()))
+	return names
+}
+
+func (m *Manager) Doctor(opts DoctorOptions) DoctorReport {
+	report := DoctorReport{Lines: []string{}, Items: []DoctorItem{}, ExitCode: 0}
 
-	for _, opt := range optionals {
-		if commandExists(opt) {
-			report.Lines = append(report.Lines, fmt.Sprintf("ok   %s", opt))
+	for _, req := range []string{"git", "tmux"} {
+		if m.commandExists(req) {
+			report.add(DoctorItem{Check: "requirement", Status: doctorOK, Message: req})
 		} else {
-			report.Lines = append(report.Lines, fmt.Sprintf("warn %s (optional)", opt))
+			report.add(DoctorItem{Check: "requirement", Status: doctorMiss, Message: req})
+			report.MissingReqs = append(report.MissingReqs, req)
 		}
 	}
 
-	repoRoot, err := m.RequireRepo()
-	if err != nil {
-		report.Lines = append(report.Lines, "warn not inside a git repository; skipped worktree checks")
+	for _, opt := range m.sessionToolExecutables() {
+		if m.commandExists(opt) {
+			report.add(DoctorItem{Check: "tool", Status: doctorOK, Message: opt})
+		} else {
+			report.add(DoctorItem{Check: "tool", Status: doctorWarn, Message: opt + " (optional)"})
+		}
+	}
+
+	repoRoot, repoErr := m.RequireRepo()
+	m.doctorConfig(&report, repoRoot)
+
+	if repoErr != nil {
+		report.add(DoctorItem{Check: "repo", Status: doctorWarn, Message: "not inside a git repository; skipped worktree checks"})
 		return report
 	}
 
 	items, err := m.parseWorktreeList(repoRoot)
 	if err != nil {
-		report.Lines = append(report.Lines, fmt.Sprintf("warn unable to parse worktrees: %v", err))
+		report.add(DoctorItem{Check: "worktree", Status: doctorWarn, Message: fmt.Sprintf("unable to parse worktrees: %v", err)})
 		return report
 	}
+	m.doctorWorktrees(&report, repoRoot, items, opts.Fix)
+	m.doctorLayout(&report, repoRoot)
+	m.doctorWorktreeLayout(&report, repoRoot, items, opts.Fix)
+	if m.commandExists("tmux") {
+		m.doctorTmuxSessions(&report, repoRoot, items, opts.Fix)
+	}
+	return report
+}
+
+// doctorConfig parses each config file on its own so a broken file is named
+// in the report instead of failing the whole run.
+func (m *Manager) doctorConfig(report *DoctorReport, repoRoot string) {
+	type configFile struct {
+		path   string
+		isRepo bool
+	}
+	var files []configFile
+	if path := globalConfigPath(); path != "" {
+		files = append(files, configFile{path: path})
+	}
+	if repoRoot != "" {
+		files = append(files, configFile{path: filepath.Join(repoRoot, ".sprout.toml"), isRepo: true})
+	}
+	for _, f := range files {
+		if _, err := os.Stat(f.path); err != nil {
+			continue
+		}
+		scratch := DefaultConfig()
+		err := parseTOMLFlat(f.path, &scratch)
+		if err == nil && !f.isRepo && repoRoot != "" {
+			err = parseTOMLFlatTable(f.path, &scratch, repoConfigTable(m.RepoName(repoRoot)))
+		}
+		if err == nil {
+			err = parseTOMLStructured(f.path, &scratch, m.RepoName(repoRoot), f.isRepo)
+		}
+		if err != nil {
+			report.add(DoctorItem{Check: "config", Status: doctorFail, Message: fmt.Sprintf("invalid config %s: %v", f.path, err)})
+			continue
+		}
+		report.add(DoctorItem{Check: "config", Status: doctorOK, Message: "config " + f.path})
+	}
+}
+
+// doctorLayout reports the [[windows]] problems sprout config lint finds,
+// checking dirs against the main worktree.
+func (m *Manager) doctorLayout(report *DoctorReport, repoRoot string) {
+	if len(m.Cfg.Windows) == 0 {
+		return
+	}
+	issues := m.LintWindows(repoRoot)
+	for _, issue := range issues {
+		report.add(DoctorItem{Check: "layout", Status: doctorWarn, Message: "layout: " + issue.String()})
+	}
+	if len(issues) == 0 {
+		report.add(DoctorItem{Check: "layout", Status: doctorOK, Message: "window layout"})
+	}
+}
+
+func (m *Manager) doctorWorktrees(report *DoctorReport, repoRoot string, items []Worktree, fix bool) {
 	bad := false
-	for _, wt := range items {
-		if st, err := os.Stat(wt.Path); err != nil || !st.IsDir() {
-			report.Lines = append(report.Lines, fmt.Sprintf("warn missing worktree path: %s", wt.Path))
+	pruned := false
+	for i, wt := range items {
+		if !m.dirExists(wt.Path) {
 			bad = true
+			item := DoctorItem{Check: "worktree", Status: doctorWarn, Message: "stale worktree registration: " + wt.Path}
+			if wt.Locked {
+				item.Message += " (locked; unlock it to prune)"
+				report.add(item)
+				continue
+			}
+			item.applyFix(fix, func() error {
+				if !pruned {
+					if err := m.runCmdQuiet(repoRoot, "git", "worktree", "prune"); err != nil {
+						return err
+					}
+					pruned = true
+				}
+				return nil
+			})
+			report.add(item)
 			continue
 		}
+		if i > 0 {
+			if err := m.checkWorktreeGitdir(wt.Path); err != nil {
+				bad = true
+				item := DoctorItem{Check: "worktree", Status: doctorWarn, Message: fmt.Sprintf("broken gitdir pointer for %s: %v", wt.Path, err)}
+				item.applyFix(fix, func() error {
+					return m.repairWorktreeGitdir(repoRoot, wt.Path)
+				})
+				report.add(item)
+			}
+		}
 		if wt.Branch != "" && !m.BranchExists(repoRoot, wt.Branch) {
-			report.Lines = append(report.Lines, fmt.Sprintf("warn branch missing for worktree %s: %s", wt.Path, wt.Branch))
 			bad = true
+			report.add(DoctorItem{Check: "worktree", Status: doctorWarn, Message: fmt.Sprintf("branch missing for worktree %s: %s", wt.Path, wt.Branch)})
 		}
 	}
 	if !bad {
-		report.Lines = append(report.Lines, "ok   worktree metadata")
+		report.add(DoctorItem{Check: "worktree", Status: doctorOK, Message: "worktree metadata"})
+	}
+	if pruned {
+		infoLogf("doctor pruned stale worktrees repo=%q", repoRoot)
 	}
-	return report
 }
 
-func runCmdBytes(dir, name string, args ...string) ([]byte, error) {
-	return runCmdBytesWithTimeout(dir, 0, name, args...)
+// checkWorktreeGitdir verifies that a linked worktree's .git file points at
+// an administrative directory that points back at the worktree.
+func (m *Manager) checkWorktreeGitdir(path string) error {
+	data, err := m.readFile(filepath.Join(path, ".git"))
+	if err != nil {
+		return err
+	}
+	gitdir, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir:")
+	if !ok {
+		return errors.New(".git is not a gitdir file")
+	}
+	gitdir = strings.TrimSpace(gitdir)
+	if !filepath.IsAbs(gitdir) {
+		gitdir = filepath.Join(path, gitdir)
+	}
+	back, err := m.readFile(filepath.Join(gitdir, "gitdir"))
+	if err != nil {
+		return fmt.Errorf("gitdir %s is missing", gitdir)
+	}
+	want := filepath.Join(path, ".git")
+	got := strings.TrimSpace(string(back))
+	if !samePath(got, want) {
+		return fmt.Errorf("gitdir %s points to %s", gitdir, got)
+	}
+	return nil
+}
+
+// repairWorktreeGitdir runs git worktree repair. Older gits cannot follow a
+// .git file that points at a missing directory, so the file is rewritten from
+// the administrative directory that still points back at path. That
+// fallback is local only: on an SSH host git's repair is all there is.
+func (m *Manager) repairWorktreeGitdir(repoRoot, path string) error {
+	repairErr := m.runCmdQuiet(repoRoot, "git", "worktree", "repair", path)
+	if repairErr == nil && m.checkWorktreeGitdir(path) == nil {
+		return nil
+	}
+	if m.sshRemoteActive() {
+		if repairErr != nil {
+			return repairErr
+		}
+		return m.checkWorktreeGitdir(path)
+	}
+	commonDir, err := m.runCmdOutput(repoRoot, "git", "rev-parse", "--path-format=absolute", "--git-common-dir")
+	if err != nil {
+		return err
+	}
+	admins, err := os.ReadDir(filepath.Join(commonDir, "worktrees"))
+	if err != nil {
+		return err
+	}
+	for _, admin := range admins {
+		adminDir := filepath.Join(commonDir, "worktrees", admin.Name())
+		back, err := os.ReadFile(filepath.Join(adminDir, "gitdir"))
+		if err != nil || !samePath(strings.TrimSpace(string(back)), filepath.Join(path, ".git")) {
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(path, ".git"), []byte("gitdir: "+adminDir+"\n"), 0o644); err != nil {
+			return err
+		}
+		return m.checkWorktreeGitdir(path)
+	}
+	if repairErr != nil {
+		return repairErr
+	}
+	return m.checkWorktreeGitdir(path)
+}
+
+func samePath(a, b string) bool {
+	if filepath.Clean(a) == filepath.Clean(b) {
+		return true
+	}
+	ra, errA := filepath.EvalSymlinks(a)
+	rb, errB := filepath.EvalSymlinks(b)
+	return errA == nil && errB == nil && ra == rb
+}
+
+func (m *Manager) doctorTmuxSessions(report *DoctorReport, repoRoot string, items []Worktree, fix bool) {
+	sessions, err := m.sproutSessions(repoRoot, items)
+	if err != nil {
+		report.add(DoctorItem{Check: "tmux", Status: doctorWarn, Message: fmt.Sprintf("unable to list tmux sessions: %v", err)})
+		return
+	}
+	orphans := orphanSessionNames(sessions)
+	if len(orphans) == 0 {
+		report.add(DoctorItem{Check: "tmux", Status: doctorOK, Message: "tmux sessions"})
+		return
+	}
+	for _, session := range orphans {
+		item := DoctorItem{Check: "tmux", Status: doctorWarn, Message: "orphaned tmux session: " + session}
+		item.applyFix(fix, func() error {
+			return m.runCmdQuiet("", "tmux", "kill-session", "-t", session)
+		})
+		report.add(item)
+	}
+}
+
+func (m *Manager) runCmdBytes(dir, name string, args ...string) ([]byte, error) {
+	return m.runCmdBytesContext(context.Background(), dir, 0, name, args...)
 }
 
-func runCmdBytesWithTimeout(dir string, timeout time.Duration, name string, args ...string) ([]byte, error) {
+// runCmdBytesContext runs a command that is killed when ctx is done or
+// timeout (if positive) passes. A command stopped by ctx returns an error
+// wrapping ctx.Err().
+func (m *Manager) runCmdBytesContext(parent context.Context, dir string, timeout time.Duration, name string, args ...string) ([]byte, error) {
+	if err := parent.Err(); err != nil {
+		return nil, fmt.Errorf("%s %s not run: %w", name, strings.Join(args, " "), err)
+	}
 	start := time.Now()
 	timeoutInfo := ""
 	if timeout > 0 {
 		timeoutInfo = fmt.Sprintf(" timeout=%s", timeout)
 	}
-	debugLogf("cmd start dir=%q name=%q args=%q%s", dir, name, strings.Join(args, " "), timeoutInfo)
-	ctx := context.Background()
-	cancel := func() {}
+	traceLogf("cmd start dir=%q name=%q args=%q%s", dir, name, strings.Join(args, " "), timeoutInfo)
+	ctx, cancel := parent, context.CancelFunc(func() {})
 	if timeout > 0 {
-		ctx, cancel = context.WithTimeout(context.Background(), timeout)
+		ctx, cancel = context.WithTimeout(parent, timeout)
 	}
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, name, args...)
-	if dir != "" {
-		cmd.Dir = dir
+	runDir, runName, runArgs := m.remoteCommand(dir, name, args, false)
+	cmd := exec.CommandContext(ctx, runName, runArgs...)
+	if runDir != "" {
+		cmd.Dir = runDir
 	}
+	// Children such as git's hooks may keep the output pipe open after the
+	// command is killed; do not wait on them.
+	cmd.WaitDelay = 2 * time.Second
 	out, err := cmd.CombinedOutput()
 	elapsed := time.Since(start)
+	recordCmdTiming(dir, name, args, elapsed, err != nil)
 	if err != nil {
 		trimmed := strings.TrimSpace(string(out))
 		if len(trimmed) > 600 {
 			trimmed = trimmed[:600] + "...(truncated)"
 		}
 		debugLogf("cmd fail dur=%s dir=%q name=%q args=%q err=%v out=%q", elapsed, dir, name, strings.Join(args, " "), err, trimmed)
+		if parentErr := parent.Err(); parentErr != nil {
+			return nil, fmt.Errorf("%s %s stopped: %w", name, strings.Join(args, " "), parentErr)
+		}
 		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
 			if trimmed != "" {
 				return nil, fmt.Errorf("%s %s timed out after %s: %s", name, strings.Join(args, " "), timeout, trimmed)
@@ -2648,28 +4021,30 @@ func runCmdBytesWithTimeout(dir string, timeout time.Duration, name string, args
 		}
 		return nil, fmt.Errorf("%s %s failed: %w", name, strings.Join(args, " "), err)
 	}
-	debugLogf("cmd ok dur=%s dir=%q name=%q args=%q out_bytes=%d", elapsed, dir, name, strings.Join(args, " "), len(out))
+	traceLogf("cmd ok dur=%s dir=%q name=%q args=%q out_bytes=%d", elapsed, dir, name, strings.Join(args, " "), len(out))
 	return out, nil
 }
 
-func runCmdBytesAllowExitCodes(dir string, allowedExitCodes []int, name string, args ...string) ([]byte, error) {
+func (m *Manager) runCmdBytesAllowExitCodes(dir string, allowedExitCodes []int, name string, args ...string) ([]byte, error) {
 	allowed := map[int]struct{}{}
 	for _, code := range allowedExitCodes {
 		allowed[code] = struct{}{}
 	}
 
 	start := time.Now()
-	debugLogf("cmd start dir=%q name=%q args=%q allowed_exit=%v", dir, name, strings.Join(args, " "), allowedExitCodes)
-	cmd := exec.Command(name, args...)
-	if dir != "" {
-		cmd.Dir = dir
+	traceLogf("cmd start dir=%q name=%q args=%q allowed_exit=%v", dir, name, strings.Join(args, " "), allowedExitCodes)
+	runDir, runName, runArgs := m.remoteCommand(dir, name, args, false)
+	cmd := exec.Command(runName, runArgs...)
+	if runDir != "" {
+		cmd.Dir = runDir
 	}
 	out, err := cmd.CombinedOutput()
 	elapsed := time.Since(start)
 	if err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok {
 			if _, ok := allowed[exitErr.ExitCode()]; ok {
-				debugLogf("cmd ok-allowed-exit dur=%s dir=%q name=%q args=%q exit=%d out_bytes=%d", elapsed, dir, name, strings.Join(args, " "), exitErr.ExitCode(), len(out))
+				recordCmdTiming(dir, name, args, elapsed, false)
+				traceLogf("cmd ok-allowed-exit dur=%s dir=%q name=%q args=%q exit=%d out_bytes=%d", elapsed, dir, name, strings.Join(args, " "), exitErr.ExitCode(), len(out))
 				return out, nil
 			}
 		}
@@ -2677,26 +4052,30 @@ func runCmdBytesAllowExitCodes(dir string, allowedExitCodes []int, name string,
 		if len(trimmed) > 600 {
 			trimmed = trimmed[:600] + "...(truncated)"
 		}
+		recordCmdTiming(dir, name, args, elapsed, true)
 		debugLogf("cmd fail dur=%s dir=%q name=%q args=%q err=%v out=%q", elapsed, dir, name, strings.Join(args, " "), err, trimmed)
 		if trimmed != "" {
 			return nil, fmt.Errorf("%s %s failed: %w: %s", name, strings.Join(args, " "), err, trimmed)
 		}
 		return nil, fmt.Errorf("%s %s failed: %w", name, strings.Join(args, " "), err)
 	}
-	debugLogf("cmd ok dur=%s dir=%q name=%q args=%q out_bytes=%d", elapsed, dir, name, strings.Join(args, " "), len(out))
+	recordCmdTiming(dir, name, args, elapsed, false)
+	traceLogf("cmd ok dur=%s dir=%q name=%q args=%q out_bytes=%d", elapsed, dir, name, strings.Join(args, " "), len(out))
 	return out, nil
 }
 
-func runCmdBytesInput(dir string, stdin []byte, name string, args ...string) ([]byte, error) {
+func (m *Manager) runCmdBytesInput(dir string, stdin []byte, name string, args ...string) ([]byte, error) {
 	start := time.Now()
-	debugLogf("cmd start dir=%q name=%q args=%q stdin_bytes=%d", dir, name, strings.Join(args, " "), len(stdin))
-	cmd := exec.Command(name, args...)
-	if dir != "" {
-		cmd.Dir = dir
+	traceLogf("cmd start dir=%q name=%q args=%q stdin_bytes=%d", dir, name, strings.Join(args, " "), len(stdin))
+	runDir, runName, runArgs := m.remoteCommand(dir, name, args, false)
+	cmd := exec.Command(runName, runArgs...)
+	if runDir != "" {
+		cmd.Dir = runDir
 	}
 	cmd.Stdin = bytes.NewReader(stdin)
 	out, err := cmd.CombinedOutput()
 	elapsed := time.Since(start)
+	recordCmdTiming(dir, name, args, elapsed, err != nil)
 	if err != nil {
 		trimmed := strings.TrimSpace(string(out))
 		if len(trimmed) > 600 {
@@ -2708,33 +4087,37 @@ func runCmdBytesInput(dir string, stdin []byte, name string, args ...string) ([]
 		}
 		return nil, fmt.Errorf("%s %s failed: %w", name, strings.Join(args, " "), err)
 	}
-	debugLogf("cmd ok dur=%s dir=%q name=%q args=%q out_bytes=%d", elapsed, dir, name, strings.Join(args, " "), len(out))
+	traceLogf("cmd ok dur=%s dir=%q name=%q args=%q out_bytes=%d", elapsed, dir, name, strings.Join(args, " "), len(out))
 	return out, nil
 }
 
-func runCmdOutput(dir, name string, args ...string) (string, error) {
-	out, err := runCmdBytes(dir, name, args...)
+func (m *Manager) runCmdOutput(dir, name string, args ...string) (string, error) {
+	return m.runCmdOutputContext(context.Background(), dir, name, args...)
+}
+
+func (m *Manager) runCmdOutputContext(ctx context.Context, dir, name string, args ...string) (string, error) {
+	out, err := m.runCmdBytesContext(ctx, dir, 0, name, args...)
 	if err != nil {
 		return "", err
 	}
 	return strings.TrimRight(string(out), "\n"), nil
 }
 
-func runCmdOutputAllowExitCodes(dir string, allowedExitCodes []int, name string, args ...string) (string, error) {
-	out, err := runCmdBytesAllowExitCodes(dir, allowedExitCodes, name, args...)
+func (m *Manager) runCmdOutputAllowExitCodes(dir string, allowedExitCodes []int, name string, args ...string) (string, error) {
+	out, err := m.runCmdBytesAllowExitCodes(dir, allowedExitCodes, name, args...)
 	if err != nil {
 		return "", err
 	}
 	return strings.TrimRight(string(out), "\n"), nil
 }
 
-func runCmdQuiet(dir, name string, args ...string) error {
-	_, err := runCmdBytes(dir, name, args...)
+func (m *Manager) runCmdQuiet(dir, name string, args ...string) error {
+	_, err := m.runCmdBytes(dir, name, args...)
 	return err
 }
 
-func runCmdQuietTimeout(dir string, timeout time.Duration, name string, args ...string) error {
-	_, err := runCmdBytesWithTimeout(dir, timeout, name, args...)
+func (m *Manager) runCmdQuietContext(ctx context.Context, dir, name string, args ...string) error {
+	_, err := m.runCmdBytesContext(ctx, dir, 0, name, args...)
 	return err
 }
 
@@ -2803,13 +4186,13 @@ func shouldRetryWorktreeRemove(err error) bool {
 	}
 }
 
-func (m *Manager) runGitWorktreeAdd(repoRoot string, args ...string) error {
+func (m *Manager) runGitWorktreeAdd(ctx context.Context, repoRoot string, args ...string) error {
 	allArgs := append([]string{"worktree", "add"}, args...)
 	timeout := gitWorktreeCommandTimeout()
-	if err := runCmdQuietTimeout(repoRoot, timeout, "git", allArgs...); err != nil {
-		if shouldRetryWorktreeAdd(err) {
-			_ = runCmdQuiet(repoRoot, "git", "worktree", "prune")
-			if retryErr := runCmdQuietTimeout(repoRoot, timeout, "git", allArgs...); retryErr == nil {
+	if _, err := m.runCmdBytesContext(ctx, repoRoot, timeout, "git", allArgs...); err != nil {
+		if ctx.Err() == nil && shouldRetryWorktreeAdd(err) {
+			_ = m.runCmdQuiet(repoRoot, "git", "worktree", "prune")
+			if _, retryErr := m.runCmdBytesContext(ctx, repoRoot, timeout, "git", allArgs...); retryErr == nil {
 				return nil
 			} else {
 				return retryErr
@@ -2820,20 +4203,21 @@ func (m *Manager) runGitWorktreeAdd(repoRoot string, args ...string) error {
 	return nil
 }
 
-func (m *Manager) runGitWorktreeRemove(repoRoot, worktreePath string, force bool) error {
+func (m *Manager) runGitWorktreeRemove(ctx context.Context, repoRoot, worktreePath string, force bool) error {
 	args := []string{"worktree", "remove"}
 	if force {
 		args = append(args, "--force")
 	}
 	args = append(args, worktreePath)
-	timeout := gitWorktreeCommandTimeout()
-	return runCmdQuietTimeout(repoRoot, timeout, "git", args...)
+	_, err := m.runCmdBytesContext(ctx, repoRoot, gitWorktreeCommandTimeout(), "git", args...)
+	return err
 }
 
-func runCmdInherit(dir, name string, args ...string) error {
-	cmd := exec.Command(name, args...)
-	if dir != "" {
-		cmd.Dir = dir
+func (m *Manager) runCmdInherit(dir, name string, args ...string) error {
+	runDir, runName, runArgs := m.remoteCommand(dir, name, args, true)
+	cmd := exec.Command(runName, runArgs...)
+	if runDir != "" {
+		cmd.Dir = runDir
 	}
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
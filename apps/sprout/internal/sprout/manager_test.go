@@ -1,15 +1,74 @@
 package sprout
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
 )
 
+// newTestRepo creates a git repository with one empty commit on main and
+// makes it the working directory until the test ends. run runs git in a
+// directory and returns its output, failing the test when git fails.
+func newTestRepo(t *testing.T) (string, func(dir string, args ...string) string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is required for this test")
+	}
+	base, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("resolve temp dir failed: %v", err)
+	}
+	repo := filepath.Join(base, "repo")
+	if err := os.MkdirAll(repo, 0o755); err != nil {
+		t.Fatalf("mkdir repo failed: %v", err)
+	}
+	run := func(dir string, args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %s failed: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+		}
+		return strings.TrimSpace(string(out))
+	}
+	run(repo, "init", "-b", "main")
+	run(repo, "config", "user.email", "sprout-test@example.com")
+	run(repo, "config", "user.name", "Sprout Test")
+	run(repo, "commit", "--allow-empty", "-m", "init")
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getwd failed: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = os.Chdir(wd)
+	})
+	if err := os.Chdir(repo); err != nil {
+		t.Fatalf("chdir failed: %v", err)
+	}
+	return repo, run
+}
+
 func TestSlugify(t *testing.T) {
 	m := NewManager(DefaultConfig())
 	got, err := m.Slugify("Checkout Redesign_v2")
@@ -21,6 +80,40 @@ func TestSlugify(t *testing.T) {
 	}
 }
 
+func TestSlugifyNonASCII(t *testing.T) {
+	m := NewManager(DefaultConfig())
+	for input, want := range map[string]string{
+		"Café Crème":    "cafe-creme",
+		"Привет мир":    "privet-mir",
+		"Straße":        "strasse",
+		"ログイン":          "roguin",
+		"きょうのチェック":      "kyounochekku",
+		"fix 画面 layout": "fix-layout",
+	} {
+		got, err := m.Slugify(input)
+		if err != nil || got != want {
+			t.Fatalf("Slugify(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+	if _, err := m.Slugify("画面"); err == nil || !strings.Contains(err.Error(), "slug_mode") {
+		t.Fatalf("expected empty slug error to suggest slug_mode, got %v", err)
+	}
+
+	cfg := DefaultConfig()
+	cfg.SlugMode = slugModeUnicode
+	got, err := NewManager(cfg).Slugify("ログイン 画面!")
+	if err != nil || got != "ログイン-画面" {
+		t.Fatalf("unicode Slugify = %q, %v", got, err)
+	}
+
+	if a, b := sessionToken("feat/日本語"), sessionToken("feat/中文"); a == b || !strings.HasPrefix(a, "feat-") {
+		t.Fatalf("unexpected session tokens %q and %q", a, b)
+	}
+	if got := sessionToken("feat/Checkout_v2"); got != "feat-Checkout_v2" {
+		t.Fatalf("ASCII session token changed: %q", got)
+	}
+}
+
 func TestMakeBranchName(t *testing.T) {
 	m := NewManager(DefaultConfig())
 	got, err := m.MakeBranchName("feat", "my feature")
@@ -145,6 +238,61 @@ func TestResolvePaneDir(t *testing.T) {
 	}
 }
 
+func TestWorktreeStartDir(t *testing.T) {
+	for _, bad := range []string{"/opt/web", "..", "../other/web"} {
+		if _, err := parseWorktreeSubdir(bad); err == nil {
+			t.Fatalf("expected error for worktree_subdir %q", bad)
+		}
+	}
+	sub, err := parseWorktreeSubdir("apps/web/")
+	if err != nil || sub != filepath.Join("apps", "web") {
+		t.Fatalf("parseWorktreeSubdir = %q, %v", sub, err)
+	}
+
+	worktree := t.TempDir()
+	m := &Manager{Cfg: DefaultConfig()}
+	if got := m.worktreeStartDir(worktree); got != worktree {
+		t.Fatalf("start dir without subdir = %q, want worktree root", got)
+	}
+	m.Cfg.WorktreeSubdir = sub
+	if got := m.worktreeStartDir(worktree); got != worktree {
+		t.Fatalf("start dir with missing subdir = %q, want worktree root", got)
+	}
+	if err := os.MkdirAll(filepath.Join(worktree, sub), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if got, want := m.worktreeStartDir(worktree), filepath.Join(worktree, sub); got != want {
+		t.Fatalf("start dir = %q, want %q", got, want)
+	}
+}
+
+func TestDisplayPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	repo := filepath.Join(home, "code", "app")
+	m := &Manager{Cfg: DefaultConfig()}
+	m.Cfg.WorktreeRootTemplate = filepath.Join(home, "code", "{repo}.worktrees")
+	wt := filepath.Join(home, "code", "app.worktrees", "feat", "x")
+
+	if got := m.displayPath(repo, wt); got != wt {
+		t.Fatalf("absolute display = %q, want %q", got, wt)
+	}
+	m.Cfg.PathDisplay = pathDisplayHome
+	if got, want := m.displayPath(repo, wt), filepath.Join("~", "code", "app.worktrees", "feat", "x"); got != want {
+		t.Fatalf("home display = %q, want %q", got, want)
+	}
+	m.Cfg.PathDisplay = pathDisplayRelative
+	if got, want := m.displayPath(repo, wt), filepath.Join("feat", "x"); got != want {
+		t.Fatalf("relative display = %q, want %q", got, want)
+	}
+	if got, want := m.displayPath(repo, repo), filepath.Join("~", "code", "app"); got != want {
+		t.Fatalf("relative display outside the worktree root = %q, want %q", got, want)
+	}
+	if _, err := parsePathDisplay("short"); err == nil {
+		t.Fatalf("expected error for unknown path_display")
+	}
+}
+
 func TestCommandShouldRemainOnExit(t *testing.T) {
 	tests := []struct {
 		command string
@@ -168,6 +316,18 @@ func TestCommandShouldRemainOnExit(t *testing.T) {
 	}
 }
 
+func TestWithRemainOnExit(t *testing.T) {
+	args := []string{"new-window", "-d"}
+	if got := withRemainOnExit(args, "s", "w", "bash"); !reflect.DeepEqual(got, args) {
+		t.Fatalf("withRemainOnExit for a shell = %q, want %q", got, args)
+	}
+	got := withRemainOnExit(args, "s", "agent-main", "codex --full-auto")
+	want := []string{"new-window", "-d", ";", "set-window-option", "-t", "s:agent-main", "remain-on-exit", "on"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("withRemainOnExit for an agent = %q, want %q", got, want)
+	}
+}
+
 func TestCopyUntrackedExcludeMatch(t *testing.T) {
 	cfg := DefaultConfig()
 	cfg.CopyUntrackedExclude = []string{"build", "dist/**", "*.log", "tmp/"}
@@ -328,7 +488,7 @@ func TestNewWorktreeFromExistingReturnsExistingWorktreePath(t *testing.T) {
 
 	cfg := DefaultConfig()
 	m := NewManager(cfg)
-	branch, gotPath, err := m.NewWorktree(NewOptions{FromBranch: "feature/existing", Launch: false})
+	branch, gotPath, err := m.NewWorktree(context.Background(), NewOptions{FromBranch: "feature/existing", Launch: false})
 	if err != nil {
 		t.Fatalf("NewWorktree failed: %v", err)
 	}
@@ -401,3 +561,3601 @@ func TestWorktreeDiffForFile_UntrackedShowsPatch(t *testing.T) {
 		t.Fatalf("expected file name in diff, got: %q", diff)
 	}
 }
+
+func TestRecordPromptHistory(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	wt := "/tmp/repo.worktrees/feat/x"
+	for _, prompt := range []string{"first", "second", "second", "  "} {
+		if err := recordPrompt(wt, prompt); err != nil {
+			t.Fatalf("recordPrompt(%q) failed: %v", prompt, err)
+		}
+	}
+
+	got := promptHistoryFor(wt)
+	if len(got) != 2 || got[0].Prompt != "first" || got[1].Prompt != "second" {
+		t.Fatalf("unexpected history: %+v", got)
+	}
+	if other := promptHistoryFor("/tmp/other"); len(other) != 0 {
+		t.Fatalf("expected empty history for other worktree, got %+v", other)
+	}
+
+	for i := 0; i < promptHistoryLimit+5; i++ {
+		if err := recordPrompt(wt, fmt.Sprintf("prompt %d", i)); err != nil {
+			t.Fatalf("recordPrompt failed: %v", err)
+		}
+	}
+	got = promptHistoryFor(wt)
+	if len(got) != promptHistoryLimit {
+		t.Fatalf("expected history capped at %d, got %d", promptHistoryLimit, len(got))
+	}
+	if last := got[len(got)-1].Prompt; last != fmt.Sprintf("prompt %d", promptHistoryLimit+4) {
+		t.Fatalf("unexpected last prompt: %q", last)
+	}
+}
+
+func TestRecordPromptKeepsCorruptHistory(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	path, err := promptHistoryPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	corrupt := []byte(`{"worktrees": {"/tmp/a": [`)
+	if err := os.WriteFile(path, corrupt, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := recordPrompt("/tmp/b", "hello"); err != nil {
+		t.Fatalf("recordPrompt failed: %v", err)
+	}
+	if got := promptHistoryFor("/tmp/b"); len(got) != 1 || got[0].Prompt != "hello" {
+		t.Fatalf("expected a new history with the prompt, got %+v", got)
+	}
+	if data, err := os.ReadFile(path + ".corrupt"); err != nil || string(data) != string(corrupt) {
+		t.Fatalf("expected the corrupt history moved aside, got %q (%v)", data, err)
+	}
+}
+
+func TestAgentSessionsRecordForgetRename(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	recordAgentSession("/tmp/repo.worktrees/feat/a", "claude")
+	recordAgentSession("/tmp/repo.worktrees/feat/b", "")
+	forgetAgentSession("/tmp/repo.worktrees/feat/b")
+	if err := renameAgentSession("/tmp/repo.worktrees/feat/a", "/tmp/repo.worktrees/feat/c"); err != nil {
+		t.Fatalf("renameAgentSession failed: %v", err)
+	}
+
+	sessions, err := readAgentSessions()
+	if err != nil {
+		t.Fatalf("readAgentSessions failed: %v", err)
+	}
+	if len(sessions.Worktrees) != 1 {
+		t.Fatalf("expected one recorded agent, got %+v", sessions.Worktrees)
+	}
+	if got, ok := sessions.Worktrees["/tmp/repo.worktrees/feat/c"]; !ok || got.AgentType != "claude" {
+		t.Fatalf("expected the claude agent under its new path, got %+v", sessions.Worktrees)
+	}
+}
+
+func TestParseAgentUsage(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   AgentUsage
+		ok     bool
+	}{
+		{name: "none", output: "hello\n> ", ok: false},
+		{name: "codex footer", output: "working\n\x1b[2m 58% context left · 12.3K tokens used\x1b[0m\n", want: AgentUsage{Tokens: 12300, ContextLeft: 58}, ok: true},
+		{name: "codex exit", output: "Token usage: total=45,210 input=40,000 output=5,210\n", want: AgentUsage{Tokens: 45210}, ok: true},
+		{name: "aider latest", output: "Tokens: 2k sent, 100 received. Cost: $0.01 message, $0.10 session.\nTokens: 3k sent, 200 received. Cost: $0.02 message, $0.12 session.\n", want: AgentUsage{CostUSD: 0.12}, ok: true},
+		{name: "claude cost", output: "Total cost:            $1.27\nTotal duration (API):  2m\n", want: AgentUsage{CostUSD: 1.27}, ok: true},
+	}
+	for _, tc := range tests {
+		got, ok := parseAgentUsage(tc.output)
+		if ok != tc.ok || got != tc.want {
+			t.Fatalf("%s: parseAgentUsage = %+v, %t; want %+v, %t", tc.name, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestRecordAgentUsage(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	wt := "/tmp/repo.worktrees/feat/a"
+
+	if _, err := recordAgentUsage(wt, AgentUsage{Tokens: 1000, ContextLeft: 90}); err != nil {
+		t.Fatalf("recordAgentUsage failed: %v", err)
+	}
+	total, _ := recordAgentUsage(wt, AgentUsage{Tokens: 5000, CostUSD: 0.5})
+	if total.Tokens != 5000 || total.CostUSD != 0.5 || total.ContextLeft != 90 {
+		t.Fatalf("running totals should replace the last report: %+v", total)
+	}
+	// Lower running totals come from a new agent session.
+	total, _ = recordAgentUsage(wt, AgentUsage{Tokens: 200})
+	if total.Tokens != 5200 || total.CostUSD != 0.5 {
+		t.Fatalf("a new session should add to the earlier ones: %+v", total)
+	}
+
+	if err := renameAgentUsage(wt, "/tmp/repo.worktrees/feat/b"); err != nil {
+		t.Fatalf("renameAgentUsage failed: %v", err)
+	}
+	totals := agentUsageTotals()
+	if _, ok := totals[wt]; ok || totals["/tmp/repo.worktrees/feat/b"].Tokens != 5200 {
+		t.Fatalf("expected the usage under the new path, got %+v", totals)
+	}
+	forgetAgentUsage("/tmp/repo.worktrees/feat/b")
+	if totals := agentUsageTotals(); len(totals) != 0 {
+		t.Fatalf("expected no usage after forget, got %+v", totals)
+	}
+}
+
+func TestWriteExport(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	repo := filepath.Join(home, "code", "app")
+
+	m := &Manager{Cfg: Config{ExportDir: "exports"}}
+	if got, want := m.ExportDir(repo), filepath.Join(repo, "exports"); got != want {
+		t.Fatalf("relative export_dir should resolve against the repo: got %q want %q", got, want)
+	}
+	m.Cfg.ExportDir = ""
+	if got, want := m.ExportDir(repo), filepath.Join(home, ".local", "share", "sprout", "exports"); got != want {
+		t.Fatalf("default export dir: got %q want %q", got, want)
+	}
+
+	now := time.Date(2026, 10, 16, 15, 4, 5, 0, time.UTC)
+	if got, want := exportFileName("app", "feat/login", "agent", ".log", now), "app-feat-login-agent-20261016-150405.log"; got != want {
+		t.Fatalf("exportFileName: got %q want %q", got, want)
+	}
+
+	wt := &Worktree{Path: filepath.Join(home, "wt"), Branch: "feat/x"}
+	first, err := m.WriteExport(repo, wt, "diff", ".patch", []byte("one"))
+	if err != nil {
+		t.Fatalf("WriteExport failed: %v", err)
+	}
+	second, err := m.WriteExport(repo, wt, "diff", ".patch", []byte("two"))
+	if err != nil {
+		t.Fatalf("WriteExport failed: %v", err)
+	}
+	if first == second {
+		t.Fatalf("a second export should not replace the first: %q", first)
+	}
+	if data, _ := os.ReadFile(first); string(data) != "one" {
+		t.Fatalf("first export was overwritten: %q", data)
+	}
+}
+
+func TestNewOutputLines(t *testing.T) {
+	cases := []struct {
+		name       string
+		prev, next []string
+		want       []string
+	}{
+		{"unchanged", []string{"a", "b"}, []string{"a", "b"}, []string{}},
+		{"appended", []string{"a", "b"}, []string{"a", "b", "c"}, []string{"c"}},
+		{"scrolled", []string{"a", "b", "c", "d"}, []string{"c", "d", "e", "f"}, []string{"e", "f"}},
+		{"redrawn in place", []string{"a", "b", "working 1s"}, []string{"a", "b", "working 2s"}, []string{"working 2s"}},
+		{"cleared", []string{"a", "b"}, []string{"x"}, []string{"x"}},
+	}
+	for _, tc := range cases {
+		got := newOutputLines(tc.prev, tc.next)
+		if strings.Join(got, "|") != strings.Join(tc.want, "|") {
+			t.Fatalf("%s: got %q want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestWriteRepoStatus(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "status.json")
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	a := RepoStatus{Repo: "a", Root: "/src/a", UpdatedAt: now, Worktrees: 2, Agents: AgentCounts{Ready: 1}}
+	b := RepoStatus{Repo: "b", Root: "/src/b", UpdatedAt: now, Worktrees: 1}
+	for _, repo := range []RepoStatus{a, b} {
+		if err := writeRepoStatus(path, repo); err != nil {
+			t.Fatalf("writeRepoStatus failed: %v", err)
+		}
+	}
+	a.Agents.Ready = 2
+	if err := writeRepoStatus(path, a); err != nil {
+		t.Fatalf("writeRepoStatus failed: %v", err)
+	}
+	status, err := ReadWatchStatus(path)
+	if err != nil {
+		t.Fatalf("ReadWatchStatus failed: %v", err)
+	}
+	if len(status.Repos) != 2 || status.Repos["/src/a"].Agents.Ready != 2 || status.Repos["/src/b"].Worktrees != 1 {
+		t.Fatalf("expected both repos with the latest status of a, got %+v", status.Repos)
+	}
+
+	metrics := renderWatchMetrics(status.Repos["/src/a"])
+	for _, want := range []string{
+		`sprout_worktrees{repo="a"} 2`,
+		`sprout_agents{repo="a",state="ready"} 2`,
+		`sprout_status_updated_seconds{repo="a"} 1792152000`,
+	} {
+		if !strings.Contains(metrics, want) {
+			t.Fatalf("metrics missing %q:\n%s", want, metrics)
+		}
+	}
+}
+
+func TestStatusline(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	app := RepoStatus{
+		Repo: "app", Root: "/src/app", UpdatedAt: now, Interval: 30,
+		Worktrees: 3, Dirty: 1, Agents: AgentCounts{Ready: 2},
+		Items: []WatchedWorktree{{Path: "/src/app"}, {Path: "/src/app.worktrees/feat/x"}},
+	}
+	lib := RepoStatus{Repo: "lib", Root: "/src/lib", UpdatedAt: now, Interval: 30, Worktrees: 1, Agents: AgentCounts{Crashed: 1}}
+	status := WatchStatus{Repos: map[string]RepoStatus{app.Root: app, lib.Root: lib}}
+
+	got, ok := statusForDir(status, "/src/app.worktrees/feat/x/cmd")
+	if !ok || got.Repo != "app" {
+		t.Fatalf("expected the app status for its worktree, got %+v", got)
+	}
+	if line := renderStatusline(got, statuslinePlain, now); line != "3 wt · 2 agents ready · 1 dirty" {
+		t.Fatalf("unexpected plain statusline %q", line)
+	}
+	if line := renderStatusline(got, statuslineTmux, now); line != "3 wt · #[fg=green]2 agents ready#[default] · 1 dirty" {
+		t.Fatalf("unexpected tmux statusline %q", line)
+	}
+
+	total, _ := statusForDir(status, "/home")
+	if line := renderStatusline(total, statuslinePlain, now); line != "4 wt · 2 agents ready · 1 crashed · 1 dirty" {
+		t.Fatalf("outside a repo the statusline should add up all repos, got %q", line)
+	}
+
+	later := now.Add(5 * time.Minute)
+	if line := renderStatusline(got, statuslinePlain, later); !strings.HasSuffix(line, " · stale") {
+		t.Fatalf("expected a stale marker, got %q", line)
+	}
+	if line := renderStatusline(got, statuslineStarship, later); line != "" {
+		t.Fatalf("starship should hide a stale status, got %q", line)
+	}
+}
+
+func TestSortWorktreesByLastActive(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	items := []Worktree{
+		{Path: "/w/a", Current: true, LastActive: now.Add(-48 * time.Hour)},
+		{Path: "/w/b", LastActive: now.Add(-time.Hour)},
+		{Path: "/w/c"},
+		{Path: "/w/d", LastActive: now.Add(-90 * 24 * time.Hour)},
+	}
+	order := func() string {
+		paths := []string{}
+		for _, it := range items {
+			paths = append(paths, filepath.Base(it.Path))
+		}
+		return strings.Join(paths, "")
+	}
+	sortWorktrees(items, sortActive)
+	if got := order(); got != "badc" {
+		t.Fatalf("active: got %s, want badc", got)
+	}
+	sortWorktrees(items, sortIdle)
+	if got := order(); got != "dabc" {
+		t.Fatalf("idle: got %s, want dabc", got)
+	}
+	sortWorktrees(items, sortPath)
+	if got := order(); got != "abcd" {
+		t.Fatalf("path: got %s, want abcd", got)
+	}
+	if _, err := parseWorktreeSort("newest"); err == nil {
+		t.Fatalf("expected an invalid sort to fail")
+	}
+}
+
+func TestWorktreeLastActive(t *testing.T) {
+	repo, run := newTestRepo(t)
+	wtPath := filepath.Join(filepath.Dir(repo), "wt")
+	run(repo, "worktree", "add", "-q", "-b", "feat/x", wtPath)
+
+	m := &Manager{}
+	gitDir := m.worktreeGitDir(wtPath)
+	if _, err := os.Stat(filepath.Join(gitDir, "index")); err != nil {
+		t.Fatalf("expected the linked worktree's index under %q: %v", gitDir, err)
+	}
+	old := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(gitDir, "index"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	head := m.branchTips(repo)["feat/x"].Time
+	if head.IsZero() {
+		t.Fatalf("expected a commit time for feat/x")
+	}
+	if got := m.worktreeLastActive(head, wtPath, 0); !got.Equal(head) {
+		t.Fatalf("expected the HEAD commit time %v, got %v", head, got)
+	}
+	agent := head.Add(time.Hour)
+	if got := m.worktreeLastActive(head, wtPath, agent.Unix()); !got.Equal(agent) {
+		t.Fatalf("expected the agent activity %v, got %v", agent, got)
+	}
+}
+
+func TestResumePrompt(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	wt := &Worktree{Path: "/tmp/repo.worktrees/feat/x", Branch: "feat/x"}
+	m := &Manager{}
+	if got := m.resumePrompt(wt); got != "" {
+		t.Fatalf("expected no prompt when agent_resume_prompt is unset, got %q", got)
+	}
+
+	m.Cfg.AgentResumePrompt = "resume {branch}: {last_prompt}"
+	if err := recordPrompt(wt.Path, "add tests"); err != nil {
+		t.Fatalf("recordPrompt failed: %v", err)
+	}
+	if got := m.resumePrompt(wt); got != "resume feat/x: add tests" {
+		t.Fatalf("unexpected resume prompt: %q", got)
+	}
+}
+
+func TestEventLog(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	now := time.Now().UTC()
+	for _, ev := range []Event{
+		{Type: eventWorktreeCreated, Time: now.Add(-2 * time.Hour), RepoRoot: "/src/app", Branch: "feat/old"},
+		{Type: eventAgentStarted, Time: now, RepoRoot: "/src/app", Branch: "feat/new", AgentType: "claude"},
+		{Type: eventAgentStarted, Time: now, RepoRoot: "/src/other", Branch: "feat/x"},
+	} {
+		if err := appendEvent(ev); err != nil {
+			t.Fatalf("appendEvent failed: %v", err)
+		}
+	}
+
+	events, err := ReadEvents(EventFilter{RepoRoot: "/src/app", Types: []string{eventAgentStarted}})
+	if err != nil {
+		t.Fatalf("ReadEvents failed: %v", err)
+	}
+	if len(events) != 1 || events[0].Branch != "feat/new" || events[0].AgentType != "claude" {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+	events, err = ReadEvents(EventFilter{Since: now.Add(-time.Hour)})
+	if err != nil || len(events) != 2 {
+		t.Fatalf("expected the two recent events, got %+v (err %v)", events, err)
+	}
+
+	partial := `{"type":"agent_ready","repo_root":"/src/app"}` + "\n" + `{"type":"agent_st`
+	got, consumed, err := readEventsFrom(strings.NewReader(partial), EventFilter{})
+	if err != nil || len(got) != 1 || got[0].Type != eventAgentReady {
+		t.Fatalf("unexpected events from partial log: %+v (err %v)", got, err)
+	}
+	if want := int64(strings.Index(partial, "\n") + 1); consumed != want {
+		t.Fatalf("expected %d bytes consumed, got %d", want, consumed)
+	}
+
+	if _, err := parseEventTypes([]string{"agent_ready,bogus"}); err == nil {
+		t.Fatal("expected an error for an unknown event type")
+	}
+	if since, err := parseSince("90m", now); err != nil || !since.Equal(now.Add(-90*time.Minute)) {
+		t.Fatalf("unexpected since: %v (err %v)", since, err)
+	}
+
+	// Fill the log to its cap; the next event rotates it.
+	path, err := eventsPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, _ := f.Stat()
+	if _, err := f.WriteString(strings.Repeat(" ", eventsMaxBytes-int(info.Size())-1) + "\n"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if err := appendEvent(Event{Type: eventAgentStopped, Time: now, RepoRoot: "/src/app"}); err != nil {
+		t.Fatalf("appendEvent failed: %v", err)
+	}
+	if info, err := os.Stat(path + ".1"); err != nil || info.Size() != eventsMaxBytes {
+		t.Fatalf("expected the full log to be rotated, got %v", err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Size() >= 1<<10 {
+		t.Fatalf("expected a fresh log after rotation, got %v", err)
+	}
+	events, err = ReadEvents(EventFilter{})
+	if err != nil || len(events) != 4 || events[3].Type != eventAgentStopped {
+		t.Fatalf("expected events from both logs, got %+v (err %v)", events, err)
+	}
+}
+
+func TestComputeStats(t *testing.T) {
+	day := time.Date(2026, 3, 2, 9, 0, 0, 0, time.Local) // a Monday
+	events := []Event{
+		{Type: eventWorktreeCreated, Time: day, Path: "/w/a"},
+		{Type: eventAgentStarted, Time: day.Add(time.Hour), Path: "/w/a"},
+		{Type: eventAgentStopped, Time: day.Add(3 * time.Hour), Path: "/w/a"},
+		{Type: eventWorktreeRemoved, Time: day.Add(48 * time.Hour), Path: "/w/a", Outcome: branchOutcomeMerged},
+		{Type: eventWorktreeCreated, Time: day.AddDate(0, 0, 14), Path: "/w/b"},
+		{Type: eventAgentStarted, Time: day.AddDate(0, 0, 14), Path: "/w/b"},
+		{Type: eventAgentStarted, Time: day.AddDate(0, 0, 15), Path: "/w/c"},
+	}
+	now := day.AddDate(0, 0, 14).Add(2 * time.Hour)
+	running := func(ev Event) bool { return ev.Path == "/w/b" }
+
+	stats := computeStats(events, time.Time{}, now, running)
+	if stats.Created != 2 || stats.Removed != 1 || stats.Merged != 1 || stats.Abandoned != 0 {
+		t.Fatalf("unexpected counts: %+v", stats)
+	}
+	if stats.LifetimesMeasured != 1 || stats.AverageLifetimeHours != 48 {
+		t.Fatalf("unexpected lifetime: %+v", stats)
+	}
+	if stats.AgentRuns != 2 || stats.AgentHours != 4 {
+		t.Fatalf("expected 2h stopped plus 2h still running, got %d runs, %.2fh", stats.AgentRuns, stats.AgentHours)
+	}
+	if len(stats.Weeks) != 3 || stats.Weeks[0].Created != 1 || stats.Weeks[0].Removed != 1 ||
+		!stats.Weeks[1].Start.Equal(weekStart(day.AddDate(0, 0, 7))) || stats.Weeks[1].Created != 0 {
+		t.Fatalf("unexpected weeks: %+v", stats.Weeks)
+	}
+
+	since := computeStats(events, day.Add(2*time.Hour), now, running)
+	if since.Created != 1 || since.Removed != 1 || since.AgentHours != 3 {
+		t.Fatalf("unexpected stats since: %+v", since)
+	}
+}
+
+func TestBranchOutcome(t *testing.T) {
+	repo, run := newTestRepo(t)
+	commit := func(file string) {
+		if err := os.WriteFile(filepath.Join(repo, file), []byte(file+"\n"), 0o644); err != nil {
+			t.Fatalf("write file failed: %v", err)
+		}
+		run(repo, "add", file)
+		run(repo, "commit", "-m", file)
+	}
+	commit("README.md")
+
+	run(repo, "branch", "untouched")
+	run(repo, "checkout", "-b", "squashed")
+	commit("a.txt")
+	commit("b.txt")
+	run(repo, "checkout", "-b", "open", "main")
+	commit("c.txt")
+	run(repo, "checkout", "-b", "merged", "main")
+	commit("d.txt")
+	run(repo, "checkout", "main")
+	run(repo, "merge", "--squash", "squashed")
+	run(repo, "commit", "-m", "squash")
+	run(repo, "merge", "--no-edit", "merged")
+
+	m := &Manager{Cfg: DefaultConfig()}
+	for branch, want := range map[string]string{
+		"untouched": branchOutcomeAbandoned,
+		"squashed":  branchOutcomeMerged,
+		"open":      branchOutcomeAbandoned,
+		"merged":    branchOutcomeMerged,
+		"main":      "",
+	} {
+		if got := m.branchOutcome(repo, branch); got != want {
+			t.Errorf("branchOutcome(%s) = %q, want %q", branch, got, want)
+		}
+	}
+}
+
+func TestServeMCP(t *testing.T) {
+	in := strings.Join([]string{
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26","capabilities":{},"clientInfo":{"name":"test","version":"1"}}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"send_agent_prompt","arguments":{"target":"x","prompt":"  "}}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"get_diff","arguments":{"bogus":true}}}`,
+		`{"jsonrpc":"2.0","id":5,"method":"resources/list"}`,
+		`not json`,
+	}, "\n") + "\n"
+	var out bytes.Buffer
+	if err := (&Manager{}).ServeMCP(strings.NewReader(in), &out); err != nil {
+		t.Fatalf("ServeMCP failed: %v", err)
+	}
+
+	var responses []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var resp map[string]any
+		if err := json.Unmarshal([]byte(line), &resp); err != nil {
+			t.Fatalf("bad response line %q: %v", line, err)
+		}
+		responses = append(responses, resp)
+	}
+	if len(responses) != 6 {
+		t.Fatalf("expected 6 responses (none for the notification), got %d: %s", len(responses), out.String())
+	}
+	result := func(i int) map[string]any {
+		r, _ := responses[i]["result"].(map[string]any)
+		return r
+	}
+	if v := result(0)["protocolVersion"]; v != "2025-03-26" {
+		t.Fatalf("expected the client's protocol version, got %v", v)
+	}
+	tools, _ := result(1)["tools"].([]any)
+	names := []string{}
+	for _, tool := range tools {
+		names = append(names, tool.(map[string]any)["name"].(string))
+	}
+	for _, want := range []string{"list_worktrees", "create_worktree", "send_agent_prompt", "get_agent_output", "get_diff"} {
+		if !containsString(names, want) {
+			t.Fatalf("tools/list is missing %s: %v", want, names)
+		}
+	}
+	for _, i := range []int{2, 3} {
+		if result(i)["isError"] != true {
+			t.Fatalf("expected a tool error for response %d, got %v", i, responses[i])
+		}
+	}
+	for i, code := range map[int]float64{4: rpcMethodNotFound, 5: rpcParseError} {
+		rpcErr, _ := responses[i]["error"].(map[string]any)
+		if rpcErr["code"] != code {
+			t.Fatalf("expected error code %v for response %d, got %v", code, i, responses[i])
+		}
+	}
+}
+
+func TestAPIServer(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	newTestRepo(t)
+
+	srv, err := NewManager(DefaultConfig()).NewAPIServer(ServeOptions{Addr: "127.0.0.1:0"})
+	if err != nil {
+		t.Fatalf("NewAPIServer failed: %v", err)
+	}
+	t.Cleanup(func() { _ = srv.Close() })
+	go func() { _ = srv.Serve() }()
+	data, err := os.ReadFile(srv.TokenPath)
+	if err != nil {
+		t.Fatalf("expected a generated token file: %v", err)
+	}
+	token := strings.TrimSpace(string(data))
+
+	do := func(method, path, token, body string) (int, map[string]any) {
+		t.Helper()
+		req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("new request failed: %v", err)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		var decoded map[string]any
+		if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+			t.Fatalf("%s %s returned bad JSON: %v", method, path, err)
+		}
+		return resp.StatusCode, decoded
+	}
+
+	if status, _ := do("GET", "/api/worktrees", "", ""); status != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without a token, got %d", status)
+	}
+	if status, _ := do("GET", "/api/worktrees", "wrong", ""); status != http.StatusUnauthorized {
+		t.Fatalf("expected 401 with a wrong token, got %d", status)
+	}
+	status, body := do("GET", "/api/worktrees", token, "")
+	if status != http.StatusOK {
+		t.Fatalf("expected 200 listing worktrees, got %d: %v", status, body)
+	}
+	if list, _ := body["result"].([]any); len(list) != 1 {
+		t.Fatalf("expected the main worktree only, got %v", body["result"])
+	}
+	if status, body := do("POST", "/api/worktrees", token, `{"branch":"feat/api"}`); status != http.StatusOK {
+		t.Fatalf("expected 200 creating a worktree, got %d: %v", status, body)
+	}
+	if status, body := do("POST", "/api/worktrees", token, `{"bogus":1}`); status != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown arguments, got %d: %v", status, body)
+	}
+	if status, body := do("GET", "/api/agent/output?target=missing&lines=5", token, ""); status != http.StatusNotFound {
+		t.Fatalf("expected 404 for a missing worktree, got %d: %v", status, body)
+	}
+	if status, body := do("DELETE", "/api/worktrees?target=feat/api&force=true", token, ""); status != http.StatusOK {
+		t.Fatalf("expected 200 removing the worktree, got %d: %v", status, body)
+	}
+	if status, _ := do("GET", "/nope", token, ""); status != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown endpoint, got %d", status)
+	}
+}
+
+func TestMoveRenamesBranchAndWorktree(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	repo, run := newTestRepo(t)
+	if err := os.WriteFile(filepath.Join(repo, "README.md"), []byte("hello\n"), 0o644); err != nil {
+		t.Fatalf("write file failed: %v", err)
+	}
+	run(repo, "add", "README.md")
+	run(repo, "commit", "-m", "add README.md")
+
+	cfg := DefaultConfig()
+	m := NewManager(cfg)
+	_, oldPath, err := m.NewWorktree(context.Background(), NewOptions{Branch: "feat/old-name", SkipCopyUntracked: true})
+	if err != nil {
+		t.Fatalf("NewWorktree failed: %v", err)
+	}
+
+	newPath, _, err := m.Move(MoveOptions{Target: "feat/old-name", NewBranch: "feat/new-name"})
+	if err != nil {
+		t.Fatalf("Move failed: %v", err)
+	}
+	if _, err := os.Stat(oldPath); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected old path to be gone, stat err=%v", err)
+	}
+	if filepath.Base(newPath) != "new-name" {
+		t.Fatalf("unexpected new path: %q", newPath)
+	}
+	if got := run(newPath, "symbolic-ref", "--short", "HEAD"); got != "feat/new-name" {
+		t.Fatalf("unexpected branch in moved worktree: %q", got)
+	}
+	if _, err := m.FindWorktree("feat/new-name"); err != nil {
+		t.Fatalf("moved worktree not listed: %v", err)
+	}
+	if _, _, err := m.Move(MoveOptions{Target: repo, NewBranch: "feat/main-move"}); err == nil {
+		t.Fatalf("expected error moving the main worktree")
+	}
+}
+
+func TestWorktreeStatusLabel(t *testing.T) {
+	tests := []struct {
+		wt   Worktree
+		want string
+	}{
+		{Worktree{}, "clean"},
+		{Worktree{Dirty: true}, "dirty"},
+		{Worktree{Dirty: true, RebaseState: rebaseStateRunning}, "rebasing"},
+		{Worktree{RebaseState: rebaseStateAborted}, "rebase aborted"},
+	}
+	for _, tt := range tests {
+		if got := worktreeStatusLabel(tt.wt); got != tt.want {
+			t.Fatalf("worktreeStatusLabel(%+v)=%q want %q", tt.wt, got, tt.want)
+		}
+	}
+}
+
+func TestRemoveRefusesLockedWorktree(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	repo, run := newTestRepo(t)
+	if err := os.WriteFile(filepath.Join(repo, "README.md"), []byte("hello\n"), 0o644); err != nil {
+		t.Fatalf("write file failed: %v", err)
+	}
+	run(repo, "add", "README.md")
+	run(repo, "commit", "-m", "add README.md")
+
+	m := NewManager(DefaultConfig())
+	if _, _, err := m.NewWorktree(context.Background(), NewOptions{Branch: "feat/keep", SkipCopyUntracked: true}); err != nil {
+		t.Fatalf("NewWorktree failed: %v", err)
+	}
+	if _, err := m.Lock("feat/keep", "in review"); err != nil {
+		t.Fatalf("Lock failed: %v", err)
+	}
+	wt, err := m.FindWorktree("feat/keep")
+	if err != nil {
+		t.Fatalf("FindWorktree failed: %v", err)
+	}
+	if !wt.Locked || wt.LockReason != "in review" {
+		t.Fatalf("expected locked worktree with reason, got %+v", wt)
+	}
+	if _, _, err := m.Remove(context.Background(), RemoveOptions{Target: "feat/keep"}); err == nil || !strings.Contains(err.Error(), "locked") {
+		t.Fatalf("expected locked error, got %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	stop := func(DeleteProgress) { cancel() }
+	if _, _, err := m.Remove(ctx, RemoveOptions{Target: "feat/keep", Force: true, OnDeleteProgress: stop}); err == nil {
+		t.Fatal("expected the stopped removal to fail")
+	}
+	if wt, err := m.FindWorktree("feat/keep"); err != nil || !wt.Locked || wt.LockReason != "in review" {
+		t.Fatalf("expected a failed removal to keep the lock, got %+v, %v", wt, err)
+	}
+	path, _, err := m.Remove(context.Background(), RemoveOptions{Target: "feat/keep", Force: true})
+	if err != nil {
+		t.Fatalf("forced Remove failed: %v", err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected worktree to be removed, stat err=%v", err)
+	}
+}
+
+func TestGitBackendsAgree(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	repo, run := newTestRepo(t)
+	write := func(name, content string) {
+		path := filepath.Join(repo, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir failed: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s failed: %v", name, err)
+		}
+	}
+	write(".gitignore", "*.log\n")
+	write("keep.txt", "keep\n")
+	write("edit.txt", "one\n")
+	write("gone.txt", "gone\n")
+	run(repo, "add", ".")
+	run(repo, "commit", "-m", "init")
+	run(repo, "branch", "feat/one")
+	run(repo, "update-ref", "refs/remotes/origin/main", "HEAD")
+	run(repo, "update-ref", "refs/remotes/origin/feat/remote", "HEAD")
+
+	write("edit.txt", "two\n")
+	run(repo, "add", "edit.txt")
+	write("edit.txt", "three\n")
+	write("staged.txt", "new\n")
+	run(repo, "add", "staged.txt")
+	if err := os.Remove(filepath.Join(repo, "gone.txt")); err != nil {
+		t.Fatalf("remove failed: %v", err)
+	}
+	write("dir/untracked.txt", "?\n")
+	write("debug.log", "ignored\n")
+
+	linked := filepath.Join(filepath.Dir(repo), "linked")
+	run(repo, "worktree", "add", linked, "feat/one")
+	if err := os.WriteFile(filepath.Join(linked, "keep.txt"), []byte("changed\n"), 0o644); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+
+	execMgr := NewManager(DefaultConfig())
+	cfg := DefaultConfig()
+	cfg.GitBackend = gitBackendGoGit
+	goGitMgr := NewManager(cfg)
+	for _, dir := range []string{repo, linked} {
+		want, err := execMgr.WorktreeDiffFiles(dir)
+		if err != nil {
+			t.Fatalf("exec status failed: %v", err)
+		}
+		got, err := goGitStatus(dir)
+		if err != nil {
+			t.Fatalf("gogit status failed: %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("status of %s differs:\ngogit: %+v\nexec:  %+v", dir, got, want)
+		}
+		if !goGitMgr.WorktreeDirty(context.Background(), dir) {
+			t.Fatalf("expected %s to be dirty with gogit", dir)
+		}
+	}
+	for _, remote := range []bool{false, true} {
+		want := execMgr.branchRefs(repo, remote)
+		got, err := goGitBranchRefs(repo, remote)
+		if err != nil {
+			t.Fatalf("gogit branches failed: %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("branches (remote=%t) differ: gogit %v, exec %v", remote, got, want)
+		}
+	}
+	if _, err := parseGitBackend("libgit2"); err == nil {
+		t.Fatalf("expected an unknown git_backend to be rejected")
+	}
+}
+
+func TestRunCmdContextStopsCommand(t *testing.T) {
+	if _, err := exec.LookPath("sleep"); err != nil {
+		t.Skip("sleep is required for this test")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	_, err := (&Manager{}).runCmdBytesContext(ctx, "", 0, "sleep", "5")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected a canceled error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Fatalf("command was not stopped, ran for %s", elapsed)
+	}
+}
+
+func TestSSHRemoteRunsGitOnHost(t *testing.T) {
+	// A fake ssh that runs the remote script locally and records the host.
+	bin := t.TempDir()
+	hostLog := filepath.Join(bin, "hosts")
+	script := "#!/bin/sh\nfor last; do :; done\nfor arg; do case \"$arg\" in -*|*=*) ;; *) echo \"$arg\" >>" + hostLog + "; break;; esac; done\nexec sh -c \"$last\"\n"
+	if err := os.WriteFile(filepath.Join(bin, "ssh"), []byte(script), 0o755); err != nil {
+		t.Fatalf("write fake ssh: %v", err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	// The quote in the path checks that remote commands quote it.
+	created, _ := newTestRepo(t)
+	repo := filepath.Join(filepath.Dir(created), "repo's")
+	if err := os.Rename(created, repo); err != nil {
+		t.Fatalf("rename repo failed: %v", err)
+	}
+	if err := os.Chdir(repo); err != nil {
+		t.Fatalf("chdir failed: %v", err)
+	}
+
+	cfg := DefaultConfig()
+	cfg.SSHHost = "dev-box"
+	cfg.SSHRepo = repo
+	m := NewManager(cfg)
+
+	root, err := m.RequireRepo()
+	if err != nil {
+		t.Fatalf("require repo: %v", err)
+	}
+	if root != repo {
+		t.Fatalf("expected remote repo %q, got %q", repo, root)
+	}
+	if exists, err := m.pathExists(filepath.Join(repo, ".git")); err != nil || !exists {
+		t.Fatalf("expected .git to exist on the host: exists=%t err=%v", exists, err)
+	}
+	if exists, err := m.pathExists(filepath.Join(repo, "missing")); err != nil || exists {
+		t.Fatalf("expected missing path: exists=%t err=%v", exists, err)
+	}
+	notes := filepath.Join(repo, "notes.txt")
+	if err := os.WriteFile(notes, []byte("on the host\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := m.readFile(notes); err != nil || string(data) != "on the host\n" {
+		t.Fatalf("readFile = %q, %v", data, err)
+	}
+	if info, err := m.statPath(notes); err != nil || info.IsDir() || info.Size() != 12 || info.ModTime().IsZero() {
+		t.Fatalf("statPath = %+v, %v", info, err)
+	}
+	if info, err := m.statPath(repo); err != nil || !info.IsDir() {
+		t.Fatalf("expected the repo to be a directory: %+v, %v", info, err)
+	}
+	if _, err := m.statPath(filepath.Join(repo, "missing")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected a missing file to stat as not existing, got %v", err)
+	}
+	if gitDir := m.worktreeGitDir(repo); gitDir != filepath.Join(repo, ".git") {
+		t.Fatalf("worktreeGitDir = %q", gitDir)
+	}
+	if !m.anyPathExists(filepath.Join(repo, "missing"), notes) || m.anyPathExists(filepath.Join(repo, "missing")) {
+		t.Fatalf("anyPathExists does not match the host")
+	}
+	if err := os.Chmod(notes, 0o640); err != nil {
+		t.Fatal(err)
+	}
+	preview, err := m.WorktreeFilePreview(repo, DiffFile{Path: "notes.txt", Status: "??"})
+	if err != nil {
+		t.Fatalf("preview on the host failed: %v", err)
+	}
+	for _, want := range []string{"12 bytes", "-rw-r-----", "1 on the host"} {
+		if !strings.Contains(stripANSI(preview), want) {
+			t.Fatalf("expected the preview to contain %q, got:\n%s", want, stripANSI(preview))
+		}
+	}
+	if err := m.removePath(notes); err != nil {
+		t.Fatalf("removePath failed: %v", err)
+	}
+	if err := m.removePath(notes); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected removing a missing file to fail as not existing, got %v", err)
+	}
+	hosts, err := os.ReadFile(hostLog)
+	if err != nil {
+		t.Fatalf("read host log: %v", err)
+	}
+	if len(strings.Fields(string(hosts))) == 0 {
+		t.Fatalf("expected git to run over ssh")
+	}
+	for _, host := range strings.Fields(string(hosts)) {
+		if host != "dev-box" {
+			t.Fatalf("expected commands on dev-box, got %q", host)
+		}
+	}
+
+	if _, name, _ := m.remoteCommand("", "lazygit", nil, false); name != "lazygit" {
+		t.Fatalf("expected local commands to stay local, got %q", name)
+	}
+	if _, name, _ := NewManager(DefaultConfig()).remoteCommand("", "git", nil, false); name != "git" {
+		t.Fatalf("expected another manager to stay local, got %q", name)
+	}
+}
+
+func TestContainerize(t *testing.T) {
+	m := &Manager{Cfg: DefaultConfig()}
+	if got := m.containerize("s", "/w", "claude"); got != "claude" {
+		t.Fatalf("expected commands unchanged without container_command, got %q", got)
+	}
+
+	m.Cfg.ContainerCommand = "devcontainer exec --workspace-folder {worktree}"
+	want := `devcontainer exec --workspace-folder '/src/it'\''s' sh -c 'claude --resume'`
+	if got := m.containerize("s", "/src/it's", "claude --resume"); got != want {
+		t.Fatalf("containerize = %q, want %q", got, want)
+	}
+	if got := m.containerize("s", "/w", ""); !strings.Contains(got, "exec bash") {
+		t.Fatalf("expected an empty command to start a shell in the container, got %q", got)
+	}
+	if got := m.containerUpCommand(); got != "devcontainer up --workspace-folder {worktree}" {
+		t.Fatalf("expected devcontainer up by default, got %q", got)
+	}
+
+	m.Cfg.ContainerCommand = "docker exec -it {name}"
+	if got := m.containerUpCommand(); got != "" {
+		t.Fatalf("expected no default container_up for docker exec, got %q", got)
+	}
+	if got := expandContainerTemplate("docker start {name}", "sprout-app/feat login", "/w"); got != "docker start sprout-app-feat-login" {
+		t.Fatalf("unexpected container name: %q", got)
+	}
+}
+
+func TestRemoveCurrentWorktree(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	repo, _ := newTestRepo(t)
+
+	m := NewManager(DefaultConfig())
+	_, path, err := m.NewWorktree(context.Background(), NewOptions{Branch: "feat/here", SkipCopyUntracked: true})
+	if err != nil {
+		t.Fatalf("NewWorktree failed: %v", err)
+	}
+	if err := os.Chdir(path); err != nil {
+		t.Fatalf("chdir failed: %v", err)
+	}
+
+	if _, _, err := m.Remove(context.Background(), RemoveOptions{Target: "feat/here"}); !errors.Is(err, errRemoveCurrentWorktree) {
+		t.Fatalf("expected current worktree error, got %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("expected worktree to be kept, stat err=%v", err)
+	}
+
+	switched := ""
+	if _, _, err := m.Remove(context.Background(), RemoveOptions{
+		Target:       "feat/here",
+		SwitchToMain: true,
+		OnSwitch:     func(p string) { switched = p },
+	}); err != nil {
+		t.Fatalf("Remove with SwitchToMain failed: %v", err)
+	}
+	if switched != repo {
+		t.Fatalf("expected switch to %q, got %q", repo, switched)
+	}
+	if cwd, _ := os.Getwd(); cwd != repo {
+		t.Fatalf("expected working directory %q, got %q", repo, cwd)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected worktree to be removed, stat err=%v", err)
+	}
+
+	if _, _, err := m.Remove(context.Background(), RemoveOptions{Target: repo, SwitchToMain: true}); err == nil || !strings.Contains(err.Error(), "main worktree") {
+		t.Fatalf("expected main worktree error, got %v", err)
+	}
+}
+
+func TestLockRepo(t *testing.T) {
+	repo, _ := newTestRepo(t)
+	path, err := (&Manager{}).repoLockPath(repo)
+	if err != nil {
+		t.Fatalf("repoLockPath failed: %v", err)
+	}
+
+	// A second open file description stands in for another process.
+	other, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		t.Fatalf("open lock file failed: %v", err)
+	}
+	defer other.Close()
+	if ok, err := tryLockFile(other); !ok || err != nil {
+		t.Fatalf("tryLockFile failed: ok=%t err=%v", ok, err)
+	}
+	if _, err := other.WriteString("pid 1: new feat/x\n"); err != nil {
+		t.Fatalf("write lock file failed: %v", err)
+	}
+
+	m := NewManager(DefaultConfig())
+	if _, err := m.lockRepo(context.Background(), repo, "rm feat/y"); !errors.Is(err, errRepoBusy) || !strings.Contains(err.Error(), "pid 1: new feat/x") {
+		t.Fatalf("expected busy error naming the holder, got %v", err)
+	}
+	m.WaitForLock = true
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	if _, err := m.lockRepo(ctx, repo, "rm feat/y"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the wait to end with ctx, got %v", err)
+	}
+
+	if err := unlockFile(other); err != nil {
+		t.Fatalf("unlockFile failed: %v", err)
+	}
+	unlock, err := m.lockRepo(context.Background(), repo, "rm feat/y")
+	if err != nil {
+		t.Fatalf("lockRepo failed: %v", err)
+	}
+	nested, err := m.lockRepo(context.Background(), repo, "launch feat/y")
+	if err != nil {
+		t.Fatalf("nested lockRepo failed: %v", err)
+	}
+	nested()
+	nested()
+	if ok, _ := tryLockFile(other); ok {
+		t.Fatalf("expected the lock to stay held until the outer unlock")
+	}
+	unlock()
+	if ok, err := tryLockFile(other); !ok || err != nil {
+		t.Fatalf("expected the lock to be free after unlock, got ok=%t err=%v", ok, err)
+	}
+}
+
+func TestCanceledCreateAndRemove(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	repo, run := newTestRepo(t)
+	if err := os.WriteFile(filepath.Join(repo, "notes.txt"), []byte("untracked\n"), 0o644); err != nil {
+		t.Fatalf("write file failed: %v", err)
+	}
+
+	m := NewManager(DefaultConfig())
+
+	// Cancel once the worktree exists and untracked files are being copied.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, _, err := m.NewWorktree(ctx, NewOptions{Branch: "feat/canceled", OnCopyProgress: func(CopyProgress) { cancel() }})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected a canceled create, got %v", err)
+	}
+	if m.BranchExists(repo, "feat/canceled") {
+		t.Fatalf("expected the new branch to be rolled back")
+	}
+	if list := run(repo, "worktree", "list", "--porcelain"); strings.Contains(list, "canceled") {
+		t.Fatalf("expected the worktree to be rolled back, got:\n%s", list)
+	}
+
+	_, path, err := m.NewWorktree(context.Background(), NewOptions{Branch: "feat/kept", SkipCopyUntracked: true})
+	if err != nil {
+		t.Fatalf("NewWorktree failed: %v", err)
+	}
+	canceled, cancelRemove := context.WithCancel(context.Background())
+	cancelRemove()
+	if _, _, err := m.Remove(canceled, RemoveOptions{Target: "feat/kept"}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected a canceled remove, got %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("expected the worktree to be left alone: %v", err)
+	}
+}
+
+func TestDoctorFixRepairsWorktrees(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("SPROUT_CONFIG", "")
+
+	repo, run := newTestRepo(t)
+	if err := os.WriteFile(filepath.Join(repo, "README.md"), []byte("hello\n"), 0o644); err != nil {
+		t.Fatalf("write file failed: %v", err)
+	}
+	run(repo, "add", "README.md")
+	run(repo, "commit", "-m", "add README.md")
+
+	m := NewManager(DefaultConfig())
+	_, stale, err := m.NewWorktree(context.Background(), NewOptions{Branch: "feat/stale", SkipCopyUntracked: true})
+	if err != nil {
+		t.Fatalf("NewWorktree stale failed: %v", err)
+	}
+	_, broken, err := m.NewWorktree(context.Background(), NewOptions{Branch: "feat/broken", SkipCopyUntracked: true})
+	if err != nil {
+		t.Fatalf("NewWorktree broken failed: %v", err)
+	}
+	if err := os.RemoveAll(stale); err != nil {
+		t.Fatalf("remove stale worktree dir failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(broken, ".git"), []byte("gitdir: "+filepath.Join(repo, ".git", "worktrees", "missing")+"\n"), 0o644); err != nil {
+		t.Fatalf("corrupt .git failed: %v", err)
+	}
+
+	countFixes := func(report DoctorReport, fix string) int {
+		n := 0
+		for _, item := range report.Items {
+			if item.Check == "worktree" && item.Fix == fix {
+				n++
+			}
+		}
+		return n
+	}
+	report := m.Doctor(DoctorOptions{})
+	if got := countFixes(report, doctorFixAvailable); got != 2 {
+		t.Fatalf("expected 2 fixable worktree items, got %d: %v", got, report.Lines)
+	}
+	report = m.Doctor(DoctorOptions{Fix: true})
+	if got := countFixes(report, doctorFixed); got != 2 {
+		t.Fatalf("expected 2 fixed worktree items, got %d: %v", got, report.Lines)
+	}
+	if err := m.checkWorktreeGitdir(broken); err != nil {
+		t.Fatalf("expected gitdir to be repaired: %v", err)
+	}
+	report = m.Doctor(DoctorOptions{})
+	if countFixes(report, doctorFixAvailable) != 0 {
+		t.Fatalf("expected no remaining problems, got %v", report.Lines)
+	}
+}
+
+func TestWorktreePathTemplate(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("SPROUT_CONFIG", "")
+
+	repo, _ := newTestRepo(t)
+
+	cfg := DefaultConfig()
+	cfg.WorktreePathTemplate = "{slug}"
+	m := NewManager(cfg)
+	root := m.WorktreeRootDir(repo)
+	if got := m.worktreePathName("main", time.Now()); got != "main" {
+		t.Fatalf("expected a branch without a type to be its slug, got %q", got)
+	}
+	_, first, err := m.NewWorktree(context.Background(), NewOptions{Branch: "feat/login", SkipCopyUntracked: true})
+	if err != nil {
+		t.Fatalf("NewWorktree feat/login failed: %v", err)
+	}
+	_, second, err := m.NewWorktree(context.Background(), NewOptions{Branch: "fix/login", SkipCopyUntracked: true})
+	if err != nil {
+		t.Fatalf("NewWorktree fix/login failed: %v", err)
+	}
+	if first != filepath.Join(root, "login") || second != filepath.Join(root, "login-2") {
+		t.Fatalf("expected login and login-2, got %s and %s", first, second)
+	}
+
+	m.Cfg.WorktreePathTemplate = "{type}-{slug}"
+	report := m.Doctor(DoctorOptions{})
+	layout := 0
+	for _, item := range report.Items {
+		if item.Check == "layout" && item.Fix == doctorFixAvailable {
+			layout++
+		}
+	}
+	if layout != 2 {
+		t.Fatalf("expected 2 worktrees to migrate, got %d: %v", layout, report.Lines)
+	}
+	m.Doctor(DoctorOptions{Fix: true})
+	for _, path := range []string{filepath.Join(root, "feat-login"), filepath.Join(root, "fix-login")} {
+		if err := m.checkWorktreeGitdir(path); err != nil {
+			t.Fatalf("expected a working worktree at %s: %v", path, err)
+		}
+	}
+	if _, err := os.Stat(first); !os.IsNotExist(err) {
+		t.Fatalf("expected %s to be moved away, got %v", first, err)
+	}
+	report = m.Doctor(DoctorOptions{})
+	for _, item := range report.Items {
+		if item.Check == "layout" {
+			t.Fatalf("expected no layout problems after the fix, got %v", report.Lines)
+		}
+	}
+
+	if _, err := parseWorktreePathTemplate("../{branch}"); err == nil {
+		t.Fatalf("expected a template leaving the root to be rejected")
+	}
+	if _, err := parseWorktreePathTemplate("{type}"); err == nil {
+		t.Fatalf("expected a template without {branch} or {slug} to be rejected")
+	}
+}
+
+func TestFlattenBranchDirs(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("SPROUT_CONFIG", "")
+
+	repo, _ := newTestRepo(t)
+
+	m := NewManager(DefaultConfig())
+	_, nested, err := m.NewWorktree(context.Background(), NewOptions{Branch: "feat/old", SkipCopyUntracked: true})
+	if err != nil {
+		t.Fatalf("NewWorktree feat/old failed: %v", err)
+	}
+
+	m.Cfg.FlattenBranchDirs = true
+	branch, path, err := m.NewWorktree(context.Background(), NewOptions{Branch: "feat/x/y", SkipCopyUntracked: true})
+	if err != nil {
+		t.Fatalf("NewWorktree feat/x/y failed: %v", err)
+	}
+	if branch != "feat/x/y" || path != filepath.Join(m.WorktreeRootDir(repo), "feat-x-y") {
+		t.Fatalf("expected feat/x/y in feat-x-y, got %s in %s", branch, path)
+	}
+	for target, want := range map[string]string{"feat-x-y": path, "feat/x/y": path, "feat-old": nested} {
+		wt, err := m.FindWorktree(target)
+		if err != nil {
+			t.Fatalf("FindWorktree(%q) failed: %v", target, err)
+		}
+		if wt.Path != want {
+			t.Fatalf("FindWorktree(%q) = %s, want %s", target, wt.Path, want)
+		}
+	}
+}
+
+func TestResolveTarget(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("SPROUT_CONFIG", "")
+
+	newTestRepo(t)
+
+	m := NewManager(DefaultConfig())
+	paths := map[string]string{}
+	for _, branch := range []string{"feat/checkout-redesign", "feat/check-two", "fix/login"} {
+		_, path, err := m.NewWorktree(context.Background(), NewOptions{Branch: branch, SkipCopyUntracked: true})
+		if err != nil {
+			t.Fatalf("NewWorktree %s failed: %v", branch, err)
+		}
+		paths[branch] = path
+	}
+
+	for _, tc := range []struct {
+		target string
+		want   string
+		exact  bool
+	}{
+		{target: "fix/login", want: "fix/login", exact: true},
+		{target: "feat/checkout", want: "feat/checkout-redesign"},
+		{target: "LOG", want: "fix/login"},
+		{target: "chkout", want: "feat/checkout-redesign"},
+	} {
+		wt, exact, err := m.ResolveTarget(tc.target)
+		if err != nil {
+			t.Fatalf("ResolveTarget(%q) failed: %v", tc.target, err)
+		}
+		if wt.Path != paths[tc.want] || exact != tc.exact {
+			t.Fatalf("ResolveTarget(%q) = %s (exact %v), want %s (exact %v)", tc.target, wt.Path, exact, paths[tc.want], tc.exact)
+		}
+	}
+
+	_, _, err := m.ResolveTarget("feat/check")
+	if !errors.Is(err, errAmbiguousTarget) {
+		t.Fatalf("expected an ambiguous target error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "feat/checkout-redesign") || !strings.Contains(err.Error(), "feat/check-two") {
+		t.Fatalf("expected the candidates to be listed, got %v", err)
+	}
+	if _, _, err := m.ResolveTarget("zzz"); !errors.Is(err, errWorktreeNotFound) {
+		t.Fatalf("expected a not found error, got %v", err)
+	}
+}
+
+func TestResolveOutputFormat(t *testing.T) {
+	t.Setenv("SPROUT_OUTPUT", "")
+	if got, err := resolveOutputFormat(""); err != nil || got != outputText {
+		t.Fatalf("default format=%q err=%v", got, err)
+	}
+	t.Setenv("SPROUT_OUTPUT", "JSON")
+	if got, err := resolveOutputFormat(""); err != nil || got != outputJSON {
+		t.Fatalf("env format=%q err=%v", got, err)
+	}
+	if got, err := resolveOutputFormat("text"); err != nil || got != outputText {
+		t.Fatalf("flag should override env, got %q err=%v", got, err)
+	}
+	if _, err := resolveOutputFormat("yaml"); err == nil {
+		t.Fatalf("expected error for unsupported format")
+	}
+}
+
+func TestParseAddedTodos(t *testing.T) {
+	diff := strings.Join([]string{
+		"diff --git a/main.go b/main.go",
+		"--- a/main.go",
+		"+++ b/main.go",
+		"@@ -10,0 +11,3 @@ func main() {",
+		"+	// TODO: handle errors",
+		"+	run()",
+		"+	// FIXME remove debug output",
+		"@@ -40 +44 @@",
+		"-	// TODO old marker",
+		"+	// TODOS are not markers",
+		"diff --git a/gone.go b/gone.go",
+		"--- a/gone.go",
+		"+++ /dev/null",
+		"@@ -1 +0,0 @@",
+		"-// TODO removed",
+	}, "\n")
+
+	got := parseAddedTodos(diff)
+	want := []TodoMarker{
+		{File: "main.go", Line: 11, Kind: "TODO", Text: "// TODO: handle errors"},
+		{File: "main.go", Line: 13, Kind: "FIXME", Text: "// FIXME remove debug output"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("unexpected markers: %+v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("marker %d = %+v want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestDiffGoSymbols(t *testing.T) {
+	oldSrc := []byte(`package demo
+
+type Store struct{}
+
+func (s *Store) Get() int { return 1 }
+
+func helper() {}
+
+func legacy() {}
+`)
+	newSrc := []byte(`package demo
+
+type Store struct{ n int }
+
+func (s *Store) Get() int { return 1 }
+
+func helper() {}
+
+func NewStore() *Store { return &Store{} }
+`)
+
+	got, err := diffGoSymbols("demo.go", oldSrc, newSrc)
+	if err != nil {
+		t.Fatalf("diffGoSymbols failed: %v", err)
+	}
+	want := []SymbolChange{
+		{File: "demo.go", Kind: "func", Name: "NewStore", Change: "added"},
+		{File: "demo.go", Kind: "type", Name: "Store", Change: "modified"},
+		{File: "demo.go", Kind: "func", Name: "legacy", Change: "removed"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("unexpected changes: %+v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("change %d = %+v want %+v", i, got[i], want[i])
+		}
+	}
+
+	if _, err := diffGoSymbols("broken.go", nil, []byte("package demo\nfunc {")); err == nil {
+		t.Fatalf("expected parse error for broken source")
+	}
+}
+
+func TestTailDebugLogFiltersLevels(t *testing.T) {
+	t.Setenv("SPROUT_DEBUG_LOG", filepath.Join(t.TempDir(), "sprout.log"))
+	prev := activeLogLevel.Load()
+	t.Cleanup(func() { activeLogLevel.Store(prev) })
+	setLogLevel("trace")
+
+	errorLogf("launch failed")
+	infoLogf("launch start")
+	traceLogf("cmd start")
+
+	lines, err := tailDebugLog(10, logLevelInfo)
+	if err != nil {
+		t.Fatalf("tailDebugLog failed: %v", err)
+	}
+	if len(lines) != 2 || !strings.Contains(lines[0], "ERROR launch failed") || !strings.Contains(lines[1], "INFO  launch start") {
+		t.Fatalf("unexpected filtered lines: %q", lines)
+	}
+
+	setLogLevel("error")
+	infoLogf("suppressed")
+	if lines, _ := tailDebugLog(10, logLevelTrace); len(lines) != 3 {
+		t.Fatalf("expected info line to be suppressed at error level, got %q", lines)
+	}
+}
+
+func TestHighlightDiffHTML(t *testing.T) {
+	diff := "diff --git a/main.go b/main.go\n--- a/main.go\n+++ b/main.go\n@@ -1,2 +1,2 @@\n package main\n-var x = \"<a>\"\n+func f() {} // done\n"
+	lines := highlightDiffHTML(diff)
+	classes := []string{}
+	for _, l := range lines {
+		classes = append(classes, l.Class)
+	}
+	if got := strings.Join(classes, ","); got != "file,meta,meta,hunk,ctx,del,add" {
+		t.Fatalf("unexpected classes: %s", got)
+	}
+	if got := string(lines[5].HTML); got != `-<span class="k">var</span> x = <span class="s">&#34;&lt;a&gt;&#34;</span>` {
+		t.Fatalf("unexpected removed line html: %s", got)
+	}
+	if got := string(lines[6].HTML); !strings.Contains(got, `<span class="k">func</span>`) || !strings.Contains(got, `<span class="c">// done</span>`) {
+		t.Fatalf("unexpected added line html: %s", got)
+	}
+}
+
+func TestRenderReviewMarkdown(t *testing.T) {
+	diff := "diff --git a/a.go b/a.go\n--- a/a.go\n+++ b/a.go\n@@ -1 +1,2 @@\n-old\n+new\n+more\ndiff --git a/docs/x.md b/docs/x.md\nnew file mode 100644\n--- /dev/null\n+++ b/docs/x.md\n@@ -0,0 +1 @@\n+```go\n"
+	chunks := splitDiffFiles(diff)
+	if len(chunks) != 2 || chunks[0].File != "a.go" || chunks[1].File != "docs/x.md" {
+		t.Fatalf("unexpected chunks: %+v", chunks)
+	}
+	if chunks[0].Additions != 2 || chunks[0].Deletions != 1 || chunks[1].Additions != 1 || chunks[1].Deletions != 0 {
+		t.Fatalf("unexpected stats: %+v", chunks)
+	}
+	md := renderReviewMarkdown("feat/x", "main", diff, time.Unix(0, 0))
+	for _, want := range []string{"# Review: feat/x", "- Base: `main`", "- Files changed: 2 (+3 / -1)", "| `a.go` | 2 | 1 |", "### docs/x.md\n\n````diff\n"} {
+		if !strings.Contains(md, want) {
+			t.Fatalf("markdown missing %q:\n%s", want, md)
+		}
+	}
+}
+
+func TestAgentOutputDeltasAndHeatmap(t *testing.T) {
+	prev := map[string]agentPaneSample{"%1": {Path: "/w/a", History: 10}, "%2": {Path: "/w/b", History: 40}}
+	cur := map[string]agentPaneSample{
+		"%1": {Path: "/w/a", History: 25},
+		"%2": {Path: "/w/b", History: 5},  // cleared
+		"%3": {Path: "/w/c", History: 99}, // first sample is a baseline
+	}
+	deltas := agentOutputDeltas(prev, cur)
+	if len(deltas) != 1 || deltas["/w/a"] != 15 {
+		t.Fatalf("unexpected deltas: %v", deltas)
+	}
+
+	var act RepoActivity
+	act.Commits[activityHours-1] = 2
+	act.AgentLines[0] = 10
+	heatmap := []rune(activityHeatmap(act, act.MaxScore()))
+	if len(heatmap) != activityHours {
+		t.Fatalf("expected %d cells, got %d", activityHours, len(heatmap))
+	}
+	if heatmap[activityHours-1] != '█' || heatmap[0] != '░' || heatmap[1] != '·' {
+		t.Fatalf("unexpected heatmap: %s", string(heatmap))
+	}
+	if commits, lines := act.Totals(); commits != 2 || lines != 10 {
+		t.Fatalf("unexpected totals: %d %d", commits, lines)
+	}
+	if got := compactCount(1234); got != "1.2k" {
+		t.Fatalf("compactCount(1234)=%q", got)
+	}
+}
+
+func TestPriorityAndWIPLimit(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	repo, run := newTestRepo(t)
+	if err := os.WriteFile(filepath.Join(repo, "README.md"), []byte("hello\n"), 0o644); err != nil {
+		t.Fatalf("write file failed: %v", err)
+	}
+	run(repo, "add", "README.md")
+	run(repo, "commit", "-m", "add README.md")
+
+	cfg := DefaultConfig()
+	cfg.WIPLimit = 2
+	m := NewManager(cfg)
+	for _, branch := range []string{"feat/a", "feat/b"} {
+		if _, _, err := m.NewWorktree(context.Background(), NewOptions{Branch: branch, SkipCopyUntracked: true}); err != nil {
+			t.Fatalf("NewWorktree %s failed: %v", branch, err)
+		}
+	}
+	if _, err := m.SetPriority("feat/a", "high"); err != nil {
+		t.Fatalf("SetPriority high failed: %v", err)
+	}
+	if _, err := m.SetPriority("feat/b", "low"); err != nil {
+		t.Fatalf("SetPriority low failed: %v", err)
+	}
+	if _, err := m.SetPriority("feat/b", "urgent"); err == nil {
+		t.Fatalf("expected error for unknown priority")
+	}
+
+	wt, err := m.FindWorktree("feat/a")
+	if err != nil || wt.Priority != priorityHigh {
+		t.Fatalf("expected feat/a to be high priority, got %+v err=%v", wt, err)
+	}
+
+	status, err := m.WIPStatus()
+	if err != nil {
+		t.Fatalf("WIPStatus failed: %v", err)
+	}
+	if !status.Exceeded() || status.Count != 2 {
+		t.Fatalf("expected WIP limit to be reached, got %+v", status)
+	}
+	if status.Candidates[0].Branch != "feat/b" {
+		t.Fatalf("expected low priority worktree first, got %s", status.Candidates[0].Branch)
+	}
+
+	if _, err := m.SetPriority("feat/b", "normal"); err != nil {
+		t.Fatalf("SetPriority normal failed: %v", err)
+	}
+	if got := m.branchPriorities(repo); len(got) != 1 || got["feat/a"] != priorityHigh {
+		t.Fatalf("unexpected stored priorities: %v", got)
+	}
+
+	items := []Worktree{{Branch: "x"}, {Branch: "y", Priority: priorityLow}, {Branch: "z", Priority: priorityHigh}}
+	sortByPriority(items)
+	if items[0].Branch != "z" || items[1].Branch != "x" || items[2].Branch != "y" {
+		t.Fatalf("unexpected priority order: %+v", items)
+	}
+}
+
+func TestFetchPullRequest(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	upstream, run := newTestRepo(t)
+	run(upstream, "commit", "--allow-empty", "-m", "contributed change")
+	run(upstream, "update-ref", "refs/pull/7/head", "HEAD")
+	run(upstream, "reset", "--hard", "HEAD~1")
+	repo := filepath.Join(filepath.Dir(upstream), "clone")
+	run(upstream, "clone", "-q", upstream, repo)
+	// newTestRepo changes back to the starting directory when the test ends.
+	if err := os.Chdir(repo); err != nil {
+		t.Fatalf("chdir failed: %v", err)
+	}
+
+	m := NewManager(DefaultConfig())
+	if _, err := m.FetchPullRequest(context.Background(), 0); !errors.Is(err, errInvalidArguments) {
+		t.Fatalf("expected invalid number error, got %v", err)
+	}
+	branch, err := m.FetchPullRequest(context.Background(), 7)
+	if err != nil {
+		t.Fatalf("FetchPullRequest failed: %v", err)
+	}
+	if branch != "pr/7" {
+		t.Fatalf("unexpected pull request branch: %q", branch)
+	}
+	if got := run(repo, "log", "-1", "--format=%s", branch); got != "contributed change" {
+		t.Fatalf("pull request branch is at %q", got)
+	}
+	if got := run(repo, "config", "branch.pr/7.merge"); got != "refs/pull/7/head" {
+		t.Fatalf("pull request branch tracks %q", got)
+	}
+	if _, _, err := m.NewWorktree(context.Background(), NewOptions{FromBranch: branch, SkipCopyUntracked: true}); err != nil {
+		t.Fatalf("NewWorktree from pull request failed: %v", err)
+	}
+	wt, err := m.FindWorktree(branch)
+	if err != nil || wt.PR != 7 {
+		t.Fatalf("expected worktree of pull request 7, got %+v err=%v", wt, err)
+	}
+	if again, err := m.FetchPullRequest(context.Background(), 7); err != nil || again != branch {
+		t.Fatalf("second FetchPullRequest = %q, %v", again, err)
+	}
+}
+
+func TestCreateFromRemoteBranch(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	upstream, run := newTestRepo(t)
+	run(upstream, "branch", "feat/remote")
+	run(upstream, "branch", "feat/both")
+	repo := filepath.Join(filepath.Dir(upstream), "clone")
+	run(upstream, "clone", "-q", upstream, repo)
+	run(repo, "branch", "feat/both", "origin/feat/both")
+	run(upstream, "checkout", "-q", "feat/remote")
+	run(upstream, "commit", "--allow-empty", "-m", "pushed after clone")
+	// newTestRepo changes back to the starting directory when the test ends.
+	if err := os.Chdir(repo); err != nil {
+		t.Fatalf("chdir failed: %v", err)
+	}
+
+	m := NewManager(DefaultConfig())
+	branches, err := m.ListBranches(repo)
+	if err != nil {
+		t.Fatalf("ListBranches failed: %v", err)
+	}
+	where := map[string]string{}
+	for _, b := range branches {
+		where[b.Name] = b.Location()
+		if b.Author != "Sprout Test" || b.LastCommit.IsZero() || b.Subject != "init" {
+			t.Fatalf("missing tip commit of %s: %+v", b.Name, b)
+		}
+	}
+	if !reflect.DeepEqual(where, map[string]string{"feat/both": "local+remote", "feat/remote": "remote"}) {
+		t.Fatalf("unexpected branch locations: %v", where)
+	}
+
+	_, path, err := m.NewWorktree(context.Background(), NewOptions{FromBranch: "feat/remote", SkipCopyUntracked: true})
+	if err != nil {
+		t.Fatalf("NewWorktree from remote branch failed: %v", err)
+	}
+	if got := run(path, "rev-parse", "--abbrev-ref", "@{upstream}"); got != "origin/feat/remote" {
+		t.Fatalf("remote branch worktree tracks %q", got)
+	}
+	if got := run(path, "log", "-1", "--format=%s"); got != "pushed after clone" {
+		t.Fatalf("remote branch worktree is at %q, want the fetched tip", got)
+	}
+}
+
+func TestCheckDirWritable(t *testing.T) {
+	dir := t.TempDir()
+	if err := checkDirWritable(filepath.Join(dir, "not", "yet", "created")); err != nil {
+		t.Fatalf("expected missing dir under writable parent to pass: %v", err)
+	}
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, []byte("x"), 0o644); err != nil {
+		t.Fatalf("write file failed: %v", err)
+	}
+	if err := checkDirWritable(file); err == nil {
+		t.Fatalf("expected error for a file path")
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Fatalf("expected write check to clean up after itself, found %d entries", len(entries))
+	}
+}
+
+func TestDetailTabAt(t *testing.T) {
+	// " AGENT OUTPUT " starts at column 1; " | " separates the labels.
+	cases := []struct {
+		x    int
+		tab  detailTab
+		want bool
+	}{
+		{0, detailTabAgent, false},
+		{1, detailTabAgent, true},
+		{14, detailTabAgent, true},
+		{16, detailTabAgent, false},
+		{18, detailTabDiff, true},
+		{27, detailTabDiff, true},
+		{31, detailTabLog, true},
+		{35, detailTabLog, true},
+		{37, detailTabAgent, false},
+		{39, detailTabTodos, true},
+		{49, detailTabSymbols, true},
+		{60, detailTabAgent, false},
+	}
+	for _, tc := range cases {
+		tab, ok := detailTabAt(tc.x)
+		if ok != tc.want || ok && tab != tc.tab {
+			t.Fatalf("detailTabAt(%d) = %v, %v; want %v, %v", tc.x, tab, ok, tc.tab, tc.want)
+		}
+	}
+}
+
+func TestSessionResourceSampling(t *testing.T) {
+	out := `    1     0  0.0  1000 00:00:01
+  100     1  5.0  2048 01:00:00
+  101   100 50.0  4096 1-00:00:10
+  102   101  1.0  1024 00:00:00
+  200     1 90.0  8192 00:00:30`
+	procs := parseProcessTable(out)
+	if got := parseCPUTime("1-00:00:10"); got != 86410 {
+		t.Fatalf("parseCPUTime = %v, want 86410", got)
+	}
+
+	usage := sumProcessTrees(procs, []int{100})
+	if usage.Procs != 3 || usage.RSS != (2048+4096+1024)*1024 || usage.CPU != 56 {
+		t.Fatalf("unexpected usage from ps: %+v", usage)
+	}
+
+	// A second sample replaces ps's lifetime average with the recent share.
+	sampler := &resourceSampler{}
+	now := time.Now()
+	sampler.apply(procs, now)
+	next := parseProcessTable(strings.Replace(out, "1-00:00:10", "1-00:00:12", 1))
+	sampler.apply(next, now.Add(2*time.Second))
+	usage = sumProcessTrees(next, []int{100})
+	if usage.CPU != 100 {
+		t.Fatalf("expected 100%% CPU from the time delta, got %+v", usage)
+	}
+	if got := formatResourceUsage(usage); got != "100% 7M" {
+		t.Fatalf("formatResourceUsage = %q", got)
+	}
+}
+
+func TestDiffBaseCheckpointAndRef(t *testing.T) {
+	repo, run := newTestRepo(t)
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(repo, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s failed: %v", name, err)
+		}
+	}
+	write("README.md", "one\n")
+	run(repo, "add", "README.md")
+	run(repo, "commit", "-m", "add README.md")
+
+	m := NewManager(DefaultConfig())
+	wt := &Worktree{Path: repo}
+	if _, err := m.diffBaseRevision(repo, wt, DiffBase{Kind: diffBaseCheckpoint}); err == nil {
+		t.Fatalf("expected an error before any checkpoint was recorded")
+	}
+	if _, err := m.diffBaseRevision(repo, wt, DiffBase{Kind: diffBaseRef, Ref: "no-such-ref"}); err == nil {
+		t.Fatalf("expected an error for an unknown ref")
+	}
+
+	write("README.md", "one\ntwo\n")
+	if err := m.recordCheckpoint(repo); err != nil {
+		t.Fatalf("recordCheckpoint failed: %v", err)
+	}
+	write("README.md", "one\ntwo\nthree\n")
+	write("notes.txt", "new\n")
+
+	rev, err := m.diffBaseRevision(repo, wt, DiffBase{Kind: diffBaseCheckpoint})
+	if err != nil {
+		t.Fatalf("checkpoint revision: %v", err)
+	}
+	files, err := m.WorktreeDiffFilesAgainst(repo, rev)
+	if err != nil {
+		t.Fatalf("WorktreeDiffFilesAgainst failed: %v", err)
+	}
+	want := []DiffFile{{Path: "README.md", Status: "M "}, {Path: "notes.txt", Status: "??"}}
+	if !reflect.DeepEqual(files, want) {
+		t.Fatalf("unexpected files against checkpoint: got=%+v want=%+v", files, want)
+	}
+	patch, err := m.WorktreeDiffForFileAgainst(repo, rev, files[0], 120)
+	if err != nil {
+		t.Fatalf("WorktreeDiffForFileAgainst failed: %v", err)
+	}
+	if !strings.Contains(patch, "+three") || strings.Contains(patch, "+two") {
+		t.Fatalf("expected only the change since the checkpoint, got: %q", patch)
+	}
+
+	head, err := m.diffBaseRevision(repo, wt, DiffBase{Kind: diffBaseHead})
+	if err != nil {
+		t.Fatalf("HEAD revision: %v", err)
+	}
+	patch, err = m.WorktreeDiffForFileAgainst(repo, head, files[0], 120)
+	if err != nil {
+		t.Fatalf("WorktreeDiffForFileAgainst HEAD failed: %v", err)
+	}
+	if !strings.Contains(patch, "+two") || !strings.Contains(patch, "+three") {
+		t.Fatalf("expected every uncommitted change against HEAD, got: %q", patch)
+	}
+}
+
+func TestValidateNewFieldErrors(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	repo, run := newTestRepo(t)
+	parent := filepath.Dir(repo)
+	run(repo, "branch", "feat/taken")
+
+	m := NewManager(DefaultConfig())
+	codes := func(opts NewOptions) map[string]string {
+		t.Helper()
+		_, err := m.ValidateNew(opts)
+		var verr *ValidationError
+		if !errors.As(err, &verr) {
+			t.Fatalf("expected a ValidationError for %+v, got %v", opts, err)
+		}
+		got := map[string]string{}
+		for _, f := range verr.Fields {
+			got[f.Field] = f.Code
+		}
+		return got
+	}
+
+	if got := codes(NewOptions{Type: "oops", Name: "!!"}); !reflect.DeepEqual(got, map[string]string{createFieldType: createErrInvalidType, createFieldName: createErrEmptySlug}) {
+		t.Fatalf("unexpected type/name errors: %v", got)
+	}
+	if got := codes(NewOptions{Type: "feat", Name: "taken", BaseBranch: "nope"}); !reflect.DeepEqual(got, map[string]string{createFieldName: createErrBranchExists, createFieldBase: createErrBaseNotFound}) {
+		t.Fatalf("unexpected conflict/base errors: %v", got)
+	}
+	if got := codes(NewOptions{FromBranch: "feat/missing"}); got[createFieldBranch] != createErrBranchNotFound {
+		t.Fatalf("unexpected missing branch errors: %v", got)
+	}
+	if got := codes(NewOptions{Branch: "bad..name"}); got[createFieldBranch] != createErrInvalidBranch {
+		t.Fatalf("unexpected invalid branch errors: %v", got)
+	}
+
+	collision := filepath.Join(parent, "repo.worktrees", "feat", "collide")
+	if err := os.MkdirAll(collision, 0o755); err != nil {
+		t.Fatalf("mkdir collision failed: %v", err)
+	}
+	if got := codes(NewOptions{Type: "feat", Name: "collide"}); got[createFieldPath] != createErrPathExists {
+		t.Fatalf("unexpected path collision errors: %v", got)
+	}
+
+	req, err := m.ValidateNew(NewOptions{Type: "feat", Name: "Fresh Idea"})
+	if err != nil {
+		t.Fatalf("ValidateNew failed: %v", err)
+	}
+	if req.Branch != "feat/fresh-idea" || req.Base != "main" || filepath.Base(req.Path) != "fresh-idea" {
+		t.Fatalf("unexpected request: %+v", req)
+	}
+
+	m.Cfg.BranchTypes = []string{"story", "bug"}
+	m.Cfg.BranchTemplate = "{type}/{user}/{slug}"
+	m.Cfg.BranchPattern = `^(story|bug)/[a-z0-9-]+/[a-z0-9-]+$`
+	if got := codes(NewOptions{Type: "feat", Name: "x"}); got[createFieldType] != createErrInvalidType {
+		t.Fatalf("expected feat to be rejected by branch_types: %v", got)
+	}
+	req, err = m.ValidateNew(NewOptions{Type: "story", Name: "Login Page"})
+	if err != nil {
+		t.Fatalf("ValidateNew with branch_template failed: %v", err)
+	}
+	if req.Branch != "story/sprout-test/login-page" {
+		t.Fatalf("unexpected templated branch: %q", req.Branch)
+	}
+	if got := codes(NewOptions{Branch: "wip/Login"}); got[createFieldBranch] != createErrBranchPattern {
+		t.Fatalf("expected branch_pattern error: %v", got)
+	}
+}
+
+func TestFormatAge(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	cases := map[time.Duration]string{
+		20 * time.Second:     "just now",
+		5 * time.Minute:      "5m",
+		3 * time.Hour:        "3h",
+		4 * 24 * time.Hour:   "4d",
+		20 * 24 * time.Hour:  "2w",
+		100 * 24 * time.Hour: "3mo",
+		800 * 24 * time.Hour: "2y",
+	}
+	for ago, want := range cases {
+		if got := formatAge(now.Add(-ago), now); got != want {
+			t.Fatalf("formatAge(%s ago) = %q, want %q", ago, got, want)
+		}
+	}
+	if got := commitSubject("\nline one\nline two\n\nbody\n"); got != "line one line two" {
+		t.Fatalf("commitSubject = %q", got)
+	}
+}
+
+func TestFuzzyMatch(t *testing.T) {
+	if _, _, ok := fuzzyMatch("fxl", "feat/login"); ok {
+		t.Fatalf("expected out-of-order runes not to match")
+	}
+	_, positions, ok := fuzzyMatch("FL", "feat/login")
+	if !ok {
+		t.Fatalf("expected a case-insensitive match")
+	}
+	if want := []int{0, 5}; !reflect.DeepEqual(positions, want) {
+		t.Fatalf("positions = %v, want %v", positions, want)
+	}
+
+	tight, _, _ := fuzzyMatch("login", "feat/login-page")
+	loose, _, _ := fuzzyMatch("login", "fix/large-obscure-gizmo-ui")
+	if tight <= loose {
+		t.Fatalf("expected a contiguous match to outscore a scattered one: %d <= %d", tight, loose)
+	}
+
+	got := highlightMatches("feat/[x]", []int{0, 5}, 35)
+	if width := tview.TaggedStringWidth(got); width != len("feat/[x]") {
+		t.Fatalf("highlightMatches(%q) = %q renders %d wide, want the text unchanged", "feat/[x]", got, width)
+	}
+	if got := highlightMatches("feature/very-long", []int{0, 14}, 10); got != "[::bu]f[::-]eature..." {
+		t.Fatalf("expected truncated matches to stay plain, got %q", got)
+	}
+}
+
+func TestOSC52Sequence(t *testing.T) {
+	if got, want := osc52Sequence("feat/x", false), "\x1b]52;c;ZmVhdC94\a"; got != want {
+		t.Fatalf("osc52Sequence = %q, want %q", got, want)
+	}
+	if got, want := osc52Sequence("feat/x", true), "\x1bPtmux;\x1b\x1b]52;c;ZmVhdC94\a\x1b\\"; got != want {
+		t.Fatalf("tmux osc52Sequence = %q, want %q", got, want)
+	}
+}
+
+func TestWorktreeFilePatch(t *testing.T) {
+	repo, run := newTestRepo(t)
+	if err := os.WriteFile(filepath.Join(repo, "a.txt"), []byte("one\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	run(repo, "add", "a.txt")
+	run(repo, "commit", "-m", "init")
+	if err := os.WriteFile(filepath.Join(repo, "a.txt"), []byte("one\ntwo\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	run(repo, "add", "a.txt")
+	if err := os.WriteFile(filepath.Join(repo, "a.txt"), []byte("one\ntwo\nthree\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	m := NewManager(DefaultConfig())
+	patch, err := m.WorktreeFilePatch(repo, "", DiffFile{Path: "a.txt", Status: "MM"})
+	if err != nil {
+		t.Fatalf("WorktreeFilePatch failed: %v", err)
+	}
+	if !strings.Contains(patch, "+two") || !strings.Contains(patch, "+three") || strings.Contains(patch, "\x1b[") {
+		t.Fatalf("expected one plain patch of staged and unstaged changes, got:\n%s", patch)
+	}
+}
+
+func TestWorktreeLog(t *testing.T) {
+	repo, run := newTestRepo(t)
+	run(repo, "checkout", "-b", "feat/log")
+	if err := os.WriteFile(filepath.Join(repo, "a.txt"), []byte("one\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	run(repo, "add", "a.txt")
+	run(repo, "commit", "-m", "add a")
+	run(repo, "commit", "--allow-empty", "-m", "second | subject")
+
+	cfg := DefaultConfig()
+	cfg.BaseBranch = "main"
+	m := NewManager(cfg)
+	commits, err := m.WorktreeLog(repo, &Worktree{Path: repo, Branch: "feat/log"})
+	if err != nil {
+		t.Fatalf("WorktreeLog failed: %v", err)
+	}
+	if len(commits) != 2 || commits[0].Subject != "second | subject" || commits[1].Subject != "add a" {
+		t.Fatalf("expected the two branch commits newest first, got %+v", commits)
+	}
+	if commits[0].Author != "Sprout Test" || commits[0].Date.IsZero() || !strings.HasPrefix(commits[0].Hash, commits[0].ShortHash) {
+		t.Fatalf("unexpected commit fields: %+v", commits[0])
+	}
+
+	patch, err := m.CommitPatch(repo, commits[1].Hash, 80)
+	if err != nil {
+		t.Fatalf("CommitPatch failed: %v", err)
+	}
+	if !strings.Contains(patch, "add a") || !strings.Contains(patch, "a.txt") || !strings.Contains(patch, "one") {
+		t.Fatalf("expected the commit message, stat, and patch, got:\n%s", patch)
+	}
+}
+
+func TestBranchConflicts(t *testing.T) {
+	repo, run := newTestRepo(t)
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(repo, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	write("a.txt", "one\n")
+	write("b.txt", "one\n")
+	run(repo, "add", ".")
+	run(repo, "commit", "-m", "init")
+	run(repo, "branch", "feat/clean")
+	run(repo, "checkout", "-b", "feat/conflict")
+	write("a.txt", "branch\n")
+	run(repo, "commit", "-am", "branch change")
+	run(repo, "checkout", "main")
+	write("a.txt", "main\n")
+	run(repo, "commit", "-am", "main change")
+
+	cfg := DefaultConfig()
+	cfg.BaseBranch = "main"
+	m := NewManager(cfg)
+	run(repo, "checkout", "feat/conflict")
+	files, err := m.BranchConflicts(repo, &Worktree{Path: repo, Branch: "feat/conflict"})
+	if err != nil {
+		if strings.Contains(err.Error(), "write-tree") {
+			t.Skip("git merge-tree --write-tree needs git 2.38")
+		}
+		t.Fatalf("BranchConflicts failed: %v", err)
+	}
+	if !reflect.DeepEqual(files, []string{"a.txt"}) {
+		t.Fatalf("expected a.txt to conflict, got %v", files)
+	}
+
+	run(repo, "checkout", "feat/clean")
+	files, err = m.BranchConflicts(repo, &Worktree{Path: repo, Branch: "feat/clean"})
+	if err != nil || files == nil || len(files) != 0 {
+		t.Fatalf("expected no conflicts for a clean branch, got %v, %v", files, err)
+	}
+	run(repo, "checkout", "main")
+	if files, err := m.BranchConflicts(repo, &Worktree{Path: repo, Branch: "main"}); err != nil || files != nil {
+		t.Fatalf("expected nothing to check on the base branch, got %v, %v", files, err)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	repo, git := newTestRepo(t)
+	parent := filepath.Dir(repo)
+	commitFile := func(dir, name string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name+"\n"), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		git(dir, "add", name)
+		git(dir, "commit", "-m", "add "+name)
+	}
+	commitFile(repo, "README.md")
+	pathA := filepath.Join(parent, "a")
+	pathB := filepath.Join(parent, "b")
+	git(repo, "worktree", "add", "-b", "feat/a", pathA)
+	git(repo, "worktree", "add", "-b", "feat/b", pathB)
+	commitFile(pathA, "a.txt")
+	commitFile(pathB, "b1.txt")
+	commitFile(pathB, "b2.txt")
+
+	cfg := DefaultConfig()
+	cfg.BaseBranch = "main"
+	m := NewManager(cfg)
+	ctx := context.Background()
+
+	if err := os.WriteFile(filepath.Join(pathA, "a.txt"), []byte("changed\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err := m.PlanMerge(ctx, MergeOptions{Target: "feat/a"}); err == nil || !strings.Contains(err.Error(), "uncommitted") {
+		t.Fatalf("expected a dirty worktree to be refused, got %v", err)
+	}
+	git(pathA, "checkout", "--", "a.txt")
+
+	// main is checked out in the repository, so the merge happens there.
+	res, err := m.Merge(ctx, MergeOptions{Target: "feat/a", SkipChecks: true})
+	if err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	if res.Into == "" || res.Removed {
+		t.Fatalf("expected a merge in the main worktree that keeps feat/a, got %+v", res)
+	}
+	if parents := strings.Fields(git(repo, "log", "-1", "--format=%P", "main")); len(parents) != 2 {
+		t.Fatalf("expected a merge commit on main, got parents %v", parents)
+	}
+	if _, err := os.Stat(filepath.Join(repo, "a.txt")); err != nil {
+		t.Fatalf("expected a.txt in the main worktree: %v", err)
+	}
+
+	// With main checked out nowhere, a temporary worktree is used.
+	git(repo, "checkout", "--detach")
+	before := git(repo, "rev-parse", "main")
+	res, err = m.Merge(ctx, MergeOptions{Target: "feat/b", Squash: true, DeleteBranch: true, SkipChecks: true})
+	if err != nil {
+		t.Fatalf("squash Merge failed: %v", err)
+	}
+	if res.Into != "" || !res.Removed || len(res.Warnings) != 0 {
+		t.Fatalf("expected a squash merge in a temporary worktree that removes feat/b, got %+v", res)
+	}
+	if parent := git(repo, "rev-parse", "main~1"); parent != before {
+		t.Fatalf("expected one squashed commit on main, got parent %s want %s", parent, before)
+	}
+	if files := git(repo, "show", "--name-only", "--format=", "main"); files != "b1.txt\nb2.txt" {
+		t.Fatalf("expected the squashed commit to add both files, got %q", files)
+	}
+	if _, err := os.Stat(pathB); !os.IsNotExist(err) {
+		t.Fatalf("expected %s to be removed, got %v", pathB, err)
+	}
+	if m.BranchExists(repo, "feat/b") {
+		t.Fatal("expected feat/b to be deleted")
+	}
+	if list := git(repo, "worktree", "list", "--porcelain"); strings.Count(list, "worktree ") != 2 {
+		t.Fatalf("expected the temporary worktree to be gone, got:\n%s", list)
+	}
+}
+
+func TestSavedFilters(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	if got := savedFilter("/repo/a"); got != "" {
+		t.Fatalf("expected no saved filter, got %q", got)
+	}
+	if err := saveFilter("/repo/a", " login "); err != nil {
+		t.Fatalf("saveFilter failed: %v", err)
+	}
+	if err := saveFilter("/repo/b", "fix"); err != nil {
+		t.Fatalf("saveFilter failed: %v", err)
+	}
+	if got := savedFilter("/repo/a"); got != "login" {
+		t.Fatalf("savedFilter(/repo/a) = %q, want login", got)
+	}
+	if err := saveFilter("/repo/a", ""); err != nil {
+		t.Fatalf("saveFilter failed: %v", err)
+	}
+	if got := savedFilter("/repo/a"); got != "" {
+		t.Fatalf("expected a cleared filter to be forgotten, got %q", got)
+	}
+	if got := savedFilter("/repo/b"); got != "fix" {
+		t.Fatalf("savedFilter(/repo/b) = %q, want fix", got)
+	}
+}
+
+func TestTmuxKeyForEvent(t *testing.T) {
+	cases := []struct {
+		ev      *tcell.EventKey
+		key     string
+		literal bool
+	}{
+		{tcell.NewEventKey(tcell.KeyRune, 'x', tcell.ModNone), "x", true},
+		{tcell.NewEventKey(tcell.KeyRune, 'x', tcell.ModAlt), "M-x", false},
+		{tcell.NewEventKey(tcell.KeyEnter, 0, tcell.ModNone), "Enter", false},
+		{tcell.NewEventKey(tcell.KeyBackspace2, 0, tcell.ModNone), "BSpace", false},
+		{tcell.NewEventKey(tcell.KeyTab, 0, tcell.ModNone), "Tab", false},
+		{tcell.NewEventKey(tcell.KeyLeft, 0, tcell.ModCtrl), "C-Left", false},
+		{tcell.NewEventKey(tcell.KeyCtrlC, 0, tcell.ModCtrl), "C-c", false},
+		{tcell.NewEventKey(tcell.KeyF5, 0, tcell.ModNone), "F5", false},
+	}
+	for _, tc := range cases {
+		key, literal, ok := tmuxKeyForEvent(tc.ev)
+		if !ok || key != tc.key || literal != tc.literal {
+			t.Fatalf("tmuxKeyForEvent(%v) = %q, %v, %v; want %q, %v", tc.ev.Name(), key, literal, ok, tc.key, tc.literal)
+		}
+	}
+}
+
+func TestResolveAgentCommandMissing(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.AgentCommand = "sprout-missing-agent --flag"
+	cfg.AgentCommands = map[string]string{"shell": "sh -i", "other": "sprout-missing-other", "same": "sprout-missing-agent"}
+	m := NewManager(cfg)
+
+	_, err := m.resolveAgentCommand("", "")
+	var notFound *AgentNotFoundError
+	if !errors.As(err, &notFound) {
+		t.Fatalf("expected AgentNotFoundError, got %v", err)
+	}
+	if err.Error() != "agent command not found: sprout-missing-agent" || !reflect.DeepEqual(notFound.Alternatives, []string{"shell"}) {
+		t.Fatalf("unexpected error %q with alternatives %v", err, notFound.Alternatives)
+	}
+	if got, err := m.resolveAgentCommand("", "shell"); err != nil || got != "sh -i" {
+		t.Fatalf("resolveAgentCommand(shell) = %q, %v", got, err)
+	}
+	if _, err := m.resolveAgentCommand("", "nope"); err == nil || !strings.Contains(err.Error(), "unknown agent type") {
+		t.Fatalf("expected unknown agent type error, got %v", err)
+	}
+}
+
+func TestResolveAgentCommandWorktreeOverride(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.AgentCommand = "sh"
+	cfg.AgentArgs = "-e"
+	cfg.AgentCommands = map[string]string{"shell": "sh -i"}
+	m := NewManager(cfg)
+
+	main := t.TempDir()
+	linked := t.TempDir()
+	if err := os.Mkdir(filepath.Join(main, ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(linked, ".git"), []byte("gitdir: "+main+"/.git/worktrees/x\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// The main worktree's .sprout.toml is the repo config, not an override.
+	if err := os.WriteFile(filepath.Join(main, ".sprout.toml"), []byte("agent_command = \"sh -x\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := m.resolveAgentCommand(main, ""); err != nil || got != "sh -e" {
+		t.Fatalf("resolveAgentCommand(main) = %q, %v; want %q", got, err, "sh -e")
+	}
+
+	if err := os.WriteFile(filepath.Join(linked, ".sprout.toml"), []byte("agent_args = \"-v\"\nagent_command_shell = \"sh -l\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := m.resolveAgentCommand(linked, ""); err != nil || got != "sh -v" {
+		t.Fatalf("resolveAgentCommand(linked) = %q, %v; want %q", got, err, "sh -v")
+	}
+	if got, err := m.resolveAgentCommand(linked, "shell"); err != nil || got != "sh -l -v" {
+		t.Fatalf("resolveAgentCommand(linked, shell) = %q, %v; want %q", got, err, "sh -l -v")
+	}
+	override, err := m.worktreeAgentOverride(linked)
+	if err != nil || override.Source != filepath.Join(linked, ".sprout.toml") || override.Command != "" {
+		t.Fatalf("worktreeAgentOverride(linked) = %+v, %v", override, err)
+	}
+}
+
+func TestAgentWatcherObserve(t *testing.T) {
+	w := newAgentWatcher(0)
+	now := time.Now()
+	steps := []struct {
+		status string
+		event  string
+	}{
+		{agentStatusReady, ""},
+		{agentStatusBusy, ""},
+		{agentStatusBusy, ""},
+		{agentStatusReady, notifyEventReady},
+		{agentStatusExited, notifyEventExited},
+		{agentStatusExited, ""},
+		{agentStatusBusy, ""},
+		{agentStatusNone, notifyEventExited},
+	}
+	for i, step := range steps {
+		if got := w.observe("/wt", step.status, now, time.Time{}); got != step.event {
+			t.Fatalf("step %d: observe(%q) = %q, want %q", i, step.status, got, step.event)
+		}
+	}
+	w.forget(map[string]struct{}{})
+	if got := w.observe("/wt", agentStatusReady, now, time.Time{}); got != "" {
+		t.Fatalf("expected a forgotten worktree to start a new baseline, got %q", got)
+	}
+}
+
+func TestAgentWatcherTimers(t *testing.T) {
+	w := newAgentWatcher(15 * time.Minute)
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// An agent already busy when watching starts has no known start time.
+	w.observe("/wt", agentStatusBusy, start, time.Time{})
+	if got := w.timers()["/wt"].label(start.Add(time.Hour)); got != "busy" {
+		t.Fatalf("label = %q, want busy", got)
+	}
+	// It is ready since its pane last changed, not since it was polled.
+	if got := w.observe("/wt", agentStatusReady, start.Add(10*time.Minute), start.Add(9*time.Minute)); got != notifyEventReady {
+		t.Fatalf("expected ready, got %q", got)
+	}
+	if got := w.timers()["/wt"].label(start.Add(21 * time.Minute)); got != "ready 12m" {
+		t.Fatalf("label = %q, want ready 12m", got)
+	}
+	if got := w.observe("/wt", agentStatusReady, start.Add(23*time.Minute), start.Add(22*time.Minute)); got != "" {
+		t.Fatalf("expected no event before the idle threshold, got %q", got)
+	}
+	if got := w.observe("/wt", agentStatusReady, start.Add(24*time.Minute), time.Time{}); got != notifyEventIdle {
+		t.Fatalf("expected idle, got %q", got)
+	}
+	if got := w.observe("/wt", agentStatusReady, start.Add(3*time.Hour), time.Time{}); got != "" {
+		t.Fatalf("expected idle to fire once, got %q", got)
+	}
+	if got := w.timers()["/wt"].label(start.Add(3*time.Hour + 9*time.Minute)); got != "ready 3h" {
+		t.Fatalf("label = %q, want ready 3h", got)
+	}
+
+	// Busy again, then ready: a new spell that can turn idle again.
+	w.observe("/wt", agentStatusBusy, start.Add(4*time.Hour), time.Time{})
+	if got := w.timers()["/wt"].label(start.Add(4*time.Hour + 90*time.Minute)); got != "busy 1h30m" {
+		t.Fatalf("label = %q, want busy 1h30m", got)
+	}
+	w.observe("/wt", agentStatusReady, start.Add(5*time.Hour), start.Add(5*time.Hour))
+	if got := w.observe("/wt", agentStatusReady, start.Add(5*time.Hour+15*time.Minute), time.Time{}); got != notifyEventIdle {
+		t.Fatalf("expected idle again, got %q", got)
+	}
+
+	// An agent that was already waiting long before watching began is idle
+	// on the first poll, without a ready event.
+	if got := w.observe("/other", agentStatusReady, start, start.Add(-time.Hour)); got != notifyEventIdle {
+		t.Fatalf("expected idle for a long-waiting agent, got %q", got)
+	}
+	w.observe("/gone", agentStatusExited, start, time.Time{})
+	if _, ok := w.timers()["/gone"]; ok {
+		t.Fatalf("exited agents should have no timer")
+	}
+}
+
+func TestPostWebhook(t *testing.T) {
+	got := make(chan AgentEvent, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev AgentEvent
+		if err := json.NewDecoder(r.Body).Decode(&ev); err != nil {
+			t.Errorf("decode webhook body: %v", err)
+		}
+		got <- ev
+	}))
+	defer srv.Close()
+
+	ev := AgentEvent{Event: notifyEventReady, Repo: "app", Branch: "feat/a", Path: "/tmp/app/feat/a"}
+	if err := postWebhook(srv.URL, ev); err != nil {
+		t.Fatalf("postWebhook failed: %v", err)
+	}
+	if received := <-got; received.Event != ev.Event || received.Branch != ev.Branch {
+		t.Fatalf("unexpected webhook payload: %+v", received)
+	}
+}
+
+func TestPick(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	repo, git := newTestRepo(t)
+	parent := filepath.Dir(repo)
+	writeFile := func(dir, name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	commitFile := func(dir, name, content string) {
+		writeFile(dir, name, content)
+		git(dir, "add", name)
+		git(dir, "commit", "-m", "edit "+name)
+	}
+	commitFile(repo, "README.md", "readme\n")
+	commitFile(repo, "shared.txt", "one\n")
+	pathA := filepath.Join(parent, "a")
+	pathB := filepath.Join(parent, "b")
+	git(repo, "worktree", "add", "-b", "feat/a", pathA)
+	git(repo, "worktree", "add", "-b", "feat/b", pathB)
+
+	cfg := DefaultConfig()
+	cfg.BaseBranch = "main"
+	m := NewManager(cfg)
+	ctx := context.Background()
+
+	if _, err := m.Pick(ctx, PickOptions{Source: "feat/a", Dest: "feat/a", Files: []string{"README.md"}}); err == nil {
+		t.Fatalf("expected picking into the same worktree to fail")
+	}
+	if _, err := m.Pick(ctx, PickOptions{Source: "feat/a", Dest: "feat/b", Files: []string{"README.md"}}); err == nil || !strings.Contains(err.Error(), "no changes") {
+		t.Fatalf("expected an unchanged file to be refused, got %v", err)
+	}
+
+	// A committed change and an untracked file both come along.
+	commitFile(pathA, "README.md", "readme from a\n")
+	writeFile(pathA, "new.txt", "new\n")
+	res, err := m.Pick(ctx, PickOptions{Source: "feat/a", Dest: "feat/b", Files: []string{"README.md", "new.txt"}})
+	if err != nil {
+		t.Fatalf("pick files failed: %v", err)
+	}
+	if len(res.Conflicts) != 0 {
+		t.Fatalf("unexpected conflicts: %v", res.Conflicts)
+	}
+	for name, want := range map[string]string{"README.md": "readme from a\n", "new.txt": "new\n"} {
+		got, err := os.ReadFile(filepath.Join(pathB, name))
+		if err != nil || string(got) != want {
+			t.Fatalf("%s in b = %q (%v), want %q", name, got, err, want)
+		}
+	}
+	git(pathB, "checkout", "--", "README.md")
+	if err := os.Remove(filepath.Join(pathB, "new.txt")); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+
+	commitFile(pathA, "a.txt", "a\n")
+	hash := git(pathA, "rev-parse", "HEAD")
+	res, err = m.Pick(ctx, PickOptions{Source: "feat/a", Dest: "feat/b", Commits: []string{hash}})
+	if err != nil {
+		t.Fatalf("pick commit failed: %v", err)
+	}
+	if len(res.Commits) != 1 {
+		t.Fatalf("expected one new commit, got %v", res.Commits)
+	}
+	if subject := git(pathB, "log", "-1", "--format=%s"); subject != "edit a.txt" {
+		t.Fatalf("b's tip = %q, want the cherry-picked commit", subject)
+	}
+
+	// A conflicting cherry-pick is aborted and leaves b where it was.
+	commitFile(pathA, "shared.txt", "from a\n")
+	conflicting := git(pathA, "rev-parse", "HEAD")
+	commitFile(pathB, "shared.txt", "from b\n")
+	before := git(pathB, "rev-parse", "HEAD")
+	if _, err := m.Pick(ctx, PickOptions{Source: "feat/a", Dest: "feat/b", Commits: []string{conflicting}}); err == nil || !strings.Contains(err.Error(), "conflict") {
+		t.Fatalf("expected a conflicting cherry-pick to fail, got %v", err)
+	}
+	if after := git(pathB, "rev-parse", "HEAD"); after != before {
+		t.Fatalf("b moved from %s to %s after an aborted cherry-pick", before, after)
+	}
+	if status := git(pathB, "status", "--porcelain"); status != "" {
+		t.Fatalf("b is not clean after an aborted cherry-pick: %q", status)
+	}
+}
+
+func TestReadTaskList(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		return path
+	}
+
+	markdown := write("tasks.md", `# Sprint
+
+Some notes that are not tasks.
+
+- [ ] Add login page
+  Use the existing form components.
+
+    Keep it accessible.
+- [x] Already done
+1. Fix flaky test
+* Plain item
+`)
+	tasks, err := ReadTaskList(markdown)
+	if err != nil {
+		t.Fatalf("markdown: %v", err)
+	}
+	want := []PlanTask{
+		{Title: "Add login page", Prompt: "Add login page\n\nUse the existing form components.\n\n  Keep it accessible."},
+		{Title: "Fix flaky test", Prompt: "Fix flaky test"},
+		{Title: "Plain item", Prompt: "Plain item"},
+	}
+	if !reflect.DeepEqual(tasks, want) {
+		t.Fatalf("markdown tasks = %#v, want %#v", tasks, want)
+	}
+
+	yaml := write("tasks.yaml", `tasks:
+  # a comment
+  - Add login page
+  - title: "Fix: flaky test"
+    type: fix
+    prompt: |
+      The test in ci.go fails
+      one run in ten.
+  - prompt: >
+      Write the
+      release notes
+  - 'It''s quoted' # trailing comment
+`)
+	tasks, err = ReadTaskList(yaml)
+	if err != nil {
+		t.Fatalf("yaml: %v", err)
+	}
+	want = []PlanTask{
+		{Title: "Add login page", Prompt: "Add login page"},
+		{Title: "Fix: flaky test", Prompt: "The test in ci.go fails\none run in ten.", Type: "fix"},
+		{Title: "Write the release notes", Prompt: "Write the release notes"},
+		{Title: "It's quoted", Prompt: "It's quoted"},
+	}
+	if !reflect.DeepEqual(tasks, want) {
+		t.Fatalf("yaml tasks = %#v, want %#v", tasks, want)
+	}
+
+	for name, content := range map[string]string{
+		"unknown.yaml": "- title: a\n  owner: me\n",
+		"nested.yml":   "- a\n  - b\n",
+		"empty.md":     "# Nothing to do\n",
+	} {
+		if _, err := ReadTaskList(write(name, content)); err == nil {
+			t.Fatalf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestPlan(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	newTestRepo(t)
+
+	cfg := DefaultConfig()
+	cfg.BaseBranch = "main"
+	m := NewManager(cfg)
+	ctx := context.Background()
+
+	tasks := []PlanTask{
+		{Title: "Add login page", Prompt: "Add login page\n\nUse the form components."},
+		{Title: "Flaky test", Prompt: "Flaky test", Type: "fix"},
+		{Title: "!!!", Prompt: "!!!"},
+	}
+	results, err := m.Plan(ctx, PlanOptions{Tasks: tasks, Type: "feat"})
+	if err != nil {
+		t.Fatalf("plan failed: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %#v", results)
+	}
+	if results[0].Branch != "feat/add-login-page" || results[0].Error != "" || results[0].Existing {
+		t.Fatalf("unexpected first result: %#v", results[0])
+	}
+	if results[1].Branch != "fix/flaky-test" || results[1].Error != "" {
+		t.Fatalf("unexpected second result: %#v", results[1])
+	}
+	if results[2].Error == "" {
+		t.Fatalf("expected a title without a slug to fail: %#v", results[2])
+	}
+
+	items, err := m.ListWorktrees(ctx)
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	found := false
+	for _, it := range items {
+		if it.Branch == "feat/add-login-page" {
+			found = true
+			if it.Task != tasks[0].Prompt {
+				t.Fatalf("task = %q, want %q", it.Task, tasks[0].Prompt)
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("feat/add-login-page worktree missing: %#v", items)
+	}
+
+	results, err = m.Plan(ctx, PlanOptions{Tasks: tasks[:1], Type: "feat"})
+	if err != nil {
+		t.Fatalf("second plan failed: %v", err)
+	}
+	if !results[0].Existing || results[0].Error != "" {
+		t.Fatalf("expected the existing worktree to be left alone: %#v", results[0])
+	}
+}
+
+func TestLintWindows(t *testing.T) {
+	worktree := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(worktree, "web", "scripts"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(worktree, "web", "scripts", "dev.sh"), []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatalf("write script: %v", err)
+	}
+	cfg := DefaultConfig()
+	cfg.Windows = []WindowConfig{
+		{Name: "dev", Layout: "main-vertical", Dir: "web", Panes: []PaneConfig{
+			{Run: "sh"},
+			{Run: "PORT=3000 ./scripts/dev.sh"},
+			{Run: "cd api && make"},
+		}},
+		{Name: "dev", Layout: "sideways", Panes: []PaneConfig{
+			{Dir: "{worktree}/missing", Run: "sprout-lint-missing-tool --watch"},
+			{},
+		}},
+		{Name: "logs"},
+	}
+
+	got := map[string]bool{}
+	for _, issue := range NewManager(cfg).LintWindows(worktree) {
+		got[issue.String()] = true
+	}
+	want := []string{
+		`window "dev": same name as window 1; tmux would reuse that window`,
+		`window "dev": unknown layout "sideways" (want even-horizontal, even-vertical, main-horizontal, main-horizontal-mirrored, main-vertical, main-vertical-mirrored, tiled)`,
+		`window "dev" pane 1: dir "{worktree}/missing" does not exist (` + filepath.Join(worktree, "missing") + `)`,
+		`window "dev" pane 1: sprout-lint-missing-tool is not installed`,
+		`window "dev" pane 2: empty pane; set run or dir, or leave it out`,
+		`window "logs": no [[windows.panes]]; the window opens a shell`,
+	}
+	for _, issue := range want {
+		if !got[issue] {
+			t.Errorf("missing issue %q in %v", issue, got)
+		}
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d issues, want %d: %v", len(got), len(want), got)
+	}
+
+	cfg.ContainerCommand = "docker exec -it app"
+	for _, issue := range NewManager(cfg).LintWindows(worktree) {
+		if strings.Contains(issue.Message, "not installed") {
+			t.Fatalf("commands run in a container were checked: %v", issue)
+		}
+	}
+	if !validTmuxLayout("bb62,159x48,0,0{79x48,0,0,79x48,80,0}") {
+		t.Fatalf("expected a saved tmux layout string to be valid")
+	}
+}
+
+func TestPreviewLaunchWindows(t *testing.T) {
+	repo, _ := newTestRepo(t)
+
+	cfg := DefaultConfig()
+	cfg.Windows = []WindowConfig{
+		{Name: "editor", Layout: "main-vertical", Panes: []PaneConfig{{Run: "nvim ."}, {Dir: "web", Run: "npm run dev"}}},
+		{Name: "shell"},
+	}
+	m := NewManager(cfg)
+	preview, err := m.PreviewLaunch(repo)
+	if err != nil {
+		t.Fatalf("PreviewLaunch failed: %v", err)
+	}
+	s := preview.Session
+	want := []string{
+		"tmux new-session -d -s " + s + " -n editor -c " + repo + " 'nvim .' \\; set-window-option -t " + s + ":editor remain-on-exit on",
+		"tmux split-window -h -t " + s + ":editor -c " + filepath.Join(repo, "web") + " 'npm run dev'",
+		"tmux select-layout -t " + s + ":editor main-vertical",
+		"tmux new-window -d -t " + s + " -n shell -c " + repo + " " + defaultShellCommand(),
+	}
+	if !reflect.DeepEqual(preview.Commands, want) {
+		t.Fatalf("unexpected commands:\n%s\nwant:\n%s", strings.Join(preview.Commands, "\n"), strings.Join(want, "\n"))
+	}
+	if preview.Window != "editor" || m.preview != nil {
+		t.Fatalf("unexpected preview state: window=%q preview=%v", preview.Window, m.preview)
+	}
+}
+
+func TestLaunchSwitchClient(t *testing.T) {
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux is required for this test")
+	}
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("TMUX", "/tmp/tmux-test/default,1,0")
+	repo, _ := newTestRepo(t)
+
+	launch := func(opts LaunchOptions) []string {
+		m := NewManager(DefaultConfig())
+		m.preview = newTmuxPreview()
+		opts.Target = repo
+		if _, err := m.Launch(opts); err != nil {
+			t.Fatalf("Launch failed: %v", err)
+		}
+		return m.preview.commands
+	}
+	session := NewManager(DefaultConfig()).tmuxWorktreeSessionNameFrom(repo, "main", repo)
+	switchClient := "tmux switch-client -t " + session
+
+	if got := launch(LaunchOptions{}); slices.Contains(got, switchClient) {
+		t.Fatalf("launch inside tmux switched the client:\n%s", strings.Join(got, "\n"))
+	}
+	if got := launch(LaunchOptions{SwitchClient: true}); !slices.Contains(got, switchClient) {
+		t.Fatalf("expected %q, got:\n%s", switchClient, strings.Join(got, "\n"))
+	}
+}
+
+func TestGoWindow(t *testing.T) {
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux is required for this test")
+	}
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("TMUX", "/tmp/tmux-test/default,1,0")
+	repo, _ := newTestRepo(t)
+
+	m := NewManager(DefaultConfig())
+	m.preview = newTmuxPreview()
+	if _, err := m.Go(GoOptions{Target: repo, Launch: true, Attach: true, Window: "lazygit"}); err != nil {
+		t.Fatalf("Go failed: %v", err)
+	}
+	session := m.tmuxWorktreeSessionNameFrom(repo, "main", repo)
+	got := strings.Join(m.preview.commands, "\n")
+	for _, want := range []string{
+		"tmux new-session -d -s " + session + " ",
+		"tmux select-window -t " + session + ":lazygit",
+		"tmux switch-client -t " + session,
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected %q among:\n%s", want, got)
+		}
+	}
+}
+
+func TestPreviewLaunchPaneSizeAndFocus(t *testing.T) {
+	repo, _ := newTestRepo(t)
+
+	cfg := DefaultConfig()
+	cfg.Windows = []WindowConfig{
+		{Name: "dev", Layout: "main-vertical", Panes: []PaneConfig{
+			{Run: "nvim .", Size: "70%", Focus: true},
+			{Run: "npm test", Size: "20"},
+			{Run: "npm run dev"},
+		}},
+		{Name: "logs", Panes: []PaneConfig{{Run: "tail -f log"}, {Run: "htop", Size: "30%"}}},
+	}
+	m := NewManager(cfg)
+	preview, err := m.PreviewLaunch(repo)
+	if err != nil {
+		t.Fatalf("PreviewLaunch failed: %v", err)
+	}
+	s := preview.Session
+	got := strings.Join(preview.Commands, "\n")
+	for _, want := range []string{
+		"tmux split-window -h -t " + s + ":dev -c " + repo + " -l 20 'npm test'",
+		"tmux split-window -h -t " + s + ":dev -c " + repo + " 'npm run dev'",
+		"tmux select-layout -t " + s + ":dev main-vertical",
+		"tmux resize-pane -t " + s + ":dev.0 -x 70%",
+		"tmux resize-pane -t " + s + ":dev.1 -x 20",
+		"tmux select-pane -t " + s + ":dev.0",
+		"tmux split-window -v -t " + s + ":logs -c " + repo + " -l 30% htop",
+	} {
+		if !strings.Contains(got, want+"\n") && !strings.HasSuffix(got, want) {
+			t.Errorf("missing %q in:\n%s", want, got)
+		}
+	}
+	// A sized window without a layout keeps its split sizes.
+	if strings.Contains(got, "select-layout -t "+s+":logs") || strings.Contains(got, "resize-pane -t "+s+":logs") {
+		t.Fatalf("expected the logs window to keep its split sizes:\n%s", got)
+	}
+}
+
+func TestPreviewLaunchPaneEnv(t *testing.T) {
+	repo, _ := newTestRepo(t)
+
+	cfg := DefaultConfig()
+	cfg.Windows = []WindowConfig{
+		{Name: "dev", Env: map[string]string{"APP": "{repo}", "MODE": "dev"}, Panes: []PaneConfig{
+			{Run: "pnpm dev", Env: map[string]string{"PORT": "{port}", "MODE": "watch"}},
+			{Run: "npm test"},
+		}},
+	}
+	m := NewManager(cfg)
+	preview, err := m.PreviewLaunch(repo)
+	if err != nil {
+		t.Fatalf("PreviewLaunch failed: %v", err)
+	}
+	s := preview.Session
+	port := strconv.Itoa(worktreePort(repo))
+	app := m.RepoName(repo)
+	got := strings.Join(preview.Commands, "\n")
+	for _, want := range []string{
+		"-e APP=" + app + " -e MODE=watch -e PORT=" + port + " 'pnpm dev'",
+		"tmux split-window -v -t " + s + ":dev -c " + repo + " -e APP=" + app + " -e MODE=dev 'npm test'",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in:\n%s", want, got)
+		}
+	}
+	if worktreePort(repo) < paneEnvPortBase || worktreePort(repo) >= paneEnvPortBase+paneEnvPortRange {
+		t.Fatalf("unexpected port %d", worktreePort(repo))
+	}
+}
+
+func TestExecAll(t *testing.T) {
+	repo, run := newTestRepo(t)
+	base := filepath.Dir(repo)
+	linked := filepath.Join(base, "linked")
+	run(repo, "worktree", "add", "-q", "-b", "feat/one", linked)
+
+	m := NewManager(DefaultConfig())
+	results, err := m.Exec(context.Background(), ExecOptions{All: true, Parallel: 2, Command: []string{`basename "$PWD"; test "$(basename "$PWD")" = repo`}})
+	if err != nil {
+		t.Fatalf("Exec failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %+v", results)
+	}
+	byBranch := map[string]ExecResult{}
+	for _, res := range results {
+		byBranch[res.Branch] = res
+	}
+	if res := byBranch["main"]; !res.OK() || res.Output != "repo\n" {
+		t.Fatalf("unexpected main result: %+v", res)
+	}
+	if res := byBranch["feat/one"]; res.OK() || res.ExitCode != 1 || res.Output != "linked\n" {
+		t.Fatalf("unexpected feat/one result: %+v", res)
+	}
+
+	// Several arguments run as one command, not a script; streamed output
+	// of --all is prefixed with the branch.
+	var out bytes.Buffer
+	if _, err := m.Exec(context.Background(), ExecOptions{All: true, Command: []string{"printf", "%s\n%s", "a b", "c"}, Output: &out}); err != nil {
+		t.Fatalf("Exec failed: %v", err)
+	}
+	for _, want := range []string{"main | a b\nmain | c\n", "feat/one | a b\nfeat/one | c\n"} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("missing %q in output:\n%s", want, out.String())
+		}
+	}
+
+	results, err = m.Exec(context.Background(), ExecOptions{Target: "feat/one", Command: []string{"exit 3"}})
+	if err != nil {
+		t.Fatalf("Exec failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Path != linked || results[0].ExitCode != 3 {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+}
+
+func TestCheckRecordsResult(t *testing.T) {
+	repo, run := newTestRepo(t)
+	base := filepath.Dir(repo)
+	linked := filepath.Join(base, "linked")
+	run(repo, "worktree", "add", "-q", "-b", "feat/one", linked)
+
+	cfg := DefaultConfig()
+	m := NewManager(cfg)
+	if _, err := m.Check(context.Background(), ExecOptions{All: true}); err == nil {
+		t.Fatal("expected an error without check_command")
+	}
+	cfg.CheckCommand = `test "$(basename "$PWD")" = repo`
+	m = NewManager(cfg)
+	results, err := m.Check(context.Background(), ExecOptions{All: true})
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %+v", results)
+	}
+
+	checks := m.branchChecks(repo)
+	if checks["main"].Status != checkPass || checks["main"].Stale || checks["feat/one"].Status != checkFail {
+		t.Fatalf("unexpected checks: %+v", checks)
+	}
+	run(linked, "commit", "--allow-empty", "-m", "more")
+	items, err := m.ListWorktrees(context.Background())
+	if err != nil {
+		t.Fatalf("ListWorktrees failed: %v", err)
+	}
+	for _, it := range items {
+		if got, want := formatCheck(it.Check), map[string]string{"main": "pass", "feat/one": "fail*"}[it.Branch]; got != want {
+			t.Fatalf("check of %s = %q, want %q", it.Branch, got, want)
+		}
+	}
+}
+
+func TestRemovalRisk(t *testing.T) {
+	repo, run := newTestRepo(t)
+	base := filepath.Dir(repo)
+	linked := filepath.Join(base, "linked")
+	run(repo, "worktree", "add", "-q", "-b", "feat/one", linked)
+
+	m := NewManager(DefaultConfig())
+	wt := &Worktree{Path: linked, Branch: "feat/one"}
+	if risk := m.RemovalRisk(context.Background(), wt); risk != (RemovalRisk{}) || risk.AtRisk(true) {
+		t.Fatalf("expected no risk for a fresh worktree, got %+v", risk)
+	}
+
+	run(linked, "commit", "--allow-empty", "-m", "one")
+	run(linked, "commit", "--allow-empty", "-m", "two")
+	if err := os.WriteFile(filepath.Join(linked, "stash.txt"), []byte("x\n"), 0o644); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	run(linked, "stash", "push", "-u")
+	if err := os.WriteFile(filepath.Join(linked, "new.txt"), []byte("x\n"), 0o644); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	run(repo, "update-ref", "refs/remotes/origin/feat/one", "feat/one~1")
+
+	risk := m.RemovalRisk(context.Background(), wt)
+	want := RemovalRisk{DirtyFiles: 1, Unpushed: 1, Stashes: 1, Remote: "origin/feat/one"}
+	if risk != want {
+		t.Fatalf("risk = %+v, want %+v", risk, want)
+	}
+	if got := risk.Summary(wt.Branch); got != "1 uncommitted file, 1 unpushed commit, 1 stash entry, on origin/feat/one" {
+		t.Fatalf("unexpected summary %q", got)
+	}
+	risk.DirtyFiles = 0
+	if risk.AtRisk(false) || !risk.AtRisk(true) {
+		t.Fatalf("expected unpushed commits to matter only when deleting the branch: %+v", risk)
+	}
+}
+
+func TestUndoRemove(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	repo, run := newTestRepo(t)
+	write := func(path, content string) {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+	}
+	write(filepath.Join(repo, "a.txt"), "one\n")
+	run(repo, "add", ".")
+	run(repo, "commit", "-m", "add a.txt")
+	linked := filepath.Join(filepath.Dir(repo), "linked")
+	run(repo, "worktree", "add", "-q", "-b", "feat/one", linked)
+	write(filepath.Join(linked, "a.txt"), "two\n")
+	run(linked, "commit", "-am", "two")
+	write(filepath.Join(linked, "a.txt"), "three\n")
+	write(filepath.Join(linked, "new.txt"), "untracked\n")
+
+	m := NewManager(DefaultConfig())
+	if _, err := m.Undo(context.Background()); err == nil {
+		t.Fatal("expected nothing to undo")
+	}
+	if _, warnings, err := m.Remove(context.Background(), RemoveOptions{Target: linked, Force: true, DeleteBranch: true}); err != nil || len(warnings) > 0 {
+		t.Fatalf("Remove failed: %v %v", err, warnings)
+	}
+	if m.BranchExists(repo, "feat/one") {
+		t.Fatal("expected the branch to be deleted")
+	}
+
+	rec, err := m.Undo(context.Background())
+	if err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+	if rec.Path != linked || rec.Branch != "feat/one" || !rec.BranchDeleted || rec.Files == "" {
+		t.Fatalf("unexpected record: %+v", rec)
+	}
+	for name, want := range map[string]string{"a.txt": "three\n", "new.txt": "untracked\n"} {
+		data, err := os.ReadFile(filepath.Join(linked, name))
+		if err != nil || string(data) != want {
+			t.Fatalf("%s = %q (%v), want %q", name, data, err, want)
+		}
+	}
+	if out, err := m.runCmdOutput(linked, "git", "log", "-1", "--format=%s"); err != nil || out != "two" {
+		t.Fatalf("expected the branch back at its commit, got %q (%v)", out, err)
+	}
+	if _, err := m.Undo(context.Background()); err == nil {
+		t.Fatal("expected a second undo to have nothing to restore")
+	}
+
+	// With undo_minutes = 0 nothing is kept.
+	cfg := DefaultConfig()
+	cfg.UndoMinutes = 0
+	m = NewManager(cfg)
+	if _, _, err := m.Remove(context.Background(), RemoveOptions{Target: linked, Force: true}); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if rec, err := m.LastRemoved(); err != nil || rec != nil {
+		t.Fatalf("expected no undo record, got %+v (%v)", rec, err)
+	}
+}
+
+func TestPreviewLaunchPerRepoSession(t *testing.T) {
+	repo, run := newTestRepo(t)
+	branch := run(repo, "rev-parse", "--abbrev-ref", "HEAD")
+
+	cfg := DefaultConfig()
+	cfg.SessionMode = sessionModePerRepo
+	cfg.Windows = []WindowConfig{{Name: "editor"}, {Name: "shell"}}
+	m := NewManager(cfg)
+	preview, err := m.PreviewLaunch(repo)
+	if err != nil {
+		t.Fatalf("PreviewLaunch failed: %v", err)
+	}
+	s := m.tmuxSessionName(repo)
+	if preview.Session != s {
+		t.Fatalf("session = %q, want the repository's %q", preview.Session, s)
+	}
+	editor := sessionToken(branch) + "-editor"
+	shell := sessionToken(branch) + "-shell"
+	want := []string{
+		"tmux new-session -d -s " + s + " -n " + editor + " -c " + repo + " " + defaultShellCommand(),
+		"tmux set-option -w -t " + s + ":" + editor + " @sprout_worktree " + repo,
+		"tmux new-window -d -t " + s + " -n " + shell + " -c " + repo + " " + defaultShellCommand(),
+		"tmux set-option -w -t " + s + ":" + shell + " @sprout_worktree " + repo,
+	}
+	if !reflect.DeepEqual(preview.Commands, want) {
+		t.Fatalf("unexpected commands:\n%s\nwant:\n%s", strings.Join(preview.Commands, "\n"), strings.Join(want, "\n"))
+	}
+	if preview.Window != editor {
+		t.Fatalf("window = %q, want %q", preview.Window, editor)
+	}
+}
+
+func TestPerfTimings(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	if err := resetPerf(); err != nil {
+		t.Fatalf("reset perf: %v", err)
+	}
+
+	if got := perfCommandKey("git", []string{"-C", "/repo", "-c", "core.quotepath=off", "status", "--porcelain"}); got != "git status" {
+		t.Fatalf("perfCommandKey = %q, want git status", got)
+	}
+	var h PerfHistogram
+	for _, ms := range []int64{5, 5, 5, 5, 5, 5, 5, 5, 5, 700} {
+		h.add(ms)
+	}
+	if h.Count != 10 || h.MaxMS != 700 || h.percentileMS(0.5) != 10 || h.percentileMS(0.99) != 700 {
+		t.Fatalf("unexpected histogram: %+v p50=%d p99=%d", h, h.percentileMS(0.5), h.percentileMS(0.99))
+	}
+
+	recordCmdTiming("/repo", "git", []string{"fetch", "origin"}, 1500*time.Millisecond, false)
+	recordCmdTiming("/repo", "git", []string{"status"}, 3*time.Millisecond, false)
+	recordCmdTiming("/other", "tmux", []string{"list-panes", "-a"}, 200*time.Millisecond, true)
+	timeOperation("list_worktrees")()
+	flushPerf()
+	recordCmdTiming("/repo/sub", "git", []string{"status"}, 120*time.Millisecond, false)
+
+	report, err := loadPerfReport(PerfReportOptions{Dirs: []string{"/repo"}})
+	if err != nil {
+		t.Fatalf("load perf report: %v", err)
+	}
+	if len(report.Commands) != 3 || report.Commands[0].Name != "git fetch" {
+		t.Fatalf("unexpected commands: %+v", report.Commands)
+	}
+	for _, st := range report.Commands {
+		if st.Name == "git status" && st.Count != 2 {
+			t.Fatalf("expected the unflushed git status to be counted, got %+v", st)
+		}
+	}
+	if len(report.Operations) != 1 || report.Operations[0].Name != "list_worktrees" {
+		t.Fatalf("unexpected operations: %+v", report.Operations)
+	}
+	if len(report.Slowest) != 2 || report.Slowest[0].Command != "git fetch origin" || report.Slowest[1].Dir != "/repo/sub" {
+		t.Fatalf("unexpected slowest: %+v", report.Slowest)
+	}
+}
+
+func TestQueryCache(t *testing.T) {
+	repo, run := newTestRepo(t)
+	run(repo, "branch", "feat/a")
+
+	m := NewManager(DefaultConfig())
+	names := func() string {
+		branches, err := m.ListBranches(repo)
+		if err != nil {
+			t.Fatalf("list branches: %v", err)
+		}
+		var out []string
+		for _, b := range branches {
+			out = append(out, b.Name)
+		}
+		return strings.Join(out, ",")
+	}
+	if got := names(); got != "feat/a" {
+		t.Fatalf("branches = %q, want feat/a", got)
+	}
+	run(repo, "branch", "feat/b")
+	if got := names(); got != "feat/a" {
+		t.Fatalf("expected the cached branches, got %q", got)
+	}
+	m.InvalidateQueries(repo)
+	if got := names(); got != "feat/a,feat/b" {
+		t.Fatalf("branches after invalidation = %q, want feat/a,feat/b", got)
+	}
+
+	stamp := m.headStamp(repo)
+	if stamp == "" {
+		t.Fatalf("expected a HEAD stamp for %s", repo)
+	}
+	run(repo, "commit", "--allow-empty", "-m", "second")
+	if m.headStamp(repo) == stamp {
+		t.Fatalf("expected the HEAD stamp to change with a commit")
+	}
+
+	ahead, behind := m.cachedAheadBehind(repo, "main", "feat/a", m.branchTips(repo))
+	if ahead != 0 || behind != 1 {
+		t.Fatalf("ahead/behind = %d/%d, want 0/1", ahead, behind)
+	}
+	run(repo, "checkout", "-q", "feat/a")
+	run(repo, "commit", "--allow-empty", "-m", "feature")
+	run(repo, "checkout", "-q", "main")
+	ahead, behind = m.cachedAheadBehind(repo, "main", "feat/a", m.branchTips(repo))
+	if ahead != 1 || behind != 1 {
+		t.Fatalf("ahead/behind after a commit = %d/%d, want 1/1", ahead, behind)
+	}
+}
+
+func TestListedWorktreeDirty(t *testing.T) {
+	repo, run := newTestRepo(t)
+	if err := os.WriteFile(filepath.Join(repo, "tracked.txt"), []byte("one\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	run(repo, "add", "tracked.txt")
+	run(repo, "commit", "-m", "init")
+
+	ctx := context.Background()
+	cfg := DefaultConfig()
+	m := NewManager(cfg)
+	if m.listedWorktreeDirty(ctx, repo, repo) {
+		t.Fatalf("expected a clean worktree")
+	}
+	if err := os.WriteFile(filepath.Join(repo, "new.txt"), []byte("new\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if m.listedWorktreeDirty(ctx, repo, repo) {
+		t.Fatalf("expected the untracked scan to wait for dirty_untracked's interval")
+	}
+	m.InvalidateQueries(repo)
+	if !m.listedWorktreeDirty(ctx, repo, repo) {
+		t.Fatalf("expected the untracked file once rescanned")
+	}
+
+	cfg.DirtyUntracked = dirtyUntrackedNo
+	if NewManager(cfg).listedWorktreeDirty(ctx, repo, repo) {
+		t.Fatalf("expected dirty_untracked = no to leave untracked files out")
+	}
+	cfg.DirtyUntracked = dirtyUntrackedAll
+	if !NewManager(cfg).listedWorktreeDirty(ctx, repo, repo) {
+		t.Fatalf("expected dirty_untracked = all to count untracked files")
+	}
+
+	cfg.DirtyUntracked = dirtyUntrackedNo
+	if err := os.WriteFile(filepath.Join(repo, "tracked.txt"), []byte("two\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if !NewManager(cfg).listedWorktreeDirty(ctx, repo, repo) {
+		t.Fatalf("expected a changed tracked file to make the worktree dirty")
+	}
+	if _, err := parseDirtyUntracked("sometimes"); err == nil {
+		t.Fatalf("expected an invalid dirty_untracked to fail")
+	}
+}
+
+func TestFetch(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	origin, run := newTestRepo(t)
+	run(origin, "branch", "gone")
+	repo := filepath.Join(filepath.Dir(origin), "clone")
+	run(origin, "clone", "-q", origin, repo)
+	run(origin, "branch", "-D", "gone")
+	run(origin, "branch", "fresh")
+	// newTestRepo changes back to the starting directory when the test ends.
+	if err := os.Chdir(repo); err != nil {
+		t.Fatalf("chdir failed: %v", err)
+	}
+
+	cfg := DefaultConfig()
+	cfg.AutoFetchMinutes = 10
+	m := NewManager(cfg)
+	ctx := context.Background()
+	res, err := m.Fetch(ctx)
+	if err != nil {
+		t.Fatalf("fetch failed: %v", err)
+	}
+	if !samePath(res.Repo, repo) || res.Skipped {
+		t.Fatalf("unexpected fetch result: %+v", res)
+	}
+	refs, err := m.runCmdOutput(repo, "git", "for-each-ref", "--format=%(refname:short)", "refs/remotes")
+	if err != nil {
+		t.Fatalf("for-each-ref failed: %v", err)
+	}
+	if !strings.Contains(refs, "origin/fresh") || strings.Contains(refs, "origin/gone") {
+		t.Fatalf("expected fetch to add origin/fresh and prune origin/gone, got:\n%s", refs)
+	}
+	if last := lastFetch(res.Repo); !last.Equal(res.FetchedAt) {
+		t.Fatalf("expected the fetch to be recorded at %v, got %v", res.FetchedAt, last)
+	}
+
+	res, err = m.AutoFetch(ctx)
+	if err != nil || !res.Skipped {
+		t.Fatalf("expected auto-fetch to skip a repository fetched just now, got %+v, %v", res, err)
+	}
+	now := time.Now()
+	if fetchDue(now.Add(-5*time.Minute), 10*time.Minute, now) || !fetchDue(now.Add(-10*time.Minute), 10*time.Minute, now) {
+		t.Fatalf("unexpected fetchDue at the auto_fetch_minutes boundary")
+	}
+	if fetchDue(time.Time{}, 0, now) {
+		t.Fatalf("expected auto_fetch_minutes = 0 to never fetch")
+	}
+}
+
+func TestRunHook(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("SPROUT_DEBUG_LOG", filepath.Join(dir, "sprout-debug.log"))
+	worktree := filepath.Join(dir, "feature")
+	if err := os.MkdirAll(worktree, 0o755); err != nil {
+		t.Fatalf("mkdir worktree failed: %v", err)
+	}
+	out := filepath.Join(dir, "hook.out")
+
+	cfg := DefaultConfig()
+	cfg.Hooks = map[string]string{hookOnCreate: `echo "$SPROUT_HOOK $SPROUT_EVENT $SPROUT_REPO $SPROUT_BRANCH $SPROUT_WORKTREE $PWD" > ` + out + `; echo logged`}
+	m := NewManager(cfg)
+	m.runEventHook(Event{Type: eventWorktreeRemoved, Repo: "app", RepoRoot: dir, Branch: "feature", Path: worktree})
+	m.runEventHook(Event{Type: eventWorktreeCreated, Repo: "app", RepoRoot: dir, Branch: "feature", Path: worktree})
+
+	var got []byte
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		data, err := os.ReadFile(out)
+		if err == nil && strings.HasSuffix(string(data), "\n") {
+			got = data
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	want := fmt.Sprintf("on_create worktree_created app feature %s %s\n", worktree, worktree)
+	if string(got) != want {
+		t.Fatalf("expected hook output %q, got %q", want, got)
+	}
+	for time.Now().Before(deadline) {
+		if data, _ := os.ReadFile(hookLogPath()); strings.Contains(string(data), "logged") {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("expected the hook's output in %s", hookLogPath())
+}
+
+func TestHookShell(t *testing.T) {
+	command := `echo "$SPROUT_BRANCH" > out.txt`
+	if got, want := hookShell("linux", command), []string{"sh", "-c", command}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+	if got, want := hookShell("windows", command), []string{"cmd", "/C", command}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+func TestLabels(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	repo, _ := newTestRepo(t)
+
+	m := NewManager(DefaultConfig())
+	for _, branch := range []string{"feat/a", "feat/b"} {
+		if _, _, err := m.NewWorktree(context.Background(), NewOptions{Branch: branch, SkipCopyUntracked: true}); err != nil {
+			t.Fatalf("NewWorktree %s failed: %v", branch, err)
+		}
+	}
+	if _, labels, err := m.AddLabels("feat/a", []string{"Experiment", "ui", "experiment"}); err != nil || !reflect.DeepEqual(labels, []string{"experiment", "ui"}) {
+		t.Fatalf("AddLabels returned %v, %v", labels, err)
+	}
+	if _, _, err := m.AddLabels("feat/b", []string{"ui"}); err != nil {
+		t.Fatalf("AddLabels failed: %v", err)
+	}
+	if _, _, err := m.AddLabels("feat/b", []string{"not a label"}); err == nil {
+		t.Fatalf("expected an invalid label to be rejected")
+	}
+
+	items, err := m.ListWorktrees(context.Background())
+	if err != nil {
+		t.Fatalf("ListWorktrees failed: %v", err)
+	}
+	labeled := map[string][]string{}
+	for _, it := range items {
+		labeled[it.Branch] = it.Labels
+	}
+	if !reflect.DeepEqual(labeled["feat/a"], []string{"experiment", "ui"}) || !reflect.DeepEqual(labeled["feat/b"], []string{"ui"}) {
+		t.Fatalf("unexpected labels: %v", labeled)
+	}
+
+	filter := parseWorktreeFilter("feat label:experiment LABEL:ui")
+	if filter.Query != "feat" || !reflect.DeepEqual(filter.Labels, []string{"experiment", "ui"}) {
+		t.Fatalf("unexpected filter: %+v", filter)
+	}
+	if !filter.matches(Worktree{Labels: labeled["feat/a"]}, "") || filter.matches(Worktree{Labels: labeled["feat/b"]}, "") {
+		t.Fatalf("expected only feat/a to carry both labels")
+	}
+
+	if _, labels, err := m.RemoveLabels("feat/a", []string{"ui"}); err != nil || !reflect.DeepEqual(labels, []string{"experiment"}) {
+		t.Fatalf("RemoveLabels returned %v, %v", labels, err)
+	}
+	if _, labels, err := m.RemoveLabels("feat/a", nil); err != nil || len(labels) != 0 {
+		t.Fatalf("clearing labels returned %v, %v", labels, err)
+	}
+	if got := m.branchLabels(repo); len(got["feat/a"]) != 0 {
+		t.Fatalf("expected feat/a labels to be cleared, got %v", got)
+	}
+}
+
+func TestWorktreeFilter(t *testing.T) {
+	items := []Worktree{
+		{Branch: "feat/login", Path: "/wt/feat/login", Dirty: true, AgentState: "yes", Priority: priorityHigh},
+		{Branch: "fix/crash", Path: "/wt/fix/crash", AgentState: "crashed"},
+		{Branch: "chore/deps", Path: "/wt/chore/deps", RebaseState: rebaseStateRunning, Unfinished: true, AgentState: "no"},
+		{Branch: "feat/merge", Path: "/wt/feat/merge", Unfinished: true, AgentState: "no"},
+	}
+	statuses := map[string]string{"/wt/feat/login": agentStatusReady}
+	cases := []struct {
+		filter string
+		want   []string
+	}{
+		{"", []string{"feat/login", "fix/crash", "chore/deps", "feat/merge"}},
+		{"status:dirty", []string{"feat/login", "chore/deps", "feat/merge"}},
+		{"status:clean status:rebasing", []string{"fix/crash", "chore/deps"}},
+		{"agent:ready", []string{"feat/login"}},
+		{"agent:busy", nil},
+		{"agent:crashed", []string{"fix/crash"}},
+		{"agent:none", []string{"chore/deps", "feat/merge"}},
+		{"agent:running", []string{"feat/login"}},
+		{"priority:h", []string{"feat/login"}},
+		{"priority:normal fix", []string{"fix/crash"}},
+		{"deps", []string{"chore/deps"}},
+	}
+	for _, tc := range cases {
+		filter := parseWorktreeFilter(tc.filter)
+		var got []string
+		for _, item := range items {
+			if filter.matches(item, statuses[item.Path]) && filter.queryMatches(item) {
+				got = append(got, item.Branch)
+			}
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("filter %q kept %v, want %v", tc.filter, got, tc.want)
+		}
+	}
+	if !parseWorktreeFilter("agent:ready").needsAgentStatus() || parseWorktreeFilter("agent:crashed status:dirty").needsAgentStatus() {
+		t.Fatalf("expected only ready, busy, and exited agent terms to need the agent status")
+	}
+
+	presets := map[string]string{"dirty": "status:dirty", "off": ""}
+	if got := resolveFilter(presets, "Dirty"); got != "status:dirty" {
+		t.Fatalf("expected the dirty preset to resolve, got %q", got)
+	}
+	if got := resolveFilter(presets, "off"); got != "off" {
+		t.Fatalf("expected an emptied preset to be a plain query, got %q", got)
+	}
+	if got := filterPresetFor(presets, " status:dirty "); got != "dirty" {
+		t.Fatalf("expected status:dirty to be the dirty preset, got %q", got)
+	}
+}
+
+func TestWorktreeFilterUnfinishedRebase(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	repo, run := newTestRepo(t)
+	for _, content := range []string{"one\n", "two\n"} {
+		if err := os.WriteFile(filepath.Join(repo, "a.txt"), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		run(repo, "add", "a.txt")
+		run(repo, "commit", "-m", strings.TrimSpace(content))
+	}
+	// Stop the rebase at its only commit, with nothing left to commit.
+	run(repo, "-c", "sequence.editor=sed -i.bak s/^pick/edit/", "rebase", "-i", "HEAD~1")
+
+	items, err := NewManager(DefaultConfig()).ListWorktrees(context.Background())
+	if err != nil {
+		t.Fatalf("ListWorktrees failed: %v", err)
+	}
+	if len(items) != 1 || items[0].Dirty || !items[0].Unfinished {
+		t.Fatalf("expected a clean worktree with an unfinished rebase, got %+v", items)
+	}
+	if !parseWorktreeFilter("status:dirty").matches(items[0], "") {
+		t.Fatal("expected status:dirty to keep a worktree stopped mid-rebase")
+	}
+}
+
+func TestWrapANSI(t *testing.T) {
+	cases := []struct {
+		name  string
+		text  string
+		width int
+		want  string
+	}{
+		{"short", "abc\nde", 5, "abc\nde"},
+		{"exact", "abcde\nf", 5, "abcde\nf"},
+		{"long", "abcdefgh", 3, "abc\ndef\ngh"},
+		{"escapes take no room", "\x1b[31mabcd\x1b[0m", 2, "\x1b[31mab\ncd\x1b[0m"},
+		{"wide rune moves whole", "ab世界", 3, "ab\n世\n界"},
+		{"tab expands", "a\tb", 10, "a       b"},
+		{"tab fills the line", "abcdef\tg", 7, "abcdef \ng"},
+		{"no width", "abcdef", 0, "abcdef"},
+	}
+	for _, tc := range cases {
+		if got := wrapANSI(tc.text, tc.width); got != tc.want {
+			t.Fatalf("%s: wrapANSI(%q, %d) = %q, want %q", tc.name, tc.text, tc.width, got, tc.want)
+		}
+	}
+}
+
+func TestHighlightDiff(t *testing.T) {
+	diff := strings.Join([]string{
+		"diff --git a/x.go b/x.go",
+		"--- a/x.go",
+		"+++ b/x.go",
+		"@@ -1,3 +1,3 @@ func main() {",
+		" keep",
+		"-old := compute(1)",
+		"+old := compute(2)",
+		"-gone",
+		"\\ No newline at end of file",
+	}, "\n")
+	got := strings.Split(highlightDiff(diff), "\n")
+	want := []string{
+		diffStyleMeta + "diff --git a/x.go b/x.go" + diffStyleReset,
+		diffStyleMeta + "--- a/x.go" + diffStyleReset,
+		diffStyleMeta + "+++ b/x.go" + diffStyleReset,
+		diffStyleHunk + "@@ -1,3 +1,3 @@" + diffStyleReset + " func main() {",
+		" keep",
+		diffStyleRemoved + "-old := compute(" + diffStyleEmph + "1" + diffStyleReset + diffStyleRemoved + ")" + diffStyleReset,
+		diffStyleAdded + "+old := compute(" + diffStyleEmph + "2" + diffStyleReset + diffStyleAdded + ")" + diffStyleReset,
+		diffStyleRemoved + "-gone" + diffStyleReset,
+		diffStyleNote + "\\ No newline at end of file" + diffStyleReset,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d lines, got %d: %q", len(want), len(got), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("line %d: expected %q, got %q", i, want[i], got[i])
+		}
+	}
+
+	// Lines that share little are colored without emphasis.
+	rewrite := highlightDiff("@@ -1 +1 @@\n-alpha\n+zzzzzz")
+	if strings.Contains(rewrite, diffStyleEmph) {
+		t.Fatalf("expected no intraline emphasis for a rewrite, got %q", rewrite)
+	}
+}
+
+func TestWorktreeFilePreview(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir, run := newTestRepo(t)
+	if err := os.WriteFile(filepath.Join(dir, "gone.txt"), []byte("bye\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	run(dir, "add", "gone.txt")
+	run(dir, "commit", "-m", "add gone.txt")
+	if err := os.Remove(filepath.Join(dir, "gone.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("first\nsecond\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var logo bytes.Buffer
+	if err := png.Encode(&logo, image.NewRGBA(image.Rect(0, 0, 3, 2))); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "logo.png"), logo.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	run(dir, "add", "logo.png")
+	m := NewManager(DefaultConfig())
+	files, err := m.WorktreeDiffFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := map[string]string{}
+	for _, file := range files {
+		status[file.Path] = file.Status
+	}
+	if want := map[string]string{"notes.txt": "??", "logo.png": "A ", "gone.txt": " D"}; !reflect.DeepEqual(status, want) {
+		t.Fatalf("expected changed files %v, got %v", want, status)
+	}
+
+	text, err := m.WorktreeFilePreview(dir, DiffFile{Path: "notes.txt", Status: status["notes.txt"]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := stripANSI(text)
+	for _, want := range []string{"# notes.txt (??)", "text/plain; charset=utf-8", "13 bytes", "1 first", "2 second"} {
+		if !strings.Contains(plain, want) {
+			t.Fatalf("expected preview to contain %q, got:\n%s", want, plain)
+		}
+	}
+
+	binary, err := m.WorktreeFilePreview(dir, DiffFile{Path: "logo.png", Status: status["logo.png"]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain = stripANSI(binary)
+	for _, want := range []string{"image/png", "3x2 png"} {
+		if !strings.Contains(plain, want) {
+			t.Fatalf("expected preview to contain %q, got:\n%s", want, plain)
+		}
+	}
+	if strings.Contains(plain, "IHDR") {
+		t.Fatalf("expected no content for a binary file, got:\n%s", plain)
+	}
+
+	gone, err := m.WorktreeFilePreview(dir, DiffFile{Path: "gone.txt", Status: status["gone.txt"]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(gone, "deleted") {
+		t.Fatalf("expected a deleted file note, got %q", gone)
+	}
+}
+
+func TestDiscardFileChanges(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	repo, run := newTestRepo(t)
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(repo, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("edited.txt", "original\n")
+	write("staged.txt", "original\n")
+	write("renamed.txt", "original\n")
+	run(repo, "add", ".")
+	run(repo, "commit", "-m", "add edited.txt, staged.txt, and renamed.txt")
+
+	write("edited.txt", "agent edit\n")
+	write("staged.txt", "agent edit\n")
+	write("added.txt", "new\n")
+	write("untracked.txt", "new\n")
+	run(repo, "add", "staged.txt", "added.txt")
+	run(repo, "mv", "renamed.txt", "moved.txt")
+
+	m := NewManager(DefaultConfig())
+	files, err := m.WorktreeDiffFiles(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 5 {
+		t.Fatalf("expected 5 changed files, got %+v", files)
+	}
+	for _, file := range files {
+		if err := m.DiscardFileChanges(repo, file); err != nil {
+			t.Fatalf("discard %s (%q) failed: %v", file.Path, file.Status, err)
+		}
+	}
+	if files, err := m.WorktreeDiffFiles(repo); err != nil || len(files) != 0 {
+		t.Fatalf("expected a clean worktree, got %+v, %v", files, err)
+	}
+	for _, name := range []string{"edited.txt", "staged.txt", "renamed.txt"} {
+		if data, _ := os.ReadFile(filepath.Join(repo, name)); string(data) != "original\n" {
+			t.Fatalf("expected %s to be restored, got %q", name, data)
+		}
+	}
+	for _, name := range []string{"added.txt", "untracked.txt", "moved.txt"} {
+		if _, err := os.Stat(filepath.Join(repo, name)); !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("expected %s to be deleted, got %v", name, err)
+		}
+	}
+
+	if err := m.DiscardFileChanges(repo, DiffFile{Path: "edited.txt", Status: "UU"}); err == nil {
+		t.Fatal("expected a conflicted file to be refused")
+	}
+}
+
+func TestDetailBrowseKeyExportsAgentOutput(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	repo, _ := newTestRepo(t)
+
+	u := newTUI(NewManager(DefaultConfig()), repo)
+	u.items = []Worktree{{Path: repo, Branch: "main", AgentState: "no"}}
+	u.visible = []int{0}
+	u.detailTab = detailTabAgent
+	u.handleDetailBrowseKey(tcell.NewEventKey(tcell.KeyRune, 'e', tcell.ModNone))
+	if u.footerLevel != "WARN" || u.footerMsg != "no agent running" {
+		t.Fatalf("expected e to export the agent output, got %s: %s", u.footerLevel, u.footerMsg)
+	}
+}
+
+func TestRefreshWhileTogglingConfig(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	repo, _ := newTestRepo(t)
+
+	u := newTUI(NewManager(DefaultConfig()), repo)
+	screen := tcell.NewSimulationScreen("")
+	ran := make(chan error, 1)
+	go func() {
+		ran <- u.app.SetScreen(screen).SetRoot(u.pages, true).Run()
+	}()
+	stop := sync.OnceFunc(func() {
+		u.app.Stop()
+		<-ran
+	})
+	t.Cleanup(stop)
+
+	refreshed := make(chan error, 1)
+	u.app.QueueUpdate(func() {
+		u.refresh(func(err error) {
+			refreshed <- err
+		})
+	})
+	// Keep toggling panes on the UI goroutine, while background checks read
+	// the manager's config, until the rows are in.
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case err := <-refreshed:
+			if err != nil {
+				t.Fatalf("refresh failed: %v", err)
+			}
+		case <-timeout:
+			t.Fatal("refresh did not finish")
+		case <-time.After(time.Millisecond):
+			u.app.QueueUpdate(func() {
+				u.startUpdateCheck()
+				u.resizeDetails(5)
+				u.resizeDetails(-5)
+			})
+			continue
+		}
+		break
+	}
+	stop()
+	if len(u.items) != 1 || u.items[0].Path != repo {
+		t.Fatalf("unexpected rows after refresh: %+v", u.items)
+	}
+
+	if got := u.mgr.Cfg.DetailsPercent; got != DefaultConfig().DetailsPercent {
+		t.Fatalf("expected resizing to leave the manager's config alone, got details_percent %d", got)
+	}
+	if data, _ := os.ReadFile(globalConfigPath()); !strings.Contains(string(data), "details_percent = ") {
+		t.Fatalf("expected details_percent to be saved, got %q", data)
+	}
+}
+
+func TestRepoSidebar(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	repo, run := newTestRepo(t)
+	other := filepath.Join(filepath.Dir(repo), "other")
+	run(filepath.Dir(repo), "init", "-q", "-b", "main", other)
+	run(other, "-c", "user.email=sprout-test@example.com", "-c", "user.name=Sprout Test", "commit", "--allow-empty", "-m", "init")
+	run(other, "worktree", "add", "-q", "-b", "feat/x", filepath.Join(t.TempDir(), "x"))
+
+	m := NewManager(DefaultConfig())
+	if worktrees, ready := m.repoAgentCounts(other); worktrees != 2 || ready != 0 {
+		t.Fatalf("expected 2 worktrees and no ready agents in %s, got %d and %d", other, worktrees, ready)
+	}
+
+	u := newTUI(m, repo)
+	ran := make(chan error, 1)
+	go func() {
+		ran <- u.app.SetScreen(tcell.NewSimulationScreen("")).SetRoot(u.pages, true).Run()
+	}()
+	stop := sync.OnceFunc(func() {
+		u.app.Stop()
+		<-ran
+	})
+	t.Cleanup(stop)
+	// Showing the sidebar refreshes to count the other repositories.
+	refreshed := make(chan error, 1)
+	u.app.QueueUpdate(func() {
+		u.toggleRepoSidebar()
+		u.refreshWaiters = append(u.refreshWaiters, func(err error) {
+			refreshed <- err
+		})
+	})
+	select {
+	case err := <-refreshed:
+		if err != nil {
+			t.Fatalf("refresh failed: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("refresh did not finish")
+	}
+	stop()
+
+	if !u.showRepoSidebar {
+		t.Fatal("expected the repo sidebar to be shown")
+	}
+	if u.mgr.Cfg.RepoSidebar {
+		t.Fatal("expected showing the sidebar to leave the manager's config alone")
+	}
+	if data, _ := os.ReadFile(globalConfigPath()); !strings.Contains(string(data), "repo_sidebar = true") {
+		t.Fatalf("expected repo_sidebar to be saved, got %q", data)
+	}
+
+	repos := loadRepoChoices(repo)
+	for i := range repos {
+		if repos[i].Root == other {
+			repos[i].Worktrees, repos[i].Ready = m.repoAgentCounts(other)
+		}
+	}
+	u.setRepoChoices(repos)
+	rows := map[string]string{}
+	for row := 0; row < u.repoSidebar.GetRowCount(); row++ {
+		rows[u.repoSidebar.GetCell(row, 1).Text] = u.repoSidebar.GetCell(row, 0).Text + u.repoSidebar.GetCell(row, 2).Text
+	}
+	if want := map[string]string{"repo": "*1", "other": " 2"}; !reflect.DeepEqual(rows, want) {
+		t.Fatalf("unexpected sidebar rows %v, want %v", rows, want)
+	}
+}
+
+func TestSwitchRepoLoadsItsConfig(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	repo, run := newTestRepo(t)
+	other := filepath.Join(filepath.Dir(repo), "other")
+	run(filepath.Dir(repo), "init", "-q", "-b", "main", other)
+	run(other, "-c", "user.email=sprout-test@example.com", "-c", "user.name=Sprout Test", "commit", "--allow-empty", "-m", "init")
+	if err := os.MkdirAll(filepath.Dir(globalConfigPath()), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(globalConfigPath(), []byte("[repos.other]\nbase_branch = \"develop\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(other, ".sprout.toml"), []byte("undo_minutes = 7\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewManager(DefaultConfig())
+	u := newTUI(m, repo)
+	ran := make(chan error, 1)
+	go func() {
+		ran <- u.app.SetScreen(tcell.NewSimulationScreen("")).SetRoot(u.pages, true).Run()
+	}()
+	stop := sync.OnceFunc(func() {
+		u.app.Stop()
+		<-ran
+	})
+	t.Cleanup(stop)
+	refreshed := make(chan error, 1)
+	u.app.QueueUpdate(func() {
+		u.switchRepo(repoChoice{Name: "other", Root: other})
+		u.refreshWaiters = append(u.refreshWaiters, func(err error) {
+			refreshed <- err
+		})
+	})
+	select {
+	case err := <-refreshed:
+		if err != nil {
+			t.Fatalf("refresh failed: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("refresh did not finish")
+	}
+	stop()
+
+	if u.repoRoot != other {
+		t.Fatalf("expected to switch to %s, got %s", other, u.repoRoot)
+	}
+	if got := u.mgr.Cfg.BaseBranch; got != "develop" {
+		t.Fatalf("expected base_branch from [repos.other], got %q", got)
+	}
+	if got := u.mgr.Cfg.UndoMinutes; got != 7 {
+		t.Fatalf("expected undo_minutes from other's .sprout.toml, got %d", got)
+	}
+	if m.Cfg.BaseBranch != DefaultConfig().BaseBranch || m.Cfg.UndoMinutes != DefaultConfig().UndoMinutes {
+		t.Fatalf("expected the first repository's manager to keep its config, got base_branch %q and undo_minutes %d", m.Cfg.BaseBranch, m.Cfg.UndoMinutes)
+	}
+	if u.manager() != u.mgr {
+		t.Fatal("expected background loops to get the switched repository's manager")
+	}
+}
@@ -0,0 +1,467 @@
+package sprout
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// mcpProtocolVersions are the Model Context Protocol revisions the server
+// speaks, newest first.
+var mcpProtocolVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+const (
+	mcpAgentOutputLines = 100
+
+	// JSON-RPC error codes.
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+)
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// mcpTool is a tool the server offers. call decodes its own arguments and
+// returns a value that is sent back as JSON text; it stops once ctx is done.
+type mcpTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"inputSchema"`
+	call        func(ctx context.Context, m *Manager, args json.RawMessage) (any, error)
+}
+
+func mcpSchema(required []string, props map[string]any) map[string]any {
+	schema := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func mcpString(description string) map[string]any {
+	return map[string]any{"type": "string", "description": description}
+}
+
+func mcpBool(description string) map[string]any {
+	return map[string]any{"type": "boolean", "description": description}
+}
+
+// errInvalidArguments is wrapped by the errors of tool calls whose arguments
+// do not fit the tool.
+var errInvalidArguments = errors.New("invalid arguments")
+
+const mcpTargetDescription = "Worktree branch, directory name, or path"
+
+var mcpTools = []mcpTool{
+	{
+		Name:        "list_worktrees",
+		Description: "List the worktrees of the repository with their branch, path, dirty state, tmux session, and agent state.",
+		InputSchema: mcpSchema(nil, map[string]any{}),
+		call: func(ctx context.Context, m *Manager, _ json.RawMessage) (any, error) {
+			return m.ListWorktrees(ctx)
+		},
+	},
+	{
+		Name:        "create_worktree",
+		Description: "Create a branch and worktree. Pass branch for an exact branch name, type and name to build one like feat/<slug>, from_branch to check out an existing branch, or from_pr to fetch a GitHub pull request.",
+		InputSchema: mcpSchema(nil, map[string]any{
+			"branch":           mcpString("Full name of the new branch"),
+			"type":             mcpString("Branch type prefix, such as feat or fix"),
+			"name":             mcpString("Feature name, turned into a slug"),
+			"base":             mcpString("Base branch to create from (default: base_branch)"),
+			"from_branch":      mcpString("Existing branch to create the worktree from"),
+			"from_pr":          map[string]any{"type": "integer", "description": "GitHub pull request number to fetch from origin and create the worktree from"},
+			"start_agent":      mcpBool("Start the agent in the new worktree"),
+			"agent_type":       mcpString("Agent type to start instead of the default"),
+			"ignore_wip_limit": mcpBool("Create even when the WIP limit is reached"),
+		}),
+		call: mcpCreateWorktree,
+	},
+	{
+		Name:        "remove_worktree",
+		Description: "Remove a worktree and its tmux session. Refuses dirty or locked worktrees unless force is set.",
+		InputSchema: mcpSchema([]string{"target"}, map[string]any{
+			"target":        mcpString(mcpTargetDescription),
+			"force":         mcpBool("Remove even with uncommitted changes or a lock"),
+			"delete_branch": mcpBool("Also delete the worktree's branch"),
+		}),
+		call: func(ctx context.Context, m *Manager, raw json.RawMessage) (any, error) {
+			var args struct {
+				Target       string `json:"target"`
+				Force        bool   `json:"force"`
+				DeleteBranch bool   `json:"delete_branch"`
+			}
+			if err := decodeMCPArgs(raw, &args); err != nil {
+				return nil, err
+			}
+			path, warnings, err := m.Remove(ctx, RemoveOptions{Target: args.Target, Force: args.Force, DeleteBranch: args.DeleteBranch})
+			if err != nil {
+				return nil, err
+			}
+			return map[string]any{"path": path, "warnings": warnings}, nil
+		},
+	},
+	{
+		Name:        "start_agent",
+		Description: "Start the agent of a worktree in its tmux session, unless it is already running.",
+		InputSchema: mcpSchema([]string{"target"}, map[string]any{
+			"target":     mcpString(mcpTargetDescription),
+			"agent_type": mcpString("Agent type to start instead of the default"),
+		}),
+		call: func(ctx context.Context, m *Manager, raw json.RawMessage) (any, error) {
+			var args struct {
+				Target    string `json:"target"`
+				AgentType string `json:"agent_type"`
+			}
+			if err := decodeMCPArgs(raw, &args); err != nil {
+				return nil, err
+			}
+			path, already, err := m.StartAgent(ctx, AgentOptions{Target: args.Target, AgentType: args.AgentType})
+			if err != nil {
+				return nil, err
+			}
+			return map[string]any{"path": path, "already_running": already}, nil
+		},
+	},
+	{
+		Name:        "stop_agent",
+		Description: "Stop the agent of a worktree.",
+		InputSchema: mcpSchema([]string{"target"}, map[string]any{
+			"target": mcpString(mcpTargetDescription),
+		}),
+		call: func(ctx context.Context, m *Manager, raw json.RawMessage) (any, error) {
+			var args struct {
+				Target string `json:"target"`
+			}
+			if err := decodeMCPArgs(raw, &args); err != nil {
+				return nil, err
+			}
+			path, stopped, err := m.StopAgent(ctx, args.Target)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]any{"path": path, "stopped": stopped}, nil
+		},
+	},
+	{
+		Name:        "send_agent_prompt",
+		Description: "Type a prompt into a worktree's agent and press enter. The agent must be running; use get_agent_output to follow its progress.",
+		InputSchema: mcpSchema([]string{"target", "prompt"}, map[string]any{
+			"target": mcpString(mcpTargetDescription),
+			"prompt": mcpString("Instruction for the agent"),
+		}),
+		call: func(ctx context.Context, m *Manager, raw json.RawMessage) (any, error) {
+			var args struct {
+				Target string `json:"target"`
+				Prompt string `json:"prompt"`
+			}
+			if err := decodeMCPArgs(raw, &args); err != nil {
+				return nil, err
+			}
+			if strings.TrimSpace(args.Prompt) == "" {
+				return nil, fmt.Errorf("%w: prompt is empty", errInvalidArguments)
+			}
+			path, err := m.SendAgentCommand(args.Target, args.Prompt)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]any{"path": path, "sent": true}, nil
+		},
+	},
+	{
+		Name:        "get_agent_output",
+		Description: "Read the recent output of a worktree's agent pane, and whether the agent is busy, ready for input, or exited.",
+		InputSchema: mcpSchema([]string{"target"}, map[string]any{
+			"target": mcpString(mcpTargetDescription),
+			"lines":  map[string]any{"type": "integer", "description": fmt.Sprintf("Lines of scrollback to read (default %d)", mcpAgentOutputLines)},
+		}),
+		call: func(ctx context.Context, m *Manager, raw json.RawMessage) (any, error) {
+			var args struct {
+				Target string `json:"target"`
+				Lines  int    `json:"lines"`
+			}
+			if err := decodeMCPArgs(raw, &args); err != nil {
+				return nil, err
+			}
+			if args.Lines <= 0 {
+				args.Lines = mcpAgentOutputLines
+			}
+			repoRoot, wt, err := m.resolveWorktreeForTmux(args.Target)
+			if err != nil {
+				return nil, err
+			}
+			status := m.agentStatus(repoRoot, wt)
+			if status == agentStatusNone {
+				return nil, fmt.Errorf("agent is not running: %s", wt.Path)
+			}
+			out, err := m.agentOutputForWorktree(repoRoot, wt, args.Lines)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]any{"path": wt.Path, "status": status, "output": strings.TrimRight(stripANSI(out), "\n")}, nil
+		},
+	},
+	{
+		Name:        "get_diff",
+		Description: "Get a worktree's changes as a unified diff: everything on its branch since it forked from the base branch (the default), or only uncommitted changes.",
+		InputSchema: mcpSchema([]string{"target"}, map[string]any{
+			"target":           mcpString(mcpTargetDescription),
+			"uncommitted_only": mcpBool("Only show changes not committed yet"),
+		}),
+		call: func(ctx context.Context, m *Manager, raw json.RawMessage) (any, error) {
+			var args struct {
+				Target          string `json:"target"`
+				UncommittedOnly bool   `json:"uncommitted_only"`
+			}
+			if err := decodeMCPArgs(raw, &args); err != nil {
+				return nil, err
+			}
+			repoRoot, err := m.RequireRepo()
+			if err != nil {
+				return nil, err
+			}
+			wt, err := m.FindWorktree(args.Target)
+			if err != nil {
+				return nil, err
+			}
+			var diff string
+			if args.UncommittedOnly {
+				diff, err = m.runCmdOutput(wt.Path, "git", "--no-pager", "diff", "--no-color", "--no-ext-diff", "HEAD")
+			} else {
+				diff, err = m.BranchDiff(repoRoot, wt)
+			}
+			if err != nil {
+				return nil, err
+			}
+			return map[string]any{"path": wt.Path, "diff": diff}, nil
+		},
+	},
+}
+
+func mcpCreateWorktree(ctx context.Context, m *Manager, raw json.RawMessage) (any, error) {
+	var args struct {
+		Branch         string `json:"branch"`
+		Type           string `json:"type"`
+		Name           string `json:"name"`
+		Base           string `json:"base"`
+		FromBranch     string `json:"from_branch"`
+		FromPR         int    `json:"from_pr"`
+		StartAgent     bool   `json:"start_agent"`
+		AgentType      string `json:"agent_type"`
+		IgnoreWIPLimit bool   `json:"ignore_wip_limit"`
+	}
+	if err := decodeMCPArgs(raw, &args); err != nil {
+		return nil, err
+	}
+	if !args.IgnoreWIPLimit {
+		if status, err := m.WIPStatus(); err == nil && status.Exceeded() {
+			return nil, fmt.Errorf("%s (set ignore_wip_limit to create anyway)", wipLimitMessage(status, 3))
+		}
+	}
+	if args.FromPR != 0 {
+		if args.Branch != "" || args.Type != "" || args.Name != "" || args.FromBranch != "" {
+			return nil, fmt.Errorf("%w: from_pr cannot be combined with branch, type, name, or from_branch", errInvalidArguments)
+		}
+		branch, err := m.FetchPullRequest(ctx, args.FromPR)
+		if err != nil {
+			return nil, err
+		}
+		args.FromBranch = branch
+	}
+	branch, path, err := m.NewWorktree(ctx, NewOptions{
+		Branch:     args.Branch,
+		Type:       args.Type,
+		Name:       args.Name,
+		BaseBranch: args.Base,
+		FromBranch: args.FromBranch,
+	})
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]any{"branch": branch, "path": path, "agent_started": false}
+	if args.StartAgent || args.AgentType != "" {
+		if _, _, err := m.StartAgent(ctx, AgentOptions{Target: path, AgentType: args.AgentType}); err != nil {
+			result["agent_error"] = err.Error()
+		} else {
+			result["agent_started"] = true
+		}
+	}
+	return result, nil
+}
+
+func findMCPTool(name string) (mcpTool, bool) {
+	for _, tool := range mcpTools {
+		if tool.Name == name {
+			return tool, true
+		}
+	}
+	return mcpTool{}, false
+}
+
+func decodeMCPArgs(raw json.RawMessage, v any) error {
+	if len(bytes.TrimSpace(raw)) == 0 || string(bytes.TrimSpace(raw)) == "null" {
+		raw = json.RawMessage("{}")
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("%w: %v", errInvalidArguments, err)
+	}
+	return nil
+}
+
+// mcpServer serves the Model Context Protocol over newline-delimited
+// JSON-RPC, one message per line.
+type mcpServer struct {
+	mgr *Manager
+	out io.Writer
+}
+
+// ServeMCP answers MCP requests read from in until it is closed. Tool
+// failures are reported to the client as tool errors; only a broken stream
+// ends the server.
+func (m *Manager) ServeMCP(in io.Reader, out io.Writer) error {
+	srv := &mcpServer{mgr: m, out: out}
+	reader := bufio.NewReader(in)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			srv.handle(bytes.TrimSpace(line))
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+func (s *mcpServer) handle(line []byte) {
+	var req rpcRequest
+	if err := json.Unmarshal(line, &req); err != nil {
+		s.reply(json.RawMessage("null"), nil, &rpcError{Code: rpcParseError, Message: "parse error"})
+		return
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		s.reply(req.ID, nil, &rpcError{Code: rpcInvalidRequest, Message: "invalid request"})
+		return
+	}
+	// Requests without an id are notifications and get no reply.
+	notification := len(req.ID) == 0
+	debugLogf("mcp request method=%s", req.Method)
+
+	result, rpcErr := s.dispatch(req)
+	if notification {
+		return
+	}
+	s.reply(req.ID, result, rpcErr)
+}
+
+func (s *mcpServer) dispatch(req rpcRequest) (any, *rpcError) {
+	switch req.Method {
+	case "initialize":
+		var params struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		_ = json.Unmarshal(req.Params, &params)
+		version := mcpProtocolVersions[0]
+		if containsString(mcpProtocolVersions, params.ProtocolVersion) {
+			version = params.ProtocolVersion
+		}
+		return map[string]any{
+			"protocolVersion": version,
+			"capabilities":    map[string]any{"tools": map[string]any{}},
+			"serverInfo":      map[string]any{"name": "sprout", "version": Version},
+			"instructions":    "Manage the git worktrees of the repository sprout was started in, and the AI agents running in their tmux sessions. Worktrees are addressed by branch or path.",
+		}, nil
+	case "notifications/initialized", "notifications/cancelled":
+		return nil, nil
+	case "ping":
+		return map[string]any{}, nil
+	case "tools/list":
+		return map[string]any{"tools": mcpTools}, nil
+	case "tools/call":
+		var params struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: "invalid params"}
+		}
+		if tool, ok := findMCPTool(params.Name); ok {
+			return s.callTool(tool, params.Arguments), nil
+		}
+		return nil, &rpcError{Code: rpcInvalidParams, Message: "unknown tool: " + params.Name}
+	}
+	return nil, &rpcError{Code: rpcMethodNotFound, Message: "method not found: " + req.Method}
+}
+
+// callTool runs tool and wraps its result, or its error, as tool content so
+// the calling model can read what went wrong.
+func (s *mcpServer) callTool(tool mcpTool, args json.RawMessage) map[string]any {
+	value, err := tool.call(context.Background(), s.mgr, args)
+	if err != nil {
+		infoLogf("mcp tool=%s failed: %v", tool.Name, err)
+		return map[string]any{
+			"content": []map[string]any{{"type": "text", "text": err.Error()}},
+			"isError": true,
+		}
+	}
+	text, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return map[string]any{
+			"content": []map[string]any{{"type": "text", "text": err.Error()}},
+			"isError": true,
+		}
+	}
+	infoLogf("mcp tool=%s ok", tool.Name)
+	return map[string]any{
+		"content": []map[string]any{{"type": "text", "text": string(text)}},
+		"isError": false,
+	}
+}
+
+func (s *mcpServer) reply(id json.RawMessage, result any, rpcErr *rpcError) {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	resp := rpcResponse{JSONRPC: "2.0", ID: id, Error: rpcErr}
+	if rpcErr == nil {
+		resp.Result = result
+		if result == nil {
+			resp.Result = map[string]any{}
+		}
+	}
+	data, err := json.Marshal(resp)
+	if err != nil {
+		errorLogf("mcp encode response failed: %v", err)
+		return
+	}
+	if _, err := s.out.Write(append(data, '\n')); err != nil {
+		errorLogf("mcp write response failed: %v", err)
+	}
+}
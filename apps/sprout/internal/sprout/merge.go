@@ -0,0 +1,301 @@
+package sprout
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type MergeOptions struct {
+	Target string
+	// BaseBranch is the branch to merge into; empty uses base_branch.
+	BaseBranch string
+	// Squash folds the branch into a single commit on the base branch
+	// instead of recording a merge commit.
+	Squash  bool
+	Message string
+	// Remove removes the worktree once the branch is merged. DeleteBranch
+	// also deletes the branch, which implies Remove since git will not
+	// delete a branch that is checked out.
+	Remove       bool
+	DeleteBranch bool
+	// SkipChecks merges even when the branch's pull request checks are
+	// failing or still running.
+	SkipChecks bool
+}
+
+// MergePlan is what Merge will do, worked out before anything changes.
+type MergePlan struct {
+	Path   string
+	Branch string
+	Base   string
+	// Into is the worktree that has the base branch checked out, or empty
+	// when the merge happens in a temporary worktree.
+	Into    string
+	Commits int
+	// Checks summarizes the pull request checks: passed, failing, pending,
+	// or none when there is no pull request or gh cannot tell.
+	Checks  string
+	Squash  bool
+	Message string
+	Remove  bool
+	Delete  bool
+}
+
+type MergeResult struct {
+	MergePlan
+	// Commit is the base branch's new tip.
+	Commit   string
+	Removed  bool
+	Warnings []string
+}
+
+// Pull request check states reported in MergePlan.Checks.
+const (
+	mergeChecksPassed  = "passed"
+	mergeChecksFailing = "failing"
+	mergeChecksPending = "pending"
+	mergeChecksNone    = "none"
+)
+
+// PlanMerge verifies that a worktree's branch can be merged into the base
+// branch and describes how Merge would do it. The worktree must be clean,
+// have commits the base branch lacks, and merge without conflicts; its pull
+// request checks, when gh can report them, must have passed unless
+// opts.SkipChecks is set.
+func (m *Manager) PlanMerge(ctx context.Context, opts MergeOptions) (MergePlan, error) {
+	repoRoot, err := m.RequireRepo()
+	if err != nil {
+		return MergePlan{}, err
+	}
+	wt, err := m.findWorktree(ctx, opts.Target)
+	if err != nil {
+		return MergePlan{}, err
+	}
+	if wt.Branch == "" {
+		return MergePlan{}, errors.New("cannot merge a detached worktree")
+	}
+	base, err := m.ResolveBaseBranch(repoRoot, opts.BaseBranch)
+	if err != nil {
+		return MergePlan{}, err
+	}
+	if base == wt.Branch {
+		return MergePlan{}, errors.New("worktree is on the base branch: " + base)
+	}
+	remove := opts.Remove || opts.DeleteBranch
+	if remove && wt.Locked {
+		return MergePlan{}, fmt.Errorf("%s (unlock it to remove it after the merge)", lockedWorktreeMessage(wt))
+	}
+	if m.WorktreeDirty(ctx, wt.Path) {
+		return MergePlan{}, fmt.Errorf("worktree has uncommitted changes: %s (commit or stash them first)", wt.Path)
+	}
+	if m.worktreeRebaseInProgress(wt.Path) {
+		return MergePlan{}, fmt.Errorf("a rebase is in progress in %s", wt.Path)
+	}
+
+	count, err := m.runCmdOutputContext(ctx, repoRoot, "git", "rev-list", "--count", base+".."+wt.Branch)
+	if err != nil {
+		return MergePlan{}, err
+	}
+	commits, _ := strconv.Atoi(strings.TrimSpace(count))
+	if commits == 0 {
+		return MergePlan{}, fmt.Errorf("nothing to merge: %s has no commits that are not on %s", wt.Branch, base)
+	}
+	if files, err := m.mergeConflicts(repoRoot, base, wt.Branch); err != nil {
+		debugLogf("merge conflict check failed branch=%q base=%q: %v", wt.Branch, base, err)
+	} else if len(files) > 0 {
+		return MergePlan{}, fmt.Errorf("merging %s into %s would conflict in %s (rebase it first)", wt.Branch, base, strings.Join(files, ", "))
+	}
+
+	into, found, err := m.findExistingWorktreePath(repoRoot, base, "")
+	if err != nil {
+		return MergePlan{}, err
+	}
+	if !found {
+		into = ""
+	} else if m.WorktreeDirty(ctx, into) {
+		return MergePlan{}, fmt.Errorf("%s is checked out with uncommitted changes in %s (commit or stash them first)", base, into)
+	}
+
+	checks := m.pullRequestChecks(repoRoot, wt.Branch)
+	if !opts.SkipChecks && (checks == mergeChecksFailing || checks == mergeChecksPending) {
+		return MergePlan{}, fmt.Errorf("pull request checks for %s are %s (use --skip-checks to merge anyway)", wt.Branch, checks)
+	}
+
+	message := strings.TrimSpace(opts.Message)
+	if message == "" {
+		message = fmt.Sprintf("Merge branch '%s'", wt.Branch)
+		if opts.Squash {
+			message = wt.Branch
+			if commits == 1 {
+				if subject, err := m.runCmdOutput(repoRoot, "git", "log", "-1", "--format=%s", wt.Branch); err == nil && strings.TrimSpace(subject) != "" {
+					message = strings.TrimSpace(subject)
+				}
+			}
+		}
+	}
+	return MergePlan{
+		Path:    wt.Path,
+		Branch:  wt.Branch,
+		Base:    base,
+		Into:    into,
+		Commits: commits,
+		Checks:  checks,
+		Squash:  opts.Squash,
+		Message: message,
+		Remove:  remove,
+		Delete:  opts.DeleteBranch,
+	}, nil
+}
+
+// Merge merges a worktree's branch into the base branch, in the worktree
+// that has the base branch checked out or, when there is none, in a
+// temporary one. A failed merge leaves the base branch where it was. Once
+// merged, the worktree and branch are removed as opts asks; a failure there
+// is reported as a warning since the merge itself went through.
+func (m *Manager) Merge(ctx context.Context, opts MergeOptions) (MergeResult, error) {
+	plan, err := m.PlanMerge(ctx, opts)
+	if err != nil {
+		return MergeResult{}, err
+	}
+	res := MergeResult{MergePlan: plan}
+	repoRoot, err := m.RequireRepo()
+	if err != nil {
+		return res, err
+	}
+	unlock, err := m.lockRepo(ctx, repoRoot, "merge "+plan.Branch)
+	if err != nil {
+		return res, err
+	}
+	defer unlock()
+
+	dir := plan.Into
+	if dir == "" {
+		tmp, err := os.MkdirTemp("", "sprout-merge-")
+		if err != nil {
+			return res, err
+		}
+		defer os.RemoveAll(tmp)
+		if err := m.runCmdQuietContext(ctx, repoRoot, "git", "worktree", "add", tmp, plan.Base); err != nil {
+			return res, err
+		}
+		defer func() {
+			if err := m.runCmdQuiet(repoRoot, "git", "worktree", "remove", "--force", tmp); err != nil {
+				errorLogf("merge temporary worktree removal failed path=%q: %v", tmp, err)
+			}
+		}()
+		dir = tmp
+	}
+
+	orig, err := m.runCmdOutput(dir, "git", "rev-parse", "HEAD")
+	if err != nil {
+		return res, err
+	}
+	orig = strings.TrimSpace(orig)
+	infoLogf("merge start branch=%q base=%q dir=%q squash=%t", plan.Branch, plan.Base, dir, plan.Squash)
+	if err := m.mergeBranch(ctx, dir, plan); err != nil {
+		errorLogf("merge failed branch=%q base=%q: %v", plan.Branch, plan.Base, err)
+		if rollbackErr := m.runCmdQuiet(dir, "git", "reset", "--hard", "--quiet", orig); rollbackErr != nil {
+			return res, fmt.Errorf("%w (rolling back %s to %s also failed: %v)", err, plan.Base, orig, rollbackErr)
+		}
+		return res, fmt.Errorf("%w (%s was left at %s)", err, plan.Base, shortHash(orig))
+	}
+	commit, err := m.runCmdOutput(dir, "git", "rev-parse", "HEAD")
+	if err != nil {
+		return res, err
+	}
+	res.Commit = strings.TrimSpace(commit)
+	infoLogf("merge done branch=%q base=%q commit=%q", plan.Branch, plan.Base, res.Commit)
+
+	if plan.Remove {
+		_, warnings, err := m.Remove(ctx, RemoveOptions{
+			Target:       plan.Path,
+			DeleteBranch: plan.Delete,
+			// git branch -d does not see a squashed branch as merged.
+			Force:  plan.Squash,
+			Merged: true,
+		})
+		res.Warnings = append(res.Warnings, warnings...)
+		if err != nil {
+			res.Warnings = append(res.Warnings, fmt.Sprintf("merged, but removing the worktree failed: %v", err))
+		} else {
+			res.Removed = true
+		}
+	}
+	return res, nil
+}
+
+func (m *Manager) mergeBranch(ctx context.Context, dir string, plan MergePlan) error {
+	if !plan.Squash {
+		return m.runCmdQuietContext(ctx, dir, "git", "merge", "--no-ff", "--no-edit", "-m", plan.Message, plan.Branch)
+	}
+	if err := m.runCmdQuietContext(ctx, dir, "git", "merge", "--squash", plan.Branch); err != nil {
+		return err
+	}
+	return m.runCmdQuietContext(ctx, dir, "git", "commit", "--no-edit", "-m", plan.Message)
+}
+
+// mergeConflicts lists the files that would conflict when merging head into
+// base, using git merge-tree so no worktree is touched.
+func (m *Manager) mergeConflicts(dir, base, head string) ([]string, error) {
+	out, err := m.runCmdOutputAllowExitCodes(dir, []int{1}, "git", "merge-tree", "--write-tree", "--name-only", "--no-messages", base, head)
+	if err != nil {
+		return nil, err
+	}
+	return parseMergeTreeConflicts(out), nil
+}
+
+// pullRequestChecks asks gh how the checks of the branch's pull request are
+// doing.
+func (m *Manager) pullRequestChecks(repoRoot, branch string) string {
+	if !m.commandExists("gh") {
+		return mergeChecksNone
+	}
+	// gh exits 1 when a check failed and 8 while some are pending.
+	out, err := m.runCmdBytesAllowExitCodes(repoRoot, []int{1, 8}, "gh", "pr", "checks", branch, "--json", "bucket")
+	if err != nil {
+		debugLogf("merge gh pr checks failed branch=%q: %v", branch, err)
+		return mergeChecksNone
+	}
+	var checks []struct {
+		Bucket string `json:"bucket"`
+	}
+	if err := json.Unmarshal(out, &checks); err != nil {
+		debugLogf("merge gh pr checks output unreadable branch=%q: %v", branch, err)
+		return mergeChecksNone
+	}
+	buckets := make([]string, 0, len(checks))
+	for _, c := range checks {
+		buckets = append(buckets, c.Bucket)
+	}
+	return summarizeChecks(buckets)
+}
+
+// summarizeChecks folds gh's check buckets into one state: failing if any
+// failed or was canceled, else pending if any is still running.
+func summarizeChecks(buckets []string) string {
+	if len(buckets) == 0 {
+		return mergeChecksNone
+	}
+	state := mergeChecksPassed
+	for _, b := range buckets {
+		switch b {
+		case "fail", "cancel":
+			return mergeChecksFailing
+		case "pending":
+			state = mergeChecksPending
+		}
+	}
+	return state
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
@@ -0,0 +1,340 @@
+package sprout
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Agent events that can trigger notifications.
+const (
+	// notifyEventReady fires when a busy agent starts waiting for input.
+	notifyEventReady = "ready"
+	// notifyEventExited fires when a running agent's pane exits.
+	notifyEventExited = "exited"
+	// notifyEventIdle fires once when an agent has waited for input longer
+	// than agent_idle_minutes.
+	notifyEventIdle = "idle"
+)
+
+// Agent statuses observed by the notification watcher.
+const (
+	agentStatusNone   = ""
+	agentStatusBusy   = "busy"
+	agentStatusReady  = "ready"
+	agentStatusExited = "exited"
+)
+
+const (
+	notifyPollInterval   = 2 * time.Second
+	notifyWebhookTimeout = 5 * time.Second
+)
+
+func parseNotifyEvents(values []string) ([]string, error) {
+	events := make([]string, 0, len(values))
+	for _, value := range values {
+		switch v := strings.ToLower(strings.TrimSpace(value)); v {
+		case "":
+		case notifyEventReady, notifyEventExited, notifyEventIdle:
+			events = append(events, v)
+		default:
+			return nil, fmt.Errorf("invalid notify event %q (want ready, exited, or idle)", value)
+		}
+	}
+	return events, nil
+}
+
+// AgentEvent is an agent state change, as posted to notify_webhook.
+type AgentEvent struct {
+	Event  string    `json:"event"`
+	Repo   string    `json:"repo"`
+	Branch string    `json:"branch"`
+	Path   string    `json:"path"`
+	Time   time.Time `json:"time"`
+	// Idle is how long the agent has been waiting, on idle events.
+	Idle string `json:"idle,omitempty"`
+}
+
+func (e AgentEvent) title() string {
+	switch e.Event {
+	case notifyEventExited:
+		return fmt.Sprintf("sprout: %s agent exited", e.Branch)
+	case notifyEventIdle:
+		return fmt.Sprintf("sprout: %s agent is idle", e.Branch)
+	}
+	return fmt.Sprintf("sprout: %s agent is ready", e.Branch)
+}
+
+func (e AgentEvent) message() string {
+	switch e.Event {
+	case notifyEventExited:
+		return fmt.Sprintf("The agent in %s stopped running.", e.Repo)
+	case notifyEventIdle:
+		return fmt.Sprintf("The agent in %s has been waiting for input for %s.", e.Repo, e.Idle)
+	}
+	return fmt.Sprintf("The agent in %s is waiting for input.", e.Repo)
+}
+
+// Notifier sends agent events to the channels configured for them.
+type Notifier struct {
+	cfg Config
+}
+
+func newNotifier(cfg Config) *Notifier {
+	return &Notifier{cfg: cfg}
+}
+
+// Enabled reports whether any channel is configured.
+func (n *Notifier) Enabled() bool {
+	return len(n.cfg.NotifyDesktop) > 0 || len(n.cfg.NotifyBell) > 0 ||
+		(n.cfg.NotifyWebhook != "" && len(n.cfg.NotifyWebhookEvents) > 0)
+}
+
+// Notify sends ev to every channel configured for its event type. Failures
+// are logged; a missed notification is not worth interrupting anyone for.
+func (n *Notifier) Notify(ev AgentEvent) {
+	infoLogf("notify event=%s branch=%q path=%q", ev.Event, ev.Branch, ev.Path)
+	if containsString(n.cfg.NotifyBell, ev.Event) {
+		ringBell()
+	}
+	if containsString(n.cfg.NotifyDesktop, ev.Event) {
+		if err := sendDesktopNotification(ev.title(), ev.message()); err != nil {
+			errorLogf("notify desktop failed event=%s branch=%q: %v", ev.Event, ev.Branch, err)
+		}
+	}
+	if n.cfg.NotifyWebhook != "" && containsString(n.cfg.NotifyWebhookEvents, ev.Event) {
+		if err := postWebhook(n.cfg.NotifyWebhook, ev); err != nil {
+			errorLogf("notify webhook failed event=%s branch=%q: %v", ev.Event, ev.Branch, err)
+		}
+	}
+}
+
+// ringBell rings the controlling terminal, which is also where the TUI draws.
+func ringBell() {
+	if tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0); err == nil {
+		tty.WriteString("\a")
+		tty.Close()
+		return
+	}
+	fmt.Fprint(os.Stderr, "\a")
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// sendDesktopNotification shows a notification with osascript on macOS and
+// notify-send elsewhere. Both run locally, where the user is, in SSH remote
+// mode too.
+func sendDesktopNotification(title, message string) error {
+	name, args := "notify-send", []string{"--app-name=sprout", title, message}
+	if runtime.GOOS == "darwin" {
+		script := fmt.Sprintf("display notification %s with title %s", appleScriptString(message), appleScriptString(title))
+		name, args = "osascript", []string{"-e", script}
+	} else if _, err := exec.LookPath(name); err != nil {
+		return fmt.Errorf("notify-send not found")
+	}
+	if out, err := exec.Command(name, args...).CombinedOutput(); err != nil {
+		if trimmed := strings.TrimSpace(string(out)); trimmed != "" {
+			return fmt.Errorf("%s failed: %w: %s", name, err, trimmed)
+		}
+		return fmt.Errorf("%s failed: %w", name, err)
+	}
+	return nil
+}
+
+func appleScriptString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+func postWebhook(url string, ev AgentEvent) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), notifyWebhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "sprout/"+Version)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// agentStatus reports whether wt's agent is busy, ready for input, or has
+// exited, or agentStatusNone when it has no agent window.
+func (m *Manager) agentStatus(repoRoot string, wt *Worktree) string {
+	session := m.tmuxWorktreeSessionName(repoRoot, wt)
+	if !m.tmuxWorktreeRunning(session, wt.Path) {
+		return agentStatusNone
+	}
+	window := m.tmuxAgentWindowName(worktreeBranchOrName(wt))
+	if !m.tmuxWindowExists(session, window) {
+		if _, ok := m.findAgentPaneInSession(session, wt.Path); !ok {
+			return agentStatusNone
+		}
+	}
+	target := m.agentPaneTarget(repoRoot, wt)
+	dead, err := m.runCmdOutput("", "tmux", "display-message", "-p", "-t", target, "#{pane_dead}")
+	if err != nil {
+		return agentStatusNone
+	}
+	if strings.TrimSpace(dead) == "1" {
+		return agentStatusExited
+	}
+	out, err := m.tmuxCapturePaneWithCursor(target, 40)
+	if err != nil {
+		return agentStatusNone
+	}
+	if agentReadyForInstruction(out) {
+		return agentStatusReady
+	}
+	return agentStatusBusy
+}
+
+// repoAgentCounts counts the worktrees of the repository at repoRoot and
+// the agents among them waiting for input, for repositories other than the
+// one whose worktrees are listed.
+func (m *Manager) repoAgentCounts(repoRoot string) (worktrees, ready int) {
+	items, err := m.parseWorktreeList(repoRoot)
+	if err != nil {
+		debugLogf("repo_agent_counts root=%q: %v", repoRoot, err)
+		return 0, 0
+	}
+	hasTmux := m.commandExists("tmux")
+	for i := range items {
+		if hasTmux && m.agentStatus(repoRoot, &items[i]) == agentStatusReady {
+			ready++
+		}
+	}
+	return len(items), ready
+}
+
+// agentTimer is the status of an agent and since when it has had it. Since
+// is zero when that is not known, like for an agent that was already busy
+// when watching began.
+type agentTimer struct {
+	Status string
+	Since  time.Time
+	// IdleWarned is set once the idle event fired for this ready spell.
+	IdleWarned bool
+}
+
+// label is the status with how long the agent has had it, e.g. "ready 12m".
+func (t agentTimer) label(now time.Time) string {
+	if t.Since.IsZero() {
+		return t.Status
+	}
+	if d := compactDuration(now.Sub(t.Since)); d != "" {
+		return t.Status + " " + d
+	}
+	return t.Status
+}
+
+// compactDuration renders d in whole minutes, hours, or days, or "" for less
+// than a minute.
+func compactDuration(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return ""
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d/time.Minute))
+	case d < 24*time.Hour:
+		if m := int(d%time.Hour) / int(time.Minute); m > 0 {
+			return fmt.Sprintf("%dh%dm", int(d/time.Hour), m)
+		}
+		return fmt.Sprintf("%dh", int(d/time.Hour))
+	}
+	return fmt.Sprintf("%dd", int(d/(24*time.Hour)))
+}
+
+// agentWatcher turns successive agent statuses into events and keeps track
+// of how long each agent has been busy or ready.
+type agentWatcher struct {
+	last map[string]agentTimer
+	// idleAfter is how long an agent may wait for input before the idle
+	// event; zero disables it.
+	idleAfter time.Duration
+}
+
+func newAgentWatcher(idleAfter time.Duration) *agentWatcher {
+	return &agentWatcher{last: map[string]agentTimer{}, idleAfter: idleAfter}
+}
+
+// observe records the status of the agent at path, seen at now, and returns
+// the event its change amounts to, or "" for none. activity is when its pane
+// last changed, if known; a ready agent has been ready since then. The first
+// status seen for a path only sets the baseline, so agents that were already
+// waiting do not fire ready, though they can still turn idle.
+func (w *agentWatcher) observe(path, status string, now, activity time.Time) string {
+	prev, seen := w.last[path]
+	timer := prev
+	if !seen || prev.Status != status {
+		timer = agentTimer{Status: status}
+		switch {
+		case status == agentStatusReady && !activity.IsZero() && !activity.After(now):
+			timer.Since = activity
+		case seen:
+			timer.Since = now
+		}
+	}
+	event := ""
+	if seen && prev.Status != status {
+		switch {
+		case prev.Status == agentStatusBusy && status == agentStatusReady:
+			event = notifyEventReady
+		case (prev.Status == agentStatusBusy || prev.Status == agentStatusReady) && (status == agentStatusExited || status == agentStatusNone):
+			event = notifyEventExited
+		}
+	}
+	if event == "" && status == agentStatusReady && w.idleAfter > 0 && !timer.IdleWarned &&
+		!timer.Since.IsZero() && now.Sub(timer.Since) >= w.idleAfter {
+		timer.IdleWarned = true
+		event = notifyEventIdle
+	}
+	w.last[path] = timer
+	return event
+}
+
+// timers copies the current timer of every watched agent.
+func (w *agentWatcher) timers() map[string]agentTimer {
+	res := make(map[string]agentTimer, len(w.last))
+	for path, timer := range w.last {
+		if timer.Status == agentStatusBusy || timer.Status == agentStatusReady {
+			res[path] = timer
+		}
+	}
+	return res
+}
+
+// forget drops paths that are no longer worktrees.
+func (w *agentWatcher) forget(alive map[string]struct{}) {
+	for path := range w.last {
+		if _, ok := alive[path]; !ok {
+			delete(w.last, path)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package sprout
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// Output formats accepted by --output and SPROUT_OUTPUT.
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// cliOutput holds the output state of the running command.
+var cliOutput = struct {
+	Format   string
+	Command  string
+	Start    time.Time
+	Warnings []string
+}{Format: outputText}
+
+// CommandResult is the envelope written to stdout for every command when the
+// output format is json.
+type CommandResult struct {
+	Command  string   `json:"command"`
+	OK       bool     `json:"ok"`
+	Result   any      `json:"result,omitempty"`
+	Warnings []string `json:"warnings"`
+	Error    string   `json:"error,omitempty"`
+	// Fields lists per-field validation errors, e.g. of sprout new.
+	Fields     []FieldError `json:"fields,omitempty"`
+	DurationMS int64        `json:"duration_ms"`
+}
+
+func resolveOutputFormat(flagValue string) (string, error) {
+	format := strings.ToLower(strings.TrimSpace(flagValue))
+	if format == "" {
+		format = strings.ToLower(strings.TrimSpace(os.Getenv("SPROUT_OUTPUT")))
+	}
+	switch format {
+	case "":
+		return outputText, nil
+	case outputText, outputJSON:
+		return format, nil
+	}
+	return "", fmt.Errorf("invalid output format %q (want text or json)", format)
+}
+
+func initOutput(cmd *cobra.Command) error {
+	flagValue, _ := cmd.Flags().GetString("output")
+	format, err := resolveOutputFormat(flagValue)
+	if err != nil {
+		return err
+	}
+	cliOutput.Format = format
+	cliOutput.Command = strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" ")
+	cliOutput.Start = time.Now()
+	cliOutput.Warnings = nil
+	return nil
+}
+
+func jsonOutput() bool {
+	return cliOutput.Format == outputJSON
+}
+
+func writeCommandResult(result any, warnings []string, err error) {
+	res := CommandResult{
+		Command:    cliOutput.Command,
+		OK:         err == nil,
+		Result:     result,
+		Warnings:   append([]string{}, warnings...),
+		DurationMS: time.Since(cliOutput.Start).Milliseconds(),
+	}
+	if err != nil {
+		res.Error = err.Error()
+		var verr *ValidationError
+		if errors.As(err, &verr) {
+			res.Fields = verr.Fields
+		}
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if encErr := enc.Encode(res); encErr != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", encErr)
+	}
+}
+
+// cliWarn reports a non-fatal problem. Text output prints it right away; json
+// output collects it into the result envelope.
+func cliWarn(msg string) {
+	if jsonOutput() {
+		cliOutput.Warnings = append(cliOutput.Warnings, msg)
+		return
+	}
+	fmt.Fprintln(os.Stderr, WarnMsg(msg))
+}
+
+// cliFail reports err and exits with status 1.
+func cliFail(err error) {
+	if jsonOutput() {
+		writeCommandResult(nil, cliOutput.Warnings, err)
+	} else {
+		fmt.Fprintln(os.Stderr, ErrorMsg(err.Error()))
+	}
+	flushPerf()
+	os.Exit(1)
+}
+
+// cliUsage reports a usage error and exits with status 1.
+func cliUsage(usage string) {
+	cliFail(fmt.Errorf("usage: %s", usage))
+}
+
+// cliDone finishes a successful command: json output writes result, text
+// output runs printText.
+func cliDone(result any, printText func()) {
+	if jsonOutput() {
+		writeCommandResult(result, cliOutput.Warnings, nil)
+		return
+	}
+	if printText != nil {
+		printText()
+	}
+}
@@ -0,0 +1,95 @@
+package sprout
+
+import (
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// envNameRe matches the variable names env in [[windows]] may set.
+var envNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Range of the {port} placeholder of pane env values.
+const (
+	paneEnvPortBase  = 20000
+	paneEnvPortRange = 10000
+)
+
+// parsePaneEnv checks the variable names of a window or pane env table.
+func parsePaneEnv(env map[string]string) error {
+	for name := range env {
+		if !envNameRe.MatchString(name) {
+			return fmt.Errorf("invalid env name %q", name)
+		}
+	}
+	return nil
+}
+
+// worktreePort is a port picked from a worktree's path, the same on every
+// launch, so each worktree's dev servers can listen on their own port.
+func worktreePort(worktreePath string) int {
+	h := fnv.New32a()
+	h.Write([]byte(worktreePath))
+	return paneEnvPortBase + int(h.Sum32()%paneEnvPortRange)
+}
+
+// paneEnv is the environment of a pane as sorted NAME=value pairs: its
+// window's env with the pane's on top. Values can use {branch}, {slug} (the
+// branch as a name safe for hosts and containers), {repo}, {worktree}, and
+// {port}.
+func paneEnv(win WindowConfig, pane PaneConfig, repoName, branch, worktreePath string) []string {
+	if len(win.Env) == 0 && len(pane.Env) == 0 {
+		return nil
+	}
+	merged := map[string]string{}
+	for name, value := range win.Env {
+		merged[name] = value
+	}
+	for name, value := range pane.Env {
+		merged[name] = value
+	}
+	r := strings.NewReplacer(
+		"{branch}", branch,
+		"{slug}", safeName(branch),
+		"{repo}", repoName,
+		"{worktree}", worktreePath,
+		"{port}", strconv.Itoa(worktreePort(worktreePath)),
+	)
+	env := make([]string, 0, len(merged))
+	for name, value := range merged {
+		env = append(env, name+"="+r.Replace(value))
+	}
+	sort.Strings(env)
+	return env
+}
+
+// withPaneEnv prepares a pane command and its environment for tmux. tmux
+// sets the environment with -e, except in a container, where the command
+// exports it itself so it reaches the container's processes.
+func (m *Manager) withPaneEnv(session, worktreePath, command string, env []string) (string, []string) {
+	if len(env) == 0 || !m.containerEnabled() {
+		return m.containerize(session, worktreePath, command), env
+	}
+	command = strings.TrimSpace(command)
+	if command == "" || command == defaultShellCommand() {
+		command = containerShell
+	}
+	exports := make([]string, len(env))
+	for i, pair := range env {
+		name, value, _ := strings.Cut(pair, "=")
+		exports[i] = "export " + name + "=" + shellQuote(value) + ";"
+	}
+	return m.containerize(session, worktreePath, strings.Join(exports, " ")+" "+command), nil
+}
+
+// tmuxEnvArgs turns NAME=value pairs into tmux -e flags.
+func tmuxEnvArgs(env []string) []string {
+	args := make([]string, 0, 2*len(env))
+	for _, pair := range env {
+		args = append(args, "-e", pair)
+	}
+	return args
+}
@@ -0,0 +1,382 @@
+package sprout
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	perfFile = "perf.json"
+	// perfSlowThreshold is how long a command must take to be kept as a
+	// slow invocation; faster ones only count toward the histograms.
+	perfSlowThreshold = 100 * time.Millisecond
+	// perfMaxSamples and perfSampleAge bound the slow invocations kept.
+	perfMaxSamples = 200
+	perfSampleAge  = 7 * 24 * time.Hour
+	// perfMaxCommandLen trims the command line of a sample.
+	perfMaxCommandLen = 200
+)
+
+// perfBucketsMS are the upper bounds of the histogram buckets, in
+// milliseconds; a last bucket counts everything slower.
+var perfBucketsMS = []int64{10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+// PerfHistogram counts how long the runs of a command or an operation took.
+type PerfHistogram struct {
+	Count   int     `json:"count"`
+	TotalMS int64   `json:"total_ms"`
+	MaxMS   int64   `json:"max_ms"`
+	Buckets []int64 `json:"buckets"`
+}
+
+func (h *PerfHistogram) add(ms int64) {
+	if len(h.Buckets) != len(perfBucketsMS)+1 {
+		h.Buckets = make([]int64, len(perfBucketsMS)+1)
+	}
+	h.Count++
+	h.TotalMS += ms
+	if ms > h.MaxMS {
+		h.MaxMS = ms
+	}
+	i := sort.Search(len(perfBucketsMS), func(i int) bool { return ms <= perfBucketsMS[i] })
+	h.Buckets[i]++
+}
+
+func (h *PerfHistogram) merge(o *PerfHistogram) {
+	if len(h.Buckets) != len(perfBucketsMS)+1 {
+		h.Buckets = make([]int64, len(perfBucketsMS)+1)
+	}
+	h.Count += o.Count
+	h.TotalMS += o.TotalMS
+	if o.MaxMS > h.MaxMS {
+		h.MaxMS = o.MaxMS
+	}
+	for i := range h.Buckets {
+		if i < len(o.Buckets) {
+			h.Buckets[i] += o.Buckets[i]
+		}
+	}
+}
+
+// percentileMS estimates the p-th percentile from the buckets: the upper
+// bound of the bucket it falls in, or the maximum for the last bucket.
+func (h *PerfHistogram) percentileMS(p float64) int64 {
+	if h.Count == 0 {
+		return 0
+	}
+	rank := int64(float64(h.Count)*p + 0.999999)
+	var seen int64
+	for i, n := range h.Buckets {
+		seen += n
+		if seen >= rank {
+			if i < len(perfBucketsMS) && perfBucketsMS[i] < h.MaxMS {
+				return perfBucketsMS[i]
+			}
+			return h.MaxMS
+		}
+	}
+	return h.MaxMS
+}
+
+// PerfSample is one slow command invocation.
+type PerfSample struct {
+	Time       time.Time `json:"time"`
+	DurationMS int64     `json:"duration_ms"`
+	Dir        string    `json:"dir,omitempty"`
+	Command    string    `json:"command"`
+	Failed     bool      `json:"failed,omitempty"`
+}
+
+// perfData is what perf.json holds: histograms of commands, keyed by program
+// and subcommand ("git status"), and of Manager operations, with the
+// slowest recent commands.
+type perfData struct {
+	Commands   map[string]*PerfHistogram `json:"commands"`
+	Operations map[string]*PerfHistogram `json:"operations"`
+	Slow       []PerfSample              `json:"slow"`
+}
+
+func newPerfData() *perfData {
+	return &perfData{Commands: map[string]*PerfHistogram{}, Operations: map[string]*PerfHistogram{}}
+}
+
+func (d *perfData) merge(o *perfData) {
+	for key, h := range o.Commands {
+		if d.Commands[key] == nil {
+			d.Commands[key] = &PerfHistogram{}
+		}
+		d.Commands[key].merge(h)
+	}
+	for key, h := range o.Operations {
+		if d.Operations[key] == nil {
+			d.Operations[key] = &PerfHistogram{}
+		}
+		d.Operations[key].merge(h)
+	}
+	d.Slow = append(d.Slow, o.Slow...)
+	d.trimSlow(time.Now())
+}
+
+// trimSlow drops samples older than perfSampleAge and keeps the slowest
+// perfMaxSamples of the rest.
+func (d *perfData) trimSlow(now time.Time) {
+	kept := d.Slow[:0]
+	for _, s := range d.Slow {
+		if now.Sub(s.Time) <= perfSampleAge {
+			kept = append(kept, s)
+		}
+	}
+	sort.SliceStable(kept, func(i, j int) bool { return kept[i].DurationMS > kept[j].DurationMS })
+	if len(kept) > perfMaxSamples {
+		kept = kept[:perfMaxSamples]
+	}
+	d.Slow = kept
+}
+
+var (
+	perfMu      sync.Mutex
+	perfPending = newPerfData()
+)
+
+// perfCommandKey names the histogram of a command: the program and its
+// first argument that is not an option, such as "git status".
+func perfCommandKey(name string, args []string) string {
+	skipNext := false
+	for _, arg := range args {
+		if skipNext {
+			skipNext = false
+			continue
+		}
+		if arg == "-C" || arg == "-c" {
+			skipNext = true
+			continue
+		}
+		if strings.HasPrefix(arg, "-") {
+			continue
+		}
+		return name + " " + arg
+	}
+	return name
+}
+
+// recordCmdTiming counts a finished command run by the runCmd helpers.
+func recordCmdTiming(dir, name string, args []string, elapsed time.Duration, failed bool) {
+	ms := elapsed.Milliseconds()
+	key := perfCommandKey(name, args)
+	perfMu.Lock()
+	defer perfMu.Unlock()
+	if perfPending.Commands[key] == nil {
+		perfPending.Commands[key] = &PerfHistogram{}
+	}
+	perfPending.Commands[key].add(ms)
+	if elapsed < perfSlowThreshold {
+		return
+	}
+	command := strings.TrimSpace(name + " " + strings.Join(args, " "))
+	if len(command) > perfMaxCommandLen {
+		command = command[:perfMaxCommandLen] + "..."
+	}
+	perfPending.Slow = append(perfPending.Slow, PerfSample{Time: time.Now().UTC(), DurationMS: ms, Dir: dir, Command: command, Failed: failed})
+	if len(perfPending.Slow) > 2*perfMaxSamples {
+		perfPending.trimSlow(time.Now())
+	}
+}
+
+// timeOperation starts timing a Manager operation; call the returned
+// function when it is done:
+//
+//	defer timeOperation("list_worktrees")()
+func timeOperation(op string) func() {
+	start := time.Now()
+	return func() {
+		ms := time.Since(start).Milliseconds()
+		perfMu.Lock()
+		defer perfMu.Unlock()
+		if perfPending.Operations[op] == nil {
+			perfPending.Operations[op] = &PerfHistogram{}
+		}
+		perfPending.Operations[op].add(ms)
+	}
+}
+
+func perfPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "sprout", perfFile), nil
+}
+
+func readPerfData() (*perfData, error) {
+	path, err := perfPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return newPerfData(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	d := newPerfData()
+	if err := json.Unmarshal(data, d); err != nil {
+		return nil, err
+	}
+	if d.Commands == nil {
+		d.Commands = map[string]*PerfHistogram{}
+	}
+	if d.Operations == nil {
+		d.Operations = map[string]*PerfHistogram{}
+	}
+	return d, nil
+}
+
+// flushPerf adds the timings recorded in this process to perf.json. Two
+// processes flushing at once can lose one's timings, which is fine for
+// numbers that are only a diagnostic.
+func flushPerf() {
+	perfMu.Lock()
+	pending := perfPending
+	perfPending = newPerfData()
+	perfMu.Unlock()
+	if len(pending.Commands) == 0 && len(pending.Operations) == 0 {
+		return
+	}
+
+	path, err := perfPath()
+	if err != nil {
+		return
+	}
+	stored, err := readPerfData()
+	if err != nil {
+		debugLogf("perf read failed, starting over: %v", err)
+		stored = newPerfData()
+	}
+	stored.merge(pending)
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		debugLogf("perf write failed: %v", err)
+		return
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		debugLogf("perf write failed: %v", err)
+	}
+}
+
+// resetPerf forgets the timings recorded so far.
+func resetPerf() error {
+	perfMu.Lock()
+	perfPending = newPerfData()
+	perfMu.Unlock()
+	path, err := perfPath()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// PerfStat summarizes the histogram of one command or operation.
+type PerfStat struct {
+	Name    string `json:"name"`
+	Count   int    `json:"count"`
+	TotalMS int64  `json:"total_ms"`
+	AvgMS   int64  `json:"avg_ms"`
+	P90MS   int64  `json:"p90_ms"`
+	MaxMS   int64  `json:"max_ms"`
+}
+
+// PerfReport is what sprout perf shows.
+type PerfReport struct {
+	Commands   []PerfStat   `json:"commands"`
+	Operations []PerfStat   `json:"operations"`
+	Slowest    []PerfSample `json:"slowest"`
+}
+
+// PerfReportOptions selects what a PerfReport covers. Zero values mean
+// every directory and no limit.
+type PerfReportOptions struct {
+	// Dirs keeps the slow invocations run in or under one of them.
+	Dirs  []string
+	Limit int
+}
+
+// loadPerfReport summarizes perf.json, with this process's own timings,
+// ordered by the total time spent.
+func loadPerfReport(opts PerfReportOptions) (PerfReport, error) {
+	d, err := readPerfData()
+	if err != nil {
+		return PerfReport{}, err
+	}
+	perfMu.Lock()
+	pending := newPerfData()
+	pending.merge(perfPending)
+	perfMu.Unlock()
+	d.merge(pending)
+
+	report := PerfReport{
+		Commands:   perfStats(d.Commands),
+		Operations: perfStats(d.Operations),
+		Slowest:    []PerfSample{},
+	}
+	for _, s := range d.Slow {
+		if len(opts.Dirs) > 0 && !perfSampleIn(s, opts.Dirs) {
+			continue
+		}
+		report.Slowest = append(report.Slowest, s)
+	}
+	if opts.Limit > 0 {
+		if len(report.Commands) > opts.Limit {
+			report.Commands = report.Commands[:opts.Limit]
+		}
+		if len(report.Slowest) > opts.Limit {
+			report.Slowest = report.Slowest[:opts.Limit]
+		}
+	}
+	return report, nil
+}
+
+func perfSampleIn(s PerfSample, dirs []string) bool {
+	for _, dir := range dirs {
+		if samePath(s.Dir, dir) || strings.HasPrefix(s.Dir, dir+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+func perfStats(hists map[string]*PerfHistogram) []PerfStat {
+	stats := []PerfStat{}
+	for name, h := range hists {
+		if h.Count == 0 {
+			continue
+		}
+		stats = append(stats, PerfStat{
+			Name:    name,
+			Count:   h.Count,
+			TotalMS: h.TotalMS,
+			AvgMS:   h.TotalMS / int64(h.Count),
+			P90MS:   h.percentileMS(0.9),
+			MaxMS:   h.MaxMS,
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].TotalMS != stats[j].TotalMS {
+			return stats[i].TotalMS > stats[j].TotalMS
+		}
+		return stats[i].Name < stats[j].Name
+	})
+	return stats
+}
@@ -0,0 +1,183 @@
+package sprout
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type PickOptions struct {
+	Source string
+	Dest   string
+	// Files are changed files of the source worktree whose changes are
+	// applied to the destination. Since is the revision they are compared
+	// with; empty means where the source branch forked from the base
+	// branch, so committed and uncommitted changes both come along.
+	Files []string
+	Since string
+	// Commits are cherry-picked onto the destination's branch, in order.
+	Commits []string
+}
+
+type PickResult struct {
+	Source string
+	Dest   string
+	Files  []string
+	// Commits are the new commits on the destination branch.
+	Commits []string
+	// Conflicts are files a three-way apply left with conflict markers in
+	// the destination worktree.
+	Conflicts []string
+}
+
+// Pick copies changes from one worktree into another: the changes of
+// selected files, applied with git apply, or whole commits, cherry-picked.
+// A cherry-pick that conflicts is aborted so the destination is left as it
+// was. A file patch that does not apply cleanly is retried as a three-way
+// merge, which can leave conflict markers to resolve.
+func (m *Manager) Pick(ctx context.Context, opts PickOptions) (PickResult, error) {
+	if len(opts.Files) == 0 && len(opts.Commits) == 0 {
+		return PickResult{}, fmt.Errorf("%w: pass files or commits to pick", errInvalidArguments)
+	}
+	if len(opts.Files) > 0 && len(opts.Commits) > 0 {
+		return PickResult{}, fmt.Errorf("%w: pick files or commits, not both", errInvalidArguments)
+	}
+	repoRoot, err := m.RequireRepo()
+	if err != nil {
+		return PickResult{}, err
+	}
+	src, err := m.findWorktree(ctx, opts.Source)
+	if err != nil {
+		return PickResult{}, err
+	}
+	dst, err := m.findWorktree(ctx, opts.Dest)
+	if err != nil {
+		return PickResult{}, err
+	}
+	if src.Path == dst.Path {
+		return PickResult{}, errors.New("source and destination are the same worktree")
+	}
+	res := PickResult{Source: src.Path, Dest: dst.Path}
+	if len(opts.Commits) > 0 {
+		commits, err := m.pickCommits(ctx, src, dst, opts.Commits)
+		res.Commits = commits
+		return res, err
+	}
+
+	since := opts.Since
+	if since == "" {
+		since = m.branchDiffBase(repoRoot, src)
+	}
+	conflicts, err := m.pickFiles(src, dst, since, opts.Files)
+	res.Files = opts.Files
+	res.Conflicts = conflicts
+	return res, err
+}
+
+func (m *Manager) pickFiles(src, dst *Worktree, since string, files []string) ([]string, error) {
+	changed, err := m.WorktreeDiffFilesAgainst(src.Path, since)
+	if err != nil {
+		return nil, err
+	}
+	byPath := map[string]DiffFile{}
+	for _, f := range changed {
+		byPath[f.Path] = f
+	}
+	var patch bytes.Buffer
+	for _, name := range files {
+		file, ok := byPath[name]
+		if !ok {
+			return nil, fmt.Errorf("%s has no changes to %s", worktreeBranchOrName(src), name)
+		}
+		p, err := m.applicablePatch(src.Path, since, file)
+		if err != nil {
+			return nil, err
+		}
+		patch.WriteString(p)
+		if !strings.HasSuffix(p, "\n") {
+			patch.WriteString("\n")
+		}
+	}
+
+	_, err = m.runCmdBytesInput(dst.Path, patch.Bytes(), "git", "apply", "--whitespace=nowarn")
+	if err == nil {
+		infoLogf("pick files src=%q dst=%q files=%q", src.Path, dst.Path, files)
+		return nil, nil
+	}
+	debugLogf("pick git apply failed, trying a three-way apply dst=%q: %v", dst.Path, err)
+	if _, err := m.runCmdBytesInput(dst.Path, patch.Bytes(), "git", "apply", "--3way", "--whitespace=nowarn"); err != nil {
+		conflicts := m.unmergedFiles(dst.Path)
+		if len(conflicts) == 0 {
+			return nil, fmt.Errorf("the changes do not apply to %s: %w", worktreeBranchOrName(dst), err)
+		}
+		return conflicts, fmt.Errorf("applied to %s with conflicts in %s; resolve them there", worktreeBranchOrName(dst), strings.Join(conflicts, ", "))
+	}
+	infoLogf("pick files 3way src=%q dst=%q files=%q", src.Path, dst.Path, files)
+	return nil, nil
+}
+
+// applicablePatch is the patch of one file between rev and the working tree,
+// binary changes included, for git apply.
+func (m *Manager) applicablePatch(path, rev string, file DiffFile) (string, error) {
+	if file.Status == "??" {
+		return m.runCmdOutputAllowExitCodes(path, []int{1}, "git", "--no-pager", "diff", "--no-index", "--binary", "--no-color", "--no-ext-diff", "--", "/dev/null", file.Path)
+	}
+	return m.runCmdOutput(path, "git", "--no-pager", "diff", "--binary", "--no-color", "--no-ext-diff", rev, "--", file.Path)
+}
+
+func (m *Manager) pickCommits(ctx context.Context, src, dst *Worktree, commits []string) ([]string, error) {
+	if dst.Branch == "" {
+		return nil, errors.New("cannot cherry-pick onto a detached worktree")
+	}
+	if m.WorktreeDirty(ctx, dst.Path) {
+		return nil, fmt.Errorf("worktree has uncommitted changes: %s (commit or stash them first)", dst.Path)
+	}
+	hashes := make([]string, 0, len(commits))
+	for _, c := range commits {
+		hash, err := m.runCmdOutput(src.Path, "git", "rev-parse", "--verify", "--quiet", c+"^{commit}")
+		if err != nil || strings.TrimSpace(hash) == "" {
+			return nil, fmt.Errorf("no such commit in %s: %s", worktreeBranchOrName(src), c)
+		}
+		hashes = append(hashes, strings.TrimSpace(hash))
+	}
+	before, err := m.runCmdOutput(dst.Path, "git", "rev-parse", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	before = strings.TrimSpace(before)
+
+	args := append([]string{"cherry-pick", "--allow-empty"}, hashes...)
+	if err := m.runCmdQuietContext(ctx, dst.Path, "git", args...); err != nil {
+		conflicts := m.unmergedFiles(dst.Path)
+		if abortErr := m.runCmdQuiet(dst.Path, "git", "cherry-pick", "--abort"); abortErr != nil {
+			errorLogf("pick cherry-pick --abort failed dst=%q: %v", dst.Path, abortErr)
+		}
+		if len(conflicts) > 0 {
+			return nil, fmt.Errorf("cherry-pick onto %s would conflict in %s; nothing was changed", worktreeBranchOrName(dst), strings.Join(conflicts, ", "))
+		}
+		return nil, err
+	}
+	out, err := m.runCmdOutput(dst.Path, "git", "rev-list", "--reverse", before+"..HEAD")
+	if err != nil {
+		return nil, err
+	}
+	infoLogf("pick commits src=%q dst=%q commits=%q", src.Path, dst.Path, hashes)
+	return strings.Fields(out), nil
+}
+
+// unmergedFiles lists the files left with conflicts in a worktree.
+func (m *Manager) unmergedFiles(path string) []string {
+	out, err := m.runCmdOutput(path, "git", "diff", "--name-only", "--diff-filter=U")
+	if err != nil {
+		return nil
+	}
+	files := []string{}
+	for _, line := range strings.Split(out, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			files = append(files, line)
+		}
+	}
+	return files
+}
@@ -0,0 +1,126 @@
+package sprout
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// errPickCanceled is returned by pickWorktree when the picker is closed
+// without choosing a worktree.
+var errPickCanceled = errors.New("no worktree picked")
+
+// pickWorktree lets the user choose one of items in a small full-screen
+// picker: typing filters the worktrees by fuzzy match on their branch, the
+// arrow keys move, enter chooses and esc cancels. query is the filter it
+// opens with.
+func pickWorktree(items []Worktree, query string) (*Worktree, error) {
+	if len(items) == 0 {
+		return nil, errors.New("no worktrees to pick from")
+	}
+	app := tview.NewApplication()
+
+	input := tview.NewInputField().SetText(query)
+	styleModalInputField(input)
+	input.SetLabel("> ").SetLabelColor(ansiColor(ansiCyan))
+	input.SetPlaceholder("type to filter worktrees")
+	input.SetPlaceholderTextColor(paneBorderColor())
+
+	table := tview.NewTable().SetSelectable(true, false).SetBorders(false)
+	table.SetSeparator(' ')
+	table.SetBackgroundColor(tcell.ColorDefault)
+	table.SetSelectedStyle(tcell.StyleDefault.Foreground(tcell.ColorDefault).Background(tcell.ColorDefault).Reverse(true))
+	table.SetBorder(true)
+	table.SetBorderColor(paneBorderColor())
+
+	hints := tview.NewTextView().SetDynamicColors(true).SetWrap(false)
+	hints.SetTextColor(paneBorderColor())
+	hints.SetBackgroundColor(tcell.ColorDefault)
+	hints.SetText(" ↑↓ navigate  enter select  esc cancel")
+
+	var shown []*Worktree
+	rebuild := func(query string) {
+		type match struct {
+			wt        *Worktree
+			score     int
+			positions []int
+		}
+		q := strings.ToLower(strings.TrimSpace(query))
+		matches := make([]match, 0, len(items))
+		for i := range items {
+			score, positions, ok := fuzzyMatch(q, worktreeBranchOrName(&items[i]))
+			if !ok {
+				continue
+			}
+			matches = append(matches, match{wt: &items[i], score: score, positions: positions})
+		}
+		sort.SliceStable(matches, func(i, j int) bool { return matches[i].score > matches[j].score })
+
+		table.Clear()
+		shown = shown[:0]
+		now := time.Now()
+		for row, m := range matches {
+			name := worktreeBranchOrName(m.wt)
+			session := ""
+			if m.wt.TmuxState == "yes" {
+				session = "session"
+			}
+			table.SetCell(row, 0, tview.NewTableCell(highlightMatches(name, m.positions, len(name))).SetExpansion(1))
+			table.SetCell(row, 1, tview.NewTableCell(session).SetTextColor(ansiColor(ansiGreen)))
+			table.SetCell(row, 2, tview.NewTableCell(formatLastActive(m.wt.LastActive, now)).SetTextColor(paneBorderColor()))
+			table.SetCell(row, 3, tview.NewTableCell(tview.Escape(filepath.Base(m.wt.Path))).SetTextColor(paneBorderColor()))
+			shown = append(shown, m.wt)
+		}
+		table.SetTitle(fmt.Sprintf(" %d of %d ", len(shown), len(items)))
+		if len(shown) > 0 {
+			table.Select(0, 0)
+		}
+	}
+	rebuild(query)
+	input.SetChangedFunc(rebuild)
+
+	var picked *Worktree
+	input.SetInputCapture(func(ev *tcell.EventKey) *tcell.EventKey {
+		row, _ := table.GetSelection()
+		switch ev.Key() {
+		case tcell.KeyEscape, tcell.KeyCtrlC:
+			app.Stop()
+			return nil
+		case tcell.KeyEnter:
+			if row >= 0 && row < len(shown) {
+				picked = shown[row]
+				app.Stop()
+			}
+			return nil
+		case tcell.KeyUp, tcell.KeyCtrlP:
+			if row > 0 {
+				table.Select(row-1, 0)
+			}
+			return nil
+		case tcell.KeyDown, tcell.KeyCtrlN:
+			if row < len(shown)-1 {
+				table.Select(row+1, 0)
+			}
+			return nil
+		}
+		return ev
+	})
+
+	root := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(input, 1, 0, true).
+		AddItem(table, 0, 1, false).
+		AddItem(hints, 1, 0, false)
+	if err := app.SetRoot(root, true).SetFocus(input).Run(); err != nil {
+		return nil, err
+	}
+	if picked == nil {
+		return nil, errPickCanceled
+	}
+	return picked, nil
+}
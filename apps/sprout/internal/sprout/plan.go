@@ -0,0 +1,395 @@
+package sprout
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// branchTaskKey is the git config variable, under branch.<name>, that holds
+// the task a branch was planned for by sprout plan.
+const branchTaskKey = "sprouttask"
+
+// PlanTask is one task of a task list.
+type PlanTask struct {
+	Title string `json:"title"`
+	// Prompt is the task text sent to the agent; it defaults to the title.
+	Prompt string `json:"prompt"`
+	// Type is the branch type; empty uses the type sprout plan was given.
+	Type string `json:"type,omitempty"`
+}
+
+type PlanOptions struct {
+	Tasks      []PlanTask
+	Type       string
+	BaseBranch string
+	// StartAgent starts an agent in each new worktree and sends it the task.
+	StartAgent bool
+	AgentType  string
+}
+
+// PlanResult is the outcome of one task.
+type PlanResult struct {
+	Title  string `json:"title"`
+	Branch string `json:"branch,omitempty"`
+	Path   string `json:"path,omitempty"`
+	// Existing is set when the task's worktree was already there; it is left
+	// alone, so running a plan twice does not prompt agents twice.
+	Existing bool   `json:"existing,omitempty"`
+	Prompted bool   `json:"prompted"`
+	Error    string `json:"error,omitempty"`
+}
+
+// ReadTaskList reads a task list file. YAML files (.yaml, .yml) hold a list
+// of task titles or of mappings with title, prompt, and type, optionally
+// under a tasks key. Anything else is read as markdown: each top-level list
+// item is a task, its indented lines are the rest of the prompt, and checked
+// items (- [x]) are skipped as done.
+func ReadTaskList(path string) ([]PlanTask, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	var tasks []PlanTask
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		tasks, err = parseYAMLTasks(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	default:
+		tasks = parseMarkdownTasks(text)
+	}
+	if len(tasks) == 0 {
+		return nil, fmt.Errorf("%s: no tasks found", path)
+	}
+	return tasks, nil
+}
+
+var (
+	markdownItemRe  = regexp.MustCompile(`^(?:[-*+]|\d+[.)])\s+(.*)$`)
+	markdownCheckRe = regexp.MustCompile(`^\[([ xX])\]\s*`)
+)
+
+func parseMarkdownTasks(text string) []PlanTask {
+	var tasks []PlanTask
+	var cur *PlanTask
+	var body []string
+	done := false
+	finish := func() {
+		if cur != nil && !done {
+			cur.Prompt = joinTaskPrompt(cur.Title, dedent(body))
+			tasks = append(tasks, *cur)
+		}
+		cur, body, done = nil, nil, false
+	}
+	for _, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) == "" {
+			if cur != nil {
+				body = append(body, "")
+			}
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			if cur != nil {
+				body = append(body, line)
+			}
+			continue
+		}
+		finish()
+		match := markdownItemRe.FindStringSubmatch(line)
+		if match == nil {
+			// Headings and paragraphs between lists are not tasks.
+			continue
+		}
+		title := match[1]
+		if check := markdownCheckRe.FindStringSubmatch(title); check != nil {
+			done = check[1] != " "
+			title = title[len(check[0]):]
+		}
+		if title = strings.TrimSpace(title); title == "" {
+			continue
+		}
+		cur = &PlanTask{Title: title}
+	}
+	finish()
+	return tasks
+}
+
+// dedent strips the indentation common to the non-blank lines and trims
+// surrounding blank lines.
+func dedent(lines []string) string {
+	common := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+		if common < 0 || indent < common {
+			common = indent
+		}
+	}
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		if len(line) >= common && common > 0 {
+			line = line[common:]
+		}
+		out[i] = strings.TrimRight(line, " \t")
+	}
+	return strings.Trim(strings.Join(out, "\n"), "\n")
+}
+
+// joinTaskPrompt is the prompt of a task: its title, followed by its details
+// when it has any.
+func joinTaskPrompt(title, details string) string {
+	if details = strings.TrimSpace(details); details == "" {
+		return title
+	}
+	return title + "\n\n" + details
+}
+
+var yamlKeyRe = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_-]*):(?:\s+(.*))?$`)
+
+// parseYAMLTasks reads the small subset of YAML a task list needs: a
+// sequence of plain or quoted strings, or of flat mappings whose values may
+// be block scalars (| or >).
+func parseYAMLTasks(text string) ([]PlanTask, error) {
+	lines := strings.Split(text, "\n")
+	var tasks []PlanTask
+	var cur *PlanTask
+	itemIndent := -1
+	finish := func() error {
+		if cur == nil {
+			return nil
+		}
+		if cur.Title == "" {
+			// A task given only as a prompt is titled by its first line.
+			cur.Title, _, _ = strings.Cut(cur.Prompt, "\n")
+		}
+		if cur.Title == "" {
+			return fmt.Errorf("task %d has no title", len(tasks)+1)
+		}
+		if cur.Prompt == "" {
+			cur.Prompt = cur.Title
+		}
+		tasks = append(tasks, *cur)
+		cur = nil
+		return nil
+	}
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], " \t")
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || line == "---" {
+			continue
+		}
+		if trimmed[0] == '\t' {
+			return nil, fmt.Errorf("line %d: indent with spaces, not tabs", i+1)
+		}
+		indent := len(line) - len(trimmed)
+		if line == "tasks:" && itemIndent < 0 {
+			continue
+		}
+
+		keyIndent := indent
+		if trimmed == "-" || strings.HasPrefix(trimmed, "- ") {
+			if itemIndent < 0 {
+				itemIndent = indent
+			}
+			if indent != itemIndent {
+				return nil, fmt.Errorf("line %d: nested lists are not supported", i+1)
+			}
+			if err := finish(); err != nil {
+				return nil, err
+			}
+			cur = &PlanTask{}
+			trimmed = strings.TrimSpace(trimmed[1:])
+			if trimmed == "" {
+				continue
+			}
+			if yamlKeyRe.FindStringSubmatch(trimmed) == nil {
+				title, err := yamlScalar(trimmed)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", i+1, err)
+				}
+				cur.Title = title
+				continue
+			}
+			keyIndent = indent + 2
+		} else if cur == nil || indent <= itemIndent {
+			return nil, fmt.Errorf("line %d: expected a list item (- <task>)", i+1)
+		}
+
+		match := yamlKeyRe.FindStringSubmatch(trimmed)
+		if match == nil {
+			return nil, fmt.Errorf("line %d: expected <key>: <value>", i+1)
+		}
+		value := match[2]
+		var err error
+		if value == "|" || value == "|-" || value == "|+" || value == ">" || value == ">-" || value == ">+" {
+			var block []string
+			for i+1 < len(lines) {
+				next := strings.TrimRight(lines[i+1], " \t")
+				if strings.TrimSpace(next) != "" && len(next)-len(strings.TrimLeft(next, " ")) <= keyIndent {
+					break
+				}
+				block = append(block, next)
+				i++
+			}
+			value = dedent(block)
+			if match[2][0] == '>' {
+				value = foldYAMLBlock(value)
+			}
+		} else if value, err = yamlScalar(value); err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		switch match[1] {
+		case "title":
+			cur.Title = value
+		case "prompt":
+			cur.Prompt = value
+		case "type":
+			cur.Type = value
+		default:
+			return nil, fmt.Errorf("line %d: unknown key %q (want title, prompt, or type)", i+1, match[1])
+		}
+	}
+	if err := finish(); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// yamlScalar reads a plain, single-quoted, or double-quoted YAML scalar.
+func yamlScalar(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	switch {
+	case value == "":
+		return "", nil
+	case value[0] == '"':
+		end := 1
+		for ; end < len(value); end++ {
+			if value[end] == '\\' {
+				end++
+			} else if value[end] == '"' {
+				break
+			}
+		}
+		if end >= len(value) {
+			return "", fmt.Errorf("unterminated string %s", value)
+		}
+		return strconv.Unquote(value[:end+1])
+	case value[0] == '\'':
+		var b strings.Builder
+		for i := 1; i < len(value); i++ {
+			if value[i] != '\'' {
+				b.WriteByte(value[i])
+				continue
+			}
+			if i+1 < len(value) && value[i+1] == '\'' {
+				b.WriteByte('\'')
+				i++
+				continue
+			}
+			return b.String(), nil
+		}
+		return "", fmt.Errorf("unterminated string %s", value)
+	}
+	if before, _, found := strings.Cut(value, " #"); found {
+		value = before
+	}
+	return strings.TrimSpace(value), nil
+}
+
+// foldYAMLBlock joins the lines of a folded (>) block scalar: single line
+// breaks become spaces and blank lines become line breaks.
+func foldYAMLBlock(value string) string {
+	paragraphs := strings.Split(value, "\n\n")
+	for i, p := range paragraphs {
+		paragraphs[i] = strings.Join(strings.Fields(p), " ")
+	}
+	return strings.Join(paragraphs, "\n")
+}
+
+// Plan creates a worktree for each task, with the branch named after the
+// task title, and records the task in the branch's config. With
+// opts.StartAgent it starts an agent in each new worktree and, once the
+// agents are ready for input, sends each its task. Tasks whose worktree
+// already exists are left alone. It keeps going past failures, which are
+// reported per task.
+func (m *Manager) Plan(ctx context.Context, opts PlanOptions) ([]PlanResult, error) {
+	repoRoot, err := m.RequireRepo()
+	if err != nil {
+		return nil, err
+	}
+	results := make([]PlanResult, len(opts.Tasks))
+	started := []int{}
+	for i, task := range opts.Tasks {
+		res := &results[i]
+		res.Title = task.Title
+		if err := ctx.Err(); err != nil {
+			res.Error = err.Error()
+			continue
+		}
+		branchType := task.Type
+		if branchType == "" {
+			branchType = opts.Type
+		}
+		req, err := m.ValidateNew(NewOptions{Type: branchType, Name: task.Title, BaseBranch: opts.BaseBranch})
+		if err != nil {
+			errorLogf("plan validate failed title=%q: %v", task.Title, err)
+			res.Error = err.Error()
+			continue
+		}
+		res.Branch = req.Branch
+		if req.ExistingPath != "" {
+			res.Path, res.Existing = req.ExistingPath, true
+			continue
+		}
+		if _, res.Path, err = m.CreateWorktree(ctx, req); err != nil {
+			errorLogf("plan create failed title=%q branch=%q: %v", task.Title, req.Branch, err)
+			res.Error = err.Error()
+			continue
+		}
+		if err := m.runCmdQuiet(repoRoot, "git", "config", "branch."+res.Branch+"."+branchTaskKey, task.Prompt); err != nil {
+			errorLogf("plan record_task failed branch=%q: %v", res.Branch, err)
+		}
+		infoLogf("plan created title=%q branch=%q path=%q", task.Title, res.Branch, res.Path)
+		if !opts.StartAgent {
+			continue
+		}
+		if _, _, err := m.StartAgent(ctx, AgentOptions{Target: res.Path, AgentType: opts.AgentType}); err != nil {
+			errorLogf("plan start_agent failed path=%q: %v", res.Path, err)
+			res.Error = fmt.Sprintf("created, but the agent did not start: %v", err)
+			continue
+		}
+		started = append(started, i)
+	}
+
+	// Agents take a while to be ready for input, so wait for them together.
+	var wg sync.WaitGroup
+	for _, i := range started {
+		wg.Add(1)
+		go func(res *PlanResult, prompt string) {
+			defer wg.Done()
+			if err := m.sendWhenReady(ctx, res.Path, prompt, agentPromptTimeout); err != nil {
+				errorLogf("plan prompt failed path=%q: %v", res.Path, err)
+				res.Error = fmt.Sprintf("created, but the task was not sent: %v", err)
+				return
+			}
+			res.Prompted = true
+		}(&results[i], opts.Tasks[i].Prompt)
+	}
+	wg.Wait()
+	return results, nil
+}
+
+// branchTasks reads the task recorded for each branch by sprout plan.
+func (m *Manager) branchTasks(repoRoot string) map[string]string {
+	return m.branchConfigValues(repoRoot, branchTaskKey)
+}
@@ -0,0 +1,110 @@
+package sprout
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// branchPRKey is the git config variable, under branch.<name>, that records
+// the pull request a branch was created from.
+const branchPRKey = "sproutpr"
+
+const prRemote = "origin"
+
+// pullRequestHead describes where a pull request's commits live, as reported
+// by gh pr view.
+type pullRequestHead struct {
+	HeadRefName       string `json:"headRefName"`
+	IsCrossRepository bool   `json:"isCrossRepository"`
+}
+
+// lookupPullRequest asks gh for the head branch of a pull request. It returns
+// false when gh is missing or cannot answer, e.g. when it is not logged in.
+func (m *Manager) lookupPullRequest(ctx context.Context, repoRoot string, number int) (pullRequestHead, bool) {
+	var head pullRequestHead
+	if !m.commandExists("gh") {
+		return head, false
+	}
+	out, err := m.runCmdOutputContext(ctx, repoRoot, "gh", "pr", "view", strconv.Itoa(number), "--json", "headRefName,isCrossRepository")
+	if err != nil {
+		debugLogf("from_pr gh pr view failed number=%d: %v", number, err)
+		return head, false
+	}
+	if err := json.Unmarshal([]byte(out), &head); err != nil {
+		debugLogf("from_pr gh pr view output unreadable number=%d: %v", number, err)
+		return head, false
+	}
+	return head, strings.TrimSpace(head.HeadRefName) != ""
+}
+
+// FetchPullRequest fetches GitHub pull request number from origin into a local
+// branch that tracks it and returns the branch name. A pull request from a
+// branch of origin itself checks out that branch; one from a fork, or any
+// pull request when gh is not available, becomes pr/<number> tracking
+// refs/pull/<number>/head, so git pull picks up new pushes.
+func (m *Manager) FetchPullRequest(ctx context.Context, number int) (string, error) {
+	if number <= 0 {
+		return "", fmt.Errorf("%w: invalid pull request number %d", errInvalidArguments, number)
+	}
+	repoRoot, err := m.RequireRepo()
+	if err != nil {
+		return "", err
+	}
+	if _, err := m.runCmdOutput(repoRoot, "git", "remote", "get-url", prRemote); err != nil {
+		return "", fmt.Errorf("pull requests are fetched from %s, but this repository has no %s remote", prRemote, prRemote)
+	}
+
+	var branch string
+	if head, ok := m.lookupPullRequest(ctx, repoRoot, number); ok && !head.IsCrossRepository {
+		branch = head.HeadRefName
+		if existing, found, _ := m.findExistingWorktreePath(repoRoot, branch, ""); found {
+			infoLogf("from_pr existing worktree number=%d branch=%q path=%q", number, branch, existing)
+			return branch, nil
+		}
+		remoteRef := "refs/remotes/" + prRemote + "/" + branch
+		if err := m.runCmdQuietContext(ctx, repoRoot, "git", "fetch", prRemote, "+refs/heads/"+branch+":"+remoteRef); err != nil {
+			return "", fmt.Errorf("fetch pull request #%d (%s): %w", number, branch, err)
+		}
+		if !m.BranchExists(repoRoot, branch) {
+			if err := m.runCmdQuietContext(ctx, repoRoot, "git", "branch", "--track", branch, prRemote+"/"+branch); err != nil {
+				return "", err
+			}
+		}
+	} else {
+		branch = "pr/" + strconv.Itoa(number)
+		if existing, found, _ := m.findExistingWorktreePath(repoRoot, branch, ""); found {
+			infoLogf("from_pr existing worktree number=%d branch=%q path=%q", number, branch, existing)
+			return branch, nil
+		}
+		// Without a leading +, git refuses to move an existing pr/<number>
+		// that has local commits the pull request does not.
+		pullRef := "refs/pull/" + strconv.Itoa(number) + "/head"
+		if err := m.runCmdQuietContext(ctx, repoRoot, "git", "fetch", prRemote, pullRef+":refs/heads/"+branch); err != nil {
+			return "", fmt.Errorf("fetch pull request #%d: %w", number, err)
+		}
+		for key, value := range map[string]string{"remote": prRemote, "merge": pullRef} {
+			if err := m.runCmdQuiet(repoRoot, "git", "config", "branch."+branch+"."+key, value); err != nil {
+				return "", err
+			}
+		}
+	}
+	if err := m.runCmdQuiet(repoRoot, "git", "config", "branch."+branch+"."+branchPRKey, strconv.Itoa(number)); err != nil {
+		return "", err
+	}
+	infoLogf("from_pr fetched number=%d branch=%q", number, branch)
+	return branch, nil
+}
+
+// branchPRs reads the pull request number recorded for each branch.
+func (m *Manager) branchPRs(repoRoot string) map[string]int {
+	res := map[string]int{}
+	for branch, value := range m.branchConfigValues(repoRoot, branchPRKey) {
+		if n, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && n > 0 {
+			res[branch] = n
+		}
+	}
+	return res
+}
@@ -0,0 +1,197 @@
+package sprout
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Worktree priorities. Normal is the default and is never stored.
+const (
+	priorityHigh   = "high"
+	priorityNormal = "normal"
+	priorityLow    = "low"
+)
+
+// branchPriorityKey is the git config variable, under branch.<name>, that
+// holds a branch's priority. Keeping it in branch config means it follows
+// the branch through `git branch -m`.
+const branchPriorityKey = "sproutpriority"
+
+func parsePriority(value string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "high", "h":
+		return priorityHigh, nil
+	case "", "normal", "n":
+		return priorityNormal, nil
+	case "low", "l":
+		return priorityLow, nil
+	}
+	return "", fmt.Errorf("invalid priority %q (want high, normal, or low)", value)
+}
+
+func priorityRank(priority string) int {
+	switch priority {
+	case priorityHigh:
+		return 0
+	case priorityLow:
+		return 2
+	}
+	return 1
+}
+
+// worktreePriority returns the priority of wt, treating unset as normal.
+func worktreePriority(wt Worktree) string {
+	if wt.Priority == "" {
+		return priorityNormal
+	}
+	return wt.Priority
+}
+
+// branchConfigValues reads the branch.<name>.<key> variable of every branch
+// that sets it, keyed by branch name.
+func (m *Manager) branchConfigValues(repoRoot, key string) map[string]string {
+	res := map[string]string{}
+	// -z separates entries with NUL and name from value with a newline, so
+	// values can span lines.
+	out, err := m.runCmdOutputAllowExitCodes(repoRoot, []int{1}, "git", "config", "-z", "--get-regexp", `^branch\..*\.`+key+`$`)
+	if err != nil {
+		debugLogf("branch_config read failed repo=%q key=%s: %v", repoRoot, key, err)
+		return res
+	}
+	for _, entry := range strings.Split(out, "\x00") {
+		name, value, ok := strings.Cut(entry, "\n")
+		if !ok {
+			continue
+		}
+		res[strings.TrimSuffix(strings.TrimPrefix(name, "branch."), "."+key)] = value
+	}
+	return res
+}
+
+// branchPriorities reads every stored branch priority of a repository.
+func (m *Manager) branchPriorities(repoRoot string) map[string]string {
+	res := map[string]string{}
+	for branch, value := range m.branchConfigValues(repoRoot, branchPriorityKey) {
+		if priority, err := parsePriority(value); err == nil && priority != priorityNormal {
+			res[branch] = priority
+		}
+	}
+	return res
+}
+
+// SetPriority stores the priority of the target worktree's branch. Normal
+// clears the stored value.
+func (m *Manager) SetPriority(target, priority string) (string, error) {
+	priority, err := parsePriority(priority)
+	if err != nil {
+		return "", err
+	}
+	repoRoot, err := m.RequireRepo()
+	if err != nil {
+		return "", err
+	}
+	wt, err := m.findWorktreeLite(repoRoot, target)
+	if err != nil {
+		return "", err
+	}
+	if wt.Branch == "" {
+		return "", fmt.Errorf("cannot set priority on detached worktree: %s", wt.Path)
+	}
+	key := "branch." + wt.Branch + "." + branchPriorityKey
+	if priority == priorityNormal {
+		// Exit status 5 means the key was not set.
+		if _, err := m.runCmdOutputAllowExitCodes(repoRoot, []int{5}, "git", "config", "--unset", key); err != nil {
+			return "", err
+		}
+	} else if err := m.runCmdQuiet(repoRoot, "git", "config", key, priority); err != nil {
+		return "", err
+	}
+	infoLogf("set_priority done path=%q branch=%q priority=%s", wt.Path, wt.Branch, priority)
+	return wt.Path, nil
+}
+
+// sortByPriority orders items high priority first, keeping the existing
+// order within a priority. Bulk operations use it so urgent worktrees are
+// handled before the rest.
+func sortByPriority(items []Worktree) {
+	sort.SliceStable(items, func(i, j int) bool {
+		return priorityRank(worktreePriority(items[i])) < priorityRank(worktreePriority(items[j]))
+	})
+}
+
+// WIPStatus reports how many linked worktrees count toward the WIP limit.
+type WIPStatus struct {
+	Count int
+	Limit int
+	// Candidates are worktrees to finish or prune first: lowest priority,
+	// then clean before dirty.
+	Candidates []Worktree
+}
+
+// Exceeded reports whether creating another worktree would go past the limit.
+func (s WIPStatus) Exceeded() bool {
+	return s.Limit > 0 && s.Count >= s.Limit
+}
+
+// WIPStatus counts the linked worktrees of the current repository against
+// the configured wip_limit. The main worktree does not count.
+func (m *Manager) WIPStatus() (WIPStatus, error) {
+	status := WIPStatus{Limit: m.Cfg.WIPLimit}
+	if status.Limit <= 0 {
+		return status, nil
+	}
+	repoRoot, err := m.RequireRepo()
+	if err != nil {
+		return status, err
+	}
+	items, err := m.parseWorktreeList(repoRoot)
+	if err != nil {
+		return status, err
+	}
+	priorities := m.branchPriorities(repoRoot)
+	linked := []Worktree{}
+	for i, wt := range items {
+		if i == 0 {
+			continue
+		}
+		wt.Priority = priorities[wt.Branch]
+		wt.Dirty = m.WorktreeDirty(context.Background(), wt.Path)
+		linked = append(linked, wt)
+	}
+	status.Count = len(linked)
+	sort.SliceStable(linked, func(i, j int) bool {
+		ri, rj := priorityRank(worktreePriority(linked[i])), priorityRank(worktreePriority(linked[j]))
+		if ri != rj {
+			return ri > rj
+		}
+		return !linked[i].Dirty && linked[j].Dirty
+	})
+	status.Candidates = linked
+	return status, nil
+}
+
+// wipLimitMessage explains a reached WIP limit and names what to finish first.
+func wipLimitMessage(status WIPStatus, maxCandidates int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "WIP limit reached (%d/%d worktrees); finish or prune one first", status.Count, status.Limit)
+	if len(status.Candidates) == 0 {
+		return b.String()
+	}
+	names := []string{}
+	for i, wt := range status.Candidates {
+		if i == maxCandidates {
+			break
+		}
+		name := worktreeBranchOrName(&wt)
+		name += " (" + worktreePriority(wt)
+		if wt.Dirty {
+			name += ", dirty"
+		}
+		names = append(names, name+")")
+	}
+	b.WriteString(": ")
+	b.WriteString(strings.Join(names, ", "))
+	return b.String()
+}
@@ -0,0 +1,142 @@
+package sprout
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How long cached git queries are trusted. Queries about a worktree's files
+// see uncommitted changes, which no key catches, so they live the shortest.
+const (
+	queryCacheMaxEntries = 1024
+	repoNameCacheTTL     = 10 * time.Minute
+	branchExistsCacheTTL = 5 * time.Second
+	branchListCacheTTL   = 30 * time.Second
+	aheadBehindCacheTTL  = time.Minute
+	logCacheTTL          = 2 * time.Second
+	symbolCacheTTL       = 5 * time.Second
+)
+
+// queryCache memoizes expensive git queries for a Manager, so the TUI's
+// redraws and a CLI command's repeated lookups run them once. An entry is
+// keyed by its repository, the HEAD of the directory it was run in, and the
+// query, and is dropped when its TTL passes or its repository is
+// invalidated: after a sprout operation changes refs or worktrees, when an
+// event is emitted, or on a manual refresh.
+type queryCache struct {
+	mu      sync.Mutex
+	entries map[string]queryCacheEntry
+}
+
+type queryCacheEntry struct {
+	repo string
+	// worktree marks queries about the files of a worktree, which
+	// invalidateWorktreeQueries drops.
+	worktree  bool
+	value     any
+	err       error
+	fetchedAt time.Time
+}
+
+func newQueryCache() *queryCache {
+	return &queryCache{entries: map[string]queryCacheEntry{}}
+}
+
+// cachedRepoQuery returns what fetch returned for a query about the
+// repository at repoRoot within the last ttl, or runs it.
+func cachedRepoQuery[T any](m *Manager, repoRoot, query string, ttl time.Duration, fetch func() (T, error)) (T, error) {
+	return cachedQuery(m, repoRoot, repoRoot, query, ttl, false, fetch)
+}
+
+// cachedWorktreeQuery is cachedRepoQuery for a query about the files of the
+// worktree at dir.
+func cachedWorktreeQuery[T any](m *Manager, repoRoot, dir, query string, ttl time.Duration, fetch func() (T, error)) (T, error) {
+	return cachedQuery(m, repoRoot, dir, query, ttl, true, fetch)
+}
+
+// cachedQuery returns what fetch returned for query in dir of repoRoot
+// within the last ttl, or runs it. Errors are cached like values, so a
+// failing query is not retried on every redraw.
+func cachedQuery[T any](m *Manager, repoRoot, dir, query string, ttl time.Duration, worktree bool, fetch func() (T, error)) (T, error) {
+	c := m.queries
+	if c == nil {
+		return fetch()
+	}
+	key := strings.Join([]string{repoRoot, dir, m.headStamp(dir), query}, "\x00")
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && time.Since(entry.fetchedAt) <= ttl {
+		value, _ := entry.value.(T)
+		return value, entry.err
+	}
+
+	value, err := fetch()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= queryCacheMaxEntries {
+		c.entries = map[string]queryCacheEntry{}
+	}
+	c.entries[key] = queryCacheEntry{
+		repo:      repoRoot,
+		worktree:  worktree,
+		value:     value,
+		err:       err,
+		fetchedAt: time.Now(),
+	}
+	return value, err
+}
+
+// InvalidateQueries drops the cached git queries of repoRoot, or of every
+// repository when repoRoot is empty.
+func (m *Manager) InvalidateQueries(repoRoot string) {
+	m.dropQueries(func(e queryCacheEntry) bool { return repoRoot == "" || e.repo == repoRoot })
+}
+
+// invalidateWorktreeQueries drops the cached queries about worktree files,
+// keeping the repository-wide ones.
+func (m *Manager) invalidateWorktreeQueries() {
+	m.dropQueries(func(e queryCacheEntry) bool { return e.worktree })
+}
+
+func (m *Manager) dropQueries(match func(queryCacheEntry) bool) {
+	c := m.queries
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, entry := range c.entries {
+		if match(entry) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// headStamp identifies the HEAD of the worktree at dir without running git:
+// what HEAD points at, and the size and time of its reflog, which grows
+// with every commit, reset, and checkout. It is empty when dir has no git
+// dir of its own to read, and in SSH remote mode, where reading it would
+// cost as much as the query, so entries there expire by their ttl alone.
+func (m *Manager) headStamp(dir string) string {
+	if m.sshRemoteActive() {
+		return ""
+	}
+	gitDir := m.worktreeGitDir(dir)
+	if gitDir == "" {
+		return ""
+	}
+	head, err := os.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return ""
+	}
+	stamp := strings.TrimSpace(string(head))
+	if info, err := os.Stat(filepath.Join(gitDir, "logs", "HEAD")); err == nil {
+		stamp += fmt.Sprintf(" %d %d", info.Size(), info.ModTime().UnixNano())
+	}
+	return stamp
+}
@@ -0,0 +1,185 @@
+package sprout
+
+import (
+	"errors"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Rebase states reported in Worktree.RebaseState.
+const (
+	rebaseStateRunning = "rebasing"
+	rebaseStateDone    = "rebased"
+	rebaseStateAborted = "rebase aborted"
+)
+
+type RebaseOptions struct {
+	Target     string
+	BaseBranch string
+	Attach     bool
+}
+
+func (m *Manager) tmuxRebaseWindowName(branch string) string {
+	name := "rebase-" + sessionToken(branch)
+	if len(name) > 60 {
+		return name[:60]
+	}
+	return name
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Rebase opens `git rebase -i <base>` for a worktree in a dedicated window of
+// its tmux session. It returns the worktree path and the base branch used.
+func (m *Manager) Rebase(opts RebaseOptions) (string, string, error) {
+	repoRoot, err := m.RequireRepo()
+	if err != nil {
+		return "", "", err
+	}
+	wt, err := m.findWorktreeLite(repoRoot, opts.Target)
+	if err != nil {
+		return "", "", err
+	}
+	if !m.commandExists("tmux") {
+		return "", "", errors.New("tmux is required for rebase workflows")
+	}
+	if wt.Branch == "" {
+		return "", "", errors.New("cannot rebase a detached worktree")
+	}
+	base, err := m.ResolveBaseBranch(repoRoot, opts.BaseBranch)
+	if err != nil {
+		return "", "", err
+	}
+	if base == wt.Branch {
+		return "", "", errors.New("worktree is on the base branch: " + base)
+	}
+
+	branch := worktreeBranchOrName(wt)
+	session := m.tmuxWorktreeSessionNameFrom(repoRoot, branch, wt.Path)
+	window := m.tmuxRebaseWindowName(branch)
+
+	alive, exists := m.tmuxWindowPaneState(session, window)
+	if !alive && m.worktreeRebaseInProgress(wt.Path) {
+		return "", "", errors.New("a rebase is already in progress; run `git rebase --continue` or `git rebase --abort` in " + wt.Path)
+	}
+	if exists && !alive {
+		// Drop the finished window so the new rebase starts from a fresh pane.
+		if err := m.runCmdQuiet("", "tmux", "kill-window", "-t", session+":"+window); err != nil {
+			return "", "", err
+		}
+	}
+
+	if _, _, err := m.tmuxEnsureWorktreeWindow(repoRoot, branch, wt.Path); err != nil {
+		errorLogf("rebase ensure_worktree_window failed path=%q branch=%q: %v", wt.Path, branch, err)
+		return "", "", err
+	}
+	if err := m.tmuxEnsureWindow(session, window, wt.Path, "git rebase -i "+shellQuote(base)); err != nil {
+		errorLogf("rebase ensure_window failed path=%q window=%q: %v", wt.Path, window, err)
+		return "", "", err
+	}
+	if err := m.tmuxTagWindow(session, window, wt.Path); err != nil {
+		return "", "", err
+	}
+	infoLogf("rebase start path=%q session=%q window=%q base=%q", wt.Path, session, window, base)
+
+	if opts.Attach {
+		if err := m.tmuxFocusWindow(session, window, !m.insideTmux()); err != nil {
+			return "", "", err
+		}
+	}
+	return wt.Path, base, nil
+}
+
+// worktreeRebaseInProgress reports whether git has an unfinished rebase in
+// the worktree at path.
+func (m *Manager) worktreeRebaseInProgress(path string) bool {
+	gitDir, err := m.runCmdOutput(path, "git", "rev-parse", "--absolute-git-dir")
+	if err != nil {
+		return false
+	}
+	gitDir = strings.TrimSpace(gitDir)
+	return m.anyPathExists(filepath.Join(gitDir, "rebase-merge"), filepath.Join(gitDir, "rebase-apply"))
+}
+
+// worktreeOperationInProgress reports whether a rebase, merge, cherry-pick,
+// or revert has stopped in the worktree at path. Unlike
+// worktreeRebaseInProgress it runs no git command outside SSH remote mode,
+// since every refresh asks.
+func (m *Manager) worktreeOperationInProgress(path string) bool {
+	gitDir := m.worktreeGitDir(path)
+	if gitDir == "" {
+		return false
+	}
+	var paths []string
+	for _, name := range []string{"rebase-merge", "rebase-apply", "MERGE_HEAD", "CHERRY_PICK_HEAD", "REVERT_HEAD"} {
+		paths = append(paths, filepath.Join(gitDir, name))
+	}
+	return m.anyPathExists(paths...)
+}
+
+// tmuxWindowPaneState reports whether the first pane of a window is still
+// running and whether the window exists at all.
+func (m *Manager) tmuxWindowPaneState(session, window string) (alive bool, exists bool) {
+	out, err := m.runCmdOutput("", "tmux", "list-panes", "-t", session+":"+window, "-F", "#{pane_dead}")
+	if err != nil {
+		return false, false
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	return strings.TrimSpace(lines[0]) != "1", true
+}
+
+func (m *Manager) tmuxWindowExitStatus(session, window string) (int, bool) {
+	out, err := m.runCmdOutput("", "tmux", "list-panes", "-t", session+":"+window, "-F", "#{pane_dead} #{pane_dead_status}")
+	if err != nil {
+		return 0, false
+	}
+	fields := strings.Fields(strings.Split(strings.TrimSpace(out), "\n")[0])
+	if len(fields) != 2 || fields[0] != "1" {
+		return 0, false
+	}
+	code, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return 0, false
+	}
+	return code, true
+}
+
+// worktreeRebaseState derives the rebase state shown in the status column:
+// an unfinished rebase wins, otherwise the exit status of a finished rebase
+// window tells whether it completed or was aborted.
+func (m *Manager) worktreeRebaseState(session string, wt *Worktree) string {
+	if m.worktreeRebaseInProgress(wt.Path) {
+		return rebaseStateRunning
+	}
+	if session == "" || wt.Branch == "" {
+		return ""
+	}
+	window := m.tmuxRebaseWindowName(wt.Branch)
+	alive, exists := m.tmuxWindowPaneState(session, window)
+	if !exists {
+		return ""
+	}
+	if alive {
+		return rebaseStateRunning
+	}
+	code, ok := m.tmuxWindowExitStatus(session, window)
+	if ok && code == 0 {
+		return rebaseStateDone
+	}
+	return rebaseStateAborted
+}
+
+// worktreeStatusLabel is the STATUS column value for wt. An unfinished
+// merge, cherry-pick, or revert shows as dirty even with a clean index.
+func worktreeStatusLabel(wt Worktree) string {
+	if wt.RebaseState != "" {
+		return wt.RebaseState
+	}
+	if wt.Dirty || wt.Unfinished {
+		return "dirty"
+	}
+	return "clean"
+}
@@ -0,0 +1,274 @@
+package sprout
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sshRemote selects the SSH remote mode: sprout manages the repository at
+// repo on host, running its git and tmux commands there over ssh while the
+// CLI and TUI stay local. NewManager sets it from the ssh_host and ssh_repo
+// settings; the zero value is local mode.
+type sshRemote struct {
+	host string
+	repo string
+}
+
+// remoteCommands are the commands that run on the SSH host in remote mode.
+var remoteCommands = map[string]bool{"git": true, "tmux": true, "sh": true, "mkdir": true, "mv": true, "rm": true}
+
+var remoteCommandPaths sync.Map // host and command name -> bool, found on the SSH host
+
+// remoteNotExistCode is the exit code of the remote file helpers' scripts
+// for a missing path, so it can be reported as fs.ErrNotExist.
+const remoteNotExistCode = 66
+
+// sshRemoteActive reports whether sprout is managing a repository over SSH.
+func (m *Manager) sshRemoteActive() bool {
+	return m.remote.host != ""
+}
+
+// remoteCommand rewrites a command to run on the SSH host in remote mode:
+// it becomes ssh host "cd dir && name args...", with git commands that have
+// no directory run in ssh_repo. Other commands, and every command outside
+// remote mode, are returned unchanged. Connections are shared through an ssh
+// control socket, since a refresh runs many short commands.
+func (m *Manager) remoteCommand(dir, name string, args []string, tty bool) (string, string, []string) {
+	if !m.sshRemoteActive() || !remoteCommands[name] {
+		return dir, name, args
+	}
+	if dir == "" && name == "git" {
+		dir = m.remote.repo
+	}
+	parts := make([]string, 0, len(args)+1)
+	parts = append(parts, shellQuote(name))
+	for _, arg := range args {
+		parts = append(parts, shellQuote(arg))
+	}
+	script := strings.Join(parts, " ")
+	if dir != "" {
+		script = "cd " + shellQuote(dir) + " && " + script
+	}
+	sshArgs := []string{
+		"-o", "ControlMaster=auto",
+		"-o", "ControlPath=" + filepath.Join(os.TempDir(), "sprout-ssh-%C"),
+		"-o", "ControlPersist=60",
+	}
+	if tty {
+		sshArgs = append(sshArgs, "-t")
+	} else {
+		sshArgs = append(sshArgs, "-o", "BatchMode=yes")
+	}
+	return "", "ssh", append(sshArgs, m.remote.host, script)
+}
+
+// remoteCommandExists reports whether name is installed on the SSH host.
+func (m *Manager) remoteCommandExists(name string) bool {
+	key := m.remote.host + "\x00" + name
+	if found, ok := remoteCommandPaths.Load(key); ok {
+		return found.(bool)
+	}
+	_, err := m.runCmdOutput("", "sh", "-c", `command -v "$1"`, "sh", name)
+	found := err == nil
+	remoteCommandPaths.Store(key, found)
+	return found
+}
+
+// remoteNotExist turns the failure of a remote file helper's script into an
+// fs.ErrNotExist path error when the script found no path.
+func remoteNotExist(op, path string, err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == remoteNotExistCode {
+		return &fs.PathError{Op: op, Path: path, Err: fs.ErrNotExist}
+	}
+	return err
+}
+
+// pathExists reports whether path exists, on the SSH host in remote mode.
+func (m *Manager) pathExists(path string) (bool, error) {
+	if m.sshRemoteActive() {
+		out, err := m.runCmdOutput("", "sh", "-c", `if test -e "$1"; then echo yes; else echo no; fi`, "sh", path)
+		if err != nil {
+			return false, err
+		}
+		return strings.TrimSpace(out) == "yes", nil
+	}
+	if _, err := os.Stat(path); err == nil {
+		return true, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return false, err
+	}
+	return false, nil
+}
+
+// anyPathExists reports whether any of paths exists, on the SSH host in
+// remote mode, where it costs one command for all of them.
+func (m *Manager) anyPathExists(paths ...string) bool {
+	if m.sshRemoteActive() {
+		script := `for p; do if test -e "$p"; then echo yes; exit; fi; done; echo no`
+		out, err := m.runCmdOutput("", "sh", append([]string{"-c", script, "sh"}, paths...)...)
+		return err == nil && strings.TrimSpace(out) == "yes"
+	}
+	for _, path := range paths {
+		if _, err := os.Stat(path); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// dirExists reports whether path is a directory, on the SSH host in remote
+// mode.
+func (m *Manager) dirExists(path string) bool {
+	if m.sshRemoteActive() {
+		return m.runCmdQuiet("", "sh", "-c", `test -d "$1"`, "sh", path) == nil
+	}
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// remoteFileInfo is what statPath learns about a file on the SSH host.
+type remoteFileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (i remoteFileInfo) Name() string       { return i.name }
+func (i remoteFileInfo) Size() int64        { return i.size }
+func (i remoteFileInfo) Mode() fs.FileMode  { return i.mode }
+func (i remoteFileInfo) ModTime() time.Time { return i.modTime }
+func (i remoteFileInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i remoteFileInfo) Sys() any           { return nil }
+
+// statPath is os.Stat, on the SSH host in remote mode, where the result has
+// the file's type, permissions, size, and modification time.
+func (m *Manager) statPath(path string) (fs.FileInfo, error) {
+	if !m.sshRemoteActive() {
+		return os.Stat(path)
+	}
+	// GNU and BSD stat take different flags.
+	script := `test -e "$1" || exit ` + strconv.Itoa(remoteNotExistCode) + `
+if test -d "$1"; then kind=d; elif test -f "$1"; then kind=f; else kind=o; fi
+info=$(stat -L -c '%s %Y %a' -- "$1" 2>/dev/null || stat -L -f '%z %m %Lp' -- "$1") || exit 1
+echo "$kind $info"`
+	out, err := m.runCmdOutput("", "sh", "-c", script, "sh", path)
+	if err != nil {
+		return nil, remoteNotExist("stat", path, err)
+	}
+	fields := strings.Fields(out)
+	if len(fields) != 4 {
+		return nil, fmt.Errorf("stat %s: unexpected output %q", path, out)
+	}
+	size, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("stat %s: %w", path, err)
+	}
+	sec, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("stat %s: %w", path, err)
+	}
+	perm, err := strconv.ParseUint(fields[3], 8, 32)
+	if err != nil {
+		return nil, fmt.Errorf("stat %s: %w", path, err)
+	}
+	info := remoteFileInfo{name: filepath.Base(path), size: size, mode: fs.FileMode(perm) & fs.ModePerm, modTime: time.Unix(sec, 0)}
+	switch fields[0] {
+	case "d":
+		info.mode |= fs.ModeDir
+	case "o":
+		info.mode |= fs.ModeIrregular
+	}
+	return info, nil
+}
+
+// readFile is os.ReadFile, on the SSH host in remote mode.
+func (m *Manager) readFile(path string) ([]byte, error) {
+	if !m.sshRemoteActive() {
+		return os.ReadFile(path)
+	}
+	script := `test -e "$1" || exit ` + strconv.Itoa(remoteNotExistCode) + `
+exec cat -- "$1"`
+	out, err := m.runCmdBytes("", "sh", "-c", script, "sh", path)
+	if err != nil {
+		return nil, remoteNotExist("open", path, err)
+	}
+	return out, nil
+}
+
+// readFileHead reads up to n bytes from the start of the file at path, on
+// the SSH host in remote mode.
+func (m *Manager) readFileHead(path string, n int64) ([]byte, error) {
+	if !m.sshRemoteActive() {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return io.ReadAll(io.LimitReader(f, n))
+	}
+	script := `test -e "$1" || exit ` + strconv.Itoa(remoteNotExistCode) + `
+exec head -c "$2" -- "$1"`
+	out, err := m.runCmdBytes("", "sh", "-c", script, "sh", path, strconv.FormatInt(n, 10))
+	if err != nil {
+		return nil, remoteNotExist("open", path, err)
+	}
+	return out, nil
+}
+
+// mkdirAll creates dir and its parents, on the SSH host in remote mode.
+func (m *Manager) mkdirAll(dir string) error {
+	if m.sshRemoteActive() {
+		return m.runCmdQuiet("", "mkdir", "-p", dir)
+	}
+	return os.MkdirAll(dir, 0o755)
+}
+
+// removePath is os.Remove, on the SSH host in remote mode: it removes a
+// file or an empty directory.
+func (m *Manager) removePath(path string) error {
+	if !m.sshRemoteActive() {
+		return os.Remove(path)
+	}
+	script := `test -e "$1" || test -L "$1" || exit ` + strconv.Itoa(remoteNotExistCode) + `
+if test -d "$1" && ! test -L "$1"; then exec rmdir -- "$1"; fi
+exec rm -f -- "$1"`
+	if err := m.runCmdQuiet("", "sh", "-c", script, "sh", path); err != nil {
+		return remoteNotExist("remove", path, err)
+	}
+	return nil
+}
+
+// removeAll removes path and anything under it, on the SSH host in remote
+// mode.
+func (m *Manager) removeAll(path string) error {
+	if m.sshRemoteActive() {
+		return m.runCmdQuiet("", "rm", "-rf", path)
+	}
+	return os.RemoveAll(path)
+}
+
+// renamePath moves oldPath to newPath, on the SSH host in remote mode.
+func (m *Manager) renamePath(oldPath, newPath string) error {
+	if m.sshRemoteActive() {
+		return m.runCmdQuiet("", "mv", oldPath, newPath)
+	}
+	return os.Rename(oldPath, newPath)
+}
+
+// insideTmux reports whether sprout runs inside the tmux server it manages,
+// so sessions are switched to rather than attached. A local tmux is never
+// the SSH host's.
+func (m *Manager) insideTmux() bool {
+	return os.Getenv("TMUX") != "" && !m.sshRemoteActive()
+}
@@ -0,0 +1,101 @@
+package sprout
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// RemovalRisk is what removing a worktree, and maybe its branch, could
+// lose. The TUI delete modal shows it before anything is removed.
+type RemovalRisk struct {
+	// DirtyFiles counts uncommitted and untracked files, which a removal
+	// always loses.
+	DirtyFiles int `json:"dirty_files"`
+	// Unpushed counts the branch's commits that neither a remote nor
+	// base_branch has; they are lost when the branch is deleted.
+	Unpushed int `json:"unpushed"`
+	// Stashes counts the stash entries made on the branch. They stay in the
+	// repository either way, but are easy to forget about.
+	Stashes int `json:"stashes"`
+	// Remote is the remote-tracking branch of the same name, such as
+	// origin/feat/x, or empty when no remote has the branch.
+	Remote string `json:"remote,omitempty"`
+}
+
+// AtRisk reports whether the removal would lose work: uncommitted files, or
+// unpushed commits when the branch is deleted too.
+func (r RemovalRisk) AtRisk(deleteBranch bool) bool {
+	return r.DirtyFiles > 0 || (deleteBranch && r.Unpushed > 0)
+}
+
+// Summary describes the risk in one line, e.g. "2 uncommitted files, 1
+// unpushed commit, not on any remote".
+func (r RemovalRisk) Summary(branch string) string {
+	parts := []string{plural(r.DirtyFiles, "uncommitted file"), plural(r.Unpushed, "unpushed commit"), plural(r.Stashes, "stash entry")}
+	switch {
+	case branch == "":
+	case r.Remote != "":
+		parts = append(parts, "on "+r.Remote)
+	default:
+		parts = append(parts, "not on any remote")
+	}
+	return strings.Join(parts, ", ")
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	if strings.HasSuffix(noun, "y") {
+		return fmt.Sprintf("%d %sies", n, strings.TrimSuffix(noun, "y"))
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// RemovalRisk works out what removing wt could lose. Checks that fail are
+// logged and count as nothing found.
+func (m *Manager) RemovalRisk(ctx context.Context, wt *Worktree) RemovalRisk {
+	var risk RemovalRisk
+	if files, err := m.worktreeStatus(ctx, wt.Path); err != nil {
+		debugLogf("removal_risk status failed path=%q: %v", wt.Path, err)
+	} else {
+		risk.DirtyFiles = len(files)
+	}
+	if wt.Branch == "" {
+		return risk
+	}
+
+	args := []string{"rev-list", "--count", "refs/heads/" + wt.Branch, "--not", "--remotes"}
+	if base := m.Cfg.BaseBranch; base != wt.Branch && m.BranchExists(wt.Path, base) {
+		args = append(args, "refs/heads/"+base)
+	}
+	if out, err := m.runCmdOutputContext(ctx, wt.Path, "git", args...); err != nil {
+		debugLogf("removal_risk unpushed failed path=%q: %v", wt.Path, err)
+	} else if n, err := strconv.Atoi(strings.TrimSpace(out)); err == nil {
+		risk.Unpushed = n
+	}
+
+	if out, err := m.runCmdOutputContext(ctx, wt.Path, "git", "stash", "list", "--format=%gs"); err != nil {
+		debugLogf("removal_risk stashes failed path=%q: %v", wt.Path, err)
+	} else {
+		for _, line := range strings.Split(out, "\n") {
+			if strings.HasPrefix(line, "WIP on "+wt.Branch+":") || strings.HasPrefix(line, "On "+wt.Branch+":") {
+				risk.Stashes++
+			}
+		}
+	}
+
+	if out, err := m.runCmdOutputContext(ctx, wt.Path, "git", "for-each-ref", "--format=%(refname:short)", "refs/remotes"); err != nil {
+		debugLogf("removal_risk remotes failed path=%q: %v", wt.Path, err)
+	} else {
+		for _, ref := range strings.Split(out, "\n") {
+			if _, name, ok := strings.Cut(strings.TrimSpace(ref), "/"); ok && name == wt.Branch {
+				risk.Remote = strings.TrimSpace(ref)
+				break
+			}
+		}
+	}
+	return risk
+}
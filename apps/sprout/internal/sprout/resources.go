@@ -0,0 +1,173 @@
+package sprout
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ResourceUsage is the CPU and memory use of every process running in a tmux
+// session: the pane processes and all of their descendants.
+type ResourceUsage struct {
+	// CPU is in percent of one core, so a busy session can exceed 100.
+	CPU   float64
+	RSS   int64
+	Procs int
+}
+
+type processInfo struct {
+	PID  int
+	PPID int
+	// CPU is the percentage reported by ps until a second sample turns it
+	// into the share of CPU time used since the previous one.
+	CPU     float64
+	CPUTime float64
+	RSS     int64
+}
+
+// parseProcessTable parses `ps -A -o pid=,ppid=,pcpu=,rss=,time=` output.
+// rss is in KiB; time is cumulative CPU time as [[dd-]hh:]mm:ss.
+func parseProcessTable(out string) map[int]processInfo {
+	procs := map[int]processInfo{}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 5 {
+			continue
+		}
+		pid, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		ppid, _ := strconv.Atoi(fields[1])
+		cpu, _ := strconv.ParseFloat(fields[2], 64)
+		rss, _ := strconv.ParseInt(fields[3], 10, 64)
+		procs[pid] = processInfo{PID: pid, PPID: ppid, CPU: cpu, CPUTime: parseCPUTime(fields[4]), RSS: rss * 1024}
+	}
+	return procs
+}
+
+// parseCPUTime converts ps's [[dd-]hh:]mm:ss to seconds.
+func parseCPUTime(value string) float64 {
+	days := 0.0
+	if d, rest, ok := strings.Cut(value, "-"); ok {
+		n, _ := strconv.ParseFloat(d, 64)
+		days, value = n, rest
+	}
+	secs := 0.0
+	for _, part := range strings.Split(value, ":") {
+		n, _ := strconv.ParseFloat(part, 64)
+		secs = secs*60 + n
+	}
+	return days*86400 + secs
+}
+
+// sumProcessTrees adds up the usage of roots and all of their descendants.
+func sumProcessTrees(procs map[int]processInfo, roots []int) ResourceUsage {
+	children := map[int][]int{}
+	for pid, p := range procs {
+		children[p.PPID] = append(children[p.PPID], pid)
+	}
+	var usage ResourceUsage
+	seen := map[int]bool{}
+	queue := append([]int(nil), roots...)
+	for len(queue) > 0 {
+		pid := queue[0]
+		queue = queue[1:]
+		p, ok := procs[pid]
+		if !ok || seen[pid] {
+			continue
+		}
+		seen[pid] = true
+		usage.CPU += p.CPU
+		usage.RSS += p.RSS
+		usage.Procs++
+		queue = append(queue, children[pid]...)
+	}
+	return usage
+}
+
+// resourceSampler turns consecutive process samples into current CPU use.
+// Linux ps reports CPU averaged over a process's whole lifetime, which hides
+// a long-running dev server that just started spinning.
+type resourceSampler struct {
+	prevCPUTime map[int]float64
+	prevAt      time.Time
+}
+
+func (s *resourceSampler) apply(procs map[int]processInfo, now time.Time) {
+	elapsed := now.Sub(s.prevAt).Seconds()
+	if s.prevCPUTime != nil && elapsed > 0 {
+		for pid, p := range procs {
+			if before, ok := s.prevCPUTime[pid]; ok && p.CPUTime >= before {
+				p.CPU = (p.CPUTime - before) / elapsed * 100
+				procs[pid] = p
+			}
+		}
+	}
+	s.prevCPUTime = make(map[int]float64, len(procs))
+	for pid, p := range procs {
+		s.prevCPUTime[pid] = p.CPUTime
+	}
+	s.prevAt = now
+}
+
+// sessionResources samples the resource usage of every tmux session, keyed
+// by session name, and of the windows of each worktree in per-repo
+// sessions, keyed by worktree path.
+func (m *Manager) sessionResources(sampler *resourceSampler) (map[string]ResourceUsage, error) {
+	if !m.commandExists("tmux") {
+		return nil, nil
+	}
+	out, err := m.runCmdOutput("", "tmux", "list-panes", "-a", "-F", "#{session_name}\t#{"+tmuxOwnerOption+"}\t#{pane_pid}")
+	if err != nil {
+		// No server running means no sessions.
+		return nil, nil
+	}
+	roots := map[string][]int{}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.SplitN(line, "\t", 3)
+		if len(fields) < 3 {
+			continue
+		}
+		if pid, err := strconv.Atoi(strings.TrimSpace(fields[2])); err == nil {
+			roots[fields[0]] = append(roots[fields[0]], pid)
+			if owner := fields[1]; owner != "" {
+				roots[owner] = append(roots[owner], pid)
+			}
+		}
+	}
+	if len(roots) == 0 {
+		return nil, nil
+	}
+
+	psOut, err := m.runCmdOutput("", "ps", "-A", "-o", "pid=,ppid=,pcpu=,rss=,time=")
+	if err != nil {
+		return nil, err
+	}
+	procs := parseProcessTable(psOut)
+	if sampler != nil {
+		sampler.apply(procs, time.Now())
+	}
+	usage := make(map[string]ResourceUsage, len(roots))
+	for session, pids := range roots {
+		usage[session] = sumProcessTrees(procs, pids)
+	}
+	return usage, nil
+}
+
+// formatResourceUsage renders usage compactly, e.g. "12% 340M".
+func formatResourceUsage(usage ResourceUsage) string {
+	return fmt.Sprintf("%.0f%% %s", usage.CPU, compactBytes(usage.RSS))
+}
+
+// compactBytes formats n as 512K, 340M, or 1.2G.
+func compactBytes(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1fG", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%dM", n>>20)
+	}
+	return fmt.Sprintf("%dK", n>>10)
+}
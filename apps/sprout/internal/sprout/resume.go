@@ -0,0 +1,276 @@
+package sprout
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	agentSessionsFile = "agents.json"
+	// resumePromptTimeout bounds the wait for a resumed agent to be ready for
+	// the resume prompt.
+	resumePromptTimeout = 30 * time.Second
+	// agentPromptTimeout bounds the wait for a freshly started agent to be
+	// ready for its first prompt.
+	agentPromptTimeout  = 60 * time.Second
+	agentPromptAttempts = 3
+)
+
+var agentSessionsMu sync.Mutex
+
+// AgentSession records an agent sprout started, so it can be started again
+// after the tmux server goes away.
+type AgentSession struct {
+	// AgentType is the agent type it was started with; empty for the
+	// default agent command.
+	AgentType string    `json:"agent_type,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+type agentSessions struct {
+	Worktrees map[string]AgentSession `json:"worktrees"`
+}
+
+func agentSessionsPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "sprout", agentSessionsFile), nil
+}
+
+func readAgentSessions() (agentSessions, error) {
+	sessions := agentSessions{Worktrees: map[string]AgentSession{}}
+	path, err := agentSessionsPath()
+	if err != nil {
+		return sessions, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return sessions, nil
+		}
+		return sessions, err
+	}
+	if err := json.Unmarshal(data, &sessions); err != nil {
+		return agentSessions{Worktrees: map[string]AgentSession{}}, err
+	}
+	if sessions.Worktrees == nil {
+		sessions.Worktrees = map[string]AgentSession{}
+	}
+	return sessions, nil
+}
+
+func writeAgentSessions(sessions agentSessions) error {
+	path, err := agentSessionsPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(sessions, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// updateAgentSessions applies change to the recorded agent sessions and
+// saves them when it reports a change.
+func updateAgentSessions(change func(map[string]AgentSession) bool) error {
+	agentSessionsMu.Lock()
+	defer agentSessionsMu.Unlock()
+
+	sessions, err := readAgentSessions()
+	if err != nil {
+		errorLogf("agent_sessions read failed: %v", err)
+	}
+	if !change(sessions.Worktrees) {
+		return nil
+	}
+	return writeAgentSessions(sessions)
+}
+
+// recordAgentSession remembers that the agent of worktreePath is running.
+func recordAgentSession(worktreePath, agentType string) {
+	err := updateAgentSessions(func(worktrees map[string]AgentSession) bool {
+		worktrees[worktreePath] = AgentSession{AgentType: agentType, StartedAt: time.Now()}
+		return true
+	})
+	if err != nil {
+		errorLogf("agent_sessions record failed path=%q: %v", worktreePath, err)
+	}
+}
+
+// forgetAgentSession drops the record of worktreePath's agent once it is
+// stopped on purpose, so it is not resumed.
+func forgetAgentSession(worktreePath string) {
+	err := updateAgentSessions(func(worktrees map[string]AgentSession) bool {
+		if _, ok := worktrees[worktreePath]; !ok {
+			return false
+		}
+		delete(worktrees, worktreePath)
+		return true
+	})
+	if err != nil {
+		errorLogf("agent_sessions forget failed path=%q: %v", worktreePath, err)
+	}
+}
+
+// renameAgentSession carries the record of a moved worktree's agent over to
+// its new path.
+func renameAgentSession(oldPath, newPath string) error {
+	return updateAgentSessions(func(worktrees map[string]AgentSession) bool {
+		session, ok := worktrees[oldPath]
+		if !ok {
+			return false
+		}
+		delete(worktrees, oldPath)
+		worktrees[newPath] = session
+		return true
+	})
+}
+
+// ResumableAgent is a worktree whose agent was started by sprout and is no
+// longer running, typically because the tmux server restarted.
+type ResumableAgent struct {
+	Worktree Worktree
+	Session  AgentSession
+}
+
+// ResumableAgents lists the agents of the current repository to resume.
+func (m *Manager) ResumableAgents() ([]ResumableAgent, error) {
+	repoRoot, err := m.RequireRepo()
+	if err != nil {
+		return nil, err
+	}
+	agentSessionsMu.Lock()
+	sessions, err := readAgentSessions()
+	agentSessionsMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if len(sessions.Worktrees) == 0 {
+		return nil, nil
+	}
+	items, err := m.parseWorktreeList(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+	var agents []ResumableAgent
+	for i := range items {
+		wt := items[i]
+		wt.Path = absPath(wt.Path)
+		session, ok := sessions.Worktrees[wt.Path]
+		if !ok {
+			continue
+		}
+		if m.agentStatus(repoRoot, &wt) != agentStatusNone {
+			continue
+		}
+		agents = append(agents, ResumableAgent{Worktree: wt, Session: session})
+	}
+	sort.SliceStable(agents, func(i, j int) bool {
+		return agents[i].Worktree.Path < agents[j].Worktree.Path
+	})
+	return agents, nil
+}
+
+// ResumeResult is the outcome of resuming one agent.
+type ResumeResult struct {
+	Path      string `json:"path"`
+	Branch    string `json:"branch"`
+	AgentType string `json:"agent_type,omitempty"`
+	Prompted  bool   `json:"prompted"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ResumeAgents starts every resumable agent again with the agent type it had,
+// then sends agent_resume_prompt once the agent is ready for input. It keeps
+// going past failures, which are reported per agent.
+func (m *Manager) ResumeAgents() ([]ResumeResult, error) {
+	agents, err := m.ResumableAgents()
+	if err != nil {
+		return nil, err
+	}
+	results := make([]ResumeResult, 0, len(agents))
+	for i := range agents {
+		agent := &agents[i]
+		res := ResumeResult{Path: agent.Worktree.Path, Branch: worktreeBranchOrName(&agent.Worktree), AgentType: agent.Session.AgentType}
+		if _, _, err := m.StartAgent(context.Background(), AgentOptions{Target: agent.Worktree.Path, AgentType: agent.Session.AgentType}); err != nil {
+			errorLogf("resume_agent start failed path=%q: %v", res.Path, err)
+			res.Error = err.Error()
+			results = append(results, res)
+			continue
+		}
+		if prompt := m.resumePrompt(&agent.Worktree); prompt != "" {
+			if err := m.sendWhenReady(context.Background(), agent.Worktree.Path, prompt, resumePromptTimeout); err != nil {
+				errorLogf("resume_agent prompt failed path=%q: %v", res.Path, err)
+				res.Error = fmt.Sprintf("resume prompt not sent: %v", err)
+			} else {
+				res.Prompted = true
+			}
+		}
+		infoLogf("resume_agent done path=%q type=%q prompted=%t", res.Path, res.AgentType, res.Prompted)
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// resumePrompt expands agent_resume_prompt for wt: {branch} is its branch
+// and {last_prompt} the last prompt sent to its agent.
+func (m *Manager) resumePrompt(wt *Worktree) string {
+	prompt := strings.TrimSpace(m.Cfg.AgentResumePrompt)
+	if prompt == "" {
+		return ""
+	}
+	last := ""
+	if history := promptHistoryFor(wt.Path); len(history) > 0 {
+		last = history[len(history)-1].Prompt
+	}
+	return strings.NewReplacer("{branch}", worktreeBranchOrName(wt), "{last_prompt}", last).Replace(prompt)
+}
+
+// sendWhenReady waits for the agent of worktreePath to be ready for input and
+// sends it prompt. A send that fails is retried, once the agent is ready
+// again, up to agentPromptAttempts times.
+func (m *Manager) sendWhenReady(ctx context.Context, worktreePath, prompt string, timeout time.Duration) error {
+	repoRoot, wt, err := m.resolveWorktreeForTmux(worktreePath)
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().Add(timeout)
+	for attempt := 1; ; attempt++ {
+		for {
+			status := m.agentStatus(repoRoot, wt)
+			if status == agentStatusReady {
+				break
+			}
+			if status == agentStatusExited {
+				return errors.New("agent exited before it was ready for input")
+			}
+			if time.Now().After(deadline) {
+				return fmt.Errorf("agent did not become ready for input within %s", timeout)
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(500 * time.Millisecond):
+			}
+		}
+		_, err := m.SendAgentCommand(worktreePath, prompt)
+		if err == nil || attempt == agentPromptAttempts {
+			return err
+		}
+		debugLogf("send_when_ready retry path=%q attempt=%d: %v", worktreePath, attempt, err)
+	}
+}
@@ -0,0 +1,252 @@
+package sprout
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	serveDefaultAddr = "127.0.0.1:7337"
+	serveTokenFile   = "serve-token"
+	serveMaxBody     = 1 << 20
+)
+
+type ServeOptions struct {
+	// Addr is the listen address; empty means 127.0.0.1:7337.
+	Addr string
+	// Token is the bearer token clients must send; empty uses the token
+	// stored in ~/.config/sprout/serve-token, creating it on first use.
+	Token string
+}
+
+// APIServer serves sprout's worktree and agent operations as a JSON API.
+type APIServer struct {
+	URL string
+	// TokenPath is the file the token was read from or written to, or empty
+	// when the token was passed in.
+	TokenPath string
+	server    *http.Server
+	listener  net.Listener
+}
+
+// apiRoute maps an endpoint to the MCP tool that implements it. Tools read
+// their arguments from the JSON body, or from the query string for GET and
+// DELETE.
+type apiRoute struct {
+	pattern string
+	tool    string
+	// mutates routes run one at a time, so two requests cannot create or
+	// remove the same worktree at once.
+	mutates bool
+}
+
+var apiRoutes = []apiRoute{
+	{pattern: "GET /api/worktrees", tool: "list_worktrees"},
+	{pattern: "POST /api/worktrees", tool: "create_worktree", mutates: true},
+	{pattern: "DELETE /api/worktrees", tool: "remove_worktree", mutates: true},
+	{pattern: "POST /api/agent/start", tool: "start_agent", mutates: true},
+	{pattern: "POST /api/agent/stop", tool: "stop_agent", mutates: true},
+	{pattern: "POST /api/agent/prompt", tool: "send_agent_prompt"},
+	{pattern: "GET /api/agent/output", tool: "get_agent_output"},
+	{pattern: "GET /api/diff", tool: "get_diff"},
+}
+
+// NewAPIServer starts listening for API requests on the current repository.
+// Every request needs an "Authorization: Bearer <token>" header. Call Serve
+// to handle requests and Close to stop.
+func (m *Manager) NewAPIServer(opts ServeOptions) (*APIServer, error) {
+	if _, err := m.RequireRepo(); err != nil {
+		return nil, err
+	}
+	token, tokenPath := strings.TrimSpace(opts.Token), ""
+	if token == "" {
+		var err error
+		if token, tokenPath, err = loadOrCreateServeToken(); err != nil {
+			return nil, fmt.Errorf("serve token: %w", err)
+		}
+	}
+	addr := strings.TrimSpace(opts.Addr)
+	if addr == "" {
+		addr = serveDefaultAddr
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	var writeMu sync.Mutex
+	for _, route := range apiRoutes {
+		tool, ok := findMCPTool(route.tool)
+		if !ok {
+			ln.Close()
+			return nil, fmt.Errorf("no tool for %s", route.pattern)
+		}
+		mux.HandleFunc(route.pattern, func(w http.ResponseWriter, r *http.Request) {
+			args, err := apiArgs(w, r, tool)
+			if err != nil {
+				writeAPIError(w, http.StatusBadRequest, err)
+				return
+			}
+			if route.mutates {
+				writeMu.Lock()
+				defer writeMu.Unlock()
+			}
+			result, err := tool.call(r.Context(), m, args)
+			if err != nil {
+				infoLogf("serve %s failed: %v", route.pattern, err)
+				writeAPIError(w, apiErrorStatus(err), err)
+				return
+			}
+			infoLogf("serve %s ok", route.pattern)
+			writeAPIJSON(w, http.StatusOK, map[string]any{"result": result})
+		})
+	}
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeAPIError(w, http.StatusNotFound, fmt.Errorf("no endpoint %s %s", r.Method, r.URL.Path))
+	})
+
+	infoLogf("serve start addr=%q", ln.Addr().String())
+	return &APIServer{
+		URL:       "http://" + ln.Addr().String(),
+		TokenPath: tokenPath,
+		server: &http.Server{
+			Handler:           requireAPIToken(token, mux),
+			ReadHeaderTimeout: 5 * time.Second,
+		},
+		listener: ln,
+	}, nil
+}
+
+// Serve handles requests until Close is called.
+func (s *APIServer) Serve() error {
+	err := s.server.Serve(s.listener)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+func (s *APIServer) Close() error {
+	return s.server.Close()
+}
+
+func requireAPIToken(token string, next http.Handler) http.Handler {
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := []byte(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(got, want) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="sprout"`)
+			writeAPIError(w, http.StatusUnauthorized, errors.New("missing or wrong bearer token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// apiArgs reads the tool arguments of a request: the JSON body, or for GET
+// and DELETE the query string, converted to the types the tool declares.
+func apiArgs(w http.ResponseWriter, r *http.Request, tool mcpTool) (json.RawMessage, error) {
+	if r.Method != http.MethodGet && r.Method != http.MethodDelete {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, serveMaxBody))
+		if err != nil {
+			return nil, err
+		}
+		return body, nil
+	}
+	props, _ := tool.InputSchema["properties"].(map[string]any)
+	args := map[string]any{}
+	for key, values := range r.URL.Query() {
+		value := values[len(values)-1]
+		prop, _ := props[key].(map[string]any)
+		switch prop["type"] {
+		case "boolean":
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s must be true or false", errInvalidArguments, key)
+			}
+			args[key] = b
+		case "integer":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s must be a number", errInvalidArguments, key)
+			}
+			args[key] = n
+		default:
+			args[key] = value
+		}
+	}
+	return json.Marshal(args)
+}
+
+func apiErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errInvalidArguments):
+		return http.StatusBadRequest
+	case errors.Is(err, errWorktreeNotFound):
+		return http.StatusNotFound
+	}
+	return http.StatusUnprocessableEntity
+}
+
+func writeAPIError(w http.ResponseWriter, status int, err error) {
+	writeAPIJSON(w, status, map[string]any{"error": err.Error()})
+}
+
+func writeAPIJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(body)
+}
+
+func serveTokenPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "sprout", serveTokenFile), nil
+}
+
+// loadOrCreateServeToken returns the stored API token, generating and
+// storing a random one, readable only by the user, the first time.
+func loadOrCreateServeToken() (string, string, error) {
+	path, err := serveTokenPath()
+	if err != nil {
+		return "", "", err
+	}
+	if data, err := os.ReadFile(path); err == nil {
+		if token := strings.TrimSpace(string(data)); token != "" {
+			return token, path, nil
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", "", err
+	}
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token := hex.EncodeToString(buf)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", "", err
+	}
+	if err := os.WriteFile(path, []byte(token+"\n"), 0o600); err != nil {
+		return "", "", err
+	}
+	return token, path, nil
+}
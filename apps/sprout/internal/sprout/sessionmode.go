@@ -0,0 +1,125 @@
+package sprout
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Session modes: a tmux session per worktree, or one per repository with
+// the windows of all its worktrees.
+const (
+	sessionModePerWorktree = "per-worktree"
+	sessionModePerRepo     = "per-repo"
+)
+
+// tmuxOwnerOption is the window option that names the worktree a window of
+// a per-repo session belongs to.
+const tmuxOwnerOption = "@sprout_worktree"
+
+func parseSessionMode(value string) (string, error) {
+	switch v := strings.ToLower(strings.TrimSpace(value)); v {
+	case "", sessionModePerWorktree:
+		return sessionModePerWorktree, nil
+	case sessionModePerRepo:
+		return v, nil
+	}
+	return "", fmt.Errorf("invalid session_mode %q (want per-worktree or per-repo)", value)
+}
+
+// perRepoSessions reports whether the worktrees of a repository share one
+// tmux session.
+func (m *Manager) perRepoSessions() bool {
+	return m.Cfg.SessionMode == sessionModePerRepo
+}
+
+// tmuxScopedWindowName makes a window name that is the same for every
+// worktree, like a [[windows]] name, unique within a shared per-repo
+// session by putting the branch in front.
+func (m *Manager) tmuxScopedWindowName(branch, name string) string {
+	if !m.perRepoSessions() || name == "" {
+		return name
+	}
+	return trimTmuxWindowName(sessionToken(branch) + "-" + name)
+}
+
+// tmuxTagWindow marks a window of a per-repo session as worktreePath's, so
+// its windows can be told from those of the other worktrees.
+func (m *Manager) tmuxTagWindow(session, window, worktreePath string) error {
+	if !m.perRepoSessions() {
+		return nil
+	}
+	return m.tmux("set-option", "-w", "-t", session+":"+window, tmuxOwnerOption, absPath(worktreePath))
+}
+
+// tmuxWorktreeWindowIDs lists the windows of a per-repo session tagged as
+// worktreePath's.
+func (m *Manager) tmuxWorktreeWindowIDs(session, worktreePath string) []string {
+	out, err := m.runCmdOutput("", "tmux", "list-windows", "-t", session, "-F", "#{window_id}\t#{"+tmuxOwnerOption+"}")
+	if err != nil {
+		return nil
+	}
+	path := absPath(worktreePath)
+	var ids []string
+	for _, line := range strings.Split(out, "\n") {
+		id, owner, ok := strings.Cut(strings.TrimSpace(line), "\t")
+		if ok && filepath.Clean(owner) == path {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// tmuxWorktreeRunning reports whether a worktree has tmux windows: its own
+// session, or in per-repo mode, windows of its own in the shared one.
+func (m *Manager) tmuxWorktreeRunning(session, worktreePath string) bool {
+	if !m.perRepoSessions() {
+		return m.tmuxHasSession(session)
+	}
+	// A launch preview starts from no session at all.
+	if m.preview != nil || !m.tmuxHasSession(session) {
+		return false
+	}
+	return len(m.tmuxWorktreeWindowIDs(session, worktreePath)) > 0
+}
+
+// tmuxKillWorktree stops a worktree's tmux windows: its whole session, or
+// in per-repo mode only its own windows, leaving the other worktrees'.
+func (m *Manager) tmuxKillWorktree(session, worktreePath string) error {
+	if !m.perRepoSessions() {
+		return m.runCmdQuiet("", "tmux", "kill-session", "-t", session)
+	}
+	var errs []error
+	for _, id := range m.tmuxWorktreeWindowIDs(session, worktreePath) {
+		if err := m.runCmdQuiet("", "tmux", "kill-window", "-t", id); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// tmuxRetagWorktree moves the windows of a per-repo session from oldPath to
+// newPath after a worktree is moved.
+func (m *Manager) tmuxRetagWorktree(session, oldPath, newPath string) error {
+	if !m.perRepoSessions() {
+		return nil
+	}
+	var errs []error
+	for _, id := range m.tmuxWorktreeWindowIDs(session, oldPath) {
+		if err := m.runCmdQuiet("", "tmux", "set-option", "-w", "-t", id, tmuxOwnerOption, absPath(newPath)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// containerName is the {name} of a worktree's container: its session name,
+// or in per-repo mode the session and the worktree's directory, since the
+// worktrees share the session.
+func (m *Manager) containerName(session, worktreePath string) string {
+	if m.perRepoSessions() {
+		return session + "-" + filepath.Base(worktreePath)
+	}
+	return session
+}
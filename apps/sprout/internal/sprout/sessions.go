@@ -0,0 +1,176 @@
+package sprout
+
+import (
+	"errors"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TmuxSession is a tmux session created by sprout, mapped back to the
+// worktree it belongs to when that worktree still exists.
+type TmuxSession struct {
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	Attached bool   `json:"attached"`
+	Windows  int    `json:"windows"`
+	Worktree string `json:"worktree,omitempty"`
+	Branch   string `json:"branch,omitempty"`
+	Orphan   bool   `json:"orphan"`
+}
+
+// TmuxWindow is a window of a worktree's tmux session.
+type TmuxWindow struct {
+	Index  int    `json:"index"`
+	Name   string `json:"name"`
+	Active bool   `json:"active"`
+}
+
+// WorktreeWindows lists the windows of wt's tmux session in order, only its
+// own ones in a per-repo session, or none when it has no session.
+func (m *Manager) WorktreeWindows(wt *Worktree) ([]TmuxWindow, error) {
+	repoRoot, err := m.RequireRepo()
+	if err != nil {
+		return nil, err
+	}
+	if !m.commandExists("tmux") {
+		return nil, nil
+	}
+	session := m.tmuxWorktreeSessionName(repoRoot, wt)
+	if !m.tmuxWorktreeRunning(session, wt.Path) {
+		return nil, nil
+	}
+	out, err := m.runCmdOutput("", "tmux", "list-windows", "-t", session, "-F", "#{window_index}\t#{window_active}\t#{"+tmuxOwnerOption+"}\t#{window_name}")
+	if err != nil {
+		return nil, err
+	}
+	var windows []TmuxWindow
+	for _, line := range strings.Split(strings.TrimRight(out, "\n"), "\n") {
+		fields := strings.SplitN(line, "\t", 4)
+		if len(fields) < 4 {
+			continue
+		}
+		if m.perRepoSessions() && (fields[2] == "" || filepath.Clean(fields[2]) != absPath(wt.Path)) {
+			continue
+		}
+		index, _ := strconv.Atoi(fields[0])
+		windows = append(windows, TmuxWindow{Index: index, Name: fields[3], Active: fields[1] == "1"})
+	}
+	return windows, nil
+}
+
+// Sessions lists every tmux session matching the session prefix. Outside a
+// repository, sessions can only be judged by whether their directory exists.
+func (m *Manager) Sessions() ([]TmuxSession, error) {
+	if !m.commandExists("tmux") {
+		return nil, errors.New("tmux is required for session management")
+	}
+	repoRoot, err := m.RequireRepo()
+	if err != nil {
+		return m.sproutSessions("", nil)
+	}
+	items, err := m.parseWorktreeList(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+	return m.sproutSessions(repoRoot, items)
+}
+
+// KillOrphanSessions kills the sessions Sessions reports as orphaned and
+// returns their names.
+func (m *Manager) KillOrphanSessions() ([]string, error) {
+	sessions, err := m.Sessions()
+	if err != nil {
+		return nil, err
+	}
+	var killed []string
+	var errs []error
+	for _, name := range orphanSessionNames(sessions) {
+		if err := m.runCmdQuiet("", "tmux", "kill-session", "-t", name); err != nil {
+			errorLogf("kill_orphan_session failed session=%q: %v", name, err)
+			errs = append(errs, err)
+			continue
+		}
+		infoLogf("kill_orphan_session done session=%q", name)
+		killed = append(killed, name)
+	}
+	return killed, errors.Join(errs...)
+}
+
+func orphanSessionNames(sessions []TmuxSession) []string {
+	var names []string
+	for _, s := range sessions {
+		if s.Orphan {
+			names = append(names, s.Name)
+		}
+	}
+	return names
+}
+
+// sproutSessions lists sessions named with the session prefix and maps them
+// to the worktrees of repoRoot. A session is orphaned when its directory is
+// gone, or when it is named for repoRoot but matches none of its worktrees.
+func (m *Manager) sproutSessions(repoRoot string, items []Worktree) ([]TmuxSession, error) {
+	out, err := m.runCmdOutput("", "tmux", "list-sessions", "-F", "#{session_name}\t#{session_path}\t#{session_attached}\t#{session_windows}")
+	if err != nil {
+		// No server running means no sessions.
+		return nil, nil
+	}
+
+	repoBase := ""
+	byName := map[string]*Worktree{}
+	if repoRoot != "" {
+		repoBase = m.tmuxSessionName(repoRoot)
+		for i := range items {
+			byName[m.tmuxWorktreeSessionName(repoRoot, &items[i])] = &items[i]
+		}
+		if len(items) > 0 {
+			if _, ok := byName[repoBase]; !ok {
+				byName[repoBase] = &items[0]
+			}
+		}
+	}
+	prefix := safeName(m.Cfg.SessionPrefix)
+
+	var sessions []TmuxSession
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 4 || fields[0] == "" {
+			continue
+		}
+		name := fields[0]
+		ownRepo := repoBase != "" && (name == repoBase || strings.HasPrefix(name, repoBase+"-"))
+		if !ownRepo && (prefix == "" || !strings.HasPrefix(name, prefix+"-")) {
+			continue
+		}
+		windows, _ := strconv.Atoi(fields[3])
+		s := TmuxSession{Name: name, Path: fields[1], Attached: fields[2] != "0", Windows: windows}
+
+		wt := byName[name]
+		if wt == nil && s.Path != "" {
+			// Another repo can share the prefix (app vs app-web); a session
+			// running inside a known worktree still belongs to it.
+			for i := range items {
+				if samePath(s.Path, items[i].Path) || strings.HasPrefix(s.Path, items[i].Path+string(filepath.Separator)) {
+					wt = &items[i]
+					break
+				}
+			}
+		}
+		switch {
+		case wt != nil:
+			s.Worktree = wt.Path
+			s.Branch = wt.Branch
+		case ownRepo:
+			s.Orphan = true
+		case s.Path != "":
+			if exists, err := m.pathExists(s.Path); err == nil && !exists {
+				s.Orphan = true
+			}
+		}
+		sessions = append(sessions, s)
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].Name < sessions[j].Name })
+	return sessions, nil
+}
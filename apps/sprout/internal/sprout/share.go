@@ -0,0 +1,331 @@
+package sprout
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type ShareOptions struct {
+	Target string
+	// Addr is the listen address; empty binds a random loopback port.
+	Addr string
+	// Agent includes the agent transcript below the diff.
+	Agent      bool
+	AgentLines int
+}
+
+// ShareServer serves a read-only HTML view of a worktree's branch diff.
+type ShareServer struct {
+	Path     string
+	URLs     []string
+	server   *http.Server
+	listener net.Listener
+}
+
+// Share starts listening for a read-only view of the target worktree. The
+// page is rendered on every request, so reloading shows the current diff.
+// Call Serve to handle requests and Close to stop.
+func (m *Manager) Share(opts ShareOptions) (*ShareServer, error) {
+	repoRoot, wt, err := m.resolveWorktreeForTmux(opts.Target)
+	if err != nil {
+		return nil, err
+	}
+	addr := strings.TrimSpace(opts.Addr)
+	if addr == "" {
+		addr = "127.0.0.1:0"
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "read-only", http.StatusMethodNotAllowed)
+			return
+		}
+		page, err := m.renderSharePage(repoRoot, wt, opts)
+		if err != nil {
+			errorLogf("share render failed path=%q: %v", wt.Path, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		_, _ = w.Write(page)
+	})
+
+	infoLogf("share start path=%q addr=%q", wt.Path, ln.Addr().String())
+	return &ShareServer{
+		Path:     wt.Path,
+		URLs:     shareURLs(ln.Addr()),
+		server:   &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second},
+		listener: ln,
+	}, nil
+}
+
+// Serve handles requests until Close is called.
+func (s *ShareServer) Serve() error {
+	err := s.server.Serve(s.listener)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+func (s *ShareServer) Close() error {
+	return s.server.Close()
+}
+
+// shareURLs lists the URLs a listener is reachable at. A wildcard bind also
+// lists every non-loopback IPv4 address so the page can be opened over LAN.
+func shareURLs(addr net.Addr) []string {
+	tcp, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return []string{"http://" + addr.String() + "/"}
+	}
+	if !tcp.IP.IsUnspecified() {
+		return []string{fmt.Sprintf("http://%s/", net.JoinHostPort(tcp.IP.String(), fmt.Sprint(tcp.Port)))}
+	}
+	urls := []string{fmt.Sprintf("http://127.0.0.1:%d/", tcp.Port)}
+	ifaceAddrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return urls
+	}
+	for _, a := range ifaceAddrs {
+		ipNet, ok := a.(*net.IPNet)
+		if !ok || ipNet.IP.IsLoopback() || ipNet.IP.To4() == nil {
+			continue
+		}
+		urls = append(urls, fmt.Sprintf("http://%s:%d/", ipNet.IP.String(), tcp.Port))
+	}
+	return urls
+}
+
+// BranchDiff returns the plain unified diff of everything the worktree
+// changed since it forked from the base branch, including uncommitted and
+// untracked files.
+func (m *Manager) BranchDiff(repoRoot string, wt *Worktree) (string, error) {
+	since := m.branchDiffBase(repoRoot, wt)
+	diff, err := m.runCmdOutput(wt.Path, "git", "--no-pager", "diff", "--no-color", "--no-ext-diff", since)
+	if err != nil {
+		return "", err
+	}
+	parts := []string{}
+	if strings.TrimSpace(diff) != "" {
+		parts = append(parts, diff)
+	}
+	untracked, err := m.runCmdOutput(wt.Path, "git", "ls-files", "--others", "--exclude-standard")
+	if err != nil {
+		return "", err
+	}
+	for _, file := range strings.Split(untracked, "\n") {
+		if file = strings.TrimSpace(file); file == "" {
+			continue
+		}
+		fileDiff, err := m.runCmdOutputAllowExitCodes(wt.Path, []int{1}, "git", "--no-pager", "diff", "--no-index", "--no-color", "--no-ext-diff", "--", "/dev/null", file)
+		if err != nil {
+			return "", err
+		}
+		if strings.TrimSpace(fileDiff) != "" {
+			parts = append(parts, fileDiff)
+		}
+	}
+	if len(parts) == 0 {
+		return "", nil
+	}
+	return strings.Join(parts, "\n") + "\n", nil
+}
+
+type shareLine struct {
+	Class string
+	HTML  template.HTML
+}
+
+type sharePage struct {
+	Title      string
+	Branch     string
+	Path       string
+	Generated  string
+	Lines      []shareLine
+	Agent      bool
+	Transcript string
+}
+
+func (m *Manager) renderSharePage(repoRoot string, wt *Worktree, opts ShareOptions) ([]byte, error) {
+	diff, err := m.BranchDiff(repoRoot, wt)
+	if err != nil {
+		return nil, err
+	}
+	page := sharePage{
+		Title:     worktreeBranchOrName(wt),
+		Branch:    worktreeBranchOrName(wt),
+		Path:      wt.Path,
+		Generated: time.Now().Format(time.RFC1123),
+		Lines:     highlightDiffHTML(diff),
+		Agent:     opts.Agent,
+	}
+	if opts.Agent {
+		lines := opts.AgentLines
+		if lines <= 0 {
+			lines = 2000
+		}
+		out, err := m.agentOutputForWorktree(repoRoot, wt, lines)
+		if err != nil {
+			page.Transcript = fmt.Sprintf("agent transcript unavailable: %v", err)
+		} else {
+			page.Transcript = strings.TrimRight(stripANSI(out), "\n")
+		}
+	}
+	var buf bytes.Buffer
+	if err := shareTemplate.Execute(&buf, page); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// highlightDiffHTML classifies each diff line and highlights the code in
+// added, removed, and context lines.
+func highlightDiffHTML(diff string) []shareLine {
+	diff = strings.TrimRight(diff, "\n")
+	if diff == "" {
+		return nil
+	}
+	var lines []shareLine
+	inHeader := false
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			inHeader = true
+			lines = append(lines, shareLine{Class: "file", HTML: template.HTML(template.HTMLEscapeString(line))})
+		case strings.HasPrefix(line, "@@"):
+			inHeader = false
+			lines = append(lines, shareLine{Class: "hunk", HTML: template.HTML(template.HTMLEscapeString(line))})
+		case inHeader:
+			lines = append(lines, shareLine{Class: "meta", HTML: template.HTML(template.HTMLEscapeString(line))})
+		case strings.HasPrefix(line, "+"):
+			lines = append(lines, shareLine{Class: "add", HTML: template.HTML("+" + highlightCodeHTML(line[1:]))})
+		case strings.HasPrefix(line, "-"):
+			lines = append(lines, shareLine{Class: "del", HTML: template.HTML("-" + highlightCodeHTML(line[1:]))})
+		case strings.HasPrefix(line, " "):
+			lines = append(lines, shareLine{Class: "ctx", HTML: template.HTML(" " + highlightCodeHTML(line[1:]))})
+		default:
+			lines = append(lines, shareLine{Class: "meta", HTML: template.HTML(template.HTMLEscapeString(line))})
+		}
+	}
+	return lines
+}
+
+var shareKeywords = map[string]struct{}{}
+
+func init() {
+	for _, kw := range strings.Fields(`break case catch chan class const continue def default defer do elif else
+		enum except export extends false fn for from func function go if impl import in interface let map
+		match mod new nil None null package pub raise range return select self static struct switch this
+		throw true True False try type use var while with yield async await`) {
+		shareKeywords[kw] = struct{}{}
+	}
+}
+
+// highlightCodeHTML escapes a line of source and wraps keywords, string
+// literals, and line comments in spans. It is language-agnostic on purpose:
+// good enough to read a diff, not a full lexer.
+func highlightCodeHTML(code string) string {
+	var b strings.Builder
+	isIdent := func(c byte) bool {
+		return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+	}
+	for i := 0; i < len(code); {
+		c := code[i]
+		switch {
+		case strings.HasPrefix(code[i:], "//") || c == '#' && (i == 0 || code[i-1] == ' ' || code[i-1] == '\t'):
+			b.WriteString(`<span class="c">` + template.HTMLEscapeString(code[i:]) + `</span>`)
+			return b.String()
+		case c == '"' || c == '\'' || c == '`':
+			j := i + 1
+			for j < len(code) && code[j] != c {
+				if code[j] == '\\' && c != '`' {
+					j++
+				}
+				j++
+			}
+			if j < len(code) {
+				j++
+			} else {
+				j = len(code)
+			}
+			b.WriteString(`<span class="s">` + template.HTMLEscapeString(code[i:j]) + `</span>`)
+			i = j
+		case isIdent(c):
+			j := i
+			for j < len(code) && isIdent(code[j]) {
+				j++
+			}
+			word := code[i:j]
+			if _, ok := shareKeywords[word]; ok {
+				b.WriteString(`<span class="k">` + word + `</span>`)
+			} else {
+				b.WriteString(template.HTMLEscapeString(word))
+			}
+			i = j
+		default:
+			b.WriteString(template.HTMLEscapeString(string(c)))
+			i++
+		}
+	}
+	return b.String()
+}
+
+var shareTemplate = template.Must(template.New("share").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}} · sprout</title>
+<style>
+body { margin: 0; background: #0d1117; color: #c9d1d9; font: 14px/1.5 -apple-system, BlinkMacSystemFont, "Segoe UI", sans-serif; }
+header { padding: 16px 24px; border-bottom: 1px solid #30363d; }
+header h1 { margin: 0; font-size: 18px; color: #7ee787; }
+header p { margin: 4px 0 0; color: #8b949e; font-size: 12px; }
+main { padding: 16px 24px; }
+h2 { font-size: 14px; color: #8b949e; text-transform: uppercase; letter-spacing: .05em; }
+pre { margin: 0; padding: 8px 0; background: #161b22; border: 1px solid #30363d; border-radius: 6px; overflow-x: auto; font: 12px/1.45 ui-monospace, SFMono-Regular, Menlo, monospace; }
+pre div { padding: 0 12px; white-space: pre; }
+.file { color: #e6edf3; font-weight: bold; background: #21262d; margin-top: 8px; }
+.meta { color: #8b949e; }
+.hunk { color: #79c0ff; background: #152238; }
+.add { background: #12261e; color: #aff5b4; }
+.del { background: #2d1416; color: #ffdcd7; }
+.k { color: #ff7b72; font-weight: bold; }
+.s { color: #a5d6ff; }
+.c { color: #8b949e; font-style: italic; }
+.transcript { padding: 8px 12px; white-space: pre-wrap; }
+.empty { color: #8b949e; }
+</style>
+</head>
+<body>
+<header>
+<h1>{{.Branch}}</h1>
+<p>{{.Path}} · generated {{.Generated}} · read-only</p>
+</header>
+<main>
+<h2>Diff</h2>
+{{if .Lines}}<pre>{{range .Lines}}<div class="{{.Class}}">{{.HTML}}</div>{{end}}</pre>{{else}}<p class="empty">No changes since the base branch.</p>{{end}}
+{{if .Agent}}<h2>Agent transcript</h2>
+<pre class="transcript">{{.Transcript}}</pre>{{end}}
+</main>
+</body>
+</html>
+`))
@@ -2,6 +2,10 @@ package sprout
 
 import "fmt"
 
+// ShellHook returns the spr function for shell. It runs sprout with
+// SPROUT_EMIT_CD_MARKER=1 and cds to the last __SPROUT_CD__= line sprout
+// prints, which sprout go and sprout new point at the worktree and sprout rm
+// of the current worktree at the main one.
 func ShellHook(shell string) (string, error) {
 	switch shell {
 	case "zsh", "bash":
@@ -43,8 +47,57 @@ func ShellHook(shell string) (string, error) {
 
   return $_rc
 end
+`, nil
+	case "powershell", "pwsh":
+		return `function spr {
+  $env:SPROUT_EMIT_CD_MARKER = "1"
+  try {
+    $_out = & sprout @args
+    $_rc = $LASTEXITCODE
+  } finally {
+    Remove-Item Env:SPROUT_EMIT_CD_MARKER -ErrorAction SilentlyContinue
+  }
+  $_cd = ""
+
+  foreach ($line in $_out) {
+    if ($line -like "__SPROUT_CD__=*") {
+      $_cd = $line.Substring("__SPROUT_CD__=".Length)
+    } else {
+      $line
+    }
+  }
+
+  if ($_cd) {
+    Set-Location -LiteralPath $_cd
+  }
+
+  $global:LASTEXITCODE = $_rc
+}
+`, nil
+	case "nu", "nushell":
+		return `def --env --wrapped spr [...args] {
+  $env.SPROUT_EMIT_CD_MARKER = "1"
+  let out = (do --env --ignore-errors { ^sprout ...$args } | lines)
+  let rc = $env.LAST_EXIT_CODE
+  hide-env SPROUT_EMIT_CD_MARKER
+  mut cd_to = ""
+
+  for line in $out {
+    if ($line | str starts-with "__SPROUT_CD__=") {
+      $cd_to = ($line | str replace "__SPROUT_CD__=" "")
+    } else {
+      print $line
+    }
+  }
+
+  if $cd_to != "" {
+    cd $cd_to
+  }
+
+  $env.LAST_EXIT_CODE = $rc
+}
 `, nil
 	default:
-		return "", fmt.Errorf("unsupported shell: %s", shell)
+		return "", fmt.Errorf("unsupported shell: %s (want zsh, bash, fish, powershell, or nu)", shell)
 	}
 }
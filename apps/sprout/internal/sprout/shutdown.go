@@ -0,0 +1,211 @@
+package sprout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const agentExitPollInterval = 200 * time.Millisecond
+
+// Quit actions accepted by the on_quit setting.
+const (
+	quitActionNone       = "none"
+	quitActionAsk        = "ask"
+	quitActionStopAgents = "stop-agents"
+	quitActionDetach     = "detach"
+)
+
+func parseQuitAction(value string) (string, error) {
+	switch v := strings.ToLower(strings.TrimSpace(value)); v {
+	case "", quitActionNone:
+		return quitActionNone, nil
+	case quitActionAsk, quitActionStopAgents, quitActionDetach:
+		return v, nil
+	}
+	return "", fmt.Errorf("invalid on_quit %q (want none, ask, stop-agents, or detach)", value)
+}
+
+// ShutdownPlan lists what a shutdown would stop in the current repository.
+type ShutdownPlan struct {
+	// Agents are worktrees with a running agent.
+	Agents []Worktree
+	// Sessions are worktrees with a tmux session.
+	Sessions []Worktree
+}
+
+// Empty reports whether the plan has nothing to stop. Sessions only count
+// when they are going to be detached.
+func (p ShutdownPlan) Empty(detach bool) bool {
+	return len(p.Agents) == 0 && (!detach || len(p.Sessions) == 0)
+}
+
+// Summary describes the plan in one line, e.g. "2 agents (feat-a, feat-b)".
+func (p ShutdownPlan) Summary(detach bool) string {
+	parts := []string{}
+	if len(p.Agents) > 0 {
+		parts = append(parts, countedNames(len(p.Agents), "agent", p.Agents))
+	}
+	if detach && len(p.Sessions) > 0 {
+		parts = append(parts, countedNames(len(p.Sessions), "session", p.Sessions))
+	}
+	if len(parts) == 0 {
+		return "nothing running"
+	}
+	return strings.Join(parts, " and ")
+}
+
+func countedNames(n int, noun string, items []Worktree) string {
+	if n != 1 {
+		noun += "s"
+	}
+	names := make([]string, 0, len(items))
+	for i := range items {
+		names = append(names, worktreeBranchOrName(&items[i]))
+	}
+	return fmt.Sprintf("%d %s (%s)", n, noun, strings.Join(names, ", "))
+}
+
+// ShutdownPlan collects the running agents and tmux sessions of the current
+// repository's worktrees.
+func (m *Manager) ShutdownPlan() (ShutdownPlan, error) {
+	var plan ShutdownPlan
+	if !m.commandExists("tmux") {
+		return plan, nil
+	}
+	items, err := m.ListWorktrees(context.Background())
+	if err != nil {
+		return plan, err
+	}
+	for _, wt := range items {
+		if wt.TmuxState == "yes" {
+			plan.Sessions = append(plan.Sessions, wt)
+		}
+		if wt.AgentState == "yes" {
+			plan.Agents = append(plan.Agents, wt)
+		}
+	}
+	return plan, nil
+}
+
+// ShutdownResult lists the worktrees whose agents and sessions were stopped.
+type ShutdownResult struct {
+	StoppedAgents    []string `json:"stopped_agents"`
+	DetachedSessions []string `json:"detached_sessions"`
+}
+
+// Shutdown stops every agent in the plan. With detach it kills the whole
+// tmux session of each worktree instead, which stops its agent as well.
+// It keeps going past failures and returns them joined.
+func (m *Manager) Shutdown(plan ShutdownPlan, detach bool) (ShutdownResult, error) {
+	res := ShutdownResult{StoppedAgents: []string{}, DetachedSessions: []string{}}
+	repoRoot, err := m.RequireRepo()
+	if err != nil {
+		return res, err
+	}
+	var errs []error
+	detached := map[string]bool{}
+	if detach {
+		for i := range plan.Sessions {
+			wt := &plan.Sessions[i]
+			session := m.tmuxWorktreeSessionName(repoRoot, wt)
+			if err := m.tmuxKillWorktree(session, wt.Path); err != nil {
+				errorLogf("shutdown detach failed session=%q: %v", session, err)
+				errs = append(errs, fmt.Errorf("%s: %w", worktreeBranchOrName(wt), err))
+				continue
+			}
+			detached[wt.Path] = true
+			forgetAgentSession(wt.Path)
+			if err := m.containerDown(session, wt.Path); err != nil {
+				errorLogf("shutdown container_down failed session=%q: %v", session, err)
+				errs = append(errs, fmt.Errorf("%s: %w", worktreeBranchOrName(wt), err))
+			}
+			res.DetachedSessions = append(res.DetachedSessions, wt.Path)
+		}
+	}
+	for i := range plan.Agents {
+		wt := &plan.Agents[i]
+		if detached[wt.Path] {
+			res.StoppedAgents = append(res.StoppedAgents, wt.Path)
+			continue
+		}
+		if err := m.stopAgentInSession(repoRoot, wt); err != nil {
+			errorLogf("shutdown stop_agent failed path=%q: %v", wt.Path, err)
+			errs = append(errs, fmt.Errorf("%s: %w", worktreeBranchOrName(wt), err))
+			continue
+		}
+		forgetAgentSession(wt.Path)
+		m.emit(repoRoot, wt, Event{Type: eventAgentStopped})
+		res.StoppedAgents = append(res.StoppedAgents, wt.Path)
+	}
+	infoLogf("shutdown done agents=%d sessions=%d errors=%d", len(res.StoppedAgents), len(res.DetachedSessions), len(errs))
+	return res, errors.Join(errs...)
+}
+
+// stopAgentInSession kills the agent window of a worktree's session, or the
+// agent pane when the agent runs inside another window.
+func (m *Manager) stopAgentInSession(repoRoot string, wt *Worktree) error {
+	session := m.tmuxWorktreeSessionName(repoRoot, wt)
+	agentWindow := m.tmuxAgentWindowName(worktreeBranchOrName(wt))
+	if m.tmuxWindowExists(session, agentWindow) {
+		return m.runCmdQuiet("", "tmux", "kill-window", "-t", session+":"+agentWindow)
+	}
+	if paneID, ok := m.findAgentPaneInSession(session, wt.Path); ok {
+		return m.runCmdQuiet("", "tmux", "kill-pane", "-t", paneID)
+	}
+	return nil
+}
+
+// exitAgentGracefully asks the running agent of wt to exit by sending it the
+// agent_exit_keys, then waits up to agent_exit_wait seconds for it to, so it
+// can save its state and finish writing files before its window is killed.
+// It reports whether the agent exited; callers kill the window either way.
+func (m *Manager) exitAgentGracefully(ctx context.Context, repoRoot string, wt *Worktree) bool {
+	if len(m.Cfg.AgentExitKeys) == 0 || m.Cfg.AgentExitWait <= 0 {
+		return false
+	}
+	pane := m.agentPaneTarget(repoRoot, wt)
+	if m.agentPaneExited(pane) {
+		return true
+	}
+	args := append([]string{"send-keys", "-t", pane}, m.Cfg.AgentExitKeys...)
+	if err := m.runCmdQuiet("", "tmux", args...); err != nil {
+		errorLogf("agent_exit send_keys failed path=%q pane=%q: %v", wt.Path, pane, err)
+		return false
+	}
+	deadline := time.Now().Add(time.Duration(m.Cfg.AgentExitWait) * time.Second)
+	for time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(agentExitPollInterval):
+		}
+		if m.agentPaneExited(pane) {
+			infoLogf("agent_exit done path=%q", wt.Path)
+			return true
+		}
+	}
+	infoLogf("agent_exit timed out path=%q wait=%ds", wt.Path, m.Cfg.AgentExitWait)
+	return false
+}
+
+// agentPaneExited reports whether the agent in a pane is gone. An agent the
+// pane was started with ends the pane, or leaves it dead under
+// remain-on-exit; one typed into a shell leaves the shell in front.
+func (m *Manager) agentPaneExited(paneTarget string) bool {
+	out, err := m.runCmdOutput("", "tmux", "display-message", "-p", "-t", paneTarget, "#{pane_dead}\t#{pane_start_command}\t#{pane_current_command}")
+	if err != nil {
+		return !m.tmuxPaneExists(paneTarget)
+	}
+	dead, rest, _ := strings.Cut(strings.TrimRight(out, "\n"), "\t")
+	start, current, _ := strings.Cut(rest, "\t")
+	if dead == "1" {
+		return true
+	}
+	if commandShouldRemainOnExit(strings.Trim(start, `"`)) {
+		return false
+	}
+	return !commandShouldRemainOnExit(current)
+}
@@ -0,0 +1,208 @@
+package sprout
+
+import (
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Slug modes decide what happens to non-ASCII letters in feature names.
+const (
+	// slugModeASCII transliterates Latin, Cyrillic, Greek, and kana letters
+	// and drops anything else.
+	slugModeASCII = "ascii"
+	// slugModeUnicode keeps letters and digits of every script.
+	slugModeUnicode = "unicode"
+)
+
+var slugBadUnicodeRe = regexp.MustCompile(`[^\p{L}\p{M}\p{N}/-]+`)
+
+func parseSlugMode(value string) (string, error) {
+	switch v := strings.ToLower(strings.TrimSpace(value)); v {
+	case "", slugModeASCII:
+		return slugModeASCII, nil
+	case slugModeUnicode:
+		return v, nil
+	}
+	return "", fmt.Errorf("invalid slug_mode %q (want ascii or unicode)", value)
+}
+
+// translitTable spells out letters that do not decompose into an ASCII base
+// letter plus accents.
+var translitTable = map[rune]string{
+	'ß': "ss", 'æ': "ae", 'œ': "oe", 'ø': "o", 'đ': "d", 'ð': "d", 'þ': "th", 'ł': "l", 'ı': "i",
+
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'ґ': "g", 'д': "d", 'е': "e", 'є': "ye", 'ж': "zh",
+	'з': "z", 'и': "i", 'і': "i", 'й': "i", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o",
+	'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch",
+	'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya",
+
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i", 'θ': "th", 'ι': "i",
+	'κ': "k", 'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x", 'ο': "o", 'π': "p", 'ρ': "r", 'σ': "s",
+	'ς': "s", 'τ': "t", 'υ': "y", 'φ': "f", 'χ': "ch", 'ψ': "ps", 'ω': "o",
+
+	'あ': "a", 'い': "i", 'う': "u", 'え': "e", 'お': "o",
+	'か': "ka", 'き': "ki", 'く': "ku", 'け': "ke", 'こ': "ko",
+	'が': "ga", 'ぎ': "gi", 'ぐ': "gu", 'げ': "ge", 'ご': "go",
+	'さ': "sa", 'し': "shi", 'す': "su", 'せ': "se", 'そ': "so",
+	'ざ': "za", 'じ': "ji", 'ず': "zu", 'ぜ': "ze", 'ぞ': "zo",
+	'た': "ta", 'ち': "chi", 'つ': "tsu", 'て': "te", 'と': "to",
+	'だ': "da", 'ぢ': "ji", 'づ': "zu", 'で': "de", 'ど': "do",
+	'な': "na", 'に': "ni", 'ぬ': "nu", 'ね': "ne", 'の': "no",
+	'は': "ha", 'ひ': "hi", 'ふ': "fu", 'へ': "he", 'ほ': "ho",
+	'ば': "ba", 'び': "bi", 'ぶ': "bu", 'べ': "be", 'ぼ': "bo",
+	'ぱ': "pa", 'ぴ': "pi", 'ぷ': "pu", 'ぺ': "pe", 'ぽ': "po",
+	'ま': "ma", 'み': "mi", 'む': "mu", 'め': "me", 'も': "mo",
+	'や': "ya", 'ゆ': "yu", 'よ': "yo",
+	'ら': "ra", 'り': "ri", 'る': "ru", 'れ': "re", 'ろ': "ro",
+	'わ': "wa", 'を': "o", 'ん': "n", 'ゔ': "vu",
+	'ゎ': "wa",
+}
+
+// kanaYoon holds the small ya/yu/yo that merge with the kana before them,
+// as in きゃ (kya) or しょ (sho).
+var kanaYoon = map[rune]string{'ゃ': "a", 'ゅ': "u", 'ょ': "o"}
+
+// kanaSmallVowels replace the vowel of the kana before them, as in チェ
+// (che) or ファ (fa).
+var kanaSmallVowels = map[rune]byte{'ぁ': 'a', 'ぃ': 'i', 'ぅ': 'u', 'ぇ': 'e', 'ぉ': 'o'}
+
+// transliterate spells s in ASCII as far as it can: accents are dropped and
+// Cyrillic, Greek, and kana are romanized, keeping case. ASCII passes
+// through unchanged, as do letters it has no spelling for, like kanji.
+func transliterate(s string) string {
+	var out []byte
+	lastKana := -1
+	geminate := false
+	for _, r := range norm.NFC.String(s) {
+		upper := unicode.IsUpper(r)
+		r = unicode.ToLower(r)
+		if r >= 'ァ' && r <= 'ヶ' {
+			// Katakana sits 0x60 above its hiragana twin.
+			r -= 0x60
+		}
+		if r == 'っ' {
+			geminate = true
+			continue
+		}
+		if r == 'ー' {
+			continue
+		}
+		if vowel, ok := kanaYoon[r]; ok {
+			if lastKana >= 0 && len(out)-lastKana > 1 && out[len(out)-1] == 'i' {
+				out = out[:len(out)-1]
+				prev := string(out[lastKana:])
+				if !strings.HasSuffix(prev, "sh") && !strings.HasSuffix(prev, "ch") && !strings.HasSuffix(prev, "j") {
+					out = append(out, 'y')
+				}
+				out = append(out, vowel...)
+			} else {
+				out = append(out, 'y')
+				out = append(out, vowel...)
+			}
+			lastKana = -1
+			continue
+		}
+		if vowel, ok := kanaSmallVowels[r]; ok {
+			if lastKana >= 0 && len(out)-lastKana > 1 {
+				out[len(out)-1] = vowel
+			} else {
+				out = append(out, vowel)
+			}
+			lastKana = -1
+			continue
+		}
+		if spelled, ok := translitTable[r]; ok {
+			if upper && spelled != "" {
+				spelled = strings.ToUpper(spelled[:1]) + spelled[1:]
+			}
+			if r >= 'ぁ' && r <= 'ゖ' {
+				if geminate && spelled != "" && !strings.ContainsRune("aiueon", rune(spelled[0])) {
+					if strings.HasPrefix(spelled, "ch") {
+						out = append(out, 't')
+					} else {
+						out = append(out, spelled[0])
+					}
+				}
+				geminate = false
+				lastKana = len(out)
+			} else {
+				lastKana = -1
+			}
+			out = append(out, spelled...)
+			continue
+		}
+		geminate = false
+		lastKana = -1
+		if upper {
+			r = unicode.ToUpper(r)
+		}
+		if r <= unicode.MaxASCII {
+			out = append(out, byte(r))
+			continue
+		}
+		// Drop accents: é decomposes into e and a combining mark.
+		base := []rune(norm.NFD.String(string(r)))
+		if base[0] <= unicode.MaxASCII {
+			for _, c := range base[1:] {
+				if !unicode.Is(unicode.Mn, c) {
+					base = nil
+					break
+				}
+			}
+			if base != nil {
+				out = append(out, byte(base[0]))
+				continue
+			}
+		}
+		out = append(out, string(r)...)
+	}
+	return string(out)
+}
+
+// slugify turns a feature name into a branch slug. The ascii mode
+// transliterates first and then drops whatever is left outside [a-z0-9].
+func slugify(input, mode string) string {
+	slug := strings.ToLower(input)
+	badRe := slugBadRe
+	if mode == slugModeUnicode {
+		slug = norm.NFC.String(slug)
+		badRe = slugBadUnicodeRe
+	} else {
+		slug = transliterate(slug)
+	}
+	slug = strings.ReplaceAll(slug, "_", "-")
+	slug = strings.ReplaceAll(slug, " ", "-")
+	slug = badRe.ReplaceAllString(slug, "-")
+	slug = slashRe.ReplaceAllString(slug, "/")
+	slug = dashRe.ReplaceAllString(slug, "-")
+	return strings.Trim(slug, "-/")
+}
+
+// hasNonASCIILetter reports whether s has a letter outside ASCII.
+func hasNonASCIILetter(s string) bool {
+	for _, r := range s {
+		if r > unicode.MaxASCII && unicode.IsLetter(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// sessionToken makes a tmux-safe token from value. Letters transliterate
+// cannot spell would all collapse into "-", so a short hash of the original
+// keeps names like feat/日本語 and feat/中文 from sharing a session.
+func sessionToken(value string) string {
+	spelled := transliterate(value)
+	token := safeName(spelled)
+	if hasNonASCIILetter(spelled) {
+		h := fnv.New32a()
+		h.Write([]byte(value))
+		token = safeName(fmt.Sprintf("%s-%06x", token, h.Sum32()&0xffffff))
+	}
+	return token
+}
@@ -0,0 +1,306 @@
+package sprout
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Branch outcomes recorded when a worktree is removed.
+const (
+	branchOutcomeMerged    = "merged"
+	branchOutcomeAbandoned = "abandoned"
+)
+
+// branchOutcome tells whether branch was merged into base_branch before its
+// worktree goes away. Merges, rebases, and squash merges all count as merged.
+// It returns "" when there is nothing to compare against.
+func (m *Manager) branchOutcome(repoRoot, branch string) string {
+	base := m.Cfg.BaseBranch
+	if branch == "" || branch == base || !m.BranchExists(repoRoot, base) || !m.BranchExists(repoRoot, branch) {
+		return ""
+	}
+	ahead, err := m.runCmdOutput(repoRoot, "git", "rev-list", "--count", base+".."+branch)
+	if err != nil {
+		return ""
+	}
+	if strings.TrimSpace(ahead) == "0" {
+		// Nothing left to merge: either it was merged, or nothing was ever
+		// committed to it. A branch that never moved has a single reflog
+		// entry, the one for its creation.
+		reflog, err := m.runCmdOutput(repoRoot, "git", "reflog", "show", "--format=%H", "refs/heads/"+branch, "--")
+		if err != nil {
+			return ""
+		}
+		switch len(strings.Fields(reflog)) {
+		case 0:
+			return ""
+		case 1:
+			return branchOutcomeAbandoned
+		}
+		return branchOutcomeMerged
+	}
+	if m.commitsUpstream(repoRoot, base, branch) {
+		return branchOutcomeMerged
+	}
+	// A squash merge lands the whole branch as one commit, so compare
+	// against a single commit holding the branch's changes.
+	mergeBase, err := m.runCmdOutput(repoRoot, "git", "merge-base", base, branch)
+	if err != nil {
+		return branchOutcomeAbandoned
+	}
+	squashed, err := m.runCmdOutput(repoRoot, "git", "commit-tree", branch+"^{tree}", "-p", strings.TrimSpace(mergeBase), "-m", "sprout squash check")
+	if err == nil && m.commitsUpstream(repoRoot, base, strings.TrimSpace(squashed)) {
+		return branchOutcomeMerged
+	}
+	return branchOutcomeAbandoned
+}
+
+// aheadBehind counts the commits of branch missing from base, and of base
+// missing from branch. It returns -1, -1 when either is unknown or they are
+// the same branch.
+// cachedAheadBehind is aheadBehind cached by the commits base and branch
+// point at, as read by branchTips, so the count is only redone once either
+// moves.
+func (m *Manager) cachedAheadBehind(repoRoot, base, branch string, tips map[string]branchTip) (int, int) {
+	baseCommit, branchCommit := tips[base].Commit, tips[branch].Commit
+	if baseCommit == "" || branchCommit == "" {
+		return m.aheadBehind(repoRoot, base, branch)
+	}
+	counts, _ := cachedRepoQuery(m, repoRoot, "ahead_behind\x00"+baseCommit+"\x00"+branchCommit, aheadBehindCacheTTL, func() ([2]int, error) {
+		ahead, behind := m.aheadBehind(repoRoot, base, branch)
+		return [2]int{ahead, behind}, nil
+	})
+	return counts[0], counts[1]
+}
+
+func (m *Manager) aheadBehind(repoRoot, base, branch string) (int, int) {
+	if base == "" || branch == "" || branch == base {
+		return -1, -1
+	}
+	out, err := m.runCmdOutput(repoRoot, "git", "rev-list", "--left-right", "--count", "refs/heads/"+base+"...refs/heads/"+branch)
+	if err != nil {
+		return -1, -1
+	}
+	fields := strings.Fields(out)
+	if len(fields) != 2 {
+		return -1, -1
+	}
+	behind, err1 := strconv.Atoi(fields[0])
+	ahead, err2 := strconv.Atoi(fields[1])
+	if err1 != nil || err2 != nil {
+		return -1, -1
+	}
+	return ahead, behind
+}
+
+// commitsUpstream reports whether every commit of tip missing from base has
+// an equivalent change in base, as git cherry sees it.
+func (m *Manager) commitsUpstream(repoRoot, base, tip string) bool {
+	out, err := m.runCmdOutput(repoRoot, "git", "cherry", base, tip)
+	if err != nil {
+		return false
+	}
+	lines := strings.Fields(strings.TrimSpace(out))
+	if len(lines) == 0 {
+		return false
+	}
+	for i := 0; i < len(lines); i += 2 {
+		if lines[i] != "-" {
+			return false
+		}
+	}
+	return true
+}
+
+// StatsWeek counts the worktrees created and removed in the week starting
+// on Start, a Monday.
+type StatsWeek struct {
+	Start   time.Time `json:"start"`
+	Created int       `json:"created"`
+	Removed int       `json:"removed"`
+}
+
+// Stats summarizes worktree and agent activity from the event log.
+type Stats struct {
+	// Repo is the repository summarized, or empty for every repository.
+	Repo      string     `json:"repo"`
+	Since     *time.Time `json:"since,omitempty"`
+	Created   int        `json:"created"`
+	Removed   int        `json:"removed"`
+	Merged    int        `json:"merged"`
+	Abandoned int        `json:"abandoned"`
+	// AverageLifetimeHours is measured over the removed worktrees whose
+	// creation is also in the log, LifetimesMeasured of them.
+	AverageLifetimeHours float64     `json:"average_lifetime_hours"`
+	LifetimesMeasured    int         `json:"lifetimes_measured"`
+	AgentHours           float64     `json:"agent_hours"`
+	AgentRuns            int         `json:"agent_runs"`
+	Weeks                []StatsWeek `json:"weeks"`
+}
+
+// StatsOptions selects what Stats summarizes.
+type StatsOptions struct {
+	// All summarizes every repository instead of the current one.
+	All   bool
+	Since time.Time
+}
+
+// Stats summarizes the event log for the current repository, or for every
+// repository with opts.All or outside one.
+func (m *Manager) Stats(opts StatsOptions) (Stats, error) {
+	filter := EventFilter{}
+	repo := ""
+	if !opts.All {
+		if repoRoot, err := m.RequireRepo(); err == nil {
+			filter.RepoRoot = m.mainRepoRoot(repoRoot)
+			repo = m.RepoName(repoRoot)
+		}
+	}
+	// Spans that started before opts.Since still count from then on, so
+	// the whole log is read and clipped in computeStats.
+	events, err := ReadEvents(filter)
+	if err != nil {
+		return Stats{}, err
+	}
+	running := func(ev Event) bool {
+		status := m.agentStatus(ev.RepoRoot, &Worktree{Path: ev.Path, Branch: ev.Branch})
+		return status == agentStatusBusy || status == agentStatusReady
+	}
+	stats := computeStats(events, opts.Since, time.Now(), running)
+	stats.Repo = repo
+	return stats, nil
+}
+
+// computeStats folds events into Stats, counting what happened from since
+// (or the start of the log) to now. An agent counts from agent_started until
+// it is stopped, exits, its worktree is removed, or it is started again; one
+// still open at the end counts until now only if running says it is, since
+// an agent lost with the tmux server leaves no event behind.
+func computeStats(events []Event, since, now time.Time, running func(Event) bool) Stats {
+	events = append([]Event(nil), events...)
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Time.Before(events[j].Time) })
+
+	stats := Stats{Weeks: []StatsWeek{}}
+	if !since.IsZero() {
+		stats.Since = &since
+	}
+	inRange := func(t time.Time) bool { return since.IsZero() || !t.Before(since) }
+	weeks := map[time.Time]*StatsWeek{}
+	week := func(t time.Time) *StatsWeek {
+		start := weekStart(t)
+		w, ok := weeks[start]
+		if !ok {
+			w = &StatsWeek{Start: start}
+			weeks[start] = w
+		}
+		return w
+	}
+
+	created := map[string]time.Time{}
+	agents := map[string]Event{}
+	var agentTime, lifetime time.Duration
+	endAgent := func(path string, end time.Time) {
+		start, ok := agents[path]
+		if !ok {
+			return
+		}
+		delete(agents, path)
+		from := start.Time
+		if !since.IsZero() && from.Before(since) {
+			from = since
+		}
+		if end.After(from) {
+			agentTime += end.Sub(from)
+			stats.AgentRuns++
+		}
+	}
+
+	for _, ev := range events {
+		switch ev.Type {
+		case eventWorktreeCreated:
+			created[ev.Path] = ev.Time
+			if inRange(ev.Time) {
+				stats.Created++
+				week(ev.Time).Created++
+			}
+		case eventWorktreeRemoved:
+			endAgent(ev.Path, ev.Time)
+			createdAt, ok := created[ev.Path]
+			delete(created, ev.Path)
+			if !inRange(ev.Time) {
+				continue
+			}
+			stats.Removed++
+			week(ev.Time).Removed++
+			switch ev.Outcome {
+			case branchOutcomeMerged:
+				stats.Merged++
+			case branchOutcomeAbandoned:
+				stats.Abandoned++
+			}
+			if ok {
+				lifetime += ev.Time.Sub(createdAt)
+				stats.LifetimesMeasured++
+			}
+		case eventAgentStarted:
+			endAgent(ev.Path, ev.Time)
+			agents[ev.Path] = ev
+		case eventAgentStopped, eventAgentExited:
+			endAgent(ev.Path, ev.Time)
+		}
+	}
+	for path, start := range agents {
+		if running != nil && running(start) {
+			endAgent(path, now)
+		}
+	}
+
+	if stats.LifetimesMeasured > 0 {
+		stats.AverageLifetimeHours = lifetime.Hours() / float64(stats.LifetimesMeasured)
+	}
+	stats.AgentHours = agentTime.Hours()
+
+	// List every week from the first to the last with activity, so quiet
+	// weeks show as zeros.
+	var starts []time.Time
+	for start := range weeks {
+		starts = append(starts, start)
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+	if len(starts) > 0 {
+		last := starts[len(starts)-1]
+		for start := starts[0]; !start.After(last); start = start.AddDate(0, 0, 7) {
+			w := StatsWeek{Start: start}
+			if counted, ok := weeks[start]; ok {
+				w = *counted
+			}
+			stats.Weeks = append(stats.Weeks, w)
+		}
+	}
+	return stats
+}
+
+// weekStart returns the local midnight starting the Monday-based week of t.
+func weekStart(t time.Time) time.Time {
+	t = t.Local()
+	offset := (int(t.Weekday()) + 6) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, time.Local)
+}
+
+// formatHours renders a number of hours as "3d 4h", "5h 30m", or "12m".
+func formatHours(hours float64) string {
+	d := time.Duration(hours * float64(time.Hour)).Round(time.Minute)
+	days := int(d / (24 * time.Hour))
+	h := int(d % (24 * time.Hour) / time.Hour)
+	mins := int(d % time.Hour / time.Minute)
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh", days, h)
+	case h > 0:
+		return fmt.Sprintf("%dh %dm", h, mins)
+	}
+	return fmt.Sprintf("%dm", mins)
+}
@@ -0,0 +1,154 @@
+package sprout
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Formats accepted by sprout statusline.
+const (
+	statuslinePlain    = "plain"
+	statuslineTmux     = "tmux"
+	statuslineStarship = "starship"
+)
+
+func parseStatuslineFormat(value string) (string, error) {
+	switch v := strings.ToLower(strings.TrimSpace(value)); v {
+	case "", statuslinePlain:
+		return statuslinePlain, nil
+	case statuslineTmux, statuslineStarship:
+		return v, nil
+	}
+	return "", fmt.Errorf("invalid statusline format %q (want plain, tmux, or starship)", value)
+}
+
+// Stale reports whether the watcher that wrote the status has missed a few
+// of its refreshes, such as after it was stopped.
+func (s RepoStatus) Stale(now time.Time) bool {
+	interval := time.Duration(s.Interval) * time.Second
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	return now.Sub(s.UpdatedAt) > 3*interval
+}
+
+// pathWithin reports whether path is dir or inside it.
+func pathWithin(path, dir string) bool {
+	if dir == "" {
+		return false
+	}
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// statusForDir picks the status of the repository dir is in, going by the
+// paths of its worktrees so no git command has to run. Outside any watched
+// repository it adds up all of them. ok is false when there is no status.
+func statusForDir(status WatchStatus, dir string) (RepoStatus, bool) {
+	best, bestLen := RepoStatus{}, -1
+	for _, repo := range status.Repos {
+		paths := []string{repo.Root}
+		for _, item := range repo.Items {
+			paths = append(paths, item.Path)
+		}
+		for _, p := range paths {
+			// The longest match wins, for worktrees nested in other
+			// repositories' directories.
+			if pathWithin(dir, p) && len(p) > bestLen {
+				best, bestLen = repo, len(p)
+			}
+		}
+	}
+	if bestLen >= 0 {
+		return best, true
+	}
+	if len(status.Repos) == 0 {
+		return RepoStatus{}, false
+	}
+	var total RepoStatus
+	for _, repo := range status.Repos {
+		total.Worktrees += repo.Worktrees
+		total.Dirty += repo.Dirty
+		total.Agents.Busy += repo.Agents.Busy
+		total.Agents.Ready += repo.Agents.Ready
+		total.Agents.Crashed += repo.Agents.Crashed
+		// The stalest repository decides whether the total is stale.
+		if total.UpdatedAt.IsZero() || repo.UpdatedAt.Before(total.UpdatedAt) {
+			total.UpdatedAt, total.Interval = repo.UpdatedAt, repo.Interval
+		}
+	}
+	return total, true
+}
+
+// renderStatusline renders a status as one short line, like
+// "3 wt · 2 agents ready · 1 dirty". Counts that are zero are left out; tmux
+// gets its #[fg=...] color markup. A stale status is marked as such, except
+// for starship, which hides the module rather than show old counts in every
+// prompt.
+func renderStatusline(status RepoStatus, format string, now time.Time) string {
+	stale := status.Stale(now)
+	if stale && format == statuslineStarship {
+		return ""
+	}
+	color := func(text, fg string) string {
+		if format != statuslineTmux {
+			return text
+		}
+		return "#[fg=" + fg + "]" + text + "#[default]"
+	}
+	agents := func(n int) string {
+		if n == 1 {
+			return "1 agent"
+		}
+		return fmt.Sprintf("%d agents", n)
+	}
+	parts := []string{fmt.Sprintf("%d wt", status.Worktrees)}
+	if status.Agents.Ready > 0 {
+		parts = append(parts, color(agents(status.Agents.Ready)+" ready", "green"))
+	}
+	if status.Agents.Busy > 0 {
+		parts = append(parts, color(fmt.Sprintf("%d busy", status.Agents.Busy), "yellow"))
+	}
+	if status.Agents.Crashed > 0 {
+		parts = append(parts, color(fmt.Sprintf("%d crashed", status.Agents.Crashed), "red"))
+	}
+	if status.Dirty > 0 {
+		parts = append(parts, fmt.Sprintf("%d dirty", status.Dirty))
+	}
+	if stale {
+		parts = append(parts, color("stale", "colour244"))
+	}
+	return strings.Join(parts, " · ")
+}
+
+// Statusline renders the status sprout watch last wrote to path for the
+// repository of dir. It only reads the status file, so it stays fast enough
+// to run on every prompt or status bar redraw. It is empty when there is no
+// status yet.
+func Statusline(path, dir, format string, now time.Time) (string, error) {
+	status, err := ReadWatchStatus(path)
+	if err != nil {
+		return "", err
+	}
+	repo, ok := statusForDir(status, dir)
+	if !ok {
+		return "", nil
+	}
+	return renderStatusline(repo, format, now), nil
+}
+
+// statuslineDir is the directory the status line is for: the pane's
+// directory when tmux passes it, else the working directory.
+func statuslineDir(dir string) string {
+	if dir != "" {
+		return dir
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	return wd
+}
@@ -0,0 +1,181 @@
+package sprout
+
+import (
+	"bytes"
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SymbolChange is a top-level function, method, or type that was added,
+// removed, or modified on a worktree's branch.
+type SymbolChange struct {
+	File   string
+	Kind   string
+	Name   string
+	Change string
+}
+
+type goSymbol struct {
+	Kind   string
+	Name   string
+	Source string
+}
+
+// WorktreeSymbolChanges summarizes the symbols changed in the worktree since
+// it forked from the base branch. Only Go sources are understood; files that
+// do not parse are skipped.
+func (m *Manager) WorktreeSymbolChanges(repoRoot string, wt *Worktree) ([]SymbolChange, error) {
+	since := m.branchDiffBase(repoRoot, wt)
+	out, err := m.runCmdOutput(wt.Path, "git", "--no-pager", "diff", "--name-status", "--no-renames", since)
+	if err != nil {
+		return nil, err
+	}
+	statuses := map[string]string{}
+	for _, line := range strings.Split(out, "\n") {
+		status, file, ok := strings.Cut(line, "\t")
+		if ok {
+			statuses[file] = status
+		}
+	}
+	untracked, err := m.runCmdOutput(wt.Path, "git", "ls-files", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range strings.Split(untracked, "\n") {
+		if file = strings.TrimSpace(file); file != "" {
+			statuses[file] = "A"
+		}
+	}
+
+	files := make([]string, 0, len(statuses))
+	for file := range statuses {
+		if filepath.Ext(file) == ".go" {
+			files = append(files, file)
+		}
+	}
+	sort.Strings(files)
+
+	var changes []SymbolChange
+	for _, file := range files {
+		status := statuses[file]
+		var oldSrc, newSrc []byte
+		if status != "A" {
+			src, err := m.runCmdBytes(wt.Path, "git", "show", since+":"+file)
+			if err != nil {
+				errorLogf("symbols read_base failed path=%q file=%q: %v", wt.Path, file, err)
+				continue
+			}
+			oldSrc = src
+		}
+		if status != "D" {
+			src, err := m.readFile(filepath.Join(wt.Path, file))
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				errorLogf("symbols read_worktree failed path=%q file=%q: %v", wt.Path, file, err)
+				continue
+			}
+			newSrc = src
+		}
+		fileChanges, err := diffGoSymbols(file, oldSrc, newSrc)
+		if err != nil {
+			debugLogf("symbols parse failed path=%q file=%q: %v", wt.Path, file, err)
+			continue
+		}
+		changes = append(changes, fileChanges...)
+	}
+	return changes, nil
+}
+
+// diffGoSymbols compares the top-level declarations of two versions of a Go
+// file. A nil source stands for a file that does not exist on that side.
+func diffGoSymbols(file string, oldSrc, newSrc []byte) ([]SymbolChange, error) {
+	oldSyms, err := goFileSymbols(oldSrc)
+	if err != nil {
+		return nil, err
+	}
+	newSyms, err := goFileSymbols(newSrc)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []SymbolChange
+	for key, sym := range newSyms {
+		prev, ok := oldSyms[key]
+		switch {
+		case !ok:
+			changes = append(changes, SymbolChange{File: file, Kind: sym.Kind, Name: sym.Name, Change: "added"})
+		case prev.Source != sym.Source:
+			changes = append(changes, SymbolChange{File: file, Kind: sym.Kind, Name: sym.Name, Change: "modified"})
+		}
+	}
+	for key, sym := range oldSyms {
+		if _, ok := newSyms[key]; !ok {
+			changes = append(changes, SymbolChange{File: file, Kind: sym.Kind, Name: sym.Name, Change: "removed"})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Name != changes[j].Name {
+			return changes[i].Name < changes[j].Name
+		}
+		return changes[i].Kind < changes[j].Kind
+	})
+	return changes, nil
+}
+
+func goFileSymbols(src []byte) (map[string]goSymbol, error) {
+	syms := map[string]goSymbol{}
+	if src == nil {
+		return syms, nil
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	add := func(kind, name string, node ast.Node) {
+		var buf bytes.Buffer
+		_ = printer.Fprint(&buf, fset, node)
+		syms[kind+" "+name] = goSymbol{Kind: kind, Name: name, Source: buf.String()}
+	}
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				add("method", "("+goReceiverName(d.Recv.List[0].Type)+")."+d.Name.Name, d)
+			} else {
+				add("func", d.Name.Name, d)
+			}
+		case *ast.GenDecl:
+			if d.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range d.Specs {
+				if ts, ok := spec.(*ast.TypeSpec); ok {
+					add("type", ts.Name.Name, ts)
+				}
+			}
+		}
+	}
+	return syms, nil
+}
+
+func goReceiverName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return "*" + goReceiverName(t.X)
+	case *ast.IndexExpr:
+		return goReceiverName(t.X)
+	case *ast.IndexListExpr:
+		return goReceiverName(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return "?"
+}
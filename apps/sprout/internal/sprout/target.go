@@ -0,0 +1,108 @@
+package sprout
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// errAmbiguousTarget is wrapped by the error of a target that partially
+// matches more than one worktree.
+var errAmbiguousTarget = errors.New("ambiguous target")
+
+// ResolveTarget finds the worktree a command-line target names. An exact
+// branch, path, or directory name wins; otherwise a target that is a prefix
+// of one worktree's branch or directory name, then one that fuzzily matches a
+// single branch, such as chkout for feat/checkout-redesign. It reports
+// whether the match was exact. A partial match of several worktrees fails
+// with errAmbiguousTarget, listing them.
+func (m *Manager) ResolveTarget(target string) (*Worktree, bool, error) {
+	repoRoot, err := m.RequireRepo()
+	if err != nil {
+		return nil, false, err
+	}
+	items, err := m.parseWorktreeList(repoRoot)
+	if err != nil {
+		return nil, false, err
+	}
+	targetAbs := ""
+	if st, err := os.Stat(target); err == nil && st.IsDir() {
+		targetAbs = absPath(target)
+	}
+	for i := range items {
+		items[i].Path = absPath(items[i].Path)
+		if m.worktreeMatchesTarget(&items[i], target, targetAbs) {
+			return &items[i], true, nil
+		}
+	}
+
+	needle := strings.ToLower(strings.TrimSpace(target))
+	if needle == "" {
+		return nil, false, fmt.Errorf("%w for target: %s", errWorktreeNotFound, target)
+	}
+	var prefixed []*Worktree
+	for i := range items {
+		for _, name := range targetNames(&items[i]) {
+			if strings.HasPrefix(strings.ToLower(name), needle) {
+				prefixed = append(prefixed, &items[i])
+				break
+			}
+		}
+	}
+	if len(prefixed) == 1 {
+		return prefixed[0], false, nil
+	}
+	if len(prefixed) > 1 {
+		return nil, false, ambiguousTargetError(target, prefixed)
+	}
+
+	type scored struct {
+		wt    *Worktree
+		score int
+	}
+	var fuzzy []scored
+	for i := range items {
+		best, found := 0, false
+		for _, name := range targetNames(&items[i]) {
+			if score, _, ok := fuzzyMatch(needle, name); ok && (!found || score > best) {
+				best, found = score, true
+			}
+		}
+		if found {
+			fuzzy = append(fuzzy, scored{wt: &items[i], score: best})
+		}
+	}
+	switch len(fuzzy) {
+	case 0:
+		return nil, false, fmt.Errorf("%w for target: %s", errWorktreeNotFound, target)
+	case 1:
+		return fuzzy[0].wt, false, nil
+	}
+	sort.SliceStable(fuzzy, func(i, j int) bool { return fuzzy[i].score > fuzzy[j].score })
+	candidates := make([]*Worktree, 0, len(fuzzy))
+	for _, s := range fuzzy {
+		candidates = append(candidates, s.wt)
+	}
+	return nil, false, ambiguousTargetError(target, candidates)
+}
+
+// targetNames are the names of a worktree a partial target is matched
+// against: its branch and its directory name.
+func targetNames(wt *Worktree) []string {
+	names := []string{filepath.Base(wt.Path)}
+	if wt.Branch != "" {
+		names = append([]string{wt.Branch}, names...)
+	}
+	return names
+}
+
+func ambiguousTargetError(target string, candidates []*Worktree) error {
+	names := make([]string, 0, len(candidates))
+	for _, wt := range candidates {
+		names = append(names, "  "+worktreeBranchOrName(wt))
+	}
+	return fmt.Errorf("%w %q matches %d worktrees:\n%s\nuse a longer target, or --pick to choose one", errAmbiguousTarget, target, len(candidates), strings.Join(names, "\n"))
+}
@@ -0,0 +1,107 @@
+package sprout
+
+import (
+	"bufio"
+	"bytes"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const todoScanMaxFileBytes = 1 << 20
+
+var (
+	todoMarkerRe = regexp.MustCompile(`\b(TODO|FIXME)\b`)
+	hunkHeaderRe = regexp.MustCompile(`^@@ -\d+(?:,\d+)? \+(\d+)(?:,\d+)? @@`)
+)
+
+// TodoMarker is a TODO or FIXME comment added on a worktree's branch.
+type TodoMarker struct {
+	File string
+	Line int
+	Kind string
+	Text string
+}
+
+// WorktreeTodos returns the TODO/FIXME markers added in the worktree compared
+// to the merge base with the base branch, including uncommitted and untracked
+// files.
+func (m *Manager) WorktreeTodos(repoRoot string, wt *Worktree) ([]TodoMarker, error) {
+	since := m.branchDiffBase(repoRoot, wt)
+	diff, err := m.runCmdOutput(wt.Path, "git", "--no-pager", "diff", "--no-color", "--no-ext-diff", "-U0", since)
+	if err != nil {
+		return nil, err
+	}
+	markers := parseAddedTodos(diff)
+
+	untracked, err := m.runCmdOutput(wt.Path, "git", "ls-files", "--others", "--exclude-standard")
+	if err != nil {
+		return markers, err
+	}
+	for _, file := range strings.Split(untracked, "\n") {
+		file = strings.TrimSpace(file)
+		if file == "" {
+			continue
+		}
+		markers = append(markers, m.scanFileTodos(wt.Path, file)...)
+	}
+	return markers, nil
+}
+
+// parseAddedTodos extracts markers from the added lines of a unified diff.
+func parseAddedTodos(diff string) []TodoMarker {
+	var markers []TodoMarker
+	file := ""
+	line := 0
+	for _, raw := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(raw, "+++ "):
+			file = strings.TrimPrefix(strings.TrimPrefix(raw, "+++ "), "b/")
+			if file == "/dev/null" {
+				file = ""
+			}
+		case strings.HasPrefix(raw, "@@"):
+			if match := hunkHeaderRe.FindStringSubmatch(raw); match != nil {
+				line, _ = strconv.Atoi(match[1])
+			}
+		case strings.HasPrefix(raw, "+"):
+			if file != "" {
+				if marker, ok := todoMarkerInLine(file, line, raw[1:]); ok {
+					markers = append(markers, marker)
+				}
+			}
+			line++
+		}
+	}
+	return markers
+}
+
+func (m *Manager) scanFileTodos(root, file string) []TodoMarker {
+	path := filepath.Join(root, file)
+	st, err := m.statPath(path)
+	if err != nil || !st.Mode().IsRegular() || st.Size() > todoScanMaxFileBytes {
+		return nil
+	}
+	data, err := m.readFile(path)
+	if err != nil || bytes.IndexByte(data, 0) >= 0 {
+		return nil
+	}
+	var markers []TodoMarker
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), todoScanMaxFileBytes)
+	for line := 1; scanner.Scan(); line++ {
+		if marker, ok := todoMarkerInLine(file, line, scanner.Text()); ok {
+			markers = append(markers, marker)
+		}
+	}
+	return markers
+}
+
+func todoMarkerInLine(file string, line int, text string) (TodoMarker, bool) {
+	match := todoMarkerRe.FindStringSubmatch(text)
+	if match == nil {
+		return TodoMarker{}, false
+	}
+	return TodoMarker{File: file, Line: line, Kind: match[1], Text: strings.TrimSpace(text)}, true
+}
@@ -1,7 +1,10 @@
 package sprout
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -23,42 +26,69 @@ type repoChoice struct {
 	Name       string
 	GitHubRepo string
 	Branch     string
+	// Worktrees and Ready count the worktrees and the agents waiting for
+	// input, for the repository sidebar. They are only counted while it
+	// is shown, and not for the current repository, whose rows are known.
+	Worktrees int
+	Ready     int
 }
 
 type tuiState struct {
+	// mgr is read by background goroutines, so its Cfg is never written
+	// while the TUI runs: pane toggles live in fields of tuiState and are
+	// saved to the config file. Switching repositories replaces it, under
+	// mgrMu, with a Manager holding that repository's config.
 	mgr      *Manager
+	mgrMu    sync.Mutex
 	repoName string
 	repoRoot string
 	repoSlug string
-
-	app         *tview.Application
-	pages       *tview.Pages
-	table       *counterTable
-	statusPane  *tview.TextView
-	detailPane  *tview.Flex
-	detailPages *tview.Pages
-	detailTabs  *tview.TextView
-	detail      *tview.TextView
-	diffFiles   *counterTable
-	diffView    *tview.TextView
-	footerLeft  *tview.TextView
-	footerRight *tview.TextView
+	// leftRemoved is the main worktree sprout moved to after removing the
+	// worktree it was started in, so the shell can be told to follow.
+	leftRemoved string
+
+	app          *tview.Application
+	pages        *tview.Pages
+	root         *tview.Flex
+	body         *tview.Flex
+	bodyRow      *tview.Flex
+	footer       *tview.Flex
+	banner       *tview.TextView
+	bannerHeight int
+	table        *counterTable
+	statusPane   *tview.TextView
+	detailPane   *tview.Flex
+	detailPages  *tview.Pages
+	detailTabs   *tview.TextView
+	detail       *tview.TextView
+	diffFiles    *counterTable
+	diffView     *tview.TextView
+	footerLeft   *tview.TextView
+	footerRight  *tview.TextView
+	repoSidebar  *tview.Table
 
 	items    []Worktree
 	visible  []int
 	selected int
 	filter   string
-	repos    []repoChoice
+	// filterMatches holds, per index into items, the byte offsets in the
+	// branch name that the filter matched, for highlighting.
+	filterMatches map[int][]int
+	// columns is the worktree table's columns as last rendered.
+	columns []string
+	repos   []repoChoice
 
 	focusables          []tview.Primitive
 	lastDetail          string
 	lastDiff            string
+	diffBase            DiffBase
+	diffRev             string
+	diffPreview         bool
 	detailTab           detailTab
+	detailWrap          map[detailTab]bool
 	diffItems           []DiffFile
 	diffSel             int
 	diffPath            string
-	diffCache           map[string]diffFilesCacheEntry
-	patchCache          map[string]diffPatchCacheEntry
 	agentPrompt         map[string]agentPromptState
 	agentOutputCache    map[string]string
 	agentOutputActivity map[string]int64
@@ -68,6 +98,76 @@ type tuiState struct {
 	forceTableSelect    bool
 	footerLevel         string
 	footerMsg           string
+	todos               map[string][]TodoMarker
+	todoScan            chan todoScanRequest
+	agentWatch          chan todoScanRequest
+	resources           map[string]ResourceUsage
+	showResources       bool
+	sortMode            string
+	detailsPercent      int
+	zoomed              bool
+	// zoomTable is which pane zoom maximizes; it follows the focus between
+	// the Details and Worktrees panes.
+	zoomTable bool
+	// agentFullscreen gives the Details pane the whole terminal, status pane
+	// and banner included, for reading long agent output.
+	agentFullscreen bool
+	// passthrough is the agent pane that keystrokes are forwarded to, or
+	// empty when keys drive the TUI.
+	passthrough string
+	// previewPane is, per worktree path, the tmux pane the AGENT OUTPUT
+	// tab shows instead of the agent's, picked with w.
+	previewPane map[string]tmuxPaneInfo
+	// logItems is the LOG tab's commits of the worktree at logPath. logSel
+	// is the highlighted commit and logOpen the hash whose patch is shown.
+	logItems []LogCommit
+	logSel   int
+	logPath  string
+	logOpen  string
+	// commitPatches caches rendered commit patches by hash and width;
+	// commits do not change, so entries only go with clearDiffCaches.
+	commitPatches map[string]string
+	// conflicts holds, per worktree path, the files that would conflict
+	// when merging the branch into the base branch. Worktrees not checked
+	// yet, or whose check failed, have no entry.
+	conflicts    map[string][]string
+	conflictScan chan todoScanRequest
+	// agentTimers holds, per worktree path, how long its agent has been
+	// busy or ready, as last seen by the agent notifier.
+	agentTimers map[string]agentTimer
+	// agentUsage holds, per worktree path, the agent usage the agent
+	// notifier last sampled.
+	agentUsage map[string]AgentUsage
+	// refreshSeq numbers refreshes so only the latest one's rows are shown;
+	// refreshWaiters are the then functions waiting for it.
+	refreshSeq     int
+	refreshWaiters []func(error)
+	// refreshSpinner, while a refresh runs, stops the goroutine animating
+	// the table counter.
+	refreshSpinner chan struct{}
+	refreshStarted time.Time
+	// detailPollWake makes the live details poller look again now rather
+	// than when its backed-off interval ends. detailPollKey is the worktree
+	// and tab it last looked at.
+	detailPollWake chan struct{}
+	detailPollKey  string
+	// showRepoSidebar shows repoSidebar left of the panes, listing
+	// sidebarRepos: repos by name, so rows stay put when switching.
+	// repoSelection remembers, per repository root, the worktree selected
+	// when the TUI last switched away from it.
+	showRepoSidebar bool
+	sidebarRepos    []repoChoice
+	repoSelection   map[string]string
+	// lastFetch is when the repository was last fetched by sprout, as of
+	// the last refresh; fetching is set while the TUI fetches it.
+	lastFetch time.Time
+	fetching  bool
+}
+
+type todoScanRequest struct {
+	mgr      *Manager
+	repoRoot string
+	items    []Worktree
 }
 
 type paneSize struct {
@@ -80,8 +180,23 @@ type detailTab int
 const (
 	detailTabAgent detailTab = iota
 	detailTabDiff
+	detailTabLog
+	detailTabTodos
+	detailTabSymbols
 )
 
+// onDiffPage reports whether the tab is drawn as a list beside the Patch view
+// rather than in the agent text view.
+func (t detailTab) onDiffPage() bool {
+	return t == detailTabDiff || t == detailTabLog
+}
+
+// wraps reports whether the tab shows agent output or a patch, whose lines w
+// soft-wraps instead of clipping.
+func (t detailTab) wraps() bool {
+	return t == detailTabAgent || t.onDiffPage()
+}
+
 type agentPromptState int
 
 const (
@@ -93,26 +208,33 @@ const (
 var agentPromptOnlyRe = regexp.MustCompile(`^(>|>>|>>>|\$|#|:|›|❯|➜)\s*$`)
 var agentPromptInputRe = regexp.MustCompile(`^(>|>>|>>>|\$|#|:|›|❯|➜)\s+.*$`)
 
-type diffFilesCacheEntry struct {
-	files     []DiffFile
-	fetchedAt time.Time
-}
-
-type diffPatchCacheEntry struct {
-	text      string
-	fetchedAt time.Time
+// diffFilesResult is the files of a worktree that differ from a diff base.
+type diffFilesResult struct {
+	files []DiffFile
+	// rev is the revision the files were compared with ("" for the
+	// working tree preset).
+	rev string
 }
 
 const (
-	detailPollInterval = 150 * time.Millisecond
-	detailCaptureLines = 60
-	diffFilesCacheTTL  = 900 * time.Millisecond
-	diffPatchCacheTTL  = 2 * time.Second
+	todoScanInterval       = 30 * time.Second
+	conflictScanInterval   = time.Minute
+	activitySampleInterval = time.Minute
+	resourceSampleInterval = 3 * time.Second
+	refreshSpinnerInterval = 120 * time.Millisecond
+	// autoFetchCheckInterval is how often the TUI checks whether
+	// auto_fetch_minutes have passed since the repository was last fetched.
+	autoFetchCheckInterval = time.Minute
 )
 
+var refreshSpinnerFrames = []rune("⠋⠙⠹⠸⠼⠴⠦⠧⠇⠏")
+
 type counterTable struct {
 	*tview.Table
 	counter string
+	// onResize, when set, runs before the table is drawn at a new width.
+	onResize  func()
+	lastWidth int
 }
 
 func newCounterTable() *counterTable {
@@ -124,6 +246,12 @@ func (c *counterTable) SetCounter(value string) {
 }
 
 func (c *counterTable) Draw(screen tcell.Screen) {
+	if c.onResize != nil {
+		if _, _, w, _ := c.GetInnerRect(); w != c.lastWidth {
+			c.lastWidth = w
+			c.onResize()
+		}
+	}
 	c.Table.Draw(screen)
 	if c.counter == "" {
 		return
@@ -161,16 +289,30 @@ func paneFocusColor() tcell.Color {
 	return ColorToTcell(ThemeColorSecondary)
 }
 
+// ansiColor returns a color of the terminal's own palette, which already
+// suits its background, or the default color when colors are disabled.
 func ansiColor(code int) tcell.Color {
+	if !colorEnabled {
+		return tcell.ColorDefault
+	}
 	return tcell.PaletteColor(code)
 }
 
+// translateANSI converts ANSI escapes to tview color tags, or drops them
+// when colors are disabled.
+func translateANSI(text string) string {
+	if !colorEnabled {
+		return tview.Escape(stripANSI(text))
+	}
+	return tview.TranslateANSI(text)
+}
+
 func paletteLevelColor(level string) tcell.Color {
 	switch strings.ToUpper(strings.TrimSpace(level)) {
 	case "ERROR":
-		return tcell.ColorRed
+		return ColorToTcell(ColorRed)
 	case "WARN":
-		return tcell.ColorYellow
+		return ColorToTcell(ColorYellow)
 	case "INFO":
 		return ColorToTcell(ThemeColorSecondary)
 	default:
@@ -189,7 +331,7 @@ func applyTheme() {
 	tview.Styles.SecondaryTextColor = ColorToTcell(ThemeColorSecondary)
 	tview.Styles.TertiaryTextColor = ColorToTcell(ThemeColorMuted)
 	tview.Styles.InverseTextColor = tcell.ColorDefault
-	tview.Styles.ContrastSecondaryTextColor = tcell.ColorRed
+	tview.Styles.ContrastSecondaryTextColor = ColorToTcell(ColorRed)
 
 	tview.Borders.HorizontalFocus = tview.Borders.Horizontal
 	tview.Borders.VerticalFocus = tview.Borders.Vertical
@@ -210,18 +352,39 @@ func RunUI(mgr *Manager) int {
 		return 1
 	}
 
+	defer flushPerf()
 	u := newTUI(mgr, repoRoot)
-	if err := u.refresh(); err != nil {
-		u.setError("refresh failed: %v", err)
-	}
+	u.refresh(nil)
 	u.startUpdateCheck()
-	stopLive := u.startLiveDetailUpdates(detailPollInterval)
+	u.startHealthCheck()
+	u.startResumeCheck()
+	stopLive := u.startLiveDetailUpdates(
+		time.Duration(mgr.Cfg.DetailPollMS)*time.Millisecond,
+		time.Duration(mgr.Cfg.DetailIdlePollMS)*time.Millisecond,
+	)
 	defer stopLive()
+	stopTodoScan := u.startTodoScanner(todoScanInterval)
+	defer stopTodoScan()
+	stopConflictScan := u.startConflictScanner(conflictScanInterval)
+	defer stopConflictScan()
+	stopActivity := u.startActivitySampler(activitySampleInterval)
+	defer stopActivity()
+	stopResources := u.startResourceSampler(resourceSampleInterval)
+	defer stopResources()
+	stopNotifier := u.startAgentNotifier(notifyPollInterval)
+	defer stopNotifier()
+	// It runs even with auto_fetch_minutes unset, which another
+	// repository's config may set.
+	stopAutoFetch := u.startAutoFetch(autoFetchCheckInterval)
+	defer stopAutoFetch()
 
 	if err := u.app.SetRoot(u.pages, true).Run(); err != nil {
 		fmt.Printf("error: ui failed: %v\n", err)
 		return 1
 	}
+	if u.leftRemoved != "" {
+		fmt.Fprintln(os.Stderr, WarnMsg(fmt.Sprintf("the worktree sprout started in was removed; cd %s", u.leftRemoved)))
+	}
 	return 0
 }
 
@@ -330,17 +493,32 @@ func newTUI(mgr *Manager, repoRoot string) *tuiState {
 
 	body := tview.NewFlex().
 		SetDirection(tview.FlexRow).
-		AddItem(detailPane, 0, 3, false).
-		AddItem(table, 0, 2, true)
+		AddItem(detailPane, 0, mgr.Cfg.DetailsPercent, false).
+		AddItem(table, 0, 100-mgr.Cfg.DetailsPercent, true)
 
 	footer := tview.NewFlex().
 		AddItem(footerLeft, 0, 1, false).
 		AddItem(footerRight, 14, 0, false)
 
+	repoSidebar := tview.NewTable().
+		SetSelectable(true, false).
+		SetBorders(false)
+	repoSidebar.SetSeparator(' ')
+	repoSidebar.SetBackgroundColor(tcell.ColorDefault)
+	repoSidebar.SetSelectedStyle(tcell.StyleDefault.Foreground(tcell.ColorDefault).Background(tcell.ColorDefault).Reverse(true))
+	repoSidebar.
+		SetBorder(true).
+		SetBorderColor(paneBorderColor()).
+		SetTitle("[0]-Repos").
+		SetTitleColor(paneBorderColor())
+
+	// bodyRow holds the body and, when shown, the repository sidebar.
+	bodyRow := tview.NewFlex()
+
 	root := tview.NewFlex().
 		SetDirection(tview.FlexRow).
 		AddItem(statusPane, 3, 0, false).
-		AddItem(body, 0, 1, true).
+		AddItem(bodyRow, 0, 1, true).
 		AddItem(footer, 1, 0, false)
 
 	pages := tview.NewPages().AddPage("main", root, true, true)
@@ -351,6 +529,13 @@ func newTUI(mgr *Manager, repoRoot string) *tuiState {
 		repoRoot:            repoRoot,
 		app:                 tview.NewApplication().EnableMouse(true),
 		pages:               pages,
+		root:                root,
+		body:                body,
+		bodyRow:             bodyRow,
+		repoSidebar:         repoSidebar,
+		showRepoSidebar:     mgr.Cfg.RepoSidebar,
+		repoSelection:       map[string]string{},
+		footer:              footer,
 		table:               table,
 		statusPane:          statusPane,
 		detailPane:          detailPane,
@@ -362,17 +547,36 @@ func newTUI(mgr *Manager, repoRoot string) *tuiState {
 		footerLeft:          footerLeft,
 		footerRight:         footerRight,
 		detailTab:           detailTabAgent,
+		detailWrap:          map[detailTab]bool{},
 		diffSel:             0,
-		diffCache:           map[string]diffFilesCacheEntry{},
-		patchCache:          map[string]diffPatchCacheEntry{},
 		agentPrompt:         map[string]agentPromptState{},
+		agentUsage:          map[string]AgentUsage{},
 		agentOutputCache:    map[string]string{},
 		agentOutputActivity: map[string]int64{},
+		previewPane:         map[string]tmuxPaneInfo{},
+		commitPatches:       map[string]string{},
+		conflicts:           map[string][]string{},
+		conflictScan:        make(chan todoScanRequest, 1),
 		paneSizes:           map[string]paneSize{},
 		paneActivity:        map[string]int64{},
 		panePromptActivity:  map[string]int64{},
+		todos:               map[string][]TodoMarker{},
+		todoScan:            make(chan todoScanRequest, 1),
+		agentWatch:          make(chan todoScanRequest, 1),
+		detailPollWake:      make(chan struct{}, 1),
+		resources:           map[string]ResourceUsage{},
+		showResources:       mgr.Cfg.ShowResources,
+		sortMode:            mgr.Cfg.Sort,
+		detailsPercent:      mgr.Cfg.DetailsPercent,
+		diffBase:            DiffBase{Kind: diffBaseWorking},
+		filter:              savedFilter(repoRoot),
+	}
+	u.layoutBodyRow()
+	// The path column takes the width the other columns leave. Draw runs
+	// with the application locked, so re-render on the event loop.
+	table.onResize = func() {
+		go u.app.QueueUpdateDraw(u.renderTable)
 	}
-	u.focusables = []tview.Primitive{u.statusPane, u.detailPane, u.table}
 
 	table.SetSelectionChangedFunc(func(row, _ int) {
 		if u.app.GetFocus() != u.table && !u.forceTableSelect {
@@ -386,6 +590,7 @@ func newTUI(mgr *Manager, repoRoot string) *tuiState {
 		u.renderTableMeta()
 		u.renderStatusPane()
 		u.renderDetails()
+		u.wakeDetailPoll()
 	})
 	table.SetSelectedFunc(func(row, _ int) {
 		if row > 0 {
@@ -393,6 +598,7 @@ func newTUI(mgr *Manager, repoRoot string) *tuiState {
 		}
 	})
 	u.app.SetInputCapture(u.handleKey)
+	u.app.SetMouseCapture(u.handleMouse)
 
 	u.footerRight.SetText(fmt.Sprintf("v%s", Version))
 	u.refreshRepoChoices()
@@ -407,9 +613,18 @@ func (u *tuiState) handleKey(ev *tcell.EventKey) *tcell.EventKey {
 	focus := u.app.GetFocus()
 	inDetail := focus == u.detailPane || focus == u.detail || focus == u.diffFiles || focus == u.diffView
 
+	if mainFocus && u.passthrough != "" {
+		return u.handlePassthroughKey(ev)
+	}
+	if mainFocus && u.handleLayoutKey(ev) {
+		return nil
+	}
 	if mainFocus && inDetail {
 		return u.handleDetailBrowseKey(ev)
 	}
+	if mainFocus && focus == u.repoSidebar && u.handleRepoSidebarKey(ev) {
+		return nil
+	}
 
 	switch ev.Key() {
 	case tcell.KeyCtrlC:
@@ -427,6 +642,11 @@ func (u *tuiState) handleKey(ev *tcell.EventKey) *tcell.EventKey {
 			u.goCurrent()
 			return nil
 		}
+	case tcell.KeyEscape:
+		if mainFocus && u.banner != nil {
+			u.setBanner(nil)
+			return nil
+		}
 	case tcell.KeyTAB:
 		if mainFocus {
 			u.cycleFocus(1)
@@ -453,7 +673,7 @@ func (u *tuiState) handleKey(ev *tcell.EventKey) *tcell.EventKey {
 		}
 		switch ev.Rune() {
 		case 'q':
-			u.app.Stop()
+			u.quit()
 			return nil
 		case '[':
 			u.cycleDetailTab(-1)
@@ -468,22 +688,107 @@ func (u *tuiState) handleKey(ev *tcell.EventKey) *tcell.EventKey {
 			u.moveSelection(-1)
 			return nil
 		case 'r':
-			if err := u.refresh(); err != nil {
-				u.setError("refresh failed: %v", err)
-			}
+			u.mgr.InvalidateQueries("")
+			u.refresh(nil)
 			return nil
 		case 'n':
-			u.showCreateModal()
+			u.newWorktreeWithinWIPLimit()
 			return nil
 		case 'x':
 			u.showDeleteModal()
 			return nil
+		case 'u':
+			u.undoRemove()
+			return nil
+		case '1', '2', '3', '4', '5', '6', '7', '8', '9':
+			if u.app.GetFocus() == u.table {
+				u.goWindowCurrent(int(ev.Rune() - '0'))
+				return nil
+			}
 		case 'd':
 			u.showDetachModal()
 			return nil
 		case '/':
 			u.showFilterModal()
 			return nil
+		case 'f':
+			u.cycleFilterPreset()
+			return nil
+		case 'p':
+			u.showPromptModal()
+			return nil
+		case 'm':
+			u.showRenameModal()
+			return nil
+		case 'b':
+			u.rebaseCurrent()
+			return nil
+		case 'M':
+			u.mergeCurrent()
+			return nil
+		case 'l':
+			u.toggleLockCurrent()
+			return nil
+		case 'L':
+			u.showLogsModal()
+			return nil
+		case 'P':
+			u.cyclePriorityCurrent()
+			return nil
+		case 'R':
+			u.toggleResources()
+			return nil
+		case 'S':
+			u.toggleRepoSidebar()
+			return nil
+		case 'A':
+			u.resumeAgents()
+			return nil
+		case 'F':
+			u.fetchNow()
+			return nil
+		case 'o':
+			u.cycleSort()
+			return nil
+		case 'w':
+			if u.detailTab.wraps() {
+				u.toggleDetailWrap()
+				return nil
+			}
+		case 'W':
+			if u.detailTab == detailTabAgent {
+				u.cyclePreviewPane()
+				return nil
+			}
+		case 'y':
+			u.yankPath()
+			return nil
+		case 'Y':
+			u.yankBranch()
+			return nil
+		case 'e':
+			u.exportCurrent(false)
+			return nil
+		case 'E':
+			if u.detailTab == detailTabDiff {
+				u.exportCurrent(true)
+				return nil
+			}
+		case 'v':
+			if u.detailTab == detailTabDiff {
+				u.toggleDiffPreview()
+				return nil
+			}
+		case 'X':
+			if u.detailTab == detailTabDiff {
+				u.showDiscardFileModal()
+				return nil
+			}
+		case 's':
+			if u.app.GetFocus() == u.statusPane {
+				u.showSessionsModal()
+				return nil
+			}
 		case '?':
 			u.showHelpModal()
 			return nil
@@ -492,9 +797,341 @@ func (u *tuiState) handleKey(ev *tcell.EventKey) *tcell.EventKey {
 	return ev
 }
 
+// handleMouse routes clicks and wheel events on the main screen. tview would
+// focus the *tview.Table embedded in a counterTable, which the focus checks
+// do not recognise, so clicks on the panes are handled here instead.
+func (u *tuiState) handleMouse(ev *tcell.EventMouse, action tview.MouseAction) (*tcell.EventMouse, tview.MouseAction) {
+	if ev == nil {
+		return ev, action
+	}
+	if name, _ := u.pages.GetFrontPage(); name != "main" {
+		return ev, action
+	}
+	x, y := ev.Position()
+	inDiff := u.detailTab.onDiffPage()
+	switch action {
+	case tview.MouseLeftDown:
+		// Focus moves on click, below, so the pane styles follow it.
+		return nil, action
+	case tview.MouseLeftClick, tview.MouseLeftDoubleClick:
+		switch {
+		case u.table.InRect(x, y):
+			u.focusPane(u.table)
+			row, _ := u.table.CellAt(x, y)
+			if row > 0 && row <= len(u.visible) {
+				u.selectTableRow(row, true)
+				if action == tview.MouseLeftDoubleClick {
+					u.goCurrent()
+				}
+			}
+			return nil, action
+		case inDiff && u.diffFiles.InRect(x, y):
+			u.focusPane(u.diffFiles)
+			row, _ := u.diffFiles.CellAt(x, y)
+			if row <= 0 {
+				return nil, action
+			}
+			if u.detailTab == detailTabLog {
+				u.selectLogCommit(row - 1)
+				if action == tview.MouseLeftDoubleClick {
+					u.openLogCommit()
+				}
+				return nil, action
+			}
+			u.selectDiffFile(row - 1)
+			return nil, action
+		case u.detailTabs.InRect(x, y):
+			tx, _, _, _ := u.detailTabs.GetInnerRect()
+			if tab, ok := detailTabAt(x - tx); ok {
+				u.setDetailTab(tab)
+			}
+			return nil, action
+		case inDiff && u.diffView.InRect(x, y):
+			u.focusPane(u.diffView)
+			return nil, action
+		case !inDiff && u.detail.InRect(x, y):
+			u.focusPane(u.detail)
+			return nil, action
+		case u.statusPane.InRect(x, y):
+			u.focusPane(u.statusPane)
+			return nil, action
+		case u.showRepoSidebar && u.repoSidebar.InRect(x, y):
+			u.focusPane(u.repoSidebar)
+			row, _ := u.repoSidebar.CellAt(x, y)
+			if row >= 0 && row < len(u.sidebarRepos) {
+				u.repoSidebar.Select(row, 0)
+				if action == tview.MouseLeftDoubleClick {
+					u.switchRepo(u.sidebarRepos[row])
+				}
+			}
+			return nil, action
+		}
+	case tview.MouseScrollUp, tview.MouseScrollDown:
+		delta := 1
+		if action == tview.MouseScrollUp {
+			delta = -1
+		}
+		switch {
+		case u.table.InRect(x, y):
+			u.focusPane(u.table)
+			u.moveSelection(delta)
+			return nil, action
+		case u.showRepoSidebar && u.repoSidebar.InRect(x, y):
+			u.focusPane(u.repoSidebar)
+			u.moveRepoSidebar(delta)
+			return nil, action
+		case inDiff && u.diffFiles.InRect(x, y):
+			u.focusPane(u.diffFiles)
+			if u.detailTab == detailTabLog {
+				u.moveLogSelection(delta)
+			} else {
+				u.moveDiffSelection(delta)
+			}
+			return nil, action
+		case inDiff && u.diffView.InRect(x, y):
+			// Focusing the view keeps the wheel position across re-renders.
+			u.focusPane(u.diffView)
+		case !inDiff && u.detail.InRect(x, y):
+			// A focused agent view stops following new output.
+			u.focusPane(u.detail)
+		}
+	}
+	return ev, action
+}
+
+// handleLayoutKey resizes and zooms the Details and Worktrees panes. It runs
+// before the per-pane handlers so the keys work whichever pane has focus.
+func (u *tuiState) handleLayoutKey(ev *tcell.EventKey) bool {
+	switch {
+	case ev.Key() == tcell.KeyUp && ev.Modifiers()&tcell.ModCtrl != 0:
+		u.resizeDetails(-detailsPercentStep)
+	case ev.Key() == tcell.KeyDown && ev.Modifiers()&tcell.ModCtrl != 0:
+		u.resizeDetails(detailsPercentStep)
+	case ev.Key() == tcell.KeyRune && ev.Rune() == 'z':
+		if u.agentFullscreen || (!u.zoomTable && u.app.GetFocus() != u.statusPane && u.detailTab == detailTabAgent) {
+			u.toggleAgentFullscreen()
+			return true
+		}
+		u.toggleZoom()
+	case ev.Key() == tcell.KeyEscape && u.agentFullscreen:
+		u.toggleAgentFullscreen()
+	default:
+		return false
+	}
+	return true
+}
+
+// detailsPercentStep is how far one ctrl+up/down moves the pane split.
+const detailsPercentStep = 5
+
+// layoutBody sizes the Details and Worktrees panes from the split ratio, or
+// gives the whole body to one of them while zoomed.
+func (u *tuiState) layoutBody() {
+	details, table := u.detailsPercent, 100-u.detailsPercent
+	if u.agentFullscreen {
+		details, table = 1, 0
+	} else if u.zoomed {
+		details, table = 1, 0
+		if u.zoomTable {
+			details, table = 0, 1
+		}
+	}
+	u.body.ResizeItem(u.detailPane, 0, details)
+	u.body.ResizeItem(u.table, 0, table)
+}
+
+// resizeDetails moves the split between the Details and Worktrees panes by
+// delta percent and saves the new ratio as details_percent.
+func (u *tuiState) resizeDetails(delta int) {
+	n := u.detailsPercent + delta
+	n = max(minDetailsPercent, min(maxDetailsPercent, n))
+	if u.zoomed {
+		u.zoomed = false
+	} else if n == u.detailsPercent {
+		u.setInfo("details pane already at %d%%", n)
+		return
+	}
+	u.detailsPercent = n
+	u.layoutBody()
+	if err := saveGlobalConfigValue("details_percent", strconv.Itoa(n)); err != nil {
+		errorLogf("ui save details_percent failed: %v", err)
+		u.setWarn("details pane %d%% (not saved: %v)", n, err)
+		return
+	}
+	u.setInfo("details pane %d%%", n)
+}
+
+// toggleZoom maximizes the focused pane, or restores the split.
+func (u *tuiState) toggleZoom() {
+	u.zoomed = !u.zoomed
+	u.layoutBody()
+	switch {
+	case !u.zoomed:
+		u.setInfo("pane split restored")
+	case u.zoomTable:
+		u.setInfo("worktrees pane zoomed (z to restore)")
+	default:
+		u.setInfo("details pane zoomed (z to restore)")
+	}
+}
+
+// toggleAgentFullscreen switches the agent output between its pane and the
+// whole terminal. The agent's tmux pane follows the new size on the next
+// render, so its output wraps at the width it is shown at.
+func (u *tuiState) toggleAgentFullscreen() {
+	u.agentFullscreen = !u.agentFullscreen
+	u.layoutBody()
+	u.layoutRoot()
+	if u.agentFullscreen {
+		u.setInfo("agent output fullscreen (z or esc to restore)")
+	} else {
+		u.setInfo("agent output restored")
+	}
+	// The new pane size is only known once the layout has been drawn.
+	go u.app.QueueUpdateDraw(func() {
+		if u.detailTab == detailTabAgent {
+			u.renderDetails()
+		}
+	})
+}
+
+// startAgentPassthrough forwards keystrokes to the selected worktree's agent
+// pane until ctrl+] is pressed, so the agent can be used without attaching.
+func (u *tuiState) startAgentPassthrough() {
+	item := u.selectedItem()
+	if item == nil {
+		u.setWarn("nothing selected")
+		return
+	}
+	if pane, ok := u.previewPane[item.Path]; ok {
+		u.app.SetFocus(u.detailPane)
+		u.passthrough = pane.PaneID
+		u.wakeDetailPoll()
+		u.updatePaneFocusStyles()
+		u.detail.ScrollToEnd()
+		u.setInfo("typing to %s in %s (ctrl+] to stop)", previewPaneLabel(pane), worktreeBranchOrName(item))
+		return
+	}
+	if item.AgentState != "yes" {
+		u.setWarn("agent is not running for this worktree")
+		return
+	}
+	target := u.mgr.agentPaneTarget(u.repoRoot, item)
+	if target == "" {
+		u.setWarn("agent pane not found")
+		return
+	}
+	// Focusing the pane rather than the output view keeps it following
+	// new output.
+	u.app.SetFocus(u.detailPane)
+	u.passthrough = target
+	u.wakeDetailPoll()
+	u.updatePaneFocusStyles()
+	u.detail.ScrollToEnd()
+	u.setInfo("typing to %s's agent (ctrl+] to stop)", worktreeBranchOrName(item))
+}
+
+func (u *tuiState) stopAgentPassthrough() {
+	u.passthrough = ""
+	u.updatePaneFocusStyles()
+	u.setInfo("stopped typing to the agent")
+}
+
+func (u *tuiState) handlePassthroughKey(ev *tcell.EventKey) *tcell.EventKey {
+	if ev.Key() == tcell.KeyCtrlRightSq {
+		u.stopAgentPassthrough()
+		return nil
+	}
+	key, literal, ok := tmuxKeyForEvent(ev)
+	if !ok {
+		return nil
+	}
+	args := []string{key}
+	if literal {
+		// A lone ";" would end the tmux command.
+		if key == ";" {
+			key = "\\;"
+		}
+		args = []string{"-l", "--", key}
+	}
+	if err := u.mgr.tmuxSendPaneKeys(u.passthrough, args...); err != nil {
+		errorLogf("ui passthrough send failed pane=%q: %v", u.passthrough, err)
+		u.passthrough = ""
+		u.updatePaneFocusStyles()
+		u.setError("sending keys to the agent failed: %v", err)
+	}
+	return nil
+}
+
+// tmuxNamedKeys maps tcell keys to tmux send-keys key names.
+var tmuxNamedKeys = map[tcell.Key]string{
+	tcell.KeyEnter:      "Enter",
+	tcell.KeyTab:        "Tab",
+	tcell.KeyBacktab:    "BTab",
+	tcell.KeyBackspace:  "BSpace",
+	tcell.KeyBackspace2: "BSpace",
+	tcell.KeyEscape:     "Escape",
+	tcell.KeyUp:         "Up",
+	tcell.KeyDown:       "Down",
+	tcell.KeyLeft:       "Left",
+	tcell.KeyRight:      "Right",
+	tcell.KeyHome:       "Home",
+	tcell.KeyEnd:        "End",
+	tcell.KeyPgUp:       "PPage",
+	tcell.KeyPgDn:       "NPage",
+	tcell.KeyDelete:     "DC",
+	tcell.KeyInsert:     "IC",
+}
+
+// tmuxKeyForEvent translates a key event into a tmux send-keys argument.
+// literal reports that key is text to send with send-keys -l.
+func tmuxKeyForEvent(ev *tcell.EventKey) (key string, literal, ok bool) {
+	mods := ev.Modifiers()
+	if ev.Key() == tcell.KeyRune {
+		if mods&tcell.ModAlt != 0 {
+			return "M-" + string(ev.Rune()), false, true
+		}
+		return string(ev.Rune()), true, true
+	}
+	if name, found := tmuxNamedKeys[ev.Key()]; found {
+		switch ev.Key() {
+		case tcell.KeyUp, tcell.KeyDown, tcell.KeyLeft, tcell.KeyRight, tcell.KeyHome, tcell.KeyEnd:
+			if mods&tcell.ModShift != 0 {
+				name = "S-" + name
+			}
+			if mods&tcell.ModAlt != 0 {
+				name = "M-" + name
+			}
+			if mods&tcell.ModCtrl != 0 {
+				name = "C-" + name
+			}
+		}
+		return name, false, true
+	}
+	if ev.Key() >= tcell.KeyF1 && ev.Key() <= tcell.KeyF12 {
+		return fmt.Sprintf("F%d", ev.Key()-tcell.KeyF1+1), false, true
+	}
+	if ev.Key() >= tcell.KeyCtrlA && ev.Key() <= tcell.KeyCtrlZ {
+		return "C-" + string(rune('a'+ev.Key()-tcell.KeyCtrlA)), false, true
+	}
+	return "", false, false
+}
+
+// focusPane moves focus to p and restyles the panes.
+func (u *tuiState) focusPane(p tview.Primitive) {
+	if u.app.GetFocus() == p {
+		return
+	}
+	u.app.SetFocus(p)
+	u.updatePaneFocusStyles()
+}
+
 func (u *tuiState) handleDetailBrowseKey(ev *tcell.EventKey) *tcell.EventKey {
-	if u.detailTab == detailTabDiff {
+	switch u.detailTab {
+	case detailTabDiff:
 		return u.handleDiffBrowseKey(ev)
+	case detailTabLog:
+		return u.handleLogBrowseKey(ev)
 	}
 
 	switch ev.Key() {
@@ -543,6 +1180,16 @@ func (u *tuiState) handleDetailBrowseKey(ev *tcell.EventKey) *tcell.EventKey {
 			u.detail.ScrollToBeginning()
 		case 'G':
 			u.detail.ScrollToEnd()
+		case 'a':
+			u.startAgentPassthrough()
+		case 'e':
+			u.exportCurrent(false)
+		case 'R':
+			u.showRestartAgentModal()
+		case 'w':
+			u.toggleDetailWrap()
+		case 'W':
+			u.cyclePreviewPane()
 		case 'h', '[':
 			u.cycleDetailTab(-1)
 		case 'l', ']':
@@ -610,6 +1257,88 @@ func (u *tuiState) handleDiffBrowseKey(ev *tcell.EventKey) *tcell.EventKey {
 			u.selectDiffFile(0)
 		case 'G':
 			u.selectDiffFile(len(u.diffItems) - 1)
+		case 'b':
+			u.showDiffBaseModal()
+		case 'y':
+			u.yankPatch()
+		case 'e':
+			u.exportCurrent(false)
+		case 'E':
+			u.exportCurrent(true)
+		case 'c':
+			u.pickSelectedFile()
+		case 'v':
+			u.toggleDiffPreview()
+		case 'X':
+			u.showDiscardFileModal()
+		case 'w':
+			u.toggleDetailWrap()
+		case 'h', '[':
+			u.cycleDetailTab(-1)
+		case 'l', ']':
+			u.cycleDetailTab(1)
+		}
+		return nil
+	}
+	return ev
+}
+
+func (u *tuiState) handleLogBrowseKey(ev *tcell.EventKey) *tcell.EventKey {
+	switch ev.Key() {
+	case tcell.KeyCtrlC:
+		u.app.Stop()
+		return nil
+	case tcell.KeyTAB:
+		u.cycleFocus(1)
+		return nil
+	case tcell.KeyBacktab:
+		u.cycleFocus(-1)
+		return nil
+	case tcell.KeyEnter:
+		u.openLogCommit()
+		return nil
+	case tcell.KeyCtrlU, tcell.KeyPgUp:
+		u.scrollTextView(u.diffView, -10)
+		return nil
+	case tcell.KeyCtrlD, tcell.KeyPgDn:
+		u.scrollTextView(u.diffView, 10)
+		return nil
+	case tcell.KeyUp:
+		u.moveLogSelection(-1)
+		return nil
+	case tcell.KeyDown:
+		u.moveLogSelection(1)
+		return nil
+	case tcell.KeyHome:
+		u.selectLogCommit(0)
+		return nil
+	case tcell.KeyEnd:
+		u.selectLogCommit(len(u.logItems) - 1)
+		return nil
+	case tcell.KeyLeft:
+		u.cycleDetailTab(-1)
+		return nil
+	case tcell.KeyRight:
+		u.cycleDetailTab(1)
+		return nil
+	case tcell.KeyRune:
+		switch ev.Rune() {
+		case 'j':
+			u.moveLogSelection(1)
+		case 'k':
+			u.moveLogSelection(-1)
+		case 'J':
+			u.scrollTextView(u.diffView, 10)
+		case 'K':
+			u.scrollTextView(u.diffView, -10)
+		case 'g':
+			u.selectLogCommit(0)
+		case 'G':
+			u.selectLogCommit(len(u.logItems) - 1)
+		case 'c':
+			u.pickSelectedCommit()
+		case 'w':
+			u.toggleDetailWrap()
 		case 'h', '[':
 			u.cycleDetailTab(-1)
 		case 'l', ']':
@@ -655,7 +1384,7 @@ func (u *tuiState) cycleFocus(delta int) {
 }
 
 func (u *tuiState) cycleDetailTab(delta int) {
-	tabs := []detailTab{detailTabAgent, detailTabDiff}
+	tabs := []detailTab{detailTabAgent, detailTabDiff, detailTabLog, detailTabTodos, detailTabSymbols}
 	idx := 0
 	for i, tab := range tabs {
 		if u.detailTab == tab {
@@ -675,11 +1404,15 @@ func (u *tuiState) setDetailTab(tab detailTab) {
 		return
 	}
 	u.detailTab = tab
-	if tab == detailTabAgent {
+	if !tab.onDiffPage() {
 		u.detailPages.ShowPage("agent")
 		u.detailPages.HidePage("diff")
 		u.lastDetail = ""
-		u.detail.ScrollToEnd()
+		if tab == detailTabAgent {
+			u.detail.ScrollToEnd()
+		} else {
+			u.detail.ScrollToBeginning()
+		}
 		if u.app.GetFocus() == u.diffFiles || u.app.GetFocus() == u.diffView {
 			u.app.SetFocus(u.detail)
 		}
@@ -688,6 +1421,11 @@ func (u *tuiState) setDetailTab(tab detailTab) {
 		u.detailPages.HidePage("agent")
 		u.lastDiff = ""
 		u.diffView.ScrollToBeginning()
+		if tab == detailTabLog {
+			u.diffFiles.SetTitle("Commits")
+		} else {
+			u.diffFiles.SetTitle("Files")
+		}
 		if u.app.GetFocus() == u.detail {
 			u.app.SetFocus(u.diffFiles)
 		}
@@ -700,6 +1438,23 @@ func (u *tuiState) setDetailTab(tab detailTab) {
 
 func (u *tuiState) updatePaneFocusStyles() {
 	focus := u.app.GetFocus()
+	switch focus {
+	case u.table:
+		u.zoomTable = true
+	case u.detailPane, u.detail, u.diffFiles, u.diffView:
+		u.zoomTable = false
+	}
+	if u.passthrough != "" && focus != u.detailPane {
+		u.passthrough = ""
+	}
+	if u.agentFullscreen && (focus == u.table || focus == u.statusPane || focus == u.repoSidebar) {
+		// Moving to a hidden pane leaves fullscreen.
+		u.agentFullscreen = false
+		u.layoutRoot()
+		u.layoutBody()
+	} else if u.zoomed {
+		u.layoutBody()
+	}
 	stylePane := func(active bool, setTitle func(string), setBorderColor func(tcell.Color), setTitleColor func(tcell.Color), baseTitle string) {
 		if active {
 			setTitle("> " + baseTitle)
@@ -726,6 +1481,13 @@ func (u *tuiState) updatePaneFocusStyles() {
 		func(c tcell.Color) { u.table.SetTitleColor(c) },
 		"[3]-Worktrees",
 	)
+	stylePane(
+		focus == u.repoSidebar,
+		func(s string) { u.repoSidebar.SetTitle(s) },
+		func(c tcell.Color) { u.repoSidebar.SetBorderColor(c) },
+		func(c tcell.Color) { u.repoSidebar.SetTitleColor(c) },
+		"[0]-Repos",
+	)
 	stylePane(
 		focus == u.detailPane || focus == u.detail || focus == u.diffFiles || focus == u.diffView,
 		func(s string) { u.detailPane.SetTitle(s) },
@@ -796,19 +1558,76 @@ func (u *tuiState) selectDiffFile(idx int) {
 	u.renderSelectedFileDiff()
 }
 
+func (u *tuiState) moveLogSelection(delta int) {
+	u.selectLogCommit(u.logSel + delta)
+}
+
+func (u *tuiState) selectLogCommit(idx int) {
+	if len(u.logItems) == 0 {
+		return
+	}
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= len(u.logItems) {
+		idx = len(u.logItems) - 1
+	}
+	if idx == u.logSel {
+		return
+	}
+	u.logSel = idx
+	u.renderLogList()
+}
+
+// openLogCommit shows the highlighted commit's patch in the patch view.
+func (u *tuiState) openLogCommit() {
+	if u.logSel < 0 || u.logSel >= len(u.logItems) {
+		return
+	}
+	u.logOpen = u.logItems[u.logSel].Hash
+	u.renderLogList()
+	u.renderOpenCommit()
+}
+
+// applyFilter fuzzy-matches the filter against branch names, best match
+// first. A worktree whose branch does not match is still shown, last, when
+// its path contains the query. kind:value terms, such as label:experiment or
+// agent:ready, keep only the worktrees that satisfy them. Without a query,
+// worktrees are in the sort order.
 func (u *tuiState) applyFilter() {
 	u.visible = u.visible[:0]
-	q := strings.ToLower(strings.TrimSpace(u.filter))
+	u.filterMatches = map[int][]int{}
+	filter := parseWorktreeFilter(u.filter)
+	q := strings.ToLower(strings.TrimSpace(filter.Query))
+	scores := map[int]int{}
 	for i, item := range u.items {
+		if !filter.matches(item, u.agentTimers[item.Path].Status) {
+			continue
+		}
 		if q == "" {
 			u.visible = append(u.visible, i)
 			continue
 		}
-		hay := strings.ToLower(item.Branch + " " + item.Path)
-		if strings.Contains(hay, q) {
+		if score, positions, ok := fuzzyMatch(q, item.Branch); ok {
+			scores[i] = score
+			u.filterMatches[i] = positions
+			u.visible = append(u.visible, i)
+			continue
+		}
+		if strings.Contains(strings.ToLower(item.Path), q) {
+			scores[i] = math.MinInt
 			u.visible = append(u.visible, i)
 		}
 	}
+	if q != "" {
+		sort.SliceStable(u.visible, func(a, b int) bool {
+			return scores[u.visible[a]] > scores[u.visible[b]]
+		})
+	} else {
+		sort.SliceStable(u.visible, func(a, b int) bool {
+			return worktreeLess(&u.items[u.visible[a]], &u.items[u.visible[b]], u.sortMode)
+		})
+	}
 	if u.selected >= len(u.visible) {
 		u.selected = len(u.visible) - 1
 	}
@@ -817,14 +1636,99 @@ func (u *tuiState) applyFilter() {
 	}
 }
 
-func (u *tuiState) refresh() error {
-	u.refreshRepoChoices()
-	items, err := u.mgr.ListWorktrees()
-	if err != nil {
-		return err
+// refresh reloads the worktrees off the UI goroutine, since listing them
+// runs git and tmux for each one. Until the new rows are in, the table keeps
+// showing the ones it has, with a spinner in its counter. then, when not
+// nil, runs on the UI goroutine once the rows are drawn, with the error if
+// loading failed; without it, a failure shows in the footer. A refresh
+// started while another runs supersedes it, and the then functions of both
+// run when it is done.
+func (u *tuiState) refresh(then func(error)) {
+	u.refreshSeq++
+	seq := u.refreshSeq
+	if then != nil {
+		u.refreshWaiters = append(u.refreshWaiters, then)
+	}
+	u.startRefreshSpinner()
+	u.renderTableMeta()
+
+	mgr := u.mgr
+	repoRoot := u.repoRoot
+	countRepos := u.showRepoSidebar
+	go func() {
+		done := timeOperation("tui_refresh")
+		repos := loadRepoChoices(repoRoot)
+		fetched := lastFetch(mgr.mainRepoRoot(repoRoot))
+		if countRepos {
+			for i := range repos {
+				if repos[i].Root != repoRoot {
+					repos[i].Worktrees, repos[i].Ready = mgr.repoAgentCounts(repos[i].Root)
+				}
+			}
+		}
+		items, err := mgr.ListWorktrees(context.Background())
+		done()
+		u.app.QueueUpdateDraw(func() {
+			if seq != u.refreshSeq {
+				return
+			}
+			u.stopRefreshSpinner()
+			waiters := u.refreshWaiters
+			u.refreshWaiters = nil
+			if err != nil {
+				u.renderTableMeta()
+				if len(waiters) == 0 {
+					u.setError("refresh failed: %v", err)
+				}
+			} else {
+				u.lastFetch = fetched
+				u.setRepoChoices(repos)
+				u.applyRefresh(items)
+			}
+			for _, then := range waiters {
+				then(err)
+			}
+		})
+	}()
+}
+
+// startRefreshSpinner animates the table counter until stopRefreshSpinner.
+func (u *tuiState) startRefreshSpinner() {
+	if u.refreshSpinner != nil {
+		return
+	}
+	stop := make(chan struct{})
+	u.refreshSpinner = stop
+	u.refreshStarted = time.Now()
+	go func() {
+		ticker := time.NewTicker(refreshSpinnerInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				u.app.QueueUpdateDraw(u.renderTableMeta)
+			}
+		}
+	}()
+}
+
+func (u *tuiState) stopRefreshSpinner() {
+	if u.refreshSpinner == nil {
+		return
 	}
+	close(u.refreshSpinner)
+	u.refreshSpinner = nil
+}
+
+// applyRefresh shows freshly listed worktrees.
+func (u *tuiState) applyRefresh(items []Worktree) {
 	u.clearDiffCaches()
 	u.items = items
+	u.requestTodoScan()
+	u.requestConflictScan()
+	u.requestAgentWatch()
 	alive := map[string]struct{}{}
 	for _, it := range items {
 		if strings.TrimSpace(it.Path) == "" {
@@ -845,36 +1749,38 @@ func (u *tuiState) refresh() error {
 	u.renderTableMeta()
 	u.renderDetails()
 	u.renderStatusPane()
-	return nil
+	u.renderRepoSidebar()
 }
 
-func (u *tuiState) startLiveDetailUpdates(interval time.Duration) func() {
+// startLiveDetailUpdates keeps the selected worktree's agent output and
+// prompt state current. It polls every fast interval while the agent's pane
+// keeps changing or keys are passed through to it, and backs off towards
+// slow while nothing changes, so an idle TUI leaves the CPU alone.
+func (u *tuiState) startLiveDetailUpdates(fast, slow time.Duration) func() {
 	done := make(chan struct{})
-	ticker := time.NewTicker(interval)
 	go func() {
-		defer ticker.Stop()
+		interval := fast
+		timer := time.NewTimer(interval)
+		defer timer.Stop()
 		for {
 			select {
 			case <-done:
 				return
-			case <-ticker.C:
-				u.app.QueueUpdateDraw(func() {
-					if !u.isMainFocus() {
-						return
-					}
-					item := u.selectedItem()
-					if item == nil {
-						return
-					}
-					if u.detailTab == detailTabAgent {
-						if u.shouldRefreshAgentDetail(item) {
-							u.renderDetails()
-						}
-						return
-					}
-					u.captureAgentPromptState(item, 40)
-				})
+			case <-timer.C:
+			case <-u.detailPollWake:
+				timer.Stop()
+			}
+			active := make(chan bool, 1)
+			u.app.QueueUpdateDraw(func() {
+				active <- u.pollDetail()
+			})
+			select {
+			case <-done:
+				return
+			case changed := <-active:
+				interval = nextDetailPollInterval(interval, changed, fast, slow)
 			}
+			timer.Reset(interval)
 		}
 	}()
 	return func() {
@@ -882,15 +1788,81 @@ func (u *tuiState) startLiveDetailUpdates(interval time.Duration) func() {
 	}
 }
 
+// pollDetail updates the details of the selected worktree from its agent
+// pane, reporting whether anything is going on there.
+func (u *tuiState) pollDetail() bool {
+	if !u.isMainFocus() {
+		return false
+	}
+	item := u.selectedItem()
+	if item == nil {
+		return false
+	}
+	key := fmt.Sprintf("%s\x00%d", item.Path, u.detailTab)
+	moved := key != u.detailPollKey
+	u.detailPollKey = key
+	if u.detailTab == detailTabAgent {
+		changed := u.shouldRefreshAgentDetail(item)
+		if changed {
+			u.renderDetails()
+		}
+		return changed || moved || u.passthrough != ""
+	}
+	return u.captureAgentPromptState(item, 40) || moved
+}
+
+// wakeDetailPoll makes the live details poller look again now, at its
+// fast interval, after the selection or the passthrough changes.
+func (u *tuiState) wakeDetailPoll() {
+	select {
+	case u.detailPollWake <- struct{}{}:
+	default:
+	}
+}
+
+// nextDetailPollInterval is how long the live details poller waits next:
+// fast after a poll found activity, otherwise twice the current interval,
+// up to slow.
+func nextDetailPollInterval(current time.Duration, active bool, fast, slow time.Duration) time.Duration {
+	if active || current < fast {
+		return fast
+	}
+	next := current * 2
+	if next > slow {
+		next = slow
+	}
+	if next < fast {
+		next = fast
+	}
+	return next
+}
+
 func (u *tuiState) detailPaneTitle() string {
+	if u.passthrough != "" {
+		if item := u.selectedItem(); item != nil {
+			if pane, ok := u.previewPane[item.Path]; ok && pane.PaneID == u.passthrough {
+				return "[2]-Details (typing to " + previewPaneLabel(pane) + ", ctrl+] to stop)"
+			}
+		}
+		return "[2]-Details (typing to agent, ctrl+] to stop)"
+	}
 	return "[2]-Details"
 }
 
 func (u *tuiState) startUpdateCheck() {
+	mgr := u.mgr
 	go func() {
-		if latest, ok := checkForUpdate(Version, u.mgr.Cfg); ok {
+		if latest, ok := checkForUpdate(Version, mgr.Cfg); ok {
+			current := Version
+			if commit := buildInfo().ShortCommit(); commit != "" {
+				current += " " + commit
+			}
+			channel := mgr.Cfg.UpdateChannel
+			if channel == "" {
+				channel = updateChannelStable
+			}
 			u.app.QueueUpdateDraw(func() {
-				u.setWarn("update available: %s (current %s)", latest, Version)
+				u.setWarn("update available: %s on the %s channel (current %s)", latest, channel, current)
 			})
 		}
 	}()
@@ -900,6 +1872,17 @@ func (u *tuiState) shouldRefreshAgentDetail(item *Worktree) bool {
 	if item == nil {
 		return false
 	}
+	if pane, ok := u.previewPane[item.Path]; ok {
+		activity, err := u.mgr.tmuxPaneActivity(pane.PaneID)
+		if err != nil {
+			return true
+		}
+		if last, ok := u.paneActivity[pane.PaneID]; ok && last == activity {
+			return false
+		}
+		u.paneActivity[pane.PaneID] = activity
+		return true
+	}
 	if item.AgentState != "yes" {
 		return false
 	}
@@ -918,31 +1901,94 @@ func (u *tuiState) shouldRefreshAgentDetail(item *Worktree) bool {
 	return true
 }
 
-func (u *tuiState) renderDetailTabs() {
-	agentStyle := lipgloss.NewStyle().Foreground(ColorCyan).Bold(true)
-	diffStyle := lipgloss.NewStyle().Foreground(ColorCyan).Bold(true)
-	separator := lipgloss.NewStyle().Foreground(ColorCyan).Render("|")
+// detailTabLabels are the detail tabs in the order they are drawn.
+var detailTabLabels = []struct {
+	tab   detailTab
+	label string
+}{
+	{detailTabAgent, " AGENT OUTPUT "},
+	{detailTabDiff, " GIT DIFF "},
+	{detailTabLog, " LOG "},
+	{detailTabTodos, " TODOS "},
+	{detailTabSymbols, " SYMBOLS "},
+}
 
-	switch u.detailTab {
-	case detailTabDiff:
-		diffStyle = diffStyle.Reverse(true)
-	default:
-		agentStyle = agentStyle.Reverse(true)
-	}
+// detailTabSeparator sits between the tab labels.
+const detailTabSeparator = " | "
 
-	agent := agentStyle.Render(" AGENT OUTPUT ")
-	diff := diffStyle.Render(" GIT DIFF ")
+func (u *tuiState) renderDetailTabs() {
+	tabStyle := lipgloss.NewStyle().Foreground(ColorCyan).Bold(true)
+	separator := lipgloss.NewStyle().Foreground(ColorCyan).Render(strings.TrimSpace(detailTabSeparator))
+
+	labels := make([]string, 0, len(detailTabLabels))
+	for _, t := range detailTabLabels {
+		style := tabStyle
+		if t.tab == u.detailTab {
+			style = style.Reverse(true)
+		}
+		labels = append(labels, style.Render(t.label))
+	}
+	u.detailTabs.SetText(translateANSI(" " + strings.Join(labels, " "+separator+" ")))
+}
 
-	u.detailTabs.SetText(tview.TranslateANSI(fmt.Sprintf(" %s %s %s", agent, separator, diff)))
+// detailTabAt returns the tab drawn at column x of the tab bar.
+func detailTabAt(x int) (detailTab, bool) {
+	pos := 1
+	for _, t := range detailTabLabels {
+		width := len(t.label)
+		if x >= pos && x < pos+width {
+			return t.tab, true
+		}
+		pos += width + len(detailTabSeparator)
+	}
+	return detailTabAgent, false
 }
 
 func (u *tuiState) currentFilterLabel() string {
 	if strings.TrimSpace(u.filter) == "" {
 		return "(none)"
 	}
+	if name := filterPresetFor(u.mgr.Cfg.FilterPresets, u.filter); name != "" {
+		return name + " (" + u.filter + ")"
+	}
 	return u.filter
 }
 
+// cycleFilterPreset sets the filter to the next preset of the filters
+// table, by name, and clears it after the last one.
+func (u *tuiState) cycleFilterPreset() {
+	presets := u.mgr.Cfg.FilterPresets
+	names := filterPresetNames(presets)
+	if len(names) == 0 {
+		u.setWarn("no filter presets; add them under [filters] in the config")
+		return
+	}
+	next := 0
+	if current := filterPresetFor(presets, u.filter); current != "" {
+		for i, name := range names {
+			if name == current {
+				next = i + 1
+			}
+		}
+	}
+	if next < len(names) {
+		u.filter = strings.TrimSpace(presets[names[next]])
+	} else {
+		u.filter = ""
+	}
+	if err := saveFilter(u.repoRoot, u.filter); err != nil {
+		errorLogf("saved_filters write failed: %v", err)
+	}
+	u.applyFilter()
+	u.renderTable()
+	u.renderDetails()
+	if u.filter == "" {
+		u.setInfo("filter cleared")
+		return
+	}
+	u.setInfo("filter preset %s: %s", names[next], u.filter)
+}
+
 func (u *tuiState) renderStatusPane() {
 	repoBranch := u.mgr.CurrentBranch(u.repoRoot)
 	if repoBranch == "" {
@@ -985,27 +2031,109 @@ func (u *tuiState) renderStatusPane() {
 		"%s %s %s %s  %s %s  %s %s",
 		check, repoStr, arrow, branchStr, selLabel, selBranch, agLabel, agStatus,
 	)
+	fetched := u.fetchLabel()
+	if fetched != "" {
+		fetchLabel := lipgloss.NewStyle().Foreground(ColorBlue).Render("fetched:")
+		fetchText := lipgloss.NewStyle().Foreground(ThemeColorMuted).Render(fetched)
+		status += fmt.Sprintf("  %s %s", fetchLabel, fetchText)
+		fetched = "   fetched: " + fetched
+	}
+	resources := ""
+	if item := u.selectedItem(); item != nil {
+		if usage, ok := u.worktreeResources(item); ok {
+			resources = fmt.Sprintf("cpu %.0f%%  mem %s  procs %d", usage.CPU, compactBytes(usage.RSS), usage.Procs)
+			resLabel := lipgloss.NewStyle().Foreground(ColorBlue).Render("res:")
+			resText := lipgloss.NewStyle().Foreground(resourceUsageColor(usage)).Render(resources)
+			status += fmt.Sprintf("  %s %s", resLabel, resText)
+			resources = "   res: " + resources
+		}
+	}
+
+	conflicts := ""
+	if item := u.selectedItem(); item != nil {
+		if files := u.conflicts[item.Path]; len(files) > 0 {
+			conflicts = fmt.Sprintf("CONFLICT in %d file", len(files))
+			if len(files) > 1 {
+				conflicts += "s"
+			}
+			mergeLabel := lipgloss.NewStyle().Foreground(ColorBlue).Render("merge:")
+			mergeText := lipgloss.NewStyle().Foreground(ColorRed).Bold(true).Render(conflicts)
+			status += fmt.Sprintf("  %s %s", mergeLabel, mergeText)
+			conflicts = "   merge: " + conflicts
+		}
+	}
+
+	task := ""
+	if item := u.selectedItem(); item != nil && item.Task != "" {
+		task, _, _ = strings.Cut(item.Task, "\n")
+		task = truncate(task, 60)
+		taskLabel := lipgloss.NewStyle().Foreground(ColorBlue).Render("task:")
+		taskText := lipgloss.NewStyle().Foreground(ThemeColorMuted).Render(task)
+		status += fmt.Sprintf("  %s %s", taskLabel, taskText)
+		task = "   task: " + task
+	}
+
+	usage := ""
+	if item := u.selectedItem(); item != nil {
+		current := u.worktreeUsage(*item)
+		usage = formatAgentUsage(current)
+		if current.ContextLeft > 0 && item.AgentState == "yes" {
+			usage = strings.TrimSpace(fmt.Sprintf("%s  %d%% context left", usage, current.ContextLeft))
+		}
+		if usage != "" {
+			usageLabel := lipgloss.NewStyle().Foreground(ColorBlue).Render("usage:")
+			usageText := lipgloss.NewStyle().Foreground(ThemeColorMuted).Render(usage)
+			status += fmt.Sprintf("  %s %s", usageLabel, usageText)
+			usage = "   usage: " + usage
+		}
+	}
+
+	agentCmd := ""
+	if item := u.selectedItem(); item != nil && item.AgentCommand != "" {
+		agentCmd = truncate(item.AgentCommand, 48)
+		cmdLabel := lipgloss.NewStyle().Foreground(ColorBlue).Render("agent cmd:")
+		cmdText := lipgloss.NewStyle().Foreground(ColorPurple).Render(agentCmd + " (.sprout.toml)")
+		status += fmt.Sprintf("  %s %s", cmdLabel, cmdText)
+		agentCmd = "   agent cmd: " + agentCmd + " (.sprout.toml)"
+	}
 
 	if u.app.GetFocus() == u.statusPane {
 		status = lipgloss.NewStyle().Reverse(true).Render(
-			fmt.Sprintf("✓ %s -> %s   selected: %s   agent: %s   (enter to switch repo)", repo, repoBranch, selectedBranch, agentLabel),
+			fmt.Sprintf("✓ %s -> %s   selected: %s   agent: %s%s%s%s%s%s%s   (enter to switch repo, s for sessions)", repo, repoBranch, selectedBranch, agentLabel, fetched, resources, conflicts, task, usage, agentCmd),
 		)
 	}
 
-	u.statusPane.SetText(tview.TranslateANSI(status))
+	u.statusPane.SetText(translateANSI(status))
 }
 
 func (u *tuiState) refreshRepoChoices() {
-	parent := filepath.Dir(u.repoRoot)
+	u.setRepoChoices(loadRepoChoices(u.repoRoot))
+}
+
+func (u *tuiState) setRepoChoices(repos []repoChoice) {
+	u.repos = repos
+	u.repoSlug = ""
+	for _, r := range u.repos {
+		if r.Root == u.repoRoot {
+			u.repoSlug = r.GitHubRepo
+			break
+		}
+	}
+	u.renderRepoSidebar()
+}
+
+// loadRepoChoices lists repoRoot and the git repositories beside it,
+// repoRoot first and the rest by name. It reads every repository's remote,
+// so refresh calls it off the UI goroutine.
+func loadRepoChoices(repoRoot string) []repoChoice {
+	parent := filepath.Dir(repoRoot)
 	entries, err := os.ReadDir(parent)
 	if err != nil {
-		u.repos = []repoChoice{buildRepoChoice(u.repoRoot)}
-		u.repoSlug = u.repos[0].GitHubRepo
-		return
+		return []repoChoice{buildRepoChoice(repoRoot)}
 	}
 
 	choices := map[string]repoChoice{}
-	choices[u.repoRoot] = buildRepoChoice(u.repoRoot)
+	choices[repoRoot] = buildRepoChoice(repoRoot)
 
 	for _, ent := range entries {
 		if !ent.IsDir() {
@@ -1018,36 +2146,29 @@ func (u *tuiState) refreshRepoChoices() {
 		choices[root] = buildRepoChoice(root)
 	}
 
-	u.repos = u.repos[:0]
+	repos := make([]repoChoice, 0, len(choices))
 	for _, choice := range choices {
-		u.repos = append(u.repos, choice)
+		repos = append(repos, choice)
 	}
 
-	sort.Slice(u.repos, func(i, j int) bool {
-		if u.repos[i].Root == u.repoRoot {
+	sort.Slice(repos, func(i, j int) bool {
+		if repos[i].Root == repoRoot {
 			return true
 		}
-		if u.repos[j].Root == u.repoRoot {
+		if repos[j].Root == repoRoot {
 			return false
 		}
-		li := u.repos[i].GitHubRepo
+		li := repos[i].GitHubRepo
 		if li == "" {
-			li = u.repos[i].Name
+			li = repos[i].Name
 		}
-		lj := u.repos[j].GitHubRepo
+		lj := repos[j].GitHubRepo
 		if lj == "" {
-			lj = u.repos[j].Name
+			lj = repos[j].Name
 		}
 		return li < lj
 	})
-
-	u.repoSlug = ""
-	for _, r := range u.repos {
-		if r.Root == u.repoRoot {
-			u.repoSlug = r.GitHubRepo
-			break
-		}
-	}
+	return repos
 }
 
 func buildRepoChoice(root string) repoChoice {
@@ -1118,9 +2239,10 @@ func parseGitHubRepo(url string) string {
 func (u *tuiState) renderTable() {
 	u.table.Clear()
 
-	headers := []string{"CUR", "BRANCH", "STATUS", "TMUX", "AGENT", "PATH"}
-	for col, h := range headers {
-		cell := tview.NewTableCell(h).
+	columns := tableColumns(u.mgr.Cfg, u.showResources)
+	u.columns = columns
+	for col, name := range columns {
+		cell := tview.NewTableCell(worktreeColumnHeaders[name]).
 			SetAttributes(tcell.AttrBold).
 			SetTextColor(ColorToTcell(ThemeColorPrimary)).
 			SetExpansion(1).
@@ -1128,54 +2250,38 @@ func (u *tuiState) renderTable() {
 		u.table.SetCell(0, col, cell)
 	}
 
+	// Lay out every column but the path first, so the path can take what
+	// is left of the table's width.
+	widths := make([]int, len(columns))
+	for col, name := range columns {
+		widths[col] = len(worktreeColumnHeaders[name])
+	}
+	rows := make([][]*tview.TableCell, len(u.visible))
 	for row, idx := range u.visible {
 		item := u.items[idx]
-		cur := ""
-		if item.Current {
-			cur = "*"
-		}
-		branch := item.Branch
-		if branch == "" {
-			branch = "detached"
-		}
-		status := "clean"
-		if item.Dirty {
-			status = "dirty"
-		}
-		agent := u.tableAgentLabel(item)
-
-		values := []string{cur, truncate(branch, 35), status, item.TmuxState, agent, truncatePath(item.Path, 120)}
-		for col, val := range values {
-			cell := tview.NewTableCell(val).SetExpansion(1).SetTextColor(tcell.ColorDefault)
-			switch col {
-			case 0:
-				if val != "" {
-					cell.SetTextColor(ColorToTcell(ThemeColorAccent))
-				}
-			case 2:
-				if status == "dirty" {
-					cell.SetTextColor(tcell.ColorRed)
-				} else {
-					cell.SetTextColor(tcell.ColorGreen)
-				}
-			case 3:
-				if val == "yes" {
-					cell.SetTextColor(tcell.ColorGreen)
-				} else if val == "no" {
-					cell.SetTextColor(tcell.ColorRed)
-				} else {
-					cell.SetTextColor(ColorToTcell(ThemeColorSecondary))
-				}
-			case 4:
-				cell.SetTextColor(tableAgentColor(val))
-			}
-			if item.Current && col == 1 {
-				cell.SetTextColor(ColorToTcell(ThemeColorAccent))
-				cell.SetAttributes(tcell.AttrBold)
+		rows[row] = make([]*tview.TableCell, len(columns))
+		for col, name := range columns {
+			if name == columnPath {
+				continue
 			}
-			if status == "dirty" && col == 2 {
-				cell.SetAttributes(tcell.AttrBold)
+			cell := u.tableCell(name, idx, item)
+			if w := tview.TaggedStringWidth(cell.Text); w > widths[col] {
+				widths[col] = w
 			}
+			rows[row][col] = cell
+		}
+	}
+	if pathCol := columnIndex(columns, columnPath); pathCol >= 0 {
+		_, _, total, _ := u.table.GetInnerRect()
+		others := append(append([]int(nil), widths[:pathCol]...), widths[pathCol+1:]...)
+		pathWidth := pathColumnWidth(total, others, 1)
+		for row, idx := range u.visible {
+			cell := tview.NewTableCell(tview.Escape(truncatePath(u.mgr.displayPath(u.repoRoot, u.items[idx].Path), pathWidth))).SetExpansion(1).SetTextColor(tcell.ColorDefault)
+			rows[row][pathCol] = cell
+		}
+	}
+	for row, cells := range rows {
+		for col, cell := range cells {
 			u.table.SetCell(row+1, col, cell)
 		}
 	}
@@ -1190,6 +2296,150 @@ func (u *tuiState) renderTable() {
 	u.renderTableMeta()
 }
 
+// tableCell renders column name of item, u.items[idx], for the worktree
+// table. The path column is laid out by renderTable.
+func (u *tuiState) tableCell(name string, idx int, item Worktree) *tview.TableCell {
+	cell := tview.NewTableCell("").SetExpansion(1).SetTextColor(tcell.ColorDefault)
+	switch name {
+	case columnCur:
+		if item.Current {
+			cell.SetText("*").SetTextColor(ColorToTcell(ThemeColorAccent))
+		}
+	case columnBranch:
+		branch := item.Branch
+		if branch == "" {
+			branch = "detached"
+		}
+		if item.PR != 0 {
+			branch += " #" + strconv.Itoa(item.PR)
+		}
+		priority := worktreePriority(item)
+		prefix := ""
+		switch priority {
+		case priorityHigh:
+			prefix = "↑ "
+			cell.SetTextColor(ColorToTcell(ColorRed)).SetAttributes(tcell.AttrBold)
+		case priorityLow:
+			prefix = "↓ "
+			cell.SetTextColor(ColorToTcell(ThemeColorMuted))
+		}
+		if item.Current {
+			cell.SetTextColor(ColorToTcell(ThemeColorAccent)).SetAttributes(tcell.AttrBold)
+		}
+		cell.SetText(highlightMatches(prefix+branch, shiftOffsets(u.filterMatches[idx], len(prefix)), branchColumnWidth))
+	case columnPriority:
+		priority := worktreePriority(item)
+		cell.SetText(priority)
+		switch priority {
+		case priorityHigh:
+			cell.SetTextColor(ColorToTcell(ColorRed))
+		case priorityLow:
+			cell.SetTextColor(ColorToTcell(ThemeColorMuted))
+		}
+	case columnLabels:
+		cell.SetText(formatLabels(item)).SetTextColor(ColorToTcell(ColorCyan))
+	case columnStatus:
+		status := worktreeStatusLabel(item)
+		cell.SetText(status)
+		switch status {
+		case "dirty", rebaseStateAborted:
+			cell.SetTextColor(ColorToTcell(ColorRed))
+		case rebaseStateRunning:
+			cell.SetTextColor(ColorToTcell(ColorYellow))
+		default:
+			cell.SetTextColor(ColorToTcell(ColorGreen))
+		}
+		if status == "dirty" {
+			cell.SetAttributes(tcell.AttrBold)
+		}
+	case columnTmux:
+		cell.SetText(item.TmuxState)
+		switch item.TmuxState {
+		case "yes":
+			cell.SetTextColor(ColorToTcell(ColorGreen))
+		case "no":
+			cell.SetTextColor(ColorToTcell(ColorRed))
+		default:
+			cell.SetTextColor(ColorToTcell(ThemeColorSecondary))
+		}
+	case columnAgent:
+		agent := u.tableAgentLabel(item)
+		cell.SetText(agent).SetTextColor(tableAgentColor(agent))
+	case columnTodo:
+		cell.SetTextColor(ColorToTcell(ThemeColorMuted))
+		if markers, ok := u.todos[item.Path]; ok {
+			cell.SetText(strconv.Itoa(len(markers)))
+			if len(markers) > 0 {
+				cell.SetTextColor(ColorToTcell(ColorYellow))
+			}
+		}
+	case columnMerge:
+		cell.SetText(conflictLabel(u.conflicts, item))
+		if len(u.conflicts[item.Path]) > 0 {
+			cell.SetTextColor(ColorToTcell(ColorRed)).SetAttributes(tcell.AttrBold)
+		} else {
+			cell.SetTextColor(ColorToTcell(ThemeColorMuted))
+		}
+	case columnLock:
+		if item.Locked {
+			cell.SetText("locked")
+		}
+		cell.SetTextColor(ColorToTcell(ColorYellow))
+	case columnResources:
+		if usage, ok := u.worktreeResources(&item); ok {
+			cell.SetText(formatResourceUsage(usage)).SetTextColor(ColorToTcell(resourceUsageColor(usage)))
+		}
+	case columnAhead:
+		cell.SetText(formatAheadBehind(item))
+		if item.Behind > 0 {
+			cell.SetTextColor(ColorToTcell(ColorYellow))
+		} else {
+			cell.SetTextColor(ColorToTcell(ThemeColorMuted))
+		}
+	case columnCheck:
+		cell.SetText(formatCheck(item.Check))
+		switch {
+		case item.Check.Stale:
+			cell.SetTextColor(ColorToTcell(ThemeColorMuted))
+		case item.Check.Status == checkFail:
+			cell.SetTextColor(ColorToTcell(ColorRed)).SetAttributes(tcell.AttrBold)
+		default:
+			cell.SetTextColor(ColorToTcell(ColorGreen))
+		}
+	case columnCost:
+		cell.SetText(formatAgentUsage(u.worktreeUsage(item))).SetTextColor(ColorToTcell(ThemeColorMuted))
+	case columnActive:
+		cell.SetText(formatLastActive(item.LastActive, time.Now())).SetTextColor(ColorToTcell(ThemeColorMuted))
+		if !item.LastActive.IsZero() && time.Since(item.LastActive) > staleBranchAge {
+			cell.SetTextColor(ColorToTcell(ColorYellow))
+		}
+	}
+	return cell
+}
+
+// worktreeUsage is the agent usage of item, as last sampled by the agent
+// notifier or else as listed.
+func (u *tuiState) worktreeUsage(item Worktree) AgentUsage {
+	if usage, ok := u.agentUsage[item.Path]; ok {
+		return usage
+	}
+	return item.Usage
+}
+
+// conflictLabel is the merge column of a worktree: CONFLICT when merging
+// its branch into the base branch would conflict, ok when it would not, and
+// empty until checked.
+func conflictLabel(conflicts map[string][]string, item Worktree) string {
+	files, ok := conflicts[item.Path]
+	switch {
+	case !ok:
+		return ""
+	case len(files) > 0:
+		return "CONFLICT"
+	}
+	return "ok"
+}
+
 func (u *tuiState) updateSelectedAgentCell() {
 	item := u.selectedItem()
 	if item == nil {
@@ -1202,29 +2452,68 @@ func (u *tuiState) updateSelectedAgentCell() {
 	if row <= 0 {
 		return
 	}
+	col := columnIndex(u.columns, columnAgent)
+	if col < 0 {
+		return
+	}
 	label := u.tableAgentLabel(*item)
-	cell := u.table.GetCell(row, 4)
+	cell := u.table.GetCell(row, col)
 	if cell == nil {
 		return
 	}
 	cell.SetText(label)
 	cell.SetTextColor(tableAgentColor(label))
-	u.table.SetCell(row, 4, cell)
+	u.table.SetCell(row, col, cell)
 }
 
-func (u *tuiState) renderTableMeta() {
-	if len(u.visible) == 0 {
-		u.table.SetCounter("0 of 0")
+// updateAgentCells redraws the agent column of every visible row.
+func (u *tuiState) updateAgentCells() {
+	col := columnIndex(u.columns, columnAgent)
+	if col < 0 {
+		return
+	}
+	for i, idx := range u.visible {
+		cell := u.table.GetCell(i+1, col)
+		if cell == nil {
+			continue
+		}
+		label := u.tableAgentLabel(u.items[idx])
+		cell.SetText(label)
+		cell.SetTextColor(tableAgentColor(label))
+	}
+}
+
+// updateCostCells refreshes the cost column in place after the agent
+// notifier sampled new usage.
+func (u *tuiState) updateCostCells() {
+	col := columnIndex(u.columns, columnCost)
+	if col < 0 {
 		return
 	}
-	current := u.selected + 1
-	if current < 1 {
-		current = 1
+	for i, idx := range u.visible {
+		if cell := u.table.GetCell(i+1, col); cell != nil {
+			cell.SetText(formatAgentUsage(u.worktreeUsage(u.items[idx])))
+		}
+	}
+}
+
+func (u *tuiState) renderTableMeta() {
+	counter := "0 of 0"
+	if len(u.visible) > 0 {
+		current := u.selected + 1
+		if current < 1 {
+			current = 1
+		}
+		if current > len(u.visible) {
+			current = len(u.visible)
+		}
+		counter = fmt.Sprintf("%d of %d", current, len(u.visible))
 	}
-	if current > len(u.visible) {
-		current = len(u.visible)
+	if u.refreshSpinner != nil {
+		frame := int(time.Since(u.refreshStarted)/refreshSpinnerInterval) % len(refreshSpinnerFrames)
+		counter = string(refreshSpinnerFrames[frame]) + " refreshing… " + counter
 	}
-	u.table.SetCounter(fmt.Sprintf("%d of %d", current, len(u.visible)))
+	u.table.SetCounter(counter)
 }
 
 func (u *tuiState) selectedItem() *Worktree {
@@ -1239,6 +2528,9 @@ func (u *tuiState) selectedAgentPromptLabel(item *Worktree) (string, string) {
 	if item == nil {
 		return "n/a", "cyan"
 	}
+	if item.AgentState == "crashed" {
+		return "crashed", "red"
+	}
 	if item.AgentState != "yes" {
 		return "offline", "red"
 	}
@@ -1248,14 +2540,23 @@ func (u *tuiState) selectedAgentPromptLabel(item *Worktree) (string, string) {
 	}
 	switch state {
 	case agentPromptReady:
-		return "ready", "green"
+		return u.agentTimerLabel(item.Path, agentStatusReady), "green"
 	case agentPromptBusy:
-		return "busy", "yellow"
+		return u.agentTimerLabel(item.Path, agentStatusBusy), "yellow"
 	default:
 		return "running", "blue"
 	}
 }
 
+// agentTimerLabel is status with how long the agent at path has had it, when
+// the agent notifier saw it in that status too.
+func (u *tuiState) agentTimerLabel(path, status string) string {
+	if timer, ok := u.agentTimers[path]; ok && timer.Status == status {
+		return timer.label(time.Now())
+	}
+	return status
+}
+
 func (u *tuiState) tableAgentLabel(item Worktree) string {
 	if item.AgentState != "yes" {
 		return item.AgentState
@@ -1266,22 +2567,35 @@ func (u *tuiState) tableAgentLabel(item Worktree) string {
 	}
 	switch state {
 	case agentPromptReady:
-		return "ready"
+		return u.agentTimerLabel(item.Path, agentStatusReady)
 	case agentPromptBusy:
-		return "busy"
+		return u.agentTimerLabel(item.Path, agentStatusBusy)
 	default:
 		return "yes"
 	}
 }
 
+// resourceUsageColor flags sessions that keep most of a core busy.
+func resourceUsageColor(usage ResourceUsage) lipgloss.Color {
+	switch {
+	case usage.CPU >= 80:
+		return ColorRed
+	case usage.CPU >= 30:
+		return ColorYellow
+	}
+	return ThemeColorMuted
+}
+
 func tableAgentColor(label string) tcell.Color {
+	// Drop the time of labels like "ready 12m".
+	label, _, _ = strings.Cut(label, " ")
 	switch label {
 	case "ready", "yes":
-		return tcell.ColorGreen
+		return ColorToTcell(ColorGreen)
 	case "busy", "running":
-		return tcell.ColorYellow
-	case "no", "offline":
-		return tcell.ColorRed
+		return ColorToTcell(ColorYellow)
+	case "no", "offline", "crashed":
+		return ColorToTcell(ColorRed)
 	default:
 		return ColorToTcell(ThemeColorSecondary)
 	}
@@ -1311,29 +2625,32 @@ func (u *tuiState) setAgentPromptState(item *Worktree, next agentPromptState) {
 	u.updateSelectedAgentCell()
 }
 
-func (u *tuiState) captureAgentPromptState(item *Worktree, lines int) {
+// captureAgentPromptState updates whether the agent of item waits for
+// input, reporting whether its pane changed since it was last looked at.
+func (u *tuiState) captureAgentPromptState(item *Worktree, lines int) bool {
 	if item == nil || item.AgentState != "yes" {
-		return
+		return false
 	}
 	activity, err := u.mgr.agentPaneActivity(u.repoRoot, item)
 	if err == nil {
 		paneTarget := u.mgr.agentPaneTarget(u.repoRoot, item)
 		if paneTarget != "" {
 			if last, ok := u.panePromptActivity[paneTarget]; ok && last == activity {
-				return
+				return false
 			}
 			u.panePromptActivity[paneTarget] = activity
 		}
 	}
 	out, err := u.mgr.agentOutputForWorktree(u.repoRoot, item, lines)
 	if err != nil {
-		return
+		return false
 	}
 	if agentReadyForInstruction(out) {
 		u.setAgentPromptState(item, agentPromptReady)
-		return
+		return true
 	}
 	u.setAgentPromptState(item, agentPromptBusy)
+	return true
 }
 
 func stripANSI(input string) string {
@@ -1411,1379 +2728,3872 @@ func (u *tuiState) renderDetails() {
 	switch u.detailTab {
 	case detailTabDiff:
 		u.renderDiffDetail()
+	case detailTabLog:
+		u.renderLogDetail()
+	case detailTabTodos:
+		u.renderTodoDetail()
+	case detailTabSymbols:
+		u.renderSymbolDetail()
 	default:
 		u.renderAgentDetail()
 	}
 }
 
-func (u *tuiState) renderAgentDetail() {
+func (u *tuiState) renderTodoDetail() {
 	item := u.selectedItem()
 	if item == nil {
-		u.setDetailText("Select a worktree to view agent output.", false)
+		u.setDetailText("Select a worktree to view TODO/FIXME markers.", false)
 		return
 	}
-
-	captureLines := u.detailCaptureLineCount()
-	if item.AgentState != "yes" {
-		u.setAgentPromptState(item, agentPromptUnknown)
-		u.setDetailText(
-			"Agent pane is not available for this worktree.\n\n"+
-				"Press enter on the worktree list to attach.\n"+
-				"A tmux session will open with your configured session tools.",
-			false,
-		)
+	markers, scanned := u.todos[item.Path]
+	if !scanned {
+		u.setDetailText("Scanning for TODO/FIXME markers...", false)
 		return
 	}
-
-	u.syncDetailPaneSize(item)
-	paneTarget := u.mgr.agentPaneTarget(u.repoRoot, item)
-	var out string
-	activity, activityErr := u.mgr.agentPaneActivity(u.repoRoot, item)
-	if paneTarget != "" && activityErr == nil {
-		if last, ok := u.agentOutputActivity[paneTarget]; ok && last == activity {
-			if cached, ok := u.agentOutputCache[paneTarget]; ok {
-				out = cached
-			}
-		}
+	if len(markers) == 0 {
+		u.setDetailText("No TODO/FIXME markers added on this branch.", false)
+		return
 	}
-	if out == "" {
-		fetched, err := u.mgr.agentOutputForWorktree(u.repoRoot, item, captureLines)
-		if err != nil {
-			u.setAgentPromptState(item, agentPromptUnknown)
-			u.setDetailText(fmt.Sprintf("Unable to read agent output.\n\n%s", err), false)
-			return
-		}
-		out = fetched
-		if paneTarget != "" {
-			u.agentOutputCache[paneTarget] = out
-			if activityErr == nil {
-				u.agentOutputActivity[paneTarget] = activity
-			}
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("%s# %d marker(s) added since the base branch[-]\n\n", colorTag(ColorCyan), len(markers)))
+	for _, marker := range markers {
+		kindColor := colorTag(ColorYellow)
+		if marker.Kind == "FIXME" {
+			kindColor = colorTag(ColorRed)
 		}
+		b.WriteString(fmt.Sprintf("%s%-5s[-] %s%s:%d[-]\n      %s\n", kindColor, marker.Kind, colorTag(ColorBlue), tview.Escape(marker.File), marker.Line, tview.Escape(marker.Text)))
 	}
-	if strings.TrimSpace(out) == "" {
-		u.setAgentPromptState(item, agentPromptBusy)
-		out = "(agent pane is running, but no output yet)"
-	} else if agentReadyForInstruction(out) {
-		u.setAgentPromptState(item, agentPromptReady)
-	} else {
-		u.setAgentPromptState(item, agentPromptBusy)
-	}
-	u.setDetailANSI(out, true)
-}
-
-func (u *tuiState) clearDiffCaches() {
-	u.diffCache = map[string]diffFilesCacheEntry{}
-	u.patchCache = map[string]diffPatchCacheEntry{}
-	u.lastDiff = ""
+	u.setDetailRenderedText(b.String(), false)
 }
 
-func (u *tuiState) cachedDiffFiles(path string) ([]DiffFile, error) {
-	now := time.Now()
-	if entry, ok := u.diffCache[path]; ok && now.Sub(entry.fetchedAt) <= diffFilesCacheTTL {
-		return entry.files, nil
+func (u *tuiState) renderSymbolDetail() {
+	item := u.selectedItem()
+	if item == nil {
+		u.setDetailText("Select a worktree to view changed symbols.", false)
+		return
 	}
-	files, err := u.mgr.WorktreeDiffFiles(path)
+	changes, err := cachedWorktreeQuery(u.mgr, u.repoRoot, item.Path, "symbols", symbolCacheTTL, func() ([]SymbolChange, error) {
+		return u.mgr.WorktreeSymbolChanges(u.repoRoot, item)
+	})
 	if err != nil {
-		return nil, err
-	}
-	u.diffCache[path] = diffFilesCacheEntry{
-		files:     files,
-		fetchedAt: now,
+		u.setDetailText(fmt.Sprintf("Unable to summarize changed symbols.\n\n%s", err), false)
+		return
 	}
-	if len(u.diffCache) > 128 {
-		u.diffCache = map[string]diffFilesCacheEntry{path: u.diffCache[path]}
+	if len(changes) == 0 {
+		u.setDetailText("No Go functions or types changed on this branch.", false)
+		return
 	}
-	return files, nil
-}
 
-func diffPatchCacheKey(path string, file DiffFile, width int) string {
-	return strings.Join([]string{
-		path,
-		file.Path,
-		file.Status,
-		strconv.Itoa(width),
-	}, "\x00")
-}
-
-func (u *tuiState) cachedFileDiff(path string, file DiffFile, width int) (string, error) {
-	key := diffPatchCacheKey(path, file, width)
-	now := time.Now()
-	if entry, ok := u.patchCache[key]; ok && now.Sub(entry.fetchedAt) <= diffPatchCacheTTL {
-		return entry.text, nil
+	counts := map[string]int{}
+	for _, change := range changes {
+		counts[change.Change]++
 	}
-	diff, err := u.mgr.WorktreeDiffForFile(path, file, width)
-	if err != nil {
-		return "", err
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("%s# %d added, %d modified, %d removed since the base branch[-]\n", colorTag(ColorCyan), counts["added"], counts["modified"], counts["removed"]))
+	file := ""
+	for _, change := range changes {
+		if change.File != file {
+			file = change.File
+			b.WriteString(fmt.Sprintf("\n%s%s[-]\n", colorTag(ColorBlue), tview.Escape(file)))
+		}
+		marker, color := "~", ColorYellow
+		switch change.Change {
+		case "added":
+			marker, color = "+", ColorGreen
+		case "removed":
+			marker, color = "-", ColorRed
+		}
+		b.WriteString(fmt.Sprintf("  %s%s[-] %-6s %s\n", colorTag(color), marker, change.Kind, tview.Escape(change.Name)))
 	}
-	u.patchCache[key] = diffPatchCacheEntry{
-		text:      diff,
-		fetchedAt: now,
+	u.setDetailRenderedText(b.String(), false)
+}
+
+// requestTodoScan queues a TODO/FIXME scan of the current worktrees, replacing
+// any scan request that has not been picked up yet.
+func (u *tuiState) requestTodoScan() {
+	if u.todoScan == nil {
+		return
 	}
-	if len(u.patchCache) > 512 {
-		u.patchCache = map[string]diffPatchCacheEntry{key: u.patchCache[key]}
+	req := todoScanRequest{mgr: u.mgr, repoRoot: u.repoRoot, items: append([]Worktree(nil), u.items...)}
+	select {
+	case <-u.todoScan:
+	default:
 	}
-	return diff, nil
+	u.todoScan <- req
 }
 
-func (u *tuiState) renderDiffDetail() {
-	item := u.selectedItem()
-	if item == nil {
-		u.diffItems = nil
-		u.diffSel = 0
-		u.diffPath = ""
-		u.renderDiffFileList()
-		u.setDiffText("Select a worktree to view git diff.", false)
+// requestConflictScan queues a merge conflict check of the current
+// worktrees, like requestTodoScan.
+func (u *tuiState) requestConflictScan() {
+	if u.conflictScan == nil {
 		return
 	}
-	files, err := u.cachedDiffFiles(item.Path)
-	if err != nil {
-		u.diffItems = nil
-		u.diffSel = 0
-		u.diffPath = item.Path
-		u.renderDiffFileList()
-		u.setDiffText(fmt.Sprintf("Unable to read git diff.\n\n%s", err), false)
-		return
+	req := todoScanRequest{mgr: u.mgr, repoRoot: u.repoRoot, items: append([]Worktree(nil), u.items...)}
+	select {
+	case <-u.conflictScan:
+	default:
 	}
-	u.syncDiffFiles(item.Path, files)
-	u.renderDiffFileList()
-	if len(u.diffItems) == 0 {
-		u.setDiffText("(working tree is clean)", false)
+	u.conflictScan <- req
+}
+
+// requestAgentWatch hands the current worktrees to the agent notifier.
+func (u *tuiState) requestAgentWatch() {
+	if u.agentWatch == nil {
 		return
 	}
-	u.renderSelectedFileDiff()
+	req := todoScanRequest{mgr: u.mgr, repoRoot: u.repoRoot, items: append([]Worktree(nil), u.items...)}
+	select {
+	case <-u.agentWatch:
+	default:
+	}
+	u.agentWatch <- req
 }
 
-func (u *tuiState) syncDiffFiles(path string, files []DiffFile) {
-	switchedWorktree := path != u.diffPath
-	prev := ""
-	if !switchedWorktree && u.diffSel >= 0 && u.diffSel < len(u.diffItems) {
-		prev = u.diffItems[u.diffSel].Path
+// startAgentNotifier polls every agent of the current repository, not just
+// the selected one. When one becomes ready for input, exits, or has waited
+// for input longer than agent_idle_minutes it records the event and sends
+// the configured notifications. It also hands the TUI how long each agent
+// has been busy or ready.
+func (u *tuiState) startAgentNotifier(interval time.Duration) func() {
+	done := make(chan struct{})
+	mgr := u.mgr
+	notifier := newNotifier(mgr.Cfg)
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		watcher := newAgentWatcher(time.Duration(mgr.Cfg.AgentIdleMinutes) * time.Minute)
+		labels := map[string]string{}
+		// Usage is read again only once a pane has new output.
+		usageActivity := map[string]int64{}
+		var last *todoScanRequest
+		for {
+			select {
+			case <-done:
+				return
+			case req := <-u.agentWatch:
+				last = &req
+				if req.mgr != mgr {
+					// Another repository, with its own notify settings.
+					mgr = req.mgr
+					notifier = newNotifier(mgr.Cfg)
+					watcher.idleAfter = time.Duration(mgr.Cfg.AgentIdleMinutes) * time.Minute
+				}
+				continue
+			case <-ticker.C:
+				if last == nil {
+					continue
+				}
+			}
+			alive := map[string]struct{}{}
+			idle := []string{}
+			usage := map[string]AgentUsage{}
+			for i := range last.items {
+				wt := last.items[i]
+				alive[wt.Path] = struct{}{}
+				now := time.Now()
+				status := mgr.agentStatus(last.repoRoot, &wt)
+				var activity time.Time
+				if status == agentStatusReady || status == agentStatusBusy {
+					// Without pane activity (tmux before 3.4), read usage
+					// again every 30 seconds.
+					mark := now.Unix() / 30
+					if ts, err := mgr.agentPaneActivity(last.repoRoot, &wt); err == nil && ts > 0 {
+						mark = ts
+						if status == agentStatusReady {
+							activity = time.Unix(ts, 0)
+						}
+					}
+					if usageActivity[wt.Path] != mark {
+						usageActivity[wt.Path] = mark
+						if total, ok := mgr.sampleAgentUsage(last.repoRoot, &wt); ok {
+							usage[wt.Path] = total
+						}
+					}
+				}
+				event := watcher.observe(wt.Path, status, now, activity)
+				if event == "" {
+					continue
+				}
+				ev := AgentEvent{
+					Event:  event,
+					Repo:   mgr.RepoName(last.repoRoot),
+					Branch: worktreeBranchOrName(&wt),
+					Path:   wt.Path,
+					Time:   now,
+				}
+				switch event {
+				case notifyEventReady:
+					mgr.emit(last.repoRoot, &wt, Event{Type: eventAgentReady})
+				case notifyEventIdle:
+					mgr.emit(last.repoRoot, &wt, Event{Type: eventAgentIdle})
+					ev.Idle = compactDuration(now.Sub(watcher.last[wt.Path].Since))
+					idle = append(idle, fmt.Sprintf("%s (%s)", ev.Branch, ev.Idle))
+				default:
+					mgr.emit(last.repoRoot, &wt, Event{Type: eventAgentExited})
+				}
+				if !notifier.Enabled() {
+					continue
+				}
+				notifier.Notify(ev)
+			}
+			watcher.forget(alive)
+			for path := range usageActivity {
+				if _, ok := alive[path]; !ok {
+					delete(usageActivity, path)
+				}
+			}
+
+			// Redraw only when a label changes, which is about once a minute.
+			timers := watcher.timers()
+			now := time.Now()
+			changed := len(timers) != len(labels)
+			next := make(map[string]string, len(timers))
+			for path, timer := range timers {
+				next[path] = timer.label(now)
+				if labels[path] != next[path] {
+					changed = true
+				}
+			}
+			labels = next
+			if !changed && len(idle) == 0 && len(usage) == 0 {
+				continue
+			}
+			u.app.QueueUpdateDraw(func() {
+				for path, total := range usage {
+					u.agentUsage[path] = total
+				}
+				u.agentTimers = timers
+				if parseWorktreeFilter(u.filter).needsAgentStatus() {
+					u.applyFilter()
+					u.renderTable()
+				}
+				u.updateAgentCells()
+				u.updateCostCells()
+				u.renderStatusPane()
+				u.renderRepoSidebar()
+				if len(idle) > 0 {
+					u.setWarn("agent waiting for input: %s", strings.Join(idle, ", "))
+				}
+			})
+		}
+	}()
+	return func() {
+		close(done)
 	}
+}
 
-	u.diffPath = path
-	u.diffItems = files
+// startHealthCheck runs the startup health checks in the background and
+// shows a banner when something is misconfigured.
+func (u *tuiState) startHealthCheck() {
+	repoRoot := u.repoRoot
+	mgr := u.mgr
+	go func() {
+		problems := mgr.StartupHealth(repoRoot)
+		if len(problems) == 0 {
+			return
+		}
+		for _, p := range problems {
+			infoLogf("startup_health problem=%q hint=%q", p.Message, p.Hint)
+		}
+		u.app.QueueUpdateDraw(func() {
+			u.setBanner(problems)
+		})
+	}()
+}
 
-	if len(u.diffItems) == 0 {
-		u.diffSel = 0
-		return
+// fetchLabel is how long ago the repository was last fetched, for the
+// status pane, or "" when sprout never fetched it.
+func (u *tuiState) fetchLabel() string {
+	switch {
+	case u.fetching:
+		return "fetching…"
+	case u.lastFetch.IsZero():
+		return ""
 	}
-
-	if switchedWorktree {
-		u.diffSel = 0
+	if ago := compactDuration(time.Since(u.lastFetch)); ago != "" {
+		return ago + " ago"
 	}
-	if prev != "" {
-		for i := range u.diffItems {
-			if u.diffItems[i].Path == prev {
-				u.diffSel = i
-				break
+	return "just now"
+}
+
+// startAutoFetch fetches the repository in the background whenever
+// auto_fetch_minutes have passed since it was last fetched, checking every
+// interval. Other sprout processes fetching it count, so several TUIs on
+// one repository do not each fetch it. It follows repository switches.
+func (u *tuiState) startAutoFetch(interval time.Duration) func() {
+	done := make(chan struct{})
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		// Failures are retried every interval but shown once, not on
+		// every retry while offline.
+		failing := false
+		for {
+			res, err := u.manager().AutoFetch(context.Background())
+			switch {
+			case err != nil:
+				errorLogf("auto_fetch failed: %v", err)
+				if !failing {
+					u.app.QueueUpdateDraw(func() {
+						u.setWarn("auto-fetch failed: %v", err)
+					})
+				}
+				failing = true
+			case !res.Skipped:
+				failing = false
+				u.app.QueueUpdateDraw(func() {
+					u.refresh(nil)
+				})
+			}
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
 			}
 		}
+	}()
+	return func() {
+		close(done)
 	}
-	if u.diffSel < 0 {
-		u.diffSel = 0
+}
+
+// fetchNow fetches the repository in the background, then refreshes the
+// worktrees so their ahead/behind counts follow.
+func (u *tuiState) fetchNow() {
+	if u.fetching {
+		u.setInfo("already fetching")
+		return
 	}
-	if u.diffSel >= len(u.diffItems) {
-		u.diffSel = len(u.diffItems) - 1
+	u.fetching = true
+	u.renderStatusPane()
+	u.setInfo("fetching %s…", u.repoName)
+	mgr := u.mgr
+	go func() {
+		res, err := mgr.Fetch(context.Background())
+		u.app.QueueUpdateDraw(func() {
+			u.fetching = false
+			if err != nil {
+				u.renderStatusPane()
+				u.setError("fetch failed: %v", err)
+				return
+			}
+			u.lastFetch = res.FetchedAt
+			u.refresh(func(error) {
+				u.setInfo("fetched in %s", formatPerfMS(res.DurationMS))
+			})
+		})
+	}()
+}
+
+// startResumeCheck points out agents that stopped with the tmux server, so
+// they can be resumed with one key.
+func (u *tuiState) startResumeCheck() {
+	mgr := u.mgr
+	go func() {
+		agents, err := mgr.ResumableAgents()
+		if err != nil {
+			errorLogf("resume_check failed: %v", err)
+			return
+		}
+		if len(agents) == 0 {
+			return
+		}
+		u.app.QueueUpdateDraw(func() {
+			u.setInfo("%d agent(s) stopped with the tmux server, press A to resume", len(agents))
+		})
+	}()
+}
+
+// setBanner shows the health banner above the main panes, or removes it when
+// problems is empty.
+func (u *tuiState) setBanner(problems []HealthProblem) {
+	u.banner = nil
+	u.bannerHeight = 0
+	if len(problems) > 0 {
+		var b strings.Builder
+		fmt.Fprintf(&b, " %s[::b]%d configuration problem(s)[-::-] %s(esc to dismiss, sprout doctor for details)[-]", colorTag(ColorYellow), len(problems), colorTag(ColorGray))
+		for _, p := range problems {
+			fmt.Fprintf(&b, "\n %s![-] %s %s- %s[-]", colorTag(ColorYellow), tview.Escape(p.Message), colorTag(ColorGray), tview.Escape(p.Hint))
+		}
+		banner := tview.NewTextView().SetDynamicColors(true).SetWrap(false)
+		banner.SetBackgroundColor(tcell.ColorDefault)
+		banner.SetText(b.String())
+		u.banner = banner
+		u.bannerHeight = len(problems) + 1
 	}
+	u.layoutRoot()
 }
 
-func diffStatusColor(status string) tcell.Color {
-	s := strings.TrimSpace(status)
-	switch {
-	case strings.Contains(s, "D"):
-		return ansiColor(ansiRed)
-	case strings.Contains(s, "A"), s == "??":
-		return ansiColor(ansiGreen)
-	case strings.Contains(s, "R"), strings.Contains(s, "C"):
-		return ansiColor(ansiBlue)
-	case strings.Contains(s, "M"), strings.Contains(s, "U"):
-		return ansiColor(ansiYellow)
-	default:
-		return ansiColor(ansiCyan)
+// layoutRoot stacks the status pane, health banner, body, and footer. The
+// status pane and banner are left out while the agent output is fullscreen.
+func (u *tuiState) layoutRoot() {
+	u.root.Clear()
+	if !u.agentFullscreen {
+		u.root.AddItem(u.statusPane, 3, 0, false)
+		if u.banner != nil {
+			u.root.AddItem(u.banner, u.bannerHeight, 0, false)
+		}
 	}
+	u.layoutBodyRow()
+	u.root.AddItem(u.bodyRow, 0, 1, true)
+	u.root.AddItem(u.footer, 1, 0, false)
 }
 
-func (u *tuiState) renderDiffFileList() {
-	u.diffFiles.Clear()
-	headers := []string{"", "ST", "FILE"}
-	for col, h := range headers {
-		cell := tview.NewTableCell(h).
-			SetAttributes(tcell.AttrBold).
-			SetTextColor(ansiColor(ansiCyan)).
-			SetExpansion(1).
-			SetSelectable(false)
-		u.diffFiles.SetCell(0, col, cell)
+// repoSidebarWidth is the width of the repository sidebar, borders
+// included.
+const repoSidebarWidth = 30
+
+// layoutBodyRow shows the repository sidebar beside the body when it is
+// on, except while the agent output is fullscreen, and makes it a pane
+// tab cycles through.
+func (u *tuiState) layoutBodyRow() {
+	u.bodyRow.Clear()
+	u.focusables = []tview.Primitive{u.statusPane, u.detailPane, u.table}
+	if u.showRepoSidebar && !u.agentFullscreen {
+		u.bodyRow.AddItem(u.repoSidebar, repoSidebarWidth, 0, false)
+		u.focusables = append([]tview.Primitive{u.repoSidebar}, u.focusables...)
 	}
+	u.bodyRow.AddItem(u.body, 0, 1, true)
+}
 
-	if len(u.diffItems) == 0 {
-		u.diffFiles.SetCell(1, 0, tview.NewTableCell("").SetSelectable(false))
-		u.diffFiles.SetCell(1, 1, tview.NewTableCell("").SetSelectable(false))
-		u.diffFiles.SetCell(1, 2, tview.NewTableCell("(no changed files)").SetTextColor(ansiColor(ansiMagenta)).SetSelectable(false))
-		u.diffFiles.SetCounter("0 of 0")
-		u.diffFiles.SetOffset(0, 0)
+// toggleRepoSidebar shows or hides the repository sidebar and saves the
+// choice as repo_sidebar.
+func (u *tuiState) toggleRepoSidebar() {
+	u.showRepoSidebar = !u.showRepoSidebar
+	if !u.showRepoSidebar && u.app.GetFocus() == u.repoSidebar {
+		u.app.SetFocus(u.table)
+	}
+	u.layoutBodyRow()
+	u.updatePaneFocusStyles()
+	state := "hidden"
+	if u.showRepoSidebar {
+		state = "shown"
+		u.renderRepoSidebar()
+		// Count the other repositories' worktrees and agents.
+		u.refresh(nil)
+	}
+	if err := saveGlobalConfigValue("repo_sidebar", strconv.FormatBool(u.showRepoSidebar)); err != nil {
+		errorLogf("ui save repo_sidebar failed: %v", err)
+		u.setWarn("repo sidebar %s (not saved: %v)", state, err)
 		return
 	}
+	u.setInfo("repo sidebar %s", state)
+}
 
-	for i, f := range u.diffItems {
-		row := i + 1
-		selected := i == u.diffSel
-		marker := " "
-		if selected {
-			marker = ">"
+// renderRepoSidebar lists the repositories with their worktree and ready
+// agent counts, the current one marked.
+func (u *tuiState) renderRepoSidebar() {
+	if !u.showRepoSidebar {
+		return
+	}
+	// Keep the highlight on the repository it was on.
+	highlighted := u.repoRoot
+	if row, _ := u.repoSidebar.GetSelection(); row >= 0 && row < len(u.sidebarRepos) {
+		highlighted = u.sidebarRepos[row].Root
+	}
+	u.sidebarRepos = append(u.sidebarRepos[:0], u.repos...)
+	sort.SliceStable(u.sidebarRepos, func(i, j int) bool {
+		return repoChoiceLabel(u.sidebarRepos[i]) < repoChoiceLabel(u.sidebarRepos[j])
+	})
+	sel := 0
+	muted := ColorToTcell(ThemeColorMuted)
+	u.repoSidebar.Clear()
+	for row, repo := range u.sidebarRepos {
+		if repo.Root == highlighted {
+			sel = row
 		}
-		status := strings.TrimSpace(f.Status)
-		if status == "" {
-			status = "??"
+		worktrees, ready := repo.Worktrees, repo.Ready
+		mark := " "
+		name := tview.NewTableCell(truncate(repoChoiceLabel(repo), repoSidebarWidth-10)).SetExpansion(1)
+		if repo.Root == u.repoRoot {
+			mark = "*"
+			name.SetAttributes(tcell.AttrBold)
+			worktrees, ready = u.currentRepoCounts()
+		}
+		counts := tview.NewTableCell(fmt.Sprintf("%d", worktrees)).SetTextColor(muted).SetAlign(tview.AlignRight)
+		readyCell := tview.NewTableCell("").SetAlign(tview.AlignRight)
+		if ready > 0 {
+			readyCell.SetText(fmt.Sprintf("%d●", ready)).SetTextColor(ansiColor(ansiGreen))
 		}
+		u.repoSidebar.SetCell(row, 0, tview.NewTableCell(mark).SetTextColor(ansiColor(ansiGreen)))
+		u.repoSidebar.SetCell(row, 1, name)
+		u.repoSidebar.SetCell(row, 2, counts)
+		u.repoSidebar.SetCell(row, 3, readyCell)
+	}
+	if len(u.sidebarRepos) > 0 {
+		u.repoSidebar.Select(sel, 0)
+	}
+}
 
-		markerCell := tview.NewTableCell(marker).SetExpansion(1).SetTextColor(ansiColor(ansiCyan))
-		statusCell := tview.NewTableCell(status).SetExpansion(1).SetTextColor(diffStatusColor(status))
-		pathCell := tview.NewTableCell(truncatePath(f.Path, 80)).SetExpansion(1).SetTextColor(tcell.ColorDefault)
-		if selected {
-			markerCell.SetAttributes(tcell.AttrReverse)
-			statusCell.SetAttributes(tcell.AttrReverse)
-			pathCell.SetAttributes(tcell.AttrReverse)
+// currentRepoCounts counts the listed worktrees and the agents among them
+// the agent notifier last saw waiting for input.
+func (u *tuiState) currentRepoCounts() (worktrees, ready int) {
+	for _, item := range u.items {
+		if timer, ok := u.agentTimers[item.Path]; ok && timer.Status == agentStatusReady {
+			ready++
 		}
-		u.diffFiles.SetCell(row, 0, markerCell)
-		u.diffFiles.SetCell(row, 1, statusCell)
-		u.diffFiles.SetCell(row, 2, pathCell)
 	}
-	u.diffFiles.SetCounter(fmt.Sprintf("%d of %d", u.diffSel+1, len(u.diffItems)))
-	u.ensureDiffSelectionVisible()
+	return len(u.items), ready
 }
 
-func (u *tuiState) ensureDiffSelectionVisible() {
-	if len(u.diffItems) == 0 {
-		u.diffFiles.SetOffset(0, 0)
+// handleRepoSidebarKey moves through the repository sidebar and switches
+// to the highlighted repository on enter. Other keys work as elsewhere.
+func (u *tuiState) handleRepoSidebarKey(ev *tcell.EventKey) bool {
+	switch ev.Key() {
+	case tcell.KeyDown:
+		u.moveRepoSidebar(1)
+	case tcell.KeyUp:
+		u.moveRepoSidebar(-1)
+	case tcell.KeyEnter:
+		if row, _ := u.repoSidebar.GetSelection(); row >= 0 && row < len(u.sidebarRepos) {
+			u.switchRepo(u.sidebarRepos[row])
+		}
+	case tcell.KeyRune:
+		switch ev.Rune() {
+		case 'j':
+			u.moveRepoSidebar(1)
+		case 'k':
+			u.moveRepoSidebar(-1)
+		case 'g':
+			u.moveRepoSidebar(-len(u.sidebarRepos))
+		case 'G':
+			u.moveRepoSidebar(len(u.sidebarRepos))
+		default:
+			return false
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+func (u *tuiState) moveRepoSidebar(delta int) {
+	if len(u.sidebarRepos) == 0 {
 		return
 	}
-	_, _, _, h := u.diffFiles.GetInnerRect()
-	visibleRows := h - 1
-	if visibleRows < 1 {
-		visibleRows = 1
+	row, _ := u.repoSidebar.GetSelection()
+	row = max(0, min(len(u.sidebarRepos)-1, row+delta))
+	u.repoSidebar.Select(row, 0)
+}
+
+// startActivitySampler records agent output volume for the repo activity
+// heatmap while the UI is running.
+func (u *tuiState) startActivitySampler(interval time.Duration) func() {
+	done := make(chan struct{})
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		prev, err := u.manager().sampleAgentPanes()
+		if err != nil {
+			errorLogf("activity_sample failed: %v", err)
+		}
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			cur, err := u.manager().sampleAgentPanes()
+			if err != nil {
+				errorLogf("activity_sample failed: %v", err)
+				continue
+			}
+			if err := recordAgentActivity(agentOutputDeltas(prev, cur), time.Now()); err != nil {
+				errorLogf("activity_record failed: %v", err)
+			}
+			prev = cur
+		}
+	}()
+	return func() {
+		close(done)
 	}
-	maxOffset := len(u.diffItems) - visibleRows
-	if maxOffset < 0 {
-		maxOffset = 0
+}
+
+// startResourceSampler samples the CPU and memory of every tmux session in
+// the background for the resources column and the status pane.
+func (u *tuiState) startResourceSampler(interval time.Duration) func() {
+	done := make(chan struct{})
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		sampler := &resourceSampler{}
+		for {
+			usage, err := u.manager().sessionResources(sampler)
+			if err != nil {
+				errorLogf("resource_sample failed: %v", err)
+			} else {
+				u.app.QueueUpdateDraw(func() {
+					u.resources = usage
+					if u.showResources {
+						u.renderTable()
+					}
+					u.renderStatusPane()
+				})
+			}
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return func() {
+		close(done)
 	}
-	offset := u.diffSel - (visibleRows / 2)
-	if offset < 0 {
-		offset = 0
+}
+
+// worktreeResources returns the sampled usage of item's tmux session, or of
+// its own windows when the session is shared per repository.
+func (u *tuiState) worktreeResources(item *Worktree) (ResourceUsage, bool) {
+	key := u.mgr.tmuxWorktreeSessionName(u.repoRoot, item)
+	if u.mgr.perRepoSessions() {
+		key = absPath(item.Path)
 	}
-	if offset > maxOffset {
-		offset = maxOffset
+	usage, ok := u.resources[key]
+	return usage, ok
+}
+
+// cycleSort switches the worktree order between path, most recently active
+// first, and least recently active first, keeping the selection.
+func (u *tuiState) cycleSort() {
+	selected := ""
+	if item := u.selectedItem(); item != nil {
+		selected = item.Path
+	}
+	u.sortMode = nextWorktreeSort(u.sortMode)
+	u.applyFilter()
+	u.renderTable()
+	u.selectPath(selected)
+	switch u.sortMode {
+	case sortActive:
+		u.setInfo("sorted by last active, most recent first")
+	case sortIdle:
+		u.setInfo("sorted by last active, least recent first")
+	default:
+		u.setInfo("sorted by path")
+	}
+	if parseWorktreeFilter(u.filter).Query != "" {
+		u.setInfo("sort applies once the filter is cleared")
 	}
-	u.diffFiles.SetOffset(offset, 0)
 }
 
-func (u *tuiState) renderSelectedFileDiff() {
+func (u *tuiState) toggleResources() {
+	u.showResources = !u.showResources
+	u.renderTable()
+	if u.showResources {
+		u.setInfo("resource column shown")
+	} else {
+		u.setInfo("resource column hidden")
+	}
+}
+
+func (u *tuiState) startTodoScanner(interval time.Duration) func() {
+	done := make(chan struct{})
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		var last *todoScanRequest
+		for {
+			select {
+			case <-done:
+				return
+			case req := <-u.todoScan:
+				last = &req
+			case <-ticker.C:
+				if last == nil {
+					continue
+				}
+			}
+			results := map[string][]TodoMarker{}
+			items := append([]Worktree(nil), last.items...)
+			sortByPriority(items)
+			for i := range items {
+				wt := items[i]
+				markers, err := last.mgr.WorktreeTodos(last.repoRoot, &wt)
+				if err != nil {
+					errorLogf("todo_scan failed path=%q: %v", wt.Path, err)
+					continue
+				}
+				results[wt.Path] = markers
+			}
+			u.app.QueueUpdateDraw(func() {
+				u.todos = results
+				u.renderTable()
+				if u.detailTab == detailTabTodos {
+					u.renderDetails()
+				}
+			})
+		}
+	}()
+	return func() {
+		close(done)
+	}
+}
+
+// startConflictScanner checks, after every refresh and then every interval,
+// which branches would conflict when merged into the base branch.
+func (u *tuiState) startConflictScanner(interval time.Duration) func() {
+	done := make(chan struct{})
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		var last *todoScanRequest
+		for {
+			select {
+			case <-done:
+				return
+			case req := <-u.conflictScan:
+				last = &req
+			case <-ticker.C:
+				if last == nil {
+					continue
+				}
+			}
+			results := map[string][]string{}
+			items := append([]Worktree(nil), last.items...)
+			sortByPriority(items)
+			for i := range items {
+				wt := items[i]
+				files, err := last.mgr.BranchConflicts(last.repoRoot, &wt)
+				if err != nil {
+					debugLogf("conflict_scan failed path=%q: %v", wt.Path, err)
+					continue
+				}
+				if files == nil {
+					continue
+				}
+				results[wt.Path] = files
+			}
+			u.app.QueueUpdateDraw(func() {
+				u.conflicts = results
+				u.renderTable()
+				u.renderStatusPane()
+			})
+		}
+	}()
+	return func() {
+		close(done)
+	}
+}
+
+func (u *tuiState) renderAgentDetail() {
 	item := u.selectedItem()
 	if item == nil {
-		u.setDiffText("No worktree selected.", false)
+		u.setDetailText("Select a worktree to view agent output.", false)
 		return
 	}
-	if len(u.diffItems) == 0 || u.diffSel < 0 || u.diffSel >= len(u.diffItems) {
-		u.setDiffText("(working tree is clean)", false)
+
+	captureLines := u.detailCaptureLineCount()
+	if pane, ok := u.previewPane[item.Path]; ok {
+		out, err := u.mgr.paneOutput(pane.PaneID, captureLines)
+		if err == nil {
+			header := fmt.Sprintf("\x1b[36m# %s (W: next pane)\x1b[0m\n", previewPaneLabel(pane))
+			u.setDetailANSI(header+out, true)
+			return
+		}
+		// The pane is gone; fall back to the agent.
+		delete(u.previewPane, item.Path)
+	}
+	if item.AgentState == "crashed" {
+		// remain-on-exit keeps the dead pane, so its last output shows why.
+		u.setAgentPromptState(item, agentPromptUnknown)
+		out, _ := u.mgr.agentOutputForWorktree(u.repoRoot, item, captureLines)
+		u.setDetailANSI("\x1b[31m# agent crashed (R: restart)\x1b[0m\n"+out, true)
 		return
 	}
-	diff, err := u.cachedFileDiff(item.Path, u.diffItems[u.diffSel], u.detailDiffWidth())
-	if err != nil {
-		u.setDiffText(fmt.Sprintf("Unable to read file diff.\n\n%s", err), false)
+	if item.AgentState != "yes" {
+		u.setAgentPromptState(item, agentPromptUnknown)
+		u.setDetailText(
+			"Agent pane is not available for this worktree.\n\n"+
+				"Press enter on the worktree list to attach.\n"+
+				"A tmux session will open with your configured session tools.",
+			false,
+		)
 		return
 	}
-	u.setDiffANSI(diff, false)
-}
 
-func (u *tuiState) detailDiffWidth() int {
-	_, _, w, _ := u.diffView.GetInnerRect()
-	if w <= 0 {
-		return 0
+	u.syncDetailPaneSize(item)
+	paneTarget := u.mgr.agentPaneTarget(u.repoRoot, item)
+	var out string
+	activity, activityErr := u.mgr.agentPaneActivity(u.repoRoot, item)
+	if paneTarget != "" && activityErr == nil {
+		if last, ok := u.agentOutputActivity[paneTarget]; ok && last == activity {
+			if cached, ok := u.agentOutputCache[paneTarget]; ok {
+				out = cached
+			}
+		}
 	}
-	return w
+	if out == "" {
+		fetched, err := u.mgr.agentOutputForWorktree(u.repoRoot, item, captureLines)
+		if err != nil {
+			u.setAgentPromptState(item, agentPromptUnknown)
+			u.setDetailText(fmt.Sprintf("Unable to read agent output.\n\n%s", err), false)
+			return
+		}
+		out = fetched
+		if paneTarget != "" {
+			u.agentOutputCache[paneTarget] = out
+			if activityErr == nil {
+				u.agentOutputActivity[paneTarget] = activity
+			}
+		}
+	}
+	if strings.TrimSpace(out) == "" {
+		u.setAgentPromptState(item, agentPromptBusy)
+		out = "(agent pane is running, but no output yet)"
+	} else if agentReadyForInstruction(out) {
+		u.setAgentPromptState(item, agentPromptReady)
+	} else {
+		u.setAgentPromptState(item, agentPromptBusy)
+	}
+	u.setDetailANSI(out, true)
 }
 
-func (u *tuiState) syncDetailPaneSize(item *Worktree) {
+// cyclePreviewPane moves the AGENT OUTPUT tab of the selected worktree to the
+// next pane of its tmux session, wrapping around to the agent.
+func (u *tuiState) cyclePreviewPane() {
+	item := u.selectedItem()
 	if item == nil {
+		u.setWarn("nothing selected")
 		return
 	}
-	_, _, w, h := u.detail.GetInnerRect()
-	if w <= 0 || h <= 0 {
+	panes, agentID, err := u.mgr.previewPanes(u.repoRoot, item)
+	if err != nil {
+		u.setWarn("%v", err)
 		return
 	}
-	if w < 20 {
-		w = 20
-	}
-	if h < 4 {
-		h = 4
-	}
-
-	paneTarget := u.mgr.agentPaneTarget(u.repoRoot, item)
-	if paneTarget == "" {
+	if len(panes) == 0 {
+		u.setWarn("no panes to preview")
 		return
 	}
-
-	if last, ok := u.paneSizes[paneTarget]; ok && last.w == w && last.h == h {
-		return
+	next := 0
+	if current, ok := u.previewPane[item.Path]; ok {
+		for i, pane := range panes {
+			if pane.PaneID == current.PaneID {
+				next = (i + 1) % len(panes)
+				break
+			}
+		}
+	} else if agentID != "" {
+		next = 1 % len(panes)
+	}
+	pane := panes[next]
+	if pane.PaneID == agentID {
+		delete(u.previewPane, item.Path)
+	} else {
+		u.previewPane[item.Path] = pane
 	}
-	if err := tmuxResizePane(paneTarget, w, h); err != nil {
+	u.setDetailTab(detailTabAgent)
+	u.lastDetail = ""
+	u.renderDetails()
+	if pane.PaneID == agentID {
+		u.setInfo("showing the agent pane")
 		return
 	}
-	u.paneSizes[paneTarget] = paneSize{w: w, h: h}
+	u.setInfo("previewing %s", previewPaneLabel(pane))
 }
 
-func (u *tuiState) setDetailText(text string, follow bool) {
-	u.setDetailRenderedText(tview.Escape(text), follow)
+func previewPaneLabel(pane tmuxPaneInfo) string {
+	label := pane.WindowName + "." + pane.PaneIndex
+	if cmd := strings.TrimSpace(pane.CurrentCommand); cmd != "" {
+		label += " (" + cmd + ")"
+	}
+	return label
 }
 
-func (u *tuiState) setDetailANSI(text string, follow bool) {
-	u.setDetailRenderedText(tview.TranslateANSI(text), follow)
+func (u *tuiState) clearDiffCaches() {
+	u.mgr.invalidateWorktreeQueries()
+	u.commitPatches = map[string]string{}
+	u.lastDiff = ""
 }
 
-func (u *tuiState) setDetailRenderedText(text string, follow bool) {
-	if text == u.lastDetail {
-		return
-	}
-	row, col := u.detail.GetScrollOffset()
-	u.detail.SetText(text)
-	u.lastDetail = text
-	if u.app.GetFocus() == u.detail {
-		u.detail.ScrollTo(row, col)
-		return
-	}
-	if follow {
-		u.detail.ScrollToEnd()
-	} else {
-		u.detail.ScrollToBeginning()
+// cachedDiffFiles returns the files of item that differ from the selected
+// diff base, along with the revision the base resolved to.
+func (u *tuiState) cachedDiffFiles(item *Worktree) ([]DiffFile, string, error) {
+	query := strings.Join([]string{"diff_files", u.diffBase.Kind, u.diffBase.Ref}, "\x00")
+	res, err := cachedWorktreeQuery(u.mgr, u.repoRoot, item.Path, query, time.Duration(u.mgr.Cfg.DiffFilesCacheMS)*time.Millisecond, func() (diffFilesResult, error) {
+		rev, err := u.mgr.diffBaseRevision(u.repoRoot, item, u.diffBase)
+		if err != nil {
+			return diffFilesResult{}, err
+		}
+		files, err := u.mgr.WorktreeDiffFilesAgainst(item.Path, rev)
+		if err != nil {
+			return diffFilesResult{}, err
+		}
+		return diffFilesResult{files: files, rev: rev}, nil
+	})
+	if err != nil {
+		return nil, "", err
 	}
+	return res.files, res.rev, nil
 }
 
-func (u *tuiState) setDiffText(text string, keepScroll bool) {
-	u.setDiffRenderedText(tview.Escape(text), keepScroll)
+func diffPatchCacheKey(rev string, file DiffFile, width int) string {
+	return strings.Join([]string{
+		"diff_patch",
+		rev,
+		file.Path,
+		file.Status,
+		strconv.Itoa(width),
+	}, "\x00")
 }
 
-func (u *tuiState) setDiffANSI(text string, keepScroll bool) {
-	u.setDiffRenderedText(tview.TranslateANSI(text), keepScroll)
+func (u *tuiState) cachedFileDiff(path, rev string, file DiffFile, width int) (string, error) {
+	return cachedWorktreeQuery(u.mgr, u.repoRoot, path, diffPatchCacheKey(rev, file, width), time.Duration(u.mgr.Cfg.DiffPatchCacheMS)*time.Millisecond, func() (string, error) {
+		return u.mgr.WorktreeDiffForFileAgainst(path, rev, file, width)
+	})
 }
 
-func (u *tuiState) setDiffRenderedText(text string, keepScroll bool) {
-	if text == u.lastDiff {
+func (u *tuiState) renderDiffDetail() {
+	item := u.selectedItem()
+	if item == nil {
+		u.diffItems = nil
+		u.diffSel = 0
+		u.diffPath = ""
+		u.renderDiffFileList()
+		u.setDiffText("Select a worktree to view git diff.", false)
 		return
 	}
-	row, col := u.diffView.GetScrollOffset()
-	u.diffView.SetText(text)
-	u.lastDiff = text
-	if keepScroll {
-		u.diffView.ScrollTo(row, col)
+	u.renderDiffTitle()
+	files, rev, err := u.cachedDiffFiles(item)
+	if err != nil {
+		u.diffItems = nil
+		u.diffSel = 0
+		u.diffPath = item.Path
+		u.renderDiffFileList()
+		u.setDiffText(fmt.Sprintf("Unable to read git diff.\n\n%s", err), false)
 		return
 	}
-	u.diffView.ScrollToBeginning()
+	u.diffRev = rev
+	u.syncDiffFiles(item.Path, files)
+	u.renderDiffFileList()
+	if len(u.diffItems) == 0 {
+		u.setDiffText(u.noDiffText(), false)
+		return
+	}
+	u.renderSelectedFileDiff()
 }
 
-func (u *tuiState) detailCaptureLineCount() int {
-	_, _, _, h := u.detail.GetInnerRect()
-	if h <= 0 {
-		return detailCaptureLines
+func (u *tuiState) noDiffText() string {
+	if u.diffBase.Kind == "" || u.diffBase.Kind == diffBaseWorking {
+		return "(working tree is clean)"
 	}
-	lines := h + 6
-	if lines > detailCaptureLines {
-		lines = detailCaptureLines
-	}
-	if lines < 20 {
-		lines = 20
-	}
-	return lines
+	return fmt.Sprintf("(no changes against %s)", u.diffBase.Label())
 }
 
-func (u *tuiState) scrollTextView(view *tview.TextView, delta int) {
-	if view == nil {
-		return
-	}
-	row, col := view.GetScrollOffset()
-	next := row + delta
-	if next < 0 {
-		next = 0
+// renderDiffTitle names the diff base in the patch title when it is not the
+// working tree.
+func (u *tuiState) renderDiffTitle() {
+	title := "Patch"
+	if u.diffPreview {
+		title = "Preview"
+	} else if u.diffBase.Kind != "" && u.diffBase.Kind != diffBaseWorking {
+		title = "Patch vs " + u.diffBase.Label()
 	}
-	view.ScrollTo(next, col)
+	u.diffView.SetTitle(title)
 }
 
-func (u *tuiState) worktreeGraphic(selectedPath string) string {
-	if len(u.items) == 0 {
-		return lipgloss.NewStyle().Foreground(ColorPurple).Render("(no worktrees)")
+func (u *tuiState) syncDiffFiles(path string, files []DiffFile) {
+	switchedWorktree := path != u.diffPath
+	prev := ""
+	if !switchedWorktree && u.diffSel >= 0 && u.diffSel < len(u.diffItems) {
+		prev = u.diffItems[u.diffSel].Path
 	}
 
-	ordered := append([]Worktree(nil), u.items...)
-	sort.SliceStable(ordered, func(i, j int) bool {
-		if ordered[i].Current != ordered[j].Current {
-			return ordered[i].Current
-		}
-		if ordered[i].Branch == ordered[j].Branch {
-			return ordered[i].Path < ordered[j].Path
-		}
-		return ordered[i].Branch < ordered[j].Branch
-	})
+	u.diffPath = path
+	u.diffItems = files
 
-	repoLabel := u.repoName
-	if strings.TrimSpace(u.repoSlug) != "" {
-		repoLabel = u.repoSlug
+	if len(u.diffItems) == 0 {
+		u.diffSel = 0
+		return
 	}
 
-	lines := []string{
-		lipgloss.NewStyle().Bold(true).Foreground(ColorGreen).Render(repoLabel),
-		lipgloss.NewStyle().Foreground(ColorCyan).Render("│"),
+	if switchedWorktree {
+		u.diffSel = 0
 	}
-
-	for i, wt := range ordered {
-		branch := wt.Branch
-		if branch == "" {
-			branch = "detached"
-		}
-
-		arm := lipgloss.NewStyle().Foreground(ColorCyan).Render("├─")
-		stem := lipgloss.NewStyle().Foreground(ColorCyan).Render("│ ")
-		if i == len(ordered)-1 {
-			arm = lipgloss.NewStyle().Foreground(ColorCyan).Render("└─")
-			stem = "  "
+	if prev != "" {
+		for i := range u.diffItems {
+			if u.diffItems[i].Path == prev {
+				u.diffSel = i
+				break
+			}
 		}
+	}
+	if u.diffSel < 0 {
+		u.diffSel = 0
+	}
+	if u.diffSel >= len(u.diffItems) {
+		u.diffSel = len(u.diffItems) - 1
+	}
+}
 
-		marker := "○"
-		markerColor := ColorCyan
-		if wt.Current {
-			marker = "●"
-			markerColor = ColorGreen
-		}
-		if wt.Path == selectedPath {
-			marker = "◆"
-			markerColor = ColorBlue
-		}
+func diffStatusColor(status string) tcell.Color {
+	s := strings.TrimSpace(status)
+	switch {
+	case strings.Contains(s, "D"):
+		return ansiColor(ansiRed)
+	case strings.Contains(s, "A"), s == "??":
+		return ansiColor(ansiGreen)
+	case strings.Contains(s, "R"), strings.Contains(s, "C"):
+		return ansiColor(ansiBlue)
+	case strings.Contains(s, "M"), strings.Contains(s, "U"):
+		return ansiColor(ansiYellow)
+	default:
+		return ansiColor(ansiCyan)
+	}
+}
 
-		branchColor := ColorCyan
-		if wt.Dirty {
-			branchColor = ColorRed
-		} else if wt.Current {
-			branchColor = ColorGreen
-		}
+func (u *tuiState) renderDiffFileList() {
+	u.diffFiles.Clear()
+	headers := []string{"", "ST", "FILE"}
+	for col, h := range headers {
+		cell := tview.NewTableCell(h).
+			SetAttributes(tcell.AttrBold).
+			SetTextColor(ansiColor(ansiCyan)).
+			SetExpansion(1).
+			SetSelectable(false)
+		u.diffFiles.SetCell(0, col, cell)
+	}
 
-		state := "clean"
-		stateColor := ColorGreen
-		if wt.Dirty {
-			state = "dirty"
-			stateColor = ColorRed
-		}
+	if len(u.diffItems) == 0 {
+		u.diffFiles.SetCell(1, 0, tview.NewTableCell("").SetSelectable(false))
+		u.diffFiles.SetCell(1, 1, tview.NewTableCell("").SetSelectable(false))
+		u.diffFiles.SetCell(1, 2, tview.NewTableCell("(no changed files)").SetTextColor(ansiColor(ansiMagenta)).SetSelectable(false))
+		u.diffFiles.SetCounter("0 of 0")
+		u.diffFiles.SetOffset(0, 0)
+		return
+	}
 
-		tmuxState := lipgloss.NewStyle().Foreground(ColorCyan).Render("·")
-		switch wt.TmuxState {
-		case "yes":
-			tmuxState = lipgloss.NewStyle().Foreground(ColorGreen).Render("●")
-		case "no":
-			tmuxState = lipgloss.NewStyle().Foreground(ColorRed).Render("○")
+	for i, f := range u.diffItems {
+		row := i + 1
+		selected := i == u.diffSel
+		marker := " "
+		if selected {
+			marker = ">"
 		}
-
-		agentState := lipgloss.NewStyle().Foreground(ColorCyan).Render("·")
-		switch wt.AgentState {
-		case "yes":
-			agentState = lipgloss.NewStyle().Foreground(ColorGreen).Render("●")
-		case "no":
-			agentState = lipgloss.NewStyle().Foreground(ColorRed).Render("○")
+		status := strings.TrimSpace(f.Status)
+		if status == "" {
+			status = "??"
 		}
 
-		branchText := lipgloss.NewStyle().Bold(true).Foreground(branchColor).Render(truncate(branch, 42))
-		stateText := lipgloss.NewStyle().Foreground(stateColor).Render("(" + state + ")")
-		markerText := lipgloss.NewStyle().Foreground(markerColor).Render(marker)
-
-		line := fmt.Sprintf(
-			"%s%s %s %s tmux:%s agent:%s",
-			arm, markerText, branchText, stateText, tmuxState, agentState,
-		)
-		lines = append(lines, line)
-
-		pathColor := ColorPurple
-		if wt.Path == selectedPath {
-			pathColor = ColorBlue
+		markerCell := tview.NewTableCell(marker).SetExpansion(1).SetTextColor(ansiColor(ansiCyan))
+		statusCell := tview.NewTableCell(status).SetExpansion(1).SetTextColor(diffStatusColor(status))
+		pathCell := tview.NewTableCell(truncatePath(f.Path, 80)).SetExpansion(1).SetTextColor(tcell.ColorDefault)
+		if selected {
+			markerCell.SetAttributes(tcell.AttrReverse)
+			statusCell.SetAttributes(tcell.AttrReverse)
+			pathCell.SetAttributes(tcell.AttrReverse)
 		}
-		pathArm := lipgloss.NewStyle().Foreground(ColorCyan).Render("└─")
-		pathText := lipgloss.NewStyle().Foreground(pathColor).Render(truncatePath(wt.Path, 74))
-		lines = append(lines, fmt.Sprintf("%s%s %s", stem, pathArm, pathText))
+		u.diffFiles.SetCell(row, 0, markerCell)
+		u.diffFiles.SetCell(row, 1, statusCell)
+		u.diffFiles.SetCell(row, 2, pathCell)
 	}
-
-	return tview.TranslateANSI(strings.Join(lines, "\n"))
-}
-
-func (u *tuiState) setStatus(format string, args ...any) {
-	u.renderFooter("STATUS", fmt.Sprintf(format, args...))
-}
-
-func (u *tuiState) setInfo(format string, args ...any) {
-	u.renderFooter("INFO", fmt.Sprintf(format, args...))
-}
-
-func (u *tuiState) setWarn(format string, args ...any) {
-	u.renderFooter("WARN", fmt.Sprintf(format, args...))
+	u.diffFiles.SetCounter(fmt.Sprintf("%d of %d", u.diffSel+1, len(u.diffItems)))
+	u.ensureDiffSelectionVisible()
 }
 
-func (u *tuiState) setError(format string, args ...any) {
-	u.renderFooter("ERROR", fmt.Sprintf(format, args...))
+func (u *tuiState) ensureDiffSelectionVisible() {
+	u.centerFilesRow(u.diffSel, len(u.diffItems))
 }
 
-func (u *tuiState) footerKeymap() string {
-	base := "[::b]tab[::-] pane | [::b]r[::-] refresh | [::b]?[::-] help | [::b]q[::-] quit"
-	focus := u.app.GetFocus()
-	inDetail := focus == u.detailPane || focus == u.detail || focus == u.diffFiles || focus == u.diffView
-
-	switch {
-	case focus == u.statusPane:
-		return "[::b]enter[::-] repos | " + base
-	case focus == u.table:
-		return "[::b]j/k[::-] move | [::b]enter[::-] attach | [::b]d[::-] detach | [::b]n[::-] new | [::b]x[::-] remove | [::b]/[::-] filter | " + base
-	case inDetail:
-		if u.detailTab == detailTabDiff {
-			return "[::b]j/k[::-] files | [::b]J/K[::-] patch scroll | [::b]h/l[::-] tab | " + base
-		}
-		return "[::b]j/k/pgup/pgdn[::-] scroll | [::b]h/l/[[/]][::-] tab | " + base
-	default:
-		return "[::b]tab[::-] cycle modal focus | [::b]esc[::-] close modal"
+// centerFilesRow scrolls the list beside the patch view so that row sel of
+// count rows sits in the middle.
+func (u *tuiState) centerFilesRow(sel, count int) {
+	if count == 0 {
+		u.diffFiles.SetOffset(0, 0)
+		return
 	}
-}
-
-func (u *tuiState) renderFooter(level, message string) {
-	if strings.TrimSpace(level) == "" {
-		level = "INFO"
+	_, _, _, h := u.diffFiles.GetInnerRect()
+	visibleRows := h - 1
+	if visibleRows < 1 {
+		visibleRows = 1
 	}
-	if strings.TrimSpace(message) == "" {
-		message = "ready"
+	maxOffset := count - visibleRows
+	if maxOffset < 0 {
+		maxOffset = 0
 	}
-	u.footerLevel = level
-	u.footerMsg = message
-	u.redrawFooter()
+	offset := sel - (visibleRows / 2)
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > maxOffset {
+		offset = maxOffset
+	}
+	u.diffFiles.SetOffset(offset, 0)
 }
 
-func (u *tuiState) redrawFooter() {
-	level := u.footerLevel
-	if strings.TrimSpace(level) == "" {
-		level = "INFO"
+func (u *tuiState) renderSelectedFileDiff() {
+	item := u.selectedItem()
+	if item == nil {
+		u.setDiffText("No worktree selected.", false)
+		return
 	}
-	message := u.footerMsg
-	if strings.TrimSpace(message) == "" {
-		message = "ready"
+	if len(u.diffItems) == 0 || u.diffSel < 0 || u.diffSel >= len(u.diffItems) {
+		u.setDiffText(u.noDiffText(), false)
+		return
 	}
-	levelColor := ColorCyan
-	switch level {
-	case "ERROR":
-		levelColor = ColorRed
-	case "WARN":
-		levelColor = ColorPurple
-	case "INFO":
-		levelColor = ColorBlue
+	if u.diffPreview {
+		u.renderSelectedFilePreview(item)
+		return
 	}
-
-	keymapStyle := lipgloss.NewStyle().Foreground(ColorGray)
-	levelStyle := lipgloss.NewStyle().Foreground(levelColor).Bold(true)
-	msgStyle := lipgloss.NewStyle()
-	versionStyle := lipgloss.NewStyle().Foreground(ColorCyan)
-
-	left := fmt.Sprintf("╰─ %s  %s: %s",
-		keymapStyle.Render(u.footerKeymap()),
-		levelStyle.Render(level),
-		msgStyle.Render(message),
-	)
-	right := fmt.Sprintf("─ %s ╯", versionStyle.Render("v"+Version))
-
-	u.footerLeft.SetText(tview.TranslateANSI(left))
-	u.footerRight.SetText(tview.TranslateANSI(right))
+	diff, err := u.cachedFileDiff(item.Path, u.diffRev, u.diffItems[u.diffSel], u.detailDiffWidth())
+	if err != nil {
+		u.setDiffText(fmt.Sprintf("Unable to read file diff.\n\n%s", err), false)
+		return
+	}
+	u.setDiffANSI(diff, false)
 }
 
-func (u *tuiState) showModal(name string, p tview.Primitive, width, height int) {
-	u.pages.AddPage(name, centered(width, height, p), true, true)
-	u.app.SetFocus(p)
-	u.updatePaneFocusStyles()
+// renderSelectedFilePreview shows what the selected file holds now in the
+// patch view.
+func (u *tuiState) renderSelectedFilePreview(item *Worktree) {
+	file := u.diffItems[u.diffSel]
+	key := strings.Join([]string{"file_preview", file.Path, file.Status}, "\x00")
+	preview, err := cachedWorktreeQuery(u.mgr, u.repoRoot, item.Path, key, time.Duration(u.mgr.Cfg.DiffPatchCacheMS)*time.Millisecond, func() (string, error) {
+		return u.mgr.WorktreeFilePreview(item.Path, file)
+	})
+	if err != nil {
+		u.setDiffText(fmt.Sprintf("Unable to preview file.\n\n%s", err), false)
+		return
+	}
+	u.setDiffANSI(preview, false)
 }
 
-func (u *tuiState) closeModal(name string) {
-	u.pages.RemovePage(name)
-	u.app.SetFocus(u.table)
-	u.updatePaneFocusStyles()
+// toggleDiffPreview switches the patch view between the selected file's
+// patch and a preview of its content.
+func (u *tuiState) toggleDiffPreview() {
+	u.diffPreview = !u.diffPreview
+	u.lastDiff = ""
+	u.renderDiffDetail()
+	if u.diffPreview {
+		u.setInfo("previewing files (v for patches)")
+	} else {
+		u.setInfo("showing patches")
+	}
 }
 
-func formatByteSize(n int64) string {
-	if n < 1024 {
-		return fmt.Sprintf("%d B", n)
+func (u *tuiState) renderLogDetail() {
+	item := u.selectedItem()
+	if item == nil {
+		u.syncLogCommits("", nil)
+		u.renderLogList()
+		u.diffView.SetTitle("Patch")
+		u.setDiffText("Select a worktree to view its commits.", false)
+		return
 	}
-	units := []string{"B", "KB", "MB", "GB", "TB"}
-	v := float64(n)
-	uIdx := 0
-	for v >= 1024 && uIdx < len(units)-1 {
-		v /= 1024
-		uIdx++
+	commits, err := u.cachedLog(item)
+	if err != nil {
+		u.syncLogCommits(item.Path, nil)
+		u.renderLogList()
+		u.diffView.SetTitle("Patch")
+		u.setDiffText(fmt.Sprintf("Unable to read git log.\n\n%s", err), false)
+		return
 	}
-	return fmt.Sprintf("%.1f %s", v, units[uIdx])
+	u.syncLogCommits(item.Path, commits)
+	u.renderLogList()
+	u.renderOpenCommit()
 }
 
-func (u *tuiState) showProgressModal(name, title string, totalSteps int) (func(string), func(string), func(float64), func()) {
-	const barWidth = 44
-	const modalWidth = 64
-
-	titleView := tview.NewTextView().SetDynamicColors(true).SetWrap(false)
-	titleView.SetBackgroundColor(tcell.ColorDefault)
-	titleStyle := lipgloss.NewStyle().Foreground(ThemeColorPrimary).Bold(true)
-	titleView.SetText(tview.TranslateANSI(" " + titleStyle.Render(strings.TrimSpace(title))))
-
-	stepView := tview.NewTextView().SetDynamicColors(true).SetWrap(false)
-	stepView.SetBackgroundColor(tcell.ColorDefault)
-	stepView.SetTextColor(tcell.ColorDefault)
-
-	barView := tview.NewTextView().SetDynamicColors(true).SetWrap(false)
-	barView.SetBackgroundColor(tcell.ColorDefault)
-
-	layout := tview.NewFlex().
-		SetDirection(tview.FlexRow).
-		AddItem(titleView, 1, 0, false).
-		AddItem(nil, 1, 0, false).
-		AddItem(stepView, 1, 0, false).
-		AddItem(barView, 1, 0, false)
-	layout.SetBackgroundColor(tcell.ColorDefault)
-
-	u.showModal(name, layout, modalWidth, 7)
-	u.app.SetFocus(layout)
+func (u *tuiState) cachedLog(item *Worktree) ([]LogCommit, error) {
+	return cachedWorktreeQuery(u.mgr, u.repoRoot, item.Path, "log", logCacheTTL, func() ([]LogCommit, error) {
+		return u.mgr.WorktreeLog(u.repoRoot, item)
+	})
+}
 
-	spinChars := []string{"|", "/", "-", "\\"}
+// syncLogCommits keeps the highlighted and open commits across refreshes of
+// the same worktree and resets them when the worktree changes.
+func (u *tuiState) syncLogCommits(path string, commits []LogCommit) {
+	prev := ""
+	if path == u.logPath && u.logSel >= 0 && u.logSel < len(u.logItems) {
+		prev = u.logItems[u.logSel].Hash
+	}
+	if path != u.logPath {
+		u.logOpen = ""
+	}
+	u.logPath = path
+	u.logItems = commits
+	u.logSel = 0
+	for i, c := range commits {
+		if c.Hash == prev {
+			u.logSel = i
+			break
+		}
+	}
+}
 
-	var mu sync.Mutex
-	var step int
-	var stepProgress float64
-	label := "Working..."
-	var frame int
+func (u *tuiState) renderLogList() {
+	u.diffFiles.Clear()
+	headers := []string{"", "HASH", "AUTHOR", "AGE", "SUBJECT"}
+	for col, h := range headers {
+		cell := tview.NewTableCell(h).
+			SetAttributes(tcell.AttrBold).
+			SetTextColor(ansiColor(ansiCyan)).
+			SetSelectable(false)
+		u.diffFiles.SetCell(0, col, cell)
+	}
 
-	render := func() {
-		mu.Lock()
-		s, sp, l, f := step, stepProgress, label, frame
-		mu.Unlock()
+	if len(u.logItems) == 0 {
+		u.diffFiles.SetCell(1, 4, tview.NewTableCell("(no commits on this branch)").SetTextColor(ansiColor(ansiMagenta)).SetSelectable(false))
+		u.diffFiles.SetCounter("0 of 0")
+		u.diffFiles.SetOffset(0, 0)
+		return
+	}
 
-		pct := 0.0
-		if totalSteps > 0 {
-			if sp < 0 {
-				sp = 0
-			}
-			if sp > 1 {
-				sp = 1
-			}
-			if s > 0 {
-				base := float64(s - 1)
-				pct = (base + sp) / float64(totalSteps)
-			}
-			if pct > 1.0 {
-				pct = 1.0
-			}
+	now := time.Now()
+	for i, c := range u.logItems {
+		row := i + 1
+		marker := " "
+		if c.Hash == u.logOpen {
+			marker = "●"
 		}
-
-		filled := int(float64(barWidth) * pct)
-		if filled > barWidth {
-			filled = barWidth
+		if i == u.logSel {
+			marker = ">"
 		}
-		empty := barWidth - filled
-
-		spin := spinChars[f%len(spinChars)]
-		spinStyle := lipgloss.NewStyle().Foreground(ThemeColorPrimary)
-		stepView.SetText(tview.TranslateANSI(fmt.Sprintf(" %s %s", spinStyle.Render(spin), l)))
-
-		filledStyle := lipgloss.NewStyle().Foreground(ThemeColorPrimary)
-		emptyStyle := lipgloss.NewStyle().Foreground(ColorGray)
-		pctStyle := lipgloss.NewStyle().Foreground(ThemeColorPrimary).Bold(true)
-
-		var pctText string
-		if s == 0 {
-			pctText = pctStyle.Render("  --%")
-		} else {
-			pctText = pctStyle.Render(fmt.Sprintf(" %3d%%", int(pct*100)))
+		cells := []*tview.TableCell{
+			tview.NewTableCell(marker).SetTextColor(ansiColor(ansiCyan)),
+			tview.NewTableCell(c.ShortHash).SetTextColor(ansiColor(ansiYellow)),
+			tview.NewTableCell(tview.Escape(truncate(c.Author, 16))).SetTextColor(ansiColor(ansiBlue)),
+			tview.NewTableCell(formatAge(c.Date, now)).SetTextColor(ansiColor(ansiMagenta)),
+			tview.NewTableCell(tview.Escape(c.Subject)).SetTextColor(tcell.ColorDefault).SetExpansion(1),
 		}
-
-		barText := " " +
-			filledStyle.Render(strings.Repeat("█", filled)) +
-			emptyStyle.Render(strings.Repeat("░", empty)) +
-			pctText
-		barView.SetText(tview.TranslateANSI(barText))
-	}
-	render()
-
-	done := make(chan struct{})
-	go func() {
-		ticker := time.NewTicker(120 * time.Millisecond)
-		defer ticker.Stop()
-		for {
-			select {
-			case <-done:
-				return
-			case <-ticker.C:
-				mu.Lock()
-				frame++
-				mu.Unlock()
-				u.app.QueueUpdateDraw(func() {
-					render()
-				})
+		for col, cell := range cells {
+			if i == u.logSel {
+				cell.SetAttributes(tcell.AttrReverse)
 			}
+			u.diffFiles.SetCell(row, col, cell)
 		}
-	}()
+	}
+	u.diffFiles.SetCounter(fmt.Sprintf("%d of %d", u.logSel+1, len(u.logItems)))
+	u.centerFilesRow(u.logSel, len(u.logItems))
+}
 
-	advance := func(next string) {
-		mu.Lock()
-		step++
-		stepProgress = 0
-		if strings.TrimSpace(next) != "" {
-			label = strings.TrimSpace(next)
-		}
-		mu.Unlock()
-		u.app.QueueUpdateDraw(func() {
-			render()
-		})
+// renderOpenCommit shows the patch of the commit opened with enter.
+func (u *tuiState) renderOpenCommit() {
+	if len(u.logItems) == 0 {
+		u.diffView.SetTitle("Patch")
+		u.setDiffText("(no commits on this branch since the base branch)", false)
+		return
 	}
-	setLabel := func(next string) {
-		mu.Lock()
-		if strings.TrimSpace(next) != "" {
-			label = strings.TrimSpace(next)
+	if u.logOpen == "" {
+		u.diffView.SetTitle("Patch")
+		u.setDiffText("Press enter to show the selected commit's patch.", false)
+		return
+	}
+	short := u.logOpen
+	for _, c := range u.logItems {
+		if c.Hash == u.logOpen {
+			short = c.ShortHash
+			break
 		}
-		mu.Unlock()
-		u.app.QueueUpdateDraw(func() {
-			render()
-		})
 	}
-	setStepProgress := func(progress float64) {
-		mu.Lock()
-		if progress < 0 {
-			progress = 0
+	u.diffView.SetTitle("Patch of " + short)
+	width := u.detailDiffWidth()
+	key := u.logOpen + "\x00" + strconv.Itoa(width)
+	text, ok := u.commitPatches[key]
+	if !ok {
+		var err error
+		text, err = u.mgr.CommitPatch(u.logPath, u.logOpen, width)
+		if err != nil {
+			u.setDiffText(fmt.Sprintf("Unable to read commit %s.\n\n%s", short, err), false)
+			return
 		}
-		if progress > 1 {
-			progress = 1
+		if len(u.commitPatches) > 64 {
+			u.commitPatches = map[string]string{}
 		}
-		stepProgress = progress
-		mu.Unlock()
-		u.app.QueueUpdateDraw(func() {
-			render()
-		})
-	}
-	stop := func() {
-		close(done)
+		u.commitPatches[key] = text
 	}
-	return advance, setLabel, setStepProgress, stop
+	u.setDiffANSI(text, false)
 }
 
-func centered(width, height int, p tview.Primitive) tview.Primitive {
-	return tview.NewFlex().
-		AddItem(nil, 0, 1, false).
-		AddItem(
-			tview.NewFlex().
-				SetDirection(tview.FlexRow).
-				AddItem(nil, 0, 1, false).
-				AddItem(p, height, 1, true).
-				AddItem(nil, 0, 1, false),
-			width, 1, true,
-		).
-		AddItem(nil, 0, 1, false)
+func (u *tuiState) detailDiffWidth() int {
+	_, _, w, _ := u.diffView.GetInnerRect()
+	if w <= 0 {
+		return 0
+	}
+	return w
 }
 
-func styleModalInputField(field *tview.InputField) {
-	field.
-		SetLabel("").
-		SetFieldTextColor(tcell.ColorDefault).
-		SetFieldBackgroundColor(tcell.ColorDefault).
-		SetBackgroundColor(tcell.ColorDefault)
-}
+func (u *tuiState) syncDetailPaneSize(item *Worktree) {
+	if item == nil {
+		return
+	}
+	_, _, w, h := u.detail.GetInnerRect()
+	if w <= 0 || h <= 0 {
+		return
+	}
+	if w < 20 {
+		w = 20
+	}
+	if h < 4 {
+		h = 4
+	}
 
-func styleModalCheckbox(field *tview.Checkbox) {
-	field.
-		SetLabelColor(ansiColor(ansiCyan)).
-		SetFieldBackgroundColor(tcell.ColorDefault).
-		SetFieldTextColor(tcell.ColorDefault).
-		SetBackgroundColor(tcell.ColorDefault)
+	paneTarget := u.mgr.agentPaneTarget(u.repoRoot, item)
+	if paneTarget == "" {
+		return
+	}
+
+	if last, ok := u.paneSizes[paneTarget]; ok && last.w == w && last.h == h {
+		return
+	}
+	if err := u.mgr.tmuxResizePane(paneTarget, w, h); err != nil {
+		return
+	}
+	u.paneSizes[paneTarget] = paneSize{w: w, h: h}
 }
 
-func styleModalDropDown(field *tview.DropDown) {
-	base := tcell.StyleDefault.Foreground(tcell.ColorDefault).Background(tcell.ColorDefault)
-	focus := base.Reverse(true)
-	field.
-		SetLabel("").
-		SetLabelColor(ansiColor(ansiCyan)).
-		SetFieldTextColor(tcell.ColorDefault).
-		SetFieldBackgroundColor(tcell.ColorDefault).
-		SetFieldStyle(base).
-		SetFocusedStyle(focus).
-		SetListStyles(base, focus).
-		SetTextOptions("", "", "> ", "", "(select)").
-		SetBackgroundColor(tcell.ColorDefault)
+func (u *tuiState) setDetailText(text string, follow bool) {
+	u.setDetailRenderedText(tview.Escape(text), follow)
 }
 
-func modalHeader(title string) *tview.TextView {
-	header := tview.NewTextView().
-		SetDynamicColors(true).
-		SetWrap(false)
-	header.SetBackgroundColor(tcell.ColorDefault)
-	header.SetTextColor(paneBorderColor())
-	header.SetText(" " + title)
-	return header
+func (u *tuiState) setDetailANSI(text string, follow bool) {
+	if u.detailWrap[u.detailTab] {
+		_, _, w, _ := u.detail.GetInnerRect()
+		text = wrapANSI(text, w)
+	}
+	u.setDetailRenderedText(translateANSI(text), follow)
 }
 
-func modalFieldBox(title string, inner tview.Primitive) *tview.Flex {
-	box := tview.NewFlex().SetDirection(tview.FlexRow)
-	box.AddItem(inner, 1, 1, false)
-	box.SetBackgroundColor(tcell.ColorDefault)
-	box.SetBorder(true)
-	box.SetBorderColor(paneBorderColor())
-	box.SetTitle(" " + title + " ")
-	box.SetTitleColor(ansiColor(ansiCyan))
-	return box
+func (u *tuiState) setDetailRenderedText(text string, follow bool) {
+	if text == u.lastDetail {
+		return
+	}
+	row, col := u.detail.GetScrollOffset()
+	u.detail.SetText(text)
+	u.lastDetail = text
+	if u.app.GetFocus() == u.detail {
+		u.detail.ScrollTo(row, col)
+		return
+	}
+	if follow {
+		u.detail.ScrollToEnd()
+	} else {
+		u.detail.ScrollToBeginning()
+	}
 }
 
-func modalButton(label string, selected func()) *tview.Button {
-	btn := tview.NewButton(label).SetSelectedFunc(selected)
-	btn.SetLabelColor(tcell.ColorDefault)
-	btn.SetLabelColorActivated(ansiColor(ansiCyan))
-	btn.SetBackgroundColor(tcell.ColorDefault)
-	btn.SetBackgroundColorActivated(tcell.ColorDefault)
-	return btn
+func (u *tuiState) setDiffText(text string, keepScroll bool) {
+	u.setDiffRenderedText(tview.Escape(text), keepScroll)
 }
 
-func setPrimitiveInputCapture(p tview.Primitive, capture func(ev *tcell.EventKey) *tcell.EventKey) {
-	switch v := p.(type) {
-	case *tview.InputField:
-		v.SetInputCapture(capture)
-	case *tview.DropDown:
-		v.SetInputCapture(capture)
-	case *tview.Checkbox:
-		v.SetInputCapture(capture)
-	case *tview.Button:
-		v.SetInputCapture(capture)
-	case *tview.Table:
-		v.SetInputCapture(capture)
+func (u *tuiState) setDiffANSI(text string, keepScroll bool) {
+	if u.detailWrap[u.detailTab] {
+		text = wrapANSI(text, u.detailDiffWidth())
 	}
+	u.setDiffRenderedText(translateANSI(text), keepScroll)
 }
 
-func cycleModalFocus(app *tview.Application, focusables []tview.Primitive, delta int) {
-	if len(focusables) == 0 {
+// toggleDetailWrap soft-wraps the agent output or patch of the current tab,
+// or clips it again. Each tab remembers its own setting.
+func (u *tuiState) toggleDetailWrap() {
+	if !u.detailTab.wraps() {
 		return
 	}
-	cur := app.GetFocus()
-	idx := 0
-	for i, f := range focusables {
-		if cur == f {
-			idx = i
-			break
-		}
+	wrap := !u.detailWrap[u.detailTab]
+	u.detailWrap[u.detailTab] = wrap
+	u.lastDetail = ""
+	u.lastDiff = ""
+	u.renderDetails()
+	view := u.detail
+	if u.detailTab.onDiffPage() {
+		view = u.diffView
+	}
+	row, _ := view.GetScrollOffset()
+	view.ScrollTo(row, 0)
+	if wrap {
+		u.setInfo("wrapping long lines")
+	} else {
+		u.setInfo("clipping long lines")
 	}
-	next := (idx + delta) % len(focusables)
-	if next < 0 {
-		next += len(focusables)
+}
+
+func (u *tuiState) setDiffRenderedText(text string, keepScroll bool) {
+	if text == u.lastDiff {
+		return
 	}
-	app.SetFocus(focusables[next])
+	row, col := u.diffView.GetScrollOffset()
+	u.diffView.SetText(text)
+	u.lastDiff = text
+	if keepScroll {
+		u.diffView.ScrollTo(row, col)
+		return
+	}
+	u.diffView.ScrollToBeginning()
 }
 
-func modalCapture(
-	app *tview.Application,
-	focusables []tview.Primitive,
-	onEsc func(),
-	shortcuts map[rune]func(),
-) func(ev *tcell.EventKey) *tcell.EventKey {
-	return func(ev *tcell.EventKey) *tcell.EventKey {
-		switch ev.Key() {
-		case tcell.KeyEscape:
-			onEsc()
-			return nil
-		case tcell.KeyTAB:
-			cycleModalFocus(app, focusables, 1)
-			return nil
-		case tcell.KeyBacktab:
-			cycleModalFocus(app, focusables, -1)
-			return nil
-		}
-		if ev.Key() == tcell.KeyRune {
-			key := unicode.ToLower(ev.Rune())
-			if fn, ok := shortcuts[key]; ok {
-				if ev.Modifiers()&tcell.ModAlt != 0 {
-					fn()
-					return nil
-				}
-				switch app.GetFocus().(type) {
-				case *tview.InputField, *tview.DropDown:
-					return ev
-				default:
-					fn()
-					return nil
-				}
-			}
-		}
-		return ev
+func (u *tuiState) detailCaptureLineCount() int {
+	_, _, _, h := u.detail.GetInnerRect()
+	if h <= 0 {
+		return u.mgr.Cfg.DetailCaptureLines
+	}
+	lines := h + 6
+	if lines > u.mgr.Cfg.DetailCaptureLines {
+		lines = u.mgr.Cfg.DetailCaptureLines
+	}
+	if lines < 20 {
+		lines = 20
 	}
+	return lines
 }
 
-func (u *tuiState) showRepoSwitchModal() {
-	u.refreshRepoChoices()
-	if len(u.repos) <= 1 {
-		u.setWarn("no other repositories found near current repo")
+func (u *tuiState) scrollTextView(view *tview.TextView, delta int) {
+	if view == nil {
 		return
 	}
+	row, col := view.GetScrollOffset()
+	next := row + delta
+	if next < 0 {
+		next = 0
+	}
+	view.ScrollTo(next, col)
+}
 
-	table := tview.NewTable().
-		SetSelectable(true, false).
-		SetFixed(1, 0).
-		SetBorders(false)
-	table.SetSeparator(' ')
-	table.SetBackgroundColor(tcell.ColorDefault)
-	table.SetSelectedStyle(tcell.StyleDefault.Foreground(tcell.ColorDefault).Background(tcell.ColorDefault).Reverse(true))
-	table.SetBorder(true)
-	table.SetBorderColor(paneBorderColor())
-
-	headers := []string{"", "Repository", "Branch", "Path"}
-	for col, h := range headers {
-		cell := tview.NewTableCell(h).
-			SetAttributes(tcell.AttrBold).
-			SetTextColor(ansiColor(ansiCyan)).
-			SetSelectable(false).
-			SetExpansion(1)
-		table.SetCell(0, col, cell)
+func (u *tuiState) worktreeGraphic(selectedPath string) string {
+	if len(u.items) == 0 {
+		return lipgloss.NewStyle().Foreground(ColorPurple).Render("(no worktrees)")
 	}
 
-	currentRow := 1
-	for i, repo := range u.repos {
-		row := i + 1
-		mark := " "
-		if repo.Root == u.repoRoot {
-			mark = "*"
-			currentRow = row
+	ordered := append([]Worktree(nil), u.items...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if ordered[i].Current != ordered[j].Current {
+			return ordered[i].Current
+		}
+		if ordered[i].Branch == ordered[j].Branch {
+			return ordered[i].Path < ordered[j].Path
 		}
+		return ordered[i].Branch < ordered[j].Branch
+	})
 
-		nameCell := tview.NewTableCell(repo.Name).SetExpansion(1)
-		if repo.Root == u.repoRoot {
-			nameCell.SetAttributes(tcell.AttrBold)
-		}
+	repoLabel := u.repoName
+	if strings.TrimSpace(u.repoSlug) != "" {
+		repoLabel = u.repoSlug
+	}
 
-		table.SetCell(row, 0, tview.NewTableCell(mark).SetTextColor(ansiColor(ansiGreen)).SetExpansion(1))
-		table.SetCell(row, 1, nameCell)
-		table.SetCell(row, 2, tview.NewTableCell(repo.Branch).SetTextColor(ansiColor(ansiCyan)).SetExpansion(1))
-		table.SetCell(row, 3, tview.NewTableCell(repo.Root).SetTextColor(ansiColor(ansiMagenta)).SetExpansion(1))
+	lines := []string{
+		lipgloss.NewStyle().Bold(true).Foreground(ColorGreen).Render(repoLabel),
+		lipgloss.NewStyle().Foreground(ColorCyan).Render("│"),
 	}
 
-	cancelRow := len(u.repos) + 1
-	table.SetCell(cancelRow, 0, tview.NewTableCell(""))
-	table.SetCell(cancelRow, 1, tview.NewTableCell("Cancel").SetTextColor(tcell.ColorDefault))
+	for i, wt := range ordered {
+		branch := wt.Branch
+		if branch == "" {
+			branch = "detached"
+		}
 
-	counter := tview.NewTextView().
-		SetDynamicColors(true).
-		SetWrap(false).
-		SetTextAlign(tview.AlignRight)
-	counter.SetTextColor(paneBorderColor())
-	counter.SetBackgroundColor(tcell.ColorDefault)
+		arm := lipgloss.NewStyle().Foreground(ColorCyan).Render("├─")
+		stem := lipgloss.NewStyle().Foreground(ColorCyan).Render("│ ")
+		if i == len(ordered)-1 {
+			arm = lipgloss.NewStyle().Foreground(ColorCyan).Render("└─")
+			stem = "  "
+		}
 
-	updateCounter := func(row int) {
-		if row < 1 {
-			row = 1
+		marker := "○"
+		markerColor := ColorCyan
+		if wt.Current {
+			marker = "●"
+			markerColor = ColorGreen
 		}
-		total := len(u.repos) + 1
-		if row > total {
-			row = total
+		if wt.Path == selectedPath {
+			marker = "◆"
+			markerColor = ColorBlue
 		}
-		counter.SetText(fmt.Sprintf("%d of %d", row, total))
-	}
 
-	selectRow := func(row int) {
-		if row <= 0 {
-			return
+		branchColor := ColorCyan
+		if wt.Dirty {
+			branchColor = ColorRed
+		} else if wt.Current {
+			branchColor = ColorGreen
 		}
-		if row == cancelRow {
-			u.closeModal("repos")
-			u.setInfo("repo switch canceled")
-			return
+
+		state := worktreeStatusLabel(wt)
+		stateColor := ColorGreen
+		switch state {
+		case "dirty", rebaseStateAborted:
+			stateColor = ColorRed
+		case rebaseStateRunning:
+			stateColor = ColorPurple
 		}
-		idx := row - 1
-		if idx < 0 || idx >= len(u.repos) {
-			return
+
+		tmuxState := lipgloss.NewStyle().Foreground(ColorCyan).Render("·")
+		switch wt.TmuxState {
+		case "yes":
+			tmuxState = lipgloss.NewStyle().Foreground(ColorGreen).Render("●")
+		case "no":
+			tmuxState = lipgloss.NewStyle().Foreground(ColorRed).Render("○")
 		}
-		u.closeModal("repos")
-		u.switchRepo(u.repos[idx])
-	}
 
-	table.SetSelectionChangedFunc(func(row, col int) {
-		updateCounter(row)
-	})
-	table.SetSelectedFunc(func(row, col int) {
-		selectRow(row)
-	})
-	table.SetInputCapture(func(ev *tcell.EventKey) *tcell.EventKey {
-		switch ev.Key() {
-		case tcell.KeyEscape:
-			u.closeModal("repos")
-			u.setInfo("repo switch canceled")
-			return nil
+		agentState := lipgloss.NewStyle().Foreground(ColorCyan).Render("·")
+		switch wt.AgentState {
+		case "yes":
+			agentState = lipgloss.NewStyle().Foreground(ColorGreen).Render("●")
+		case "no":
+			agentState = lipgloss.NewStyle().Foreground(ColorRed).Render("○")
+		case "crashed":
+			agentState = lipgloss.NewStyle().Foreground(ColorRed).Render("✕")
 		}
-		if ev.Key() == tcell.KeyRune {
-			switch ev.Rune() {
-			case 'c':
-				u.closeModal("repos")
-				u.setInfo("repo switch canceled")
-				return nil
-			case 'j':
-				row, _ := table.GetSelection()
-				if row < cancelRow {
-					table.Select(row+1, 0)
-				}
-				return nil
-			case 'k':
-				row, _ := table.GetSelection()
-				if row > 1 {
-					table.Select(row-1, 0)
-				}
-				return nil
-			}
+
+		branchText := lipgloss.NewStyle().Bold(true).Foreground(branchColor).Render(truncate(branch, 42))
+		stateText := lipgloss.NewStyle().Foreground(stateColor).Render("(" + state + ")")
+		markerText := lipgloss.NewStyle().Foreground(markerColor).Render(marker)
+
+		line := fmt.Sprintf(
+			"%s%s %s %s tmux:%s agent:%s",
+			arm, markerText, branchText, stateText, tmuxState, agentState,
+		)
+		if wt.Locked {
+			line += " " + lipgloss.NewStyle().Foreground(ColorPurple).Render("locked")
 		}
-		return ev
-	})
+		lines = append(lines, line)
 
-	meta := tview.NewFlex().
-		AddItem(tview.NewBox(), 0, 1, false).
-		AddItem(counter, 10, 0, false)
+		pathColor := ColorPurple
+		if wt.Path == selectedPath {
+			pathColor = ColorBlue
+		}
+		pathArm := lipgloss.NewStyle().Foreground(ColorCyan).Render("└─")
+		pathText := lipgloss.NewStyle().Foreground(pathColor).Render(truncatePath(u.mgr.displayPath(u.repoRoot, wt.Path), 74))
+		lines = append(lines, fmt.Sprintf("%s%s %s", stem, pathArm, pathText))
+	}
 
-	picker := tview.NewFlex().
-		SetDirection(tview.FlexRow).
-		AddItem(table, 0, 1, true).
-		AddItem(meta, 1, 0, false)
-	picker.SetBackgroundColor(tcell.ColorDefault)
+	return translateANSI(strings.Join(lines, "\n"))
+}
 
-	u.showModal("repos", picker, 150, 22)
-	table.Select(currentRow, 0)
-	updateCounter(currentRow)
-	u.app.SetFocus(table)
+func (u *tuiState) setStatus(format string, args ...any) {
+	u.renderFooter("STATUS", fmt.Sprintf(format, args...))
 }
 
-func (u *tuiState) switchRepo(repo repoChoice) {
-	if repo.Root == "" || repo.Root == u.repoRoot {
-		return
+func (u *tuiState) setInfo(format string, args ...any) {
+	u.renderFooter("INFO", fmt.Sprintf(format, args...))
+}
+
+func (u *tuiState) setWarn(format string, args ...any) {
+	u.renderFooter("WARN", fmt.Sprintf(format, args...))
+}
+
+func (u *tuiState) setError(format string, args ...any) {
+	u.renderFooter("ERROR", fmt.Sprintf(format, args...))
+}
+
+func (u *tuiState) footerKeymap() string {
+	base := "[::b]tab[::-] pane | [::b]r[::-] refresh | [::b]?[::-] help | [::b]q[::-] quit"
+	focus := u.app.GetFocus()
+	inDetail := focus == u.detailPane || focus == u.detail || focus == u.diffFiles || focus == u.diffView
+
+	switch {
+	case u.passthrough != "" && focus == u.detailPane:
+		return "keys go to the agent | [::b]ctrl+][::-] stop typing"
+	case focus == u.statusPane:
+		return "[::b]enter[::-] repos | [::b]s[::-] sessions | " + base
+	case focus == u.repoSidebar:
+		return "[::b]j/k[::-] move | [::b]enter[::-] switch repo | [::b]S[::-] hide | " + base
+	case focus == u.table:
+		return "[::b]j/k[::-] move | [::b]enter[::-] attach | [::b]d[::-] detach | [::b]n[::-] new | [::b]x[::-] remove | [::b]m[::-] rename | [::b]l[::-] lock | [::b]P[::-] priority | [::b]b[::-] rebase | [::b]M[::-] merge | [::b]p[::-] prompt | [::b]/[::-] filter | [::b]f[::-] presets | [::b]o[::-] sort | [::b]y/Y[::-] copy | [::b]L[::-] logs | " + base
+	case inDetail:
+		if u.detailTab == detailTabDiff {
+			return "[::b]j/k[::-] files | [::b]J/K[::-] patch scroll | [::b]y[::-] copy patch | [::b]e/E[::-] export patch/diff | [::b]c[::-] copy to worktree | [::b]v[::-] preview | [::b]X[::-] discard | [::b]w[::-] wrap | [::b]h/l[::-] tab | " + base
+		}
+		if u.detailTab == detailTabLog {
+			return "[::b]j/k[::-] commits | [::b]enter[::-] show patch | [::b]c[::-] cherry-pick to worktree | [::b]J/K[::-] patch scroll | [::b]w[::-] wrap | [::b]h/l[::-] tab | " + base
+		}
+		if u.detailTab == detailTabAgent {
+			return "[::b]j/k/pgup/pgdn[::-] scroll | [::b]a[::-] type to agent | [::b]e[::-] export | [::b]R[::-] restart agent | [::b]w[::-] wrap | [::b]W[::-] next pane | [::b]h/l/[[/]][::-] tab | " + base
+		}
+		return "[::b]j/k/pgup/pgdn[::-] scroll | [::b]h/l/[[/]][::-] tab | " + base
+	default:
+		return "[::b]tab[::-] cycle modal focus | [::b]esc[::-] close modal"
 	}
-	if err := os.Chdir(repo.Root); err != nil {
-		u.setError("switch failed: %v", err)
-		return
+}
+
+func (u *tuiState) renderFooter(level, message string) {
+	if strings.TrimSpace(level) == "" {
+		level = "INFO"
 	}
-	u.repoRoot = repo.Root
-	u.repoName = repo.Name
-	u.repoSlug = repo.GitHubRepo
-	u.filter = ""
-	u.selected = 0
-	if err := u.refresh(); err != nil {
-		u.setError("switched repo, refresh failed: %v", err)
-		return
+	if strings.TrimSpace(message) == "" {
+		message = "ready"
 	}
-	u.setInfo("switched repo: %s", repoChoiceLabel(repo))
+	u.footerLevel = level
+	u.footerMsg = message
+	u.redrawFooter()
 }
 
-func (u *tuiState) showFilterModal() {
-	input := tview.NewInputField().SetText(u.filter)
-	styleModalInputField(input)
-
-	applyFilter := func() {
-		u.filter = strings.TrimSpace(input.GetText())
-		u.applyFilter()
-		u.renderTable()
-		u.renderDetails()
-		u.setInfo("filter updated")
-		u.closeModal("filter")
+func (u *tuiState) redrawFooter() {
+	level := u.footerLevel
+	if strings.TrimSpace(level) == "" {
+		level = "INFO"
 	}
-	clearFilter := func() {
-		u.filter = ""
-		u.applyFilter()
-		u.renderTable()
-		u.renderDetails()
-		u.setInfo("filter cleared")
-		u.closeModal("filter")
+	message := u.footerMsg
+	if strings.TrimSpace(message) == "" {
+		message = "ready"
 	}
-	cancel := func() {
-		u.closeModal("filter")
+	levelColor := ColorCyan
+	switch level {
+	case "ERROR":
+		levelColor = ColorRed
+	case "WARN":
+		levelColor = ColorPurple
+	case "INFO":
+		levelColor = ColorBlue
 	}
 
-	applyBtn := modalButton("<a> Apply", applyFilter)
-	clearBtn := modalButton("<l> Clear", clearFilter)
-	cancelBtn := modalButton("<c> Cancel", cancel)
+	keymapStyle := lipgloss.NewStyle().Foreground(ColorGray)
+	levelStyle := lipgloss.NewStyle().Foreground(levelColor).Bold(true)
+	msgStyle := lipgloss.NewStyle()
+	versionStyle := lipgloss.NewStyle().Foreground(ColorCyan)
 
-	row := tview.NewFlex().
-		AddItem(nil, 0, 1, false).
-		AddItem(applyBtn, 12, 0, false).
-		AddItem(nil, 2, 0, false).
-		AddItem(clearBtn, 12, 0, false).
-		AddItem(nil, 2, 0, false).
-		AddItem(cancelBtn, 12, 0, false).
-		AddItem(nil, 0, 1, false)
+	left := fmt.Sprintf("╰─ %s  %s: %s",
+		keymapStyle.Render(u.footerKeymap()),
+		levelStyle.Render(level),
+		msgStyle.Render(message),
+	)
+	right := fmt.Sprintf("─ %s ╯", versionStyle.Render("v"+Version))
 
-	layout := tview.NewFlex().
-		SetDirection(tview.FlexRow).
-		AddItem(modalHeader("Filter Worktrees"), 1, 0, false).
-		AddItem(nil, 1, 0, false).
-		AddItem(modalFieldBox("Filter Query", input), 3, 0, true).
-		AddItem(nil, 1, 0, false).
-		AddItem(row, 1, 0, false)
-	layout.SetBackgroundColor(tcell.ColorDefault)
+	u.footerLeft.SetText(translateANSI(left))
+	u.footerRight.SetText(translateANSI(right))
+}
 
-	focusables := []tview.Primitive{input, applyBtn, clearBtn, cancelBtn}
-	capture := modalCapture(u.app, focusables, cancel, map[rune]func(){
-		'a': applyFilter,
-		'l': clearFilter,
-		'c': cancel,
-	})
-	for _, p := range focusables {
-		setPrimitiveInputCapture(p, capture)
-	}
-	input.SetDoneFunc(func(key tcell.Key) {
-		if key == tcell.KeyEnter {
-			applyFilter()
-		}
-	})
+func (u *tuiState) showModal(name string, p tview.Primitive, width, height int) {
+	u.pages.AddPage(name, centered(width, height, p), true, true)
+	u.app.SetFocus(p)
+	u.updatePaneFocusStyles()
+}
 
-	u.showModal("filter", layout, 76, 11)
-	u.app.SetFocus(input)
+func (u *tuiState) closeModal(name string) {
+	u.pages.RemovePage(name)
+	u.app.SetFocus(u.table)
+	u.updatePaneFocusStyles()
 }
 
-func (u *tuiState) showCreateModal() {
-	repoRoot, err := u.mgr.RequireRepo()
-	if err != nil {
-		u.setError("not in a git repo: %v", err)
-		return
-	}
+// staleBranchAge is how old a branch tip gets before the create modal
+// highlights its age.
+const staleBranchAge = 30 * 24 * time.Hour
 
-	allBranches, _ := u.mgr.ListBranches(repoRoot)
-	creating := false
+// formatAge renders how long before now t was, as "just now", "5m", "3h",
+// "4d", "6w", "8mo", or "2y".
+func formatAge(t, now time.Time) string {
+	d := now.Sub(t)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d/time.Minute))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh", int(d/time.Hour))
+	case d < 14*24*time.Hour:
+		return fmt.Sprintf("%dd", int(d/(24*time.Hour)))
+	case d < 60*24*time.Hour:
+		return fmt.Sprintf("%dw", int(d/(7*24*time.Hour)))
+	case d < 365*24*time.Hour:
+		return fmt.Sprintf("%dmo", int(d/(30*24*time.Hour)))
+	}
+	return fmt.Sprintf("%dy", int(d/(365*24*time.Hour)))
+}
 
-	type branchRow struct {
-		name     string
-		isNew    bool
-		isRemote bool
+func formatByteSize(n int64) string {
+	if n < 1024 {
+		return fmt.Sprintf("%d B", n)
 	}
-	var displayRows []branchRow
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	v := float64(n)
+	uIdx := 0
+	for v >= 1024 && uIdx < len(units)-1 {
+		v /= 1024
+		uIdx++
+	}
+	return fmt.Sprintf("%.1f %s", v, units[uIdx])
+}
 
-	input := tview.NewInputField()
-	styleModalInputField(input)
-	input.SetPlaceholder("type to filter or enter a new branch name")
-	input.SetPlaceholderTextColor(paneBorderColor())
+// showProgressModal shows a progress bar over totalSteps steps. When cancel is
+// set the modal has a Cancel button, also bound to Esc and c, that calls it
+// once; the caller is expected to wind down and close the modal.
+func (u *tuiState) showProgressModal(name, title string, totalSteps int, cancel func()) (func(string), func(string), func(float64), func()) {
+	const barWidth = 44
+	const modalWidth = 64
 
-	branchTable := tview.NewTable().
-		SetSelectable(true, false).
-		SetFixed(1, 0).
-		SetBorders(false)
-	branchTable.SetSeparator(' ')
-	branchTable.SetBackgroundColor(tcell.ColorDefault)
-	branchTable.SetSelectedStyle(tcell.StyleDefault.Foreground(tcell.ColorDefault).Background(tcell.ColorDefault).Reverse(true))
-	branchTable.SetBorder(true)
-	branchTable.SetBorderColor(paneBorderColor())
+	titleView := tview.NewTextView().SetDynamicColors(true).SetWrap(false)
+	titleView.SetBackgroundColor(tcell.ColorDefault)
+	titleStyle := lipgloss.NewStyle().Foreground(ThemeColorPrimary).Bold(true)
+	titleView.SetText(translateANSI(" " + titleStyle.Render(strings.TrimSpace(title))))
 
-	counter := tview.NewTextView().
-		SetDynamicColors(true).
-		SetWrap(false).
-		SetTextAlign(tview.AlignRight)
-	counter.SetTextColor(paneBorderColor())
-	counter.SetBackgroundColor(tcell.ColorDefault)
+	stepView := tview.NewTextView().SetDynamicColors(true).SetWrap(false)
+	stepView.SetBackgroundColor(tcell.ColorDefault)
+	stepView.SetTextColor(tcell.ColorDefault)
 
-	hints := tview.NewTextView().SetDynamicColors(true).SetWrap(false)
-	hints.SetTextColor(paneBorderColor())
-	hints.SetBackgroundColor(tcell.ColorDefault)
-	hints.SetText(" ↑↓/jk navigate  enter select  c/esc cancel")
+	barView := tview.NewTextView().SetDynamicColors(true).SetWrap(false)
+	barView.SetBackgroundColor(tcell.ColorDefault)
 
-	updateCounter := func(dataIdx int) {
-		total := len(displayRows)
-		if total == 0 {
-			counter.SetText("")
-			return
-		}
-		n := dataIdx + 1
-		if n < 1 {
-			n = 1
+	layout := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(titleView, 1, 0, false).
+		AddItem(nil, 1, 0, false).
+		AddItem(stepView, 1, 0, false).
+		AddItem(barView, 1, 0, false)
+	layout.SetBackgroundColor(tcell.ColorDefault)
+
+	spinChars := []string{"|", "/", "-", "\\"}
+
+	var mu sync.Mutex
+	var step int
+	var stepProgress float64
+	label := "Working..."
+	var frame int
+	// Once canceled the label stays on "Canceling..." while the work winds
+	// down.
+	canceled := false
+	var render func()
+
+	height := 7
+	var focus tview.Primitive = layout
+	if cancel != nil {
+		var cancelBtn *tview.Button
+		requestCancel := func() {
+			mu.Lock()
+			if canceled {
+				mu.Unlock()
+				return
+			}
+			canceled = true
+			label = "Canceling..."
+			mu.Unlock()
+			cancelBtn.SetLabel("Canceling...")
+			render()
+			cancel()
 		}
-		counter.SetText(fmt.Sprintf("%d of %d  ", n, total))
+		cancelBtn = modalButton("<Esc> Cancel", requestCancel)
+		cancelBtn.SetInputCapture(func(ev *tcell.EventKey) *tcell.EventKey {
+			if ev.Key() == tcell.KeyEscape || (ev.Key() == tcell.KeyRune && unicode.ToLower(ev.Rune()) == 'c') {
+				requestCancel()
+				return nil
+			}
+			return ev
+		})
+		buttons := tview.NewFlex().
+			AddItem(nil, 0, 1, false).
+			AddItem(cancelBtn, 16, 0, true).
+			AddItem(nil, 0, 1, false)
+		buttons.SetBackgroundColor(tcell.ColorDefault)
+		layout.AddItem(nil, 1, 0, false).AddItem(buttons, 1, 0, true)
+		height += 2
+		focus = cancelBtn
 	}
 
-	rebuildTable := func(query string) {
-		displayRows = nil
-		branchTable.Clear()
-
-		branchTable.SetCell(0, 0, tview.NewTableCell("").SetSelectable(false))
-		branchTable.SetCell(0, 1, tview.NewTableCell("BRANCH").
-			SetTextColor(ansiColor(ansiCyan)).SetSelectable(false).SetExpansion(1))
-		branchTable.SetCell(0, 2, tview.NewTableCell("").SetSelectable(false))
+	u.showModal(name, layout, modalWidth, height)
+	u.app.SetFocus(focus)
 
-		rowIdx := 1
-		lq := strings.ToLower(strings.TrimSpace(query))
+	render = func() {
+		mu.Lock()
+		s, sp, l, f := step, stepProgress, label, frame
+		mu.Unlock()
 
-		// Synthetic "new branch" entry when query doesn't exactly match any existing branch
-		if lq != "" {
-			exactMatch := false
-			for _, b := range allBranches {
-				if strings.ToLower(b.Name) == lq {
-					exactMatch = true
-					break
-				}
+		pct := 0.0
+		if totalSteps > 0 {
+			if sp < 0 {
+				sp = 0
 			}
-			if !exactMatch {
-				name := strings.TrimSpace(query)
-				branchTable.SetCell(rowIdx, 0, tview.NewTableCell("✦").SetTextColor(ansiColor(ansiGreen)).SetSelectable(true))
-				branchTable.SetCell(rowIdx, 1, tview.NewTableCell(name).SetTextColor(tcell.ColorDefault).SetSelectable(true).SetExpansion(1))
-				branchTable.SetCell(rowIdx, 2, tview.NewTableCell("new").SetTextColor(paneBorderColor()).SetSelectable(true))
-				displayRows = append(displayRows, branchRow{name: name, isNew: true})
-				rowIdx++
+			if sp > 1 {
+				sp = 1
 			}
-		}
-
-		for _, b := range allBranches {
-			if lq != "" && !strings.Contains(strings.ToLower(b.Name), lq) {
-				continue
+			if s > 0 {
+				base := float64(s - 1)
+				pct = (base + sp) / float64(totalSteps)
 			}
-			typeLabel := ""
-			typeColor := paneBorderColor()
-			if b.Remote {
-				typeLabel = "remote"
-				typeColor = ansiColor(ansiMagenta)
+			if pct > 1.0 {
+				pct = 1.0
 			}
-			branchTable.SetCell(rowIdx, 0, tview.NewTableCell("").SetSelectable(true))
-			branchTable.SetCell(rowIdx, 1, tview.NewTableCell(b.Name).SetTextColor(tcell.ColorDefault).SetSelectable(true).SetExpansion(1))
-			branchTable.SetCell(rowIdx, 2, tview.NewTableCell(typeLabel).SetTextColor(typeColor).SetSelectable(true))
-			displayRows = append(displayRows, branchRow{name: b.Name, isRemote: b.Remote})
-			rowIdx++
 		}
 
-		if len(displayRows) == 0 && lq == "" {
-			branchTable.SetCell(1, 0, tview.NewTableCell(""))
-			branchTable.SetCell(1, 1, tview.NewTableCell("no branches available — type a name to create one").
-				SetTextColor(paneBorderColor()).SetSelectable(false).SetExpansion(1))
-			branchTable.SetCell(1, 2, tview.NewTableCell(""))
+		filled := int(float64(barWidth) * pct)
+		if filled > barWidth {
+			filled = barWidth
 		}
+		empty := barWidth - filled
 
-		if len(displayRows) > 0 {
-			branchTable.Select(1, 0)
-			updateCounter(0)
+		spin := spinChars[f%len(spinChars)]
+		spinStyle := lipgloss.NewStyle().Foreground(ThemeColorPrimary)
+		stepView.SetText(translateANSI(fmt.Sprintf(" %s %s", spinStyle.Render(spin), l)))
+
+		filledStyle := lipgloss.NewStyle().Foreground(ThemeColorPrimary)
+		emptyStyle := lipgloss.NewStyle().Foreground(ColorGray)
+		pctStyle := lipgloss.NewStyle().Foreground(ThemeColorPrimary).Bold(true)
+
+		var pctText string
+		if s == 0 {
+			pctText = pctStyle.Render("  --%")
 		} else {
-			counter.SetText("")
+			pctText = pctStyle.Render(fmt.Sprintf(" %3d%%", int(pct*100)))
 		}
+
+		barText := " " +
+			filledStyle.Render(strings.Repeat("█", filled)) +
+			emptyStyle.Render(strings.Repeat("░", empty)) +
+			pctText
+		barView.SetText(translateANSI(barText))
 	}
+	render()
 
-	doCreate := func(branch string, fromExisting bool, copyUntracked bool) {
-		if creating {
-			return
-		}
-		branch = strings.TrimSpace(branch)
-		if branch == "" {
-			u.setWarn("branch name is required")
-			return
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(120 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				mu.Lock()
+				frame++
+				mu.Unlock()
+				u.app.QueueUpdateDraw(func() {
+					render()
+				})
+			}
 		}
-		creating = true
+	}()
 
-		totalSteps := 2 // create + refresh
-		if u.mgr.Cfg.AutoLaunch {
-			totalSteps++
+	advance := func(next string) {
+		mu.Lock()
+		step++
+		stepProgress = 0
+		if strings.TrimSpace(next) != "" && !canceled {
+			label = strings.TrimSpace(next)
 		}
-		if u.mgr.Cfg.AutoStartAgent {
-			totalSteps++
+		mu.Unlock()
+		u.app.QueueUpdateDraw(func() {
+			render()
+		})
+	}
+	setLabel := func(next string) {
+		mu.Lock()
+		if strings.TrimSpace(next) != "" && !canceled {
+			label = strings.TrimSpace(next)
 		}
-		advance, setProgressLabel, setStepProgress, stopProgress := u.showProgressModal("create-progress", "Create Worktree", totalSteps)
-
-		go func(branch string, fromExisting bool) {
-			var path string
-			var createErr error
-			warnings := []string{}
-			var refreshed []Worktree
-			var refreshErr error
-
-			var opts NewOptions
-			lastCopyUpdate := time.Time{}
-			renderCopyLabel := func(p CopyProgress) string {
-				switch p.Phase {
+		mu.Unlock()
+		u.app.QueueUpdateDraw(func() {
+			render()
+		})
+	}
+	setStepProgress := func(progress float64) {
+		mu.Lock()
+		if progress < 0 {
+			progress = 0
+		}
+		if progress > 1 {
+			progress = 1
+		}
+		stepProgress = progress
+		mu.Unlock()
+		u.app.QueueUpdateDraw(func() {
+			render()
+		})
+	}
+	stop := func() {
+		close(done)
+	}
+	return advance, setLabel, setStepProgress, stop
+}
+
+// modalFrame centers a modal and swallows mouse events around it so clicks
+// cannot reach the panes underneath.
+type modalFrame struct {
+	*tview.Flex
+}
+
+func (m *modalFrame) MouseHandler() func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (bool, tview.Primitive) {
+	inner := m.Flex.MouseHandler()
+	return func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (bool, tview.Primitive) {
+		_, capture := inner(action, event, setFocus)
+		return true, capture
+	}
+}
+
+func centered(width, height int, p tview.Primitive) tview.Primitive {
+	return &modalFrame{Flex: tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(
+			tview.NewFlex().
+				SetDirection(tview.FlexRow).
+				AddItem(nil, 0, 1, false).
+				AddItem(p, height, 1, true).
+				AddItem(nil, 0, 1, false),
+			width, 1, true,
+		).
+		AddItem(nil, 0, 1, false)}
+}
+
+func styleModalInputField(field *tview.InputField) {
+	field.
+		SetLabel("").
+		SetFieldTextColor(tcell.ColorDefault).
+		SetFieldBackgroundColor(tcell.ColorDefault).
+		SetBackgroundColor(tcell.ColorDefault)
+}
+
+func styleModalCheckbox(field *tview.Checkbox) {
+	field.
+		SetLabelColor(ansiColor(ansiCyan)).
+		SetFieldBackgroundColor(tcell.ColorDefault).
+		SetFieldTextColor(tcell.ColorDefault).
+		SetBackgroundColor(tcell.ColorDefault)
+}
+
+func styleModalDropDown(field *tview.DropDown) {
+	base := tcell.StyleDefault.Foreground(tcell.ColorDefault).Background(tcell.ColorDefault)
+	focus := base.Reverse(true)
+	field.
+		SetLabel("").
+		SetLabelColor(ansiColor(ansiCyan)).
+		SetFieldTextColor(tcell.ColorDefault).
+		SetFieldBackgroundColor(tcell.ColorDefault).
+		SetFieldStyle(base).
+		SetFocusedStyle(focus).
+		SetListStyles(base, focus).
+		SetTextOptions("", "", "> ", "", "(select)").
+		SetBackgroundColor(tcell.ColorDefault)
+}
+
+func modalHeader(title string) *tview.TextView {
+	header := tview.NewTextView().
+		SetDynamicColors(true).
+		SetWrap(false)
+	header.SetBackgroundColor(tcell.ColorDefault)
+	header.SetTextColor(paneBorderColor())
+	header.SetText(" " + title)
+	return header
+}
+
+func modalFieldBox(title string, inner tview.Primitive) *tview.Flex {
+	box := tview.NewFlex().SetDirection(tview.FlexRow)
+	box.AddItem(inner, 1, 1, false)
+	box.SetBackgroundColor(tcell.ColorDefault)
+	box.SetBorder(true)
+	box.SetBorderColor(paneBorderColor())
+	box.SetTitle(" " + title + " ")
+	box.SetTitleColor(ansiColor(ansiCyan))
+	return box
+}
+
+func modalButton(label string, selected func()) *tview.Button {
+	btn := tview.NewButton(label).SetSelectedFunc(selected)
+	btn.SetLabelColor(tcell.ColorDefault)
+	btn.SetLabelColorActivated(ansiColor(ansiCyan))
+	btn.SetBackgroundColor(tcell.ColorDefault)
+	btn.SetBackgroundColorActivated(tcell.ColorDefault)
+	return btn
+}
+
+func setPrimitiveInputCapture(p tview.Primitive, capture func(ev *tcell.EventKey) *tcell.EventKey) {
+	switch v := p.(type) {
+	case *tview.InputField:
+		v.SetInputCapture(capture)
+	case *tview.DropDown:
+		v.SetInputCapture(capture)
+	case *tview.Checkbox:
+		v.SetInputCapture(capture)
+	case *tview.Button:
+		v.SetInputCapture(capture)
+	case *tview.Table:
+		v.SetInputCapture(capture)
+	}
+}
+
+func cycleModalFocus(app *tview.Application, focusables []tview.Primitive, delta int) {
+	if len(focusables) == 0 {
+		return
+	}
+	cur := app.GetFocus()
+	idx := 0
+	for i, f := range focusables {
+		if cur == f {
+			idx = i
+			break
+		}
+	}
+	next := (idx + delta) % len(focusables)
+	if next < 0 {
+		next += len(focusables)
+	}
+	app.SetFocus(focusables[next])
+}
+
+func modalCapture(
+	app *tview.Application,
+	focusables []tview.Primitive,
+	onEsc func(),
+	shortcuts map[rune]func(),
+) func(ev *tcell.EventKey) *tcell.EventKey {
+	return func(ev *tcell.EventKey) *tcell.EventKey {
+		switch ev.Key() {
+		case tcell.KeyEscape:
+			onEsc()
+			return nil
+		case tcell.KeyTAB:
+			cycleModalFocus(app, focusables, 1)
+			return nil
+		case tcell.KeyBacktab:
+			cycleModalFocus(app, focusables, -1)
+			return nil
+		}
+		if ev.Key() == tcell.KeyRune {
+			key := unicode.ToLower(ev.Rune())
+			if fn, ok := shortcuts[key]; ok {
+				if ev.Modifiers()&tcell.ModAlt != 0 {
+					fn()
+					return nil
+				}
+				switch app.GetFocus().(type) {
+				case *tview.InputField, *tview.DropDown:
+					return ev
+				default:
+					fn()
+					return nil
+				}
+			}
+		}
+		return ev
+	}
+}
+
+func (u *tuiState) showRepoSwitchModal() {
+	u.refreshRepoChoices()
+	if len(u.repos) <= 1 {
+		u.setWarn("no other repositories found near current repo")
+		return
+	}
+
+	table := tview.NewTable().
+		SetSelectable(true, false).
+		SetFixed(1, 0).
+		SetBorders(false)
+	table.SetSeparator(' ')
+	table.SetBackgroundColor(tcell.ColorDefault)
+	table.SetSelectedStyle(tcell.StyleDefault.Foreground(tcell.ColorDefault).Background(tcell.ColorDefault).Reverse(true))
+	table.SetBorder(true)
+	table.SetBorderColor(paneBorderColor())
+
+	headers := []string{"", "Repository", "Branch", "Activity (24h)", "Commits / Agent", "Path"}
+	for col, h := range headers {
+		cell := tview.NewTableCell(h).
+			SetAttributes(tcell.AttrBold).
+			SetTextColor(ansiColor(ansiCyan)).
+			SetSelectable(false).
+			SetExpansion(1)
+		table.SetCell(0, col, cell)
+	}
+
+	// Shade every repo on the same scale so busy projects stand out.
+	now := time.Now()
+	activity := make([]RepoActivity, len(u.repos))
+	maxScore := 0
+	for i, repo := range u.repos {
+		act, err := u.mgr.RepoActivity(repo.Root, now)
+		if err != nil {
+			errorLogf("repo_activity failed root=%q: %v", repo.Root, err)
+		}
+		activity[i] = act
+		if m := act.MaxScore(); m > maxScore {
+			maxScore = m
+		}
+	}
+
+	currentRow := 1
+	for i, repo := range u.repos {
+		row := i + 1
+		mark := " "
+		if repo.Root == u.repoRoot {
+			mark = "*"
+			currentRow = row
+		}
+
+		nameCell := tview.NewTableCell(repo.Name).SetExpansion(1)
+		if repo.Root == u.repoRoot {
+			nameCell.SetAttributes(tcell.AttrBold)
+		}
+
+		table.SetCell(row, 0, tview.NewTableCell(mark).SetTextColor(ansiColor(ansiGreen)).SetExpansion(1))
+		table.SetCell(row, 1, nameCell)
+		table.SetCell(row, 2, tview.NewTableCell(repo.Branch).SetTextColor(ansiColor(ansiCyan)).SetExpansion(1))
+		commits, lines := activity[i].Totals()
+		table.SetCell(row, 3, tview.NewTableCell(activityHeatmap(activity[i], maxScore)).SetTextColor(ansiColor(ansiGreen)))
+		table.SetCell(row, 4, tview.NewTableCell(fmt.Sprintf("%d / %s", commits, compactCount(lines))).SetTextColor(ColorToTcell(ThemeColorMuted)))
+		table.SetCell(row, 5, tview.NewTableCell(repo.Root).SetTextColor(ansiColor(ansiMagenta)).SetExpansion(1))
+	}
+
+	cancelRow := len(u.repos) + 1
+	table.SetCell(cancelRow, 0, tview.NewTableCell(""))
+	table.SetCell(cancelRow, 1, tview.NewTableCell("Cancel").SetTextColor(tcell.ColorDefault))
+
+	counter := tview.NewTextView().
+		SetDynamicColors(true).
+		SetWrap(false).
+		SetTextAlign(tview.AlignRight)
+	counter.SetTextColor(paneBorderColor())
+	counter.SetBackgroundColor(tcell.ColorDefault)
+
+	updateCounter := func(row int) {
+		if row < 1 {
+			row = 1
+		}
+		total := len(u.repos) + 1
+		if row > total {
+			row = total
+		}
+		counter.SetText(fmt.Sprintf("%d of %d", row, total))
+	}
+
+	selectRow := func(row int) {
+		if row <= 0 {
+			return
+		}
+		if row == cancelRow {
+			u.closeModal("repos")
+			u.setInfo("repo switch canceled")
+			return
+		}
+		idx := row - 1
+		if idx < 0 || idx >= len(u.repos) {
+			return
+		}
+		u.closeModal("repos")
+		u.switchRepo(u.repos[idx])
+	}
+
+	table.SetSelectionChangedFunc(func(row, col int) {
+		updateCounter(row)
+	})
+	table.SetSelectedFunc(func(row, col int) {
+		selectRow(row)
+	})
+	table.SetInputCapture(func(ev *tcell.EventKey) *tcell.EventKey {
+		switch ev.Key() {
+		case tcell.KeyEscape:
+			u.closeModal("repos")
+			u.setInfo("repo switch canceled")
+			return nil
+		}
+		if ev.Key() == tcell.KeyRune {
+			switch ev.Rune() {
+			case 'c':
+				u.closeModal("repos")
+				u.setInfo("repo switch canceled")
+				return nil
+			case 'j':
+				row, _ := table.GetSelection()
+				if row < cancelRow {
+					table.Select(row+1, 0)
+				}
+				return nil
+			case 'k':
+				row, _ := table.GetSelection()
+				if row > 1 {
+					table.Select(row-1, 0)
+				}
+				return nil
+			}
+		}
+		return ev
+	})
+
+	meta := tview.NewFlex().
+		AddItem(tview.NewBox(), 0, 1, false).
+		AddItem(counter, 10, 0, false)
+
+	picker := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(table, 0, 1, true).
+		AddItem(meta, 1, 0, false)
+	picker.SetBackgroundColor(tcell.ColorDefault)
+
+	u.showModal("repos", picker, 150, 22)
+	table.Select(currentRow, 0)
+	updateCounter(currentRow)
+	u.app.SetFocus(table)
+}
+
+func (u *tuiState) switchRepo(repo repoChoice) {
+	if repo.Root == "" || repo.Root == u.repoRoot {
+		return
+	}
+	if err := os.Chdir(repo.Root); err != nil {
+		u.setError("switch failed: %v", err)
+		return
+	}
+	// LoadConfig reads the [repos.<name>] section and .sprout.toml of the
+	// repository in the current directory.
+	cfg, err := LoadConfig()
+	if err != nil {
+		errorLogf("ui switch_repo load config failed repo=%q: %v", repo.Root, err)
+		if err := os.Chdir(u.repoRoot); err != nil {
+			errorLogf("ui switch_repo chdir back failed: %v", err)
+		}
+		u.setError("switch failed: %v", err)
+		return
+	}
+	mgr := *u.mgr
+	mgr.Cfg = cfg
+	u.mgrMu.Lock()
+	u.mgr = &mgr
+	u.mgrMu.Unlock()
+	if item := u.selectedItem(); item != nil {
+		u.repoSelection[u.repoRoot] = item.Path
+	}
+	u.repoRoot = repo.Root
+	u.repoName = repo.Name
+	u.repoSlug = repo.GitHubRepo
+	u.filter = savedFilter(repo.Root)
+	u.selected = 0
+	// The other repository's rows would be more than stale.
+	u.items = nil
+	u.applyFilter()
+	u.renderTable()
+	u.renderRepoSidebar()
+	u.refresh(func(err error) {
+		if err != nil {
+			u.setError("switched repo, refresh failed: %v", err)
+			return
+		}
+		if path, ok := u.repoSelection[repo.Root]; ok {
+			u.selectPath(path)
+		}
+		u.setInfo("switched repo: %s", repoChoiceLabel(repo))
+	})
+}
+
+// manager returns the Manager of the current repository, for background
+// loops that outlive a repository switch. The UI goroutine reads u.mgr.
+func (u *tuiState) manager() *Manager {
+	u.mgrMu.Lock()
+	defer u.mgrMu.Unlock()
+	return u.mgr
+}
+
+func (u *tuiState) showFilterModal() {
+	input := tview.NewInputField().SetText(u.filter)
+	styleModalInputField(input)
+
+	applyFilter := func() {
+		u.filter = strings.TrimSpace(input.GetText())
+		if err := saveFilter(u.repoRoot, u.filter); err != nil {
+			errorLogf("saved_filters write failed: %v", err)
+		}
+		u.applyFilter()
+		u.renderTable()
+		u.renderDetails()
+		u.setInfo("filter updated")
+		u.closeModal("filter")
+	}
+	clearFilter := func() {
+		u.filter = ""
+		if err := saveFilter(u.repoRoot, ""); err != nil {
+			errorLogf("saved_filters write failed: %v", err)
+		}
+		u.applyFilter()
+		u.renderTable()
+		u.renderDetails()
+		u.setInfo("filter cleared")
+		u.closeModal("filter")
+	}
+	cancel := func() {
+		u.closeModal("filter")
+	}
+
+	applyBtn := modalButton("<a> Apply", applyFilter)
+	clearBtn := modalButton("<l> Clear", clearFilter)
+	cancelBtn := modalButton("<c> Cancel", cancel)
+
+	row := tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(applyBtn, 12, 0, false).
+		AddItem(nil, 2, 0, false).
+		AddItem(clearBtn, 12, 0, false).
+		AddItem(nil, 2, 0, false).
+		AddItem(cancelBtn, 12, 0, false).
+		AddItem(nil, 0, 1, false)
+
+	layout := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(modalHeader("Filter Worktrees"), 1, 0, false).
+		AddItem(nil, 1, 0, false).
+		AddItem(modalFieldBox("Filter Query", input), 3, 0, true).
+		AddItem(nil, 1, 0, false).
+		AddItem(row, 1, 0, false)
+	layout.SetBackgroundColor(tcell.ColorDefault)
+
+	focusables := []tview.Primitive{input, applyBtn, clearBtn, cancelBtn}
+	capture := modalCapture(u.app, focusables, cancel, map[rune]func(){
+		'a': applyFilter,
+		'l': clearFilter,
+		'c': cancel,
+	})
+	for _, p := range focusables {
+		setPrimitiveInputCapture(p, capture)
+	}
+	input.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEnter {
+			applyFilter()
+		}
+	})
+
+	u.showModal("filter", layout, 76, 11)
+	u.app.SetFocus(input)
+}
+
+func (u *tuiState) showRenameModal() {
+	item := u.selectedItem()
+	if item == nil {
+		u.setWarn("nothing selected")
+		return
+	}
+	if item.Branch == "" {
+		u.setWarn("cannot rename a detached worktree")
+		return
+	}
+
+	input := tview.NewInputField().SetText(item.Branch)
+	styleModalInputField(input)
+
+	rename := func() {
+		newBranch := strings.TrimSpace(input.GetText())
+		if newBranch == "" {
+			u.setWarn("branch name is required")
+			return
+		}
+		if newBranch == item.Branch {
+			u.closeModal("rename")
+			return
+		}
+		path, warnings, err := u.mgr.Move(MoveOptions{Target: item.Path, NewBranch: newBranch})
+		if err != nil {
+			u.setError("rename failed: %v", err)
+			return
+		}
+		u.closeModal("rename")
+		u.refresh(func(err error) {
+			if err != nil {
+				u.setWarn("renamed, but refresh failed: %v", err)
+				return
+			}
+			u.selectPath(path)
+			if len(warnings) > 0 {
+				u.setWarn("renamed to %s (%s)", newBranch, warnings[0])
+				return
+			}
+			u.setInfo("renamed to %s", newBranch)
+		})
+	}
+	cancel := func() {
+		u.closeModal("rename")
+	}
+
+	renameBtn := modalButton("<r> Rename", rename)
+	cancelBtn := modalButton("<c> Cancel", cancel)
+
+	row := tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(renameBtn, 12, 0, false).
+		AddItem(nil, 2, 0, false).
+		AddItem(cancelBtn, 12, 0, false).
+		AddItem(nil, 0, 1, false)
+
+	layout := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(modalHeader("Rename Worktree"), 1, 0, false).
+		AddItem(nil, 1, 0, false).
+		AddItem(modalFieldBox("New Branch", input), 3, 0, true).
+		AddItem(nil, 1, 0, false).
+		AddItem(row, 1, 0, false)
+	layout.SetBackgroundColor(tcell.ColorDefault)
+
+	focusables := []tview.Primitive{input, renameBtn, cancelBtn}
+	capture := modalCapture(u.app, focusables, cancel, map[rune]func(){
+		'r': rename,
+		'c': cancel,
+	})
+	for _, p := range focusables {
+		setPrimitiveInputCapture(p, capture)
+	}
+	input.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEnter {
+			rename()
+		}
+	})
+
+	u.showModal("rename", layout, 76, 11)
+	u.app.SetFocus(input)
+}
+
+func (u *tuiState) showPromptModal() {
+	item := u.selectedItem()
+	if item == nil {
+		u.setWarn("nothing selected")
+		return
+	}
+	if item.AgentState != "yes" {
+		u.setWarn("agent is not running for this worktree")
+		return
+	}
+
+	branch := item.Branch
+	if branch == "" {
+		branch = filepath.Base(item.Path)
+	}
+
+	history := promptHistoryFor(item.Path)
+	historyIdx := len(history)
+	draft := ""
+
+	input := tview.NewInputField()
+	styleModalInputField(input)
+	input.SetPlaceholder("instruction for the agent")
+	input.SetPlaceholderTextColor(paneBorderColor())
+
+	hints := tview.NewTextView().SetDynamicColors(true).SetWrap(false)
+	hints.SetTextColor(paneBorderColor())
+	hints.SetBackgroundColor(tcell.ColorDefault)
+
+	counter := tview.NewTextView().
+		SetDynamicColors(true).
+		SetWrap(false).
+		SetTextAlign(tview.AlignRight)
+	counter.SetTextColor(paneBorderColor())
+	counter.SetBackgroundColor(tcell.ColorDefault)
+
+	updateHints := func() {
+		if len(history) == 0 {
+			hints.SetText(" enter send  esc cancel")
+			counter.SetText("")
+			return
+		}
+		hints.SetText(" ↑↓ history  enter send  esc cancel")
+		if historyIdx >= len(history) {
+			counter.SetText(fmt.Sprintf("new / %d  ", len(history)))
+			return
+		}
+		counter.SetText(fmt.Sprintf("%d of %d  ", historyIdx+1, len(history)))
+	}
+	recall := func(delta int) {
+		if len(history) == 0 {
+			return
+		}
+		if historyIdx >= len(history) {
+			draft = input.GetText()
+		}
+		next := historyIdx + delta
+		if next < 0 {
+			next = 0
+		}
+		if next > len(history) {
+			next = len(history)
+		}
+		historyIdx = next
+		if historyIdx == len(history) {
+			input.SetText(draft)
+		} else {
+			input.SetText(history[historyIdx].Prompt)
+		}
+		updateHints()
+	}
+
+	send := func() {
+		prompt := strings.TrimSpace(input.GetText())
+		if prompt == "" {
+			u.setWarn("prompt is empty")
+			return
+		}
+		u.closeModal("prompt")
+		if _, err := u.mgr.SendAgentCommand(item.Path, prompt); err != nil {
+			u.setError("send prompt failed: %v", err)
+			return
+		}
+		u.setInfo("prompt sent: %s", branch)
+	}
+	cancel := func() {
+		u.closeModal("prompt")
+	}
+
+	input.SetInputCapture(func(ev *tcell.EventKey) *tcell.EventKey {
+		switch ev.Key() {
+		case tcell.KeyEscape:
+			cancel()
+			return nil
+		case tcell.KeyEnter:
+			send()
+			return nil
+		case tcell.KeyUp:
+			recall(-1)
+			return nil
+		case tcell.KeyDown:
+			recall(1)
+			return nil
+		}
+		return ev
+	})
+
+	footer := tview.NewFlex().
+		AddItem(hints, 0, 1, false).
+		AddItem(counter, 12, 0, false)
+	footer.SetBackgroundColor(tcell.ColorDefault)
+
+	layout := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(modalHeader(fmt.Sprintf("Send Prompt: %s", tview.Escape(branch))), 1, 0, false).
+		AddItem(nil, 1, 0, false).
+		AddItem(modalFieldBox("Prompt", input), 3, 0, true).
+		AddItem(nil, 1, 0, false).
+		AddItem(footer, 1, 0, false)
+	layout.SetBackgroundColor(tcell.ColorDefault)
+
+	updateHints()
+	u.showModal("prompt", layout, 96, 9)
+	u.app.SetFocus(input)
+}
+
+// newWorktreeWithinWIPLimit opens the create modal, first asking to finish
+// or prune a worktree when the WIP limit is reached.
+func (u *tuiState) newWorktreeWithinWIPLimit() {
+	status, err := u.mgr.WIPStatus()
+	if err != nil || !status.Exceeded() {
+		u.showCreateModal()
+		return
+	}
+
+	var candidate *Worktree
+	if len(status.Candidates) > 0 {
+		candidate = &status.Candidates[0]
+	}
+	createAnyway := func() {
+		u.closeModal("wip")
+		u.showCreateModal()
+	}
+	prune := func() {
+		u.closeModal("wip")
+		if candidate == nil {
+			return
+		}
+		u.selectPath(candidate.Path)
+		if item := u.selectedItem(); item == nil || item.Path != candidate.Path {
+			u.setWarn("%s is hidden by the filter", worktreeBranchOrName(candidate))
+			return
+		}
+		u.showDeleteModal()
+	}
+	cancel := func() {
+		u.closeModal("wip")
+		u.setWarn("WIP limit reached (%d/%d)", status.Count, status.Limit)
+	}
+
+	msg := tview.NewTextView().SetDynamicColors(true)
+	msg.SetBackgroundColor(tcell.ColorDefault)
+	msg.SetTextColor(tcell.ColorDefault)
+	msg.SetWrap(true)
+	msg.SetText(tview.Escape(wipLimitMessage(status, 5)))
+	msg.SetBorder(true)
+	msg.SetBorderColor(paneBorderColor())
+
+	action := tview.NewTextView().
+		SetDynamicColors(true).
+		SetWrap(false)
+	action.SetBackgroundColor(tcell.ColorDefault)
+	action.SetTextColor(ansiColor(ansiYellow))
+	action.SetText(fmt.Sprintf(" WIP limit reached: [::b]%d/%d[::-] worktrees", status.Count, status.Limit))
+
+	pruneLabel := "Nothing to prune"
+	if candidate != nil {
+		pruneLabel = "Remove " + worktreeBranchOrName(candidate)
+	}
+	options := tview.NewTable().
+		SetSelectable(true, false).
+		SetBorders(false)
+	options.SetSeparator(' ')
+	options.SetBackgroundColor(tcell.ColorDefault)
+	options.SetSelectedStyle(tcell.StyleDefault.Foreground(tcell.ColorDefault).Background(tcell.ColorDefault).Reverse(true))
+	options.SetBorder(true)
+	options.SetBorderColor(paneBorderColor())
+	options.SetCell(0, 0, tview.NewTableCell("x").SetTextColor(ansiColor(ansiCyan)).SetExpansion(1))
+	options.SetCell(0, 1, tview.NewTableCell(pruneLabel).SetTextColor(tcell.ColorDefault).SetExpansion(1))
+	options.SetCell(1, 0, tview.NewTableCell("n").SetTextColor(ansiColor(ansiCyan)).SetExpansion(1))
+	options.SetCell(1, 1, tview.NewTableCell("Create anyway").SetTextColor(tcell.ColorDefault).SetExpansion(1))
+	options.SetCell(2, 0, tview.NewTableCell("c").SetTextColor(ansiColor(ansiCyan)).SetExpansion(1))
+	options.SetCell(2, 1, tview.NewTableCell("Cancel").SetTextColor(tcell.ColorDefault).SetExpansion(1))
+
+	selectOption := func(row int) {
+		switch row {
+		case 0:
+			prune()
+		case 1:
+			createAnyway()
+		default:
+			cancel()
+		}
+	}
+	options.SetSelectedFunc(func(row, _ int) {
+		selectOption(row)
+	})
+	options.SetInputCapture(func(ev *tcell.EventKey) *tcell.EventKey {
+		switch ev.Key() {
+		case tcell.KeyEnter:
+			row, _ := options.GetSelection()
+			selectOption(row)
+			return nil
+		case tcell.KeyEscape:
+			cancel()
+			return nil
+		}
+		if ev.Key() == tcell.KeyRune {
+			switch unicode.ToLower(ev.Rune()) {
+			case 'x':
+				prune()
+				return nil
+			case 'n':
+				createAnyway()
+				return nil
+			case 'c':
+				cancel()
+				return nil
+			case 'j':
+				row, _ := options.GetSelection()
+				if row < 2 {
+					options.Select(row+1, 0)
+				}
+				return nil
+			case 'k':
+				row, _ := options.GetSelection()
+				if row > 0 {
+					options.Select(row-1, 0)
+				}
+				return nil
+			}
+		}
+		return ev
+	})
+
+	layout := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(action, 1, 0, false).
+		AddItem(nil, 1, 0, false).
+		AddItem(options, 5, 0, true).
+		AddItem(nil, 1, 0, false).
+		AddItem(msg, 5, 0, false)
+	layout.SetBackgroundColor(tcell.ColorDefault)
+
+	u.showModal("wip", layout, 96, 14)
+	options.Select(0, 0)
+	u.app.SetFocus(options)
+}
+
+// quit leaves the TUI, first applying on_quit to agents that are still
+// running so they are not forgotten overnight.
+func (u *tuiState) quit() {
+	action := u.mgr.Cfg.OnQuit
+	if action == "" || action == quitActionNone {
+		u.app.Stop()
+		return
+	}
+	plan, err := u.mgr.ShutdownPlan()
+	if err != nil {
+		u.setError("shutdown check failed: %v", err)
+		return
+	}
+	switch {
+	case action == quitActionAsk && !plan.Empty(false):
+		u.showQuitModal(plan)
+	case action == quitActionStopAgents || action == quitActionDetach:
+		u.shutdownAndQuit(plan, action == quitActionDetach)
+	default:
+		u.app.Stop()
+	}
+}
+
+func (u *tuiState) shutdownAndQuit(plan ShutdownPlan, detach bool) {
+	if plan.Empty(detach) {
+		u.app.Stop()
+		return
+	}
+	if _, err := u.mgr.Shutdown(plan, detach); err != nil {
+		u.refresh(nil)
+		u.setError("shutdown failed: %v", err)
+		return
+	}
+	u.app.Stop()
+}
+
+// showQuitModal lists the running agents and asks whether to stop them, and
+// optionally detach every session, before quitting.
+// showAgentFallbackModal explains that the agent command is missing and
+// offers the installed agent types instead.
+func (u *tuiState) showAgentFallbackModal(notFound *AgentNotFoundError, start func(agentType string)) {
+	alternatives := notFound.Alternatives
+	if len(alternatives) > 9 {
+		alternatives = alternatives[:9]
+	}
+	cancel := func() {
+		u.closeModal("agent-fallback")
+		u.setError("agent start failed: %v", notFound)
+	}
+	choose := func(row int) {
+		if row < 0 || row >= len(alternatives) {
+			cancel()
+			return
+		}
+		u.closeModal("agent-fallback")
+		start(alternatives[row])
+	}
+
+	action := tview.NewTextView().
+		SetDynamicColors(true).
+		SetWrap(true)
+	action.SetBackgroundColor(tcell.ColorDefault)
+	action.SetTextColor(ansiColor(ansiYellow))
+	action.SetText(fmt.Sprintf(" [::b]%s[::-]. Start another agent instead?", tview.Escape(notFound.Error())))
+
+	options := tview.NewTable().
+		SetSelectable(true, false).
+		SetBorders(false)
+	options.SetSeparator(' ')
+	options.SetBackgroundColor(tcell.ColorDefault)
+	options.SetSelectedStyle(tcell.StyleDefault.Foreground(tcell.ColorDefault).Background(tcell.ColorDefault).Reverse(true))
+	options.SetBorder(true)
+	options.SetBorderColor(paneBorderColor())
+	for i, agentType := range alternatives {
+		command := commandExecutableName(u.mgr.Cfg.AgentCommands[agentType])
+		options.SetCell(i, 0, tview.NewTableCell(strconv.Itoa(i+1)).SetTextColor(ansiColor(ansiCyan)).SetExpansion(1))
+		options.SetCell(i, 1, tview.NewTableCell(fmt.Sprintf("Start %s (%s)", agentType, command)).SetTextColor(tcell.ColorDefault).SetExpansion(1))
+	}
+	rows := len(alternatives) + 1
+	options.SetCell(len(alternatives), 0, tview.NewTableCell("c").SetTextColor(ansiColor(ansiCyan)).SetExpansion(1))
+	options.SetCell(len(alternatives), 1, tview.NewTableCell("Cancel").SetTextColor(tcell.ColorDefault).SetExpansion(1))
+
+	options.SetSelectedFunc(func(row, _ int) {
+		choose(row)
+	})
+	options.SetInputCapture(func(ev *tcell.EventKey) *tcell.EventKey {
+		switch ev.Key() {
+		case tcell.KeyEnter:
+			row, _ := options.GetSelection()
+			choose(row)
+			return nil
+		case tcell.KeyEscape:
+			cancel()
+			return nil
+		}
+		if ev.Key() == tcell.KeyRune {
+			switch r := unicode.ToLower(ev.Rune()); {
+			case r >= '1' && r <= '9':
+				if n := int(r - '1'); n < len(alternatives) {
+					choose(n)
+				}
+				return nil
+			case r == 'c':
+				cancel()
+				return nil
+			case r == 'j':
+				row, _ := options.GetSelection()
+				if row < rows-1 {
+					options.Select(row+1, 0)
+				}
+				return nil
+			case r == 'k':
+				row, _ := options.GetSelection()
+				if row > 0 {
+					options.Select(row-1, 0)
+				}
+				return nil
+			}
+		}
+		return ev
+	})
+
+	layout := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(action, 2, 0, false).
+		AddItem(nil, 1, 0, false).
+		AddItem(options, rows+2, 0, true)
+	layout.SetBackgroundColor(tcell.ColorDefault)
+
+	u.showModal("agent-fallback", layout, 72, rows+7)
+	options.Select(0, 0)
+	u.app.SetFocus(options)
+}
+
+func (u *tuiState) showQuitModal(plan ShutdownPlan) {
+	stop := func() {
+		u.closeModal("quit")
+		u.shutdownAndQuit(plan, false)
+	}
+	detach := func() {
+		u.closeModal("quit")
+		u.shutdownAndQuit(plan, true)
+	}
+	leave := func() {
+		u.closeModal("quit")
+		u.app.Stop()
+	}
+	cancel := func() {
+		u.closeModal("quit")
+	}
+
+	msg := tview.NewTextView().SetDynamicColors(true)
+	msg.SetBackgroundColor(tcell.ColorDefault)
+	msg.SetTextColor(tcell.ColorDefault)
+	msg.SetWrap(true)
+	msg.SetText(tview.Escape("Running: " + plan.Summary(true)))
+	msg.SetBorder(true)
+	msg.SetBorderColor(paneBorderColor())
+
+	action := tview.NewTextView().
+		SetDynamicColors(true).
+		SetWrap(false)
+	action.SetBackgroundColor(tcell.ColorDefault)
+	action.SetTextColor(ansiColor(ansiYellow))
+	action.SetText(fmt.Sprintf(" Quit with [::b]%d[::-] agent(s) still running?", len(plan.Agents)))
+
+	options := tview.NewTable().
+		SetSelectable(true, false).
+		SetBorders(false)
+	options.SetSeparator(' ')
+	options.SetBackgroundColor(tcell.ColorDefault)
+	options.SetSelectedStyle(tcell.StyleDefault.Foreground(tcell.ColorDefault).Background(tcell.ColorDefault).Reverse(true))
+	options.SetBorder(true)
+	options.SetBorderColor(paneBorderColor())
+	rows := [][2]string{
+		{"s", "Stop agents and quit"},
+		{"d", "Stop agents, detach all sessions, and quit"},
+		{"q", "Quit and leave them running"},
+		{"c", "Cancel"},
+	}
+	for i, row := range rows {
+		options.SetCell(i, 0, tview.NewTableCell(row[0]).SetTextColor(ansiColor(ansiCyan)).SetExpansion(1))
+		options.SetCell(i, 1, tview.NewTableCell(row[1]).SetTextColor(tcell.ColorDefault).SetExpansion(1))
+	}
+
+	selectOption := func(row int) {
+		switch row {
+		case 0:
+			stop()
+		case 1:
+			detach()
+		case 2:
+			leave()
+		default:
+			cancel()
+		}
+	}
+	options.SetSelectedFunc(func(row, _ int) {
+		selectOption(row)
+	})
+	options.SetInputCapture(func(ev *tcell.EventKey) *tcell.EventKey {
+		switch ev.Key() {
+		case tcell.KeyEnter:
+			row, _ := options.GetSelection()
+			selectOption(row)
+			return nil
+		case tcell.KeyEscape:
+			cancel()
+			return nil
+		}
+		if ev.Key() == tcell.KeyRune {
+			switch unicode.ToLower(ev.Rune()) {
+			case 's':
+				stop()
+				return nil
+			case 'd':
+				detach()
+				return nil
+			case 'q':
+				leave()
+				return nil
+			case 'c':
+				cancel()
+				return nil
+			case 'j':
+				row, _ := options.GetSelection()
+				if row < len(rows)-1 {
+					options.Select(row+1, 0)
+				}
+				return nil
+			case 'k':
+				row, _ := options.GetSelection()
+				if row > 0 {
+					options.Select(row-1, 0)
+				}
+				return nil
+			}
+		}
+		return ev
+	})
+
+	layout := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(action, 1, 0, false).
+		AddItem(nil, 1, 0, false).
+		AddItem(options, 6, 0, true).
+		AddItem(nil, 1, 0, false).
+		AddItem(msg, 5, 0, false)
+	layout.SetBackgroundColor(tcell.ColorDefault)
+
+	u.showModal("quit", layout, 96, 15)
+	options.Select(0, 0)
+	u.app.SetFocus(options)
+}
+
+// setDiffBase switches the diff tab to compare with base.
+func (u *tuiState) setDiffBase(base DiffBase) {
+	u.diffBase = base
+	u.diffRev = ""
+	u.lastDiff = ""
+	u.renderDiffDetail()
+	u.setInfo("diff against %s", base.Label())
+}
+
+func (u *tuiState) showDiffBaseModal() {
+	item := u.selectedItem()
+	if item == nil {
+		u.setWarn("nothing selected")
+		return
+	}
+	cancel := func() {
+		u.closeModal("diff-base")
+		u.focusPane(u.diffFiles)
+	}
+	pick := func(kind string) {
+		u.closeModal("diff-base")
+		u.focusPane(u.diffFiles)
+		if kind == diffBaseRef {
+			u.showDiffRefModal()
+			return
+		}
+		u.setDiffBase(DiffBase{Kind: kind})
+	}
+
+	action := tview.NewTextView().
+		SetDynamicColors(true).
+		SetWrap(false)
+	action.SetBackgroundColor(tcell.ColorDefault)
+	action.SetTextColor(ansiColor(ansiYellow))
+	action.SetText(fmt.Sprintf(" Compare [::b]%s[::-] with:", tview.Escape(worktreeBranchOrName(item))))
+
+	options := tview.NewTable().
+		SetSelectable(true, false).
+		SetBorders(false)
+	options.SetSeparator(' ')
+	options.SetBackgroundColor(tcell.ColorDefault)
+	options.SetSelectedStyle(tcell.StyleDefault.Foreground(tcell.ColorDefault).Background(tcell.ColorDefault).Reverse(true))
+	options.SetBorder(true)
+	options.SetBorderColor(paneBorderColor())
+	rows := []struct {
+		key  rune
+		kind string
+		what string
+	}{
+		{'w', diffBaseWorking, "Working tree: staged and unstaged changes"},
+		{'h', diffBaseHead, "HEAD: all uncommitted changes in one patch"},
+		{'m', diffBaseMergeBase, "Merge-base with the base branch: everything on the branch"},
+		{'c', diffBaseCheckpoint, "Last checkpoint: changes since the last prompt to the agent"},
+		{'r', diffBaseRef, "Ref...: a branch, tag, or commit"},
+	}
+	selected := 0
+	for i, row := range rows {
+		marker := " "
+		if row.kind == u.diffBase.Kind {
+			marker = "*"
+			selected = i
+		}
+		options.SetCell(i, 0, tview.NewTableCell(string(row.key)).SetTextColor(ansiColor(ansiCyan)))
+		options.SetCell(i, 1, tview.NewTableCell(marker).SetTextColor(ansiColor(ansiGreen)))
+		options.SetCell(i, 2, tview.NewTableCell(row.what).SetTextColor(tcell.ColorDefault).SetExpansion(1))
+	}
+	options.SetSelectedFunc(func(row, _ int) {
+		if row >= 0 && row < len(rows) {
+			pick(rows[row].kind)
+		}
+	})
+	options.SetInputCapture(func(ev *tcell.EventKey) *tcell.EventKey {
+		switch ev.Key() {
+		case tcell.KeyEnter:
+			row, _ := options.GetSelection()
+			if row >= 0 && row < len(rows) {
+				pick(rows[row].kind)
+			}
+			return nil
+		case tcell.KeyEscape:
+			cancel()
+			return nil
+		case tcell.KeyRune:
+			for _, row := range rows {
+				if ev.Rune() == row.key {
+					pick(row.kind)
+					return nil
+				}
+			}
+			switch ev.Rune() {
+			case 'j':
+				row, _ := options.GetSelection()
+				if row < len(rows)-1 {
+					options.Select(row+1, 0)
+				}
+				return nil
+			case 'k':
+				row, _ := options.GetSelection()
+				if row > 0 {
+					options.Select(row-1, 0)
+				}
+				return nil
+			}
+		}
+		return ev
+	})
+
+	layout := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(action, 1, 0, false).
+		AddItem(nil, 1, 0, false).
+		AddItem(options, len(rows)+2, 0, true)
+	layout.SetBackgroundColor(tcell.ColorDefault)
+
+	u.showModal("diff-base", layout, 76, len(rows)+6)
+	options.Select(selected, 0)
+	u.app.SetFocus(options)
+}
+
+func (u *tuiState) showDiffRefModal() {
+	item := u.selectedItem()
+	if item == nil {
+		u.setWarn("nothing selected")
+		return
+	}
+	input := tview.NewInputField().SetText(u.diffBase.Ref)
+	styleModalInputField(input)
+
+	apply := func() {
+		base := DiffBase{Kind: diffBaseRef, Ref: strings.TrimSpace(input.GetText())}
+		if _, err := u.mgr.diffBaseRevision(u.repoRoot, item, base); err != nil {
+			u.setError("%v", err)
+			return
+		}
+		u.closeModal("diff-ref")
+		u.focusPane(u.diffFiles)
+		u.setDiffBase(base)
+	}
+	cancel := func() {
+		u.closeModal("diff-ref")
+		u.focusPane(u.diffFiles)
+	}
+
+	applyBtn := modalButton("<a> Apply", apply)
+	cancelBtn := modalButton("<c> Cancel", cancel)
+
+	row := tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(applyBtn, 12, 0, false).
+		AddItem(nil, 2, 0, false).
+		AddItem(cancelBtn, 12, 0, false).
+		AddItem(nil, 0, 1, false)
+
+	layout := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(modalHeader("Diff Against Ref"), 1, 0, false).
+		AddItem(nil, 1, 0, false).
+		AddItem(modalFieldBox("Branch, Tag, or Commit", input), 3, 0, true).
+		AddItem(nil, 1, 0, false).
+		AddItem(row, 1, 0, false)
+	layout.SetBackgroundColor(tcell.ColorDefault)
+
+	focusables := []tview.Primitive{input, applyBtn, cancelBtn}
+	capture := modalCapture(u.app, focusables, cancel, map[rune]func(){
+		'a': apply,
+		'c': cancel,
+	})
+	for _, p := range focusables {
+		setPrimitiveInputCapture(p, capture)
+	}
+	input.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEnter {
+			apply()
+		}
+	})
+
+	u.showModal("diff-ref", layout, 76, 11)
+	u.app.SetFocus(input)
+}
+
+func (u *tuiState) showCreateModal() {
+	repoRoot, err := u.mgr.RequireRepo()
+	if err != nil {
+		u.setError("not in a git repo: %v", err)
+		return
+	}
+
+	allBranches, _ := u.mgr.ListBranches(repoRoot)
+	creating := false
+
+	type branchRow struct {
+		name     string
+		isNew    bool
+		isRemote bool
+	}
+	var displayRows []branchRow
+
+	input := tview.NewInputField()
+	styleModalInputField(input)
+	input.SetPlaceholder("type to filter or enter a new branch name")
+	input.SetPlaceholderTextColor(paneBorderColor())
+
+	// fieldErrors shows why the typed or selected branch cannot be created.
+	fieldErrors := tview.NewTextView().SetDynamicColors(true).SetWrap(false)
+	fieldErrors.SetBackgroundColor(tcell.ColorDefault)
+	fieldErrors.SetTextColor(ansiColor(ansiRed))
+
+	branchTable := tview.NewTable().
+		SetSelectable(true, false).
+		SetFixed(1, 0).
+		SetBorders(false)
+	branchTable.SetSeparator(' ')
+	branchTable.SetBackgroundColor(tcell.ColorDefault)
+	branchTable.SetSelectedStyle(tcell.StyleDefault.Foreground(tcell.ColorDefault).Background(tcell.ColorDefault).Reverse(true))
+	branchTable.SetBorder(true)
+	branchTable.SetBorderColor(paneBorderColor())
+
+	counter := tview.NewTextView().
+		SetDynamicColors(true).
+		SetWrap(false).
+		SetTextAlign(tview.AlignRight)
+	counter.SetTextColor(paneBorderColor())
+	counter.SetBackgroundColor(tcell.ColorDefault)
+
+	hints := tview.NewTextView().SetDynamicColors(true).SetWrap(false)
+	hints.SetTextColor(paneBorderColor())
+	hints.SetBackgroundColor(tcell.ColorDefault)
+	hints.SetText(" ↑↓/jk navigate  enter select  c/esc cancel")
+
+	updateCounter := func(dataIdx int) {
+		total := len(displayRows)
+		if total == 0 {
+			counter.SetText("")
+			return
+		}
+		n := dataIdx + 1
+		if n < 1 {
+			n = 1
+		}
+		counter.SetText(fmt.Sprintf("%d of %d  ", n, total))
+	}
+
+	rebuildTable := func(query string) {
+		displayRows = nil
+		branchTable.Clear()
+
+		branchTable.SetCell(0, 0, tview.NewTableCell("").SetSelectable(false))
+		branchTable.SetCell(0, 1, tview.NewTableCell("BRANCH").
+			SetTextColor(ansiColor(ansiCyan)).SetSelectable(false).SetExpansion(1))
+		branchTable.SetCell(0, 2, tview.NewTableCell("WHERE").
+			SetTextColor(ansiColor(ansiCyan)).SetSelectable(false))
+		branchTable.SetCell(0, 3, tview.NewTableCell("AGE").
+			SetTextColor(ansiColor(ansiCyan)).SetSelectable(false))
+		branchTable.SetCell(0, 4, tview.NewTableCell("AUTHOR").
+			SetTextColor(ansiColor(ansiCyan)).SetSelectable(false))
+		branchTable.SetCell(0, 5, tview.NewTableCell("LAST COMMIT").
+			SetTextColor(ansiColor(ansiCyan)).SetSelectable(false).SetExpansion(1))
+		now := time.Now()
+
+		rowIdx := 1
+		lq := strings.ToLower(strings.TrimSpace(query))
+
+		// Synthetic "new branch" entry when query doesn't exactly match any existing branch
+		if lq != "" {
+			exactMatch := false
+			for _, b := range allBranches {
+				if strings.ToLower(b.Name) == lq {
+					exactMatch = true
+					break
+				}
+			}
+			if !exactMatch {
+				name := strings.TrimSpace(query)
+				branchTable.SetCell(rowIdx, 0, tview.NewTableCell("✦").SetTextColor(ansiColor(ansiGreen)).SetSelectable(true))
+				branchTable.SetCell(rowIdx, 1, tview.NewTableCell(tview.Escape(name)).SetTextColor(tcell.ColorDefault).SetSelectable(true).SetExpansion(1))
+				branchTable.SetCell(rowIdx, 2, tview.NewTableCell("new").SetTextColor(paneBorderColor()).SetSelectable(true))
+				for col := 3; col <= 5; col++ {
+					branchTable.SetCell(rowIdx, col, tview.NewTableCell("").SetSelectable(true))
+				}
+				displayRows = append(displayRows, branchRow{name: name, isNew: true})
+				rowIdx++
+			}
+		}
+
+		type branchMatch struct {
+			info      BranchInfo
+			score     int
+			positions []int
+		}
+		matches := make([]branchMatch, 0, len(allBranches))
+		for _, b := range allBranches {
+			score, positions, ok := fuzzyMatch(lq, b.Name)
+			if !ok {
+				continue
+			}
+			matches = append(matches, branchMatch{info: b, score: score, positions: positions})
+		}
+		sort.SliceStable(matches, func(i, j int) bool { return matches[i].score > matches[j].score })
+
+		for _, match := range matches {
+			b := match.info
+			typeColor := paneBorderColor()
+			if b.Remote {
+				typeColor = ansiColor(ansiMagenta)
+			}
+			age := ""
+			ageColor := paneBorderColor()
+			if !b.LastCommit.IsZero() {
+				age = formatAge(b.LastCommit, now)
+				if now.Sub(b.LastCommit) > staleBranchAge {
+					ageColor = ansiColor(ansiYellow)
+				}
+			}
+			branchTable.SetCell(rowIdx, 0, tview.NewTableCell("").SetSelectable(true))
+			branchTable.SetCell(rowIdx, 1, tview.NewTableCell(highlightMatches(b.Name, match.positions, len(b.Name))).SetTextColor(tcell.ColorDefault).SetSelectable(true).SetExpansion(1))
+			branchTable.SetCell(rowIdx, 2, tview.NewTableCell(b.Location()).SetTextColor(typeColor).SetSelectable(true))
+			branchTable.SetCell(rowIdx, 3, tview.NewTableCell(age).SetTextColor(ageColor).SetSelectable(true))
+			branchTable.SetCell(rowIdx, 4, tview.NewTableCell(truncate(b.Author, 16)).SetTextColor(paneBorderColor()).SetSelectable(true))
+			branchTable.SetCell(rowIdx, 5, tview.NewTableCell(truncate(b.Subject, 36)).SetTextColor(paneBorderColor()).SetSelectable(true).SetExpansion(1))
+			displayRows = append(displayRows, branchRow{name: b.Name, isRemote: b.Remote})
+			rowIdx++
+		}
+
+		if len(displayRows) == 0 && lq == "" {
+			branchTable.SetCell(1, 0, tview.NewTableCell(""))
+			branchTable.SetCell(1, 1, tview.NewTableCell("no branches available — type a name to create one").
+				SetTextColor(paneBorderColor()).SetSelectable(false).SetExpansion(1))
+			for col := 2; col <= 5; col++ {
+				branchTable.SetCell(1, col, tview.NewTableCell(""))
+			}
+		}
+
+		if len(displayRows) > 0 {
+			branchTable.Select(1, 0)
+			updateCounter(0)
+		} else {
+			counter.SetText("")
+		}
+	}
+
+	doCreate := func(branch string, fromExisting bool, copyUntracked bool, prompt string) {
+		if creating {
+			return
+		}
+		branch = strings.TrimSpace(branch)
+		if branch == "" {
+			u.setWarn("branch name is required")
+			return
+		}
+		creating = true
+
+		totalSteps := 2 // create + refresh
+		if u.mgr.Cfg.AutoLaunch {
+			totalSteps++
+		}
+		prompt = strings.TrimSpace(prompt)
+		if u.mgr.autoStartAgent() || prompt != "" {
+			totalSteps++
+		}
+		if prompt != "" {
+			totalSteps++
+		}
+		ctx, cancelCreate := context.WithCancel(context.Background())
+		advance, setProgressLabel, setStepProgress, stopProgress := u.showProgressModal("create-progress", "Create Worktree", totalSteps, cancelCreate)
+
+		mgr := u.mgr
+		go func(branch string, fromExisting bool) {
+			var path string
+			var createErr error
+			warnings := []string{}
+			var agentMissing *AgentNotFoundError
+			var refreshed []Worktree
+			var refreshErr error
+
+			var opts NewOptions
+			lastCopyUpdate := time.Time{}
+			renderCopyLabel := func(p CopyProgress) string {
+				switch p.Phase {
+				case "scan":
+					if p.TotalFiles <= 0 {
+						return "Scanning untracked files..."
+					}
+					return fmt.Sprintf("Scanning untracked files... %d files, %s total", p.TotalFiles, formatByteSize(p.TotalBytes))
+				default:
+					remainingFiles := p.TotalFiles - p.CopiedFiles
+					if remainingFiles < 0 {
+						remainingFiles = 0
+					}
+					remainingBytes := p.TotalBytes - p.CopiedBytes
+					if remainingBytes < 0 {
+						remainingBytes = 0
+					}
+					return fmt.Sprintf(
+						"Copying untracked files... %d/%d files (%d remaining) • %s/%s (%s remaining)",
+						p.CopiedFiles, p.TotalFiles, remainingFiles,
+						formatByteSize(p.CopiedBytes), formatByteSize(p.TotalBytes), formatByteSize(remainingBytes),
+					)
+				}
+			}
+			onCopyProgress := func(p CopyProgress) {
+				now := time.Now()
+				// Throttle UI updates while still showing smooth progress.
+				if p.CopiedFiles != p.TotalFiles && !lastCopyUpdate.IsZero() && now.Sub(lastCopyUpdate) < 120*time.Millisecond {
+					return
+				}
+				lastCopyUpdate = now
+				setProgressLabel(renderCopyLabel(p))
+				progress := 0.0
+				switch p.Phase {
+				case "scan":
+					progress = 0.05
+				default:
+					if p.TotalBytes > 0 {
+						progress = float64(p.CopiedBytes) / float64(p.TotalBytes)
+					} else if p.TotalFiles > 0 {
+						progress = float64(p.CopiedFiles) / float64(p.TotalFiles)
+					} else {
+						progress = 1.0
+					}
+					// Reserve a tiny tail for worktree finalization in the same step.
+					progress = 0.1 + (0.88 * progress)
+				}
+				setStepProgress(progress)
+			}
+			if fromExisting {
+				opts = NewOptions{
+					FromBranch:        branch,
+					Launch:            false,
+					SkipCopyUntracked: !copyUntracked,
+					OnCopyProgress:    onCopyProgress,
+				}
+			} else {
+				opts = NewOptions{
+					Branch:            branch,
+					Launch:            false,
+					SkipCopyUntracked: !copyUntracked,
+					OnCopyProgress:    onCopyProgress,
+				}
+			}
+
+			infoLogf("ui_create start branch=%q existing=%t auto_launch=%t auto_start_agent=%t prompt=%t", branch, fromExisting, mgr.Cfg.AutoLaunch, mgr.Cfg.AutoStartAgent, prompt != "")
+			advance("Creating worktree...")
+			_, path, createErr = mgr.NewWorktree(ctx, opts)
+			if createErr != nil {
+				errorLogf("ui_create new_worktree failed branch=%q: %v", branch, createErr)
+			}
+			// A cancel that comes once the worktree exists keeps it and skips
+			// the launch and agent steps left.
+			wantLaunch := createErr == nil && mgr.Cfg.AutoLaunch
+			// A prompt starts the agent even without auto_start_agent.
+			wantAgent := createErr == nil && (mgr.autoStartAgent() || prompt != "")
+
+			if wantLaunch && ctx.Err() == nil {
+				advance("Launching tmux tools...")
+				if _, err := mgr.Launch(LaunchOptions{Target: path, NoAttach: true}); err != nil {
+					errorLogf("ui_create auto_launch failed path=%q: %v", path, err)
+					warnings = append(warnings, fmt.Sprintf("launch failed: %v", err))
+				}
+			}
+			if wantAgent && ctx.Err() == nil {
+				advance("Starting agent...")
+				if _, _, err := mgr.StartAgent(ctx, AgentOptions{Target: path, Attach: false}); err != nil && ctx.Err() == nil {
+					errorLogf("ui_create auto_agent failed path=%q: %v", path, err)
+					warnings = append(warnings, fmt.Sprintf("agent start failed: %v", err))
+					errors.As(err, &agentMissing)
+				} else if prompt != "" && ctx.Err() == nil {
+					advance("Waiting for the agent to be ready for the prompt...")
+					if err := mgr.sendWhenReady(ctx, path, prompt, agentPromptTimeout); err != nil && ctx.Err() == nil {
+						errorLogf("ui_create prompt failed path=%q: %v", path, err)
+						warnings = append(warnings, fmt.Sprintf("prompt not sent: %v", err))
+					}
+				}
+			}
+
+			if createErr == nil {
+				advance("Refreshing worktrees...")
+				refreshed, refreshErr = mgr.ListWorktrees(context.Background())
+				if refreshErr != nil {
+					errorLogf("ui_create refresh failed path=%q: %v", path, refreshErr)
+				}
+			}
+			launchCanceled := (wantLaunch || wantAgent) && ctx.Err() != nil
+			cancelCreate()
+
+			u.app.QueueUpdateDraw(func() {
+				stopProgress()
+				u.closeModal("create-progress")
+
+				if createErr != nil {
+					if canceledBy(ctx, createErr) {
+						u.setWarn("create canceled: %s", branch)
+						return
+					}
+					u.setError("create failed: %v", createErr)
+					return
+				}
+
+				if refreshErr == nil {
+					u.refreshRepoChoices()
+					u.items = refreshed
+					u.applyFilter()
+					u.renderTable()
+					u.renderTableMeta()
+					u.renderDetails()
+					u.renderStatusPane()
+					u.selectPath(path)
+				}
+
+				if agentMissing != nil && len(agentMissing.Alternatives) > 0 {
+					u.showAgentFallbackModal(agentMissing, func(alt string) { u.startAgent(&Worktree{Path: path}, alt) })
+				}
+				if launchCanceled {
+					u.setWarn("created: %s (launch canceled)", path)
+					return
+				}
+				if len(warnings) > 0 {
+					u.setWarn("created: %s (warnings: %s)", path, strings.Join(warnings, " | "))
+					return
+				}
+				if refreshErr != nil {
+					u.setWarn("created: %s (refresh failed: %v)", path, refreshErr)
+					return
+				}
+				infoLogf("ui_create success path=%q warnings=%d", path, len(warnings))
+				u.setInfo("created: %s", path)
+			})
+		}(branch, fromExisting)
+	}
+
+	// validate checks the request up front so problems show next to the
+	// branch field instead of after the progress modal.
+	validate := func(branch string, fromExisting bool) bool {
+		opts := NewOptions{Branch: branch}
+		if fromExisting {
+			opts = NewOptions{FromBranch: branch}
+		}
+		_, err := u.mgr.ValidateNew(opts)
+		if err == nil {
+			fieldErrors.SetText("")
+			return true
+		}
+		var verr *ValidationError
+		if !errors.As(err, &verr) {
+			fieldErrors.SetText(" " + tview.Escape(err.Error()))
+			return false
+		}
+		parts := make([]string, 0, len(verr.Fields))
+		for _, f := range verr.Fields {
+			parts = append(parts, fmt.Sprintf("%s: %s", f.Field, f.Message))
+		}
+		fieldErrors.SetText(" " + tview.Escape(strings.Join(parts, "; ")))
+		return false
+	}
+
+	openCreateConfirm := func(branch string, fromExisting bool) {
+		branch = strings.TrimSpace(branch)
+		if !validate(branch, fromExisting) {
+			return
+		}
+
+		u.closeModal("create")
+
+		msg := tview.NewTextView().SetDynamicColors(true)
+		msg.SetBackgroundColor(tcell.ColorDefault)
+		msg.SetTextColor(tcell.ColorDefault)
+		msg.SetWrap(true)
+		mode := "new branch"
+		if fromExisting {
+			mode = "existing branch"
+			for _, b := range allBranches {
+				if b.Name == branch && b.Remote {
+					mode = "new local branch tracking " + b.RemoteRef
+					break
+				}
+			}
+		}
+		msg.SetText(fmt.Sprintf(
+			"Create worktree [::b]%s[::-] (%s)?\n\nChoose whether to include untracked + ignored files from the repo root. Tab to type a first prompt; the agent starts and gets it once it is ready.",
+			branch,
+			mode,
+		))
+		msg.SetBorder(true)
+		msg.SetBorderColor(paneBorderColor())
+
+		// promptField is an optional first prompt for the worktree's agent.
+		promptField := tview.NewInputField()
+		styleModalInputField(promptField)
+		promptField.SetLabel(" prompt: ")
+		promptField.SetLabelColor(ansiColor(ansiCyan))
+		promptField.SetPlaceholder("optional first prompt for the agent (tab to edit)")
+		promptField.SetPlaceholderTextColor(paneBorderColor())
+
+		confirm := func(copyUntracked bool) {
+			u.closeModal("create-confirm")
+			doCreate(branch, fromExisting, copyUntracked, promptField.GetText())
+		}
+		cancel := func() {
+			u.closeModal("create-confirm")
+			u.showCreateModal()
+		}
+		var render func()
+
+		action := tview.NewTextView().
+			SetDynamicColors(true).
+			SetWrap(false)
+		action.SetBackgroundColor(tcell.ColorDefault)
+		action.SetTextColor(ansiColor(ansiCyan))
+
+		options := tview.NewTable().
+			SetSelectable(true, false).
+			SetBorders(false)
+		options.SetSeparator(' ')
+		options.SetBackgroundColor(tcell.ColorDefault)
+		options.SetSelectedStyle(tcell.StyleDefault.Foreground(tcell.ColorDefault).Background(tcell.ColorDefault).Reverse(true))
+		options.SetBorder(true)
+		options.SetBorderColor(paneBorderColor())
+
+		render = func() {
+			action.SetText(fmt.Sprintf(" r - Create worktree [::b]%s[::-]   u - Create with untracked files", branch))
+
+			options.SetCell(0, 0, tview.NewTableCell("r").SetTextColor(ansiColor(ansiCyan)).SetExpansion(1))
+			options.SetCell(0, 1, tview.NewTableCell("Create worktree").SetTextColor(tcell.ColorDefault).SetExpansion(1))
+			options.SetCell(1, 0, tview.NewTableCell("u").SetTextColor(ansiColor(ansiCyan)).SetExpansion(1))
+			options.SetCell(1, 1, tview.NewTableCell("Create with untracked + ignored files").SetTextColor(tcell.ColorDefault).SetExpansion(1))
+			options.SetCell(2, 0, tview.NewTableCell("c").SetTextColor(ansiColor(ansiCyan)).SetExpansion(1))
+			options.SetCell(2, 1, tview.NewTableCell("Cancel").SetTextColor(tcell.ColorDefault).SetExpansion(1))
+		}
+		render()
+
+		selectOption := func(row int) {
+			switch row {
+			case 0:
+				confirm(false)
+			case 1:
+				confirm(true)
+			default:
+				cancel()
+			}
+		}
+		options.SetSelectedFunc(func(row, _ int) {
+			selectOption(row)
+		})
+		promptField.SetInputCapture(func(ev *tcell.EventKey) *tcell.EventKey {
+			switch ev.Key() {
+			case tcell.KeyEnter:
+				confirm(false)
+				return nil
+			case tcell.KeyTab, tcell.KeyBacktab, tcell.KeyEscape:
+				u.app.SetFocus(options)
+				return nil
+			}
+			return ev
+		})
+		options.SetInputCapture(func(ev *tcell.EventKey) *tcell.EventKey {
+			switch ev.Key() {
+			case tcell.KeyEnter:
+				row, _ := options.GetSelection()
+				selectOption(row)
+				return nil
+			case tcell.KeyEscape:
+				cancel()
+				return nil
+			case tcell.KeyTab, tcell.KeyBacktab:
+				u.app.SetFocus(promptField)
+				return nil
+			}
+			if ev.Key() == tcell.KeyRune {
+				switch unicode.ToLower(ev.Rune()) {
+				case 'r':
+					confirm(false)
+					return nil
+				case 'u':
+					confirm(true)
+					return nil
+				case 'c':
+					cancel()
+					return nil
+				case 'j':
+					row, _ := options.GetSelection()
+					if row < 2 {
+						options.Select(row+1, 0)
+					}
+					return nil
+				case 'k':
+					row, _ := options.GetSelection()
+					if row > 0 {
+						options.Select(row-1, 0)
+					}
+					return nil
+				}
+			}
+			return ev
+		})
+
+		layout := tview.NewFlex().
+			SetDirection(tview.FlexRow).
+			AddItem(action, 1, 0, false).
+			AddItem(nil, 1, 0, false).
+			AddItem(options, 5, 0, true).
+			AddItem(nil, 1, 0, false).
+			AddItem(promptField, 1, 0, false).
+			AddItem(nil, 1, 0, false).
+			AddItem(msg, 6, 0, false)
+		layout.SetBackgroundColor(tcell.ColorDefault)
+
+		u.showModal("create-confirm", layout, 96, 17)
+		options.Select(0, 0)
+		u.app.SetFocus(options)
+	}
+
+	selectCurrentRow := func() {
+		row, _ := branchTable.GetSelection()
+		if row < 1 || row-1 >= len(displayRows) {
+			return
+		}
+		r := displayRows[row-1]
+		openCreateConfirm(r.name, !r.isNew)
+	}
+
+	cancel := func() {
+		u.closeModal("create")
+	}
+
+	input.SetChangedFunc(func(text string) {
+		fieldErrors.SetText("")
+		rebuildTable(text)
+	})
+	input.SetInputCapture(func(ev *tcell.EventKey) *tcell.EventKey {
+		switch ev.Key() {
+		case tcell.KeyEscape:
+			cancel()
+			return nil
+		case tcell.KeyEnter:
+			if len(displayRows) > 0 {
+				r := displayRows[0]
+				openCreateConfirm(r.name, !r.isNew)
+			} else {
+				openCreateConfirm(strings.TrimSpace(input.GetText()), false)
+			}
+			return nil
+		case tcell.KeyDown:
+			if len(displayRows) > 0 {
+				u.app.SetFocus(branchTable)
+				branchTable.Select(1, 0)
+				updateCounter(0)
+			}
+			return nil
+		case tcell.KeyTab:
+			if len(displayRows) > 0 {
+				u.app.SetFocus(branchTable)
+				branchTable.Select(1, 0)
+				updateCounter(0)
+			}
+			return nil
+		}
+		return ev
+	})
+
+	branchTable.SetSelectionChangedFunc(func(row, col int) {
+		if row >= 1 {
+			updateCounter(row - 1)
+		}
+	})
+	branchTable.SetSelectedFunc(func(row, col int) {
+		selectCurrentRow()
+	})
+	branchTable.SetInputCapture(func(ev *tcell.EventKey) *tcell.EventKey {
+		switch ev.Key() {
+		case tcell.KeyEscape:
+			cancel()
+			return nil
+		case tcell.KeyEnter:
+			selectCurrentRow()
+			return nil
+		case tcell.KeyUp:
+			row, _ := branchTable.GetSelection()
+			if row <= 1 {
+				u.app.SetFocus(input)
+				return nil
+			}
+		case tcell.KeyBacktab:
+			u.app.SetFocus(input)
+			return nil
+		}
+		if ev.Key() == tcell.KeyRune {
+			switch ev.Rune() {
+			case 'c':
+				cancel()
+				return nil
+			case 'j':
+				row, _ := branchTable.GetSelection()
+				if row < branchTable.GetRowCount()-1 {
+					branchTable.Select(row+1, 0)
+				}
+				return nil
+			case 'k':
+				row, _ := branchTable.GetSelection()
+				if row > 1 {
+					branchTable.Select(row-1, 0)
+				} else {
+					u.app.SetFocus(input)
+				}
+				return nil
+			}
+		}
+		return ev
+	})
+
+	footer := tview.NewFlex().
+		AddItem(hints, 0, 1, false).
+		AddItem(counter, 12, 0, false)
+	footer.SetBackgroundColor(tcell.ColorDefault)
+
+	layout := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(modalHeader("Create Worktree"), 1, 0, false).
+		AddItem(nil, 1, 0, false).
+		AddItem(modalFieldBox("Branch", input), 3, 0, false).
+		AddItem(fieldErrors, 1, 0, false).
+		AddItem(branchTable, 0, 1, false).
+		AddItem(nil, 1, 0, false).
+		AddItem(footer, 1, 0, false)
+	layout.SetBackgroundColor(tcell.ColorDefault)
+
+	rebuildTable("")
+	u.showModal("create", layout, 104, 24)
+	u.app.SetFocus(input)
+}
+
+func (u *tuiState) selectPath(path string) {
+	for pos, idx := range u.visible {
+		if u.items[idx].Path == path {
+			u.selected = pos
+			u.selectTableRow(u.selected+1, true)
+			u.renderDetails()
+			return
+		}
+	}
+}
+
+func (u *tuiState) selectTableRow(row int, force bool) {
+	if row < 0 {
+		row = 0
+	}
+	prev := u.forceTableSelect
+	u.forceTableSelect = force
+	u.table.Select(row, 0)
+	u.forceTableSelect = prev
+}
+
+func (u *tuiState) showDeleteModal() {
+	item := u.selectedItem()
+	if item == nil {
+		u.setWarn("nothing selected")
+		return
+	}
+
+	branch := item.Branch
+	if branch == "" {
+		branch = filepath.Base(item.Path)
+	}
+
+	risk := u.mgr.RemovalRisk(context.Background(), item)
+
+	removing := false
+	remove := func(deleteBranch bool) {
+		if removing {
+			return
+		}
+		removing = true
+		u.closeModal("delete-confirm")
+		u.closeModal("delete")
+		ctx, cancelRemove := context.WithCancel(context.Background())
+		advance, setProgressLabel, setStepProgress, stopProgress := u.showProgressModal("delete-progress", "Remove Worktree", 2, cancelRemove)
+
+		mgr := u.mgr
+		go func() {
+			lastDeleteUpdate := time.Time{}
+			renderDeleteLabel := func(p DeleteProgress) string {
+				switch p.Phase {
 				case "scan":
 					if p.TotalFiles <= 0 {
-						return "Scanning untracked files..."
+						return "Scanning worktree files..."
 					}
-					return fmt.Sprintf("Scanning untracked files... %d files, %s total", p.TotalFiles, formatByteSize(p.TotalBytes))
+					return fmt.Sprintf("Scanning worktree files... %d files, %s total", p.TotalFiles, formatByteSize(p.TotalBytes))
 				default:
-					remainingFiles := p.TotalFiles - p.CopiedFiles
+					remainingFiles := p.TotalFiles - p.DeletedFiles
 					if remainingFiles < 0 {
 						remainingFiles = 0
 					}
-					remainingBytes := p.TotalBytes - p.CopiedBytes
+					remainingBytes := p.TotalBytes - p.DeletedBytes
 					if remainingBytes < 0 {
 						remainingBytes = 0
 					}
-					return fmt.Sprintf(
-						"Copying untracked files... %d/%d files (%d remaining) • %s/%s (%s remaining)",
-						p.CopiedFiles, p.TotalFiles, remainingFiles,
-						formatByteSize(p.CopiedBytes), formatByteSize(p.TotalBytes), formatByteSize(remainingBytes),
+					label := fmt.Sprintf(
+						"Deleting files... %d/%d files (%d remaining) • %s/%s (%s remaining)",
+						p.DeletedFiles, p.TotalFiles, remainingFiles,
+						formatByteSize(p.DeletedBytes), formatByteSize(p.TotalBytes), formatByteSize(remainingBytes),
 					)
+					if p.CurrentPath != "" {
+						label = fmt.Sprintf("%s • %s", label, truncatePath(p.CurrentPath, 44))
+					}
+					return label
 				}
 			}
-			onCopyProgress := func(p CopyProgress) {
+			onDeleteProgress := func(p DeleteProgress) {
 				now := time.Now()
-				// Throttle UI updates while still showing smooth progress.
-				if p.CopiedFiles != p.TotalFiles && !lastCopyUpdate.IsZero() && now.Sub(lastCopyUpdate) < 120*time.Millisecond {
+				if p.Phase == "delete" && p.DeletedFiles != p.TotalFiles && !lastDeleteUpdate.IsZero() && now.Sub(lastDeleteUpdate) < 120*time.Millisecond {
 					return
 				}
-				lastCopyUpdate = now
-				setProgressLabel(renderCopyLabel(p))
+				lastDeleteUpdate = now
+				setProgressLabel(renderDeleteLabel(p))
 				progress := 0.0
 				switch p.Phase {
 				case "scan":
 					progress = 0.05
 				default:
 					if p.TotalBytes > 0 {
-						progress = float64(p.CopiedBytes) / float64(p.TotalBytes)
+						progress = float64(p.DeletedBytes) / float64(p.TotalBytes)
 					} else if p.TotalFiles > 0 {
-						progress = float64(p.CopiedFiles) / float64(p.TotalFiles)
+						progress = float64(p.DeletedFiles) / float64(p.TotalFiles)
 					} else {
 						progress = 1.0
 					}
-					// Reserve a tiny tail for worktree finalization in the same step.
 					progress = 0.1 + (0.88 * progress)
 				}
 				setStepProgress(progress)
 			}
-			if fromExisting {
-				opts = NewOptions{
-					FromBranch:        branch,
-					Launch:            false,
-					SkipCopyUntracked: !copyUntracked,
-					OnCopyProgress:    onCopyProgress,
-				}
-			} else {
-				opts = NewOptions{
-					Branch:            branch,
-					Launch:            false,
-					SkipCopyUntracked: !copyUntracked,
-					OnCopyProgress:    onCopyProgress,
-				}
-			}
-
-			debugLogf("ui_create start branch=%q existing=%t auto_launch=%t auto_start_agent=%t", branch, fromExisting, u.mgr.Cfg.AutoLaunch, u.mgr.Cfg.AutoStartAgent)
-			advance("Creating worktree...")
-			_, path, createErr = u.mgr.NewWorktree(opts)
-			if createErr != nil {
-				debugLogf("ui_create new_worktree failed branch=%q: %v", branch, createErr)
-			}
-
-			if createErr == nil && u.mgr.Cfg.AutoLaunch {
-				advance("Launching tmux tools...")
-				if _, err := u.mgr.Launch(LaunchOptions{Target: path, NoAttach: true}); err != nil {
-					debugLogf("ui_create auto_launch failed path=%q: %v", path, err)
-					warnings = append(warnings, fmt.Sprintf("launch failed: %v", err))
-				}
-			}
-			if createErr == nil && u.mgr.Cfg.AutoStartAgent {
-				advance("Starting agent...")
-				if _, _, err := u.mgr.StartAgent(AgentOptions{Target: path, Attach: false}); err != nil {
-					debugLogf("ui_create auto_agent failed path=%q: %v", path, err)
-					warnings = append(warnings, fmt.Sprintf("agent start failed: %v", err))
-				}
-			}
+			advance("Removing worktree...")
+			mainPath := ""
+			_, warnings, removeErr := mgr.Remove(ctx, RemoveOptions{
+				Target:           item.Path,
+				Force:            item.Dirty || item.Locked || deleteBranch,
+				DeleteBranch:     deleteBranch,
+				OnDeleteProgress: onDeleteProgress,
+				SwitchToMain:     true,
+				OnSwitch:         func(p string) { mainPath = p },
+			})
+			canceled := canceledBy(ctx, removeErr)
+			cancelRemove()
 
-			if createErr == nil {
+			var refreshed []Worktree
+			var refreshErr error
+			// A canceled removal may have deleted part of the worktree, so
+			// show what is left.
+			if removeErr == nil || canceled {
 				advance("Refreshing worktrees...")
-				refreshed, refreshErr = u.mgr.ListWorktrees()
-				if refreshErr != nil {
-					debugLogf("ui_create refresh failed path=%q: %v", path, refreshErr)
-				}
+				refreshed, refreshErr = mgr.ListWorktrees(context.Background())
 			}
 
 			u.app.QueueUpdateDraw(func() {
 				stopProgress()
-				u.closeModal("create-progress")
+				u.closeModal("delete-progress")
+				if mainPath != "" {
+					u.repoRoot = mainPath
+					u.leftRemoved = mainPath
+				}
 
-				if createErr != nil {
-					u.setError("create failed: %v", createErr)
+				if removeErr != nil && !canceled {
+					u.setError("remove failed: %v", removeErr)
 					return
 				}
 
@@ -2795,240 +6605,527 @@ func (u *tuiState) showCreateModal() {
 					u.renderTableMeta()
 					u.renderDetails()
 					u.renderStatusPane()
-					u.selectPath(path)
 				}
 
-				if len(warnings) > 0 {
-					u.setWarn("created: %s (warnings: %s)", path, strings.Join(warnings, " | "))
-					return
-				}
-				if refreshErr != nil {
-					u.setWarn("created: %s (refresh failed: %v)", path, refreshErr)
-					return
+				if canceled {
+					u.setWarn("remove canceled: %v", removeErr)
+				} else if len(warnings) > 0 {
+					u.setWarn("removed with warning: %s", warnings[0])
+				} else {
+					if u.mgr.Cfg.UndoMinutes > 0 {
+						u.setInfo("removed: %s (u restores it for %dm)", branch, u.mgr.Cfg.UndoMinutes)
+					} else {
+						u.setInfo("removed: %s", branch)
+					}
 				}
-				debugLogf("ui_create success path=%q warnings=%d", path, len(warnings))
-				u.setInfo("created: %s", path)
 			})
-		}(branch, fromExisting)
+		}()
 	}
-
-	openCreateConfirm := func(branch string, fromExisting bool) {
-		branch = strings.TrimSpace(branch)
-		if branch == "" {
-			u.setWarn("branch name is required")
+	cancel := func() {
+		u.closeModal("delete")
+	}
+	// Work that would be lost has to be confirmed by typing the branch name.
+	confirm := func(deleteBranch bool) {
+		if !risk.AtRisk(deleteBranch) {
+			remove(deleteBranch)
 			return
 		}
+		u.closeModal("delete")
+		u.showRemoveConfirmModal(branch, risk, deleteBranch, func() { remove(deleteBranch) })
+	}
 
-		u.closeModal("create")
-
-		msg := tview.NewTextView().SetDynamicColors(true)
-		msg.SetBackgroundColor(tcell.ColorDefault)
-		msg.SetTextColor(tcell.ColorDefault)
-		msg.SetWrap(true)
-		mode := "new branch"
-		if fromExisting {
-			mode = "existing branch"
-		}
-		msg.SetText(fmt.Sprintf(
-			"Create worktree [::b]%s[::-] (%s)?\n\nChoose whether to include untracked + ignored files from the repo root.",
-			branch,
-			mode,
-		))
-		msg.SetBorder(true)
-		msg.SetBorderColor(paneBorderColor())
-
-		confirm := func(copyUntracked bool) {
-			u.closeModal("create-confirm")
-			doCreate(branch, fromExisting, copyUntracked)
-		}
-		cancel := func() {
-			u.closeModal("create-confirm")
-			u.showCreateModal()
+	msg := tview.NewTextView().SetDynamicColors(true)
+	msg.SetBackgroundColor(tcell.ColorDefault)
+	msg.SetTextColor(tcell.ColorDefault)
+	msg.SetWrap(true)
+	lockNote := ""
+	removeLabel := "Remove worktree"
+	// Five lines of text inside the border.
+	msgHeight := 7
+	if item.Locked {
+		msgHeight += 2
+		lockNote = "\n\n" + colorTag(ColorYellow) + "This worktree is locked"
+		if item.LockReason != "" {
+			lockNote += ": " + tview.Escape(item.LockReason)
 		}
-		var render func()
-
-		action := tview.NewTextView().
-			SetDynamicColors(true).
-			SetWrap(false)
-		action.SetBackgroundColor(tcell.ColorDefault)
-		action.SetTextColor(ansiColor(ansiCyan))
-
-		options := tview.NewTable().
-			SetSelectable(true, false).
-			SetBorders(false)
-		options.SetSeparator(' ')
-		options.SetBackgroundColor(tcell.ColorDefault)
-		options.SetSelectedStyle(tcell.StyleDefault.Foreground(tcell.ColorDefault).Background(tcell.ColorDefault).Reverse(true))
-		options.SetBorder(true)
-		options.SetBorderColor(paneBorderColor())
+		lockNote += ".[-] Removing it drops the lock."
+		removeLabel = "Remove locked worktree"
+	}
+	if item.Current && u.mgr.mainRepoRoot(u.repoRoot) != item.Path {
+		msgHeight += 2
+		lockNote += "\n\n" + colorTag(ColorYellow) + "sprout was started in this worktree.[-] It moves to the main worktree; cd there after quitting."
+	}
+	riskColor := ColorGreen
+	if risk.AtRisk(true) {
+		riskColor = ColorYellow
+	}
+	if risk.AtRisk(false) {
+		riskColor = ColorRed
+	}
+	msg.SetText(fmt.Sprintf(
+		"Remove worktree [::b]%s[::-]?\n\n%s%s[-]\n\n%s%s[-]%s",
+		branch,
+		colorTag(ColorCyan),
+		truncatePath(item.Path, 96),
+		colorTag(riskColor),
+		tview.Escape(risk.Summary(item.Branch)),
+		lockNote,
+	))
+	msg.SetBorder(true)
+	msg.SetBorderColor(paneBorderColor())
 
-		render = func() {
-			action.SetText(fmt.Sprintf(" r - Create worktree [::b]%s[::-]   u - Create with untracked files", branch))
+	action := tview.NewTextView().
+		SetDynamicColors(true).
+		SetWrap(false)
+	action.SetBackgroundColor(tcell.ColorDefault)
+	action.SetTextColor(ansiColor(ansiCyan))
+	action.SetText(fmt.Sprintf(" r - %s [::b]%s[::-]", removeLabel, branch))
 
-			options.SetCell(0, 0, tview.NewTableCell("r").SetTextColor(ansiColor(ansiCyan)).SetExpansion(1))
-			options.SetCell(0, 1, tview.NewTableCell("Create worktree").SetTextColor(tcell.ColorDefault).SetExpansion(1))
-			options.SetCell(1, 0, tview.NewTableCell("u").SetTextColor(ansiColor(ansiCyan)).SetExpansion(1))
-			options.SetCell(1, 1, tview.NewTableCell("Create with untracked + ignored files").SetTextColor(tcell.ColorDefault).SetExpansion(1))
-			options.SetCell(2, 0, tview.NewTableCell("c").SetTextColor(ansiColor(ansiCyan)).SetExpansion(1))
-			options.SetCell(2, 1, tview.NewTableCell("Cancel").SetTextColor(tcell.ColorDefault).SetExpansion(1))
-		}
-		render()
+	options := tview.NewTable().
+		SetSelectable(true, false).
+		SetBorders(false)
+	options.SetSeparator(' ')
+	options.SetBackgroundColor(tcell.ColorDefault)
+	options.SetSelectedStyle(tcell.StyleDefault.Foreground(tcell.ColorDefault).Background(tcell.ColorDefault).Reverse(true))
+	options.SetBorder(true)
+	options.SetBorderColor(paneBorderColor())
+	options.SetCell(0, 0, tview.NewTableCell("r").SetTextColor(ansiColor(ansiCyan)).SetExpansion(1))
+	options.SetCell(0, 1, tview.NewTableCell(removeLabel).SetTextColor(tcell.ColorDefault).SetExpansion(1))
+	rows := 2
+	if item.Branch != "" {
+		options.SetCell(1, 0, tview.NewTableCell("d").SetTextColor(ansiColor(ansiCyan)).SetExpansion(1))
+		options.SetCell(1, 1, tview.NewTableCell(removeLabel+" and delete branch").SetTextColor(tcell.ColorDefault).SetExpansion(1))
+		rows++
+	}
+	options.SetCell(rows-1, 0, tview.NewTableCell("c").SetTextColor(ansiColor(ansiCyan)).SetExpansion(1))
+	options.SetCell(rows-1, 1, tview.NewTableCell("Cancel").SetTextColor(tcell.ColorDefault).SetExpansion(1))
 
-		selectOption := func(row int) {
-			switch row {
-			case 0:
-				confirm(false)
-			case 1:
-				confirm(true)
-			default:
-				cancel()
-			}
+	selectOption := func(row int) {
+		switch {
+		case row == 0:
+			confirm(false)
+		case row == 1 && rows == 3:
+			confirm(true)
+		default:
+			cancel()
 		}
-		options.SetSelectedFunc(func(row, _ int) {
+	}
+	options.SetSelectedFunc(func(row, _ int) {
+		selectOption(row)
+	})
+	options.SetInputCapture(func(ev *tcell.EventKey) *tcell.EventKey {
+		switch ev.Key() {
+		case tcell.KeyEnter:
+			row, _ := options.GetSelection()
 			selectOption(row)
-		})
-		options.SetInputCapture(func(ev *tcell.EventKey) *tcell.EventKey {
-			switch ev.Key() {
-			case tcell.KeyEnter:
-				row, _ := options.GetSelection()
-				selectOption(row)
+			return nil
+		case tcell.KeyEscape:
+			cancel()
+			return nil
+		}
+		if ev.Key() == tcell.KeyRune {
+			switch unicode.ToLower(ev.Rune()) {
+			case 'r':
+				confirm(false)
 				return nil
-			case tcell.KeyEscape:
+			case 'd':
+				if item.Branch != "" {
+					confirm(true)
+				}
+				return nil
+			case 'c':
 				cancel()
 				return nil
-			}
-			if ev.Key() == tcell.KeyRune {
-				switch unicode.ToLower(ev.Rune()) {
-				case 'r':
-					confirm(false)
-					return nil
-				case 'u':
-					confirm(true)
-					return nil
-				case 'c':
-					cancel()
-					return nil
-				case 'j':
-					row, _ := options.GetSelection()
-					if row < 2 {
-						options.Select(row+1, 0)
-					}
-					return nil
-				case 'k':
-					row, _ := options.GetSelection()
-					if row > 0 {
-						options.Select(row-1, 0)
-					}
-					return nil
+			case 'j':
+				row, _ := options.GetSelection()
+				if row < rows-1 {
+					options.Select(row+1, 0)
+				}
+				return nil
+			case 'k':
+				row, _ := options.GetSelection()
+				if row > 0 {
+					options.Select(row-1, 0)
 				}
+				return nil
 			}
-			return ev
-		})
+		}
+		return ev
+	})
 
-		layout := tview.NewFlex().
-			SetDirection(tview.FlexRow).
-			AddItem(action, 1, 0, false).
-			AddItem(nil, 1, 0, false).
-			AddItem(options, 5, 0, true).
-			AddItem(nil, 1, 0, false).
-			AddItem(msg, 5, 0, false)
-		layout.SetBackgroundColor(tcell.ColorDefault)
+	layout := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(action, 1, 0, false).
+		AddItem(nil, 1, 0, false).
+		AddItem(options, rows+2, 0, true).
+		AddItem(nil, 1, 0, false).
+		AddItem(msg, msgHeight, 0, false)
+	layout.SetBackgroundColor(tcell.ColorDefault)
 
-		u.showModal("create-confirm", layout, 96, 14)
-		options.Select(0, 0)
-		u.app.SetFocus(options)
+	u.showModal("delete", layout, 96, 6+rows+msgHeight)
+	options.Select(0, 0)
+	u.app.SetFocus(options)
+}
+
+// showRemoveConfirmModal asks for branch to be typed before a removal that
+// would lose the work risk describes.
+func (u *tuiState) showRemoveConfirmModal(branch string, risk RemovalRisk, deleteBranch bool, remove func()) {
+	input := tview.NewInputField()
+	styleModalInputField(input)
+
+	lost := plural(risk.DirtyFiles, "uncommitted file")
+	if deleteBranch && risk.Unpushed > 0 {
+		lost = plural(risk.Unpushed, "unpushed commit")
+		if risk.DirtyFiles > 0 {
+			lost = plural(risk.DirtyFiles, "uncommitted file") + " and " + lost
+		}
 	}
+	note := tview.NewTextView().SetDynamicColors(true).SetWrap(true)
+	note.SetBackgroundColor(tcell.ColorDefault)
+	note.SetText(fmt.Sprintf("%sThis loses %s.[-] Type [::b]%s[::-] to remove it anyway.", colorTag(ColorRed), lost, tview.Escape(branch)))
 
-	selectCurrentRow := func() {
-		row, _ := branchTable.GetSelection()
-		if row < 1 || row-1 >= len(displayRows) {
+	submit := func() {
+		if strings.TrimSpace(input.GetText()) != branch {
+			u.setWarn("type %s to confirm", branch)
 			return
 		}
-		r := displayRows[row-1]
-		openCreateConfirm(r.name, !r.isNew)
+		remove()
+	}
+	cancel := func() {
+		u.closeModal("delete-confirm")
+	}
+	input.SetDoneFunc(func(key tcell.Key) {
+		switch key {
+		case tcell.KeyEnter:
+			submit()
+		case tcell.KeyEscape:
+			cancel()
+		}
+	})
+
+	layout := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(modalHeader("Confirm Removal"), 1, 0, false).
+		AddItem(nil, 1, 0, false).
+		AddItem(note, 2, 0, false).
+		AddItem(modalFieldBox("Branch", input), 3, 0, true)
+	layout.SetBackgroundColor(tcell.ColorDefault)
+
+	u.showModal("delete-confirm", layout, 76, 9)
+	u.app.SetFocus(input)
+}
+
+func (u *tuiState) showDetachModal() {
+	item := u.selectedItem()
+	if item == nil {
+		u.setWarn("nothing selected")
+		return
 	}
 
+	branch := item.Branch
+	if branch == "" {
+		branch = filepath.Base(item.Path)
+	}
+
+	detach := func() {
+		path, detached, err := u.mgr.Detach(item.Path)
+		if err != nil {
+			u.setError("detach failed: %v", err)
+			return
+		}
+		u.closeModal("detach")
+		u.refresh(func(err error) {
+			if err != nil {
+				u.setWarn("detached, but refresh failed: %v", err)
+				return
+			}
+			if !detached {
+				u.setInfo("session was not running: %s", path)
+				return
+			}
+			u.setInfo("detached: %s", path)
+		})
+	}
 	cancel := func() {
-		u.closeModal("create")
+		u.closeModal("detach")
 	}
 
-	input.SetChangedFunc(func(text string) {
-		rebuildTable(text)
+	msg := tview.NewTextView().SetDynamicColors(true)
+	msg.SetBackgroundColor(tcell.ColorDefault)
+	msg.SetTextColor(tcell.ColorDefault)
+	msg.SetWrap(true)
+	msg.SetText(fmt.Sprintf(
+		"Detach from worktree [::b]%s[::-]?\n\nThis will kill the tmux session for this worktree only.\n\n%s%s[-]",
+		branch,
+		colorTag(ColorCyan),
+		truncatePath(item.Path, 96),
+	))
+	msg.SetBorder(true)
+	msg.SetBorderColor(paneBorderColor())
+
+	action := tview.NewTextView().
+		SetDynamicColors(true).
+		SetWrap(false)
+	action.SetBackgroundColor(tcell.ColorDefault)
+	action.SetTextColor(ansiColor(ansiCyan))
+	action.SetText(fmt.Sprintf(" x - Detach worktree [::b]%s[::-]", branch))
+
+	options := tview.NewTable().
+		SetSelectable(true, false).
+		SetBorders(false)
+	options.SetSeparator(' ')
+	options.SetBackgroundColor(tcell.ColorDefault)
+	options.SetSelectedStyle(tcell.StyleDefault.Foreground(tcell.ColorDefault).Background(tcell.ColorDefault).Reverse(true))
+	options.SetBorder(true)
+	options.SetBorderColor(paneBorderColor())
+	options.SetCell(0, 0, tview.NewTableCell("x").SetTextColor(ansiColor(ansiCyan)).SetExpansion(1))
+	options.SetCell(0, 1, tview.NewTableCell("Detach session").SetTextColor(tcell.ColorDefault).SetExpansion(1))
+	options.SetCell(1, 0, tview.NewTableCell("c").SetTextColor(ansiColor(ansiCyan)).SetExpansion(1))
+	options.SetCell(1, 1, tview.NewTableCell("Cancel").SetTextColor(tcell.ColorDefault).SetExpansion(1))
+
+	selectOption := func(row int) {
+		switch row {
+		case 0:
+			detach()
+		default:
+			cancel()
+		}
+	}
+	options.SetSelectedFunc(func(row, _ int) {
+		selectOption(row)
 	})
-	input.SetInputCapture(func(ev *tcell.EventKey) *tcell.EventKey {
+	options.SetInputCapture(func(ev *tcell.EventKey) *tcell.EventKey {
 		switch ev.Key() {
-		case tcell.KeyEscape:
-			cancel()
-			return nil
 		case tcell.KeyEnter:
-			if len(displayRows) > 0 {
-				r := displayRows[0]
-				openCreateConfirm(r.name, !r.isNew)
-			} else {
-				openCreateConfirm(strings.TrimSpace(input.GetText()), false)
-			}
+			row, _ := options.GetSelection()
+			selectOption(row)
 			return nil
-		case tcell.KeyDown:
-			if len(displayRows) > 0 {
-				u.app.SetFocus(branchTable)
-				branchTable.Select(1, 0)
-				updateCounter(0)
-			}
+		case tcell.KeyEscape:
+			cancel()
 			return nil
-		case tcell.KeyTab:
-			if len(displayRows) > 0 {
-				u.app.SetFocus(branchTable)
-				branchTable.Select(1, 0)
-				updateCounter(0)
+		}
+		if ev.Key() == tcell.KeyRune {
+			switch unicode.ToLower(ev.Rune()) {
+			case 'x':
+				detach()
+				return nil
+			case 'c':
+				cancel()
+				return nil
+			case 'j':
+				row, _ := options.GetSelection()
+				if row < 1 {
+					options.Select(row+1, 0)
+				}
+				return nil
+			case 'k':
+				row, _ := options.GetSelection()
+				if row > 0 {
+					options.Select(row-1, 0)
+				}
+				return nil
 			}
-			return nil
 		}
 		return ev
 	})
 
-	branchTable.SetSelectionChangedFunc(func(row, col int) {
-		if row >= 1 {
-			updateCounter(row - 1)
+	layout := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(action, 1, 0, false).
+		AddItem(nil, 1, 0, false).
+		AddItem(options, 4, 0, true).
+		AddItem(nil, 1, 0, false).
+		AddItem(msg, 5, 0, false)
+	layout.SetBackgroundColor(tcell.ColorDefault)
+
+	u.showModal("detach", layout, 96, 13)
+	options.Select(0, 0)
+	u.app.SetFocus(options)
+}
+
+// mergeCurrent checks, off the UI goroutine since it may ask gh about the
+// pull request, whether the selected branch can be merged, then asks how.
+func (u *tuiState) mergeCurrent() {
+	item := u.selectedItem()
+	if item == nil {
+		u.setWarn("nothing selected")
+		return
+	}
+	path := item.Path
+	u.setInfo("checking %s before merging...", item.Branch)
+	mgr := u.mgr
+	go func() {
+		// Failing checks are shown in the modal instead of refusing.
+		plan, err := mgr.PlanMerge(context.Background(), MergeOptions{Target: path, SkipChecks: true})
+		u.app.QueueUpdateDraw(func() {
+			if err != nil {
+				u.setError("merge: %v", err)
+				return
+			}
+			u.showMergeModal(plan)
+		})
+	}()
+}
+
+func (u *tuiState) showMergeModal(plan MergePlan) {
+	cleanup := false
+	merging := false
+	merge := func(squash bool) {
+		if merging {
+			return
+		}
+		merging = true
+		u.closeModal("merge")
+		opts := MergeOptions{Target: plan.Path, BaseBranch: plan.Base, Squash: squash, DeleteBranch: cleanup, SkipChecks: true}
+		u.setInfo("merging %s into %s...", plan.Branch, plan.Base)
+		mgr := u.mgr
+		go func() {
+			res, err := mgr.Merge(context.Background(), opts)
+			u.app.QueueUpdateDraw(func() {
+				if err != nil {
+					u.setError("merge failed: %v", err)
+					return
+				}
+				u.refresh(func(refreshErr error) {
+					if refreshErr != nil {
+						u.setWarn("merged, but refresh failed: %v", refreshErr)
+						return
+					}
+					switch {
+					case len(res.Warnings) > 0:
+						u.setWarn("merged %s into %s with warning: %s", res.Branch, res.Base, res.Warnings[0])
+					case res.Removed:
+						u.setInfo("merged %s into %s and removed it", res.Branch, res.Base)
+					default:
+						u.setInfo("merged %s into %s (%s)", res.Branch, res.Base, shortHash(res.Commit))
+					}
+				})
+			})
+		}()
+	}
+	cancel := func() {
+		u.closeModal("merge")
+	}
+
+	msg := tview.NewTextView().SetDynamicColors(true)
+	msg.SetBackgroundColor(tcell.ColorDefault)
+	msg.SetTextColor(tcell.ColorDefault)
+	msg.SetWrap(true)
+	checksColor := ColorGray
+	switch plan.Checks {
+	case mergeChecksPassed:
+		checksColor = ColorGreen
+	case mergeChecksFailing:
+		checksColor = ColorRed
+	case mergeChecksPending:
+		checksColor = ColorYellow
+	}
+	where := "a temporary worktree"
+	if plan.Into != "" {
+		where = truncatePath(plan.Into, 60)
+	}
+	commits := "1 commit"
+	if plan.Commits != 1 {
+		commits = fmt.Sprintf("%d commits", plan.Commits)
+	}
+	msg.SetText(fmt.Sprintf(
+		"Merge [::b]%s[::-] (%s) into [::b]%s[::-] in %s%s[-]\n\nPull request checks: %s%s[-]",
+		tview.Escape(plan.Branch),
+		commits,
+		tview.Escape(plan.Base),
+		colorTag(ColorCyan),
+		tview.Escape(where),
+		colorTag(checksColor),
+		plan.Checks,
+	))
+	msg.SetBorder(true)
+	msg.SetBorderColor(paneBorderColor())
+
+	action := tview.NewTextView().
+		SetDynamicColors(true).
+		SetWrap(false)
+	action.SetBackgroundColor(tcell.ColorDefault)
+	action.SetTextColor(ansiColor(ansiCyan))
+	action.SetText(fmt.Sprintf(" m - Merge [::b]%s[::-] into [::b]%s[::-]", tview.Escape(plan.Branch), tview.Escape(plan.Base)))
+
+	options := tview.NewTable().
+		SetSelectable(true, false).
+		SetBorders(false)
+	options.SetSeparator(' ')
+	options.SetBackgroundColor(tcell.ColorDefault)
+	options.SetSelectedStyle(tcell.StyleDefault.Foreground(tcell.ColorDefault).Background(tcell.ColorDefault).Reverse(true))
+	options.SetBorder(true)
+	options.SetBorderColor(paneBorderColor())
+	cleanupLabel := func() string {
+		if cleanup {
+			return "Then remove the worktree and delete the branch: yes"
+		}
+		return "Then remove the worktree and delete the branch: no"
+	}
+	rows := []struct{ key, label string }{
+		{"m", "Merge (merge commit)"},
+		{"s", "Squash-merge (one commit)"},
+		{"d", cleanupLabel()},
+		{"c", "Cancel"},
+	}
+	for i, r := range rows {
+		options.SetCell(i, 0, tview.NewTableCell(r.key).SetTextColor(ansiColor(ansiCyan)).SetExpansion(1))
+		options.SetCell(i, 1, tview.NewTableCell(r.label).SetTextColor(tcell.ColorDefault).SetExpansion(1))
+	}
+	toggleCleanup := func() {
+		cleanup = !cleanup
+		options.GetCell(2, 1).SetText(cleanupLabel())
+	}
+
+	selectOption := func(row int) {
+		switch row {
+		case 0:
+			merge(false)
+		case 1:
+			merge(true)
+		case 2:
+			toggleCleanup()
+		default:
+			cancel()
 		}
+	}
+	options.SetSelectedFunc(func(row, _ int) {
+		selectOption(row)
 	})
-	branchTable.SetSelectedFunc(func(row, col int) {
-		selectCurrentRow()
-	})
-	branchTable.SetInputCapture(func(ev *tcell.EventKey) *tcell.EventKey {
+	options.SetInputCapture(func(ev *tcell.EventKey) *tcell.EventKey {
 		switch ev.Key() {
-		case tcell.KeyEscape:
-			cancel()
-			return nil
 		case tcell.KeyEnter:
-			selectCurrentRow()
+			row, _ := options.GetSelection()
+			selectOption(row)
 			return nil
-		case tcell.KeyUp:
-			row, _ := branchTable.GetSelection()
-			if row <= 1 {
-				u.app.SetFocus(input)
-				return nil
-			}
-		case tcell.KeyBacktab:
-			u.app.SetFocus(input)
+		case tcell.KeyEscape:
+			cancel()
 			return nil
 		}
 		if ev.Key() == tcell.KeyRune {
-			switch ev.Rune() {
+			switch unicode.ToLower(ev.Rune()) {
+			case 'm':
+				merge(false)
+				return nil
+			case 's':
+				merge(true)
+				return nil
+			case 'd':
+				toggleCleanup()
+				return nil
 			case 'c':
 				cancel()
 				return nil
 			case 'j':
-				row, _ := branchTable.GetSelection()
-				if row < branchTable.GetRowCount()-1 {
-					branchTable.Select(row+1, 0)
+				row, _ := options.GetSelection()
+				if row < len(rows)-1 {
+					options.Select(row+1, 0)
 				}
 				return nil
 			case 'k':
-				row, _ := branchTable.GetSelection()
-				if row > 1 {
-					branchTable.Select(row-1, 0)
-				} else {
-					u.app.SetFocus(input)
+				row, _ := options.GetSelection()
+				if row > 0 {
+					options.Select(row-1, 0)
 				}
 				return nil
 			}
@@ -3036,175 +7133,86 @@ func (u *tuiState) showCreateModal() {
 		return ev
 	})
 
-	footer := tview.NewFlex().
-		AddItem(hints, 0, 1, false).
-		AddItem(counter, 12, 0, false)
-	footer.SetBackgroundColor(tcell.ColorDefault)
-
 	layout := tview.NewFlex().
 		SetDirection(tview.FlexRow).
-		AddItem(modalHeader("Create Worktree"), 1, 0, false).
-		AddItem(nil, 1, 0, false).
-		AddItem(modalFieldBox("Branch", input), 3, 0, false).
+		AddItem(action, 1, 0, false).
 		AddItem(nil, 1, 0, false).
-		AddItem(branchTable, 0, 1, false).
+		AddItem(options, len(rows)+2, 0, true).
 		AddItem(nil, 1, 0, false).
-		AddItem(footer, 1, 0, false)
+		AddItem(msg, 5, 0, false)
 	layout.SetBackgroundColor(tcell.ColorDefault)
 
-	rebuildTable("")
-	u.showModal("create", layout, 86, 24)
-	u.app.SetFocus(input)
+	u.showModal("merge", layout, 96, len(rows)+11)
+	options.Select(0, 0)
+	u.app.SetFocus(options)
 }
 
-func (u *tuiState) selectPath(path string) {
-	for pos, idx := range u.visible {
-		if u.items[idx].Path == path {
-			u.selected = pos
-			u.selectTableRow(u.selected+1, true)
-			u.renderDetails()
-			return
-		}
+// pickSelectedFile applies the selected file's changes, as the diff tab
+// shows them, to another worktree.
+func (u *tuiState) pickSelectedFile() {
+	item := u.selectedItem()
+	if item == nil || u.diffSel < 0 || u.diffSel >= len(u.diffItems) {
+		u.setWarn("no changed file selected")
+		return
 	}
-}
-
-func (u *tuiState) selectTableRow(row int, force bool) {
-	if row < 0 {
-		row = 0
+	file := u.diffItems[u.diffSel].Path
+	since := u.diffRev
+	if since == "" {
+		since = "HEAD"
 	}
-	prev := u.forceTableSelect
-	u.forceTableSelect = force
-	u.table.Select(row, 0)
-	u.forceTableSelect = prev
+	u.showPickModal(item, file, PickOptions{Source: item.Path, Files: []string{file}, Since: since})
 }
 
-func (u *tuiState) showDeleteModal() {
+// showDiscardFileModal asks before throwing away the uncommitted changes to
+// the selected file of the diff tab.
+func (u *tuiState) showDiscardFileModal() {
 	item := u.selectedItem()
-	if item == nil {
-		u.setWarn("nothing selected")
+	if item == nil || u.diffSel < 0 || u.diffSel >= len(u.diffItems) {
+		u.setWarn("no changed file selected")
 		return
 	}
-
-	branch := item.Branch
-	if branch == "" {
-		branch = filepath.Base(item.Path)
+	if u.diffRev != "" {
+		u.setWarn("discarding works on the working tree diff; press b to switch to it")
+		return
 	}
-
-	removing := false
-	remove := func() {
-		if removing {
+	file := u.diffItems[u.diffSel]
+	path := item.Path
+	focus := u.app.GetFocus()
+	cancel := func() {
+		u.closeModal("discard-file")
+		u.focusPane(focus)
+	}
+	discard := func() {
+		u.closeModal("discard-file")
+		u.focusPane(focus)
+		if err := u.mgr.DiscardFileChanges(path, file); err != nil {
+			u.setError("discard failed: %v", err)
 			return
 		}
-		removing = true
-		u.closeModal("delete")
-		advance, setProgressLabel, setStepProgress, stopProgress := u.showProgressModal("delete-progress", "Remove Worktree", 2)
-
-		go func() {
-			lastDeleteUpdate := time.Time{}
-			renderDeleteLabel := func(p DeleteProgress) string {
-				switch p.Phase {
-				case "scan":
-					if p.TotalFiles <= 0 {
-						return "Scanning worktree files..."
-					}
-					return fmt.Sprintf("Scanning worktree files... %d files, %s total", p.TotalFiles, formatByteSize(p.TotalBytes))
-				default:
-					remainingFiles := p.TotalFiles - p.DeletedFiles
-					if remainingFiles < 0 {
-						remainingFiles = 0
-					}
-					remainingBytes := p.TotalBytes - p.DeletedBytes
-					if remainingBytes < 0 {
-						remainingBytes = 0
-					}
-					label := fmt.Sprintf(
-						"Deleting files... %d/%d files (%d remaining) • %s/%s (%s remaining)",
-						p.DeletedFiles, p.TotalFiles, remainingFiles,
-						formatByteSize(p.DeletedBytes), formatByteSize(p.TotalBytes), formatByteSize(remainingBytes),
-					)
-					if p.CurrentPath != "" {
-						label = fmt.Sprintf("%s • %s", label, truncatePath(p.CurrentPath, 44))
-					}
-					return label
-				}
-			}
-			onDeleteProgress := func(p DeleteProgress) {
-				now := time.Now()
-				if p.Phase == "delete" && p.DeletedFiles != p.TotalFiles && !lastDeleteUpdate.IsZero() && now.Sub(lastDeleteUpdate) < 120*time.Millisecond {
-					return
-				}
-				lastDeleteUpdate = now
-				setProgressLabel(renderDeleteLabel(p))
-				progress := 0.0
-				switch p.Phase {
-				case "scan":
-					progress = 0.05
-				default:
-					if p.TotalBytes > 0 {
-						progress = float64(p.DeletedBytes) / float64(p.TotalBytes)
-					} else if p.TotalFiles > 0 {
-						progress = float64(p.DeletedFiles) / float64(p.TotalFiles)
-					} else {
-						progress = 1.0
-					}
-					progress = 0.1 + (0.88 * progress)
-				}
-				setStepProgress(progress)
-			}
-			advance("Removing worktree...")
-			_, warnings, removeErr := u.mgr.Remove(RemoveOptions{
-				Target:           item.Path,
-				Force:            item.Dirty,
-				DeleteBranch:     false,
-				OnDeleteProgress: onDeleteProgress,
-			})
-
-			var refreshed []Worktree
-			var refreshErr error
-			if removeErr == nil {
-				advance("Refreshing worktrees...")
-				refreshed, refreshErr = u.mgr.ListWorktrees()
+		u.clearDiffCaches()
+		u.refresh(func(refreshErr error) {
+			if refreshErr != nil {
+				u.setWarn("discarded %s, refresh failed: %v", file.Path, refreshErr)
+				return
 			}
-
-			u.app.QueueUpdateDraw(func() {
-				stopProgress()
-				u.closeModal("delete-progress")
-
-				if removeErr != nil {
-					u.setError("remove failed: %v", removeErr)
-					return
-				}
-
-				if refreshErr == nil {
-					u.refreshRepoChoices()
-					u.items = refreshed
-					u.applyFilter()
-					u.renderTable()
-					u.renderTableMeta()
-					u.renderDetails()
-					u.renderStatusPane()
-				}
-
-				if len(warnings) > 0 {
-					u.setWarn("removed with warning: %s", warnings[0])
-				} else {
-					u.setInfo("removed: %s", branch)
-				}
-			})
-		}()
-	}
-	cancel := func() {
-		u.closeModal("delete")
+			u.setInfo("discarded changes to %s", file.Path)
+		})
 	}
 
+	what := "Its uncommitted changes, staged and unstaged, are replaced by the last commit's version."
+	if strings.TrimSpace(file.Status) == "??" {
+		what = "It is untracked, so the file is deleted."
+	}
 	msg := tview.NewTextView().SetDynamicColors(true)
 	msg.SetBackgroundColor(tcell.ColorDefault)
 	msg.SetTextColor(tcell.ColorDefault)
 	msg.SetWrap(true)
 	msg.SetText(fmt.Sprintf(
-		"Remove worktree [::b]%s[::-]?\n\n[cyan]%s[-]",
-		branch,
-		truncatePath(item.Path, 96),
+		"Discard the changes to [::b]%s[::-] in %s?\n\n%s\n\n%sThis cannot be undone.[-]",
+		tview.Escape(file.Path),
+		tview.Escape(worktreeBranchOrName(item)),
+		what,
+		colorTag(ColorRed),
 	))
 	msg.SetBorder(true)
 	msg.SetBorderColor(paneBorderColor())
@@ -3213,8 +7221,8 @@ func (u *tuiState) showDeleteModal() {
 		SetDynamicColors(true).
 		SetWrap(false)
 	action.SetBackgroundColor(tcell.ColorDefault)
-	action.SetTextColor(ansiColor(ansiCyan))
-	action.SetText(fmt.Sprintf(" r - Remove worktree [::b]%s[::-]", branch))
+	action.SetTextColor(ansiColor(ansiYellow))
+	action.SetText(fmt.Sprintf(" X - Discard changes to [::b]%s[::-]", tview.Escape(truncate(file.Path, 60))))
 
 	options := tview.NewTable().
 		SetSelectable(true, false).
@@ -3224,43 +7232,149 @@ func (u *tuiState) showDeleteModal() {
 	options.SetSelectedStyle(tcell.StyleDefault.Foreground(tcell.ColorDefault).Background(tcell.ColorDefault).Reverse(true))
 	options.SetBorder(true)
 	options.SetBorderColor(paneBorderColor())
-	options.SetCell(0, 0, tview.NewTableCell("r").SetTextColor(ansiColor(ansiCyan)).SetExpansion(1))
-	options.SetCell(0, 1, tview.NewTableCell("Remove worktree").SetTextColor(tcell.ColorDefault).SetExpansion(1))
-	options.SetCell(1, 0, tview.NewTableCell("c").SetTextColor(ansiColor(ansiCyan)).SetExpansion(1))
-	options.SetCell(1, 1, tview.NewTableCell("Cancel").SetTextColor(tcell.ColorDefault).SetExpansion(1))
-
-	selectOption := func(row int) {
-		switch row {
-		case 0:
-			remove()
-		default:
+	// Cancel comes first, so a stray enter keeps the changes.
+	choices := []struct {
+		key   string
+		label string
+		run   func()
+	}{
+		{"c", "Cancel", cancel},
+		{"d", "Discard changes", discard},
+	}
+	for row, choice := range choices {
+		options.SetCell(row, 0, tview.NewTableCell(choice.key).SetTextColor(ansiColor(ansiCyan)).SetExpansion(1))
+		options.SetCell(row, 1, tview.NewTableCell(choice.label).SetTextColor(tcell.ColorDefault).SetExpansion(1))
+	}
+	options.SetSelectedFunc(func(row, _ int) {
+		choices[row].run()
+	})
+	options.SetInputCapture(func(ev *tcell.EventKey) *tcell.EventKey {
+		switch ev.Key() {
+		case tcell.KeyEscape:
 			cancel()
+			return nil
+		case tcell.KeyRune:
+			key := string(ev.Rune())
+			for _, choice := range choices {
+				if choice.key == key {
+					choice.run()
+					return nil
+				}
+			}
+			switch key {
+			case "j":
+				options.Select(1, 0)
+				return nil
+			case "k":
+				options.Select(0, 0)
+				return nil
+			}
+		}
+		return ev
+	})
+
+	layout := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(action, 1, 0, false).
+		AddItem(nil, 1, 0, false).
+		AddItem(options, len(choices)+2, 0, true).
+		AddItem(nil, 1, 0, false).
+		AddItem(msg, 8, 0, false)
+	layout.SetBackgroundColor(tcell.ColorDefault)
+
+	u.showModal("discard-file", layout, 80, len(choices)+14)
+	options.Select(0, 0)
+	u.app.SetFocus(options)
+}
+
+// pickSelectedCommit cherry-picks the selected commit of the log tab onto
+// another worktree's branch.
+func (u *tuiState) pickSelectedCommit() {
+	item := u.selectedItem()
+	if item == nil || u.logSel < 0 || u.logSel >= len(u.logItems) {
+		u.setWarn("no commit selected")
+		return
+	}
+	c := u.logItems[u.logSel]
+	u.showPickModal(item, c.ShortHash+" "+c.Subject, PickOptions{Source: item.Path, Commits: []string{c.Hash}})
+}
+
+// showPickModal asks which worktree to copy a change of item into, then
+// runs the pick off the UI goroutine.
+func (u *tuiState) showPickModal(item *Worktree, what string, opts PickOptions) {
+	dests := []Worktree{}
+	for _, it := range u.items {
+		if it.Path != item.Path {
+			dests = append(dests, it)
 		}
 	}
+	if len(dests) == 0 {
+		u.setWarn("no other worktree to copy into")
+		return
+	}
+	focus := u.app.GetFocus()
+	cancel := func() {
+		u.closeModal("pick")
+		u.focusPane(focus)
+	}
+	pick := func(dest Worktree) {
+		u.closeModal("pick")
+		u.focusPane(focus)
+		opts.Dest = dest.Path
+		branch := worktreeBranchOrName(&dest)
+		u.setInfo("copying %s into %s...", what, branch)
+		mgr := u.mgr
+		go func() {
+			_, err := mgr.Pick(context.Background(), opts)
+			u.app.QueueUpdateDraw(func() {
+				u.refresh(func(refreshErr error) {
+					if refreshErr != nil && err == nil {
+						err = fmt.Errorf("copied, but refresh failed: %w", refreshErr)
+					}
+					if err != nil {
+						u.setError("copy failed: %v", err)
+						return
+					}
+					u.setInfo("copied %s into %s", what, branch)
+				})
+			})
+		}()
+	}
+
+	action := tview.NewTextView().
+		SetDynamicColors(true).
+		SetWrap(false)
+	action.SetBackgroundColor(tcell.ColorDefault)
+	action.SetTextColor(ansiColor(ansiYellow))
+	action.SetText(fmt.Sprintf(" Copy [::b]%s[::-] into:", tview.Escape(truncate(what, 60))))
+
+	options := tview.NewTable().
+		SetSelectable(true, false).
+		SetBorders(false)
+	options.SetSeparator(' ')
+	options.SetBackgroundColor(tcell.ColorDefault)
+	options.SetSelectedStyle(tcell.StyleDefault.Foreground(tcell.ColorDefault).Background(tcell.ColorDefault).Reverse(true))
+	options.SetBorder(true)
+	options.SetBorderColor(paneBorderColor())
+	for i, dest := range dests {
+		options.SetCell(i, 0, tview.NewTableCell(tview.Escape(truncate(worktreeBranchOrName(&dest), branchColumnWidth))).SetTextColor(ColorToTcell(ColorGreen)))
+		options.SetCell(i, 1, tview.NewTableCell(tview.Escape(truncatePath(u.mgr.displayPath(u.repoRoot, dest.Path), 50))).SetTextColor(ansiColor(ansiCyan)).SetExpansion(1))
+	}
 	options.SetSelectedFunc(func(row, _ int) {
-		selectOption(row)
+		if row >= 0 && row < len(dests) {
+			pick(dests[row])
+		}
 	})
 	options.SetInputCapture(func(ev *tcell.EventKey) *tcell.EventKey {
 		switch ev.Key() {
-		case tcell.KeyEnter:
-			row, _ := options.GetSelection()
-			selectOption(row)
-			return nil
 		case tcell.KeyEscape:
 			cancel()
 			return nil
-		}
-		if ev.Key() == tcell.KeyRune {
-			switch unicode.ToLower(ev.Rune()) {
-			case 'r':
-				remove()
-				return nil
-			case 'c':
-				cancel()
-				return nil
+		case tcell.KeyRune:
+			switch ev.Rune() {
 			case 'j':
 				row, _ := options.GetSelection()
-				if row < 1 {
+				if row < len(dests)-1 {
 					options.Select(row+1, 0)
 				}
 				return nil
@@ -3270,67 +7384,88 @@ func (u *tuiState) showDeleteModal() {
 					options.Select(row-1, 0)
 				}
 				return nil
+			case 'q':
+				cancel()
+				return nil
 			}
 		}
 		return ev
 	})
 
+	height := len(dests) + 2
+	if height > 14 {
+		height = 14
+	}
 	layout := tview.NewFlex().
 		SetDirection(tview.FlexRow).
 		AddItem(action, 1, 0, false).
 		AddItem(nil, 1, 0, false).
-		AddItem(options, 4, 0, true).
-		AddItem(nil, 1, 0, false).
-		AddItem(msg, 4, 0, false)
+		AddItem(options, height, 0, true)
 	layout.SetBackgroundColor(tcell.ColorDefault)
 
-	u.showModal("delete", layout, 96, 12)
+	u.showModal("pick", layout, 96, height+4)
 	options.Select(0, 0)
 	u.app.SetFocus(options)
 }
 
-func (u *tuiState) showDetachModal() {
-	item := u.selectedItem()
-	if item == nil {
-		u.setWarn("nothing selected")
+func (u *tuiState) showSessionsModal() {
+	sessions, err := u.mgr.Sessions()
+	if err != nil {
+		u.setError("sessions failed: %v", err)
 		return
 	}
+	orphans := orphanSessionNames(sessions)
 
-	branch := item.Branch
-	if branch == "" {
-		branch = filepath.Base(item.Path)
-	}
-
-	detach := func() {
-		path, detached, err := u.mgr.Detach(item.Path)
-		if err != nil {
-			u.setError("detach failed: %v", err)
-			return
-		}
-		u.closeModal("detach")
-		if err := u.refresh(); err != nil {
-			u.setWarn("detached, but refresh failed: %v", err)
-			return
-		}
-		if !detached {
-			u.setInfo("session was not running: %s", path)
+	killOrphans := func() {
+		if len(orphans) == 0 {
+			u.closeModal("sessions")
 			return
 		}
-		u.setInfo("detached: %s", path)
+		killed, err := u.mgr.KillOrphanSessions()
+		u.closeModal("sessions")
+		u.refresh(func(refreshErr error) {
+			if refreshErr != nil && err == nil {
+				u.setWarn("killed %d orphaned session(s), but refresh failed: %v", len(killed), refreshErr)
+				return
+			}
+			if err != nil {
+				u.setError("killed %d orphaned session(s); %v", len(killed), err)
+				return
+			}
+			u.setInfo("killed %d orphaned session(s)", len(killed))
+		})
 	}
 	cancel := func() {
-		u.closeModal("detach")
+		u.closeModal("sessions")
+	}
+
+	var b strings.Builder
+	if len(sessions) == 0 {
+		b.WriteString(colorTag(ColorGray) + "No sprout tmux sessions running.[-]")
+	}
+	for _, s := range sessions {
+		switch {
+		case s.Orphan:
+			fmt.Fprintf(&b, "%sorphan[-]  %s\n", colorTag(ColorRed), tview.Escape(s.Name))
+		case s.Worktree != "":
+			fmt.Fprintf(&b, "%sok[-]      %s %s%s[-]\n", colorTag(ColorGreen), tview.Escape(s.Name), colorTag(ColorGray), tview.Escape(truncatePath(s.Worktree, 60)))
+		default:
+			fmt.Fprintf(&b, "%sother[-]   %s %s%s[-]\n", colorTag(ColorGray), tview.Escape(s.Name), colorTag(ColorGray), tview.Escape(truncatePath(s.Path, 60)))
+		}
+	}
+	msgHeight := len(sessions) + 2
+	if msgHeight < 3 {
+		msgHeight = 3
+	}
+	if msgHeight > 16 {
+		msgHeight = 16
 	}
 
 	msg := tview.NewTextView().SetDynamicColors(true)
 	msg.SetBackgroundColor(tcell.ColorDefault)
 	msg.SetTextColor(tcell.ColorDefault)
-	msg.SetWrap(true)
-	msg.SetText(fmt.Sprintf(
-		"Detach from worktree [::b]%s[::-]?\n\nThis will kill the tmux session for this worktree only.\n\n[cyan]%s[-]",
-		branch,
-		truncatePath(item.Path, 96),
-	))
+	msg.SetWrap(false)
+	msg.SetText(strings.TrimRight(b.String(), "\n"))
 	msg.SetBorder(true)
 	msg.SetBorderColor(paneBorderColor())
 
@@ -3339,8 +7474,12 @@ func (u *tuiState) showDetachModal() {
 		SetWrap(false)
 	action.SetBackgroundColor(tcell.ColorDefault)
 	action.SetTextColor(ansiColor(ansiCyan))
-	action.SetText(fmt.Sprintf(" x - Detach worktree [::b]%s[::-]", branch))
+	action.SetText(fmt.Sprintf(" %d sprout session(s), [::b]%d[::-] orphaned", len(sessions), len(orphans)))
 
+	killLabel := fmt.Sprintf("Kill %d orphaned session(s)", len(orphans))
+	if len(orphans) == 0 {
+		killLabel = "No orphaned sessions"
+	}
 	options := tview.NewTable().
 		SetSelectable(true, false).
 		SetBorders(false)
@@ -3350,14 +7489,14 @@ func (u *tuiState) showDetachModal() {
 	options.SetBorder(true)
 	options.SetBorderColor(paneBorderColor())
 	options.SetCell(0, 0, tview.NewTableCell("x").SetTextColor(ansiColor(ansiCyan)).SetExpansion(1))
-	options.SetCell(0, 1, tview.NewTableCell("Detach session").SetTextColor(tcell.ColorDefault).SetExpansion(1))
+	options.SetCell(0, 1, tview.NewTableCell(killLabel).SetTextColor(tcell.ColorDefault).SetExpansion(1))
 	options.SetCell(1, 0, tview.NewTableCell("c").SetTextColor(ansiColor(ansiCyan)).SetExpansion(1))
-	options.SetCell(1, 1, tview.NewTableCell("Cancel").SetTextColor(tcell.ColorDefault).SetExpansion(1))
+	options.SetCell(1, 1, tview.NewTableCell("Close").SetTextColor(tcell.ColorDefault).SetExpansion(1))
 
 	selectOption := func(row int) {
 		switch row {
 		case 0:
-			detach()
+			killOrphans()
 		default:
 			cancel()
 		}
@@ -3378,9 +7517,9 @@ func (u *tuiState) showDetachModal() {
 		if ev.Key() == tcell.KeyRune {
 			switch unicode.ToLower(ev.Rune()) {
 			case 'x':
-				detach()
+				killOrphans()
 				return nil
-			case 'c':
+			case 'c', 'q':
 				cancel()
 				return nil
 			case 'j':
@@ -3406,10 +7545,10 @@ func (u *tuiState) showDetachModal() {
 		AddItem(nil, 1, 0, false).
 		AddItem(options, 4, 0, true).
 		AddItem(nil, 1, 0, false).
-		AddItem(msg, 5, 0, false)
+		AddItem(msg, msgHeight, 0, false)
 	layout.SetBackgroundColor(tcell.ColorDefault)
 
-	u.showModal("detach", layout, 96, 13)
+	u.showModal("sessions", layout, 96, msgHeight+8)
 	options.Select(0, 0)
 	u.app.SetFocus(options)
 }
@@ -3430,22 +7569,41 @@ func (u *tuiState) showHelpModal() {
 
 	// General bindings (always relevant)
 	general := []binding{
-		{Key: "tab / shift+tab", What: "Switch pane focus", Short: "Cycle focus across status, details, and worktrees panes."},
-		{Key: "r", What: "Refresh", Short: "Reload worktrees and repository metadata."},
+		{Key: "tab / shift+tab", What: "Switch pane focus", Short: "Cycle focus across the repo sidebar (when shown), status, details, and worktrees panes."},
+		{Key: "S", What: "Repo sidebar", Short: "Show or hide the sidebar listing sibling repositories with their worktree and ready-agent counts; saved as repo_sidebar."},
+		{Key: "ctrl+up / ctrl+down", What: "Resize panes", Short: "Move the split between the details and worktrees panes; the ratio is saved as details_percent."},
+		{Key: "z", What: "Zoom pane", Short: "Maximize the focused details or worktrees pane; on the agent output tab, fill the whole terminal and resize the agent's tmux pane to match. Press again (or esc) to restore."},
+		{Key: "mouse", What: "Click and scroll", Short: "Click a pane, worktree row, changed file, or detail tab to select it; double-click a worktree to attach; the wheel moves selections and scrolls the patch and agent output."},
+		{Key: "r", What: "Refresh", Short: "Reload worktrees and repository metadata in the background, dropping cached git queries; the rows stay while a spinner shows in the counter."},
 		{Key: "?", What: "Open keybindings", Short: "Open this contextual help window."},
-		{Key: "esc", What: "Close modal", Short: "Cancel and close the current modal window."},
-		{Key: "q / ctrl+c", What: "Quit", Short: "Exit the TUI."},
+		{Key: "esc", What: "Close modal", Short: "Cancel and close the current modal window, or dismiss the startup health banner."},
+		{Key: "q / ctrl+c", What: "Quit", Short: "Exit the TUI; q applies on_quit to running agents."},
 	}
 
 	if inTable {
 		title = "Worktree List Help"
 		bindings = []binding{
 			{Key: "j / k, up / down", What: "Move selection", Short: "Navigate through your list of git worktrees."},
-			{Key: "enter / g", What: "Attach to worktree", Short: "Open/focus the tmux session for the selected worktree."},
+			{Key: "enter / g", What: "Attach to worktree", Short: "Open/focus the tmux session for the selected worktree; with several windows, pick the one to land on."},
+			{Key: "1-9", What: "Attach to window", Short: "Land on the n-th window of the worktree's session (agent, editor, lazygit, ...), launching it if needed."},
 			{Key: "d", What: "Detach session", Short: "Stop the selected worktree's tmux session (keeps worktree)."},
 			{Key: "n", What: "New worktree", Short: "Create a new branch and worktree from this repo."},
-			{Key: "x", What: "Remove worktree", Short: "Delete the selected worktree (and optionally its branch)."},
-			{Key: "/", What: "Filter list", Short: "Narrow down the list by branch name or path."},
+			{Key: "x", What: "Remove worktree", Short: "Delete the selected worktree (and optionally its branch), after showing what it would lose."},
+			{Key: "u", What: "Undo removal", Short: "Restore the last removed worktree, its branch, and its uncommitted files within undo_minutes."},
+			{Key: "m", What: "Rename worktree", Short: "Rename the branch, move the worktree directory, and rename its tmux session."},
+			{Key: "l", What: "Lock / unlock worktree", Short: "Toggle a git worktree lock; locked worktrees need an explicit force to remove."},
+			{Key: "P", What: "Cycle priority", Short: "Cycle the selected worktree between normal, high, and low priority."},
+			{Key: "R", What: "Toggle resources", Short: "Show or hide the CPU/MEM column: CPU and memory of the processes in each worktree's tmux session."},
+			{Key: "b", What: "Interactive rebase", Short: "Open `git rebase -i <base>` in a rebase window of the worktree's tmux session."},
+			{Key: "M", What: "Merge", Short: "Merge or squash-merge the branch into the base branch after checking it is clean, conflict-free, and how its pull request checks did; optionally remove the worktree and branch."},
+			{Key: "p", What: "Send prompt", Short: "Send an instruction to the selected worktree's agent (up/down recalls previous prompts)."},
+			{Key: "A", What: "Resume agents", Short: "Start again the agents that stopped with the tmux server, with the agent type they had, and send agent_resume_prompt."},
+			{Key: "F", What: "Fetch", Short: "Run git fetch --prune for the repository in the background, then refresh; the status pane shows when it was last fetched. auto_fetch_minutes does this on a schedule."},
+			{Key: "y / Y", What: "Copy path / branch", Short: "Copy the selected worktree's path (y) or branch name (Y) to the clipboard; over SSH, or without pbcopy, wl-copy, xclip, or xsel, the terminal's clipboard via OSC 52."},
+			{Key: "o", What: "Sort", Short: "Cycle the order: by path, most recently active first, or least recently active first to spot stale worktrees. The ACTIVE column shows when each was last touched."},
+			{Key: "/", What: "Filter list", Short: "Fuzzy-match branch names (or a path substring), best match first. Terms such as label:<name>, status:dirty, agent:ready, and priority:high keep only the worktrees that satisfy them; status:dirty includes a rebase, merge, cherry-pick, or revert stopped midway. The filter is remembered per repo."},
+			{Key: "f", What: "Next filter preset", Short: "Cycle through the filter presets of the [filters] config table, then back to no filter."},
+			{Key: "L", What: "View logs", Short: "Tail the debug log; e/i/d/t filter by level (error, info, debug, trace)."},
 		}
 	} else if inDetail && u.detailTab == detailTabDiff {
 		title = "Git Diff Help"
@@ -3453,15 +7611,62 @@ func (u *tuiState) showHelpModal() {
 			{Key: "j / k", What: "Select file", Short: "Move through the list of changed files."},
 			{Key: "J / K", What: "Scroll patch", Short: "Scroll the patch view for the current file."},
 			{Key: "ctrl+u / ctrl+d", What: "Fast scroll", Short: "Scroll the patch view faster (10 lines)."},
+			{Key: "b", What: "Diff base", Short: "Compare with the working tree, HEAD, the merge-base, the last checkpoint (taken when a prompt is sent), or any ref."},
+			{Key: "y", What: "Copy patch", Short: "Copy the selected file's patch against the current diff base to the clipboard, ready for git apply."},
+			{Key: "e / E", What: "Export patch / diff", Short: "Write the selected file's patch (e) or the whole worktree diff (E) against the current diff base to a timestamped file in export_dir."},
+			{Key: "c", What: "Copy to worktree", Short: "Apply the selected file's changes against the current diff base to another worktree (git apply, three-way when it does not apply cleanly)."},
+			{Key: "X", What: "Discard changes", Short: "Throw away the uncommitted changes to the selected file after confirming: untracked files are deleted, others checked out from HEAD. Needs the working tree diff base."},
+			{Key: "v", What: "Preview file", Short: "Show the selected file as it is now instead of its patch: its type, size, and modification time, image dimensions, and the first lines of text files. v again shows patches."},
+			{Key: "w", What: "Wrap lines", Short: "Soft-wrap long patch lines at the pane width instead of clipping them; the tab remembers it."},
 			{Key: "h / l, [ / ]", What: "Switch tab", Short: "Switch back to Agent Output or next tab."},
 		}
+	} else if inDetail && u.detailTab == detailTabLog {
+		title = "Log Help"
+		bindings = []binding{
+			{Key: "j / k", What: "Select commit", Short: "Move through the commits on the branch that are not on the base branch (git log base..HEAD)."},
+			{Key: "enter", What: "Show patch", Short: "Show the selected commit's message, stat, and patch in the patch view."},
+			{Key: "c", What: "Cherry-pick", Short: "Cherry-pick the selected commit onto another worktree's branch; a conflicting cherry-pick is aborted."},
+			{Key: "J / K", What: "Scroll patch", Short: "Scroll the patch view."},
+			{Key: "ctrl+u / ctrl+d", What: "Fast scroll", Short: "Scroll the patch view faster (10 lines)."},
+			{Key: "w", What: "Wrap lines", Short: "Soft-wrap long patch lines at the pane width instead of clipping them; the tab remembers it."},
+			{Key: "h / l, [ / ]", What: "Switch tab", Short: "Switch back to Git Diff or next tab."},
+		}
 	} else if inDetail && u.detailTab == detailTabAgent {
 		title = "Agent Output Help"
 		bindings = []binding{
 			{Key: "j / k, up / down", What: "Scroll output", Short: "Scroll through the agent's terminal output."},
 			{Key: "pgup / pgdn", What: "Fast scroll", Short: "Scroll through output faster."},
+			{Key: "a", What: "Type to agent", Short: "Forward every key to the agent's tmux pane while its output streams here; ctrl+] stops."},
+			{Key: "e", What: "Export transcript", Short: "Write the agent's output, with as much scrollback as tmux kept, to a timestamped file in export_dir."},
+			{Key: "R", What: "Restart agent", Short: "Kill the agent window and start the agent again with its configured command, optionally sending the last prompt again. Works on crashed agents too."},
+			{Key: "w", What: "Wrap lines", Short: "Soft-wrap long output lines at the pane width instead of clipping them; the tab remembers it."},
+			{Key: "W", What: "Preview pane", Short: "Show the next pane of the worktree's tmux session (editor, lazygit, dev server) here instead of the agent; cycles back to the agent. a types to the previewed pane."},
 			{Key: "h / l, [ / ]", What: "Switch tab", Short: "Switch to Git Diff or next tab."},
 		}
+	} else if inDetail && u.detailTab == detailTabSymbols {
+		title = "Changed Symbols Help"
+		bindings = []binding{
+			{Key: "j / k, up / down", What: "Scroll list", Short: "Scroll through Go functions and types added, modified, or removed on the branch."},
+			{Key: "h / l, [ / ]", What: "Switch tab", Short: "Switch to another detail tab."},
+		}
+	} else if inDetail && u.detailTab == detailTabTodos {
+		title = "TODO Markers Help"
+		bindings = []binding{
+			{Key: "j / k, up / down", What: "Scroll list", Short: "Scroll through TODO/FIXME markers added since the base branch."},
+			{Key: "h / l, [ / ]", What: "Switch tab", Short: "Switch to Agent Output or Git Diff."},
+		}
+	} else if focus == u.repoSidebar {
+		title = "Repos Help"
+		bindings = []binding{
+			{Key: "j / k, up / down", What: "Move selection", Short: "Highlight another repository; * marks the current one and ● counts its agents waiting for input."},
+			{Key: "enter", What: "Switch repo", Short: "Manage the highlighted repository in place, with its saved filter and the worktree last selected in it."},
+		}
+	} else if focus == u.statusPane {
+		title = "Status Help"
+		bindings = []binding{
+			{Key: "enter", What: "Switch repo", Short: "Pick another repository to manage."},
+			{Key: "s", What: "Sessions", Short: "List sprout tmux sessions and kill orphaned ones whose worktree is gone."},
+		}
 	} else {
 		title = "General Help"
 	}
@@ -3582,97 +7787,627 @@ func (u *tuiState) showHelpModal() {
 		AddItem(hint, 0, 1, false).
 		AddItem(counter, 12, 0, false)
 
-	modal := tview.NewFlex().
-		SetDirection(tview.FlexRow).
-		AddItem(table, 0, 3, true).
-		AddItem(desc, 4, 0, false).
-		AddItem(meta, 1, 0, false)
-	modal.SetBackgroundColor(tcell.ColorDefault)
+	modal := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(table, 0, 3, true).
+		AddItem(desc, 4, 0, false).
+		AddItem(meta, 1, 0, false)
+	modal.SetBackgroundColor(tcell.ColorDefault)
+
+	u.showModal("help", modal, 118, 24)
+	table.Select(1, 0)
+	updateSelection(1)
+	u.app.SetFocus(table)
+}
+
+func (u *tuiState) showLogsModal() {
+	const logTailLines = 500
+
+	level := logLevel(activeLogLevel.Load())
+	follow := true
+
+	header := modalHeader(fmt.Sprintf("Logs: %s", tview.Escape(debugLogFilePath())))
+	view := tview.NewTextView().
+		SetDynamicColors(true).
+		SetWrap(false)
+	view.SetBackgroundColor(tcell.ColorDefault)
+	view.SetTextColor(tcell.ColorDefault)
+	view.SetBorder(true)
+	view.SetBorderColor(paneBorderColor())
+
+	hint := tview.NewTextView().SetDynamicColors(true).SetWrap(false)
+	hint.SetBackgroundColor(tcell.ColorDefault)
+	hint.SetTextColor(paneBorderColor())
+	hint.SetText(" [::b]e/i/d/t[::-] level | [::b]j/k[::-] scroll | [::b]g/G[::-] top/follow | [::b]esc[::-] close")
+
+	render := func() {
+		view.SetTitle(fmt.Sprintf(" Level: %s (logging at %s) ", level, logLevel(activeLogLevel.Load())))
+		lines, err := tailDebugLog(logTailLines, level)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				view.SetText("No log entries yet.")
+			} else {
+				view.SetText(tview.Escape(fmt.Sprintf("Unable to read log: %v", err)))
+			}
+			return
+		}
+		var b strings.Builder
+		for _, line := range lines {
+			color := "-"
+			switch logLineLevel(line) {
+			case logLevelError:
+				color = "red"
+			case logLevelInfo:
+				color = "cyan"
+			case logLevelTrace:
+				color = "gray"
+			}
+			b.WriteString(fmt.Sprintf("[%s]%s[-]\n", color, tview.Escape(line)))
+		}
+		view.SetText(b.String())
+		if follow {
+			view.ScrollToEnd()
+		}
+	}
+
+	done := make(chan struct{})
+	closeLogs := func() {
+		close(done)
+		u.closeModal("logs")
+	}
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				u.app.QueueUpdateDraw(render)
+			}
+		}
+	}()
+
+	view.SetInputCapture(func(ev *tcell.EventKey) *tcell.EventKey {
+		switch ev.Key() {
+		case tcell.KeyEscape:
+			closeLogs()
+			return nil
+		case tcell.KeyUp, tcell.KeyPgUp:
+			follow = false
+			return ev
+		}
+		if ev.Key() != tcell.KeyRune {
+			return ev
+		}
+		switch ev.Rune() {
+		case 'q', 'c':
+			closeLogs()
+		case 'e':
+			level = logLevelError
+			render()
+		case 'i':
+			level = logLevelInfo
+			render()
+		case 'd':
+			level = logLevelDebug
+			render()
+		case 't':
+			level = logLevelTrace
+			render()
+		case 'j':
+			u.scrollTextView(view, 1)
+		case 'k':
+			follow = false
+			u.scrollTextView(view, -1)
+		case 'g':
+			follow = false
+			view.ScrollToBeginning()
+		case 'G':
+			follow = true
+			view.ScrollToEnd()
+		default:
+			return ev
+		}
+		return nil
+	})
+
+	layout := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(header, 1, 0, false).
+		AddItem(view, 0, 1, true).
+		AddItem(hint, 1, 0, false)
+	layout.SetBackgroundColor(tcell.ColorDefault)
+
+	u.showModal("logs", layout, 140, 32)
+	render()
+	u.app.SetFocus(view)
+}
+
+// goCurrent attaches to the selected worktree. When its session has more
+// than one window, it asks which one to land on first.
+func (u *tuiState) goCurrent() {
+	item := u.selectedItem()
+	if item == nil {
+		u.setWarn("nothing selected")
+		return
+	}
+	windows, err := u.mgr.WorktreeWindows(item)
+	if err != nil {
+		debugLogf("tui worktree_windows failed path=%q: %v", item.Path, err)
+	}
+	if len(windows) > 1 {
+		u.showWindowModal(item, windows)
+		return
+	}
+	u.goTo(item, "")
+}
+
+// goWindowCurrent attaches to the n-th window (from 1) of the selected
+// worktree's session, launching the session first when it is not running.
+func (u *tuiState) goWindowCurrent(n int) {
+	item := u.selectedItem()
+	if item == nil {
+		u.setWarn("nothing selected")
+		return
+	}
+	windows, err := u.mgr.WorktreeWindows(item)
+	if err == nil && len(windows) == 0 {
+		if _, err = u.mgr.Launch(LaunchOptions{Target: item.Path, NoAttach: true}); err != nil {
+			u.setError("launch failed: %v", err)
+			return
+		}
+		windows, err = u.mgr.WorktreeWindows(item)
+	}
+	if err != nil {
+		u.setError("attach failed: %v", err)
+		return
+	}
+	if n > len(windows) {
+		u.setWarn("%s has %s", worktreeBranchOrName(item), plural(len(windows), "window"))
+		return
+	}
+	u.goTo(item, windows[n-1].Name)
+}
+
+// showWindowModal lists the windows of item's session to attach to, with
+// the one the session is on selected.
+func (u *tuiState) showWindowModal(item *Worktree, windows []TmuxWindow) {
+	if len(windows) > 9 {
+		windows = windows[:9]
+	}
+	choose := func(row int) {
+		if row < 0 || row >= len(windows) {
+			return
+		}
+		u.closeModal("windows")
+		u.goTo(item, windows[row].Name)
+	}
+
+	options := tview.NewTable().
+		SetSelectable(true, false).
+		SetBorders(false)
+	options.SetSeparator(' ')
+	options.SetBackgroundColor(tcell.ColorDefault)
+	options.SetSelectedStyle(tcell.StyleDefault.Foreground(tcell.ColorDefault).Background(tcell.ColorDefault).Reverse(true))
+	options.SetBorder(true)
+	options.SetBorderColor(paneBorderColor())
+	selected := 0
+	for i, window := range windows {
+		options.SetCell(i, 0, tview.NewTableCell(strconv.Itoa(i+1)).SetTextColor(ansiColor(ansiCyan)))
+		options.SetCell(i, 1, tview.NewTableCell(tview.Escape(window.Name)).SetTextColor(tcell.ColorDefault).SetExpansion(1))
+		if window.Active {
+			selected = i
+			options.SetCell(i, 2, tview.NewTableCell("current").SetTextColor(paneBorderColor()))
+		}
+	}
+	options.SetSelectedFunc(func(row, _ int) {
+		choose(row)
+	})
+	options.SetInputCapture(func(ev *tcell.EventKey) *tcell.EventKey {
+		switch ev.Key() {
+		case tcell.KeyEnter:
+			row, _ := options.GetSelection()
+			choose(row)
+			return nil
+		case tcell.KeyEscape:
+			u.closeModal("windows")
+			return nil
+		}
+		if ev.Key() == tcell.KeyRune {
+			switch r := ev.Rune(); {
+			case r >= '1' && r <= '9':
+				choose(int(r - '1'))
+				return nil
+			case r == 'q':
+				u.closeModal("windows")
+				return nil
+			case r == 'j':
+				row, _ := options.GetSelection()
+				if row < len(windows)-1 {
+					options.Select(row+1, 0)
+				}
+				return nil
+			case r == 'k':
+				row, _ := options.GetSelection()
+				if row > 0 {
+					options.Select(row-1, 0)
+				}
+				return nil
+			}
+		}
+		return ev
+	})
+
+	layout := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(modalHeader("Attach to "+worktreeBranchOrName(item)), 1, 0, false).
+		AddItem(options, len(windows)+2, 0, true)
+	layout.SetBackgroundColor(tcell.ColorDefault)
+
+	u.showModal("windows", layout, 56, len(windows)+5)
+	options.Select(selected, 0)
+	u.app.SetFocus(options)
+}
+
+// goTo attaches to item's session, on window when it is set.
+func (u *tuiState) goTo(item *Worktree, window string) {
+	var path string
+	var err error
+	u.app.Suspend(func() {
+		path, err = u.mgr.Go(GoOptions{Target: item.Path, Launch: true, Attach: true, Window: window})
+	})
+	if err != nil {
+		u.setError("attach failed: %v", err)
+		return
+	}
+	u.setInfo("attached: %s", path)
+	u.refresh(func(err error) {
+		if err != nil {
+			u.setWarn("attach succeeded, refresh failed: %v", err)
+		}
+	})
+}
+
+func (u *tuiState) launchCurrent() {
+	item := u.selectedItem()
+	if item == nil {
+		u.setWarn("nothing selected")
+		return
+	}
+	_, err := u.mgr.Launch(LaunchOptions{Target: item.Path, NoAttach: true})
+	if err != nil {
+		u.setError("launch failed: %v", err)
+		return
+	}
+	u.setInfo("launched: %s", item.Path)
+}
+
+func (u *tuiState) startAgentCurrent() {
+	item := u.selectedItem()
+	if item == nil {
+		u.setWarn("nothing selected")
+		return
+	}
+	u.startAgent(item, "")
+}
 
-	u.showModal("help", modal, 118, 24)
-	table.Select(1, 0)
-	updateSelection(1)
-	u.app.SetFocus(table)
+func (u *tuiState) startAgent(item *Worktree, agentType string) {
+	path, already, err := u.mgr.StartAgent(context.Background(), AgentOptions{Target: item.Path, AgentType: agentType})
+	var notFound *AgentNotFoundError
+	if errors.As(err, &notFound) && len(notFound.Alternatives) > 0 {
+		u.showAgentFallbackModal(notFound, func(alt string) { u.startAgent(item, alt) })
+		return
+	}
+	if err != nil {
+		u.setError("agent start failed: %v", err)
+		return
+	}
+	u.refresh(func(err error) {
+		if err != nil {
+			u.setWarn("agent updated, refresh failed: %v", err)
+		}
+		if already {
+			u.setInfo("agent already running: %s", path)
+			return
+		}
+		u.setInfo("agent started: %s", path)
+	})
 }
 
-func (u *tuiState) goCurrent() {
+func (u *tuiState) attachAgentCurrent() {
 	item := u.selectedItem()
 	if item == nil {
 		u.setWarn("nothing selected")
 		return
 	}
+
+	u.attachAgent(item, "")
+}
+
+func (u *tuiState) attachAgent(item *Worktree, agentType string) {
 	var path string
 	var err error
 	u.app.Suspend(func() {
-		path, err = u.mgr.Go(GoOptions{Target: item.Path, Launch: true, Attach: true})
+		path, _, err = u.mgr.StartAgent(context.Background(), AgentOptions{Target: item.Path, Attach: true, AgentType: agentType})
 	})
+	var notFound *AgentNotFoundError
+	if errors.As(err, &notFound) && len(notFound.Alternatives) > 0 {
+		u.showAgentFallbackModal(notFound, func(alt string) { u.attachAgent(item, alt) })
+		return
+	}
 	if err != nil {
-		u.setError("attach failed: %v", err)
+		u.setError("agent attach failed: %v", err)
 		return
 	}
-	u.setInfo("attached: %s", path)
-	if err := u.refresh(); err != nil {
-		u.setWarn("attach succeeded, refresh failed: %v", err)
+	u.refresh(func(err error) {
+		if err != nil {
+			u.setWarn("agent attached, refresh failed: %v", err)
+			return
+		}
+		u.setInfo("agent attached: %s", path)
+	})
+}
+
+func (u *tuiState) yankPath() {
+	item := u.selectedItem()
+	if item == nil {
+		u.setWarn("nothing selected")
+		return
 	}
+	u.yank("path", item.Path)
 }
 
-func (u *tuiState) launchCurrent() {
+func (u *tuiState) yankBranch() {
 	item := u.selectedItem()
 	if item == nil {
 		u.setWarn("nothing selected")
 		return
 	}
-	_, err := u.mgr.Launch(LaunchOptions{Target: item.Path, NoAttach: true})
+	if item.Branch == "" {
+		u.setWarn("detached HEAD has no branch to copy")
+		return
+	}
+	u.yank("branch", item.Branch)
+}
+
+// yankPatch copies the plain patch of the file shown in the diff tab, not
+// its rendered form.
+func (u *tuiState) yankPatch() {
+	item := u.selectedItem()
+	if item == nil || u.diffSel < 0 || u.diffSel >= len(u.diffItems) {
+		u.setWarn("no file selected")
+		return
+	}
+	file := u.diffItems[u.diffSel]
+	patch, err := u.mgr.WorktreeFilePatch(item.Path, u.diffRev, file)
 	if err != nil {
-		u.setError("launch failed: %v", err)
+		u.setError("copy failed: %v", err)
 		return
 	}
-	u.setInfo("launched: %s", item.Path)
+	if strings.TrimSpace(patch) == "" {
+		u.setWarn("no textual diff for %s", file.Path)
+		return
+	}
+	u.yank("patch of "+file.Path, patch+"\n")
 }
 
-func (u *tuiState) startAgentCurrent() {
+func (u *tuiState) yank(what, text string) {
+	how, err := copyToClipboard(text)
+	if err != nil {
+		u.setError("copy failed: %v", err)
+		return
+	}
+	if how == clipboardOSC52 {
+		u.setInfo("copied %s (via OSC 52)", what)
+		return
+	}
+	u.setInfo("copied %s", what)
+}
+
+// exportCurrent writes what the details pane shows to a file in export_dir:
+// the agent transcript on the agent tab, and on the diff tab the selected
+// file's patch, or the whole worktree diff when all is set or no file is
+// selected.
+func (u *tuiState) exportCurrent(all bool) {
+	item := u.selectedItem()
+	if item == nil {
+		u.setWarn("nothing selected")
+		return
+	}
+	var what, ext, content string
+	switch u.detailTab {
+	case detailTabAgent:
+		if item.AgentState == "no" {
+			u.setWarn("no agent running")
+			return
+		}
+		transcript, err := u.mgr.AgentTranscript(u.repoRoot, item)
+		if err != nil {
+			u.setError("export failed: %v", err)
+			return
+		}
+		what, ext, content = "agent", ".log", transcript
+	case detailTabDiff:
+		var err error
+		if !all && u.diffSel >= 0 && u.diffSel < len(u.diffItems) {
+			file := u.diffItems[u.diffSel]
+			what = filepath.Base(file.Path)
+			content, err = u.mgr.WorktreeFilePatch(item.Path, u.diffRev, file)
+		} else {
+			what = "diff"
+			content, err = u.mgr.WorktreePatch(item.Path, u.diffRev)
+		}
+		if err != nil {
+			u.setError("export failed: %v", err)
+			return
+		}
+		ext = ".patch"
+	default:
+		u.setWarn("export works on the agent and diff tabs")
+		return
+	}
+	if strings.TrimSpace(content) == "" {
+		u.setWarn("nothing to export")
+		return
+	}
+	path, err := u.mgr.WriteExport(u.repoRoot, item, what, ext, []byte(content))
+	if err != nil {
+		u.setError("export failed: %v", err)
+		return
+	}
+	u.setInfo("exported to %s", abbreviateHome(path))
+}
+
+func (u *tuiState) toggleLockCurrent() {
 	item := u.selectedItem()
 	if item == nil {
 		u.setWarn("nothing selected")
 		return
 	}
 
-	path, already, err := u.mgr.StartAgent(AgentOptions{Target: item.Path, Attach: false})
+	if item.Locked {
+		path, _, err := u.mgr.Unlock(item.Path)
+		if err != nil {
+			u.setError("unlock failed: %v", err)
+			return
+		}
+		u.refresh(func(err error) {
+			if err != nil {
+				u.setWarn("unlocked, refresh failed: %v", err)
+				return
+			}
+			u.setInfo("unlocked: %s", path)
+			return
+		})
+	}
+
+	path, err := u.mgr.Lock(item.Path, "")
 	if err != nil {
-		u.setError("agent start failed: %v", err)
+		u.setError("lock failed: %v", err)
+		return
+	}
+	u.refresh(func(err error) {
+		if err != nil {
+			u.setWarn("locked, refresh failed: %v", err)
+			return
+		}
+		u.setInfo("locked: %s", path)
+	})
+}
+
+func (u *tuiState) cyclePriorityCurrent() {
+	item := u.selectedItem()
+	if item == nil {
+		u.setWarn("nothing selected")
 		return
 	}
-	if err := u.refresh(); err != nil {
-		u.setWarn("agent updated, refresh failed: %v", err)
+
+	next := priorityHigh
+	switch worktreePriority(*item) {
+	case priorityHigh:
+		next = priorityLow
+	case priorityLow:
+		next = priorityNormal
 	}
-	if already {
-		u.setInfo("agent already running: %s", path)
+	path, err := u.mgr.SetPriority(item.Path, next)
+	if err != nil {
+		u.setError("set priority failed: %v", err)
 		return
 	}
-	u.setInfo("agent started: %s", path)
+	u.refresh(func(err error) {
+		if err != nil {
+			u.setWarn("priority set, refresh failed: %v", err)
+			return
+		}
+		u.setInfo("priority %s: %s", next, path)
+	})
 }
 
-func (u *tuiState) attachAgentCurrent() {
+func (u *tuiState) rebaseCurrent() {
 	item := u.selectedItem()
 	if item == nil {
 		u.setWarn("nothing selected")
 		return
 	}
 
-	var path string
+	var base string
 	var err error
 	u.app.Suspend(func() {
-		path, err = u.mgr.AttachAgent(item.Path)
+		_, base, err = u.mgr.Rebase(RebaseOptions{Target: item.Path, Attach: true})
 	})
 	if err != nil {
-		u.setError("agent attach failed: %v", err)
+		u.setError("rebase failed: %v", err)
 		return
 	}
-	if err := u.refresh(); err != nil {
-		u.setWarn("agent attached, refresh failed: %v", err)
-		return
-	}
-	u.setInfo("agent attached: %s", path)
+	u.refresh(func(err error) {
+		if err != nil {
+			u.setWarn("rebase started, refresh failed: %v", err)
+			return
+		}
+		u.setInfo("rebasing %s onto %s", item.Branch, base)
+	})
+}
+
+// resumeAgents starts the agents that stopped with the tmux server again.
+// Sending the resume prompt waits on each agent, so it runs off the UI
+// goroutine.
+// undoRemove restores the last removed worktree and selects it.
+func (u *tuiState) undoRemove() {
+	u.setInfo("restoring the last removed worktree...")
+	mgr := u.mgr
+	go func() {
+		rec, err := mgr.Undo(context.Background())
+		u.app.QueueUpdateDraw(func() {
+			if err != nil {
+				u.setError("undo failed: %v", err)
+				return
+			}
+			u.refresh(func(err error) {
+				if err != nil {
+					u.setWarn("restored, but refresh failed: %v", err)
+					return
+				}
+				u.selectPath(rec.Path)
+				u.setInfo("restored: %s", rec.Path)
+			})
+		})
+	}()
+}
+
+func (u *tuiState) resumeAgents() {
+	u.setInfo("resuming agents...")
+	mgr := u.mgr
+	go func() {
+		results, err := mgr.ResumeAgents()
+		u.app.QueueUpdateDraw(func() {
+			if err != nil {
+				u.setError("resume failed: %v", err)
+				return
+			}
+			u.refresh(func(err error) {
+				if err != nil {
+					u.setWarn("agents resumed, refresh failed: %v", err)
+					return
+				}
+				if len(results) == 0 {
+					u.setInfo("no agents to resume")
+					return
+				}
+				resumed := 0
+				for _, res := range results {
+					if res.Error != "" {
+						u.setError("resume %s: %s", res.Branch, res.Error)
+						continue
+					}
+					resumed++
+				}
+				if resumed == len(results) {
+					u.setInfo("resumed %d agent(s)", resumed)
+				}
+			})
+		})
+	}()
 }
 
 func (u *tuiState) stopAgentCurrent() {
@@ -3682,19 +8417,175 @@ func (u *tuiState) stopAgentCurrent() {
 		return
 	}
 
-	path, stopped, err := u.mgr.StopAgent(item.Path)
+	path, stopped, err := u.mgr.StopAgent(context.Background(), item.Path)
 	if err != nil {
 		u.setError("agent stop failed: %v", err)
 		return
 	}
-	if err := u.refresh(); err != nil {
-		u.setWarn("agent updated, refresh failed: %v", err)
-	}
-	if !stopped {
-		u.setInfo("agent was not running: %s", path)
+	u.refresh(func(err error) {
+		if err != nil {
+			u.setWarn("agent updated, refresh failed: %v", err)
+		}
+		if !stopped {
+			u.setInfo("agent was not running: %s", path)
+			return
+		}
+		u.setInfo("agent stopped: %s", path)
+	})
+}
+
+// showRestartAgentModal asks whether to restart the selected worktree's
+// agent, and whether to send it the last prompt again.
+func (u *tuiState) showRestartAgentModal() {
+	item := u.selectedItem()
+	if item == nil {
+		u.setWarn("nothing selected")
 		return
 	}
-	u.setInfo("agent stopped: %s", path)
+	branch := worktreeBranchOrName(item)
+	path := item.Path
+	last := ""
+	if history := promptHistoryFor(path); len(history) > 0 {
+		last = history[len(history)-1].Prompt
+	}
+
+	restart := func(replay bool) {
+		u.closeModal("restart-agent")
+		if replay {
+			u.setInfo("restarting agent of %s, then sending the last prompt...", branch)
+		} else {
+			u.setInfo("restarting agent of %s...", branch)
+		}
+		mgr := u.mgr
+		go func() {
+			_, replayed, err := mgr.RestartAgent(context.Background(), RestartAgentOptions{Target: path, ReplayLastPrompt: replay})
+			u.app.QueueUpdateDraw(func() {
+				u.refresh(func(refreshErr error) {
+					if refreshErr != nil && err == nil {
+						u.setWarn("agent restarted, refresh failed: %v", refreshErr)
+						return
+					}
+					switch {
+					case err != nil:
+						u.setError("agent restart failed: %v", err)
+					case replayed != "":
+						u.setInfo("agent restarted and last prompt sent: %s", branch)
+					default:
+						u.setInfo("agent restarted: %s", branch)
+					}
+				})
+			})
+		}()
+	}
+	cancel := func() {
+		u.closeModal("restart-agent")
+	}
+
+	state := "running"
+	if item.AgentState == "crashed" {
+		state = "crashed"
+	} else if item.AgentState != "yes" {
+		state = "not running"
+	}
+	lastText := "No prompt sent to this agent yet."
+	if last != "" {
+		lastText = "Last prompt: " + truncate(strings.Join(strings.Fields(last), " "), 160)
+	}
+	msg := tview.NewTextView().SetDynamicColors(true)
+	msg.SetBackgroundColor(tcell.ColorDefault)
+	msg.SetTextColor(tcell.ColorDefault)
+	msg.SetWrap(true)
+	msg.SetText(fmt.Sprintf(
+		"Restart the agent of [::b]%s[::-] (%s)?\n\nIts agent window is killed and the agent started again.\n\n%s%s[-]",
+		branch,
+		state,
+		colorTag(ColorCyan),
+		tview.Escape(lastText),
+	))
+	msg.SetBorder(true)
+	msg.SetBorderColor(paneBorderColor())
+
+	action := tview.NewTextView().
+		SetDynamicColors(true).
+		SetWrap(false)
+	action.SetBackgroundColor(tcell.ColorDefault)
+	action.SetTextColor(ansiColor(ansiCyan))
+	action.SetText(fmt.Sprintf(" R - Restart agent of [::b]%s[::-]", branch))
+
+	options := tview.NewTable().
+		SetSelectable(true, false).
+		SetBorders(false)
+	options.SetSeparator(' ')
+	options.SetBackgroundColor(tcell.ColorDefault)
+	options.SetSelectedStyle(tcell.StyleDefault.Foreground(tcell.ColorDefault).Background(tcell.ColorDefault).Reverse(true))
+	options.SetBorder(true)
+	options.SetBorderColor(paneBorderColor())
+	type restartOption struct {
+		key   string
+		label string
+		run   func()
+	}
+	choices := []restartOption{{"r", "Restart agent", func() { restart(false) }}}
+	if last != "" {
+		choices = append(choices, restartOption{"p", "Restart and send the last prompt again", func() { restart(true) }})
+	}
+	choices = append(choices, restartOption{"c", "Cancel", cancel})
+	for row, choice := range choices {
+		options.SetCell(row, 0, tview.NewTableCell(choice.key).SetTextColor(ansiColor(ansiCyan)).SetExpansion(1))
+		options.SetCell(row, 1, tview.NewTableCell(choice.label).SetTextColor(tcell.ColorDefault).SetExpansion(1))
+	}
+
+	options.SetSelectedFunc(func(row, _ int) {
+		choices[row].run()
+	})
+	options.SetInputCapture(func(ev *tcell.EventKey) *tcell.EventKey {
+		switch ev.Key() {
+		case tcell.KeyEnter:
+			row, _ := options.GetSelection()
+			choices[row].run()
+			return nil
+		case tcell.KeyEscape:
+			cancel()
+			return nil
+		}
+		if ev.Key() == tcell.KeyRune {
+			key := string(unicode.ToLower(ev.Rune()))
+			for _, choice := range choices {
+				if choice.key == key {
+					choice.run()
+					return nil
+				}
+			}
+			switch key {
+			case "j":
+				row, _ := options.GetSelection()
+				if row < len(choices)-1 {
+					options.Select(row+1, 0)
+				}
+				return nil
+			case "k":
+				row, _ := options.GetSelection()
+				if row > 0 {
+					options.Select(row-1, 0)
+				}
+				return nil
+			}
+		}
+		return ev
+	})
+
+	layout := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(action, 1, 0, false).
+		AddItem(nil, 1, 0, false).
+		AddItem(options, len(choices)+2, 0, true).
+		AddItem(nil, 1, 0, false).
+		AddItem(msg, 7, 0, false)
+	layout.SetBackgroundColor(tcell.ColorDefault)
+
+	u.showModal("restart-agent", layout, 96, len(choices)+13)
+	options.Select(0, 0)
+	u.app.SetFocus(options)
 }
 
 func truncate(s string, max int) string {
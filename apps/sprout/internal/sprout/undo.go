@@ -0,0 +1,281 @@
+package sprout
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	undoFile = "undo.json"
+	// defaultUndoMinutes is how long sprout undo can bring a removed
+	// worktree back.
+	defaultUndoMinutes = 10
+	// undoHeadRef and undoFilesRef keep the removed worktree's commit and
+	// the backup of its uncommitted files from git gc until the undo
+	// window closes.
+	undoHeadRef  = "refs/sprout/undo/head"
+	undoFilesRef = "refs/sprout/undo/files"
+)
+
+// undoBackupScript writes a tree of everything in the worktree, untracked
+// files included but ignored ones not, through a scratch index so the real
+// one is left alone.
+const undoBackupScript = `idx="$(git rev-parse --git-dir)/sprout-undo-index"
+cp "$(git rev-parse --git-path index)" "$idx" 2>/dev/null
+GIT_INDEX_FILE="$idx" git add -A && GIT_INDEX_FILE="$idx" git write-tree
+status=$?
+rm -f "$idx"
+exit $status`
+
+var undoMu sync.Mutex
+
+// RemovedWorktree is the last worktree removed from a repository, kept so
+// sprout undo can recreate it.
+type RemovedWorktree struct {
+	Path   string `json:"path"`
+	Branch string `json:"branch,omitempty"`
+	// Head is the commit the worktree was at. The branch is recreated there
+	// when it was deleted with the worktree.
+	Head string `json:"head"`
+	// Files is a commit on top of Head with the worktree's uncommitted and
+	// untracked files, or empty when it was clean.
+	Files         string    `json:"files,omitempty"`
+	BranchDeleted bool      `json:"branch_deleted,omitempty"`
+	RemovedAt     time.Time `json:"removed_at"`
+	ExpiresAt     time.Time `json:"expires_at"`
+}
+
+type undoStore struct {
+	// Repos maps the main worktree of each repository to its last removal.
+	Repos map[string]RemovedWorktree `json:"repos"`
+}
+
+func undoPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "sprout", undoFile), nil
+}
+
+func readUndoStore() (undoStore, error) {
+	store := undoStore{Repos: map[string]RemovedWorktree{}}
+	path, err := undoPath()
+	if err != nil {
+		return store, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return store, nil
+		}
+		return store, err
+	}
+	if err := json.Unmarshal(data, &store); err != nil {
+		return undoStore{Repos: map[string]RemovedWorktree{}}, err
+	}
+	if store.Repos == nil {
+		store.Repos = map[string]RemovedWorktree{}
+	}
+	return store, nil
+}
+
+func writeUndoStore(store undoStore) error {
+	path, err := undoPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(store, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// updateUndoStore applies change to the removal of mainRoot; a nil result
+// forgets it.
+func updateUndoStore(mainRoot string, change func(*RemovedWorktree) *RemovedWorktree) error {
+	undoMu.Lock()
+	defer undoMu.Unlock()
+	store, err := readUndoStore()
+	if err != nil {
+		errorLogf("undo read failed: %v", err)
+	}
+	var current *RemovedWorktree
+	if rec, ok := store.Repos[mainRoot]; ok {
+		current = &rec
+	}
+	if next := change(current); next != nil {
+		store.Repos[mainRoot] = *next
+	} else if current != nil {
+		delete(store.Repos, mainRoot)
+	} else {
+		return nil
+	}
+	return writeUndoStore(store)
+}
+
+// backupForUndo records wt, about to be removed, so sprout undo can bring it
+// back: its commit, and a commit of its uncommitted files when it has any.
+// It does nothing when undo_minutes is 0.
+func (m *Manager) backupForUndo(repoRoot string, wt *Worktree) error {
+	if m.Cfg.UndoMinutes <= 0 {
+		return nil
+	}
+	head, err := m.runCmdOutput(wt.Path, "git", "rev-parse", "HEAD")
+	if err != nil {
+		return err
+	}
+	head = strings.TrimSpace(head)
+	rec := RemovedWorktree{Path: wt.Path, Branch: wt.Branch, Head: head, RemovedAt: time.Now().UTC()}
+	rec.ExpiresAt = rec.RemovedAt.Add(time.Duration(m.Cfg.UndoMinutes) * time.Minute)
+
+	tree, err := m.runCmdOutput(wt.Path, "sh", "-c", undoBackupScript)
+	if err != nil {
+		return fmt.Errorf("back up uncommitted files: %w", err)
+	}
+	headTree, err := m.runCmdOutput(wt.Path, "git", "rev-parse", head+"^{tree}")
+	if err != nil {
+		return err
+	}
+	if tree = strings.TrimSpace(tree); tree != strings.TrimSpace(headTree) {
+		files, err := m.runCmdOutput(wt.Path, "git", "commit-tree", tree, "-p", head, "-m", "sprout undo: "+worktreeBranchOrName(wt))
+		if err != nil {
+			return fmt.Errorf("back up uncommitted files: %w", err)
+		}
+		rec.Files = strings.TrimSpace(files)
+	}
+
+	if err := m.runCmdQuiet(repoRoot, "git", "update-ref", undoHeadRef, rec.Head); err != nil {
+		return err
+	}
+	if rec.Files != "" {
+		if err := m.runCmdQuiet(repoRoot, "git", "update-ref", undoFilesRef, rec.Files); err != nil {
+			return err
+		}
+	} else {
+		_ = m.runCmdQuiet(repoRoot, "git", "update-ref", "-d", undoFilesRef)
+	}
+	infoLogf("undo backup path=%q head=%s files=%s", wt.Path, rec.Head, rec.Files)
+	return updateUndoStore(m.mainRepoRoot(repoRoot), func(*RemovedWorktree) *RemovedWorktree { return &rec })
+}
+
+// markUndoBranchDeleted notes that the removal of worktreePath deleted its
+// branch too, so sprout undo recreates it.
+func (m *Manager) markUndoBranchDeleted(repoRoot, worktreePath string) {
+	err := updateUndoStore(m.mainRepoRoot(repoRoot), func(rec *RemovedWorktree) *RemovedWorktree {
+		if rec != nil && rec.Path == worktreePath {
+			rec.BranchDeleted = true
+		}
+		return rec
+	})
+	if err != nil {
+		errorLogf("undo update failed path=%q: %v", worktreePath, err)
+	}
+}
+
+// dropUndo forgets the last removal of a repository and its refs.
+func (m *Manager) dropUndo(repoRoot, mainRoot string) {
+	for _, ref := range []string{undoHeadRef, undoFilesRef} {
+		_ = m.runCmdQuiet(repoRoot, "git", "update-ref", "-d", ref)
+	}
+	if err := updateUndoStore(mainRoot, func(*RemovedWorktree) *RemovedWorktree { return nil }); err != nil {
+		errorLogf("undo forget failed repo=%q: %v", mainRoot, err)
+	}
+}
+
+// LastRemoved is the last worktree removed from the current repository
+// that sprout undo can still bring back, or nil.
+func (m *Manager) LastRemoved() (*RemovedWorktree, error) {
+	repoRoot, err := m.RequireRepo()
+	if err != nil {
+		return nil, err
+	}
+	mainRoot := m.mainRepoRoot(repoRoot)
+	undoMu.Lock()
+	store, err := readUndoStore()
+	undoMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	rec, ok := store.Repos[mainRoot]
+	if !ok {
+		return nil, nil
+	}
+	if time.Now().After(rec.ExpiresAt) {
+		m.dropUndo(repoRoot, mainRoot)
+		return nil, nil
+	}
+	return &rec, nil
+}
+
+// Undo recreates the last worktree removed from the current repository
+// within undo_minutes: its branch if it was deleted, the worktree at its old
+// path, and its uncommitted files, which come back as unstaged changes.
+func (m *Manager) Undo(ctx context.Context) (*RemovedWorktree, error) {
+	repoRoot, err := m.RequireRepo()
+	if err != nil {
+		return nil, err
+	}
+	unlock, err := m.lockRepo(ctx, repoRoot, "undo")
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	rec, err := m.LastRemoved()
+	if err != nil {
+		return nil, err
+	}
+	if rec == nil {
+		return nil, errors.New("nothing to undo: no worktree was removed in the last undo_minutes")
+	}
+	if exists, err := m.pathExists(rec.Path); err != nil {
+		return nil, err
+	} else if exists {
+		return nil, fmt.Errorf("cannot restore %s: the path exists again", rec.Path)
+	}
+
+	args := []string{"worktree", "add", rec.Path}
+	switch {
+	case rec.Branch == "":
+		args = []string{"worktree", "add", "--detach", rec.Path, rec.Head}
+	case m.BranchCheckedOutAnywhere(rec.Branch):
+		return nil, fmt.Errorf("cannot restore %s: branch %s is checked out in another worktree", rec.Path, rec.Branch)
+	case !m.BranchExists(repoRoot, rec.Branch):
+		args = []string{"worktree", "add", "-b", rec.Branch, rec.Path, rec.Head}
+	default:
+		args = append(args, rec.Branch)
+	}
+	if err := m.mkdirAll(filepath.Dir(rec.Path)); err != nil {
+		return nil, err
+	}
+	if err := m.runGitWorktreeAdd(ctx, repoRoot, args[2:]...); err != nil {
+		return nil, err
+	}
+	if rec.Files != "" {
+		patch, err := m.runCmdBytes(rec.Path, "git", "diff", "--binary", rec.Head, rec.Files)
+		if err != nil {
+			return nil, fmt.Errorf("restored the worktree, but not its uncommitted files: %w", err)
+		}
+		if len(patch) > 0 {
+			if _, err := m.runCmdBytesInput(rec.Path, patch, "git", "apply", "--whitespace=nowarn"); err != nil {
+				return nil, fmt.Errorf("restored the worktree, but not its uncommitted files (they are in commit %s): %w", rec.Files, err)
+			}
+		}
+	}
+	m.dropUndo(repoRoot, m.mainRepoRoot(repoRoot))
+	wt := &Worktree{Path: rec.Path, Branch: rec.Branch}
+	m.emit(repoRoot, wt, Event{Type: eventWorktreeCreated})
+	infoLogf("undo done path=%q branch=%q", rec.Path, rec.Branch)
+	return rec, nil
+}
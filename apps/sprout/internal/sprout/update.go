@@ -21,9 +21,28 @@ const (
 	updateRepo          = "joegrabski/sprout"
 )
 
+// Update channels: stable releases only, or prereleases as well.
+const (
+	updateChannelStable     = "stable"
+	updateChannelPrerelease = "prerelease"
+)
+
 type updateCache struct {
 	CheckedAt time.Time `json:"checked_at"`
 	Latest    string    `json:"latest"`
+	// Channel is the update_channel Latest was found on; a cache from
+	// another channel is checked again.
+	Channel string `json:"channel,omitempty"`
+}
+
+func parseUpdateChannel(value string) (string, error) {
+	switch v := strings.ToLower(strings.TrimSpace(value)); v {
+	case "", updateChannelStable:
+		return updateChannelStable, nil
+	case updateChannelPrerelease:
+		return v, nil
+	}
+	return "", fmt.Errorf("invalid update_channel %q (want stable or prerelease)", value)
 }
 
 func shouldCheckForUpdates(cfg Config) bool {
@@ -69,35 +88,77 @@ func writeUpdateCache(cache updateCache) {
 	_ = os.WriteFile(path, data, 0o644)
 }
 
-func latestReleaseTag(ctx context.Context) (string, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://api.github.com/repos/"+updateRepo+"/releases/latest", nil)
+// latestReleaseTag asks GitHub for the newest release on channel: the
+// latest stable release, or on the prerelease channel, the highest version
+// among recent releases, prereleases included.
+func latestReleaseTag(ctx context.Context, channel string) (string, error) {
+	if channel == updateChannelPrerelease {
+		return latestPrereleaseTag(ctx)
+	}
+	body, err := githubReleasesGet(ctx, "releases/latest")
 	if err != nil {
 		return "", err
 	}
-	req.Header.Set("User-Agent", "sprout-update-check")
-	client := &http.Client{Timeout: updateCheckTimeout}
-	resp, err := client.Do(req)
-	if err != nil {
+	var payload struct {
+		TagName string `json:"tag_name"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
 		return "", err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return "", fmt.Errorf("update check failed: %s", resp.Status)
+	if strings.TrimSpace(payload.TagName) == "" {
+		return "", errors.New("update check missing tag name")
 	}
-	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	return strings.TrimSpace(payload.TagName), nil
+}
+
+func latestPrereleaseTag(ctx context.Context) (string, error) {
+	body, err := githubReleasesGet(ctx, "releases?per_page=30")
 	if err != nil {
 		return "", err
 	}
-	var payload struct {
+	var payload []struct {
 		TagName string `json:"tag_name"`
+		Draft   bool   `json:"draft"`
 	}
 	if err := json.Unmarshal(body, &payload); err != nil {
 		return "", err
 	}
-	if strings.TrimSpace(payload.TagName) == "" {
-		return "", errors.New("update check missing tag name")
+	latest := ""
+	for _, release := range payload {
+		tag := strings.TrimSpace(release.TagName)
+		if release.Draft || tag == "" {
+			continue
+		}
+		if _, ok := parseSemver(tag); !ok {
+			continue
+		}
+		if latest == "" || isNewerVersion(tag, latest) {
+			latest = tag
+		}
 	}
-	return strings.TrimSpace(payload.TagName), nil
+	if latest == "" {
+		return "", errors.New("update check found no releases")
+	}
+	return latest, nil
+}
+
+// githubReleasesGet fetches path under the repository in the GitHub API.
+func githubReleasesGet(ctx context.Context, path string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://api.github.com/repos/"+updateRepo+"/"+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "sprout-update-check")
+	client := &http.Client{Timeout: updateCheckTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("update check failed: %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 512*1024))
 }
 
 func parseSemver(value string) ([3]int, bool) {
@@ -141,7 +202,61 @@ func isNewerVersion(latest, current string) bool {
 			return false
 		}
 	}
-	return false
+	return comparePrerelease(semverPrerelease(latest), semverPrerelease(current)) > 0
+}
+
+// semverPrerelease returns the prerelease part of a version, "rc.1" in
+// v1.2.0-rc.1+build, or "" for a release.
+func semverPrerelease(value string) string {
+	raw := strings.TrimSpace(strings.ToLower(value))
+	if idx := strings.Index(raw, "+"); idx >= 0 {
+		raw = raw[:idx]
+	}
+	_, pre, _ := strings.Cut(raw, "-")
+	return pre
+}
+
+// comparePrerelease orders the prerelease parts of two versions with the
+// same core the semver way: a release comes after its prereleases, and
+// dot-separated identifiers compare numerically when both are numbers.
+func comparePrerelease(a, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return 1
+	case b == "":
+		return -1
+	}
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aErr := strconv.Atoi(as[i])
+		bn, bErr := strconv.Atoi(bs[i])
+		switch {
+		case aErr == nil && bErr == nil:
+			if an != bn {
+				if an > bn {
+					return 1
+				}
+				return -1
+			}
+		case aErr == nil:
+			return -1
+		case bErr == nil:
+			return 1
+		default:
+			if c := strings.Compare(as[i], bs[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	switch {
+	case len(as) > len(bs):
+		return 1
+	case len(as) < len(bs):
+		return -1
+	}
+	return 0
 }
 
 func checkForUpdate(current string, cfg Config) (string, bool) {
@@ -152,7 +267,11 @@ func checkForUpdate(current string, cfg Config) (string, bool) {
 		return "", false
 	}
 	cache, err := readUpdateCache()
-	if err == nil && time.Since(cache.CheckedAt) < updateCheckInterval {
+	channel := cfg.UpdateChannel
+	if channel == "" {
+		channel = updateChannelStable
+	}
+	if err == nil && cacheChannel(cache) == channel && time.Since(cache.CheckedAt) < updateCheckInterval {
 		if cache.Latest != "" && isNewerVersion(cache.Latest, current) {
 			return cache.Latest, true
 		}
@@ -161,13 +280,22 @@ func checkForUpdate(current string, cfg Config) (string, bool) {
 
 	ctx, cancel := context.WithTimeout(context.Background(), updateCheckTimeout)
 	defer cancel()
-	latest, err := latestReleaseTag(ctx)
+	latest, err := latestReleaseTag(ctx, channel)
 	if err != nil {
 		return "", false
 	}
-	writeUpdateCache(updateCache{CheckedAt: time.Now(), Latest: latest})
+	writeUpdateCache(updateCache{CheckedAt: time.Now(), Latest: latest, Channel: channel})
 	if isNewerVersion(latest, current) {
 		return latest, true
 	}
 	return "", false
 }
+
+// cacheChannel is the channel a cache was written for; caches from before
+// update_channel existed are stable ones.
+func cacheChannel(cache updateCache) string {
+	if cache.Channel == "" {
+		return updateChannelStable
+	}
+	return cache.Channel
+}
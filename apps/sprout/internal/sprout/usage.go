@@ -0,0 +1,345 @@
+package sprout
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const agentUsageFile = "usage.json"
+
+var agentUsageMu sync.Mutex
+
+// AgentUsage is the token and cost usage of a worktree's agents, as far as
+// they print it. It is an estimate: agents that never report usage count
+// nothing.
+type AgentUsage struct {
+	Tokens  int64   `json:"tokens,omitempty"`
+	CostUSD float64 `json:"cost_usd,omitempty"`
+	// ContextLeft is the percentage of the context window the running
+	// agent last reported left, or 0 when it does not report it.
+	ContextLeft int `json:"context_left,omitempty"`
+}
+
+func (u AgentUsage) add(o AgentUsage) AgentUsage {
+	u.Tokens += o.Tokens
+	u.CostUSD += o.CostUSD
+	return u
+}
+
+// worktreeUsage is the recorded usage of one worktree. Agents report running
+// totals for their own session, so the totals of earlier sessions are kept
+// apart from the latest report of the current one.
+type worktreeUsage struct {
+	Past    AgentUsage `json:"past"`
+	Session AgentUsage `json:"session"`
+}
+
+func (w worktreeUsage) total() AgentUsage {
+	total := w.Past.add(w.Session)
+	total.ContextLeft = w.Session.ContextLeft
+	return total
+}
+
+type agentUsageStore struct {
+	Worktrees map[string]worktreeUsage `json:"worktrees"`
+}
+
+var (
+	// Codex: "42% context left" in its footer.
+	usageContextLeftRe = regexp.MustCompile(`(?i)\b(\d{1,3})% context left`)
+	// Codex: "Token usage: total=12,345 input=..." on exit, and
+	// "12.3K tokens used" in its footer.
+	usageCodexTotalRe = regexp.MustCompile(`(?i)token usage:\s*total=([\d,]+)`)
+	usageTokensUsedRe = regexp.MustCompile(`(?i)\b([\d][\d,.]*\s*[km]?)\s+tokens used\b`)
+	// aider: "Tokens: 12k sent, 1.2k received. Cost: $0.02 message, $0.35 session."
+	usageSessionCostRe = regexp.MustCompile(`(?i)\$([\d.]+)\s+session\b`)
+	// Claude Code /cost: "Total cost: $0.12".
+	usageTotalCostRe = regexp.MustCompile(`(?i)total cost:\s*\$([\d.]+)`)
+)
+
+// parseAgentUsage reads the latest usage an agent printed in its output.
+// Each figure is taken from the last line reporting it; ok is false when the
+// output reports none.
+func parseAgentUsage(output string) (AgentUsage, bool) {
+	plain := stripANSI(output)
+	lines := strings.Split(strings.ReplaceAll(plain, "\r", "\n"), "\n")
+	var usage AgentUsage
+	haveTokens, haveCost := false, false
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := lines[i]
+		if usage.ContextLeft == 0 {
+			if m := usageContextLeftRe.FindStringSubmatch(line); m != nil {
+				if n, err := strconv.Atoi(m[1]); err == nil && n > 0 && n <= 100 {
+					usage.ContextLeft = n
+				}
+			}
+		}
+		if !haveTokens {
+			var raw string
+			if m := usageCodexTotalRe.FindStringSubmatch(line); m != nil {
+				raw = m[1]
+			} else if m := usageTokensUsedRe.FindStringSubmatch(line); m != nil {
+				raw = m[1]
+			}
+			if n, ok := parseTokenCount(raw); ok {
+				usage.Tokens, haveTokens = n, true
+			}
+		}
+		if !haveCost {
+			var raw string
+			if m := usageSessionCostRe.FindStringSubmatch(line); m != nil {
+				raw = m[1]
+			} else if m := usageTotalCostRe.FindStringSubmatch(line); m != nil {
+				raw = m[1]
+			}
+			if cost, err := strconv.ParseFloat(strings.TrimSuffix(raw, "."), 64); raw != "" && err == nil {
+				usage.CostUSD, haveCost = cost, true
+			}
+		}
+	}
+	return usage, haveTokens || haveCost || usage.ContextLeft > 0
+}
+
+// parseTokenCount reads token counts like "12,345", "12.3k" or "1.2M".
+func parseTokenCount(raw string) (int64, bool) {
+	raw = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(raw), ",", ""))
+	if raw == "" {
+		return 0, false
+	}
+	scale := 1.0
+	switch {
+	case strings.HasSuffix(raw, "k"):
+		scale, raw = 1e3, strings.TrimSpace(strings.TrimSuffix(raw, "k"))
+	case strings.HasSuffix(raw, "m"):
+		scale, raw = 1e6, strings.TrimSpace(strings.TrimSuffix(raw, "m"))
+	}
+	n, err := strconv.ParseFloat(raw, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return int64(n*scale + 0.5), true
+}
+
+// formatAgentUsage renders usage compactly, like "12.3k tok $0.35".
+func formatAgentUsage(u AgentUsage) string {
+	parts := []string{}
+	if u.Tokens > 0 {
+		parts = append(parts, compactCount(int(u.Tokens))+" tok")
+	}
+	if u.CostUSD > 0 {
+		parts = append(parts, fmt.Sprintf("$%.2f", u.CostUSD))
+	}
+	return strings.Join(parts, " ")
+}
+
+func agentUsagePath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "sprout", agentUsageFile), nil
+}
+
+func readAgentUsage() (agentUsageStore, error) {
+	store := agentUsageStore{Worktrees: map[string]worktreeUsage{}}
+	path, err := agentUsagePath()
+	if err != nil {
+		return store, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return store, nil
+		}
+		return store, err
+	}
+	if err := json.Unmarshal(data, &store); err != nil {
+		return agentUsageStore{Worktrees: map[string]worktreeUsage{}}, err
+	}
+	if store.Worktrees == nil {
+		store.Worktrees = map[string]worktreeUsage{}
+	}
+	return store, nil
+}
+
+func writeAgentUsage(store agentUsageStore) error {
+	path, err := agentUsagePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(store, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// updateAgentUsage applies change to the recorded usage and saves it when it
+// reports a change.
+func updateAgentUsage(change func(map[string]worktreeUsage) bool) error {
+	agentUsageMu.Lock()
+	defer agentUsageMu.Unlock()
+
+	store, err := readAgentUsage()
+	if err != nil {
+		errorLogf("agent_usage read failed: %v", err)
+	}
+	if !change(store.Worktrees) {
+		return nil
+	}
+	return writeAgentUsage(store)
+}
+
+// recordAgentUsage merges a usage report of the agent of worktreePath into
+// its recorded usage and returns the new totals. A report with lower running
+// totals than the last one comes from a new agent session, so the last
+// session is added to the past ones first.
+func recordAgentUsage(worktreePath string, report AgentUsage) (AgentUsage, error) {
+	var total AgentUsage
+	err := updateAgentUsage(func(worktrees map[string]worktreeUsage) bool {
+		w := worktrees[worktreePath]
+		session := w.Session
+		restarted := (report.Tokens > 0 && report.Tokens < session.Tokens) ||
+			(report.CostUSD > 0 && report.CostUSD < session.CostUSD)
+		if restarted {
+			w.Past = w.Past.add(session)
+			session = AgentUsage{}
+		}
+		next := session
+		if report.Tokens > 0 {
+			next.Tokens = report.Tokens
+		}
+		if report.CostUSD > 0 {
+			next.CostUSD = report.CostUSD
+		}
+		if report.ContextLeft > 0 {
+			next.ContextLeft = report.ContextLeft
+		}
+		w.Session = next
+		total = w.total()
+		if next == session && !restarted {
+			return false
+		}
+		worktrees[worktreePath] = w
+		return true
+	})
+	return total, err
+}
+
+// agentUsageTotals is the recorded usage of every worktree by path.
+func agentUsageTotals() map[string]AgentUsage {
+	agentUsageMu.Lock()
+	store, err := readAgentUsage()
+	agentUsageMu.Unlock()
+	if err != nil {
+		errorLogf("agent_usage read failed: %v", err)
+	}
+	totals := make(map[string]AgentUsage, len(store.Worktrees))
+	for path, w := range store.Worktrees {
+		totals[path] = w.total()
+	}
+	return totals
+}
+
+// renameAgentUsage carries the usage of a moved worktree over to its new
+// path.
+func renameAgentUsage(oldPath, newPath string) error {
+	return updateAgentUsage(func(worktrees map[string]worktreeUsage) bool {
+		w, ok := worktrees[oldPath]
+		if !ok {
+			return false
+		}
+		delete(worktrees, oldPath)
+		worktrees[newPath] = w
+		return true
+	})
+}
+
+// forgetAgentUsage drops the usage of a removed worktree.
+func forgetAgentUsage(worktreePath string) {
+	err := updateAgentUsage(func(worktrees map[string]worktreeUsage) bool {
+		if _, ok := worktrees[worktreePath]; !ok {
+			return false
+		}
+		delete(worktrees, worktreePath)
+		return true
+	})
+	if err != nil {
+		errorLogf("agent_usage forget failed path=%q: %v", worktreePath, err)
+	}
+}
+
+// sampleAgentUsage reads the usage the agent of wt shows in its pane and
+// records it. ok is false when the pane shows no usage.
+func (m *Manager) sampleAgentUsage(repoRoot string, wt *Worktree) (AgentUsage, bool) {
+	out, err := m.agentOutputForWorktree(repoRoot, wt, 200)
+	if err != nil {
+		return AgentUsage{}, false
+	}
+	report, ok := parseAgentUsage(out)
+	if !ok {
+		return AgentUsage{}, false
+	}
+	total, err := recordAgentUsage(wt.Path, report)
+	if err != nil {
+		errorLogf("agent_usage record failed path=%q: %v", wt.Path, err)
+	}
+	return total, true
+}
+
+// WorktreeStatus is one worktree of sprout status.
+type WorktreeStatus struct {
+	Branch string     `json:"branch"`
+	Path   string     `json:"path"`
+	Agent  string     `json:"agent"`
+	Usage  AgentUsage `json:"usage"`
+}
+
+// StatusReport summarizes the agents of a repository and their usage.
+type StatusReport struct {
+	Repo      string           `json:"repo"`
+	Worktrees []WorktreeStatus `json:"worktrees"`
+	Total     AgentUsage       `json:"total"`
+}
+
+// Status reads the usage running agents show now, then reports the recorded
+// usage of every worktree and their total.
+func (m *Manager) Status(ctx context.Context) (StatusReport, error) {
+	repoRoot, err := m.RequireRepo()
+	if err != nil {
+		return StatusReport{}, err
+	}
+	items, err := m.ListWorktrees(ctx)
+	if err != nil {
+		return StatusReport{}, err
+	}
+	report := StatusReport{Repo: m.RepoName(repoRoot), Worktrees: []WorktreeStatus{}}
+	for i := range items {
+		wt := &items[i]
+		usage := wt.Usage
+		if wt.AgentState == "yes" {
+			if total, ok := m.sampleAgentUsage(repoRoot, wt); ok {
+				usage = total
+			}
+		}
+		report.Worktrees = append(report.Worktrees, WorktreeStatus{
+			Branch: worktreeBranchOrName(wt),
+			Path:   wt.Path,
+			Agent:  wt.AgentState,
+			Usage:  usage,
+		})
+		report.Total = report.Total.add(usage)
+	}
+	return report, nil
+}
@@ -1,3 +1,84 @@
 package sprout
 
-var Version = "dev"
+import (
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// Version, Commit, and BuildDate are set at release time with
+//
+//	-ldflags "-X sprout/internal/sprout.Version=... -X sprout/internal/sprout.Commit=... -X sprout/internal/sprout.BuildDate=..."
+//
+// A plain go build of a git checkout leaves Commit and BuildDate empty;
+// buildInfo falls back to the revision and commit time go embeds then.
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildDate = ""
+)
+
+// BuildInfo is what sprout version reports about the running binary.
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildDate string `json:"build_date,omitempty"`
+	// Modified is set for builds of a checkout with uncommitted changes.
+	Modified  bool   `json:"modified,omitempty"`
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`
+}
+
+func buildInfo() BuildInfo {
+	info := BuildInfo{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range bi.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.time":
+				if info.BuildDate == "" {
+					info.BuildDate = setting.Value
+				}
+			case "vcs.modified":
+				info.Modified = setting.Value == "true"
+			}
+		}
+	}
+	return info
+}
+
+// ShortCommit is the commit abbreviated to 7 characters, with a + for
+// uncommitted changes, or empty when it is unknown.
+func (b BuildInfo) ShortCommit() string {
+	commit := b.Commit
+	if len(commit) > 7 {
+		commit = commit[:7]
+	}
+	if commit != "" && b.Modified {
+		commit += "+"
+	}
+	return commit
+}
+
+// String renders the version and what is known of the build on one line,
+// e.g. "1.4.0 (3f9c2a1, 2026-05-02T10:31:00Z, go1.22.3 linux/amd64)".
+func (b BuildInfo) String() string {
+	details := []string{}
+	if commit := b.ShortCommit(); commit != "" {
+		details = append(details, commit)
+	}
+	if b.BuildDate != "" {
+		details = append(details, b.BuildDate)
+	}
+	details = append(details, b.GoVersion+" "+b.Platform)
+	return b.Version + " (" + strings.Join(details, ", ") + ")"
+}
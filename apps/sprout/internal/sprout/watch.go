@@ -0,0 +1,277 @@
+package sprout
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	watchStatusFile      = "status.json"
+	defaultWatchInterval = 30 * time.Second
+)
+
+var watchStatusMu sync.Mutex
+
+// AgentCounts counts the agents of a repository by state.
+type AgentCounts struct {
+	Busy    int `json:"busy"`
+	Ready   int `json:"ready"`
+	Crashed int `json:"crashed"`
+}
+
+// WatchedWorktree is one worktree in the status file.
+type WatchedWorktree struct {
+	Branch string `json:"branch"`
+	Path   string `json:"path"`
+	Dirty  bool   `json:"dirty"`
+	// Agent is busy, ready, crashed, or none.
+	Agent string `json:"agent"`
+}
+
+// RepoStatus is what sprout watch last saw of a repository.
+type RepoStatus struct {
+	Repo      string            `json:"repo"`
+	Root      string            `json:"root"`
+	UpdatedAt time.Time         `json:"updated_at"`
+	Interval  int               `json:"interval_seconds"`
+	Worktrees int               `json:"worktrees"`
+	Dirty     int               `json:"dirty"`
+	Agents    AgentCounts       `json:"agents"`
+	Usage     AgentUsage        `json:"usage"`
+	Items     []WatchedWorktree `json:"items"`
+}
+
+// WatchStatus is the status file: the last status of every watched
+// repository, by main repository root.
+type WatchStatus struct {
+	Repos map[string]RepoStatus `json:"repos"`
+}
+
+// WatchOptions configures sprout watch.
+type WatchOptions struct {
+	Interval time.Duration
+	// StatusFile is the status file to write; empty uses the default.
+	StatusFile string
+	// MetricsFile, when set, also receives the status as Prometheus text
+	// metrics, for the node_exporter textfile collector.
+	MetricsFile string
+	// Once writes the status a single time instead of every Interval.
+	Once bool
+}
+
+// DefaultWatchStatusFile is the status file sprout watch writes and sprout
+// statusline reads unless told otherwise.
+func DefaultWatchStatusFile() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "sprout", watchStatusFile), nil
+}
+
+// ReadWatchStatus reads a status file. A missing file is an empty status.
+func ReadWatchStatus(path string) (WatchStatus, error) {
+	status := WatchStatus{Repos: map[string]RepoStatus{}}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return status, nil
+		}
+		return status, err
+	}
+	if err := json.Unmarshal(data, &status); err != nil {
+		return WatchStatus{Repos: map[string]RepoStatus{}}, err
+	}
+	if status.Repos == nil {
+		status.Repos = map[string]RepoStatus{}
+	}
+	return status, nil
+}
+
+// writeFileAtomic replaces path with data through a rename, so readers never
+// see a half-written file.
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// writeRepoStatus stores the status of one repository in the status file,
+// keeping the other repositories' entries.
+func writeRepoStatus(path string, repo RepoStatus) error {
+	watchStatusMu.Lock()
+	defer watchStatusMu.Unlock()
+
+	status, err := ReadWatchStatus(path)
+	if err != nil {
+		errorLogf("watch_status read failed path=%q: %v", path, err)
+	}
+	status.Repos[repo.Root] = repo
+	data, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// RepoStatus lists the worktrees of the current repository and what their
+// agents are doing.
+func (m *Manager) RepoStatus(ctx context.Context) (RepoStatus, error) {
+	repoRoot, err := m.RequireRepo()
+	if err != nil {
+		return RepoStatus{}, err
+	}
+	items, err := m.ListWorktrees(ctx)
+	if err != nil {
+		return RepoStatus{}, err
+	}
+	status := RepoStatus{
+		Repo:      m.RepoName(repoRoot),
+		Root:      m.mainRepoRoot(repoRoot),
+		UpdatedAt: time.Now().UTC(),
+		Worktrees: len(items),
+		Items:     []WatchedWorktree{},
+	}
+	for i := range items {
+		wt := &items[i]
+		agent := "none"
+		switch wt.AgentState {
+		case "crashed":
+			agent = "crashed"
+		case "yes":
+			switch m.agentStatus(repoRoot, wt) {
+			case agentStatusReady:
+				agent = "ready"
+			case agentStatusBusy:
+				agent = "busy"
+			case agentStatusExited:
+				agent = "crashed"
+			}
+		}
+		switch agent {
+		case "busy":
+			status.Agents.Busy++
+		case "ready":
+			status.Agents.Ready++
+		case "crashed":
+			status.Agents.Crashed++
+		}
+		if wt.Dirty {
+			status.Dirty++
+		}
+		status.Usage = status.Usage.add(wt.Usage)
+		status.Items = append(status.Items, WatchedWorktree{
+			Branch: worktreeBranchOrName(wt),
+			Path:   wt.Path,
+			Dirty:  wt.Dirty,
+			Agent:  agent,
+		})
+	}
+	return status, nil
+}
+
+// Watch refreshes the status of the current repository every opts.Interval
+// and writes it to the status file, and the metrics file when one is set,
+// until ctx is done. A failed refresh is logged and retried on the next
+// tick; it does not stop the watch.
+func (m *Manager) Watch(ctx context.Context, opts WatchOptions) error {
+	if _, err := m.RequireRepo(); err != nil {
+		return err
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = defaultWatchInterval
+	}
+	if opts.StatusFile == "" {
+		path, err := DefaultWatchStatusFile()
+		if err != nil {
+			return err
+		}
+		opts.StatusFile = path
+	}
+	write := func() error {
+		status, err := m.RepoStatus(ctx)
+		if err != nil {
+			return err
+		}
+		status.Interval = int(opts.Interval / time.Second)
+		if err := writeRepoStatus(opts.StatusFile, status); err != nil {
+			return err
+		}
+		if opts.MetricsFile != "" {
+			if err := writeFileAtomic(opts.MetricsFile, []byte(renderWatchMetrics(status))); err != nil {
+				return err
+			}
+		}
+		debugLogf("watch wrote repo=%q worktrees=%d busy=%d ready=%d", status.Repo, status.Worktrees, status.Agents.Busy, status.Agents.Ready)
+		return nil
+	}
+	if opts.Once {
+		return write()
+	}
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+	for {
+		if err := write(); err != nil {
+			errorLogf("watch refresh failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// renderWatchMetrics renders a repository status as Prometheus text
+// metrics.
+func renderWatchMetrics(status RepoStatus) string {
+	repo := fmt.Sprintf("repo=%q", status.Repo)
+	var b strings.Builder
+	gauge := func(name, help string, samples map[string]float64) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+		labels := make([]string, 0, len(samples))
+		for l := range samples {
+			labels = append(labels, l)
+		}
+		sort.Strings(labels)
+		for _, l := range labels {
+			fmt.Fprintf(&b, "%s{%s} %s\n", name, l, strconv.FormatFloat(samples[l], 'f', -1, 64))
+		}
+	}
+	gauge("sprout_worktrees", "Worktrees of the repository.", map[string]float64{repo: float64(status.Worktrees)})
+	gauge("sprout_worktrees_dirty", "Worktrees with uncommitted changes.", map[string]float64{repo: float64(status.Dirty)})
+	gauge("sprout_agents", "Agents by state.", map[string]float64{
+		repo + `,state="busy"`:    float64(status.Agents.Busy),
+		repo + `,state="ready"`:   float64(status.Agents.Ready),
+		repo + `,state="crashed"`: float64(status.Agents.Crashed),
+	})
+	gauge("sprout_agent_tokens", "Tokens the agents reported using.", map[string]float64{repo: float64(status.Usage.Tokens)})
+	gauge("sprout_agent_cost_usd", "Cost in US dollars the agents reported.", map[string]float64{repo: status.Usage.CostUSD})
+	gauge("sprout_status_updated_seconds", "Unix time of the last refresh.", map[string]float64{repo: float64(status.UpdatedAt.Unix())})
+	return b.String()
+}
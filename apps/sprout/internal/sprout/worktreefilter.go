@@ -0,0 +1,237 @@
+package sprout
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Kinds of worktree filter terms, written kind:value.
+const (
+	filterTermLabel    = "label"
+	filterTermStatus   = "status"
+	filterTermAgent    = "agent"
+	filterTermPriority = "priority"
+)
+
+// Values of agent: terms besides the agent statuses ready, busy, and exited,
+// which only a watched or sampled agent has.
+const (
+	filterAgentRunning = "running"
+	filterAgentNone    = "none"
+	filterAgentCrashed = "crashed"
+)
+
+var filterPresetNameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// worktreeFilter is a parsed worktree filter: the kind:value terms a
+// worktree must satisfy, and the query the rest of the filter fuzzy-matches
+// against branches. A worktree needs every label: term and one term of each
+// other kind.
+type worktreeFilter struct {
+	Query    string
+	Labels   []string
+	Status   []string
+	Agent    []string
+	Priority []string
+}
+
+func parseWorktreeFilter(filter string) worktreeFilter {
+	var f worktreeFilter
+	query := []string{}
+	for _, term := range strings.Fields(filter) {
+		kind, value, ok := strings.Cut(strings.ToLower(term), ":")
+		if !ok || value == "" {
+			query = append(query, term)
+			continue
+		}
+		switch kind {
+		case filterTermLabel:
+			f.Labels = append(f.Labels, value)
+		case filterTermStatus:
+			f.Status = append(f.Status, value)
+		case filterTermAgent:
+			f.Agent = append(f.Agent, value)
+		case filterTermPriority:
+			if priority, err := parsePriority(value); err == nil {
+				value = priority
+			}
+			f.Priority = append(f.Priority, value)
+		default:
+			// Git refuses ':' in branch names, but a path may have one.
+			query = append(query, term)
+		}
+	}
+	f.Query = strings.Join(query, " ")
+	return f
+}
+
+// needsAgentStatus reports whether the filter asks whether agents are ready,
+// busy, or exited, which takes looking at their panes.
+func (f worktreeFilter) needsAgentStatus() bool {
+	for _, value := range f.Agent {
+		switch value {
+		case filterAgentRunning, filterAgentNone, filterAgentCrashed:
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether wt, whose agent has agentStatus, satisfies the
+// terms of the filter. The query is left to the caller, which ranks by it.
+func (f worktreeFilter) matches(wt Worktree, agentStatus string) bool {
+	if !hasLabels(wt, f.Labels) {
+		return false
+	}
+	if len(f.Status) > 0 && !f.statusMatches(wt) {
+		return false
+	}
+	if len(f.Priority) > 0 && !containsString(f.Priority, worktreePriority(wt)) {
+		return false
+	}
+	if len(f.Agent) == 0 {
+		return true
+	}
+	for _, value := range f.Agent {
+		if agentFilterMatches(value, wt, agentStatus) {
+			return true
+		}
+	}
+	return false
+}
+
+// statusMatches reports whether a status: term of the filter names the
+// STATUS column of wt. status:dirty also keeps worktrees with uncommitted
+// changes or an unfinished rebase, merge, cherry-pick, or revert whatever
+// the column shows, such as rebasing.
+func (f worktreeFilter) statusMatches(wt Worktree) bool {
+	// Terms cannot hold a space: "rebase aborted" is status:rebase-aborted.
+	if containsString(f.Status, strings.ReplaceAll(worktreeStatusLabel(wt), " ", "-")) {
+		return true
+	}
+	return (wt.Dirty || wt.Unfinished) && containsString(f.Status, "dirty")
+}
+
+func agentFilterMatches(value string, wt Worktree, agentStatus string) bool {
+	switch value {
+	case filterAgentRunning:
+		return wt.AgentState == "yes"
+	case filterAgentNone:
+		return wt.AgentState != "yes" && wt.AgentState != filterAgentCrashed
+	case filterAgentCrashed:
+		return wt.AgentState == filterAgentCrashed
+	}
+	return agentStatus != agentStatusNone && value == agentStatus
+}
+
+// queryMatches reports whether the filter's query fuzzy-matches the branch
+// of wt or is part of its path, the way the TUI filter ranks worktrees.
+func (f worktreeFilter) queryMatches(wt Worktree) bool {
+	q := strings.ToLower(strings.TrimSpace(f.Query))
+	if q == "" {
+		return true
+	}
+	if _, _, ok := fuzzyMatch(q, wt.Branch); ok {
+		return true
+	}
+	return strings.Contains(strings.ToLower(wt.Path), q)
+}
+
+// filterWorktrees keeps the items of the repository at repoRoot that satisfy
+// filter, in their order. Agents are only looked at when the filter asks for
+// their status.
+func (m *Manager) filterWorktrees(repoRoot string, items []Worktree, filter worktreeFilter) []Worktree {
+	sample := filter.needsAgentStatus() && m.commandExists("tmux")
+	kept := []Worktree{}
+	for i := range items {
+		status := agentStatusNone
+		if sample {
+			status = m.agentStatus(repoRoot, &items[i])
+		}
+		if filter.matches(items[i], status) && filter.queryMatches(items[i]) {
+			kept = append(kept, items[i])
+		}
+	}
+	return kept
+}
+
+func parseFilterPresetName(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if !filterPresetNameRe.MatchString(name) {
+		return "", fmt.Errorf("invalid filter preset name %q (want letters, digits, _ and -)", name)
+	}
+	return name, nil
+}
+
+func defaultFilterPresets() map[string]string {
+	return map[string]string{
+		"dirty":       "status:dirty",
+		"agent-ready": "agent:ready",
+	}
+}
+
+// filterPresetNames lists the presets of cfg by name.
+func filterPresetNames(presets map[string]string) []string {
+	names := make([]string, 0, len(presets))
+	for name, filter := range presets {
+		if strings.TrimSpace(filter) != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resolveFilter returns the filter a preset name stands for, or value itself
+// when no preset has that name.
+func resolveFilter(presets map[string]string, value string) string {
+	if filter, ok := presets[strings.ToLower(strings.TrimSpace(value))]; ok && strings.TrimSpace(filter) != "" {
+		return filter
+	}
+	return value
+}
+
+// filterPresetFor returns the name of the preset whose filter is filter.
+func filterPresetFor(presets map[string]string, filter string) string {
+	filter = strings.TrimSpace(filter)
+	if filter == "" {
+		return ""
+	}
+	for _, name := range filterPresetNames(presets) {
+		if strings.TrimSpace(presets[name]) == filter {
+			return name
+		}
+	}
+	return ""
+}
+
+// filtersTable is the table holding the filter presets of a table of the
+// config file, like hooksTable.
+func filtersTable(table string) string {
+	return nestedConfigTable(table, "filters")
+}
+
+// parseFilterPresetLine reads a line of a filters table, such as
+// dirty = "status:dirty", into cfg.
+func parseFilterPresetLine(cfg *Config, line string) error {
+	key, value, ok := strings.Cut(line, "=")
+	if !ok {
+		return nil
+	}
+	name, err := parseFilterPresetName(key)
+	if err != nil {
+		return err
+	}
+	filter, err := parseString(strings.TrimSpace(value))
+	if err != nil {
+		return fmt.Errorf("invalid filters.%s: %w", name, err)
+	}
+	if cfg.FilterPresets == nil {
+		cfg.FilterPresets = map[string]string{}
+	}
+	cfg.FilterPresets[name] = strings.TrimSpace(filter)
+	return nil
+}
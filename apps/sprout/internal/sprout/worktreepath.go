@@ -0,0 +1,186 @@
+package sprout
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// defaultWorktreePathTemplate nests each worktree under the worktree root by
+// its branch name, so feat/login lives in <root>/feat/login.
+const defaultWorktreePathTemplate = "{branch}"
+
+// worktreePathSuffixRe matches the -2, -3, ... worktreePath appends to a
+// path another worktree already has.
+var worktreePathSuffixRe = regexp.MustCompile(`^-[0-9]+$`)
+
+func parseWorktreePathTemplate(value string) (string, error) {
+	v := strings.TrimSpace(value)
+	if v == "" {
+		return defaultWorktreePathTemplate, nil
+	}
+	for _, p := range branchPlaceholderRe.FindAllString(v, -1) {
+		switch p {
+		case "{branch}", "{type}", "{slug}", "{date}":
+		default:
+			return "", fmt.Errorf("invalid worktree_path_template %q: unknown placeholder %s (want {branch}, {type}, {slug}, or {date})", value, p)
+		}
+	}
+	if !strings.Contains(v, "{branch}") && !strings.Contains(v, "{slug}") {
+		return "", fmt.Errorf("invalid worktree_path_template %q: must contain {branch} or {slug}", value)
+	}
+	if filepath.IsAbs(v) || strings.HasPrefix(v, "~") {
+		return "", fmt.Errorf("invalid worktree_path_template %q: must be relative to the worktree root", value)
+	}
+	for _, part := range strings.Split(filepath.ToSlash(v), "/") {
+		if part == ".." {
+			return "", fmt.Errorf("invalid worktree_path_template %q: must stay inside the worktree root", value)
+		}
+	}
+	return v, nil
+}
+
+// splitBranchTypeSlug splits a branch into the {type} and {slug} of
+// worktree_path_template: the part before the first slash, and the rest with
+// slashes flattened to dashes. A branch without a slash is all slug.
+func splitBranchTypeSlug(branch string) (string, string) {
+	branchType, slug, ok := strings.Cut(branch, "/")
+	if !ok {
+		return "", branch
+	}
+	return branchType, strings.ReplaceAll(slug, "/", "-")
+}
+
+// worktreePathName expands worktree_path_template for branch into a path
+// relative to the worktree root. Path segments left empty by a missing
+// {type}, and the dashes around it, are dropped. With flatten_branch_dirs the
+// segments are joined by dashes instead, one directory per worktree.
+func (m *Manager) worktreePathName(branch string, now time.Time) string {
+	template := m.Cfg.WorktreePathTemplate
+	if template == "" {
+		template = defaultWorktreePathTemplate
+	}
+	branchType, slug := splitBranchTypeSlug(branch)
+	expanded := branchPlaceholderRe.ReplaceAllStringFunc(template, func(p string) string {
+		switch p {
+		case "{branch}":
+			return branch
+		case "{type}":
+			return branchType
+		case "{slug}":
+			return slug
+		case "{date}":
+			return now.Format("2006-01-02")
+		}
+		return p
+	})
+	parts := []string{}
+	for _, part := range strings.Split(filepath.ToSlash(expanded), "/") {
+		if part = strings.Trim(part, "-_. "); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	if len(parts) == 0 {
+		return safeName(branch)
+	}
+	if m.Cfg.FlattenBranchDirs {
+		return strings.Join(parts, "-")
+	}
+	return filepath.Join(parts...)
+}
+
+// flattenBranchDir is the directory name flatten_branch_dirs gives branch.
+func flattenBranchDir(branch string) string {
+	return strings.ReplaceAll(branch, "/", "-")
+}
+
+// worktreePath is where a new worktree for branch goes: worktree_path_template
+// under the worktree root, with -2, -3, ... appended when a worktree of
+// another branch already has that path, such as feat/login and fix/login
+// under "{slug}".
+func (m *Manager) worktreePath(repoRoot, branch string) string {
+	base := absPath(filepath.Join(m.WorktreeRootDir(repoRoot), m.worktreePathName(branch, time.Now())))
+	items, err := m.parseWorktreeList(repoRoot)
+	if err != nil {
+		return base
+	}
+	taken := make(map[string]string, len(items))
+	for _, wt := range items {
+		taken[absPath(wt.Path)] = wt.Branch
+	}
+	path := base
+	for n := 2; ; n++ {
+		if owner, ok := taken[path]; !ok || owner == branch {
+			return path
+		}
+		path = fmt.Sprintf("%s-%d", base, n)
+	}
+}
+
+// worktreeFollowsLayout reports whether path is where worktree_path_template
+// puts a worktree of branch, allowing for a collision suffix.
+func (m *Manager) worktreeFollowsLayout(repoRoot, branch, path string) bool {
+	want := absPath(filepath.Join(m.WorktreeRootDir(repoRoot), m.worktreePathName(branch, time.Now())))
+	suffix, ok := strings.CutPrefix(absPath(path), want)
+	return ok && (suffix == "" || worktreePathSuffixRe.MatchString(suffix))
+}
+
+// doctorWorktreeLayout reports linked worktrees under the worktree root that
+// are not where worktree_path_template puts them, such as after the template
+// changed, and with fix moves them there. Worktrees elsewhere were placed by
+// hand and are left alone, as are layouts with {date}, since a worktree's
+// creation date is not known.
+func (m *Manager) doctorWorktreeLayout(report *DoctorReport, repoRoot string, items []Worktree, fix bool) {
+	if strings.Contains(m.Cfg.WorktreePathTemplate, "{date}") {
+		return
+	}
+	root := m.WorktreeRootDir(repoRoot)
+	for i, wt := range items {
+		if i == 0 || wt.Branch == "" || !strings.HasPrefix(absPath(wt.Path), root+string(filepath.Separator)) {
+			continue
+		}
+		if !m.dirExists(wt.Path) || m.worktreeFollowsLayout(repoRoot, wt.Branch, wt.Path) {
+			continue
+		}
+		target := m.worktreePath(repoRoot, wt.Branch)
+		item := DoctorItem{Check: "layout", Status: doctorWarn, Message: fmt.Sprintf("worktree %s is not at its worktree_path_template path %s", wt.Path, target)}
+		if wt.Locked {
+			item.Message += " (locked; unlock it to move)"
+			report.add(item)
+			continue
+		}
+		item.applyFix(fix, func() error {
+			return m.moveWorktreeDir(repoRoot, &items[i], target)
+		})
+		report.add(item)
+	}
+}
+
+// moveWorktreeDir moves a worktree's directory to target and repairs git's
+// pointers to it. A tmux session would be left in the old directory, so the
+// worktree must not have one.
+func (m *Manager) moveWorktreeDir(repoRoot string, wt *Worktree, target string) error {
+	if m.commandExists("tmux") && m.tmuxWorktreeRunning(m.tmuxWorktreeSessionName(repoRoot, wt), wt.Path) {
+		return fmt.Errorf("stop its tmux session first (sprout detach %s)", wt.Branch)
+	}
+	if exists, err := m.pathExists(target); err != nil {
+		return err
+	} else if exists {
+		return fmt.Errorf("target path already exists: %s", target)
+	}
+	if err := m.mkdirAll(filepath.Dir(target)); err != nil {
+		return err
+	}
+	if err := m.renamePath(wt.Path, target); err != nil {
+		return fmt.Errorf("move %s: %w", wt.Path, err)
+	}
+	if err := m.repairWorktreeGitdir(repoRoot, target); err != nil {
+		return fmt.Errorf("worktree moved to %s but metadata repair failed (run git worktree repair): %w", target, err)
+	}
+	m.removeEmptyParents(filepath.Dir(wt.Path), m.WorktreeRootDir(repoRoot))
+	infoLogf("worktree_layout moved path=%q new_path=%q", wt.Path, target)
+	wt.Path = target
+	return nil
+}
@@ -28,6 +28,30 @@ Complete reference for all Sprout CLI commands.
 
 Sprout provides a comprehensive set of commands for managing git worktrees. You can either use the interactive TUI or individual commands for scripting and automation.
 
+## Machine-readable output
+
+Every command accepts the global {{ backtick }}--output json{{ backtick }} flag (or {{ backtick }}SPROUT_OUTPUT=json{{ backtick }}). Instead of styled text, the command writes a single JSON object to stdout:
+
+{{ backtick }}{{ backtick }}{{ backtick }}json
+{
+  "command": "rm",
+  "ok": true,
+  "result": { "path": "/home/me/src/app.worktrees/feat/login" },
+  "warnings": [],
+  "duration_ms": 412
+}
+{{ backtick }}{{ backtick }}{{ backtick }}
+
+Failures set {{ backtick }}ok{{ backtick }} to {{ backtick }}false{{ backtick }}, fill {{ backtick }}error{{ backtick }}, and exit with status 1. When {{ backtick }}sprout new{{ backtick }} rejects its arguments, {{ backtick }}fields{{ backtick }} lists each problem as {{ backtick }}{"field", "code", "message"}{{ backtick }}: fields are {{ backtick }}type{{ backtick }}, {{ backtick }}name{{ backtick }}, {{ backtick }}branch{{ backtick }}, {{ backtick }}base{{ backtick }}, and {{ backtick }}path{{ backtick }}; codes are {{ backtick }}required{{ backtick }}, {{ backtick }}invalid_type{{ backtick }}, {{ backtick }}empty_slug{{ backtick }}, {{ backtick }}invalid_branch{{ backtick }}, {{ backtick }}branch_exists{{ backtick }}, {{ backtick }}branch_not_found{{ backtick }}, {{ backtick }}base_not_found{{ backtick }}, and {{ backtick }}path_exists{{ backtick }}. Interactive confirmations are never shown in JSON mode; pass the corresponding flag (for example {{ backtick }}rm --yes{{ backtick }}) instead.
+
+## Concurrent operations
+
+Commands that create, move, or remove worktrees, or start tmux sessions and agents, take a lock on the repository ({{ backtick }}sprout.lock{{ backtick }} in its git directory) while they do, so two of them, such as {{ backtick }}sprout new{{ backtick }} in two terminals or the TUI and a script, cannot race each other. A command that finds another one running fails with "another sprout operation is in progress in this repository" and the process holding the lock. Pass the global {{ backtick }}--wait{{ backtick }} flag to wait for it instead. Attaching to a session does not hold the lock.
+
+## Naming worktrees
+
+Commands that take a {{ backtick }}<branch-or-worktree>{{ backtick }} accept its branch, its path, or its directory name. A target that names none of them exactly may be shortened: a prefix of one worktree's branch or directory name ({{ backtick }}sprout go feat/check{{ backtick }}), or letters of its branch in order ({{ backtick }}sprout go chkout{{ backtick }} for {{ backtick }}feat/checkout-redesign{{ backtick }}). Sprout prints which worktree it picked. A partial target that matches several worktrees is refused with the candidates listed, and {{ backtick }}sprout rm{{ backtick }} asks before removing a worktree it only guessed (pass {{ backtick }}--yes{{ backtick }} to skip the question). Leave the target out on an interactive terminal ({{ backtick }}sprout go{{ backtick }}, {{ backtick }}sprout rm{{ backtick }}, {{ backtick }}sprout agent attach{{ backtick }}) to choose the worktree in a picker instead of the full TUI: type to filter, arrows to move, Enter to choose, Esc to cancel. {{ backtick }}--pick{{ backtick }} opens the picker too, using a given target as its filter, and fails when there is no terminal; scripts and {{ backtick }}--output json{{ backtick }} still get a usage error for a missing target.
+
 {{ range .Commands }}
 ## {{ .Name }}
 
@@ -55,7 +79,7 @@ func main() {
 	commands := []Command{}
 
 	// Parse help text for each command
-	for _, cmd := range []string{"ui", "new", "list", "go", "path", "launch", "detach", "agent", "rm", "doctor", "shell-hook"} {
+	for _, cmd := range []string{"ui", "new", "plan", "list", "go", "path", "launch", "popup", "detach", "agent", "rm", "undo", "fetch", "mv", "lock", "unlock", "priority", "label", "rebase", "merge", "pick", "share", "export", "exec", "check", "sessions", "shutdown", "resume", "events", "status", "watch", "statusline", "stats", "perf", "mcp", "serve", "config", "doctor", "shell-hook", "version"} {
 		helpText, usage, description := getCommandHelp(sproutBinary, cmd)
 		commands = append(commands, Command{
 			Name:        cmd,
@@ -117,57 +141,139 @@ func getCommandHelp(binary, cmd string) (helpText, usage, description string) {
 	// Special handling for different commands
 	switch cmd {
 	case "ui":
-		usage = "sprout ui"
+		usage = "sprout ui [--on-quit <action>]"
 		description = "Launch the interactive TUI for managing worktrees."
-		helpText = "The UI command launches an interactive terminal user interface where you can:\n- View all worktrees\n- Create new worktrees\n- Launch tmux sessions\n- Start/stop AI agents\n- Remove worktrees\n\nPrimary Hotkeys:\n- Enter / g : Attach to worktree session\n- d         : Detach from session\n- x         : Remove worktree (confirmation modal)\n- n         : Create new worktree\n- /         : Filter worktree list\n- r         : Refresh state\n- ?         : Open contextual help\n- q         : Quit"
+		helpText = "The UI command launches an interactive terminal user interface where you can:\n- View all worktrees\n- Create new worktrees\n- Launch tmux sessions\n- Start/stop AI agents\n- Remove worktrees\n- Compare each worktree with HEAD, the merge-base, or the checkpoint taken when a prompt was last sent to its agent (GIT DIFF tab); patches are rendered by delta when it is installed, and otherwise colored with the changed words of each edited line emphasized\n- Review the commits on each branch since the base branch and open their patches (LOG tab)\n- Review TODO/FIXME markers added on each branch (TODO column and TODOS tab)\n- See how long each agent has been busy or waiting for input (AGENT column and status pane), with a footer warning past agent_idle_minutes\n- Track the tokens and cost agents report (COST column and status pane; see sprout status)\n- See which branches last passed check_command (CHECK column; see sprout check)\n- Spot branches that would conflict when merged into the base branch (MERGE column and status pane; r re-checks)\n- Summarize Go functions and types changed on each branch (SYMBOLS tab)\n- Compare the last 24h of commits and agent output across sibling repos (repo picker heatmap)\n- See a startup banner for common misconfigurations (unwritable worktree root, missing tools or agent command, missing base branch); esc dismisses it\n\nPrimary Hotkeys:\n- Enter / g : Attach to worktree session; with several windows, pick the one to land on (1-9 or j/k and Enter)\n- 1-9       : Attach straight to the n-th window of the worktree's session, launching it if needed\n- d         : Detach from session\n- x         : Remove worktree, or with d also its branch; the modal shows uncommitted files, unpushed commits, stashes, and the remote branch, and asks for the branch name when work would be lost\n- u         : Restore the last removed worktree within undo_minutes\n- m         : Rename worktree and branch\n- l         : Lock/unlock worktree\n- P         : Cycle priority (normal, high, low)\n- b         : Interactive rebase onto base branch\n- M         : Merge or squash-merge into the base branch, optionally removing the worktree and branch\n- n         : Create new worktree (the branch picker fuzzy-matches as you type)\n- p         : Send prompt to agent (up/down recalls history)\n- L         : Tail debug log (e/i/d/t filter by level)\n- R         : Toggle CPU/MEM column\n- A         : Resume agents that stopped with the tmux server\n- F         : Fetch the repository in the background, then refresh; auto_fetch_minutes does it on a schedule\n- Enter     : Switch repo, with activity heatmap (status pane)\n- S         : Show or hide the repo sidebar: sibling repos with worktree and ready-agent counts; Enter switches in place, keeping each repo's filter and selection (saved as repo_sidebar)\n- s         : Sessions and orphan cleanup (status pane)\n- b         : Choose the diff base: working tree, HEAD, merge-base, last checkpoint, or any ref (diff tab)\n- o         : Cycle worktree order: path, most recently active, least recently active\n- /         : Fuzzy-filter worktrees by branch, best match first; label:<name>, status:<status>, agent:<state>, and priority:<level> keep the worktrees that match; remembered per repo\n- f         : Cycle through the filter presets of the [filters] config table, then no filter\n- y / Y     : Copy the worktree path / branch name to the clipboard (OSC 52 over SSH)\n- y         : Copy the selected file's patch (diff tab)\n- c         : Copy the selected file's changes into another worktree (diff tab)\n- v         : Preview the selected file instead of its patch: type, size, image dimensions, and the first lines of text files, for new or binary files (diff tab)\n- X         : Discard the uncommitted changes to the selected file after confirming: untracked files are deleted, others checked out from HEAD (diff tab, working tree base)\n- e / E     : Export the selected file's patch / the whole worktree diff to export_dir (diff tab)\n- Enter     : Show the selected commit's patch (log tab)\n- c         : Cherry-pick the selected commit onto another worktree (log tab)\n- ctrl+up/ctrl+down : Resize the Details and Worktrees panes (saved as details_percent)\n- z         : Zoom the focused pane; on the agent output tab, fill the terminal (esc restores)\n- e         : Export the agent transcript to export_dir (agent tab)\n- a         : Type into the agent's tmux pane while its output streams live (agent tab; ctrl+] stops)\n- R         : Restart the agent, optionally sending its last prompt again; crashed agents show as \"crashed\" (agent tab)\n- w         : Soft-wrap long lines of agent output and patches instead of clipping them; each tab remembers its setting (agent, diff, and log tabs)\n- W         : Preview the next pane of the worktree's tmux session (editor, lazygit, tools) in the agent tab; cycles back to the agent\n- r         : Refresh state in the background, dropping cached git queries (branch lists, ahead/behind counts); the rows stay visible with a spinner in the table counter until it is done\n- ?         : Open contextual help\n- q         : Quit (applies on_quit to running agents; --on-quit overrides it)\n\nMouse:\n- Click a pane to focus it, a worktree row, changed file, or commit to select it, or a detail tab to switch to it\n- Double-click a worktree row to attach\n- The wheel moves the worktree and file selections and scrolls the patch and agent output"
 	case "new":
-		usage = "sprout new <type> <name> [--from <base>] [--from-branch <branch>] [--no-launch]"
+		usage = "sprout new <type> <name> [--from <base>] [--from-branch <branch>] [--from-pr <number>] [--no-launch] [--priority <level>] [--yes]"
 		description = "Create a new worktree."
 		helpText = `Creates a new git worktree and branch.
 
 Arguments:
-  <type>  Branch type prefix (e.g., feat, fix, chore)
+  <type>  Branch type, one of branch_types (default: feat, fix, chore, docs, refactor, test)
   <name>  Branch name (spaces allowed)
 
+The branch is built from branch_template, {type}/{slug} by default, and
+must match branch_pattern when one is set.
+
 Flags:
   --from <base>           Base branch to create from (default: config.base_branch)
-  --from-branch <branch>  Existing local or remote branch to create worktree from
+  --from-branch <branch>  Existing local or remote branch to create worktree from;
+                          a remote-only branch is fetched and checked out as
+                          a local branch tracking it
+  --from-pr <number>      GitHub pull request to fetch from origin and create worktree from
   --no-launch             Don't auto-launch tmux session
+  --priority <level>      Priority of the new worktree: high, normal, or low
+  --yes                   Create even when the wip_limit is reached
+
+When wip_limit is set and already reached, sprout lists the worktrees to
+finish or prune first (lowest priority, clean first) and asks to confirm.
+
+Before creating anything, sprout checks the whole request and reports every
+problem at once: an unknown type, a name with no usable characters, a branch
+that does not match branch_pattern or already exists, a missing --from base,
+or a worktree path that is taken.
+If the branch already has a worktree, that worktree is returned instead.
+
+--from-pr fetches the pull request from origin. When gh is installed and
+the pull request comes from a branch of origin, that branch is checked out;
+otherwise the worktree gets a pr/<number> branch tracking
+refs/pull/<number>/head, so git pull picks up new pushes. The pull request
+number is recorded on the branch and shown next to it in sprout list and the
+TUI.
 
 Examples:
   sprout new feat checkout-redesign
+  sprout new fix outage --priority high
   sprout new fix urgent-bug --from main
-  sprout new --from-branch feat/existing-branch`
+  sprout new --from-branch feat/existing-branch
+  sprout new --from-pr 42`
+	case "plan":
+		usage = "sprout plan <file> [--type <type>] [--from <base>] [--agent] [--agent-type <type>] [--dry-run] [--yes]"
+		description = "Create a worktree per task of a markdown or YAML task list."
+		helpText = `Reads a task list and creates one worktree per task, with the branch named
+after the task title the way sprout new names it. The task text is recorded
+on the branch and shown in the TUI status pane and sprout list --json.
+
+Markdown files list one task per top-level list item (-, *, + or 1.); the
+item's indented lines are added to the task text, and checked items (- [x])
+are skipped as done. Headings and paragraphs are ignored:
+
+  - [ ] Add a login page
+    Reuse the form components.
+  - [ ] Fix the flaky checkout test
+
+YAML files (.yaml, .yml) hold a list, optionally under a tasks key, of
+titles or of mappings with title, prompt (the task text), and type:
+
+  tasks:
+    - Add a login page
+    - title: Fix the flaky checkout test
+      type: fix
+      prompt: |
+        TestCheckout fails one run in ten on CI.
+
+With --agent, or auto_start_agent, an agent is started in each new worktree
+and sent the task text as its first prompt once it is ready for input.
+Tasks whose worktree already exists are left alone, so a plan can be run
+again after adding tasks.
+
+Arguments:
+  <file>  Task list file
+
+Flags:
+  --type <type>        Branch type of tasks that do not set one (default: the first of branch_types)
+  --from <base>        Base branch to create from (default: config.base_branch)
+  --agent              Start an agent per worktree and send it the task (default: auto_start_agent)
+  --agent-type <type>  Agent type to start (default: default_agent_type)
+  --dry-run            List the tasks and their branches without creating anything
+  --yes                Create past the wip_limit without asking
+
+Examples:
+  sprout plan TODO.md --dry-run
+  sprout plan sprint.yaml --agent`
 	case "list":
-		usage = "sprout list [--json]"
+		usage = "sprout list [--json] [--sort path|active|idle] [--label <label>] [--filter <preset>]"
 		description = "List all worktrees with their status."
 		helpText = `Lists all git worktrees with their current status.
 
 Flags:
   --json  Output as JSON
+  --sort    Order by path, active (most recently active first), or idle (least recently active first); defaults to the sort setting
+  --label   Only worktrees with this label; repeat it or separate labels with commas to require several
+  --filter  Only worktrees matching a filter preset from the [filters] config table, such as dirty or
+            agent-ready, or a filter written like the TUI's (label:x status:dirty agent:ready priority:high)
 
 Output columns:
   CUR     - * if current worktree
   BRANCH  - Branch name
-  STATUS  - clean or dirty
+  PRI     - Priority (high, normal, or low)
+  LABELS  - Labels set with sprout label
+  STATUS  - clean, dirty, or rebase state (rebasing, rebased, rebase aborted); a merge,
+            cherry-pick, or revert stopped midway shows as dirty
   TMUX    - Tmux session state (active, inactive, or -)
   AGENT   - AI agent state (active, inactive, or -)
+  LOCK    - locked if the worktree is locked
   PATH    - Worktree path`
 	case "go":
-		usage = "sprout go <branch-or-worktree> [--attach] [--no-launch]"
+		usage = "sprout go <branch-or-worktree> [--attach] [--no-launch] [--pick]"
 		description = "Switch to a worktree (optionally launching or attaching to tmux)."
 		helpText = `Navigate to a worktree and optionally manage tmux session.
 
 Arguments:
   <branch-or-worktree>  Branch name or worktree path
+  [text]                send: text to type; standard input when omitted or "-"
 
 Flags:
   --attach      Attach to existing tmux session if running
   --no-launch   Don't launch tmux session if not running
+  --pick        Choose the worktree in an interactive picker
 
 Examples:
   sprout go feat/checkout-redesign
-  sprout go main --attach`
+  sprout go chkout
+  sprout go main --attach
+  sprout go --pick`
 	case "path":
 		usage = "sprout path <branch-or-worktree>"
 		description = "Print the absolute path to a worktree."
@@ -180,7 +286,7 @@ Examples:
   cd $(sprout path feat/checkout)
   code $(sprout path main)`
 	case "launch":
-		usage = "sprout launch <branch-or-worktree> [--no-attach]"
+		usage = "sprout launch <branch-or-worktree> [--no-attach] [--dry-run]"
 		description = "Launch a tmux session for a worktree."
 		helpText = `Creates and optionally attaches to a tmux session for a worktree.
 
@@ -189,11 +295,31 @@ Arguments:
 
 Flags:
   --no-attach  Launch session without attaching
+  --dry-run    Print the tmux commands that would create the session (new
+               sessions and windows, splits, layouts) without running them
 
 The tmux session includes:
 - Neovim (if launch_nvim is enabled)
 - Lazygit (if launch_lazygit is enabled)
-- Shell in worktree directory`
+- Shell in worktree directory
+
+--dry-run shows what a new session would run even when the session exists,
+so [[windows]] changes can be tried without killing and relaunching it:
+
+  sprout launch feat/checkout --dry-run`
+	case "popup":
+		usage = "sprout popup"
+		description = "Pick a worktree and switch tmux to its session."
+		helpText = `Opens the worktree picker (type to filter, arrows to move, enter to choose,
+esc to close) and switches the tmux client to the chosen worktree's session,
+launching it first when it is not running. It is made to run in a tmux popup,
+so a key jumps between worktrees from any window.
+
+Bind it in ~/.tmux.conf; -d runs it in the current pane's repository:
+
+  bind-key S display-popup -E -w 60% -h 50% -d "#{pane_current_path}" "sprout popup"
+
+Outside tmux it fails; use sprout go or sprout launch there.`
 	case "detach":
 		usage = "sprout detach <branch-or-worktree>"
 		description = "Detach from and kill the tmux session for a worktree."
@@ -204,30 +330,87 @@ Arguments:
 
 Note: This does not remove the worktree itself, only stops the tmux session.`
 	case "agent":
-		usage = "sprout agent <start|stop|attach> <branch-or-worktree>"
+		usage = "sprout agent <start|stop|restart|attach|send|output|history> <branch-or-worktree> [text] [--type <agent>] [--prompt <text>] [--prompt-timeout <duration>] [--replay] [--attach] [--enter] [--raw-keys] [--lines <n>] [--follow] [--strip-ansi]"
 		description = "Manage AI coding agents for a worktree."
-		helpText = `Start, stop, or attach to AI coding agents.
+		helpText = `Start, stop, restart, or attach to AI coding agents, type into them, or read
+their output.
 
 Subcommands:
   start   - Start an agent in a new tmux window
   stop    - Stop the agent tmux window
+  restart - Kill the agent window and start the agent again
   attach  - Attach to running agent window
+  send    - Type text into the agent, from [text] or standard input
+  output  - Print the agent's latest output
+  history - List prompts previously sent to the agent from sprout
 
 Arguments:
   <branch-or-worktree>  Branch name or worktree path
 
+Flags:
+  --type <agent>                Start this configured agent type instead of the default
+  --prompt <text>               Send this prompt once the agent is ready for input
+  --prompt-timeout <duration>   How long to wait for the agent to be ready (default: 1m)
+  --replay                      restart: send the last prompt again once the agent is ready
+  --attach                      restart: attach to the new agent window
+  --enter                       send: press Enter after the text (default: true)
+  --raw-keys                    send: the text is tmux key names, such as C-c or Escape
+  --lines <n>                   output: number of lines to print, scrollback included (default: 50; 0 for the screen)
+  --follow, -f                  output: keep printing lines as the agent prints them
+  --strip-ansi                  output: drop colors and other escape sequences
+
+With --prompt, start and attach wait until the agent's pane shows it is ready
+for input, then type the prompt into it, retrying a send that fails. They
+fail if the agent exits or is not ready in time. In the TUI, the create
+modal takes the same first prompt (tab to its prompt field).
+
+restart works on running and crashed agents alike. It starts the agent type
+the agent was last started with unless --type is given. When an agent's
+command exits, its window stays open with the last output and sprout list
+and the TUI show the agent as "crashed"; press R on the Agent Output tab to
+restart it from the TUI.
+
+send types the text into the agent's pane and presses Enter, like a prompt
+from the TUI: it is checkpointed for the diff tab and kept in the prompt
+history. With --enter=false the text is typed but not submitted. With
+--raw-keys each word is a tmux key name, sent as is, so "C-c" interrupts the
+agent and "Escape" dismisses a dialog; Enter is only pressed when listed or
+--enter is given. One trailing newline of standard input is dropped.
+
+output prints what the agent window shows, with wrapped lines joined, so it
+can be piped into other tools or read over SSH without the TUI. --follow
+polls the agent's screen every second and prints lines as they appear; a
+status line the agent redraws in place is printed again each time it
+changes. It stops on ctrl+c or when the agent window closes. With
+--output json, output returns the lines in the result object, escape
+sequences stripped, and --follow prints one JSON string per line.
+
 Supported agents (via config):
   - codex   (default)
   - aider
   - claude
   - gemini
 
+If the agent command is not installed, start and attach fail with
+"agent command not found: <command>" instead of opening an empty window, list
+the configured agent types that are installed, and offer to start the first
+one. The TUI offers the same choice when a new worktree's agent cannot start.
+
 Examples:
   sprout agent start feat/new-feature
   sprout agent attach main
-  sprout agent stop feat/new-feature`
+  sprout agent start feat/new-feature --type claude
+  sprout agent start feat/new-feature --prompt "Add a health check endpoint"
+  sprout agent stop feat/new-feature
+  sprout agent restart feat/new-feature --replay
+  sprout agent send feat/new-feature "Run the tests and fix what fails"
+  echo "Summarize the last commit" | sprout agent send feat/new-feature
+  sprout agent send feat/new-feature C-c --raw-keys
+  sprout agent output feat/new-feature --lines 20 --strip-ansi
+  sprout agent output feat/new-feature -f | grep -i error
+  sprout agent history feat/new-feature`
 	case "rm":
-		usage = "sprout rm <branch-or-worktree> [--delete-branch] [--force]"
+		usage = "sprout rm <branch-or-worktree> [--delete-branch] [--force] [--yes] [--switch]"
 		description = "Remove a worktree (and optionally its branch)."
 		helpText = `Removes a git worktree and optionally deletes the branch.
 
@@ -236,38 +419,695 @@ Arguments:
 
 Flags:
   --delete-branch  Also delete the git branch
-  --force          Force removal even if worktree is dirty
+  --force          Force removal even if worktree is dirty or locked
+  --yes            Skip the confirmation prompt for locked worktrees and partial targets
+  --switch         Allow removing the worktree you are in by moving to the main worktree
+
+Locked worktrees are refused unless --force is given, and then only after
+confirming the prompt (or passing --yes).
+
+Removing the worktree you are in is refused, since it would leave your shell
+in a deleted directory, unless --switch is given. Through the shell hook (spr)
+it is allowed and your shell follows to the main worktree; without it sprout
+prints the directory to cd to. The TUI does the same and prints it on quit.
 
 Warning: This will stop any running tmux sessions and agents.
 
+For undo_minutes (10 by default) afterwards, sprout undo brings the worktree
+back with its uncommitted files.
+
 Examples:
   sprout rm feat/old-feature
   sprout rm fix/bug --delete-branch
   sprout rm dirty-worktree --force`
+	case "undo":
+		usage = "sprout undo"
+		description = "Restore the last removed worktree, with its uncommitted files."
+		helpText = `Recreates the worktree last removed from the current repository, if that
+was less than undo_minutes ago: at its old path, on its branch (recreated at
+its old commit if it was deleted), with its uncommitted and untracked files
+back as unstaged changes. Ignored files are not restored.
+
+Only the last removal of each repository can be undone. The TUI does the same
+with u.
+
+Examples:
+  sprout rm feat/checkout --force
+  sprout undo`
+	case "fetch":
+		usage = "sprout fetch"
+		description = "Fetch the repository once for all its worktrees."
+		helpText = `Runs git fetch --prune in the repository. Its worktrees share the
+repository's refs, so one fetch updates all of them; remote branches deleted
+upstream are pruned. Cached ahead/behind counts are dropped, and the TUI
+status pane shows how long ago sprout last fetched.
+
+The TUI fetches with F, and in the background every auto_fetch_minutes when
+that is set. Every fetch counts towards that schedule, whichever sprout ran
+it.
+
+Examples:
+  sprout fetch
+  sprout fetch --output json`
+	case "lock":
+		usage = "sprout lock <branch-or-worktree> [--reason <text>]"
+		description = "Lock a worktree to prevent accidental removal."
+		helpText = `Runs git worktree lock for the worktree. Locked worktrees show "locked" in
+the LOCK column, cannot be moved, and are only removed with rm --force after
+an explicit confirmation.
+
+Arguments:
+  <branch-or-worktree>  Branch name or worktree path
+
+Flags:
+  --reason <text>  Reason recorded with the lock
+
+Examples:
+  sprout lock feat/release --reason "release candidate"`
+	case "unlock":
+		usage = "sprout unlock <branch-or-worktree>"
+		description = "Unlock a locked worktree."
+		helpText = `Runs git worktree unlock for the worktree.
+
+Arguments:
+  <branch-or-worktree>  Branch name or worktree path
+
+Examples:
+  sprout unlock feat/release`
+	case "mv":
+		usage = "sprout mv <branch-or-worktree> <new-branch>"
+		description = "Rename a worktree's branch and move its directory to match."
+		helpText = `Renames the branch checked out in a worktree, moves the worktree directory
+under the worktree root to match the new branch, and repairs git's worktree
+metadata. A running tmux session and its windows are renamed as well.
+
+Arguments:
+  <branch-or-worktree>  Branch name or worktree path
+  <new-branch>          New branch name
+
+Note: panes in a running tmux session keep their old working directory until
+the session is relaunched.
+
+Examples:
+  sprout mv feat/checkout feat/checkout-redesign`
+	case "priority":
+		usage = "sprout priority <branch-or-worktree> [high|normal|low]"
+		description = "Show or set a worktree's priority."
+		helpText = `Prints the priority of a worktree, or sets it when a level is given. The
+priority is stored in the branch's git config (branch.<name>.sproutpriority),
+so it follows the branch when it is renamed.
+
+High-priority worktrees are marked with ↑ and shown in red in the TUI, low
+ones with ↓; background scans process high-priority worktrees first.
+
+Arguments:
+  <branch-or-worktree>  Branch name or worktree path
+  [level]               high, normal, or low
+
+Examples:
+  sprout priority feat/checkout high
+  sprout priority feat/checkout`
+	case "label":
+		usage = "sprout label <branch-or-worktree> [add|rm <label>...|clear]"
+		description = "Show, add, or remove a worktree's labels."
+		helpText = `Prints the labels of a worktree, one per line, or changes them. Labels are
+stored in the branch's git config (branch.<name>.sproutlabels), so they follow
+the branch when it is renamed. They are lowercase letters, digits, and . _ / -.
+
+Labels show in the LABELS column. sprout list --label <label> and label:<label>
+in the TUI filter keep only the worktrees with that label.
+
+Arguments:
+  <branch-or-worktree>  Branch name or worktree path
+  add <label>...        Add labels
+  rm <label>...         Remove labels
+  clear                 Remove every label
+
+Examples:
+  sprout label feat/checkout add experiment
+  sprout label feat/checkout rm experiment
+  sprout list --label experiment`
+	case "rebase":
+		usage = "sprout rebase <branch-or-worktree> [--onto <branch>] [--no-attach]"
+		description = "Open an interactive rebase for a worktree in a tmux window."
+		helpText = `Runs git rebase -i <base> for the worktree in a dedicated rebase-<branch>
+window of its tmux session, creating the session if needed. The base defaults
+to base_branch.
+
+While the rebase is open or stopped on a conflict the worktree status shows
+"rebasing"; once the window exits it shows "rebased" or "rebase aborted" until
+the next rebase is started.
+
+Arguments:
+  <branch-or-worktree>  Branch name or worktree path
+
+Flags:
+  --onto <branch>  Base branch to rebase onto
+  --no-attach      Do not attach to the rebase window
+
+Examples:
+  sprout rebase feat/checkout
+  sprout rebase feat/checkout --onto develop`
+	case "merge":
+		usage = "sprout merge <branch-or-worktree> [--into <branch>] [--squash] [-m <message>] [--remove] [--delete-branch] [--skip-checks] [--yes]"
+		description = "Merge a finished worktree's branch into the base branch."
+		helpText = `Checks that the branch is ready, shows what will happen, and merges it once
+confirmed. The checks:
+
+- the worktree has no uncommitted changes and no rebase in progress
+- the branch has commits the base branch lacks
+- merging would not conflict (a git merge-tree dry run, git 2.38 or newer)
+- the pull request checks, when gh can report them, have passed
+
+The merge happens in the worktree that has the base branch checked out, which
+must be clean, or in a temporary worktree when the base branch is not checked
+out anywhere. It records a merge commit, or with --squash a single commit
+whose message defaults to the commit subject when the branch has one commit
+and to the branch name otherwise. If the merge fails the base branch is reset
+to where it was.
+
+Afterwards --remove removes the worktree and --delete-branch also deletes the
+branch. In the TUI, M opens the same flow for the selected worktree.
+
+Arguments:
+  <branch-or-worktree>  Branch name or worktree path
+
+Flags:
+  --into <branch>      Branch to merge into (default: base_branch)
+  --squash             Squash the branch into a single commit
+  -m, --message <msg>  Commit message
+  --remove             Remove the worktree after merging
+  --delete-branch      Remove the worktree and delete its branch after merging
+  --skip-checks        Merge even when pull request checks are failing or pending
+  --yes                Skip the confirmation prompt (required with --json)
+
+Examples:
+  sprout merge feat/checkout
+  sprout merge feat/checkout --squash --delete-branch`
+	case "pick":
+		usage = "sprout pick <src> <dst> [--files <file,...>] [--since <rev>] [--commit <sha,...>]"
+		description = "Copy changed files or commits from one worktree into another."
+		helpText = `Applies changes from the <src> worktree to the <dst> worktree, either per file
+or per commit.
+
+With --files, the changes each file has in <src> since --since (default: where
+<src> forked from base_branch, so committed and uncommitted changes both come
+along) are applied to <dst>'s working tree with git apply. A patch that does
+not apply cleanly is retried as a three-way merge, which can leave conflict
+markers to resolve in <dst>.
+
+With --commit, the commits are cherry-picked onto <dst>'s branch in order.
+<dst> must be on a branch and clean. A cherry-pick that conflicts is aborted,
+leaving <dst> as it was.
+
+In the TUI, c copies the selected file on the GIT DIFF tab (against the
+current diff base) or cherry-picks the selected commit on the LOG tab into a
+worktree picked from a list.
+
+Arguments:
+  <src>  Branch name or worktree path to copy from
+  <dst>  Branch name or worktree path to copy into
+
+Flags:
+  --files <file,...>  Changed files of <src> to apply to <dst>
+  --since <rev>       Revision the files are compared with (default: where <src> forked from base_branch)
+  --commit <sha,...>  Commits to cherry-pick onto <dst>
+
+Examples:
+  sprout pick feat/api feat/web --files internal/api/types.go
+  sprout pick feat/api feat/web --commit 3f2c1ab`
+	case "share":
+		usage = "sprout share <branch-or-worktree> [--lan] [--addr <host:port>] [--agent] [--for <duration>]"
+		description = "Serve a read-only web view of a worktree diff."
+		helpText = `Starts a temporary local HTTP server that renders the worktree's branch diff
+(everything changed since it forked from base_branch, including uncommitted
+and untracked files) as a static, syntax-highlighted HTML page. The page is
+re-rendered on every reload. Only GET requests are served.
+
+Arguments:
+  <branch-or-worktree>  Branch name or worktree path
+
+Flags:
+  --lan                Listen on all interfaces and print LAN URLs
+  --addr <host:port>   Listen address (default: 127.0.0.1 on a random port)
+  --agent              Include the agent transcript below the diff
+  --for <duration>     Stop serving after this long (default: until Ctrl-C)
+
+Examples:
+  sprout share feat/checkout
+  sprout share feat/checkout --lan --agent --for 30m`
+	case "export":
+		usage = "sprout export <branch-or-worktree> [--format patch|html|markdown] [-o <file>]"
+		description = "Export a worktree diff as a patch, HTML page, or Markdown review."
+		helpText = `Exports the worktree's branch diff (everything changed since it forked from
+base_branch, including uncommitted and untracked files) for sharing outside
+git hosting.
+
+Formats:
+  patch     Plain unified diff, applicable with git apply (default)
+  html      Standalone, syntax-highlighted HTML page
+  markdown  Review document with a file list and per-file patches
+
+Arguments:
+  <branch-or-worktree>  Branch name or worktree path
+
+Flags:
+  --format <format>  patch, html, or markdown
+  -o, --out <file>   Output file, or - for stdout (default: <branch>.<ext>)
+
+Examples:
+  sprout export feat/checkout
+  sprout export feat/checkout --format markdown -o review.md
+  sprout export feat/checkout -o - | pbcopy`
+	case "exec":
+		usage = "sprout exec <branch-or-worktree|--all> [--parallel <n>] -- <command> [args...]"
+		description = "Run a command in one or every worktree."
+		helpText = `Runs a command in a worktree, or with --all in every worktree of the
+repository, and reports its exit code in each. A single quoted argument runs
+as a shell script, so pipes and && work.
+
+With one worktree the output is passed through and sprout exits with the
+command's exit code. With --all each line is prefixed with the worktree's
+branch, a summary of exit codes and durations follows, and sprout exits 1 when
+the command failed anywhere. Worktrees whose directory is missing are skipped.
+
+Arguments:
+  <branch-or-worktree>  Branch name or worktree path
+
+Flags:
+  --all           Run in every worktree of the repository
+  --parallel <n>  Number of worktrees to run in at once (default: 1)
+  --pick          Choose the worktree in an interactive picker
+
+Examples:
+  sprout exec feat/checkout -- npm test
+  sprout exec --all -- go test ./...
+  sprout exec --all --parallel 4 -- 'git fetch && git status -sb'`
+	case "check":
+		usage = "sprout check <branch-or-worktree|--all> [--parallel <n>]"
+		description = "Run check_command in one or every worktree and record the result."
+		helpText = `Runs the repository's check_command, its tests or linters, in a worktree, or
+with --all in every worktree, like sprout exec. Whether it passed, when, how
+long it took, and the commit it ran against are stored with the branch, and
+the CHECK column of sprout list and the TUI shows pass or fail, with a * once
+the branch has new commits.
+
+sprout check exits 1 when the check failed in any worktree.
+
+Arguments:
+  <branch-or-worktree>  Branch name or worktree path
+
+Flags:
+  --all           Check every worktree of the repository
+  --parallel <n>  Number of worktrees to check at once (default: 1)
+  --pick          Choose the worktree in an interactive picker
+
+Examples:
+  sprout check feat/checkout
+  sprout check --all --parallel 4`
+	case "sessions":
+		usage = "sprout sessions [list|kill-orphans]"
+		description = "List sprout tmux sessions and clean up orphaned ones."
+		helpText = `Enumerates every tmux session named with session_prefix and maps it back to
+the worktree it belongs to.
+
+A session is orphaned when its directory no longer exists, or when it is named
+for the current repository but matches none of its worktrees.
+
+Actions:
+  list          Show sessions with their window count, attach state, and worktree (default)
+  kill-orphans  Kill every orphaned session
+
+In the TUI, focus the status pane and press s to review sessions and kill the
+orphaned ones.
+
+Examples:
+  sprout sessions
+  sprout sessions kill-orphans`
+	case "shutdown":
+		usage = "sprout shutdown [--detach] [--yes]"
+		description = "Stop all running agents, optionally detaching their sessions."
+		helpText = `Stops the agent of every worktree in the current repository so none are left
+running overnight. It lists what will be stopped and asks for confirmation
+first.
+
+Flags:
+  --detach  Also kill the tmux session of every worktree
+  --yes     Skip the confirmation prompt
+
+Set on_quit to do the same whenever you quit the TUI.
+
+Examples:
+  sprout shutdown
+  sprout shutdown --detach --yes`
+	case "resume":
+		usage = "sprout resume"
+		description = "Start again the agents that stopped when the tmux server went away."
+		helpText = `sprout remembers which worktrees had an agent it started, and with which agent
+type. After the tmux server restarts, resume starts each of those agents again
+in the current repository with the same agent type. When agent_resume_prompt
+is set, each resumed agent is sent that prompt once it is ready for input.
+
+Agents that were stopped on purpose (agent stop, detach, rm) are not resumed.
+In the TUI, press A to do the same.
+
+Examples:
+  sprout resume
+  sprout resume --output json`
+	case "events":
+		usage = "sprout events [--follow] [--since <duration|time>] [--type <types>] [--all]"
+		description = "Print sprout events as JSON lines, optionally following new ones."
+		helpText = `sprout appends an event to ~/.config/sprout/events.jsonl whenever it creates or
+removes a worktree, launches a tmux session, or starts or stops an agent. While
+the TUI is open it also records when an agent becomes ready for input, exits,
+or has waited for input longer than agent_idle_minutes. This command prints them as one JSON object per line, so scripts can react to
+sprout activity.
+
+Once the log reaches 4 MB it is moved to events.jsonl.1, replacing the one
+before, and a new log is started. sprout events and sprout stats read both.
+
+Event types:
+  worktree_created, worktree_removed, session_launched,
+  agent_started, agent_stopped, agent_ready, agent_exited, agent_idle
+
+Each event has type, time, repo, repo_root, and, where they apply, branch,
+path, session, and agent_type. worktree_removed also has outcome: merged or
+abandoned, depending on whether the branch had been merged into base_branch.
+
+Flags:
+  -f, --follow  Keep printing events as they happen, from any sprout process
+  --since       Only events since this long ago (e.g. 1h or 7d) or this RFC 3339 time;
+                with --follow, print those before following
+  --type        Only events of these types (comma-separated)
+  --all         Include events from every repository, not just the current one
+
+Without --follow, --output json wraps the events in the usual result object.
+
+Examples:
+  sprout events --since 24h
+  sprout events --follow --type agent_ready,agent_exited
+  sprout events -f | jq -r 'select(.type == "worktree_created") | .path'`
+	case "status":
+		usage = "sprout status"
+		description = "Summarize agents and their token and cost usage per worktree."
+		helpText = `Lists the worktrees of the current repository with their agent state and the
+tokens and cost their agents used, with a total row.
+
+Usage is read from what agents print, so it is an estimate and agents that
+never print usage count nothing:
+
+- Codex: "N% context left", "12.3K tokens used" and "Token usage: total=N"
+- aider: "$X session" in its cost lines
+- Claude Code: "Total cost: $X" from /cost
+
+Running agents are sampled when the command runs, and the TUI samples them
+while it is open. An agent that restarts starts a new session; earlier
+sessions of a worktree stay counted. Usage is kept in
+~/.config/sprout/usage.json and dropped when the worktree is removed.
+
+Examples:
+  sprout status
+  sprout status --output json`
+	case "watch":
+		usage = "sprout watch [--interval <duration>] [--file <path>] [--prometheus <path>] [--once]"
+		description = "Keep a status file of worktrees and agents up to date for status bars."
+		helpText = `Runs in the foreground without the TUI, refreshing the worktrees and agents of
+the current repository every --interval and writing them to a JSON status
+file. Status bars such as tmux, starship, or waybar can read the file to show
+how many agents are ready without running git or tmux themselves. No daemon
+is involved: start it in a tmux window, a systemd user service, or with &.
+
+The status file holds every watched repository, keyed by its main worktree,
+so one sprout watch per repository can share it:
+
+  {"repos": {"/src/app": {"repo": "app", "updated_at": "...",
+    "interval_seconds": 30, "worktrees": 3, "dirty": 1,
+    "agents": {"busy": 1, "ready": 1, "crashed": 0},
+    "usage": {...}, "items": [{"branch": "feat/x", "path": "...",
+    "dirty": true, "agent": "ready"}]}}}
+
+An item's agent is busy, ready, crashed, or none. The file is replaced
+atomically, so readers never see it half-written. A refresh that fails is
+logged and retried on the next tick.
+
+Flags:
+  --interval <duration>  How often to refresh (default: 30s)
+  --file <path>          Status file (default: ~/.config/sprout/status.json)
+  --prometheus <path>    Also write Prometheus text metrics (sprout_worktrees,
+                         sprout_worktrees_dirty, sprout_agents{state},
+                         sprout_agent_tokens, sprout_agent_cost_usd,
+                         sprout_status_updated_seconds) for the node_exporter
+                         textfile collector
+  --once                 Write the status once and exit
+
+Examples:
+  sprout watch
+  sprout watch --interval 10s --prometheus ~/metrics/sprout-app.prom
+  sprout watch --once && jq '.repos[].agents' ~/.config/sprout/status.json
+
+sprout statusline turns the status file into a one-line summary for tmux or
+starship.`
+	case "statusline":
+		usage = "sprout statusline [--format plain|tmux|starship] [--dir <path>] [--file <path>]"
+		description = "Print a one-line worktree and agent summary for status bars."
+		helpText = `Prints a compact summary such as "3 wt · 2 agents ready · 1 dirty" from the
+status file sprout watch keeps. It reads only that file, without running git
+or tmux, so it takes a few milliseconds and can run on every status bar
+redraw or prompt.
+
+The summary is for the watched repository that --dir (default: the working
+directory) is in, matched by the paths of its worktrees; outside all of them
+it adds up every watched repository. Counts of zero are left out. When the
+watcher has missed three refreshes, such as after it stopped, the line ends
+with "stale". Without a status file the output is empty.
+
+Formats:
+  plain     Plain text (default)
+  tmux      Colored with tmux #[fg=...] markup: ready green, busy yellow,
+            crashed red
+  starship  Plain text, and empty instead of stale, so the module hides
+
+Flags:
+  --format <format>  plain, tmux, or starship
+  --dir <path>       Directory whose repository to summarize
+  --file <path>      Status file to read (default: ~/.config/sprout/status.json)
+
+Examples:
+  # ~/.tmux.conf (tmux expands #{pane_current_path} before running it)
+  set -g status-right '#(sprout statusline --format tmux --dir "#{pane_current_path}")'
+  set -g status-interval 5
+
+  # ~/.config/starship.toml
+  [custom.sprout]
+  command = "sprout statusline --format starship"
+  when = true
+  format = "[🌱 $output]($style) "`
+	case "stats":
+		usage = "sprout stats [--since <duration|time>] [--all]"
+		description = "Summarize worktree and agent activity from the event log."
+		helpText = `Summarizes the event log (see sprout events) for the current repository, or
+for every repository with --all:
+
+- Worktrees created and removed, in total and per week
+- Removed worktrees whose branch was merged into base_branch versus abandoned;
+  rebase and squash merges count as merged
+- Average worktree lifetime, from creation to removal
+- Agent time: how long agents ran, from start until they were stopped, exited,
+  or their worktree was removed
+
+Only activity since the event log was introduced is counted. Agents are seen
+exiting only while the TUI is open, so an agent that exits unattended keeps
+counting until it is stopped.
+
+Flags:
+  --since  Only count activity since this long ago (e.g. 30d) or this RFC 3339 time
+  --all    Summarize every repository, not just the current one
+
+Examples:
+  sprout stats
+  sprout stats --since 30d
+  sprout stats --all --output json`
+	case "perf":
+		usage = "sprout perf [--limit <n>] [--repo] [--reset]"
+		description = "Report how long git and tmux commands and sprout operations take."
+		helpText = `Every git and tmux command sprout runs, and operations such as listing
+worktrees, launching sessions, and refreshing the TUI, are timed. When a sprout
+process exits, its timings are added to ~/.config/sprout/perf.json, and
+sprout perf reports them:
+
+- Commands, by program and subcommand (git status, tmux list-panes), with their
+  runs, total, average, 90th percentile, and longest time
+- Operations, the same way
+- The slowest single commands of the last week that took 100ms or more, with
+  the directory they ran in
+
+Commands are ordered by the total time spent, so the ones to look at when the
+TUI is slow to refresh in a repository come first. Percentiles are estimated
+from histogram buckets.
+
+Flags:
+  --limit  Show at most this many commands and slow invocations (0 for all)
+  --repo   Only list slow invocations run in the current repository's worktrees
+  --reset  Forget the timings recorded so far
+
+Examples:
+  sprout perf
+  sprout perf --repo --limit 30
+  sprout perf --reset`
+	case "mcp":
+		usage = "sprout mcp"
+		description = "Serve worktree and agent operations as Model Context Protocol tools over stdio."
+		helpText = `Runs a Model Context Protocol (MCP) server on stdin and stdout, so an
+orchestrating model can drive sprout: create worktrees, start agents in them,
+hand out prompts, and review what they did. It works on the repository it is
+started in.
+
+Tools:
+  list_worktrees     Worktrees with their branch, path, dirty state, session, and agent state
+  create_worktree    Create a worktree (branch, or type and name, from_branch, or from_pr);
+                     start_agent also starts its agent
+  remove_worktree    Remove a worktree (force, delete_branch)
+  start_agent        Start a worktree's agent (agent_type)
+  stop_agent         Stop a worktree's agent
+  send_agent_prompt  Send a prompt to a worktree's agent
+  get_agent_output   Recent agent output and whether it is busy, ready, or exited
+  get_diff           The branch's changes since base, or only uncommitted ones
+
+Worktrees are addressed by branch or path, as on the command line. Errors are
+returned to the model as tool errors; details go to the debug log.
+
+Register it with an MCP client by running it in the repository, for example in
+a project's .mcp.json:
+
+  {"mcpServers": {"sprout": {"command": "sprout", "args": ["mcp"]}}}
+
+Examples:
+  sprout mcp`
+	case "serve":
+		usage = "sprout serve [--listen <addr>] [--token <token>]"
+		description = "Serve a JSON API for managing worktrees and agents over HTTP."
+		helpText = `Runs an HTTP server on the repository it is started in, so web dashboards
+and scripts on the same machine can manage worktrees without shelling out. It
+listens on 127.0.0.1:7337 unless --listen says otherwise.
+
+Every request needs an "Authorization: Bearer <token>" header. The token is
+--token, else $SPROUT_SERVE_TOKEN, else the one stored in
+~/.config/sprout/serve-token, which is generated on first use and readable
+only by you.
+
+Endpoints (arguments in a JSON body, or the query string for GET and DELETE):
+  GET    /api/worktrees     List worktrees
+  POST   /api/worktrees     Create a worktree (branch, or type and name,
+                            from_branch, or from_pr; start_agent, agent_type)
+  DELETE /api/worktrees     Remove a worktree (target, force, delete_branch)
+  POST   /api/agent/start   Start a worktree's agent (target, agent_type)
+  POST   /api/agent/stop    Stop a worktree's agent (target)
+  POST   /api/agent/prompt  Send a prompt to a worktree's agent (target, prompt)
+  GET    /api/agent/output  Recent agent output and status (target, lines)
+  GET    /api/diff          The branch's changes since base (target, uncommitted_only)
+
+Responses are {"result": ...} on success and {"error": "..."} otherwise, with
+401 for a missing token, 400 for bad arguments, 404 for an unknown worktree,
+and 422 when the operation fails.
+
+Examples:
+  sprout serve
+  sprout serve --listen 127.0.0.1:9000
+  curl -H "Authorization: Bearer $(cat ~/.config/sprout/serve-token)" \
+    http://127.0.0.1:7337/api/worktrees`
+	case "config":
+		usage = "sprout config <show|init|schema|lint> [--json|--toml] [--origin]"
+		description = "Show, write, describe, or lint the sprout config."
+		helpText = `Shows the configuration sprout runs with, writes a starter config file,
+prints a JSON schema of the config file, or checks its [[windows]].
+
+Actions:
+  show    Print the effective config: defaults, the global config, the repo
+          .sprout.toml, and environment variables merged, as the TOML file
+          that would set it
+  init    Write a config file listing every setting, commented out at its
+          default under its description and environment variable
+  schema  Print a JSON schema of the config file, for editors that validate
+          TOML against one (such as Taplo or Even Better TOML)
+  lint [target]
+          Check the [[windows]] of the effective config: unknown layout
+          names, windows without panes and empty panes, duplicate window
+          names, dirs missing from the target worktree (default: the main
+          worktree), and pane commands that are not installed. Exits with
+          status 1 when it finds a problem; sprout doctor reports the same
+          problems as warnings
+
+Flags:
+  --json    Print show as a JSON object
+  --toml    Print show as TOML (default)
+  --origin  With show, name where each value came from: a config file's path,
+            an environment variable, or default
+  --repo    With init, write .sprout.toml at the repository root instead of
+            the global config file
+  --force   With init, overwrite an existing file
+
+Examples:
+  sprout config show --origin
+  sprout config show --json | jq .base_branch
+  sprout config init
+  sprout config schema > ~/.config/sprout/schema.json
+  sprout config lint feat/checkout`
 	case "doctor":
-		usage = "sprout doctor"
-		description = "Check system dependencies and configuration."
+		usage = "sprout doctor [--fix]"
+		description = "Check system dependencies, configuration, and worktree health."
 		helpText = `Runs diagnostics to verify sprout's environment.
 
 Checks:
-  - Git installation and version
-  - Tmux installation and version
-  - Configured agent commands availability
-  - Git repository detection
-  - Configuration file validity
+  - Git and tmux installation
+  - Configured agent and session tool availability
+  - Global and repo config files parse cleanly
+  - Stale worktree registrations (worktree directory is gone)
+  - Broken .git gitdir pointers in linked worktrees
+  - Orphaned sprout tmux sessions with no backing worktree
+  - Branches missing for registered worktrees
+  - Worktrees not at their worktree_path_template path
+  - Problems in [[windows]], as sprout config lint finds them
+
+Flags:
+  --fix  Repair fixable problems: prune stale registrations, repair gitdir
+         pointers, kill orphaned tmux sessions, and move worktrees to their
+         worktree_path_template path
+
+Each problem is reported with its fix status: fixable with --fix, fixed, or
+fix failed. With --output json, every check is listed with its status and fix.
 
 Exit codes:
   0 - All checks passed
-  1 - One or more checks failed`
+  1 - A required tool is missing, a config file is invalid, or a fix failed`
+	case "version":
+		usage = "sprout version [--json]"
+		description = "Show the version and build info."
+		helpText = `Prints the version, the commit it was built from, the build date, and the Go
+version and platform:
+
+  sprout version
+  1.4.0 (3f9c2a1, 2026-05-02T10:31:00Z, go1.22.3 linux/amd64)
+
+sprout --version prints the same after "sprout". Release builds get the
+commit and date through -ldflags; a go build of a git checkout takes them
+from the checkout, with a + after the commit when it had uncommitted changes.
+The TUI's update-available message shows the commit too, so a nightly or
+local build is easy to tell from a release.
+
+Flags:
+  --json  Print version, commit, build_date, modified, go_version, and
+          platform as JSON`
 	case "shell-hook":
-		usage = "sprout shell-hook <zsh|bash|fish>"
+		usage = "sprout shell-hook <zsh|bash|fish|powershell|nu>"
 		description = "Output shell integration code for auto-cd functionality."
 		helpText = `Generates shell integration code for your shell.
 
 Arguments:
-  <shell>  Shell type (zsh, bash, or fish)
+  <shell>  Shell type (zsh, bash, fish, powershell, or nu/nushell)
 
-The shell hook enables automatic directory changing when using sprout commands.
+The shell hook defines spr, which runs sprout and changes directory for it:
+spr go and spr new cd into the worktree, and spr rm of the worktree you are
+in cds back to the main worktree.
 
 Installation:
   # For Zsh (add to ~/.zshrc)
@@ -279,7 +1119,14 @@ Installation:
   eval "$(sprout shell-hook bash)"
 
   # For Fish (add to ~/.config/fish/config.fish)
-  sprout shell-hook fish | source`
+  sprout shell-hook fish | source
+
+  # For PowerShell (add to $PROFILE)
+  sprout shell-hook powershell | Out-String | Invoke-Expression
+
+  # For Nushell (save once, then add the source line to config.nu)
+  sprout shell-hook nu | save -f ($nu.default-config-dir | path join sprout.nu)
+  source sprout.nu`
 	}
 
 	return
@@ -29,11 +29,30 @@ Complete reference for all Sprout configuration options.
 Sprout loads configuration in the following order, with each layer overriding the previous:
 
 1. **Global config**: {{ backtick }}~/.config/sprout/config.toml{{ backtick }} (or {{ backtick }}$SPROUT_CONFIG{{ backtick }} if set)
-2. **Repo config**: {{ backtick }}.sprout.toml{{ backtick }} at the root of the current git repository
-3. **Environment variables**: highest priority, override everything
+2. **Repo section of the global config**: a {{ backtick }}[repos.<name>]{{ backtick }} table in the global config, where {{ backtick }}<name>{{ backtick }} is the directory name of the repository
+3. **Repo config**: {{ backtick }}.sprout.toml{{ backtick }} at the root of the current git repository
+4. **Environment variables**: highest priority, override everything
 
 The repo config only needs to contain the keys you want to override. Everything else falls back to the global config.
 
+Run {{ backtick }}sprout config show --origin{{ backtick }} to see the merged result with the file or environment variable each value came from, and {{ backtick }}sprout config init{{ backtick }} to start a config file with every setting listed at its default. {{ backtick }}sprout config schema{{ backtick }} prints a JSON schema of the config file for editors that validate TOML.
+
+### Example repo section
+
+Keep per-repository settings out of the repository itself by putting them in the global config. Any option can go in a repo section; keys before the first table apply to every repository.
+
+{{ backtick }}{{ backtick }}{{ backtick }}toml
+# ~/.config/sprout/config.toml
+base_branch = "main"
+
+[repos.api]
+base_branch = "develop"
+default_agent_type = "claude"
+
+[[repos.api.windows]]
+name = "dev"
+{{ backtick }}{{ backtick }}{{ backtick }}
+
 ### Example repo config
 
 {{ backtick }}{{ backtick }}{{ backtick }}toml
@@ -62,6 +81,16 @@ base_branch = "main"
 # {{ .OpenBrace }}repo{{ .CloseBrace }} is replaced with repository name
 worktree_root_template = "../{{ .OpenBrace }}repo{{ .CloseBrace }}.worktrees"
 
+# Directory of each worktree under the root: {{ .OpenBrace }}branch{{ .CloseBrace }}, {{ .OpenBrace }}type{{ .CloseBrace }}, {{ .OpenBrace }}slug{{ .CloseBrace }}, {{ .OpenBrace }}date{{ .CloseBrace }}
+worktree_path_template = "{{ .OpenBrace }}branch{{ .CloseBrace }}"
+
+# Keep worktrees one directory deep: feat/x/y lives in feat-x-y
+flatten_branch_dirs = false
+
+# Directory inside each worktree that tmux sessions and the agent start in
+# (for monorepos); empty starts at the worktree root
+worktree_subdir = ""
+
 # Automatically launch tmux when creating new worktrees
 auto_launch = true
 
@@ -74,6 +103,9 @@ copy_untracked_exclude = ["build", "dist/**"]
 # Check for updates (disable with SPROUT_UPDATE_CHECK=0)
 update_check = true
 
+# Release channel for update checks: stable or prerelease
+update_channel = "stable"
+
 # Launch nvim in tmux session
 launch_nvim = true
 
@@ -83,17 +115,136 @@ launch_lazygit = true
 # Default agent command (deprecated, use default_agent_type)
 agent_command = "codex"
 
+# Extra arguments appended to the agent command
+agent_args = ""
+
 # Default agent type to use
 default_agent_type = "codex"
 
 # Tmux session prefix
 session_prefix = "sprout"
 
+# A tmux session per worktree, or one per repository: per-worktree or per-repo
+session_mode = "per-worktree"
+
+# How non-ASCII letters in feature names become branch slugs: ascii or unicode
+slug_mode = "ascii"
+
+# Types accepted by sprout new <type> <name>, and how the branch is built
+branch_types = ["feat", "fix", "chore", "docs", "refactor", "test"]
+branch_template = "{{ .OpenBrace }}type{{ .CloseBrace }}/{{ .OpenBrace }}slug{{ .CloseBrace }}"
+
+# Regex every new branch must match (empty allows any)
+branch_pattern = ""
+
+# How sprout reads worktree status and branches: exec (git binary) or gogit (in-process)
+git_backend = "exec"
+
+# Untracked files in the STATUS column: all (every refresh), periodic (once a minute), or no
+dirty_untracked = "periodic"
+
+# Debug log verbosity: error, info, debug, or trace (override with SPROUT_DEBUG)
+log_level = "info"
+
+# Maximum linked worktrees before sprout new asks you to finish or prune one (0 = unlimited)
+wip_limit = 0
+
+# What quitting the TUI does with running agents: none, ask, stop-agents, or detach
+on_quit = "none"
+
+# Where worktrees and agents are launched: tmux, wt (Windows Terminal), or none
+launch_backend = "tmux"
+
+# Agent events (ready, exited, idle) that trigger each kind of notification
+notify_desktop = []
+notify_bell = []
+notify_webhook = ""
+notify_webhook_events = ["ready", "exited"]
+
+# Minutes an agent may wait for input before the TUI warns that it is idle (0 = never)
+agent_idle_minutes = 15
+
+# Minutes between background fetches of the repository while the TUI runs (0 = never)
+auto_fetch_minutes = 0
+
+# tmux keys sent to an agent so it can exit cleanly before sprout stops it or removes its worktree
+agent_exit_keys = ["C-c", "C-c"]
+# Seconds to wait for the agent to exit before killing it (0 = kill right away)
+agent_exit_wait = 5
+
+# Minutes sprout undo can restore a removed worktree (0 = no backups)
+undo_minutes = 10
+
+# Where the TUI's export action (e/E) writes agent transcripts and patches
+export_dir = "~/.local/share/sprout/exports"
+
+# Manage a repository on another host: git and tmux run there over ssh
+ssh_host = ""
+ssh_repo = ""
+
+# Run worktree sessions and agents in a container ({worktree} and {name} are filled in)
+container_command = ""
+container_up = ""
+container_down = ""
+
+# Test or lint command sprout check runs in a worktree
+check_command = ""
+
+# Prompt sent to agents resumed after a tmux restart ({branch} and {last_prompt} are filled in)
+agent_resume_prompt = ""
+
+# Color output: auto, always, or never (NO_COLOR also disables color)
+color = "auto"
+
+# Palette: dark, or light for white-background terminals
+theme = "dark"
+
+# Show CPU and memory of each worktree's tmux session in the TUI (toggle with R)
+show_resources = false
+
+# Show sibling repositories in a TUI sidebar with worktree and ready-agent counts (toggle with S)
+repo_sidebar = false
+
+# Worktree table columns, in order, for sprout list and the TUI (empty uses the built-in layout)
+# columns = ["branch", "status", "agent", "ahead", "path"]
+
+# How table paths are shown: absolute, home (~/...), or relative (to the worktree root)
+path_display = "absolute"
+
+# Worktree order: path, active (most recently active first), or idle (least recently active first)
+sort = "path"
+
+# Share of the TUI body height given to the Details pane, 10-90 (ctrl+up/down saves it here)
+details_percent = 60
+
+# Details pane polling: fast while the selected agent's output changes, backing
+# off to the idle interval while nothing does
+detail_poll_ms = 150
+detail_idle_poll_ms = 2000
+# Most lines of agent output the Details pane captures
+detail_capture_lines = 60
+
+# How long the GIT DIFF tab reuses its file list and patches
+diff_files_cache_ms = 900
+diff_patch_cache_ms = 2000
+
 # Agent commands by type
 agent_command_codex = "codex"
 agent_command_aider = "aider"
 agent_command_claude = "claude"
 agent_command_gemini = "gemini"
+
+# Named worktree filters for sprout list --filter and the TUI's f key
+[filters]
+agent-ready = "agent:ready"
+dirty = "status:dirty"
+
+# Shell commands run in the background on worktree events
+[hooks]
+# on_create = "make deps"
+# on_remove = ""
+# on_agent_ready = ""
+# on_attach = ""
 {{ backtick }}{{ backtick }}{{ backtick }}
 
 ## Environment Variable Overrides
@@ -101,7 +252,7 @@ agent_command_gemini = "gemini"
 All configuration options can be overridden with environment variables:
 
 {{ backtick }}{{ backtick }}{{ backtick }}bash
-{{ range .Options }}{{ if .EnvVar }}export {{ .EnvVar }}="{{ .Default }}"
+{{ range .Options }}{{ if and .EnvVar (ne .EnvVar "-") }}export {{ .EnvVar }}="{{ .Default }}"
 {{ end }}{{ end }}{{ backtick }}{{ backtick }}{{ backtick }}
 
 ## Configuration Details
@@ -116,6 +267,40 @@ Template for the directory where worktrees will be created. The {{ backtick }}{{
 
 For example, if your repo is {{ backtick }}/home/user/myproject{{ backtick }} and the template is {{ backtick }}../{{ .OpenBrace }}repo{{ .CloseBrace }}.worktrees{{ backtick }}, worktrees will be created in {{ backtick }}/home/user/myproject.worktrees/{{ backtick }}.
 
+### worktree_path_template
+
+The directory of each worktree inside the worktree root. The default, {{ backtick }}{{ .OpenBrace }}branch{{ .CloseBrace }}{{ backtick }}, nests worktrees by branch name, so {{ backtick }}feat/login{{ backtick }} lives in {{ backtick }}myproject.worktrees/feat/login{{ backtick }}. Placeholders:
+
+- {{ backtick }}{{ .OpenBrace }}branch{{ .CloseBrace }}{{ backtick }}: the branch name, slashes included
+- {{ backtick }}{{ .OpenBrace }}type{{ .CloseBrace }}{{ backtick }}: the part of the branch before the first slash ({{ backtick }}feat{{ backtick }}), or nothing
+- {{ backtick }}{{ .OpenBrace }}slug{{ .CloseBrace }}{{ backtick }}: the rest of the branch with slashes turned into dashes ({{ backtick }}login{{ backtick }})
+- {{ backtick }}{{ .OpenBrace }}date{{ .CloseBrace }}{{ backtick }}: the day the worktree is created, as {{ backtick }}2006-01-02{{ backtick }}
+
+Use {{ backtick }}{{ .OpenBrace }}type{{ .CloseBrace }}-{{ .OpenBrace }}slug{{ .CloseBrace }}{{ backtick }} to keep every worktree one level deep ({{ backtick }}feat-login{{ backtick }}). Path segments a missing {{ backtick }}{{ .OpenBrace }}type{{ .CloseBrace }}{{ backtick }} leaves empty are dropped. When another branch's worktree already has the path, as {{ backtick }}feat/login{{ backtick }} and {{ backtick }}fix/login{{ backtick }} would under {{ backtick }}{{ .OpenBrace }}slug{{ .CloseBrace }}{{ backtick }}, the new one gets {{ backtick }}-2{{ backtick }}, {{ backtick }}-3{{ backtick }}, and so on.
+
+After changing the template, {{ backtick }}sprout doctor{{ backtick }} lists the worktrees under the root that are not where it puts them and {{ backtick }}sprout doctor --fix{{ backtick }} moves them, except locked worktrees and worktrees with a running tmux session. Templates with {{ backtick }}{{ .OpenBrace }}date{{ .CloseBrace }}{{ backtick }} are not migrated, since the creation date of existing worktrees is not known.
+
+### flatten_branch_dirs
+
+Branches with slashes get nested directories, which break toolchains that do not expect a worktree to sit several levels deep. With {{ backtick }}flatten_branch_dirs = true{{ backtick }} the directory of branch {{ backtick }}feat/x/y{{ backtick }} is {{ backtick }}feat-x-y{{ backtick }} instead; the branch keeps its name. It applies on top of {{ backtick }}worktree_path_template{{ backtick }}, joining its path segments with dashes. Commands also accept the flattened name as their target ({{ backtick }}sprout go feat-x-y{{ backtick }}), including for worktrees created before the switch, and {{ backtick }}sprout doctor --fix{{ backtick }} moves those to the flat layout.
+
+### worktree_subdir
+
+A directory inside each worktree, such as {{ backtick }}apps/web{{ backtick }}, where tmux sessions, their windows and the agent start instead of the worktree root. Useful in monorepos where most work happens in one package. It must be a relative path that stays inside the worktree. Worktrees without that directory start at their root.
+
+With {{ backtick }}[[windows]]{{ backtick }}, it is the default for panes that set no {{ backtick }}dir{{ backtick }}. A window can set its own {{ backtick }}dir{{ backtick }} as the default for its panes. Relative {{ backtick }}dir{{ backtick }} values, and {{ backtick }}{{ .OpenBrace }}worktree{{ .CloseBrace }}{{ backtick }}, still refer to the worktree root.
+
+{{ backtick }}{{ backtick }}{{ backtick }}toml
+worktree_subdir = "apps/web"
+
+[[windows]]
+name = "api"
+dir = "services/api"
+
+[[windows.panes]]
+run = "go run ./cmd/api"
+{{ backtick }}{{ backtick }}{{ backtick }}
+
 ### auto_launch
 
 When {{ backtick }}true{{ backtick }}, automatically creates and attaches to a tmux session when creating a new worktree with {{ backtick }}sprout new{{ backtick }}.
@@ -134,6 +319,10 @@ Patterns are matched against paths relative to the repo root. Supports glob synt
 
 When {{ backtick }}true{{ backtick }}, Sprout checks GitHub for updates once per day. Disable by setting {{ backtick }}SPROUT_UPDATE_CHECK=0{{ backtick }}.
 
+### update_channel
+
+Which releases the update check offers. {{ backtick }}stable{{ backtick }} only considers the latest full release; {{ backtick }}prerelease{{ backtick }} also considers release candidates and other prereleases, offering whichever recent release has the highest version. The TUI footer names the channel along with the available version.
+
 ### launch_nvim
 
 When {{ backtick }}true{{ backtick }}, opens Neovim in a tmux pane when launching a session.
@@ -148,6 +337,21 @@ When {{ backtick }}true{{ backtick }}, opens Lazygit in a tmux pane when launchi
 
 The command to run for starting an AI agent.
 
+### agent_args
+
+Extra arguments appended to the agent command, whichever agent type is started, such as a model or sandbox flags: {{ backtick }}agent_args = "--model o3"{{ backtick }}.
+
+### Per-worktree agent overrides
+
+A linked worktree can override the agent for itself alone with a {{ backtick }}.sprout.toml{{ backtick }} at its root. Only {{ backtick }}agent_command{{ backtick }}, {{ backtick }}agent_args{{ backtick }} and {{ backtick }}agent_command_*{{ backtick }} are read from it; they replace the configured values when sprout starts or restarts that worktree's agent, from any worktree. The TUI status pane shows the overriding command as {{ backtick }}agent cmd{{ backtick }}, and {{ backtick }}sprout list --json{{ backtick }} reports it as {{ backtick }}AgentCommand{{ backtick }}.
+
+{{ backtick }}{{ backtick }}{{ backtick }}toml
+# <worktree>/.sprout.toml
+agent_args = "--sandbox read-only"
+{{ backtick }}{{ backtick }}{{ backtick }}
+
+The main worktree's {{ backtick }}.sprout.toml{{ backtick }} is the repo-level config and applies to every worktree. A {{ backtick }}.sprout.toml{{ backtick }} committed on a branch applies to the worktrees of that branch, so keep overrides uncommitted or git-ignored when they are meant for one worktree.
+
 ### default_agent_type
 
 The default AI agent to use. Must match one of the agent types defined in {{ backtick }}agent_command_*{{ backtick }} options.
@@ -158,6 +362,253 @@ Supported values: {{ backtick }}codex{{ backtick }}, {{ backtick }}aider{{ backt
 
 Prefix for tmux session names. Sessions will be named {{ backtick }}{prefix}-{branch}{{ backtick }}.
 
+### session_mode
+
+How worktrees map to tmux sessions:
+
+- {{ backtick }}per-worktree{{ backtick }} gives every worktree its own session, {{ backtick }}{prefix}-{repo}-{branch}{{ backtick }} (default)
+- {{ backtick }}per-repo{{ backtick }} puts the windows of all worktrees of a repository in one session, {{ backtick }}{prefix}-{repo}{{ backtick }}, so switching worktrees is switching windows
+
+In {{ backtick }}per-repo{{ backtick }} mode the agent, lazygit, and editor windows already carry the branch, and other window names, such as {{ backtick }}[[windows]]{{ backtick }} names, get the branch in front: {{ backtick }}feat-login-editor{{ backtick }}. {{ backtick }}sprout launch{{ backtick }}, {{ backtick }}sprout popup{{ backtick }}, and the TUI select the worktree's first window; {{ backtick }}sprout detach{{ backtick }} and removing a worktree close only that worktree's windows, and the session ends with the last one. Each window is tagged with its worktree in the {{ backtick }}@sprout_worktree{{ backtick }} window option, which is how the list, agent commands, and resource usage tell the worktrees apart. Containers are named {{ backtick }}{session}-{worktree directory}{{ backtick }} instead of after the session.
+
+### slug_mode
+
+How {{ backtick }}sprout new <type> <name>{{ backtick }} turns non-ASCII letters in the feature name into the branch slug:
+
+- {{ backtick }}ascii{{ backtick }} transliterates accented Latin, Cyrillic, Greek, and Japanese kana ({{ backtick }}Café{{ backtick }} becomes {{ backtick }}cafe{{ backtick }}, {{ backtick }}Привет мир{{ backtick }} becomes {{ backtick }}privet-mir{{ backtick }}, {{ backtick }}ログイン{{ backtick }} becomes {{ backtick }}roguin{{ backtick }}) and drops anything else, such as kanji (default)
+- {{ backtick }}unicode{{ backtick }} keeps letters and digits of every script, so {{ backtick }}ログイン画面{{ backtick }} becomes {{ backtick }}feat/ログイン画面{{ backtick }}
+
+Tmux session and window names are always transliterated; letters without a spelling are replaced by a short hash so different branches never share a session.
+
+### branch_types
+
+The types {{ backtick }}sprout new <type> <name>{{ backtick }} accepts. Defaults to {{ backtick }}feat{{ backtick }}, {{ backtick }}fix{{ backtick }}, {{ backtick }}chore{{ backtick }}, {{ backtick }}docs{{ backtick }}, {{ backtick }}refactor{{ backtick }}, and {{ backtick }}test{{ backtick }}. An empty list accepts any lowercase type.
+
+### branch_template
+
+How {{ backtick }}sprout new <type> <name>{{ backtick }} builds the branch name. It must contain {{ backtick }}{{ .OpenBrace }}slug{{ .CloseBrace }}{{ backtick }} and can use:
+
+- {{ backtick }}{{ .OpenBrace }}type{{ .CloseBrace }}{{ backtick }}: the type argument
+- {{ backtick }}{{ .OpenBrace }}slug{{ .CloseBrace }}{{ backtick }}: the name, slugified according to {{ backtick }}slug_mode{{ backtick }}
+- {{ backtick }}{{ .OpenBrace }}user{{ .CloseBrace }}{{ backtick }}: your git {{ backtick }}user.email{{ backtick }} up to the {{ backtick }}@{{ backtick }}, or your login name when it is unset
+- {{ backtick }}{{ .OpenBrace }}date{{ .CloseBrace }}{{ backtick }}: today's date as {{ backtick }}YYYY-MM-DD{{ backtick }}
+
+With {{ backtick }}branch_template = "{{ .OpenBrace }}type{{ .CloseBrace }}/{{ .OpenBrace }}user{{ .CloseBrace }}/{{ .OpenBrace }}slug{{ .CloseBrace }}"{{ backtick }}, {{ backtick }}sprout new feat "login page"{{ backtick }} creates {{ backtick }}feat/jdoe/login-page{{ backtick }}.
+
+### branch_pattern
+
+A regular expression every new branch must match, whether it was built from {{ backtick }}branch_template{{ backtick }} or typed in full in the TUI. Branches checked out with {{ backtick }}--from-branch{{ backtick }} are not checked. Empty (the default) allows any valid branch name.
+
+{{ backtick }}{{ backtick }}{{ backtick }}toml
+branch_pattern = "^(feat|fix)/[a-z0-9]+/[a-z0-9-]+$"
+{{ backtick }}{{ backtick }}{{ backtick }}
+
+### git_backend
+
+How sprout answers the queries it runs on every refresh: whether a worktree is dirty, its changed files, and the branch list.
+
+- {{ backtick }}exec{{ backtick }} runs the {{ backtick }}git{{ backtick }} binary (default)
+- {{ backtick }}gogit{{ backtick }} reads the repository in-process with go-git, without spawning a process per worktree
+
+go-git honors {{ backtick }}.gitignore{{ backtick }}, {{ backtick }}info/exclude{{ backtick }}, and {{ backtick }}core.excludesFile{{ backtick }}, but its status is slower than git's on large worktrees because it cannot use git's stat cache. In a 20,000-file worktree it measured around 0.7s against 40ms for git, so {{ backtick }}gogit{{ backtick }} mainly pays off where starting processes is expensive. Anything go-git cannot read falls back to {{ backtick }}exec{{ backtick }}.
+
+### dirty_untracked
+
+Whether untracked files make a worktree show as dirty in the worktree list, the TUI, and the status line. Looking for them walks every directory git does not know, which takes seconds on large repositories, while changes to tracked files are found quickly.
+
+- {{ backtick }}all{{ backtick }} looks for untracked files on every refresh
+- {{ backtick }}periodic{{ backtick }} checks tracked files on every refresh and looks for untracked files at most once a minute per worktree, or when {{ backtick }}r{{ backtick }} refreshes the TUI (default)
+- {{ backtick }}no{{ backtick }} never counts untracked files
+
+Removing, merging, and copying changes into a worktree always look for untracked files before deciding a worktree is clean. With {{ backtick }}git_backend = "gogit"{{ backtick }}, untracked files are always counted.
+
+### log_level
+
+Verbosity of the debug log written to {{ backtick }}$SPROUT_DEBUG_LOG{{ backtick }} (default: {{ backtick }}sprout-debug.log{{ backtick }} in the system temp directory). Levels from quietest to most verbose: {{ backtick }}error{{ backtick }}, {{ backtick }}info{{ backtick }}, {{ backtick }}debug{{ backtick }}, {{ backtick }}trace{{ backtick }}. {{ backtick }}trace{{ backtick }} records every git/tmux command sprout runs. Override per invocation with {{ backtick }}SPROUT_DEBUG=trace{{ backtick }} ({{ backtick }}SPROUT_DEBUG=1{{ backtick }} means {{ backtick }}debug{{ backtick }}). Press {{ backtick }}L{{ backtick }} in the TUI to tail the log.
+
+### wip_limit
+
+Maximum number of linked worktrees (the main worktree does not count) before creating another one asks you to finish or prune something first. {{ backtick }}sprout new{{ backtick }} lists the best candidates to remove, lowest priority and clean worktrees first, and asks for confirmation ({{ backtick }}--yes{{ backtick }} skips it); the TUI offers to remove the top candidate or create anyway. {{ backtick }}0{{ backtick }} disables the limit. Set priorities with {{ backtick }}sprout priority{{ backtick }} or {{ backtick }}P{{ backtick }} in the TUI.
+
+### on_quit
+
+What pressing {{ backtick }}q{{ backtick }} in the TUI does with agents that are still running in the current repository, so they are not left working overnight:
+
+- {{ backtick }}none{{ backtick }} quits and leaves everything running (default)
+- {{ backtick }}ask{{ backtick }} lists the running agents and asks whether to stop them, stop them and detach every session, or leave them
+- {{ backtick }}stop-agents{{ backtick }} stops every agent window and keeps the sessions
+- {{ backtick }}detach{{ backtick }} kills every worktree session, agents included
+
+Override it for one run with {{ backtick }}sprout ui --on-quit <action>{{ backtick }}. {{ backtick }}ctrl+c{{ backtick }} always quits immediately. {{ backtick }}sprout shutdown{{ backtick }} does the same from the command line.
+
+### launch_backend
+
+Where {{ backtick }}sprout launch{{ backtick }}, {{ backtick }}sprout go{{ backtick }}, {{ backtick }}sprout new{{ backtick }}, and {{ backtick }}sprout agent start{{ backtick }} open a worktree:
+
+- {{ backtick }}tmux{{ backtick }} uses a tmux session per worktree (default). Without tmux installed it behaves like {{ backtick }}none{{ backtick }}.
+- {{ backtick }}wt{{ backtick }} opens a Windows Terminal tab in the worktree, and runs the agent in a tab of its own. Sprout does not track these tabs, so agents cannot be stopped, watched, or sent a prompt from sprout.
+- {{ backtick }}none{{ backtick }} creates, lists, and removes worktrees without opening anything, and does not auto-start agents. {{ backtick }}sprout launch{{ backtick }} fails.
+
+### notify_desktop, notify_bell, notify_webhook, notify_webhook_events
+
+While the TUI is open, sprout watches the agent of every worktree in the current repository, not just the selected one, and notifies you when one needs attention. Each option lists the events that use that channel:
+
+- {{ backtick }}ready{{ backtick }}: a busy agent is now waiting for input
+- {{ backtick }}exited{{ backtick }}: a running agent's pane exited
+- {{ backtick }}idle{{ backtick }}: an agent has been waiting for input for longer than {{ backtick }}agent_idle_minutes{{ backtick }}; fires once per wait
+
+{{ backtick }}notify_desktop{{ backtick }} shows a desktop notification ({{ backtick }}osascript{{ backtick }} on macOS, {{ backtick }}notify-send{{ backtick }} elsewhere), {{ backtick }}notify_bell{{ backtick }} rings the terminal bell, and {{ backtick }}notify_webhook{{ backtick }} is a URL that receives a JSON POST for each event in {{ backtick }}notify_webhook_events{{ backtick }}:
+
+{{ backtick }}{{ backtick }}{{ backtick }}json
+{"event": "ready", "repo": "app", "branch": "feat/login", "path": "/home/me/src/app.worktrees/feat/login", "time": "2025-01-01T12:00:00Z"}
+{{ backtick }}{{ backtick }}{{ backtick }}
+
+Idle events also carry {{ backtick }}"idle"{{ backtick }}, how long the agent has been waiting, e.g. {{ backtick }}"25m"{{ backtick }}.
+
+Everything is off by default. Agents are polled every two seconds, and an agent that is already waiting when the TUI starts does not trigger a notification. The environment variables take comma-separated lists, for example {{ backtick }}SPROUT_NOTIFY_BELL=ready,exited{{ backtick }}.
+
+### agent_idle_minutes
+
+The TUI's AGENT column and status pane show how long each agent has been busy or waiting for input, e.g. {{ backtick }}ready 12m{{ backtick }}. A ready agent counts from when its pane last changed, so one that was already waiting when the TUI started shows its full wait; a busy agent shows a time once sprout has seen it start working. When an agent has waited longer than {{ backtick }}agent_idle_minutes{{ backtick }} (15 by default), the footer warns about it once and the {{ backtick }}idle{{ backtick }} notification event fires. Set it to {{ backtick }}0{{ backtick }} to turn the warning off.
+
+### auto_fetch_minutes
+
+While the TUI runs, fetch the repository in the background every this many minutes with {{ backtick }}git fetch --prune{{ backtick }}: once for the repository, since its worktrees share its refs. Afterwards the worktree list refreshes, so ahead/behind counts and the other columns follow, and the status pane shows how long ago the last fetch was. Fetches by {{ backtick }}sprout fetch{{ backtick }}, {{ backtick }}F{{ backtick }} in the TUI, or another TUI on the same repository count too, so several open TUIs do not each fetch it. A failed fetch is retried every minute and reported once. The default {{ backtick }}0{{ backtick }} never fetches on its own.
+
+### agent_exit_keys
+
+Before {{ backtick }}sprout rm{{ backtick }} removes a worktree, or {{ backtick }}sprout agent stop{{ backtick }} stops its agent, sprout asks the running agent to exit by sending it {{ backtick }}agent_exit_keys{{ backtick }}, then waits up to {{ backtick }}agent_exit_wait{{ backtick }} seconds for it to before killing its tmux window. That gives the agent time to save its session and finish writing files instead of leaving them half written. Each entry is passed to {{ backtick }}tmux send-keys{{ backtick }}: key names like {{ backtick }}C-c{{ backtick }}, {{ backtick }}Enter{{ backtick }}, or {{ backtick }}Escape{{ backtick }} are pressed, anything else is typed. The default, two Ctrl-C presses, quits codex, Claude Code, gemini, and aider; an agent with a quit command can use e.g. {{ backtick }}["/quit", "Enter"]{{ backtick }}. An empty list or {{ backtick }}agent_exit_wait = 0{{ backtick }} kills agents right away. When the wait runs out, {{ backtick }}sprout rm{{ backtick }} warns that the agent was killed.
+
+### undo_minutes
+
+Before a worktree is removed, sprout keeps its commit and a backup commit of its uncommitted and untracked files (ignored files are not kept) under {{ backtick }}refs/sprout/undo/{{ backtick }} in the repository. For {{ backtick }}undo_minutes{{ backtick }} after that, {{ backtick }}sprout undo{{ backtick }} or {{ backtick }}u{{ backtick }} in the TUI recreates the last removed worktree at its old path: the branch comes back if it was deleted, and the uncommitted files return as unstaged changes. Only the last removal of each repository can be undone. Set it to {{ backtick }}0{{ backtick }} to skip the backup.
+
+### export_dir
+
+In the TUI, {{ backtick }}e{{ backtick }} on the agent output tab writes the agent's transcript, with as much scrollback as tmux kept, and {{ backtick }}e{{ backtick }} on the diff tab writes the selected file's patch; {{ backtick }}E{{ backtick }} writes the whole worktree diff. Patches are taken against the current diff base and include untracked files. Each export is a new timestamped file in {{ backtick }}export_dir{{ backtick }}, such as {{ backtick }}app-feat-login-agent-20261016-150405.log{{ backtick }}, and the footer shows its path. {{ backtick }}~{{ backtick }} expands to your home directory and relative paths are taken from the repository root.
+
+### ssh_host, ssh_repo
+
+Manage a repository that lives on another machine, such as a dev server your agents run on. With {{ backtick }}ssh_host{{ backtick }} set, sprout runs its git and tmux commands on that host over ssh, against the repository at {{ backtick }}ssh_repo{{ backtick }} there, while the CLI and TUI run locally. Worktree sessions and agents live in the host's tmux, agent output is captured from it, and attaching opens an ssh session into it.
+
+{{ backtick }}ssh_host{{ backtick }} is anything {{ backtick }}ssh{{ backtick }} accepts, including a {{ backtick }}Host{{ backtick }} from {{ backtick }}~/.ssh/config{{ backtick }}. Key-based login is required, since sprout runs ssh without a terminal. Commands share one connection per host through an ssh control socket.
+
+Put both settings in a {{ backtick }}.sprout.toml{{ backtick }} in a local workspace directory and run sprout from there; outside a repository sprout reads {{ backtick }}.sprout.toml{{ backtick }} from the current directory:
+
+{{ backtick }}{{ backtick }}{{ backtick }}toml
+ssh_host = "dev-box"
+ssh_repo = "/home/me/src/app"
+{{ backtick }}{{ backtick }}{{ backtick }}
+
+In remote mode untracked files are not copied into new worktrees, the repository lock is not taken, removing a worktree shows no per-file progress, and the repository's own {{ backtick }}.sprout.toml{{ backtick }} on the host is not read.
+
+### container_command, container_up, container_down
+
+Run each worktree's tmux windows, panes, and agent inside a container, so agents are sandboxed from the host and from each other. Set it in a repository's {{ backtick }}.sprout.toml{{ backtick }} to use it for that repository only. Every window and pane command runs as {{ backtick }}<container_command> sh -c '<command>'{{ backtick }}; windows without a command get a shell in the container.
+
+- {{ backtick }}container_command{{ backtick }} is the prefix that executes a command in the worktree's container, e.g. {{ backtick }}devcontainer exec --workspace-folder {worktree}{{ backtick }} or {{ backtick }}docker exec -it {name}{{ backtick }}
+- {{ backtick }}container_up{{ backtick }} starts the container when a worktree session is launched. It defaults to {{ backtick }}devcontainer up --workspace-folder {worktree}{{ backtick }} when {{ backtick }}container_command{{ backtick }} is a {{ backtick }}devcontainer exec{{ backtick }}
+- {{ backtick }}container_down{{ backtick }} stops the container when the session is detached ({{ backtick }}sprout detach{{ backtick }}, {{ backtick }}on_quit = "detach"{{ backtick }}) or the worktree is removed
+
+{{ backtick }}{worktree}{{ backtick }} is the worktree path, shell-quoted, and {{ backtick }}{name}{{ backtick }} is the worktree's tmux session name (with {{ backtick }}session_mode = "per-repo"{{ backtick }}, the session name and the worktree's directory), which is unique per worktree and safe as a container name. With Docker:
+
+{{ backtick }}{{ backtick }}{{ backtick }}toml
+container_command = "docker exec -it -w /work {name}"
+container_up = "docker run -d --rm --name {name} -v {worktree}:/work my-agent-image sleep infinity"
+container_down = "docker stop {name}"
+{{ backtick }}{{ backtick }}{{ backtick }}
+
+### check_command
+
+The test or lint command of the repository, such as {{ backtick }}go test ./... && golangci-lint run{{ backtick }} or {{ backtick }}pnpm lint && pnpm test{{ backtick }}. It runs with {{ backtick }}sh -c{{ backtick }} in the worktree. Set it in a repository's {{ backtick }}.sprout.toml{{ backtick }} or under {{ backtick }}[repos.<name>]{{ backtick }}.
+
+{{ backtick }}sprout check <target>{{ backtick }} runs it in one worktree and {{ backtick }}sprout check --all{{ backtick }} in every one. Whether it passed, when, how long it took, and the commit it ran against are stored with the branch in git config ({{ backtick }}branch.<name>.sproutcheck{{ backtick }}), so the {{ backtick }}check{{ backtick }} column shows which agent branches are green locally before you review them.
+
+### agent_resume_prompt
+
+sprout remembers which worktrees had an agent it started, and with which agent type, in {{ backtick }}~/.config/sprout/agents.json{{ backtick }}. When the tmux server goes away (a reboot, or {{ backtick }}tmux kill-server{{ backtick }}), {{ backtick }}sprout resume{{ backtick }} or {{ backtick }}A{{ backtick }} in the TUI starts those agents again; the TUI points them out on startup. Agents stopped on purpose, with {{ backtick }}sprout agent stop{{ backtick }}, a detach, or a removal, are not resumed.
+
+A fresh agent has lost the conversation it was having. Set {{ backtick }}agent_resume_prompt{{ backtick }} to send each resumed agent a prompt once it is ready for input, to pick the work back up. {{ backtick }}{branch}{{ backtick }} is replaced with the worktree's branch and {{ backtick }}{last_prompt}{{ backtick }} with the last prompt sent to its agent, for example:
+
+{{ backtick }}{{ backtick }}{{ backtick }}toml
+agent_resume_prompt = "You were working on {branch} and the session was lost. The last instruction was: {last_prompt}. Check the working tree and continue."
+{{ backtick }}{{ backtick }}{{ backtick }}
+
+It is empty by default, so resumed agents start without a prompt.
+
+### color
+
+When to use color in CLI output and the TUI. {{ backtick }}auto{{ backtick }} (default) colors output on a terminal and drops color when it is piped, {{ backtick }}always{{ backtick }} keeps color even in pipes, and {{ backtick }}never{{ backtick }} turns it off everywhere; the TUI then uses the terminal's default colors with bold and reverse video for emphasis. Setting the {{ backtick }}NO_COLOR{{ backtick }} environment variable to any value is the same as {{ backtick }}never{{ backtick }}; {{ backtick }}SPROUT_COLOR{{ backtick }} overrides both.
+
+### theme
+
+Color palette. {{ backtick }}dark{{ backtick }} (default) is tuned for dark terminal backgrounds; {{ backtick }}light{{ backtick }} uses darker shades of the same colors so text stays readable on white backgrounds, and passes {{ backtick }}--light{{ backtick }} to delta when rendering diffs.
+
+### show_resources
+
+Adds a {{ backtick }}CPU/MEM{{ backtick }} column to the TUI worktree list with the CPU and resident memory of every process running in the worktree's tmux session: the pane processes and all of their children, so an agent or dev server pegging the machine stands out. CPU is the share of one core used since the previous sample (taken every few seconds), so a busy session can exceed 100%. The status pane shows the same numbers with the process count. Press {{ backtick }}R{{ backtick }} to toggle the column without changing the setting.
+
+### repo_sidebar
+
+Shows a {{ backtick }}Repos{{ backtick }} sidebar left of the TUI panes listing the git repositories beside the current one, the same ones the status pane's repo picker offers, with how many worktrees each has and how many of their agents wait for input. The current repository is marked with {{ backtick }}*{{ backtick }}. Focus the sidebar with {{ backtick }}tab{{ backtick }}, move with {{ backtick }}j{{ backtick }}/{{ backtick }}k{{ backtick }}, and press {{ backtick }}enter{{ backtick }} to switch to a repository in place: its saved filter comes back, and so does the worktree last selected in it. Its {{ backtick }}[repos.<name>]{{ backtick }} section and {{ backtick }}.sprout.toml{{ backtick }} take effect as it is switched to. The other repositories are counted on each refresh. Press {{ backtick }}S{{ backtick }} to show or hide the sidebar; sprout saves the choice here.
+
+### columns
+
+Which columns the worktree tables show, and in what order, for both {{ backtick }}sprout list{{ backtick }} and the TUI. Available columns:
+
+- {{ backtick }}cur{{ backtick }}: {{ backtick }}*{{ backtick }} on the worktree you are in
+- {{ backtick }}branch{{ backtick }}: branch name, with the pull request number and priority arrow; cut at 35 characters
+- {{ backtick }}priority{{ backtick }}: {{ backtick }}high{{ backtick }}, {{ backtick }}normal{{ backtick }}, or {{ backtick }}low{{ backtick }}
+- {{ backtick }}labels{{ backtick }}: labels set with {{ backtick }}sprout label{{ backtick }}, comma-separated; cut at 24 characters
+- {{ backtick }}status{{ backtick }}: clean, dirty, or rebase state
+- {{ backtick }}tmux{{ backtick }}: whether the worktree has a tmux session
+- {{ backtick }}agent{{ backtick }}: whether an agent is running
+- {{ backtick }}todo{{ backtick }}: TODO/FIXME markers added on the branch (TUI only)
+- {{ backtick }}merge{{ backtick }}: {{ backtick }}CONFLICT{{ backtick }} when merging the branch's commits into {{ backtick }}base_branch{{ backtick }} would conflict, {{ backtick }}ok{{ backtick }} when it would not (TUI only; checked with a {{ backtick }}git merge-tree{{ backtick }} dry run after each refresh and every minute, needs git 2.38)
+- {{ backtick }}check{{ backtick }}: {{ backtick }}pass{{ backtick }} or {{ backtick }}fail{{ backtick }} from the branch's last {{ backtick }}sprout check{{ backtick }}, with a {{ backtick }}*{{ backtick }} once the branch has new commits
+- {{ backtick }}lock{{ backtick }}: {{ backtick }}locked{{ backtick }} for locked worktrees
+- {{ backtick }}resources{{ backtick }}: CPU and memory of the tmux session (TUI only, shown while {{ backtick }}R{{ backtick }} has it on)
+- {{ backtick }}ahead{{ backtick }}: commits ahead ({{ backtick }}↑{{ backtick }}) and behind ({{ backtick }}↓{{ backtick }}) {{ backtick }}base_branch{{ backtick }}
+- {{ backtick }}cost{{ backtick }}: tokens and cost the worktree's agents reported (see {{ backtick }}sprout status{{ backtick }})
+- {{ backtick }}active{{ backtick }}: how long ago the worktree was last touched, going by the latest of its HEAD commit, its git index changing, and its agent's pane activity; yellow past 30 days
+- {{ backtick }}path{{ backtick }}: worktree path, shortened to the width the other columns leave (whole when {{ backtick }}sprout list{{ backtick }} is piped)
+
+Left empty, {{ backtick }}sprout list{{ backtick }} shows {{ backtick }}cur, branch, priority, labels, status, tmux, agent, lock, path{{ backtick }} and the TUI shows {{ backtick }}cur, branch, labels, status, tmux, agent, todo, merge, check, lock, resources, path{{ backtick }}. With {{ backtick }}show_resources{{ backtick }} on and no {{ backtick }}resources{{ backtick }} entry, the column goes before the path.
+
+### path_display
+
+How worktree paths are shown in {{ backtick }}sprout list{{ backtick }} and the TUI:
+
+- {{ backtick }}absolute{{ backtick }} (default): the full path
+- {{ backtick }}home{{ backtick }}: the home directory shortened to {{ backtick }}~{{ backtick }}, e.g. {{ backtick }}~/code/app.worktrees/feat/x{{ backtick }}
+- {{ backtick }}relative{{ backtick }}: relative to the worktree root, e.g. {{ backtick }}feat/x{{ backtick }}; paths outside it, like the main checkout, are shown as with {{ backtick }}home{{ backtick }}
+
+JSON output ({{ backtick }}sprout list --json{{ backtick }}, {{ backtick }}--output json{{ backtick }}) always has absolute paths.
+
+### sort
+
+The order of the worktree tables in {{ backtick }}sprout list{{ backtick }} and the TUI:
+
+- {{ backtick }}path{{ backtick }} (default): the worktree you are in first, then by path
+- {{ backtick }}active{{ backtick }}: most recently active first, going by the same time as the {{ backtick }}active{{ backtick }} column
+- {{ backtick }}idle{{ backtick }}: least recently active first, to find worktrees to clean up
+
+{{ backtick }}sprout list --sort{{ backtick }} overrides it for one listing and {{ backtick }}o{{ backtick }} cycles through the orders in the TUI. While the TUI filter is set, worktrees are ordered by how well they match instead.
+
+### details_percent
+
+How much of the TUI's height, in percent, goes to the Details pane; the Worktrees table gets the rest. The default {{ backtick }}60{{ backtick }} splits them 3:2. Press {{ backtick }}ctrl+up{{ backtick }} or {{ backtick }}ctrl+down{{ backtick }} in the TUI to move the split by 5%; sprout writes the new value to the global config file so the layout sticks across runs. Press {{ backtick }}z{{ backtick }} to temporarily maximize the focused pane.
+
+### detail_poll_ms
+
+How often, in milliseconds, the TUI looks at the selected worktree's agent pane to update the AGENT OUTPUT tab and whether the agent waits for input. Polls that find nothing new double the interval up to {{ backtick }}detail_idle_poll_ms{{ backtick }}; new output, passing keys through to the agent, or selecting another worktree brings it back to {{ backtick }}detail_poll_ms{{ backtick }}. Raise {{ backtick }}detail_idle_poll_ms{{ backtick }} to save battery when sprout sits open all day, or lower {{ backtick }}detail_poll_ms{{ backtick }} for snappier output.
+
+{{ backtick }}detail_capture_lines{{ backtick }} caps how many lines of the agent pane each poll captures; the Details pane never captures more than it can show.
+
+### diff_files_cache_ms
+
+How long, in milliseconds, the GIT DIFF tab reuses a worktree's list of changed files ({{ backtick }}diff_files_cache_ms{{ backtick }}) and a file's patch ({{ backtick }}diff_patch_cache_ms{{ backtick }}) before asking git again. Uncommitted edits show up once they expire; {{ backtick }}0{{ backtick }} asks git on every redraw. Commits and checkouts are noticed right away either way.
+
 ### agent_command_*
 
 Custom commands for different AI agent types. Replace {{ backtick }}*{{ backtick }} with the agent type (e.g., {{ backtick }}agent_command_codex{{ backtick }}).
@@ -166,6 +617,79 @@ Examples:
 - {{ backtick }}agent_command_codex = "codex"{{ backtick }}
 - {{ backtick }}agent_command_aider = "aider --model gpt-4"{{ backtick }}
 - {{ backtick }}agent_command_claude = "claude-code"{{ backtick }}
+
+### filters
+
+Named worktree filters, so frequent views are one keystroke away: {{ backtick }}f{{ backtick }} in the TUI cycles through them by name and then back to no filter, and {{ backtick }}sprout list --filter <name>{{ backtick }} lists the worktrees one keeps. Set them in a {{ backtick }}[filters]{{ backtick }} table of the global config or of a repository's {{ backtick }}.sprout.toml{{ backtick }}, or per repository under {{ backtick }}[repos.<name>.filters]{{ backtick }}:
+
+{{ backtick }}{{ backtick }}{{ backtick }}toml
+[filters]
+mine = "label:mine"
+review = "agent:ready status:dirty"
+urgent = "priority:high"
+{{ backtick }}{{ backtick }}{{ backtick }}
+
+A filter is written like the TUI's {{ backtick }}/{{ backtick }} filter: words fuzzy-match the branch, and these terms keep only the worktrees that match them:
+
+- {{ backtick }}label:<name>{{ backtick }}: has the label (see {{ backtick }}sprout label{{ backtick }}); several must all match
+- {{ backtick }}status:<status>{{ backtick }}: {{ backtick }}clean{{ backtick }}, {{ backtick }}dirty{{ backtick }}, {{ backtick }}rebasing{{ backtick }}, {{ backtick }}rebased{{ backtick }}, or {{ backtick }}rebase-aborted{{ backtick }}; {{ backtick }}dirty{{ backtick }} also keeps worktrees with uncommitted changes or a rebase, merge, cherry-pick, or revert stopped midway, whatever their status shows
+- {{ backtick }}agent:<state>{{ backtick }}: {{ backtick }}ready{{ backtick }} (waiting for input), {{ backtick }}busy{{ backtick }}, {{ backtick }}exited{{ backtick }}, {{ backtick }}running{{ backtick }}, {{ backtick }}crashed{{ backtick }}, or {{ backtick }}none{{ backtick }}
+- {{ backtick }}priority:<level>{{ backtick }}: {{ backtick }}high{{ backtick }}, {{ backtick }}normal{{ backtick }}, or {{ backtick }}low{{ backtick }}
+
+Apart from labels, one term of each kind has to match, so {{ backtick }}status:clean status:dirty{{ backtick }} keeps both. {{ backtick }}dirty{{ backtick }} and {{ backtick }}agent-ready{{ backtick }} are built in; set one to {{ backtick }}""{{ backtick }} to drop it.
+
+### hooks
+
+Shell commands sprout runs when something happens to a worktree, in a {{ backtick }}[hooks]{{ backtick }} table of the global config or of a repository's {{ backtick }}.sprout.toml{{ backtick }}, or per repository under {{ backtick }}[repos.<name>.hooks]{{ backtick }}:
+
+- {{ backtick }}on_create{{ backtick }}: after sprout creates a worktree
+- {{ backtick }}on_remove{{ backtick }}: after sprout removes a worktree
+- {{ backtick }}on_agent_ready{{ backtick }}: when the TUI sees an agent finish and wait for input
+- {{ backtick }}on_attach{{ backtick }}: before sprout attaches to a worktree's tmux session, from {{ backtick }}sprout go{{ backtick }}, {{ backtick }}sprout launch{{ backtick }}, {{ backtick }}sprout agent attach{{ backtick }}, or the TUI
+
+{{ backtick }}{{ backtick }}{{ backtick }}toml
+[hooks]
+on_create = "direnv allow && make deps"
+on_agent_ready = "terminal-notifier -message \"$SPROUT_BRANCH is ready\""
+{{ backtick }}{{ backtick }}{{ backtick }}
+
+Each hook runs with {{ backtick }}sh -c{{ backtick }}, or {{ backtick }}cmd /C{{ backtick }} on Windows, in the worktree, or in the repository once the worktree is gone, with these environment variables: {{ backtick }}SPROUT_HOOK{{ backtick }} (the hook's name), {{ backtick }}SPROUT_EVENT{{ backtick }} (the event type, as in {{ backtick }}sprout events{{ backtick }}; empty for {{ backtick }}on_attach{{ backtick }}), {{ backtick }}SPROUT_WORKTREE{{ backtick }}, {{ backtick }}SPROUT_BRANCH{{ backtick }}, {{ backtick }}SPROUT_REPO{{ backtick }}, {{ backtick }}SPROUT_REPO_ROOT{{ backtick }} and {{ backtick }}SPROUT_SESSION{{ backtick }}.
+
+Hooks run in the background: sprout does not wait for them, and one started by a CLI command keeps running after it exits. Their output goes to {{ backtick }}sprout-hooks.log{{ backtick }} next to the debug log, and the debug log records when each hook started and how it exited. {{ backtick }}SPROUT_HOOK_ON_CREATE{{ backtick }} and the like set a hook from the environment.
+
+### windows
+
+{{ backtick }}[[windows]]{{ backtick }} replaces {{ backtick }}session_tools{{ backtick }} with windows and panes of your own. Set them at the top level of {{ backtick }}.sprout.toml{{ backtick }}, or per repository in the global config under {{ backtick }}[[repos.<name>.windows]]{{ backtick }}.
+
+Each window has a {{ backtick }}name{{ backtick }}, a tmux {{ backtick }}layout{{ backtick }} ({{ backtick }}even-horizontal{{ backtick }}, {{ backtick }}even-vertical{{ backtick }}, {{ backtick }}main-horizontal{{ backtick }}, {{ backtick }}main-vertical{{ backtick }}, {{ backtick }}tiled{{ backtick }}, or a layout string saved from {{ backtick }}tmux list-windows{{ backtick }}), an optional {{ backtick }}dir{{ backtick }} and {{ backtick }}env{{ backtick }} for its panes, and {{ backtick }}[[windows.panes]]{{ backtick }}. Each pane can set:
+
+- {{ backtick }}run{{ backtick }}: the command it runs; empty opens a shell
+- {{ backtick }}dir{{ backtick }}: where it starts (see {{ backtick }}worktree_subdir{{ backtick }})
+- {{ backtick }}size{{ backtick }}: its width or height along the window's split, as a percentage such as {{ backtick }}"30%"{{ backtick }} or a number of cells. Panes without a size share what is left. A window with sizes and no {{ backtick }}layout{{ backtick }} keeps its split sizes instead of being evened out
+- {{ backtick }}focus{{ backtick }}: select this pane once the window is built
+- {{ backtick }}env{{ backtick }}: environment variables for its command, on top of the window's {{ backtick }}env{{ backtick }}
+
+{{ backtick }}{{ backtick }}{{ backtick }}toml
+[[windows]]
+name = "dev"
+layout = "main-vertical"
+
+[[windows.panes]]
+run = "nvim ."
+size = "65%"
+focus = true
+
+[[windows.panes]]
+run = "npm run dev"
+env = { PORT = "{{ .OpenBrace }}port{{ .CloseBrace }}", DATABASE_URL = "postgres://localhost/app_{{ .OpenBrace }}slug{{ .CloseBrace }}" }
+
+[[windows.panes]]
+run = "npm test -- --watch"
+{{ backtick }}{{ backtick }}{{ backtick }}
+
+{{ backtick }}env{{ backtick }} values can use {{ backtick }}{{ .OpenBrace }}branch{{ .CloseBrace }}{{ backtick }}, {{ backtick }}{{ .OpenBrace }}slug{{ .CloseBrace }}{{ backtick }} (the branch as a name safe for hosts and containers, such as {{ backtick }}feat-login{{ backtick }}), {{ backtick }}{{ .OpenBrace }}repo{{ .CloseBrace }}{{ backtick }}, {{ backtick }}{{ .OpenBrace }}worktree{{ .CloseBrace }}{{ backtick }}, and {{ backtick }}{{ .OpenBrace }}port{{ .CloseBrace }}{{ backtick }}, a port between 20000 and 29999 picked from the worktree path so every worktree gets its own. tmux sets them with {{ backtick }}-e{{ backtick }} (tmux 3.0 or newer); with {{ backtick }}container_command{{ backtick }} the pane's command exports them inside the container.
+
+Pane sizes use {{ backtick }}split-window -l{{ backtick }} and {{ backtick }}resize-pane{{ backtick }}, which take percentages from tmux 3.1 on. Run {{ backtick }}sprout config lint{{ backtick }} to check the windows, and {{ backtick }}sprout launch <target> --dry-run{{ backtick }} to see the tmux commands they turn into.
 `
 
 func main() {
@@ -184,6 +708,27 @@ func main() {
 			EnvVar:      "SPROUT_WORKTREE_ROOT_TEMPLATE",
 			Description: "Template for worktree root directory (\\{repo\\} is replaced with repo name)",
 		},
+		{
+			Name:        "worktree_path_template",
+			Type:        "string",
+			Default:     "\\{branch\\}",
+			EnvVar:      "SPROUT_WORKTREE_PATH_TEMPLATE",
+			Description: "Directory of each worktree under the root (\\{branch\\}, \\{type\\}, \\{slug\\}, \\{date\\})",
+		},
+		{
+			Name:        "flatten_branch_dirs",
+			Type:        "bool",
+			Default:     "false",
+			EnvVar:      "SPROUT_FLATTEN_BRANCH_DIRS",
+			Description: "Map branch slashes to dashes in worktree directory names",
+		},
+		{
+			Name:        "worktree_subdir",
+			Type:        "string",
+			Default:     "",
+			EnvVar:      "SPROUT_WORKTREE_SUBDIR",
+			Description: "Directory inside each worktree where sessions and the agent start",
+		},
 		{
 			Name:        "auto_launch",
 			Type:        "bool",
@@ -212,6 +757,13 @@ func main() {
 			EnvVar:      "SPROUT_UPDATE_CHECK",
 			Description: "Check GitHub for updates once per day",
 		},
+		{
+			Name:        "update_channel",
+			Type:        "string",
+			Default:     "stable",
+			EnvVar:      "SPROUT_UPDATE_CHANNEL",
+			Description: "Release channel for update checks (stable, prerelease)",
+		},
 		{
 			Name:        "launch_nvim",
 			Type:        "bool",
@@ -233,6 +785,13 @@ func main() {
 			EnvVar:      "SPROUT_AGENT_COMMAND",
 			Description: "Default agent command (deprecated: use default_agent_type)",
 		},
+		{
+			Name:        "agent_args",
+			Type:        "string",
+			Default:     "",
+			EnvVar:      "SPROUT_AGENT_ARGS",
+			Description: "Extra arguments appended to the agent command",
+		},
 		{
 			Name:        "default_agent_type",
 			Type:        "string",
@@ -247,6 +806,293 @@ func main() {
 			EnvVar:      "SPROUT_SESSION_PREFIX",
 			Description: "Prefix for tmux session names",
 		},
+		{
+			Name:        "session_mode",
+			Type:        "string",
+			Default:     "per-worktree",
+			EnvVar:      "SPROUT_SESSION_MODE",
+			Description: "A tmux session per worktree or per repository (per-worktree, per-repo)",
+		},
+		{
+			Name:        "slug_mode",
+			Type:        "string",
+			Default:     "ascii",
+			EnvVar:      "SPROUT_SLUG_MODE",
+			Description: "How non-ASCII letters in feature names become branch slugs (ascii, unicode)",
+		},
+		{
+			Name:        "branch_types",
+			Type:        "array",
+			Default:     "[\"feat\",\"fix\",\"chore\",\"docs\",\"refactor\",\"test\"]",
+			EnvVar:      "SPROUT_BRANCH_TYPES",
+			Description: "Types accepted by sprout new (empty accepts any)",
+		},
+		{
+			Name:        "branch_template",
+			Type:        "string",
+			Default:     "\\{type\\}/\\{slug\\}",
+			EnvVar:      "SPROUT_BRANCH_TEMPLATE",
+			Description: "How sprout new builds branch names (\\{type\\}, \\{slug\\}, \\{user\\}, \\{date\\})",
+		},
+		{
+			Name:        "branch_pattern",
+			Type:        "string",
+			Default:     "",
+			EnvVar:      "SPROUT_BRANCH_PATTERN",
+			Description: "Regex every new branch must match",
+		},
+		{
+			Name:        "git_backend",
+			Type:        "string",
+			Default:     "exec",
+			EnvVar:      "SPROUT_GIT_BACKEND",
+			Description: "How worktree status and branches are read (exec, gogit)",
+		},
+		{
+			Name:        "dirty_untracked",
+			Type:        "string",
+			Default:     "periodic",
+			EnvVar:      "SPROUT_DIRTY_UNTRACKED",
+			Description: "How often untracked files count toward a dirty worktree (all, periodic, no)",
+		},
+		{
+			Name:        "log_level",
+			Type:        "string",
+			Default:     "info",
+			EnvVar:      "SPROUT_DEBUG",
+			Description: "Debug log verbosity (error, info, debug, trace)",
+		},
+		{
+			Name:        "wip_limit",
+			Type:        "int",
+			Default:     "0",
+			EnvVar:      "SPROUT_WIP_LIMIT",
+			Description: "Maximum linked worktrees before creation asks to finish or prune one (0 = unlimited)",
+		},
+		{
+			Name:        "on_quit",
+			Type:        "string",
+			Default:     "none",
+			EnvVar:      "SPROUT_ON_QUIT",
+			Description: "What quitting the TUI does with running agents (none, ask, stop-agents, detach)",
+		},
+		{
+			Name:        "launch_backend",
+			Type:        "string",
+			Default:     "tmux",
+			EnvVar:      "SPROUT_LAUNCH_BACKEND",
+			Description: "Where worktrees and agents are launched (tmux, wt, none)",
+		},
+		{
+			Name:        "agent_resume_prompt",
+			Type:        "string",
+			Default:     "",
+			EnvVar:      "SPROUT_AGENT_RESUME_PROMPT",
+			Description: "Prompt sent to agents resumed after a tmux restart ({branch}, {last_prompt})",
+		},
+		{
+			Name:        "notify_desktop",
+			Type:        "array",
+			Default:     "[]",
+			EnvVar:      "SPROUT_NOTIFY_DESKTOP",
+			Description: "Agent events (ready, exited, idle) shown as desktop notifications",
+		},
+		{
+			Name:        "notify_bell",
+			Type:        "array",
+			Default:     "[]",
+			EnvVar:      "SPROUT_NOTIFY_BELL",
+			Description: "Agent events (ready, exited, idle) that ring the terminal bell",
+		},
+		{
+			Name:        "notify_webhook",
+			Type:        "string",
+			Default:     "",
+			EnvVar:      "SPROUT_NOTIFY_WEBHOOK",
+			Description: "URL that receives a JSON POST for agent events",
+		},
+		{
+			Name:        "notify_webhook_events",
+			Type:        "array",
+			Default:     "[\"ready\", \"exited\"]",
+			EnvVar:      "SPROUT_NOTIFY_WEBHOOK_EVENTS",
+			Description: "Agent events posted to notify_webhook",
+		},
+		{
+			Name:        "agent_idle_minutes",
+			Type:        "int",
+			Default:     "15",
+			EnvVar:      "SPROUT_AGENT_IDLE_MINUTES",
+			Description: "Minutes an agent may wait for input before the idle warning (0 disables it)",
+		},
+		{
+			Name:        "auto_fetch_minutes",
+			Type:        "int",
+			Default:     "0",
+			EnvVar:      "SPROUT_AUTO_FETCH_MINUTES",
+			Description: "Minutes between background fetches of the repository while the TUI runs (0 disables it)",
+		},
+		{
+			Name:        "agent_exit_keys",
+			Type:        "array",
+			Default:     "[\"C-c\",\"C-c\"]",
+			EnvVar:      "SPROUT_AGENT_EXIT_KEYS",
+			Description: "tmux keys asking an agent to exit before it is stopped or its worktree removed",
+		},
+		{
+			Name:        "agent_exit_wait",
+			Type:        "int",
+			Default:     "5",
+			EnvVar:      "SPROUT_AGENT_EXIT_WAIT",
+			Description: "Seconds to wait for an agent to exit after agent_exit_keys (0 kills it right away)",
+		},
+		{
+			Name:        "undo_minutes",
+			Type:        "int",
+			Default:     "10",
+			EnvVar:      "SPROUT_UNDO_MINUTES",
+			Description: "Minutes sprout undo can restore a removed worktree (0 disables it)",
+		},
+		{
+			Name:        "export_dir",
+			Type:        "string",
+			Default:     "~/.local/share/sprout/exports",
+			EnvVar:      "SPROUT_EXPORT_DIR",
+			Description: "Where the TUI writes exported agent transcripts and patches",
+		},
+		{
+			Name:        "ssh_host",
+			Type:        "string",
+			Default:     "",
+			EnvVar:      "SPROUT_SSH_HOST",
+			Description: "Host whose repository sprout manages over ssh",
+		},
+		{
+			Name:        "ssh_repo",
+			Type:        "string",
+			Default:     "",
+			EnvVar:      "SPROUT_SSH_REPO",
+			Description: "Path of the repository on ssh_host",
+		},
+		{
+			Name:        "container_command",
+			Type:        "string",
+			Default:     "",
+			EnvVar:      "SPROUT_CONTAINER_COMMAND",
+			Description: "Prefix running session commands in the worktree's container",
+		},
+		{
+			Name:        "container_up",
+			Type:        "string",
+			Default:     "",
+			EnvVar:      "SPROUT_CONTAINER_UP",
+			Description: "Starts the worktree's container before its session is launched",
+		},
+		{
+			Name:        "container_down",
+			Type:        "string",
+			Default:     "",
+			EnvVar:      "SPROUT_CONTAINER_DOWN",
+			Description: "Stops the worktree's container after its session is detached or removed",
+		},
+		{
+			Name:        "check_command",
+			Type:        "string",
+			Default:     "",
+			EnvVar:      "SPROUT_CHECK_COMMAND",
+			Description: "Test or lint command sprout check runs in a worktree",
+		},
+		{
+			Name:        "color",
+			Type:        "string",
+			Default:     "auto",
+			EnvVar:      "SPROUT_COLOR",
+			Description: "When to use color (auto, always, never); NO_COLOR disables it",
+		},
+		{
+			Name:        "theme",
+			Type:        "string",
+			Default:     "dark",
+			EnvVar:      "SPROUT_THEME",
+			Description: "Color palette (dark, light)",
+		},
+		{
+			Name:        "show_resources",
+			Type:        "bool",
+			Default:     "false",
+			EnvVar:      "SPROUT_SHOW_RESOURCES",
+			Description: "Show CPU and memory of each worktree's tmux session in the TUI",
+		},
+		{
+			Name:        "repo_sidebar",
+			Type:        "bool",
+			Default:     "false",
+			EnvVar:      "SPROUT_REPO_SIDEBAR",
+			Description: "Show the repositories beside the current one in a TUI sidebar",
+		},
+		{
+			Name:        "columns",
+			Type:        "array",
+			Default:     "[]",
+			EnvVar:      "SPROUT_COLUMNS",
+			Description: "Worktree table columns, in order, for sprout list and the TUI",
+		},
+		{
+			Name:        "path_display",
+			Type:        "string",
+			Default:     "absolute",
+			EnvVar:      "SPROUT_PATH_DISPLAY",
+			Description: "How table paths are shown (absolute, home, relative)",
+		},
+		{
+			Name:        "sort",
+			Type:        "string",
+			Default:     "path",
+			EnvVar:      "SPROUT_SORT",
+			Description: "Worktree order for sprout list and the TUI (path, active, idle)",
+		},
+		{
+			Name:        "details_percent",
+			Type:        "int",
+			Default:     "60",
+			EnvVar:      "SPROUT_DETAILS_PERCENT",
+			Description: "Share of the TUI height given to the Details pane (10-90)",
+		},
+		{
+			Name:        "detail_poll_ms",
+			Type:        "int",
+			Default:     "150",
+			EnvVar:      "SPROUT_DETAIL_POLL_MS",
+			Description: "Milliseconds between Details pane updates while the selected agent is busy (20-5000)",
+		},
+		{
+			Name:        "detail_idle_poll_ms",
+			Type:        "int",
+			Default:     "2000",
+			EnvVar:      "SPROUT_DETAIL_IDLE_POLL_MS",
+			Description: "Slowest the Details pane updates once nothing changes (20-60000)",
+		},
+		{
+			Name:        "detail_capture_lines",
+			Type:        "int",
+			Default:     "60",
+			EnvVar:      "SPROUT_DETAIL_CAPTURE_LINES",
+			Description: "Most lines of agent output the Details pane captures (20-2000)",
+		},
+		{
+			Name:        "diff_files_cache_ms",
+			Type:        "int",
+			Default:     "900",
+			EnvVar:      "SPROUT_DIFF_FILES_CACHE_MS",
+			Description: "Milliseconds the GIT DIFF tab reuses its list of changed files (0-60000)",
+		},
+		{
+			Name:        "diff_patch_cache_ms",
+			Type:        "int",
+			Default:     "2000",
+			EnvVar:      "SPROUT_DIFF_PATCH_CACHE_MS",
+			Description: "Milliseconds the GIT DIFF tab reuses a file's patch (0-60000)",
+		},
 		{
 			Name:        "agent_command_*",
 			Type:        "string",
@@ -254,6 +1100,20 @@ func main() {
 			EnvVar:      "SPROUT_AGENT_COMMAND_*",
 			Description: "Custom command for specific agent type (* = agent type)",
 		},
+		{
+			Name:        "filters.*",
+			Type:        "string",
+			Default:     "dirty, agent-ready",
+			EnvVar:      "-",
+			Description: "Named worktree filter for sprout list --filter and the TUI's f key (* = preset name)",
+		},
+		{
+			Name:        "hooks.*",
+			Type:        "string",
+			Default:     "",
+			EnvVar:      "SPROUT_HOOK_*",
+			Description: "Shell command run on a worktree event (* = on_create, on_remove, on_agent_ready, on_attach)",
+		},
 		{
 			Name:        "layout_<repo>_win_<name>_pane_<idx>",
 			Type:        "string",